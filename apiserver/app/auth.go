@@ -0,0 +1,70 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
+)
+
+// tokenAuth authenticates the requests to the resource API with the API tokens in the Authorization header.
+// the requests without a token are rejected only if authRequired has been set in server block, so that
+// the clients which have not been configured with tokens can work as before
+func tokenAuth() sysadmServer.HandlerFunc {
+	return func(c *sysadmServer.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.Next()
+			return
+		}
+
+		token, ok := userApp.BearerToken(c.Request)
+		if !ok {
+			if runData.runConf.ConfServer.AuthRequired {
+				responseUnauthorized(c)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		user, e := userApp.AuthenticateApiToken(token, userApp.ApiTokenScopeApiserver)
+		if e != nil {
+			level := "error"
+			if errors.Is(e, userApp.ErrApiTokenInvalid) || errors.Is(e, userApp.ErrApiTokenScope) {
+				level = "debug"
+			}
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20090001, level, "authenticate request %s with bearer token error: %s", c.Request.URL.Path, e)})
+			responseUnauthorized(c)
+			return
+		}
+
+		userApp.SetApiTokenUser(c, user)
+		c.Next()
+	}
+}
+
+// responseUnauthorized aborts the request and asks the client for a bearer token
+func responseUnauthorized(c *sysadmServer.Context) {
+	c.Header("WWW-Authenticate", "Bearer realm=\"sysadm apiserver\"")
+	c.AbortWithStatusJSON(http.StatusUnauthorized, sysadmServer.H{"status": "unauthorized"})
+}
@@ -217,6 +217,16 @@ func validateGlobalBlock(conf *Conf) (bool, []sysadmerror.Sysadmerror) {
 	}
 	runData.runConf.ConfGlobal.ExtraSans = newExtraSans
 
+	leaseDuration := conf.ConfGlobal.LeaseDuration
+	if leaseDuration == 0 {
+		leaseDuration = defaultLeaseDuration
+	}
+	if leaseDuration < minLeaseDuration {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20020043, "fatal", "lease duration %d should not less than %d seconds", leaseDuration, minLeaseDuration))
+		return false, errs
+	}
+	runData.runConf.ConfGlobal.LeaseDuration = leaseDuration
+
 	return true, errs
 }
 
@@ -239,6 +249,7 @@ func validateServerBlock(conf *Conf) (bool, []sysadmerror.Sysadmerror) {
 
 	runData.runConf.ConfServer.Insecret = conf.ConfServer.Insecret
 	runData.runConf.ConfServer.IsTls = conf.ConfServer.IsTls
+	runData.runConf.ConfServer.AuthRequired = conf.ConfServer.AuthRequired
 
 	if runData.runConf.ConfServer.IsTls {
 		e := prepareApiServerCerts()
@@ -450,6 +461,27 @@ func validateDbBlock(conf *Conf) (bool, []sysadmerror.Sysadmerror) {
 	}
 	runData.runConf.ConfDB.MaxIdleConns = maxIdleConns
 
+	if _, e := db.ParseEndpoints(conf.ConfDB.Replicas, dbPort); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20020041, "error", "replicas of DB server are not valid: %s", e))
+		return false, errs
+	}
+	runData.runConf.ConfDB.Replicas = conf.ConfDB.Replicas
+
+	if _, e := db.ParseEndpoints(conf.ConfDB.Standbys, dbPort); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20020042, "error", "standbys of DB server are not valid: %s", e))
+		return false, errs
+	}
+	runData.runConf.ConfDB.Standbys = conf.ConfDB.Standbys
+
+	healthCheckInterval := conf.ConfDB.HealthCheckInterval
+	if healthCheckInterval == 0 {
+		healthCheckInterval = defaultDBHealthCheckInterval
+	}
+	if healthCheckInterval < 0 {
+		healthCheckInterval = 0
+	}
+	runData.runConf.ConfDB.HealthCheckInterval = healthCheckInterval
+
 	return true, errs
 }
 
@@ -572,7 +604,11 @@ func initDBEntity() (bool, []sysadmerror.Sysadmerror) {
 		MaxIdleConns: definedConf.MaxIdleConns,
 		Connect:      nil,
 		Entity:       nil,
+
+		HealthCheckInterval: definedConf.HealthCheckInterval,
 	}
+	dbConf.Replicas, _ = sysadmDB.ParseEndpoints(definedConf.Replicas, definedConf.Port)
+	dbConf.Standbys, _ = sysadmDB.ParseEndpoints(definedConf.Standbys, definedConf.Port)
 
 	newDBConf, err := sysadmDB.InitDbConfig(&dbConf, runData.workingRoot)
 	errs = append(errs, err...)
@@ -591,6 +627,7 @@ func initDBEntity() (bool, []sysadmerror.Sysadmerror) {
 	}
 
 	runData.dbEntity = entity
+	runData.dbConf = newDBConf
 	return true, errs
 }
 
@@ -40,6 +40,10 @@ type ConfGlobal struct {
 
 	// Optional extra Subject Alternative Names (SANs) to use for the API Server serving certificate. Can be both IP addresses and DNS names.
 	ExtraSans []string `form:"extraSans" json:"extraSans" yaml:"extraSans" xml:"extraSans"`
+
+	// leaseDuration is the duration in seconds of the leadership among apiservers. the singleton duties will be taken
+	// over by another apiserver within leaseDuration after the leader is down
+	LeaseDuration int `form:"leaseDuration" json:"leaseDuration" yaml:"leaseDuration" xml:"leaseDuration"`
 }
 
 // for server block
@@ -64,6 +68,10 @@ type ConfServer struct {
 
 	// key path of apiServer if apiServer listen on TLS
 	Key string `form:"key" json:"key" yaml:"key" xml:"key"`
+
+	// authRequired specifies whether the requests to the resource API must carry an API token as a bearer token.
+	// the tokens are verified if them are carried even though authRequired is false
+	AuthRequired bool `form:"authRequired" json:"authRequired" yaml:"authRequired" xml:"authRequired"`
 }
 
 // for DB block
@@ -85,6 +93,15 @@ type ConfDB struct {
 
 	// max number of idle connections
 	MaxIdleConns int `form:"maxIdleConns" json:"maxIdleConns" yaml:"maxIdleConns" xml:"maxIdleConns"`
+
+	// read-only replicas in "host:port" format, read queries will be sent to them
+	Replicas []string `form:"replicas" json:"replicas" yaml:"replicas" xml:"replicas"`
+
+	// standby servers in "host:port" format which may be promoted to primary
+	Standbys []string `form:"standbys" json:"standbys" yaml:"standbys" xml:"standbys"`
+
+	// interval in seconds of health probes for DB servers. 0 means the default interval and a negative value disables health probes
+	HealthCheckInterval int `form:"healthCheckInterval" json:"healthCheckInterval" yaml:"healthCheckInterval" xml:"healthCheckInterval"`
 }
 
 // apiserver configuration
@@ -125,11 +142,17 @@ type runningData struct {
 	// db entity
 	dbEntity sysadmDB.DbEntity
 
+	// db configuration which dbEntity was created with
+	dbConf *sysadmDB.DbConfig
+
 	// configuration data for apiserver running
 	runConf Conf
 
 	// logger entity
 	logEntity *sysadmLog.LoggerConfig
+
+	// elector of the leader among apiservers
+	elector *redis.LeaderElector
 }
 
 var runData runningData = runningData{
@@ -137,6 +160,7 @@ var runData runningData = runningData{
 	redisEntity: nil,
 	redisCtx:    nil,
 	dbEntity:    nil,
+	dbConf:      nil,
 	runConf: Conf{
 		Version:    config.Version{},
 		ConfFile:   "",
@@ -147,4 +171,5 @@ var runData runningData = runningData{
 		ConfDB:     ConfDB{},
 	},
 	logEntity: nil,
+	elector:   nil,
 }
@@ -88,6 +88,18 @@ var defaultMaxDBOpenConns int = 20
 // max number of idle connections
 var defaultMaxDBIdleConns int = 5
 
+// interval in seconds of health probes for DB servers
+var defaultDBHealthCheckInterval int = 10
+
+// duration in seconds of the leadership among apiservers
+var defaultLeaseDuration int = 15
+
+// min duration in seconds of the leadership among apiservers
+var minLeaseDuration int = 3
+
+// the key in redis which the leader of apiservers holds
+var leaderElectionKey string = "/apiserver/leader"
+
 // over time of command execution, second
 var defaultMaxExecuteTime int = 3600
 
@@ -0,0 +1,151 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	sysadmAZ "sysadm/availablezone/app"
+	sysadmDC "sysadm/datacenter/app"
+	sysadmK8sCluster "sysadm/k8scluster/app"
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/redis"
+	"sysadm/sysadmerror"
+	sysadmSetting "sysadm/syssetting/app"
+)
+
+/*
+high availability of apiservers:
+all apiservers serve requests, but the singleton duties, such as command scheduling, crontab dispatching, heartbeat
+timeouts and retention purges, should run on exactly one of them. so apiservers elect a leader by a lock in redis, the
+duties registered by RegisterLeaderDuty run on the leader only, and they are stopped when the leadership is lost.
+another apiserver will become the leader within the lease duration after the leader is down.
+only the retention purge is registered now, as there are no loops of command scheduling, crontab dispatching and
+heartbeat timeouts in apiserver yet. they should be registered by RegisterLeaderDuty when they are added.
+*/
+
+// purgeInterval is the interval of purging the deleted objects which are out of their retention
+const purgeInterval = time.Hour
+
+// leaderDuties are the duties which run on the leader only
+var leaderDuties []leaderDuty
+
+// leaderDutyLock protects leaderDuties
+var leaderDutyLock sync.Mutex
+
+// register the builtin singleton duties and the objects which deleted data are purged by apiserver
+func init() {
+	RegisterLeaderDuty("purge", runPurgeDuty)
+
+	sysadmObjects.RegisterPurger(sysadmDC.New())
+	sysadmObjects.RegisterPurger(sysadmAZ.New())
+	sysadmObjects.RegisterPurger(sysadmK8sCluster.New())
+}
+
+// RegisterLeaderDuty register a duty which runs on the leader of apiservers only. run is called in a new goroutine
+// when this apiserver becomes the leader, and ctx passed to it will be canceled when the leadership is lost.
+func RegisterLeaderDuty(name string, run func(ctx context.Context)) {
+	if run == nil {
+		return
+	}
+
+	leaderDutyLock.Lock()
+	defer leaderDutyLock.Unlock()
+	leaderDuties = append(leaderDuties, leaderDuty{name: name, run: run})
+}
+
+// IsLeader return true if this apiserver is the leader now
+func IsLeader() bool {
+	if runData.elector == nil {
+		return false
+	}
+
+	return runData.elector.IsLeader()
+}
+
+// startLeaderElection take part in the election of the leader among apiservers
+func startLeaderElection() error {
+	if runData.redisEntity == nil {
+		return fmt.Errorf("redis entity has not be initated")
+	}
+
+	hostname, e := os.Hostname()
+	if e != nil {
+		return e
+	}
+	identity := fmt.Sprintf("%s:%d:%d", hostname, runData.runConf.ConfServer.Port, os.Getpid())
+
+	conf := redis.LeaderElectionConfig{
+		Key:              leaderElectionKey,
+		Identity:         identity,
+		TTL:              time.Duration(runData.runConf.ConfGlobal.LeaseDuration) * time.Second,
+		OnStartedLeading: runLeaderDuties,
+		OnStoppedLeading: func() {
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20080002, "warning", "apiserver %s is not the leader any more", identity)})
+		},
+	}
+	elector, e := redis.NewLeaderElector(runData.redisEntity, conf)
+	if e != nil {
+		return e
+	}
+	runData.elector = elector
+
+	ctx := runData.redisCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go elector.Run(ctx)
+
+	return nil
+}
+
+// runLeaderDuties start all registered duties after this apiserver becomes the leader
+func runLeaderDuties(ctx context.Context) {
+	leaderDutyLock.Lock()
+	duties := append([]leaderDuty{}, leaderDuties...)
+	leaderDutyLock.Unlock()
+
+	var errs []sysadmerror.Sysadmerror
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20080001, "info", "apiserver becomes the leader, starting %d singleton duties", len(duties)))
+	logErrors(errs)
+
+	for _, duty := range duties {
+		go duty.run(ctx)
+	}
+}
+
+// runPurgeDuty purge the deleted objects which are out of their retention until ctx is canceled
+func runPurgeDuty(ctx context.Context) {
+	report := func(objectName string, num int, e error) {
+		var errs []sysadmerror.Sysadmerror
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(20080003, "error", "purge deleted %s error: %s", objectName, e))
+		} else if num > 0 {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(20080004, "info", "%d deleted %s have been purged", num, objectName))
+		}
+		logErrors(errs)
+	}
+
+	if e := sysadmObjects.StartPurgeJob(purgeInterval, sysadmSetting.New().GetRetention, report, ctx.Done()); e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20080005, "error", "start purge job error: %s", e)})
+	}
+}
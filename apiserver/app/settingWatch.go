@@ -0,0 +1,112 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/sysadmerror"
+	sysadmSetting "sysadm/syssetting/app"
+)
+
+// settingWatchInterval is the interval which apiserver polls setting changes in
+const settingWatchInterval = 10 * time.Second
+
+// apiServerCert holds the certificate which is serving TLS connections currently.
+// it will be replaced when the certificate or key of apiserver is changed in setting
+var apiServerCert atomic.Pointer[tls.Certificate]
+
+// settingStopCh will never be closed for apiserver running until the process exits
+var settingStopCh = make(chan struct{})
+
+// startSettingWatcher subscribes the changes of settings which apiserver is interesting in
+// and starts the setting watcher
+func startSettingWatcher() error {
+	if runData.dbConf == nil {
+		return fmt.Errorf("DB has not be initated")
+	}
+
+	e := sysadmSetting.SetRunData(runData.dbConf, runData.logEntity, runData.workingRoot)
+	if e != nil {
+		return e
+	}
+
+	// the key of apiserver certificate is encrypted by the master key in setting
+	if _, e := sysadmObjects.LoadMasterKeys(runData.workingRoot); e != nil {
+		return e
+	}
+
+	sysadmSetting.Subscribe(sysadmSetting.SettingKeyForApiServerCert, reloadApiServerCert)
+	sysadmSetting.Subscribe(sysadmSetting.SettingKeyForApiServerCertKey, reloadApiServerCert)
+
+	return sysadmSetting.StartWatcher(settingWatchInterval, settingStopCh)
+}
+
+// loadApiServerCertFromFile loads certificate of apiserver from the files which have be written during starting.
+func loadApiServerCertFromFile(certFile, keyFile string) error {
+	cert, e := tls.LoadX509KeyPair(certFile, keyFile)
+	if e != nil {
+		return e
+	}
+
+	apiServerCert.Store(&cert)
+	return nil
+}
+
+// reloadApiServerCert reloads certificate and key of apiserver from setting after any of them has be changed.
+// the certificate and the key may be changed one by one, so a pair which is not matched is skipped
+// and the certificate serving currently is kept.
+func reloadApiServerCert(change sysadmSetting.SettingChange) {
+	var errs []sysadmerror.Sysadmerror
+
+	if change.Scope != sysadmSetting.SettingScopeGlobal {
+		return
+	}
+
+	certPem, keyPem, e := sysadmSetting.New().GetCertAndKey(sysadmSetting.CertTypeApiServer)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20070001, "error", "get certificate of apiserver from setting error: %s", e))
+		logErrors(errs)
+		return
+	}
+
+	cert, e := tls.X509KeyPair([]byte(certPem), []byte(keyPem))
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20070002, "warning", "certificate and key of apiserver are not matched, keep the current certificate: %s", e))
+		logErrors(errs)
+		return
+	}
+
+	apiServerCert.Store(&cert)
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20070003, "info", "certificate of apiserver has be reloaded at revision %d", change.Revision))
+	logErrors(errs)
+}
+
+// getApiServerCertificate returns the certificate which should be used for TLS handshake
+func getApiServerCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := apiServerCert.Load()
+	if cert == nil {
+		return nil, fmt.Errorf("certificate of apiserver has not be loaded")
+	}
+
+	return cert, nil
+}
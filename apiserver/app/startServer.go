@@ -18,9 +18,11 @@
 package app
 
 import (
+	"crypto/tls"
 	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/wangyysde/sysadmServer"
+	"net/http"
 	"os"
 	"path/filepath"
 	sysadmPki "sysadm/apiserver/pki"
@@ -28,6 +30,7 @@ import (
 	runtime "sysadm/apimachinery/runtime/v1beta1"
 	"sysadm/sysadmerror"
 	"sysadm/syssetting"
+	userApp "sysadm/user/app"
 )
 
 // var exitChan chan os.Signal
@@ -100,19 +103,38 @@ func startDaemon() error {
 		return fmt.Errorf("prepare resource schema data error: %s", e)
 	}
 
+	// the API tokens in the requests are authenticated with the users data
+	e = userApp.SetRunData(runData.dbConf, runData.logEntity, runData.workingRoot)
+	if e != nil {
+		shouldExit = true
+		return fmt.Errorf("set running data for users error: %s", e)
+	}
+
 	if runData.runConf.ConfGlobal.Debug {
 		sysadmServer.SetMode(sysadmServer.DebugMode)
 	} else {
 		sysadmServer.SetMode(sysadmServer.ReleaseMode)
 	}
 	r := sysadmServer.New()
-	r.Use(sysadmServer.Logger(), sysadmServer.Recovery())
+	r.Use(sysadmServer.Logger(), sysadmServer.Recovery(), tokenAuth())
 	e = addResourceHanders(r)
 	if e != nil {
 		shouldExit = true
 		return fmt.Errorf("add resources handlers error: %s", e)
 	}
 
+	e = startSettingWatcher()
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20070004, "warning", "start setting watcher error: %s. settings will not be reloaded", e)})
+	}
+
+	// the singleton duties run on the leader only, all apiservers keep serving requests
+	e = startLeaderElection()
+	if e != nil {
+		shouldExit = true
+		return fmt.Errorf("start leader election error: %s", e)
+	}
+
 	// listen insecret port
 
 	falseStartInSecret = make(chan bool, 1)
@@ -161,7 +183,16 @@ func startSecret(engine *sysadmServer.Engine) {
 	certPath := filepath.Join(runData.workingRoot, pkiPath)
 	certFile := filepath.Join(certPath, apiServerFullCertFile)
 	keyFile := filepath.Join(certPath, apiServerCertKeyFile)
-	e := engine.RunTLS(tlsStr, certFile, keyFile)
+	e := loadApiServerCertFromFile(certFile, keyFile)
+	if e == nil {
+		// certificate is got by GetCertificate so that it can be reloaded without restarting
+		server := &http.Server{
+			Addr:      tlsStr,
+			Handler:   engine,
+			TLSConfig: &tls.Config{GetCertificate: getApiServerCertificate},
+		}
+		e = server.ListenAndServeTLS("", "")
+	}
 	if e != nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20030005, "error", "can not listent TLS service. error %s", e))
 		logErrors(errs)
@@ -18,6 +18,7 @@
 package app
 
 import (
+	"context"
 	runtime "sysadm/apimachinery/runtime/v1beta1"
 )
 
@@ -26,3 +27,9 @@ type resourceRelation struct {
 	childGvk        runtime.GroupVersionKind
 	parentFieldName string
 }
+
+// leaderDuty is a singleton duty which runs only on the leader of apiservers
+type leaderDuty struct {
+	name string
+	run  func(ctx context.Context)
+}
@@ -0,0 +1,33 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/objectsUI"
+)
+
+// delHandler 将请求中指定的可用区标记为已删除
+func delHandler(c *sysadmServer.Context) {
+	objectsUI.SoftDeleteHandler(c, New(), false, runData.sessionName, runData.logEntity, 700090011)
+}
+
+// restoreHandler 恢复请求中指定的已删除可用区
+func restoreHandler(c *sysadmServer.Context) {
+	objectsUI.SoftDeleteHandler(c, New(), true, runData.sessionName, runData.logEntity, 700090015)
+}
@@ -47,11 +47,8 @@ func SetRunData(dbConf *sysadmDB.DbConfig, logEntity *sysadmLog.LoggerConfig, wo
 		return e
 	}
 
-	runData.objectEntiy = Availablezone{
-		Name:      DefaultObjectName,
-		TableName: DefaultTableName,
-		PkName:    DefaultPkName,
-	}
+	runData.objectEntiy = New()
+	sysadmObjects.RegisterPurger(New())
 
 	return nil
 }
@@ -89,6 +86,8 @@ func AddHandlers(r *sysadmServer.Engine) error {
 		//	display.GET("/add", addK8scluster)
 		display.GET("/list", listHandler)
 		display.GET("/addform", addformHandler)
+		display.POST("/del", delHandler)
+		display.POST("/restore", restoreHandler)
 	}
 
 	return nil
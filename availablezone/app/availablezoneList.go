@@ -45,7 +45,7 @@ var allListItems = map[string]string{"TD1": "名称", "TD2": "Name", "TD3": "数
 
 // all popmenu items defined Format:
 // item name, action name, action method
-var allPopMenuItems = []string{"查看详情,detail,GET,page", "编辑可用区,edit,GET,page", "删除可用区,del,POST,tip", "启用可用区,enable,POST,tip", "禁用可用区,disable,POST,tip"}
+var allPopMenuItems = []string{"查看详情,detail,GET,page", "编辑可用区,edit,GET,page", "删除可用区,del,POST,tip", "启用可用区,enable,POST,tip", "禁用可用区,disable,POST,tip", "恢复可用区,restore,POST,tip"}
 
 func listHandler(c *sysadmServer.Context) {
 	var errs []sysadmLog.Sysadmerror
@@ -143,7 +143,7 @@ func listHandler(c *sysadmServer.Context) {
 	ids := objectsUI.GetObjectIdsFromRequest(requestData)
 	searchKeys := []string{"id", "cnName", "enName"}
 	startPos := objectsUI.GetStartPosFromRequest(requestData)
-	azConditions := objectsUI.BuildCondition(requestData, "=0", "datacenterid")
+	azConditions := objectsUI.BuildCondition(requestData, objectsUI.GetDeletedConditionFromRequest(requestData), "datacenterid")
 
 	// get total number of list objects
 	var azEntity sysadmObjects.ObjectEntity
@@ -178,7 +178,7 @@ func listHandler(c *sysadmServer.Context) {
 }
 
 func getRequestData(c *sysadmServer.Context) (map[string]string, error) {
-	requestData, e := utils.NewGetRequestData(c, []string{"groupSelectID", "searchContent", "start", "orderfield", "direction"})
+	requestData, e := utils.NewGetRequestData(c, []string{"groupSelectID", "searchContent", "start", "orderfield", "direction", "deleted"})
 	if e != nil {
 		return requestData, e
 	}
@@ -245,6 +245,10 @@ func prepareObjectData(dcList, azList []interface{}) ([]map[string]interface{},
 			statusStr = "已禁用"
 			popmenuitems = "0,1,2,3"
 		}
+		if lineData.IsDeleted == 1 {
+			statusStr = "已删除"
+			popmenuitems = "0,5"
+		}
 		lineMap["TD5"] = statusStr
 		lineMap["popmenuitems"] = popmenuitems
 		dataList = append(dataList, lineMap)
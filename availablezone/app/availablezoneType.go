@@ -26,12 +26,7 @@ import (
 )
 
 type Availablezone struct {
-	// object name. the value should be "availablezone"
-	Name string
-	// table name which hold project data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[AvailablezoneSchema]
 }
 
 // 可用区信息表结构
@@ -43,13 +38,15 @@ type AvailablezoneSchema struct {
 	// 可用区英文名称
 	EnName string `form:"enName" json:"enName" yaml:"enName" xml:"enName" db:"enName"`
 	// 可用区所属的数据中心ID
-	Datacenterid uint `form:"datacenterid" json:"datacenterid" yaml:"datacenterid" xml:"datacenterid" db:"datacenterid"`
+	Datacenterid uint `form:"datacenterid" json:"datacenterid" yaml:"datacenterid" xml:"datacenterid" db:"datacenterid" references:"datacenter"`
 	// 值班电话
 	DutyTel string `form:"dutyTel" json:"dutyTel" yaml:"dutyTel" xml:"dutyTel" db:"dutyTel"`
 	// 状态0未启用 1已启用 2 已停用
 	Status int `form:"status" json:"status" yaml:"status" xml:"status" db:"status"`
 	// 是否删除 0正常 1已删除
-	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted"`
+	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted" softdelete:"flag"`
+	// 删除时间截
+	DeletedTime int `form:"deletedTime" json:"deletedTime" yaml:"deletedTime" xml:"deletedTime" db:"deletedTime" softdelete:"time"`
 
 	// 创建时间
 	CreateTime string `form:"createTime" json:"createTime" yaml:"createTime" xml:"createTime" db:"createTime"`
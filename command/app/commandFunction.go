@@ -28,6 +28,11 @@ import (
 	"time"
 )
 
+// register command to the dependency graph of objects
+func init() {
+	sysadmObjects.RegisterReferrer(sysadmObjects.NewRepository[CommandDefinedSchema](defaultObjectName, defaultTableName, defaultPkName))
+}
+
 func New(dbConfig *sysadmDB.DbConfig, workingRoot string) (Command, error) {
 	ret := Command{}
 
@@ -59,137 +64,13 @@ func New(dbConfig *sysadmDB.DbConfig, workingRoot string) (Command, error) {
 		}
 	}
 
-	ret.Name = defaultObjectName
-	ret.TableName = defaultTableName
-	ret.PkName = defaultPkName
+	ret.Repository = sysadmObjects.NewRepository[CommandDefinedSchema](defaultObjectName, defaultTableName, defaultPkName)
 	return ret, nil
 }
 
-func (c Command) GetObjectInfoByID(id string) (interface{}, error) {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return nil, fmt.Errorf("can not get Command defined information with empty ID")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(c.TableName, c.PkName, id)
-	if e != nil {
-		return nil, e
-	}
-
-	commandData := CommandDefinedSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &commandData)
-
-	return commandData, e
-}
-
+// GetObjectInfoByName get the command information by its name
 func (c Command) GetObjectInfoByName(name string) (interface{}, error) {
-	name = strings.TrimSpace(strings.ToLower(name))
-	if name == "" {
-		return nil, fmt.Errorf("can not get command information with empty name")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(c.TableName, "name", name)
-	if e != nil {
-		return nil, e
-	}
-
-	commandData := CommandDefinedSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &commandData)
-
-	return commandData, e
-}
-
-func (c Command) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &CommandDefinedSchema{}); !ok {
-		return -1, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &CommandDefinedSchema{}); !ok {
-		return -1, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	return sysadmObjects.GetObjectCount(c.TableName, c.PkName, searchContent, ids, searchKeys, conditions)
-}
-
-func (c Command) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
-	startPos, step int, orders map[string]string) ([]interface{}, error) {
-
-	var ret []interface{}
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &CommandDefinedSchema{}); !ok {
-		return ret, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &CommandDefinedSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	var orderKeys []string
-	for k, _ := range orders {
-		orderKeys = append(orderKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(orderKeys, &CommandDefinedSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
-	}
-
-	dbData, e := sysadmObjects.GetObjectList(c.TableName, c.PkName, searchContent, ids, searchKeys, conditions, startPos, step, orders)
-	if e != nil {
-		return ret, e
-	}
-
-	var tmpRes []interface{}
-	for _, v := range dbData {
-		command := &CommandDefinedSchema{}
-		if e := sysadmObjects.Unmarshal(v, command); e != nil {
-			return ret, e
-		}
-		tmpRes = append(tmpRes, *command)
-	}
-
-	return tmpRes, nil
-}
-
-func (c Command) AddObject(data interface{}) error {
-	versionSchemaData, ok := data.(CommandDefinedSchema)
-	if !ok {
-		return fmt.Errorf("the data try to add is not valid")
-	}
-
-	insertData, e := sysadmObjects.Marshal(versionSchemaData)
-	if e != nil {
-		return e
-	}
-
-	return sysadmObjects.AddObject(c.TableName, "", insertData)
-}
-
-func (c Command) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
-	addData := make(map[string]interface{}, 0)
-
-	schemaData, ok := data.(CommandDefinedSchema)
-	if !ok {
-		return addData, "", fmt.Errorf("there is an error occurred when coverting data to CommandDefined Schema")
-	}
-
-	addData, e := sysadmObjects.Marshal(schemaData)
-	if e != nil {
-		return addData, "", e
-	}
-
-	return addData, c.TableName, nil
-}
-
-func (c Command) GetObjectIDFieldName() (string, string, error) {
-	return c.TableName, c.PkName, nil
+	return c.GetByField("name", strings.ToLower(name))
 }
 
 func GenerateCommandID() (string, error) {
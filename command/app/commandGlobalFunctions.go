@@ -48,9 +48,7 @@ func SetRunData(dbConf *sysadmDB.DbConfig, logEntity *sysadmLog.LoggerConfig, wo
 	}
 
 	runData.objectEntiy = Command{
-		Name:      defaultObjectName,
-		TableName: defaultTableName,
-		PkName:    defaultPkName,
+		Repository: sysadmObjects.NewRepository[CommandDefinedSchema](defaultObjectName, defaultTableName, defaultPkName),
 	}
 
 	return nil
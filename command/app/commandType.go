@@ -26,12 +26,7 @@ import (
 )
 
 type Command struct {
-	// object name. the value should be "command"
-	Name string
-	// table name which hold host data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[CommandDefinedSchema]
 }
 
 // 命令定义表结构
@@ -58,9 +53,9 @@ type CommandDefinedSchema struct {
 	// 指示命令是否是属地同步命令。所谓同步命令是指，命令能够快速执行完成，即能在一个HTTP会话请求超时之前（一般超时时间为几秒内）执行完成的命令。0表示同步命令，否则为异步命令
 	Synchronized int `form:"synchronized" json:"synchronized" yaml:"synchronized" xml:"synchronized" db:"synchronized"`
 	// 适应的操作系统类型.0表示适应于所有操作系统
-	OSID int `form:"osID" json:"osID" yaml:"osID" xml:"osID" db:"osID"`
+	OSID int `form:"osID" json:"osID" yaml:"osID" xml:"osID" db:"osID" references:"os"`
 	// 适应的操作系统版本.0表示适应于所有版本
-	OsVersionID int `form:"osversionid" json:"osversionid" yaml:"osversionid" xml:"osversionid" db:"osversionid"`
+	OsVersionID int `form:"osversionid" json:"osversionid" yaml:"osversionid" xml:"osversionid" db:"osversionid" references:"version"`
 	// 命令是否依赖于其它命令，0表示不依赖于其它命令的独立命令,否则本字段记录被依赖命令的id
 	Dependent int `form:"dependent" json:"dependent" yaml:"dependent" xml:"dependent" db:"dependent"`
 	// 0表示内嵌命令,1系统命令, 2 脚本或批处理程序
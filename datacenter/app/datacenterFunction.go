@@ -18,125 +18,9 @@
 package app
 
 import (
-	"fmt"
-	"strings"
 	sysadmObjects "sysadm/objects/app"
 )
 
 func New() Datacenter {
-	ret := Datacenter{}
-	ret.Name = DefaultObjectName
-	ret.TableName = DefaultTableName
-	ret.PkName = DefaultPkName
-	return ret
-}
-
-func (d Datacenter) GetObjectInfoByID(id string) (interface{}, error) {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return nil, fmt.Errorf("can not get datacenter information with empty ID")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(d.TableName, d.PkName, id)
-	if e != nil {
-		return nil, e
-	}
-
-	dcData := DatacenterSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &dcData)
-
-	return dcData, e
-}
-
-func (d Datacenter) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &DatacenterSchema{}); !ok {
-		return -1, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &DatacenterSchema{}); !ok {
-		return -1, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	return sysadmObjects.GetObjectCount(d.TableName, d.PkName, searchContent, ids, searchKeys, conditions)
-}
-
-func (d Datacenter) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
-	startPos, step int, orders map[string]string) ([]interface{}, error) {
-
-	var ret []interface{}
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &DatacenterSchema{}); !ok {
-		return ret, fmt.Errorf("search key are not valid. error: %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &DatacenterSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of conditions must be the object fields name. %s", e)
-	}
-
-	var orderKeys []string
-	for k, _ := range orders {
-		orderKeys = append(orderKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(orderKeys, &DatacenterSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
-	}
-
-	dbData, e := sysadmObjects.GetObjectList(d.TableName, d.PkName, searchContent, ids, searchKeys, conditions, startPos, step, orders)
-	if e != nil {
-		return ret, e
-	}
-
-	var tmpRes []interface{}
-	for _, v := range dbData {
-		datacenter := &DatacenterSchema{}
-		if e := sysadmObjects.Unmarshal(v, datacenter); e != nil {
-			return ret, e
-		}
-		tmpRes = append(tmpRes, *datacenter)
-	}
-
-	return tmpRes, nil
-}
-
-func (d Datacenter) AddObject(data interface{}) error {
-	dcSchemaData, ok := data.(DatacenterSchema)
-	if !ok {
-		return fmt.Errorf("the data try to add is not valid")
-	}
-
-	insertData, e := sysadmObjects.Marshal(dcSchemaData)
-	if e != nil {
-		return e
-	}
-
-	return sysadmObjects.AddObject(d.TableName, d.PkName, insertData)
-}
-
-func (d Datacenter) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
-	addData := make(map[string]interface{}, 0)
-
-	schemaData, ok := data.(DatacenterSchema)
-	if !ok {
-		return addData, "", fmt.Errorf("there is an error occurred when coverting data to Datacenter Schema schema")
-	}
-
-	addData, e := sysadmObjects.Marshal(schemaData)
-	if e != nil {
-		return addData, "", e
-	}
-
-	return addData, d.TableName, nil
-}
-
-func (d Datacenter) GetObjectIDFieldName() (string, string, error) {
-	return d.TableName, d.PkName, nil
+	return Datacenter{Repository: sysadmObjects.NewRepository[DatacenterSchema](DefaultObjectName, DefaultTableName, DefaultPkName)}
 }
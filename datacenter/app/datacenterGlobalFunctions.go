@@ -47,11 +47,8 @@ func SetRunData(dbConf *sysadmDB.DbConfig, logEntity *sysadmLog.LoggerConfig, wo
 		return e
 	}
 
-	runData.objectEntiy = Datacenter{
-		Name:      DefaultObjectName,
-		TableName: DefaultTableName,
-		PkName:    DefaultPkName,
-	}
+	runData.objectEntiy = New()
+	sysadmObjects.RegisterPurger(New())
 
 	return nil
 }
@@ -77,6 +74,7 @@ func AddHandlers(r *sysadmServer.Engine) error {
 	groupPath := "/api/" + DefaultApiVersion + "/" + DefaultModuleName
 	v1 := r.Group(groupPath)
 	{
+		v1.POST("/del", delHandler)
 		v1.POST("/validCnName", validCnNameHandler)
 		v1.POST("/validEnName", validEnNameHandler)
 	}
@@ -87,6 +85,8 @@ func AddHandlers(r *sysadmServer.Engine) error {
 	{
 		display.GET("/list", listHandler)
 		display.GET("/addform", addformHandler)
+		display.POST("/del", delHandler)
+		display.POST("/restore", restoreHandler)
 		display.GET("/getprovincebycountrycodeforselect", getprovincebycountrycodeforselectHandler)
 		display.GET("/getcitybyprovincecodeforselect", getcitybyprovincecodeforselectHandler)
 	}
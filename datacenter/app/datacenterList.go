@@ -27,7 +27,7 @@ var allListItems = map[string]string{"TD1": "名称", "TD2": "Name", "TD3": "国
 
 // all popmenu items defined Format:
 // item name, action name, action method
-var allPopMenuItems = []string{"查看详情,detail,GET,page", "编辑数据中心,edit,GET,page", "删除数据中心,del,POST,tip", "启用数据中心,enable,POST,tip", "禁用数据中心,disable,POST,tip"}
+var allPopMenuItems = []string{"查看详情,detail,GET,page", "编辑数据中心,edit,GET,page", "删除数据中心,del,POST,tip", "启用数据中心,enable,POST,tip", "禁用数据中心,disable,POST,tip", "恢复数据中心,restore,POST,tip"}
 
 func listHandler(c *sysadmServer.Context) {
 	var errs []sysadmLog.Sysadmerror
@@ -91,7 +91,7 @@ func listHandler(c *sysadmServer.Context) {
 	ids := objectsUI.GetObjectIdsFromRequest(requestData)
 	searchKeys := []string{"cnName", "address"}
 	startPos := objectsUI.GetStartPosFromRequest(requestData)
-	dcConditions := objectsUI.BuildCondition(requestData, "=0", "city")
+	dcConditions := objectsUI.BuildCondition(requestData, objectsUI.GetDeletedConditionFromRequest(requestData), "city")
 
 	// get total number of list objects
 	var dcEntity sysadmObjects.ObjectEntity
@@ -126,7 +126,7 @@ func listHandler(c *sysadmServer.Context) {
 }
 
 func getRequestData(c *sysadmServer.Context) (map[string]string, error) {
-	requestData, e := utils.NewGetRequestData(c, []string{"groupSelectID", "searchContent", "start", "orderfield", "direction"})
+	requestData, e := utils.NewGetRequestData(c, []string{"groupSelectID", "searchContent", "start", "orderfield", "direction", "deleted"})
 	if e != nil {
 		return requestData, e
 	}
@@ -309,6 +309,10 @@ func prepareObjectData(countryList, provinceList, cityList, dcList []interface{}
 			statusStr = "禁用"
 			popmenuitems = "0,1,2,3"
 		}
+		if dcData.IsDeleted == 1 {
+			statusStr = "已删除"
+			popmenuitems = "0,5"
+		}
 		lineMap["TD8"] = statusStr
 		lineMap["popmenuitems"] = popmenuitems
 		dataList = append(dataList, lineMap)
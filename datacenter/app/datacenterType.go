@@ -26,12 +26,7 @@ import (
 )
 
 type Datacenter struct {
-	// object name. the value should be "k8scluster"
-	Name string
-	// table name which hold project data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[DatacenterSchema]
 }
 
 // K8s 集群信息表结构
@@ -59,7 +54,9 @@ type DatacenterSchema struct {
 	// 状态0未启用 1已启用 2 已停用
 	Status int `form:"status" json:"status" yaml:"status" xml:"status" db:"status"`
 	// 是否删除 0正常 1已删除
-	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted"`
+	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted" softdelete:"flag"`
+	// 删除时间截
+	DeletedTime int `form:"deletedTime" json:"deletedTime" yaml:"deletedTime" xml:"deletedTime" db:"deletedTime" softdelete:"time"`
 	// 创建时间
 	CreateTime string `form:"createTime" json:"createTime" yaml:"createTime" xml:"createTime" db:"createTime"`
 	// 更新时间
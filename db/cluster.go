@@ -0,0 +1,322 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sysadm/sysadmerror"
+)
+
+// clusterInitLock protects the initiation of DbConfig.cluster
+var clusterInitLock sync.Mutex
+
+var (
+	// drainCheckInterval is the interval of checking whether the connections of the old pool are still in use after failover
+	drainCheckInterval = time.Second
+
+	// drainTimeout is the max time of waiting for the connections of the old pool to be released after failover
+	drainTimeout = 5 * time.Minute
+)
+
+// ParseEndpoints parse the addresses in "host:port" or "host" format into DbEndpoint.
+// defaultPort will be used for the address without port.
+func ParseEndpoints(addresses []string, defaultPort int) ([]DbEndpoint, error) {
+	var ret []DbEndpoint
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+
+		host, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			ret = append(ret, DbEndpoint{Host: address, Port: defaultPort})
+			continue
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("port of DB address %s is not valid", address)
+		}
+		ret = append(ret, DbEndpoint{Host: host, Port: port})
+	}
+
+	return ret, nil
+}
+
+// PrimaryHealthy return true if the last health probe of the primary server is successful.
+func (c *DbConfig) PrimaryHealthy() bool {
+	cluster := c.getCluster()
+	cluster.lock.RLock()
+	defer cluster.lock.RUnlock()
+
+	return cluster.primaryHealthy
+}
+
+// getCluster return the runtime data of replicas and health probes, and initiate it if it has not been initiated.
+func (c *DbConfig) getCluster() *dbCluster {
+	clusterInitLock.Lock()
+	defer clusterInitLock.Unlock()
+
+	if c.cluster == nil {
+		c.cluster = &dbCluster{primaryHealthy: true}
+	}
+
+	return c.cluster
+}
+
+// writeConnect return the connection to the primary server. writing and transactions should use this connection.
+func (c *DbConfig) writeConnect() *sql.DB {
+	cluster := c.getCluster()
+	cluster.lock.RLock()
+	defer cluster.lock.RUnlock()
+
+	return c.Connect
+}
+
+// readConnect return a connection to a healthy replica in round-robin order.
+// the connection to the primary server will be returned if there is not any healthy replica.
+func (c *DbConfig) readConnect() *sql.DB {
+	cluster := c.getCluster()
+	cluster.lock.RLock()
+	defer cluster.lock.RUnlock()
+
+	num := len(cluster.replicas)
+	if num > 0 {
+		start := atomic.AddUint32(&cluster.next, 1)
+		for i := 0; i < num; i++ {
+			r := cluster.replicas[(int(start)+i)%num]
+			if r.healthy && r.connect != nil {
+				return r.connect
+			}
+		}
+	}
+
+	return c.Connect
+}
+
+// openReplicas open the connections to the replicas and start the health probes if HealthCheckInterval is large than 0.
+// a replica which can not be connected will be marked as unhealthy and will be retried by the health probes.
+func (c *DbConfig) openReplicas() []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	cluster := c.getCluster()
+	var replicas []*replicaConn
+	for _, endpoint := range c.Replicas {
+		r := &replicaConn{endpoint: endpoint}
+		connect, err := openEndpoint(c, endpoint)
+		if err != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(100017, "warning", "can not connect to DB replica %s:%d: %s", endpoint.Host, endpoint.Port, err))
+		} else {
+			r.connect = connect
+			r.healthy = true
+		}
+		replicas = append(replicas, r)
+	}
+
+	cluster.lock.Lock()
+	cluster.replicas = replicas
+	cluster.primaryHealthy = true
+	cluster.lock.Unlock()
+
+	if c.HealthCheckInterval > 0 {
+		c.startHealthCheck()
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(100018, "debug", "health probes for DB servers have be started with interval %d seconds", c.HealthCheckInterval))
+	}
+
+	return errs
+}
+
+// closeReplicas stop the health probes and close the connections to the replicas.
+func (c *DbConfig) closeReplicas() {
+	cluster := c.getCluster()
+	cluster.lock.Lock()
+	defer cluster.lock.Unlock()
+
+	if cluster.stopCh != nil {
+		close(cluster.stopCh)
+		cluster.stopCh = nil
+	}
+
+	for _, r := range cluster.replicas {
+		if r.connect != nil {
+			_ = r.connect.Close()
+		}
+	}
+	cluster.replicas = nil
+}
+
+func (c *DbConfig) startHealthCheck() {
+	cluster := c.getCluster()
+	cluster.lock.Lock()
+	if cluster.stopCh != nil {
+		cluster.lock.Unlock()
+		return
+	}
+	stopCh := make(chan struct{})
+	cluster.stopCh = stopCh
+	cluster.lock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(c.HealthCheckInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				c.probe()
+			}
+		}
+	}()
+}
+
+// probe check the primary server and the replicas.
+// the connection to the primary server will be switched to the first writable server in Host and Standbys if the
+// primary server can not be pinged or it has become read-only.
+func (c *DbConfig) probe() {
+	cluster := c.getCluster()
+
+	primary := c.writeConnect()
+	primaryOK := primary != nil && primary.Ping() == nil && isWritable(c.Type, primary)
+	if !primaryOK {
+		primaryOK = c.failover()
+	}
+
+	cluster.lock.RLock()
+	replicas := cluster.replicas
+	cluster.lock.RUnlock()
+	for _, r := range replicas {
+		connect := r.connect
+		healthy := false
+		if connect == nil {
+			if newConnect, err := openEndpoint(c, r.endpoint); err == nil {
+				connect = newConnect
+				healthy = true
+			}
+		} else {
+			healthy = connect.Ping() == nil
+		}
+
+		cluster.lock.Lock()
+		r.connect = connect
+		r.healthy = healthy
+		cluster.lock.Unlock()
+	}
+
+	cluster.lock.Lock()
+	cluster.primaryHealthy = primaryOK
+	cluster.lock.Unlock()
+}
+
+// failover try to find a writable server in Host and Standbys, and switch the connection to the primary server to it.
+// return true if a writable server has been found.
+func (c *DbConfig) failover() bool {
+	candidates := append([]DbEndpoint{{Host: c.Host, Port: c.Port}}, c.Standbys...)
+	for _, endpoint := range candidates {
+		connect, err := openEndpoint(c, endpoint)
+		if err != nil {
+			continue
+		}
+		if !isWritable(c.Type, connect) {
+			_ = connect.Close()
+			continue
+		}
+
+		cluster := c.getCluster()
+		cluster.lock.Lock()
+		old := c.Connect
+		c.Connect = connect
+		c.Host = endpoint.Host
+		c.Port = endpoint.Port
+		cluster.lock.Unlock()
+		if old != nil {
+			go drainConnect(old)
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// drainConnect close the connection pool old after its connections in use have been released or drainTimeout has
+// elapsed, so the queries and transactions which were started on the old primary server before failover are not broken
+// by closing the pool. it waits at least drainCheckInterval for the callers which have got the pool just before failover.
+func drainConnect(old *sql.DB) {
+	deadline := time.Now().Add(drainTimeout)
+	for {
+		time.Sleep(drainCheckInterval)
+		if old.Stats().InUse == 0 || !time.Now().Before(deadline) {
+			break
+		}
+	}
+
+	_ = old.Close()
+}
+
+// openEndpoint open a connection to the server at endpoint with the parameters in c, and ping the server.
+func openEndpoint(c *DbConfig, endpoint DbEndpoint) (*sql.DB, error) {
+	driver := "mysql"
+	dbDsnstr := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.User, c.Password, endpoint.Host, endpoint.Port, c.DbName)
+	if strings.EqualFold(c.Type, "postgre") {
+		driver = "postgres"
+		dbDsnstr = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", endpoint.Host, endpoint.Port, c.User, c.Password, c.DbName)
+	}
+
+	connect, err := sql.Open(driver, dbDsnstr)
+	if err != nil {
+		return nil, err
+	}
+	connect.SetMaxOpenConns(c.MaxOpenConns)
+	connect.SetMaxIdleConns(c.MaxIdleConns)
+	connect.SetConnMaxLifetime(time.Minute * 5)
+
+	if err = connect.Ping(); err != nil {
+		_ = connect.Close()
+		return nil, err
+	}
+
+	return connect, nil
+}
+
+// isWritable check whether the server is not a read-only server, such as a replica or a standby in recovery.
+func isWritable(dbType string, connect *sql.DB) bool {
+	if strings.EqualFold(dbType, "postgre") {
+		inRecovery := false
+		if err := connect.QueryRow("select pg_is_in_recovery()").Scan(&inRecovery); err != nil {
+			return false
+		}
+		return !inRecovery
+	}
+
+	readOnly := 0
+	if err := connect.QueryRow("select @@global.read_only").Scan(&readOnly); err != nil {
+		return false
+	}
+
+	return readOnly == 0
+}
@@ -0,0 +1,92 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryOnPrimary(t *testing.T) {
+	e, primary := newSequenceDB(t)
+	replicaEntity, replica := newSequenceDB(t)
+	cluster := e.config.getCluster()
+	cluster.replicas = []*replicaConn{{connect: replicaEntity.config.Connect, healthy: true}}
+	entity := MySQL{Config: e.config}
+
+	if _, err := entity.NewQueryData(&SelectData{Tb: []string{"user"}, OutFeilds: []string{"*"}}); err != nil {
+		t.Fatalf("query error: %s", err)
+	}
+	if len(replica.executed("select * from")) != 1 || len(primary.executed("select * from")) != 0 {
+		t.Errorf("queries should be sent to the replica by default")
+	}
+
+	if _, err := entity.NewQueryData(&SelectData{Tb: []string{"user"}, OutFeilds: []string{"*"}, Primary: true}); err != nil {
+		t.Fatalf("query error: %s", err)
+	}
+	if len(primary.executed("select * from")) != 1 {
+		t.Errorf("the query with Primary should be sent to the primary server")
+	}
+
+	cluster.replicas[0].healthy = false
+	if _, err := entity.NewQueryData(&SelectData{Tb: []string{"user"}, OutFeilds: []string{"*"}}); err != nil {
+		t.Fatalf("query error: %s", err)
+	}
+	if len(primary.executed("select * from")) != 2 {
+		t.Errorf("queries should be sent to the primary server if there is not any healthy replica")
+	}
+}
+
+func TestDrainConnect(t *testing.T) {
+	oldInterval, oldTimeout := drainCheckInterval, drainTimeout
+	drainCheckInterval, drainTimeout = 10*time.Millisecond, 10*time.Second
+	t.Cleanup(func() { drainCheckInterval, drainTimeout = oldInterval, oldTimeout })
+
+	e, _ := newSequenceDB(t)
+	old := e.config.Connect
+	tx, err := old.Begin()
+	if err != nil {
+		t.Fatalf("begin transaction error: %s", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		drainConnect(old)
+		close(done)
+	}()
+
+	time.Sleep(5 * drainCheckInterval)
+	select {
+	case <-done:
+		t.Fatalf("the pool should not be closed while a transaction is running on it")
+	default:
+	}
+	if _, err := tx.Exec("update `ids` set nextValue = nextValue + 1 where tableName = ? and fieldName = ?", "host", "hostid"); err != nil {
+		t.Errorf("the transaction started before failover should keep working: %s", err)
+	}
+
+	_ = tx.Rollback()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("the pool should be closed after the transaction has been finished")
+	}
+	if err := old.Ping(); err == nil {
+		t.Errorf("the old pool should be closed")
+	}
+}
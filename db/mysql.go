@@ -79,6 +79,8 @@ func (p MySQL) OpenDbConnect() []sysadmerror.Sysadmerror {
 		return errs
 	}
 
+	errs = append(errs, p.Config.openReplicas()...)
+
 	return errs
 }
 
@@ -117,7 +119,7 @@ func (p MySQL) InsertData(tb string, data FieldData) (int, []sysadmerror.Sysadme
 	insertStr = insertStr + ") "
 	valueStr = valueStr + ")"
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(107038, "debug", "insert statement %s", (insertStr+valueStr)))
 	stmt, err := dbConnect.Prepare((insertStr + valueStr))
 	if err != nil {
@@ -146,7 +148,9 @@ return []sysadmerror.Sysadmerror
 func (p MySQL) CloseDB() []sysadmerror.Sysadmerror {
 	var errs []sysadmerror.Sysadmerror
 
-	dbConnect := p.Config.Connect
+	p.Config.closeReplicas()
+
+	dbConnect := p.Config.writeConnect()
 	if dbConnect == nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(107017, "warning", "The connection to DB server is nil. "))
 	}
@@ -251,7 +255,7 @@ func (p MySQL) QueryData(sd *SelectData) ([]FieldData, []sysadmerror.Sysadmerror
 		querySQL = querySQL + " limit " + strconv.Itoa(sd.Limit[0]) + ", " + strconv.Itoa(sd.Limit[1])
 	}
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(107021, "debug", "now execute the SQL query: %s", querySQL))
 	rows, err := dbConnect.Query(querySQL)
 	if err != nil {
@@ -322,7 +326,7 @@ func (p MySQL) UpdateData(tb string, data FieldData, where map[string]string) (i
 		}
 	}
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
 	stmt, err := dbConnect.Prepare(querySQL)
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1070333, "debug", "try to execute SQL:%s", querySQL))
 	if err != nil {
@@ -380,7 +384,7 @@ func (p MySQL) DeleteData(dd *SelectData) (int64, []sysadmerror.Sysadmerror) {
 		}
 	}
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(107026, "debug", "now execute the SQL query: %s", querySQL))
 	stmt, err := dbConnect.Prepare(querySQL)
 	if err != nil {
@@ -55,7 +55,7 @@ func (p MySQL) NewInsertData(tb string, data FieldData) error {
 	insertStr = insertStr + ") "
 	valueStr = valueStr + ")"
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
 	if p.Config.RunModeDebug {
 		fmt.Printf("query statement: %s\n", (insertStr + valueStr))
 	}
@@ -161,7 +161,10 @@ func (p MySQL) NewQueryData(sd *SelectData) ([]map[string]interface{}, error) {
 		querySQL = querySQL + " limit " + strconv.Itoa(sd.Limit[0]) + ", " + strconv.Itoa(sd.Limit[1])
 	}
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
+	if !sd.Primary {
+		dbConnect = p.Config.readConnect()
+	}
 	if p.Config.RunModeDebug {
 		fmt.Printf("Sql: %s\n", querySQL)
 	}
@@ -230,7 +233,7 @@ func (p MySQL) NewUpdateData(tb string, data FieldData, where map[string]string)
 		}
 	}
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
 	if p.Config.RunModeDebug {
 		fmt.Printf("query statement:%s \n", querySQL)
 	}
@@ -273,7 +276,7 @@ func (p MySQL) NewDeleteData(dd *SelectData) error {
 		}
 	}
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
 	stmt, err := dbConnect.Prepare(querySQL)
 	if p.Config.RunModeDebug {
 		fmt.Printf("query statement:%s \n", querySQL)
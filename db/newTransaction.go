@@ -28,7 +28,7 @@ func NewBegin(e DbEntity) (*Tx, error) {
 	}
 
 	dbConfig := e.GetDbConfig()
-	dbConn := dbConfig.Connect
+	dbConn := dbConfig.writeConnect()
 	sqlTx, err := dbConn.Begin()
 	if err != nil {
 		return nil, err
@@ -57,7 +57,9 @@ func (t *Tx) NewInsertData(tb string, data FieldData) error {
 		return err
 	}
 
-	fmt.Printf("query statement: %s\n ", query)
+	if t.Entity.GetDbConfig().RunModeDebug {
+		fmt.Printf("query statement: %s\n ", query)
+	}
 	tx := t.Tx
 	_, e := tx.Exec(query)
 	if e != nil {
@@ -77,7 +79,9 @@ func (t *Tx) NewUpdateData(tb string, data FieldData, where map[string]string) e
 		return err
 	}
 
-	fmt.Printf("update statement: %s\n", query)
+	if t.Entity.GetDbConfig().RunModeDebug {
+		fmt.Printf("update statement: %s\n", query)
+	}
 	tx := t.Tx
 	_, e := tx.Exec(query)
 	if e != nil {
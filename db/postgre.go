@@ -74,6 +74,8 @@ func (p Postgre)OpenDbConnect() []sysadmerror.Sysadmerror {
 		return errs
 	}
 
+	errs = append(errs,p.Config.openReplicas()...)
+
 	return errs
 }
 
@@ -116,7 +118,7 @@ func (p Postgre)InsertData(tb string,data FieldData) (int, []sysadmerror.Sysadme
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(101009,"debug","Insert SQL: %s.",(insertStr + placeHoldStr)))
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(101010,"debug","Insert Data: %v.",values))
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
     stmt, err := dbConnect.Prepare((insertStr + placeHoldStr))
     if err != nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(101011,"error","Prepare SQL error: %s.",err))
@@ -145,7 +147,9 @@ func (p Postgre)InsertData(tb string,data FieldData) (int, []sysadmerror.Sysadme
 func (p Postgre)CloseDB()([]sysadmerror.Sysadmerror){
 	var errs []sysadmerror.Sysadmerror
 
-	dbConnect := p.Config.Connect
+	p.Config.closeReplicas()
+
+	dbConnect := p.Config.writeConnect()
 	if dbConnect == nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(101017,"warning","The connection to DB server is nil. "))
 	}
@@ -236,7 +240,7 @@ func (p Postgre)QueryData(sd *SelectData) ([]FieldData, []sysadmerror.Sysadmerro
 		querySQL = querySQL + " limit " + strconv.Itoa(sd.Limit[0]) + " OFFSET " + strconv.Itoa(sd.Limit[1]) 
 	}
 
-	dbConnect := p.Config.Connect
+	dbConnect := p.Config.writeConnect()
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(101021,"debug","now execute the SQL query: %s",querySQL))
 	rows, err := dbConnect.Query(querySQL)
 	if err != nil {
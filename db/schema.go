@@ -0,0 +1,175 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WidenColumn widen the varchar column columnName of table tableName to length if it is narrower than length, so the
+// tables created by the old schema can hold the longer values. definition is the rest of the column definition after
+// the type, such as "NOT NULL COMMENT 'user password'", it is needed by MySQL because MODIFY replaces the whole
+// definition of the column. nothing will be done if the column is not narrower than length.
+func WidenColumn(e DbEntity, tableName, columnName string, length int, definition string) error {
+	if e == nil || e.GetDbConfig() == nil || e.GetDbConfig().writeConnect() == nil {
+		return fmt.Errorf("DB connection has not be opened")
+	}
+
+	if !sequenceIdentifier.MatchString(tableName) || !sequenceIdentifier.MatchString(columnName) || length < 1 {
+		return fmt.Errorf("table name %s, column name %s or length %d is not valid", tableName, columnName, length)
+	}
+
+	dbConfig := e.GetDbConfig()
+	dbType := dbConfig.Type
+	isPostgre := strings.EqualFold(dbType, "postgre")
+	schema := "database()"
+	if isPostgre {
+		schema = "current_schema()"
+	}
+	selectSQL := "select character_maximum_length from information_schema.columns where table_schema = " + schema +
+		" and table_name = " + bindVar(dbType, 1) + " and column_name = " + bindVar(dbType, 2)
+
+	var current sql.NullInt64
+	err := dbConfig.writeConnect().QueryRow(selectSQL, tableName, columnName).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("get length of column %s.%s error: %s", tableName, columnName, err)
+	}
+	if !current.Valid || current.Int64 >= int64(length) {
+		return nil
+	}
+
+	colType := "varchar(" + strconv.Itoa(length) + ")"
+	alterSQL := "alter table " + quoteIdentifier(dbType, tableName) + " modify " + quoteIdentifier(dbType, columnName) +
+		" " + colType + " " + definition
+	if isPostgre {
+		alterSQL = "alter table " + quoteIdentifier(dbType, tableName) + " alter column " +
+			quoteIdentifier(dbType, columnName) + " type " + colType
+	}
+	if dbConfig.RunModeDebug {
+		fmt.Printf("alter statement: %s\n", alterSQL)
+	}
+
+	if _, err := dbConfig.writeConnect().Exec(alterSQL); err != nil {
+		return fmt.Errorf("widen column %s.%s to %d error: %s", tableName, columnName, length, err)
+	}
+
+	return nil
+}
+
+// UpgradeSequenceTable create ids table if it does not exist, and add the primary key on (tableName, fieldName) to the
+// ids table created by the old schema. NextID relies on the primary key: only one of the concurrent callers which
+// initiate the same sequence can insert the row of the sequence. the duplicate rows of a sequence are merged into one
+// row with the largest value before the primary key is added. nothing will be done if the primary key exists.
+func UpgradeSequenceTable(e DbEntity) error {
+	if e == nil || e.GetDbConfig() == nil || e.GetDbConfig().writeConnect() == nil {
+		return fmt.Errorf("DB connection has not be opened")
+	}
+
+	dbConfig := e.GetDbConfig()
+	dbType := dbConfig.Type
+	conn := dbConfig.writeConnect()
+	seqTb := quoteIdentifier(dbType, SequenceTable)
+	createSQL := "create table if not exists " + seqTb + " (tableName varchar(255) not null, fieldName varchar(255) not null, " +
+		"nextValue int not null default 1, primary key (tableName, fieldName))"
+	if _, err := conn.Exec(createSQL); err != nil {
+		return fmt.Errorf("create table %s error: %s", SequenceTable, err)
+	}
+
+	schema := "database()"
+	if strings.EqualFold(dbType, "postgre") {
+		schema = "current_schema()"
+	}
+	selectSQL := "select count(*) from information_schema.table_constraints where table_schema = " + schema +
+		" and table_name = " + bindVar(dbType, 1) + " and constraint_type = 'PRIMARY KEY'"
+	var num int
+	if err := conn.QueryRow(selectSQL, SequenceTable).Scan(&num); err != nil {
+		return fmt.Errorf("get primary key of table %s error: %s", SequenceTable, err)
+	}
+	if num > 0 {
+		return nil
+	}
+
+	if err := mergeSequenceRows(conn, dbType); err != nil {
+		return err
+	}
+
+	alterSQL := "alter table " + seqTb + " add primary key (tableName, fieldName)"
+	if dbConfig.RunModeDebug {
+		fmt.Printf("alter statement: %s\n", alterSQL)
+	}
+	if _, err := conn.Exec(alterSQL); err != nil {
+		return fmt.Errorf("add primary key to table %s error: %s", SequenceTable, err)
+	}
+
+	return nil
+}
+
+// mergeSequenceRows replace the rows of each sequence which has more than one row in ids table with one row holding the
+// largest value of them, so the primary key can be added to the table
+func mergeSequenceRows(conn *sql.DB, dbType string) error {
+	type sequence struct {
+		tableName, fieldName string
+		nextValue            int64
+	}
+
+	seqTb := quoteIdentifier(dbType, SequenceTable)
+	rows, err := conn.Query("select tableName, fieldName, max(nextValue) from " + seqTb +
+		" group by tableName, fieldName having count(*) > 1")
+	if err != nil {
+		return fmt.Errorf("get duplicate rows of table %s error: %s", SequenceTable, err)
+	}
+	var duplicates []sequence
+	for rows.Next() {
+		var s sequence
+		if err := rows.Scan(&s.tableName, &s.fieldName, &s.nextValue); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("get duplicate rows of table %s error: %s", SequenceTable, err)
+		}
+		duplicates = append(duplicates, s)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("get duplicate rows of table %s error: %s", SequenceTable, err)
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	deleteSQL := "delete from " + seqTb + " where tableName = " + bindVar(dbType, 1) + " and fieldName = " + bindVar(dbType, 2)
+	insertSQL := "insert into " + seqTb + "(tableName, fieldName, nextValue) values (" + bindVar(dbType, 1) + "," +
+		bindVar(dbType, 2) + "," + bindVar(dbType, 3) + ")"
+	for _, s := range duplicates {
+		if _, err := tx.Exec(deleteSQL, s.tableName, s.fieldName); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("merge the rows of sequence %s.%s error: %s", s.tableName, s.fieldName, err)
+		}
+		if _, err := tx.Exec(insertSQL, s.tableName, s.fieldName, s.nextValue); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("merge the rows of sequence %s.%s error: %s", s.tableName, s.fieldName, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,207 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SequenceTable is the name of the table which holds the next value of ID for the tables
+	SequenceTable = "ids"
+
+	// maxSequenceRetry is the times we try to initiate the sequence for a table when there is no row for it
+	maxSequenceRetry = 3
+)
+
+// sequenceIdentifier matches the table name and field name which can be quoted into the statements of sequence
+var sequenceIdentifier = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]{0,63}$")
+
+// NextID allocates the next value of the ID field fieldName of table tableName.
+// the value is allocated in a short transaction of its own: the row of the sequence in ids table is locked by
+// "SELECT ... FOR UPDATE" and is increased before the transaction is committed, so concurrent callers never get
+// the same value. the sequence will be initiated with MAX(fieldName)+1 if there is no row for it in ids table.
+// return the ID and nil if successful, otherwise return 0 and an error
+func NextID(e DbEntity, tableName, fieldName string) (uint64, error) {
+	if e == nil {
+		return 0, fmt.Errorf("DB entity is nil")
+	}
+
+	dbConfig := e.GetDbConfig()
+	if dbConfig == nil || dbConfig.writeConnect() == nil {
+		return 0, fmt.Errorf("DB connection has not be opened")
+	}
+
+	sqlTx, err := dbConfig.writeConnect().Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := nextIDWithSqlTx(sqlTx, dbConfig.Type, tableName, fieldName)
+	if err != nil {
+		_ = sqlTx.Rollback()
+		return 0, err
+	}
+
+	if err = sqlTx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// NextID allocates the next value of the ID field fieldName of table tableName in transaction t.
+// the row of the sequence keeps locked until t is committed or rolled back, so the IDs allocated by this method
+// are gapless but the transactions which allocate IDs for the same table are serialized.
+// return the ID and nil if successful, otherwise return 0 and an error
+func (t *Tx) NextID(tableName, fieldName string) (uint64, error) {
+	if t == nil || t.Tx == nil {
+		return 0, fmt.Errorf("transaction has not began")
+	}
+
+	dbType := ""
+	if t.Entity != nil && t.Entity.GetDbConfig() != nil {
+		dbType = t.Entity.GetDbConfig().Type
+	}
+
+	return nextIDWithSqlTx(t.Tx, dbType, tableName, fieldName)
+}
+
+// NextGroupValue return the next value of field fieldName of the rows in table tableName which value of groupField
+// is groupValue in transaction t, such as the next revision of a setting item. the row holding the max value is read by
+// "SELECT ... FOR UPDATE", so the transactions which get the next value for the same group are serialized.
+// return 1 if there is no row for the group
+func (t *Tx) NextGroupValue(tableName, fieldName, groupField string, groupValue interface{}) (uint64, error) {
+	if t == nil || t.Tx == nil {
+		return 0, fmt.Errorf("transaction has not began")
+	}
+
+	if !sequenceIdentifier.MatchString(tableName) || !sequenceIdentifier.MatchString(fieldName) ||
+		!sequenceIdentifier.MatchString(groupField) {
+		return 0, fmt.Errorf("table name %s, field name %s or group field %s is not valid", tableName, fieldName, groupField)
+	}
+
+	dbType := ""
+	if t.Entity != nil && t.Entity.GetDbConfig() != nil {
+		dbType = t.Entity.GetDbConfig().Type
+	}
+
+	field := quoteIdentifier(dbType, fieldName)
+	selectSQL := "select " + field + " from " + quoteIdentifier(dbType, tableName) + " where " +
+		quoteIdentifier(dbType, groupField) + " = " + bindVar(dbType, 1) + " order by " + field + " desc limit 1 for update"
+	var maxValue uint64
+	err := t.Tx.QueryRow(selectSQL, groupValue).Scan(&maxValue)
+	if err == sql.ErrNoRows {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get max value of %s.%s error: %s", tableName, fieldName, err)
+	}
+
+	return maxValue + 1, nil
+}
+
+func nextIDWithSqlTx(sqlTx *sql.Tx, dbType, tableName, fieldName string) (uint64, error) {
+	tableName = strings.TrimSpace(tableName)
+	fieldName = strings.TrimSpace(fieldName)
+	if tableName == "" || fieldName == "" {
+		return 0, fmt.Errorf("table name %s or field name %s is empty", tableName, fieldName)
+	}
+
+	if !sequenceIdentifier.MatchString(tableName) || !sequenceIdentifier.MatchString(fieldName) {
+		return 0, fmt.Errorf("table name %s or field name %s is not valid", tableName, fieldName)
+	}
+
+	seqTb := quoteIdentifier(dbType, SequenceTable)
+	selectSQL := "select nextValue from " + seqTb + " where tableName = " + bindVar(dbType, 1) +
+		" and fieldName = " + bindVar(dbType, 2) + " for update"
+	updateSQL := "update " + seqTb + " set nextValue = nextValue + 1 where tableName = " + bindVar(dbType, 1) +
+		" and fieldName = " + bindVar(dbType, 2)
+
+	for i := 0; i < maxSequenceRetry; i++ {
+		var nextValue uint64
+		err := sqlTx.QueryRow(selectSQL, tableName, fieldName).Scan(&nextValue)
+		if err == sql.ErrNoRows {
+			if e := initSequence(sqlTx, dbType, tableName, fieldName); e != nil {
+				return 0, e
+			}
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("get next value of %s.%s error: %s", tableName, fieldName, err)
+		}
+
+		if _, err = sqlTx.Exec(updateSQL, tableName, fieldName); err != nil {
+			return 0, fmt.Errorf("update next value of %s.%s error: %s", tableName, fieldName, err)
+		}
+
+		return nextValue, nil
+	}
+
+	return 0, fmt.Errorf("can not initiate the sequence for %s.%s", tableName, fieldName)
+}
+
+// initSequence insert a row for tableName.fieldName into ids table with the value of MAX(fieldName)+1.
+// the row inserted by a concurrent caller is kept as ids table has primary key on (tableName, fieldName) which is added
+// by UpgradeSequenceTable, and the caller will select the row again.
+func initSequence(sqlTx *sql.Tx, dbType, tableName, fieldName string) error {
+	var maxID sql.NullInt64
+	maxSQL := "select max(" + quoteIdentifier(dbType, fieldName) + ") from " + quoteIdentifier(dbType, tableName)
+	if err := sqlTx.QueryRow(maxSQL).Scan(&maxID); err != nil {
+		return fmt.Errorf("get max value of %s.%s error: %s", tableName, fieldName, err)
+	}
+
+	nextValue := int64(1)
+	if maxID.Valid {
+		nextValue = maxID.Int64 + 1
+	}
+
+	insertSQL := "insert ignore into "
+	onConflict := ""
+	if strings.EqualFold(dbType, "postgre") {
+		insertSQL = "insert into "
+		onConflict = " on conflict do nothing"
+	}
+	insertSQL = insertSQL + quoteIdentifier(dbType, SequenceTable) + "(tableName, fieldName, nextValue) values (" +
+		bindVar(dbType, 1) + "," + bindVar(dbType, 2) + "," + bindVar(dbType, 3) + ")" + onConflict
+	if _, err := sqlTx.Exec(insertSQL, tableName, fieldName, nextValue); err != nil {
+		return fmt.Errorf("initiate the sequence for %s.%s error: %s", tableName, fieldName, err)
+	}
+
+	return nil
+}
+
+func bindVar(dbType string, n int) string {
+	if strings.EqualFold(dbType, "postgre") {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+func quoteIdentifier(dbType, identifier string) string {
+	if strings.EqualFold(dbType, "postgre") {
+		return "\"" + identifier + "\""
+	}
+
+	return "`" + identifier + "`"
+}
@@ -0,0 +1,304 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sequenceDriverName is the name of the database/sql driver which keeps ids table in memory
+const sequenceDriverName = "sequencefake"
+
+var (
+	sequenceDBLock sync.Mutex
+	sequenceDBs    = make(map[string]*sequenceDB, 0)
+)
+
+func init() {
+	sql.Register(sequenceDriverName, sequenceDriver{})
+}
+
+// sequenceDB is an in-memory DB which supports the statements of sequences
+type sequenceDB struct {
+	lock sync.Mutex
+	// ids is the next values of the sequences which key is tableName.fieldName
+	ids map[string]int64
+	// maxIDs is the max value of ID field which key is table name
+	maxIDs map[string]int64
+	// duplicates is the duplicate rows of ids table returned by the query of merging rows
+	duplicates [][]driver.Value
+	// primaryKeys is the number of primary keys of ids table
+	primaryKeys int
+	statements  []string
+}
+
+type testEntity struct {
+	DbEntity
+	config *DbConfig
+}
+
+func (e *testEntity) GetDbConfig() *DbConfig {
+	return e.config
+}
+
+// newSequenceDB return an entity of the in-memory DB and the DB
+func newSequenceDB(t *testing.T) (*testEntity, *sequenceDB) {
+	t.Helper()
+	s := &sequenceDB{ids: make(map[string]int64, 0), maxIDs: make(map[string]int64, 0)}
+	sequenceDBLock.Lock()
+	name := fmt.Sprintf("%s/%d", t.Name(), len(sequenceDBs))
+	sequenceDBs[name] = s
+	sequenceDBLock.Unlock()
+
+	conn, e := sql.Open(sequenceDriverName, name)
+	if e != nil {
+		t.Fatalf("open fake DB error: %s", e)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		sequenceDBLock.Lock()
+		delete(sequenceDBs, name)
+		sequenceDBLock.Unlock()
+	})
+
+	return &testEntity{config: &DbConfig{Type: "mysql", Connect: conn}}, s
+}
+
+// executed return the statements which begin with prefix
+func (s *sequenceDB) executed(prefix string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ret []string
+	for _, stmt := range s.statements {
+		if strings.HasPrefix(stmt, prefix) {
+			ret = append(ret, stmt)
+		}
+	}
+
+	return ret
+}
+
+func (s *sequenceDB) exec(query string, args []driver.Value) (*sequenceRows, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.statements = append(s.statements, query)
+
+	key := ""
+	if len(args) > 1 {
+		key = fmt.Sprintf("%v.%v", args[0], args[1])
+	}
+	switch {
+	case strings.HasPrefix(query, "select nextValue from"):
+		rows := &sequenceRows{columns: []string{"nextValue"}}
+		if v, ok := s.ids[key]; ok {
+			rows.data = [][]driver.Value{{v}}
+		}
+		return rows, nil
+	case strings.HasPrefix(query, "update `ids` set nextValue = nextValue + 1"):
+		s.ids[key]++
+	case strings.HasPrefix(query, "select max("):
+		tb := strings.Trim(query[strings.LastIndex(query, " ")+1:], "`")
+		var v driver.Value
+		if maxID, ok := s.maxIDs[tb]; ok {
+			v = maxID
+		}
+		return &sequenceRows{columns: []string{"max"}, data: [][]driver.Value{{v}}}, nil
+	case strings.HasPrefix(query, "insert ignore into `ids`"), strings.HasPrefix(query, "insert into `ids`"):
+		if _, ok := s.ids[key]; !ok {
+			s.ids[key] = args[2].(int64)
+		}
+	case strings.HasPrefix(query, "select count(*) from information_schema.table_constraints"):
+		return &sequenceRows{columns: []string{"num"}, data: [][]driver.Value{{int64(s.primaryKeys)}}}, nil
+	case strings.HasPrefix(query, "select tableName, fieldName, max(nextValue)"):
+		return &sequenceRows{columns: []string{"tableName", "fieldName", "nextValue"}, data: s.duplicates}, nil
+	case strings.HasPrefix(query, "delete from `ids`"):
+		delete(s.ids, key)
+	case strings.HasPrefix(query, "alter table `ids` add primary key"):
+		s.primaryKeys++
+	case strings.HasPrefix(query, "create table if not exists `ids`"):
+	case strings.HasPrefix(query, "select * from"):
+		return &sequenceRows{columns: []string{"id"}}, nil
+	default:
+		return nil, fmt.Errorf("statement %s is not supported", query)
+	}
+
+	return nil, nil
+}
+
+type sequenceDriver struct{}
+
+func (sequenceDriver) Open(name string) (driver.Conn, error) {
+	sequenceDBLock.Lock()
+	defer sequenceDBLock.Unlock()
+	s, ok := sequenceDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("DB %s does not exist", name)
+	}
+
+	return sequenceConn{db: s}, nil
+}
+
+type sequenceConn struct {
+	db *sequenceDB
+}
+
+func (c sequenceConn) Prepare(query string) (driver.Stmt, error) {
+	return sequenceStmt{db: c.db, query: query}, nil
+}
+func (c sequenceConn) Close() error              { return nil }
+func (c sequenceConn) Begin() (driver.Tx, error) { return sequenceTx{}, nil }
+
+type sequenceTx struct{}
+
+func (sequenceTx) Commit() error   { return nil }
+func (sequenceTx) Rollback() error { return nil }
+
+type sequenceStmt struct {
+	db    *sequenceDB
+	query string
+}
+
+func (s sequenceStmt) Close() error  { return nil }
+func (s sequenceStmt) NumInput() int { return -1 }
+func (s sequenceStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, e := s.db.exec(s.query, args); e != nil {
+		return nil, e
+	}
+
+	return driver.RowsAffected(1), nil
+}
+func (s sequenceStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, e := s.db.exec(s.query, args)
+	if e != nil {
+		return nil, e
+	}
+	if rows == nil {
+		return nil, fmt.Errorf("statement %s does not return rows", s.query)
+	}
+
+	return rows, nil
+}
+
+type sequenceRows struct {
+	columns []string
+	data    [][]driver.Value
+	next    int
+}
+
+func (r *sequenceRows) Columns() []string { return r.columns }
+func (r *sequenceRows) Close() error      { return nil }
+func (r *sequenceRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.next])
+	r.next++
+
+	return nil
+}
+
+func TestNextID(t *testing.T) {
+	e, s := newSequenceDB(t)
+	s.maxIDs["host"] = 41
+
+	// the sequence is initiated with MAX(hostid)+1
+	for _, want := range []uint64{42, 43} {
+		if id, err := NextID(e, "host", "hostid"); err != nil || id != want {
+			t.Errorf("NextID(host, hostid) = %d, %v, want %d", id, err, want)
+		}
+	}
+	if n := len(s.executed("insert ignore into")); n != 1 {
+		t.Errorf("the sequence is initiated %d times, want 1", n)
+	}
+
+	// the sequence of an empty table begins with 1
+	if id, err := NextID(e, "command", "commandID"); err != nil || id != 1 {
+		t.Errorf("NextID(command, commandID) = %d, %v, want 1", id, err)
+	}
+
+	// the sequence which has been initiated is not changed by MAX(fieldName)
+	s.ids["userGroup.groupid"] = 7
+	s.maxIDs["userGroup"] = 100
+	if id, err := NextID(e, "userGroup", "groupid"); err != nil || id != 7 {
+		t.Errorf("NextID(userGroup, groupid) = %d, %v, want 7", id, err)
+	}
+
+	for _, names := range [][2]string{{"", "id"}, {"host", ""}, {"host;drop table ids", "id"}, {"host", "id`"}} {
+		if _, err := NextID(e, names[0], names[1]); err == nil {
+			t.Errorf("NextID(%q, %q) should fail", names[0], names[1])
+		}
+	}
+	if _, err := NextID(nil, "host", "hostid"); err == nil {
+		t.Errorf("NextID with nil entity should fail")
+	}
+}
+
+func TestTxNextID(t *testing.T) {
+	e, s := newSequenceDB(t)
+	tx, err := e.config.Connect.Begin()
+	if err != nil {
+		t.Fatalf("begin transaction error: %s", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	dbTx := &Tx{Entity: e, Tx: tx}
+	for _, want := range []uint64{1, 2} {
+		if id, err := dbTx.NextID("projectMember", "id"); err != nil || id != want {
+			t.Errorf("Tx.NextID(projectMember, id) = %d, %v, want %d", id, err, want)
+		}
+	}
+	if s.ids["projectMember.id"] != 3 {
+		t.Errorf("next value of the sequence = %d, want 3", s.ids["projectMember.id"])
+	}
+
+	var nilTx *Tx
+	if _, err := nilTx.NextID("projectMember", "id"); err == nil {
+		t.Errorf("NextID of nil transaction should fail")
+	}
+}
+
+func TestUpgradeSequenceTable(t *testing.T) {
+	e, s := newSequenceDB(t)
+	s.duplicates = [][]driver.Value{{"host", "hostid", int64(9)}}
+	s.ids["host.hostid"] = 3
+
+	if err := UpgradeSequenceTable(e); err != nil {
+		t.Fatalf("upgrade ids table error: %s", err)
+	}
+	if s.ids["host.hostid"] != 9 {
+		t.Errorf("next value of the merged sequence = %d, want 9", s.ids["host.hostid"])
+	}
+	if s.primaryKeys != 1 {
+		t.Errorf("%d primary keys are added, want 1", s.primaryKeys)
+	}
+
+	// nothing will be done if the primary key exists
+	s.statements = nil
+	if err := UpgradeSequenceTable(e); err != nil {
+		t.Fatalf("upgrade ids table error: %s", err)
+	}
+	if n := len(s.executed("alter table")) + len(s.executed("delete from")); n != 0 || s.primaryKeys != 1 {
+		t.Errorf("ids table which has primary key should not be altered")
+	}
+}
@@ -33,7 +33,7 @@ func Begin(e DbEntity) (*Tx, []sysadmerror.Sysadmerror) {
 	}
 
 	dbConfig := e.GetDbConfig()
-	dbConn := dbConfig.Connect
+	dbConn := dbConfig.writeConnect()
 
 	tx, err := dbConn.Begin()
 	if err != nil {
@@ -20,6 +20,7 @@ package db
 import (
 	"database/sql"
 	"net"
+	"sync"
 
 	"sysadm/sysadmerror"
 )
@@ -41,6 +42,34 @@ type DbConfig struct {
 	Connect      *sql.DB  `json:"connect"`
 	RunModeDebug bool     `json:"runModeDebug"`
 	Entity       DbEntity `json:"entity"`
+	// Replicas are the read-only servers which queries of NewQueryData will be sent to
+	Replicas []DbEndpoint `json:"replicas"`
+	// Standbys are the servers which may be promoted to primary when Host is unavailable
+	Standbys []DbEndpoint `json:"standbys"`
+	// HealthCheckInterval is the interval in seconds of health probes. zero means disable health probes
+	HealthCheckInterval int `json:"healthCheckInterval"`
+	// cluster hold the connections to replicas and the state of health probes
+	cluster *dbCluster
+}
+
+// DbEndpoint is the address of a DB server
+type DbEndpoint struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type dbCluster struct {
+	lock           sync.RWMutex
+	replicas       []*replicaConn
+	next           uint32
+	primaryHealthy bool
+	stopCh         chan struct{}
+}
+
+type replicaConn struct {
+	endpoint DbEndpoint
+	connect  *sql.DB
+	healthy  bool
 }
 
 type DbEntity interface {
@@ -80,6 +109,16 @@ type SelectData struct {
 	Order     []OrderData
 	Group     []string
 	Limit     []int
+	// Primary force the query to be sent to the primary server, such as reading the data just written
+	Primary bool
+}
+
+// Condition is a condition of the statements built by UpdateIf, such as "totp_last_step < 10". Op is one of =, <>,
+// <, <=, > and >=
+type Condition struct {
+	Field string
+	Op    string
+	Value interface{}
 }
 
 type Tx struct {
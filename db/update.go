@@ -0,0 +1,80 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// conditionOps are the operators which can be used in the conditions of UpdateIf
+var conditionOps = map[string]bool{"=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// UpdateIf set the fields in data of the rows of table tableName which match all of conditions in one statement.
+// the values of data and conditions are bound to the statement, so they should not be quoted. it can be used to
+// compare and set a field when the concurrent requests may update the same row, such as marking a one-time code as used.
+// return the number of rows which have been updated, 0 means none of the rows matches the conditions
+func UpdateIf(e DbEntity, tableName string, data FieldData, conditions []Condition) (int64, error) {
+	if e == nil || e.GetDbConfig() == nil || e.GetDbConfig().writeConnect() == nil {
+		return 0, fmt.Errorf("DB connection has not be opened")
+	}
+
+	if !sequenceIdentifier.MatchString(tableName) || len(data) < 1 || len(conditions) < 1 {
+		return 0, fmt.Errorf("table name %s is not valid, or data or conditions is empty", tableName)
+	}
+
+	dbConfig := e.GetDbConfig()
+	dbType := dbConfig.Type
+	var fields []string
+	for field := range data {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var sets, wheres []string
+	var args []interface{}
+	for _, field := range fields {
+		if !sequenceIdentifier.MatchString(field) {
+			return 0, fmt.Errorf("field name %s is not valid", field)
+		}
+		args = append(args, data[field])
+		sets = append(sets, quoteIdentifier(dbType, field)+" = "+bindVar(dbType, len(args)))
+	}
+
+	for _, c := range conditions {
+		if !sequenceIdentifier.MatchString(c.Field) || !conditionOps[c.Op] {
+			return 0, fmt.Errorf("condition %s %s is not valid", c.Field, c.Op)
+		}
+		args = append(args, c.Value)
+		wheres = append(wheres, quoteIdentifier(dbType, c.Field)+" "+c.Op+" "+bindVar(dbType, len(args)))
+	}
+
+	updateSQL := "update " + quoteIdentifier(dbType, tableName) + " set " + strings.Join(sets, ", ") +
+		" where " + strings.Join(wheres, " and ")
+	if dbConfig.RunModeDebug {
+		fmt.Printf("update statement: %s\n", updateSQL)
+	}
+
+	res, err := dbConfig.writeConnect().Exec(updateSQL, args...)
+	if err != nil {
+		return 0, fmt.Errorf("update %s error: %s", tableName, err)
+	}
+
+	return res.RowsAffected()
+}
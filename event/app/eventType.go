@@ -26,12 +26,7 @@ import (
 )
 
 type Event struct {
-	// object name. the value should be "event"
-	Name string
-	// table name which hold project data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[EventSchema]
 }
 
 // 事件信息表结构
@@ -57,9 +52,9 @@ type EventSchema struct {
 	// 事件产生时的操作者,如果是系统动作产生的事件,此字段为空
 	UserID int `form:"userID" json:"userID" yaml:"userID" xml:"userID" db:"userID"`
 	// 是否删除 0正常 1已删除
-	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted"`
+	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted" softdelete:"flag"`
 	//事件的删除时间截
-	DeletedTime int `form:"deletedTime" json:"deletedTime" yaml:"deletedTime" xml:"deletedTime" db:"deletedTime"`
+	DeletedTime int `form:"deletedTime" json:"deletedTime" yaml:"deletedTime" xml:"deletedTime" db:"deletedTime" softdelete:"time"`
 }
 
 // 事件信息表结构
@@ -75,9 +70,9 @@ type EventUserSchema struct {
 	//如果用户已阅读了事件，记录用户阅读事件的时间截
 	ReadTime int `form:"readTime" json:"readTime" yaml:"readTime" xml:"readTime" db:"readTime"`
 	// 是否删除 0正常 1已删除
-	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted"`
+	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted" softdelete:"flag"`
 	//事件的删除时间截
-	DeletedTime int `form:"deletedTime" json:"deletedTime" yaml:"deletedTime" xml:"deletedTime" db:"deletedTime"`
+	DeletedTime int `form:"deletedTime" json:"deletedTime" yaml:"deletedTime" xml:"deletedTime" db:"deletedTime" softdelete:"time"`
 }
 
 // 存储运行期数据
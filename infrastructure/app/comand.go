@@ -121,20 +121,25 @@ func GetCommand(ips, macs []string, hostname, customize string) (*apiServerApp.C
 
 	// priority of mac is second
 	if len(macs) > 0 {
-		whereStatement := make(map[string]string, 0)
-		macWhereStatement := dbEntity.BuildWhereFieldExactWithSlice(macs)
-		whereStatement["mac"] = macWhereStatement
+		hostid := getCachedHostID("mac", macs...)
+		if hostid == "" {
+			whereStatement := make(map[string]string, 0)
+			macWhereStatement := dbEntity.BuildWhereFieldExactWithSlice(macs)
+			whereStatement["mac"] = macWhereStatement
 
-		selectData := db.SelectData{
-			Tb:        []string{"hostMAC"},
-			OutFeilds: []string{"hostid"},
-			Where:     whereStatement,
+			selectData := db.SelectData{
+				Tb:        []string{"hostMAC"},
+				OutFeilds: []string{"hostid"},
+				Where:     whereStatement,
+			}
+			retData, err := dbEntity.QueryData(&selectData)
+			errs = append(errs, err...)
+			if len(retData) > 0 {
+				hostid = utils.Interface2String(retData[0]["hostid"])
+				cacheHostID(hostid, "mac", macs...)
+			}
 		}
-		retData, err := dbEntity.QueryData(&selectData)
-		errs = append(errs, err...)
-		if len(retData) > 0 {
-			lineData := retData[0]
-			hostid := utils.Interface2String(lineData["hostid"])
+		if hostid != "" {
 			commands, err := getCommandForhHostid(hostid)
 			errs = append(errs, err...)
 			if commands != nil {
@@ -168,19 +173,24 @@ func GetCommand(ips, macs []string, hostname, customize string) (*apiServerApp.C
 
 		whereStatement := make(map[string]string, 0)
 		if ipv4Str != "" {
-			whereStatement["ipv4"] = dbEntity.BuildWhereFieldExact(ipv4Str)
-			whereStatement["status"] = "='1'"
-			whereStatement["isManage"] = "='1'"
-			selectData := db.SelectData{
-				Tb:        []string{"hostIP"},
-				OutFeilds: []string{"hostid"},
-				Where:     whereStatement,
+			hostid := getCachedHostID("ipv4", ipv4Str)
+			if hostid == "" {
+				whereStatement["ipv4"] = dbEntity.BuildWhereFieldExact(ipv4Str)
+				whereStatement["status"] = "='1'"
+				whereStatement["isManage"] = "='1'"
+				selectData := db.SelectData{
+					Tb:        []string{"hostIP"},
+					OutFeilds: []string{"hostid"},
+					Where:     whereStatement,
+				}
+				retData, err := dbEntity.QueryData(&selectData)
+				errs = append(errs, err...)
+				if len(retData) > 0 {
+					hostid = utils.Interface2String(retData[0]["hostid"])
+					cacheHostID(hostid, "ipv4", ipv4Str)
+				}
 			}
-			retData, err := dbEntity.QueryData(&selectData)
-			errs = append(errs, err...)
-			if len(retData) > 0 {
-				lineData := retData[0]
-				hostid := utils.Interface2String(lineData["hostIP"])
+			if hostid != "" {
 				commands, err := getCommandForhHostid(hostid)
 				errs = append(errs, err...)
 				if commands != nil {
@@ -190,19 +200,24 @@ func GetCommand(ips, macs []string, hostname, customize string) (*apiServerApp.C
 		}
 
 		if ipv6Str != "" {
-			whereStatement["ipv6"] = dbEntity.BuildWhereFieldExact(ipv6Str)
-			whereStatement["status"] = "='1'"
-			whereStatement["isManage"] = "='1'"
-			selectData := db.SelectData{
-				Tb:        []string{"hostIP"},
-				OutFeilds: []string{"hostid"},
-				Where:     whereStatement,
+			hostid := getCachedHostID("ipv6", ipv6Str)
+			if hostid == "" {
+				whereStatement["ipv6"] = dbEntity.BuildWhereFieldExact(ipv6Str)
+				whereStatement["status"] = "='1'"
+				whereStatement["isManage"] = "='1'"
+				selectData := db.SelectData{
+					Tb:        []string{"hostIP"},
+					OutFeilds: []string{"hostid"},
+					Where:     whereStatement,
+				}
+				retData, err := dbEntity.QueryData(&selectData)
+				errs = append(errs, err...)
+				if len(retData) > 0 {
+					hostid = utils.Interface2String(retData[0]["hostid"])
+					cacheHostID(hostid, "ipv6", ipv6Str)
+				}
 			}
-			retData, err := dbEntity.QueryData(&selectData)
-			errs = append(errs, err...)
-			if len(retData) > 0 {
-				lineData := retData[0]
-				hostid := utils.Interface2String(lineData["hostIP"])
+			if hostid != "" {
 				commands, err := getCommandForhHostid(hostid)
 				errs = append(errs, err...)
 				if commands != nil {
@@ -215,20 +230,25 @@ func GetCommand(ips, macs []string, hostname, customize string) (*apiServerApp.C
 	// the last one is hostname
 	hostname = strings.TrimSpace(hostname)
 	if hostname != "" {
-		whereStatement := make(map[string]string, 0)
-		whereStatement["hostname"] = " = '" + hostname + "'"
-		whereStatement["statusID"] = " = 1 "
-		selectData := db.SelectData{
-			Tb:        []string{"host"},
-			OutFeilds: []string{"hostid"},
-			Where:     whereStatement,
+		hostid := getCachedHostID("hostname", hostname)
+		if hostid == "" {
+			whereStatement := make(map[string]string, 0)
+			whereStatement["hostname"] = " = '" + hostname + "'"
+			whereStatement["statusID"] = " = 1 "
+			selectData := db.SelectData{
+				Tb:        []string{"host"},
+				OutFeilds: []string{"hostid"},
+				Where:     whereStatement,
+			}
+			retData, err := dbEntity.QueryData(&selectData)
+			errs = append(errs, err...)
+			if len(retData) > 0 {
+				hostid = utils.Interface2String(retData[0]["hostid"])
+				cacheHostID(hostid, "hostname", hostname)
+			}
 		}
-		retData, err := dbEntity.QueryData(&selectData)
-		errs = append(errs, err...)
-		if len(retData) > 0 {
-			lineData := retData[0]
-			hostid := lineData["hostid"]
-			commands, err := getCommandForhHostid(utils.Interface2String(hostid))
+		if hostid != "" {
+			commands, err := getCommandForhHostid(hostid)
 			errs = append(errs, err...)
 			if commands != nil {
 				return commands, errs
@@ -241,7 +261,6 @@ func GetCommand(ips, macs []string, hostname, customize string) (*apiServerApp.C
 
 func getCommandForhHostid(hostid string) (*apiServerApp.Command, []sysadmerror.Sysadmerror) {
 	var errs []sysadmerror.Sysadmerror
-	var tmpRet apiServerApp.Command
 	var ret *apiServerApp.Command = nil
 
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(30304010, "debug", "get commands from DB with hostid %s", hostid))
@@ -252,10 +271,19 @@ func getCommandForhHostid(hostid string) (*apiServerApp.Command, []sysadmerror.S
 		return ret, errs
 	}
 
+	// commands are taken from the queue of the host in redis. get them from DB if the queue is not available
+	if WorkingData.redisEntity != nil {
+		command, err, ok := dequeueCommand(hostid)
+		errs = append(errs, err...)
+		if ok {
+			return command, errs
+		}
+	}
+
 	dbEntity := WorkingData.dbConf.Entity
 	whereStatement := make(map[string]string, 0)
 	whereStatement["hostID"] = " = '" + hostid + "'"
-	whereStatement["tryTimes"] = " <3"
+	whereStatement["tryTimes"] = " <" + strconv.Itoa(commandMaxTryTimes)
 	whereStatement["status"] = " = " + strconv.Itoa(int(apiServerApp.CommandStatusCreated))
 	selectData := db.SelectData{
 		Tb:        []string{"command"},
@@ -270,7 +298,19 @@ func getCommandForhHostid(hostid string) (*apiServerApp.Command, []sysadmerror.S
 		return ret, errs
 	}
 
-	lineData := retData[0]
+	command, err := buildCommand(retData[0])
+	errs = append(errs, err...)
+
+	return command, errs
+}
+
+// buildCommand build the command with its parameters from the data of command table
+func buildCommand(lineData db.FieldData) (*apiServerApp.Command, []sysadmerror.Sysadmerror) {
+	var errs []sysadmerror.Sysadmerror
+	var tmpRet apiServerApp.Command
+	var ret *apiServerApp.Command = nil
+
+	dbEntity := WorkingData.dbConf.Entity
 	commandID := utils.Interface2String(lineData["commandID"])
 	command := utils.Interface2String(lineData["command"])
 	s, e := utils.Interface2Int(lineData["synchronized"])
@@ -292,7 +332,7 @@ func getCommandForhHostid(hostid string) (*apiServerApp.Command, []sysadmerror.S
 
 	pwhere := make(map[string]string, 0)
 	pwhere["commandID"] = " = '" + commandID + "'"
-	selectData = db.SelectData{
+	selectData := db.SelectData{
 		Tb:        []string{"commandParameters"},
 		OutFeilds: []string{"name", "value"},
 		Where:     pwhere,
@@ -0,0 +1,292 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	apiServerApp "sysadm/apiserver/app"
+	"sysadm/db"
+	"sysadm/redis"
+	"sysadm/sysadmerror"
+	"sysadm/utils"
+)
+
+// SetRedisEntity set the entity of redis which the commands of hosts are queued in.
+// commands are got from DB on each poll of agents if entity is nil
+func SetRedisEntity(entity redis.RedisEntity) {
+	WorkingData.redisEntity = entity
+}
+
+// AckCommand remove the command from the queue of the host after the agent has reported the status of it.
+// the command will be delivered to the agent again after the visibility timeout if it has not been acked
+func AckCommand(hostid, commandID string) []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	if WorkingData.redisEntity == nil {
+		return errs
+	}
+
+	queue, e := newCommandQueue(hostid)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305001, "error", "can not get command queue of host %s error %s", hostid, e))
+		return errs
+	}
+
+	if e := queue.Ack(context.Background(), strings.TrimSpace(commandID)); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305002, "warning", "ack command %s of host %s error %s", commandID, hostid, e))
+	}
+
+	return errs
+}
+
+// ReceiveCommandStatus save the status of the command identified by commandSeq which reported by an agent and ack
+// the command in the queue of the host, so it will not be delivered to the agent again
+func ReceiveCommandStatus(commandSeq string, statusCode int) []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	commandSeq = strings.TrimSpace(commandSeq)
+	commandID := ""
+	if len(commandSeq) > 8 {
+		commandID = strings.TrimLeft(commandSeq[8:], "0")
+	}
+	if commandID == "" {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305011, "error", "command sequence %s is not valid", commandSeq))
+		return errs
+	}
+
+	selectData := db.SelectData{
+		Tb:        []string{"command"},
+		OutFeilds: []string{"commandID", "hostID"},
+		Where:     map[string]string{"commandID": " = '" + commandID + "'"},
+	}
+	retData, err := WorkingData.dbConf.Entity.QueryData(&selectData)
+	errs = append(errs, err...)
+	if len(retData) < 1 {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305012, "error", "command %s is not found", commandID))
+		return errs
+	}
+	hostid := utils.Interface2String(retData[0]["hostID"])
+
+	updateData := make(db.FieldData, 0)
+	updateData["status"] = statusCode
+	_, err = WorkingData.dbConf.Entity.UpdateData("command", updateData, map[string]string{"commandID": commandID})
+	errs = append(errs, err...)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return errs
+	}
+
+	err = AckCommand(hostid, commandID)
+	errs = append(errs, err...)
+
+	return errs
+}
+
+// refreshCommandQueue rebuild the command queue of the host from DB. it should be called after commands of the
+// host have been committed into DB
+func refreshCommandQueue(hostid string) []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	if WorkingData.redisEntity == nil {
+		return errs
+	}
+
+	queue, e := newCommandQueue(hostid)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305003, "error", "can not get command queue of host %s error %s", hostid, e))
+		return errs
+	}
+
+	err := syncCommandQueue(context.Background(), queue, hostid, true)
+	errs = append(errs, err...)
+
+	return errs
+}
+
+// dequeueCommand take a command of the host from the queue. the command which has been finished or has been tried
+// commandMaxTryTimes is dropped from the queue. the last return value is false if the queue is not available,
+// then the commands should be got from DB
+func dequeueCommand(hostid string) (*apiServerApp.Command, []sysadmerror.Sysadmerror, bool) {
+	var errs []sysadmerror.Sysadmerror
+
+	ctx := context.Background()
+	queue, e := newCommandQueue(hostid)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305004, "error", "can not get command queue of host %s error %s", hostid, e))
+		return nil, errs, false
+	}
+
+	err := syncCommandQueue(ctx, queue, hostid, false)
+	errs = append(errs, err...)
+	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("error") {
+		return nil, errs, false
+	}
+
+	for {
+		commandID, e := queue.Pop(ctx)
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305005, "error", "pop command of host %s from queue error %s", hostid, e))
+			return nil, errs, false
+		}
+
+		if commandID == "" {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305006, "debug", "there is not any command in queue for host %s", hostid))
+			return nil, errs, true
+		}
+
+		lineData, err := getDeliverableCommand(commandID)
+		errs = append(errs, err...)
+		if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+			return nil, errs, false
+		}
+
+		if lineData == nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305007, "debug", "command %s of host %s has been finished or tried too many times, drop it from queue", commandID, hostid))
+			_ = queue.Ack(ctx, commandID)
+			continue
+		}
+
+		updateData := make(db.FieldData, 0)
+		updateData["tryTimes"] = "tryTimes + 1"
+		_, err = WorkingData.dbConf.Entity.UpdateData("command", updateData, map[string]string{"commandID": commandID})
+		errs = append(errs, err...)
+
+		command, err := buildCommand(lineData)
+		errs = append(errs, err...)
+
+		return command, errs, true
+	}
+}
+
+// syncCommandQueue rebuild the command queue of the host from DB if the queue has not been synchronized in
+// commandQueueSyncInterval or force is true. DB is the source of truth of commands, so the commands which have been
+// added by others or lost in redis will be queued again
+func syncCommandQueue(ctx context.Context, queue *redis.ReliableQueue, hostid string, force bool) []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	syncedKey := queue.Key("synced")
+	if !force {
+		synced, e := redis.Exists(WorkingData.redisEntity, ctx, syncedKey)
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305008, "error", "check whether command queue of host %s has been synchronized error %s", hostid, e))
+			return errs
+		}
+
+		if synced {
+			return errs
+		}
+	}
+
+	commandIDs, err := getPendingCommandIDs(hostid)
+	errs = append(errs, err...)
+	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("error") {
+		return errs
+	}
+
+	if e := queue.Rebuild(ctx, commandIDs); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305009, "error", "rebuild command queue of host %s error %s", hostid, e))
+		return errs
+	}
+
+	if e := redis.SetWithExpiration(WorkingData.redisEntity, ctx, syncedKey, "1", commandQueueSyncInterval); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30305010, "warning", "mark command queue of host %s as synchronized error %s", hostid, e))
+	}
+
+	return errs
+}
+
+// getPendingCommandIDs get the IDs of the commands which are waiting to be delivered to the host in FIFO order
+func getPendingCommandIDs(hostid string) ([]string, []sysadmerror.Sysadmerror) {
+	var errs []sysadmerror.Sysadmerror
+	var ret []string
+
+	whereStatement := make(map[string]string, 0)
+	whereStatement["hostID"] = " = '" + hostid + "'"
+	whereStatement["tryTimes"] = " <" + strconv.Itoa(commandMaxTryTimes)
+	whereStatement["status"] = " = " + strconv.Itoa(int(apiServerApp.CommandStatusCreated))
+	selectData := db.SelectData{
+		Tb:        []string{"command"},
+		OutFeilds: []string{"commandID"},
+		Where:     whereStatement,
+		Order:     []db.OrderData{{Key: "commandID", Order: 0}},
+	}
+	retData, err := WorkingData.dbConf.Entity.QueryData(&selectData)
+	errs = append(errs, err...)
+	for _, line := range retData {
+		ret = append(ret, utils.Interface2String(line["commandID"]))
+	}
+
+	return ret, errs
+}
+
+// getDeliverableCommand get the command with commandID if it is still waiting to be delivered.
+// nil will be returned if the command has been finished or tried commandMaxTryTimes
+func getDeliverableCommand(commandID string) (db.FieldData, []sysadmerror.Sysadmerror) {
+	var errs []sysadmerror.Sysadmerror
+
+	whereStatement := make(map[string]string, 0)
+	whereStatement["commandID"] = " = '" + commandID + "'"
+	whereStatement["tryTimes"] = " <" + strconv.Itoa(commandMaxTryTimes)
+	whereStatement["status"] = " = " + strconv.Itoa(int(apiServerApp.CommandStatusCreated))
+	selectData := db.SelectData{
+		Tb:        []string{"command"},
+		OutFeilds: []string{"commandID", "command", "synchronized"},
+		Where:     whereStatement,
+	}
+	retData, err := WorkingData.dbConf.Entity.QueryData(&selectData)
+	errs = append(errs, err...)
+	if len(retData) < 1 {
+		return nil, errs
+	}
+
+	return retData[0], errs
+}
+
+func newCommandQueue(hostid string) (*redis.ReliableQueue, error) {
+	return redis.NewReliableQueue(WorkingData.redisEntity, commandQueuePrefix+strings.TrimSpace(hostid), commandVisibilityTimeout)
+}
+
+// getCachedHostID get the hostid of a host identified by kind(mac, ip or hostname) with values from redis.
+// empty string will be returned if it has not been cached
+func getCachedHostID(kind string, values ...string) string {
+	if WorkingData.redisEntity == nil {
+		return ""
+	}
+
+	hostid, e := redis.Get(WorkingData.redisEntity, context.Background(), hostIDCacheKey(kind, values...))
+	if e != nil {
+		return ""
+	}
+
+	return hostid
+}
+
+// cacheHostID cache the hostid of a host identified by kind(mac, ip or hostname) with values for hostIDCacheTTL
+func cacheHostID(hostid, kind string, values ...string) {
+	if WorkingData.redisEntity == nil || strings.TrimSpace(hostid) == "" {
+		return
+	}
+
+	_ = redis.SetWithExpiration(WorkingData.redisEntity, context.Background(), hostIDCacheKey(kind, values...), hostid, hostIDCacheTTL)
+}
+
+func hostIDCacheKey(kind string, values ...string) string {
+	return hostIDCachePrefix + kind + ":" + strings.Join(values, ",")
+}
@@ -0,0 +1,100 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	goredis "github.com/go-redis/redis/v8"
+
+	"sysadm/db"
+	"sysadm/redis"
+	"sysadm/sysadmerror"
+)
+
+// commandDB returns the commands in commands and records the updates of them
+type commandDB struct {
+	db.DbEntity
+
+	commands map[string]string
+	updates  map[string]db.FieldData
+}
+
+func (c *commandDB) QueryData(sd *db.SelectData) ([]db.FieldData, []sysadmerror.Sysadmerror) {
+	commandID := strings.Trim(strings.TrimSpace(sd.Where["commandID"]), "= '")
+	hostID, ok := c.commands[commandID]
+	if !ok {
+		return nil, nil
+	}
+
+	return []db.FieldData{{"commandID": commandID, "hostID": hostID}}, nil
+}
+
+func (c *commandDB) UpdateData(tb string, data db.FieldData, where map[string]string) (int, []sysadmerror.Sysadmerror) {
+	c.updates[where["commandID"]] = data
+
+	return 1, nil
+}
+
+// commandRedis records the members removed from the sorted sets
+type commandRedis struct {
+	redis.RedisEntity
+
+	removed map[string][]interface{}
+}
+
+func (c *commandRedis) ZRem(ctx context.Context, key string, members ...interface{}) *goredis.IntCmd {
+	c.removed[key] = append(c.removed[key], members...)
+	cmd := goredis.NewIntCmd(ctx)
+	cmd.SetVal(int64(len(members)))
+
+	return cmd
+}
+
+func TestReceiveCommandStatus(t *testing.T) {
+	commands := &commandDB{commands: map[string]string{"12": "7"}, updates: map[string]db.FieldData{}}
+	entity := &commandRedis{removed: map[string][]interface{}{}}
+	oldConf, oldEntity := WorkingData.dbConf, WorkingData.redisEntity
+	WorkingData.dbConf = &db.DbConfig{Entity: commands}
+	WorkingData.redisEntity = entity
+	defer func() {
+		WorkingData.dbConf, WorkingData.redisEntity = oldConf, oldEntity
+	}()
+
+	errs := ReceiveCommandStatus("2024010100000012", 2)
+	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("error") {
+		t.Fatalf("receive command status error: %v", errs)
+	}
+
+	if status := commands.updates["12"]["status"]; status != 2 {
+		t.Errorf("status of command 12 should be 2, got %v", status)
+	}
+
+	queue, _ := newCommandQueue("7")
+	removed := entity.removed[queue.Key("processing")]
+	if len(removed) != 1 || removed[0] != "12" {
+		t.Errorf("command 12 should be acked in the queue of host 7, got %v", entity.removed)
+	}
+
+	errs = ReceiveCommandStatus("20240101", 2)
+	if sysadmerror.GetMaxLevel(errs) < sysadmerror.GetLevelNum("error") {
+		t.Errorf("command sequence without command id should be rejected")
+	}
+}
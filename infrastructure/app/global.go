@@ -17,6 +17,8 @@
 
 package app
 
+import "time"
+
 var moduleName string = "infrastructure"
 var apiVersion string = "1.0"
 var supportApiVers = []string{"0.1", "1.0", "ui"}
@@ -33,3 +35,16 @@ var hostStatus = map[string]string{
 	"deleted":     "已删除",
 	"unkown":      "未知",
 }
+
+// commands which have been delivered to agent but not been acked will be delivered again after commandVisibilityTimeout
+var commandVisibilityTimeout = 5 * time.Minute
+
+// command queues are rebuilt from DB every commandQueueSyncInterval
+var commandQueueSyncInterval = 10 * time.Minute
+
+// a command will not be delivered to agent after it has been tried commandMaxTryTimes
+var commandMaxTryTimes = 3
+
+var commandQueuePrefix = "infrastructure:commandqueue:"
+var hostIDCachePrefix = "infrastructure:hostid:"
+var hostIDCacheTTL = 10 * time.Minute
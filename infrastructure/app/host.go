@@ -115,8 +115,7 @@ func addHost(c *sysadmServer.Context) {
 		return
 	}
 
-	nextCommandID++
-	yid, err := addYumHostToDB(tx, &requestData, hostid, nextCommandID)
+	yid, err := addYumHostToDB(tx, &requestData, hostid)
 	errs = append(errs, err...)
 	if yid == 0 {
 		_ = tx.Rollback()
@@ -131,6 +130,10 @@ func addHost(c *sysadmServer.Context) {
 		err := apiutils.SendResponseForErrorMessage(c, 30301007, fmt.Sprintf("add the information of host %s into DB error %s", requestData.Hostname, e))
 		errs = append(errs, err...)
 		logErrors(errs)
+	} else {
+		// queue the commands of the new host, so agent can get them from redis
+		err = refreshCommandQueue(strconv.Itoa(hostid))
+		errs = append(errs, err...)
 	}
 	msg := "host has be added successful"
 	err = apiutils.SendResponseForSuccessMessage(c, msg)
@@ -431,14 +434,6 @@ func addHostToDB(tx *db.Tx, data *ApiHost) (int, []sysadmerror.Sysadmerror) {
 		return 0, errs
 	}
 
-	// update hostid value id ids table using transaction
-	tmpID, err := updateNextID("host", "hostid", tx, (nextHostid + 1))
-	errs = append(errs, err...)
-	if tmpID == 0 {
-		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30303019, "error", "can not update next hostid"))
-		return 0, errs
-	}
-
 	return nextHostid, errs
 }
 
@@ -485,7 +480,7 @@ addYumHostToDB add relation information between host and yum into DB
 return 1 and []sysadmerror.Sysadmerror
 otherwise return 0  and []sysadmerror.Sysadmerror
 */
-func addYumHostToDB(tx *db.Tx, data *ApiHost, hostid, nextCommandID int) (int, []sysadmerror.Sysadmerror) {
+func addYumHostToDB(tx *db.Tx, data *ApiHost, hostid int) (int, []sysadmerror.Sysadmerror) {
 	var errs []sysadmerror.Sysadmerror
 
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(30303010, "debug", "try to add yum host relations to DB"))
@@ -501,18 +496,17 @@ func addYumHostToDB(tx *db.Tx, data *ApiHost, hostid, nextCommandID int) (int, [
 			return 0, errs
 		}
 
+		nextCommandID, err := getNextID("command", "commandID", tx)
+		errs = append(errs, err...)
+		if nextCommandID == 0 {
+			return 0, errs
+		}
+
 		cid, err := addYumConfigCommandToDB(tx, hostid, nextCommandID, yID, data)
 		errs = append(errs, err...)
 		if cid == 0 {
 			return 0, errs
 		}
-		nextCommandID++
-	}
-
-	nextID, err := updateNextID("command", "commandID", tx, nextCommandID)
-	errs = append(errs, err...)
-	if nextID == 0 {
-		return 0, errs
 	}
 
 	return 1, errs
@@ -587,6 +581,8 @@ func addYumConfigCommandToDB(tx *db.Tx, hostid, nextCommandID int, yumid string,
 }
 
 // get next ID from ids table for tableName with fieldName
+// the ID is allocated by the sequence of tableName.fieldName in tx if tx is not nil, so concurrent requests never get the
+// same ID and the ID is not consumed if tx is rolled back
 // return the ID value and []sysadmerror.Sysadmerror if successfule
 // otherewise return 0 and []sysadmerror.Sysadmerror
 func getNextID(tableName, fieldName string, tx *db.Tx) (int, []sysadmerror.Sysadmerror) {
@@ -597,59 +593,17 @@ func getNextID(tableName, fieldName string, tx *db.Tx) (int, []sysadmerror.Sysad
 		return 0, append(errs, sysadmerror.NewErrorWithStringLevel(30303014, "error", "table name %s or field name %s is empty", tableName, fieldName))
 	}
 
-	whereStatement := make(map[string]string, 0)
-	whereStatement["tableName"] = " = '" + tableName + "'"
-	whereStatement["fieldName"] = " = '" + fieldName + "'"
-	selectData := db.SelectData{
-		Tb:        []string{"ids"},
-		OutFeilds: []string{"nextValue"},
-		Where:     whereStatement,
-	}
-
-	dbEntity := WorkingData.dbConf.Entity
-	retData, err := dbEntity.QueryData(&selectData)
-	errs = append(errs, err...)
-	if retData == nil {
-		return 0, errs
-	}
-
-	nextID := 0
-	line := retData[0]
-	if v, ok := line["nextValue"]; ok {
-		id, e := utils.Interface2Int(v)
-		if e != nil {
-			errs = append(errs, sysadmerror.NewErrorWithStringLevel(30303015, "error", "can not get next ID with table %s and field %s error %s", tableName, fieldName, e))
-			return 0, errs
-		} else {
-			nextID = id
-		}
+	var nextID uint64
+	var e error
+	if tx != nil && tx.Tx != nil {
+		nextID, e = tx.NextID(tableName, fieldName)
 	} else {
-		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30303016, "error", "can not get next ID with table %s and field %s", tableName, fieldName))
-		return 0, errs
+		nextID, e = db.NextID(WorkingData.dbConf.Entity, tableName, fieldName)
 	}
-
-	return nextID, errs
-}
-
-// update next ID into ids table for tableName with fieldName
-// return the 1 and []sysadmerror.Sysadmerror if successfule
-// otherewise return 0 and []sysadmerror.Sysadmerror
-func updateNextID(tableName, fieldName string, tx *db.Tx, nextID int) (int, []sysadmerror.Sysadmerror) {
-	var errs []sysadmerror.Sysadmerror
-	errs = append(errs, sysadmerror.NewErrorWithStringLevel(30303016, "debug", "now try to update nextID(%d) for table %s with field %s ", nextID, tableName, fieldName))
-
-	// update commandID value in ids table using transaction
-	updateData := make(db.FieldData, 0)
-	updateData["nextValue"] = nextID
-	whereStatement := make(map[string]string, 0)
-	whereStatement["tableName"] = tableName
-	whereStatement["fieldName"] = fieldName
-
-	_, err := tx.UpdateData("ids", updateData, whereStatement)
-	errs = append(errs, err...)
-	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(30303015, "error", "can not get next ID with table %s and field %s error %s", tableName, fieldName, e))
 		return 0, errs
 	}
 
-	return 1, errs
+	return int(nextID), errs
 }
@@ -21,6 +21,7 @@ import (
 	"github.com/wangyysde/sysadmServer"
 	"sysadm/config"
 	sysadmDB "sysadm/db"
+	"sysadm/redis"
 	"sysadm/sysadmerror"
 )
 
@@ -53,6 +54,8 @@ type RuningData struct {
 	workingRoot   string
 	sessionOption sessionOption
 	pageInfo      pageInfo
+	// commands of hosts are queued in redis if it is not nil
+	redisEntity redis.RedisEntity
 }
 
 // Initating working data for an instance
@@ -63,12 +63,12 @@ func delHost(c *sysadmServer.Context) {
 		return
 	}
 
+	// the host is marked as deleted only. the data of the host will be purged by the purge job after the retention of it
 	for _, hostid := range hostids {
-		if e := tryDelHostData(hostid, tx); e != nil {
+		hostid = strings.TrimSpace(hostid)
+		if hostid == "" {
 			tx.Rollback()
-			msg := fmt.Sprintf("%s", e)
-			errs = append(errs, sysadmerror.NewErrorWithStringLevel(3040003, "error", "%s", e))
-			err := apiutils.SendResponseForErrorMessage(c, 3040003, msg)
+			err := apiutils.SendResponseForErrorMessage(c, 3040003, "No host requested to delete")
 			errs = append(errs, err...)
 			logErrors(errs)
 			return
@@ -78,7 +78,7 @@ func delHost(c *sysadmServer.Context) {
 		whereMap["hostid"] = hostid
 		updataData := make(db.FieldData, 0)
 		updataData["status"] = "\"deleted\""
-		deleteTimeStr := time.Now().Format("2006-01-02 15:04:05")
+		deleteTimeStr := time.Now().Format(hostDeleteTimeFormat)
 		updataData["deletetime"] = "\"" + deleteTimeStr + "\""
 		_, err := tx.UpdateData("host", updataData, whereMap)
 		errs = append(errs, err...)
@@ -0,0 +1,103 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sysadm/db"
+	"sysadm/utils"
+	"time"
+)
+
+// hostDeleteTimeFormat is the format of deletetime field of host
+const hostDeleteTimeFormat = "2006-01-02 15:04:05"
+
+// hostPurger purges the hosts which have been marked as deleted by delHost.
+// hosts are deleted by status field but not by isDeleted field, so hostPurger is implemented here instead of
+// using sysadmObjects.Repository
+type hostPurger struct{}
+
+// GetName implements Purger interface
+func (p hostPurger) GetName() string {
+	return "host"
+}
+
+// Purge delete the hosts which have been deleted before before and their data from DB.
+// return the number of hosts have been purged
+func (p hostPurger) Purge(before time.Time) (int, error) {
+	if WorkingData.dbConf == nil {
+		return 0, fmt.Errorf("DB configuration has not be set")
+	}
+	dbEntity := WorkingData.dbConf.Entity
+
+	whereMap := make(map[string]string, 0)
+	whereMap["status"] = "=\"deleted\""
+	whereMap["deletetime"] = " between \"1\" and \"" + before.Format(hostDeleteTimeFormat) + "\""
+	selectData := db.SelectData{
+		Tb:        []string{"host"},
+		OutFeilds: []string{"hostid"},
+		Where:     whereMap,
+	}
+	dbData, errs := dbEntity.QueryData(&selectData)
+	if dbData == nil {
+		logErrors(errs)
+		return 0, fmt.Errorf("query deleted hosts error")
+	}
+
+	var hostids []string
+	for _, row := range dbData {
+		hostid := strings.TrimSpace(utils.Interface2String(row["hostid"]))
+		if hostid != "" {
+			hostids = append(hostids, hostid)
+		}
+	}
+	if len(hostids) < 1 {
+		return 0, nil
+	}
+
+	tx, errs := db.Begin(dbEntity)
+	if tx == nil {
+		logErrors(errs)
+		return 0, fmt.Errorf("start transaction on DB error")
+	}
+
+	for _, hostid := range hostids {
+		if e := tryDelHostData(hostid, tx); e != nil {
+			tx.Rollback()
+			return 0, e
+		}
+
+		deleteData := db.SelectData{
+			Tb:    []string{"host"},
+			Where: map[string]string{"hostid": "=\"" + hostid + "\""},
+		}
+		affectedRow, _ := tx.DeleteData(&deleteData)
+		if affectedRow == -1 {
+			tx.Rollback()
+			return 0, fmt.Errorf("delete host %s error", hostid)
+		}
+	}
+
+	if e := tx.Commit(); e != nil {
+		tx.Rollback()
+		return 0, e
+	}
+
+	return len(hostids), nil
+}
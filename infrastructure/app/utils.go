@@ -24,6 +24,7 @@ import (
 	"github.com/wangyysde/sysadmServer"
 	"sysadm/config"
 	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
 	"sysadm/sysadmerror"
 	"sysadm/user"
 )
@@ -54,6 +55,7 @@ func (i Infrastructure) SetWorkingData(dbConf *sysadmDB.DbConfig, logConf *confi
 			return errs
 		}
 		WorkingData.dbConf = dbConf
+		sysadmObjects.RegisterPurger(hostPurger{})
 	}
 
 	if WorkingData.logConf == nil {
@@ -24,6 +24,7 @@ import (
 	sysadmDB "sysadm/db"
 	sysadmObjects "sysadm/objects/app"
 	sysadmUtils "sysadm/utils"
+	"time"
 )
 
 func HostNew(dbConfig *sysadmDB.DbConfig, workingRoot string) (Host, error) {
@@ -56,124 +57,79 @@ func HostNew(dbConfig *sysadmDB.DbConfig, workingRoot string) (Host, error) {
 		}
 	}
 
-	ret.Name = hostObjectName
-	ret.TableName = hostTableName
-	ret.PkName = hostTablePkName
+	ret.Repository = sysadmObjects.NewRepository[HostSchema](hostObjectName, hostTableName, hostTablePkName)
 	return ret, nil
 }
 
-func (h Host) GetObjectInfoByID(id string) (interface{}, error) {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return nil, fmt.Errorf("can not get host information with empty ID")
+// ReferringIDs implements Referrer interface. hosts are deleted by status, so the hosts which status is deleted
+// are not included
+func (h Host) ReferringIDs(field string, ids []string) ([]string, error) {
+	var ret []string
+	if len(ids) < 1 {
+		return ret, nil
 	}
 
-	dbData, e := sysadmObjects.GetObjectInfoByID(h.TableName, h.PkName, id)
-	if e != nil {
-		return nil, e
-	}
-
-	hostData := HostSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &hostData)
-
-	return hostData, e
-}
-
-func (h Host) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &HostSchema{}); !ok {
-		return -1, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &HostSchema{}); !ok {
-		return -1, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	return sysadmObjects.GetObjectCount(h.TableName, h.PkName, searchContent, ids, searchKeys, conditions)
-}
-
-func (h Host) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
-	startPos, step int, orders map[string]string) ([]interface{}, error) {
-
-	var ret []interface{}
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &HostSchema{}); !ok {
-		return ret, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &HostSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	var orderKeys []string
-	for k, _ := range orders {
-		orderKeys = append(orderKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(orderKeys, &HostSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
+	var quotedIDs []string
+	for _, id := range ids {
+		quotedIDs = append(quotedIDs, "'"+strings.TrimSpace(id)+"'")
 	}
 
-	dbData, e := sysadmObjects.GetObjectList(h.TableName, h.PkName, searchContent, ids, searchKeys, conditions, startPos, step, orders)
+	conditions := make(map[string]string, 0)
+	conditions[field] = " in (" + strings.Join(quotedIDs, ",") + ")"
+	conditions["status"] = "<>'" + string(HostStatusDeleted) + "'"
+	hosts, e := h.List("", nil, nil, conditions, 0, 0, nil)
 	if e != nil {
 		return ret, e
 	}
 
-	var tmpRes []interface{}
-	for _, v := range dbData {
-		cluster := &HostSchema{}
-		if e := sysadmObjects.Unmarshal(v, cluster); e != nil {
-			return ret, e
-		}
-		tmpRes = append(tmpRes, *cluster)
+	for _, host := range hosts {
+		ret = append(ret, strconv.Itoa(host.HostId))
 	}
 
-	return tmpRes, nil
+	return ret, nil
 }
 
-func (h Host) AddObject(data interface{}) error {
-	hostSchemaData, ok := data.(HostSchema)
-	if !ok {
-		return fmt.Errorf("the data try to add is not valid")
-	}
-
-	insertData, e := sysadmObjects.Marshal(hostSchemaData)
+// SoftDeleteCascade implements SoftDeleter interface. nothing references hosts, so only hosts are marked as deleted
+func (h Host) SoftDeleteCascade(ids []string) error {
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, h)
 	if e != nil {
 		return e
 	}
 
-	return sysadmObjects.AddObject(h.TableName, "", insertData)
-}
+	if e := h.MarkDeletedTx(tx, ids); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
 
-func (h Host) GetObjectMaxID() (uint, error) {
-	return sysadmObjects.GetObjectMaxID(runData.dbConf.Entity, h.TableName, h.PkName)
+	return tx.Commit()
 }
 
-func (h Host) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
-	addData := make(map[string]interface{}, 0)
-
-	hostSchemaData, ok := data.(HostSchema)
-	if !ok {
-		return addData, "", fmt.Errorf("there is an error occurred when coverting data to host schema")
+// MarkDeletedTx implements Referrer interface. hosts are marked as deleted by status and delete time, the data of
+// them will be purged by the purge job
+func (h Host) MarkDeletedTx(tx sysadmObjects.ObjectTx, ids []string) error {
+	if tx.Tx == nil {
+		return fmt.Errorf("transaction has not began")
 	}
 
-	addData, e := sysadmObjects.Marshal(hostSchemaData)
-	if e != nil {
-		return addData, "", e
+	updateData := make(sysadmDB.FieldData, 0)
+	updateData["status"] = "'" + string(HostStatusDeleted) + "'"
+	updateData["deletetime"] = "'" + time.Now().Format("2006-01-02 15:04:05") + "'"
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+
+		if e := tx.Tx.NewUpdateData(h.TableName, updateData, map[string]string{h.PkName: id}); e != nil {
+			return e
+		}
 	}
 
-	return addData, h.TableName, nil
+	return nil
 }
 
-func (h Host) GetObjectIDFieldName() (string, string, error) {
-	return h.TableName, h.PkName, nil
+func (h Host) NextObjectID() (uint, error) {
+	return sysadmObjects.NextObjectID(runData.dbConf.Entity, h.TableName, h.PkName)
 }
 
 func (h Host) AddHostFromCluster(userid, osID, osversionid, dcid, azid int, hostname, status, k8sclusterid, machineID, systemID, architecture, kernelVersion string, ips []string) error {
@@ -207,24 +163,18 @@ func (h Host) AddHostFromCluster(userid, osID, osversionid, dcid, azid int, host
 		return e
 	}
 
-	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, objHost)
-	if e != nil {
-		return e
-	}
-
-	e = tx.AddObject(hostSchemaData)
+	hostID, e := objHost.NextObjectID()
 	if e != nil {
-		tx.Rollback()
 		return e
 	}
+	hostSchemaData.HostId = int(hostID)
 
-	e = tx.UpdateObjectNextID()
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, objHost)
 	if e != nil {
-		tx.Rollback()
 		return e
 	}
 
-	_, e = sysadmObjects.GetObjectMaxID(tx.Tx.Entity, hostTableName, DefaultPkName)
+	e = tx.AddObject(hostSchemaData)
 	if e != nil {
 		tx.Rollback()
 		return e
@@ -17,13 +17,12 @@
 
 package app
 
+import (
+	sysadmObjects "sysadm/objects/app"
+)
+
 type Host struct {
-	// object name. the value should be "host"
-	Name string
-	// table name which hold host data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[HostSchema]
 }
 
 // 节点IP地址关系表结构
@@ -103,7 +102,7 @@ type HostSchema struct {
 	// 主动模式时,apiserver连接agent时的端口号
 	AgentPort int `form:"agentPort" json:"agentPort" yaml:"agentPort" xml:"agentPort" db:"agentPort"`
 	// 节点所属集群ID，如果为空表示节点不隶属于任何K8S集群
-	K8sClusterID string `form:"k8sclusterid" json:"k8sclusterid" yaml:"k8sclusterid" xml:"k8sclusterid" db:"k8sclusterid"`
+	K8sClusterID string `form:"k8sclusterid" json:"k8sclusterid" yaml:"k8sclusterid" xml:"k8sclusterid" db:"k8sclusterid" references:"k8scluster"`
 	// 节点创建时间截
 	CreateTime string `form:"createTime" json:"createTime" yaml:"createTime" xml:"createTime" db:"createTime"`
 	// 节点下线开始时间
@@ -111,9 +110,9 @@ type HostSchema struct {
 	// 节点删除时间
 	DeleteTime string `form:"deletetime" json:"deletetime" yaml:"deletetime" xml:"deletetime" db:"deletetime"`
 	// 节点所属的数据中心ID
-	Dcid uint `form:"dcid" json:"dcid" yaml:"dcid" xml:"dcid" db:"dcid"`
+	Dcid uint `form:"dcid" json:"dcid" yaml:"dcid" xml:"dcid" db:"dcid" references:"datacenter"`
 	// 节点所属的可用区ID
-	Azid uint `form:"azid" json:"azid" yaml:"azid" xml:"azid" db:"azid"`
+	Azid uint `form:"azid" json:"azid" yaml:"azid" xml:"azid" db:"azid" references:"availablezone"`
 	// 节点的machineID
 	MachineID string `form:"machineID" json:"machineID" yaml:"machineID" xml:"machineID" db:"machineID"`
 	// 节点systemID
@@ -71,10 +71,9 @@ func clusterDetailHandler(c *sysadmServer.Context) {
 	}
 
 	// get cluster infromation by clusterID
-	var clusterEntity sysadmObjects.ObjectEntity
-	clusterEntity = New()
+	clusterEntity := New()
 
-	data, e := clusterEntity.GetObjectInfoByID(clusterID)
+	clusterData, e := clusterEntity.Get(clusterID)
 	if e != nil {
 		errs = append(errs, sysadmLog.NewErrorWithStringLevel(700070005, "error", "there is an error occurred when get cluster information:%s", e))
 		runData.logEntity.LogErrors(errs)
@@ -82,7 +81,6 @@ func clusterDetailHandler(c *sysadmServer.Context) {
 		c.HTML(http.StatusOK, messageTemplateFile, messageTplData)
 		return
 	}
-	clusterData := data.(K8sclusterSchema)
 
 	var tplDataLines []objectsUI.LineDataForDetail
 	separator := objectsUI.ItemForDetail{Label: "基础信息", IsSeparator: true}
@@ -19,92 +19,18 @@ package app
 
 import (
 	"fmt"
-	"strings"
 	sysadmObjects "sysadm/objects/app"
 )
 
-func New() K8scluster {
-	ret := K8scluster{}
-	ret.Name = DefaultObjectName
-	ret.TableName = DefaultTableName
-	ret.PkName = DefaultPkName
-	return ret
+// register k8scluster and host to the dependency graph of objects, and register k8scluster to the secret rotation
+func init() {
+	sysadmObjects.RegisterReferrer(New())
+	sysadmObjects.RegisterSecretRotator(New())
+	sysadmObjects.RegisterReferrer(Host{Repository: sysadmObjects.NewRepository[HostSchema](hostObjectName, hostTableName, hostTablePkName)})
 }
 
-func (k K8scluster) GetObjectInfoByID(id string) (interface{}, error) {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return nil, fmt.Errorf("can not get cluster information with empty ID")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(k.TableName, k.PkName, id)
-	if e != nil {
-		return nil, e
-	}
-
-	k8sclusterData := K8sclusterSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &k8sclusterData)
-
-	return k8sclusterData, e
-}
-
-func (k K8scluster) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &K8sclusterSchema{}); !ok {
-		return -1, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &K8sclusterSchema{}); !ok {
-		return -1, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	return sysadmObjects.GetObjectCount(k.TableName, k.PkName, searchContent, ids, searchKeys, conditions)
-}
-
-func (k K8scluster) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
-	startPos, step int, orders map[string]string) ([]interface{}, error) {
-
-	var ret []interface{}
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &K8sclusterSchema{}); !ok {
-		return ret, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &K8sclusterSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	var orderKeys []string
-	for k, _ := range orders {
-		orderKeys = append(orderKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(orderKeys, &K8sclusterSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
-	}
-
-	dbData, e := sysadmObjects.GetObjectList(k.TableName, k.PkName, searchContent, ids, searchKeys, conditions, startPos, step, orders)
-	if e != nil {
-		return ret, e
-	}
-
-	var tmpRes []interface{}
-	for _, v := range dbData {
-		cluster := &K8sclusterSchema{}
-		if e := sysadmObjects.Unmarshal(v, cluster); e != nil {
-			return ret, e
-		}
-		tmpRes = append(tmpRes, *cluster)
-	}
-
-	return tmpRes, nil
+func New() K8scluster {
+	return K8scluster{Repository: sysadmObjects.NewRepository[K8sclusterSchema](DefaultObjectName, DefaultTableName, DefaultPkName)}
 }
 
 func GetStatusText(status int) string {
@@ -116,36 +42,7 @@ func GetStatusText(status int) string {
 	return statusText
 }
 
-func (k K8scluster) AddObject(data interface{}) error {
-	k8sSchemaData, ok := data.(K8sclusterSchema)
-	if !ok {
-		return fmt.Errorf("the data try to add is not valid")
-	}
-
-	insertData, e := sysadmObjects.Marshal(k8sSchemaData)
-	if e != nil {
-		return e
-	}
-
-	return sysadmObjects.AddObject(k.TableName, "", insertData)
-}
-
-func (k K8scluster) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
-	addData := make(map[string]interface{}, 0)
-
-	schemaData, ok := data.(K8sclusterSchema)
-	if !ok {
-		return addData, "", fmt.Errorf("there is an error occurred when coverting data to K8S Cluster schema")
-	}
-
-	addData, e := sysadmObjects.Marshal(schemaData)
-	if e != nil {
-		return addData, "", e
-	}
-
-	return addData, k.TableName, nil
-}
-
+// GetObjectIDFieldName cluster ID is not allocated from the sequences, so it return an error always
 func (k K8scluster) GetObjectIDFieldName() (string, string, error) {
 	return "", "", fmt.Errorf("cluster ID should not automatic increment")
 }
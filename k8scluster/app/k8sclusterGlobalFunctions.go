@@ -47,11 +47,8 @@ func SetRunData(dbConf *sysadmDB.DbConfig, logEntity *sysadmLog.LoggerConfig, wo
 		return e
 	}
 
-	runData.objectEntiy = K8scluster{
-		Name:      DefaultObjectName,
-		TableName: DefaultTableName,
-		PkName:    DefaultPkName,
-	}
+	runData.objectEntiy = New()
+	sysadmObjects.RegisterPurger(New())
 
 	return nil
 }
@@ -76,6 +73,7 @@ func AddHandlers(r *sysadmServer.Engine) error {
 	groupPath := "/api/" + DefaultApiVersion + "/" + DefaultModuleName
 	v1 := r.Group(groupPath)
 	{
+		v1.POST("/del", delHandler)
 		v1.POST("/add", addPostHandler)
 		v1.POST("/validCNName", validCNNameHandler)
 		v1.POST("/validENName", validENNameHandler)
@@ -89,6 +87,8 @@ func AddHandlers(r *sysadmServer.Engine) error {
 	display := r.Group(groupPath)
 	{
 		display.GET("/addform", addformHandler)
+		display.POST("/del", delHandler)
+		display.POST("/restore", restoreHandler)
 		display.GET("/list", listHandler)
 		display.GET("/getazbydcidforselect", getazbydcidforselectHandler)
 		display.GET("/detail", clusterDetailHandler)
@@ -43,7 +43,7 @@ var defaultOrderDirection = "1"
 
 // all popmenu items defined Format:
 // item name, action name, action method
-var allPopMenuItems = []string{"查看详情,detail,GET,page", "节点列表,list,GET,page", "删除集群,del,POST,tip", "kubeconf下载,getkubeconf,GET,window"}
+var allPopMenuItems = []string{"查看详情,detail,GET,page", "节点列表,list,GET,page", "删除集群,del,POST,tip", "kubeconf下载,getkubeconf,GET,window", "恢复集群,restore,POST,tip"}
 
 // define all list items(cols) name
 var allListItems = map[string]string{"TD1": "集群ID", "TD2": "数据中心/可用区", "TD3": "集群名", "TD4": "版本", "TD5": "状态"}
@@ -106,7 +106,7 @@ func listHandler(c *sysadmServer.Context) {
 	ids := objectsUI.GetObjectIdsFromRequest(requestData)
 	searchKeys := []string{"id", "cnName", "version"}
 	startPos := objectsUI.GetStartPosFromRequest(requestData)
-	clusterConditions := objectsUI.BuildCondition(requestData, "=0", "dcid")
+	clusterConditions := objectsUI.BuildCondition(requestData, objectsUI.GetDeletedConditionFromRequest(requestData), "dcid")
 
 	// get total number of list objects
 	var clusterEntity sysadmObjects.ObjectEntity
@@ -244,6 +244,11 @@ func getDcAzName(azid uint, dcData []datacenter.DatacenterSchema, azData []az.Av
 }
 
 func getPopMenuItemsId(status, isDeleted int) string {
+	// deleted cluster can be viewed or restored only
+	if isDeleted == 1 {
+		return "0,4"
+	}
+
 	popmenuitemidstr := ""
 	switch status {
 	case 0:
@@ -264,7 +269,7 @@ func getPopMenuItemsId(status, isDeleted int) string {
 }
 
 func getRequestData(c *sysadmServer.Context) (map[string]string, error) {
-	requestData, e := utils.NewGetRequestData(c, []string{"groupSelectID", "searchContent", "objectid", "start", "orderfield", "direction"})
+	requestData, e := utils.NewGetRequestData(c, []string{"groupSelectID", "searchContent", "objectid", "start", "orderfield", "direction", "deleted"})
 	if e != nil {
 		return requestData, e
 	}
@@ -26,12 +26,7 @@ import (
 )
 
 type K8scluster struct {
-	// object name. the value should be "k8scluster"
-	Name string
-	// table name which hold project data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[K8sclusterSchema]
 }
 
 // K8s 集群信息表结构
@@ -41,9 +36,9 @@ type K8sclusterSchema struct {
 	// k8s集群自身的ID,这个值的获取见k8sclient组件的GetKubernetesClusterID函数
 	K8sClusterID string `form:"k8sClusterID" json:"k8sClusterID" yaml:"k8sClusterID" xml:"k8sClusterID" db:"k8sClusterID"`
 	// 集群所属的数据中心ID
-	Dcid uint `form:"dcid" json:"dcid" yaml:"dcid" xml:"dcid" db:"dcid"`
+	Dcid uint `form:"dcid" json:"dcid" yaml:"dcid" xml:"dcid" db:"dcid" references:"datacenter"`
 	// 集群所属的可用区ID
-	Azid uint `form:"azid" json:"azid" yaml:"azid" xml:"azid" db:"azid"`
+	Azid uint `form:"azid" json:"azid" yaml:"azid" xml:"azid" db:"azid" references:"availablezone"`
 	// 集群中文名称
 	CnName string `form:"cnName" json:"cnName" yaml:"cnName" xml:"cnName" db:"cnName"`
 	// 集群英文名称
@@ -57,13 +52,13 @@ type K8sclusterSchema struct {
 	// 连接集群的证书
 	Cert string `form:"cert" json:"cert" yaml:"cert" xml:"cert" db:"cert"`
 	// 连接集群的密钥
-	Key string `form:"key" json:"key" yaml:"key" xml:"key" db:"key"`
+	Key string `form:"key" json:"key" yaml:"key" xml:"key" db:"key" secret:"true"`
 	// 连接集群类型
 	ConnectType string `form:"connectType" yaml:"connectType" xml:"connectType" db:"connectType"`
 	// 连接集群所使用的token
-	Token string `form:"token" yaml:"token" xml:"token" db:"token"`
+	Token string `form:"token" yaml:"token" xml:"token" db:"token" secret:"true"`
 	// 连接集群所使用的kubeconfig
-	KubeConfig string `form:"kubeConfig" yaml:"kubeConfig" xml:"kubeConfig" db:"kubeConfig"`
+	KubeConfig string `form:"kubeConfig" yaml:"kubeConfig" xml:"kubeConfig" db:"kubeConfig" secret:"true"`
 	// 集群的kubernetes版本
 	Version string `form:"version" json:"version" yaml:"version" xml:"version" db:"version"`
 	// 集群的cri
@@ -77,7 +72,9 @@ type K8sclusterSchema struct {
 	// 状态0未启用 1已启用 2 已停用
 	Status int `form:"status" json:"status" yaml:"status" xml:"status" db:"status"`
 	// 是否删除 0正常 1已删除
-	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted"`
+	IsDeleted int `form:"isDeleted" json:"isDeleted" yaml:"isDeleted" xml:"isDeleted" db:"isDeleted" softdelete:"flag"`
+	// 删除时间截
+	DeletedTime int `form:"deletedTime" json:"deletedTime" yaml:"deletedTime" xml:"deletedTime" db:"deletedTime" softdelete:"time"`
 	// 创建时间
 	CreateTime string `form:"createTime" json:"createTime" yaml:"createTime" xml:"createTime" db:"createTime"`
 	// 更新时间
@@ -25,3 +25,30 @@ const (
 	DefaultObjectTable       string = "objecttable"
 	DefaultObjectTablePkName string = "id"
 )
+
+const (
+	// DeletedStateActive selects the objects which have not been deleted
+	DeletedStateActive = "active"
+	// DeletedStateDeleted selects the objects which have been deleted softly
+	DeletedStateDeleted = "deleted"
+	// DeletedStateAll selects all objects
+	DeletedStateAll = "all"
+
+	softDeleteTag        = "softdelete"
+	softDeleteFlag       = "flag"
+	softDeleteTime       = "time"
+	softDeleteTimeFormat = "2006-01-02 15:04:05"
+
+	referencesTag = "references"
+
+	// MasterKeyEnv is the environment variable which holds the master keys
+	MasterKeyEnv = "SYSADM_MASTER_KEY"
+	// MasterKeyFileEnv is the environment variable which holds the path of the master key file
+	MasterKeyFileEnv = "SYSADM_MASTER_KEY_FILE"
+	// DefaultMasterKeyFile is the path of the master key file relative to the working root
+	DefaultMasterKeyFile = "conf/master.key"
+
+	secretTag           = "secret"
+	secretPrefix        = "enc:v1:"
+	secretMasterKeySize = 32
+)
@@ -64,6 +64,11 @@ func GetWorkingRoot() string {
 // success: return db.FieldData what can be Unmarshal and nil
 // error: return nil and error
 func GetObjectInfoByID(tableName, pkName, id string) (db.FieldData, error) {
+	return getObjectInfoByID(tableName, pkName, id, false)
+}
+
+// getObjectInfoByID get object information from DB by its id. the query will be sent to the primary server if primary is true
+func getObjectInfoByID(tableName, pkName, id string, primary bool) (db.FieldData, error) {
 	tableName = strings.TrimSpace(tableName)
 	pkName = strings.TrimSpace(pkName)
 	id = strings.TrimSpace(id)
@@ -77,6 +82,7 @@ func GetObjectInfoByID(tableName, pkName, id string) (db.FieldData, error) {
 		Tb:        []string{tableName},
 		OutFeilds: []string{"*"},
 		Where:     whereMap,
+		Primary:   primary,
 	}
 
 	dbEntity := runData.dbConf.Entity
@@ -93,6 +99,11 @@ func GetObjectInfoByID(tableName, pkName, id string) (db.FieldData, error) {
 // success: return count of object and nil
 // error: return -1 and an error
 func GetObjectCount(tableName, pkName, searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
+	return getObjectCount(tableName, pkName, searchContent, ids, searchKeys, conditions, false)
+}
+
+// getObjectCount get object count from db accroding to conditions. the query will be sent to the primary server if primary is true
+func getObjectCount(tableName, pkName, searchContent string, ids, searchKeys []string, conditions map[string]string, primary bool) (int, error) {
 	tableName = strings.TrimSpace(tableName)
 	pkName = strings.TrimSpace(pkName)
 	searchContent = strings.TrimSpace(searchContent)
@@ -135,6 +146,7 @@ func GetObjectCount(tableName, pkName, searchContent string, ids, searchKeys []s
 		Tb:        []string{tableName},
 		OutFeilds: []string{outSql},
 		Where:     newConditions,
+		Primary:   primary,
 	}
 	dbEntity := runData.dbConf.Entity
 	dbData, e := dbEntity.NewQueryData(&selectData)
@@ -162,6 +174,12 @@ func GetObjectCount(tableName, pkName, searchContent string, ids, searchKeys []s
 // for where.
 func GetObjectList(tableName, pkName, searchContent string, ids, searchKeys []string, conditions map[string]string,
 	startPos, step int, orders map[string]string) ([]map[string]interface{}, error) {
+	return getObjectList(tableName, pkName, searchContent, ids, searchKeys, conditions, startPos, step, orders, false)
+}
+
+// getObjectList get object list from DB. the query will be sent to the primary server if primary is true
+func getObjectList(tableName, pkName, searchContent string, ids, searchKeys []string, conditions map[string]string,
+	startPos, step int, orders map[string]string, primary bool) ([]map[string]interface{}, error) {
 	var ret []map[string]interface{}
 
 	tableName = strings.TrimSpace(tableName)
@@ -225,6 +243,7 @@ func GetObjectList(tableName, pkName, searchContent string, ids, searchKeys []st
 		Where:     newConditions,
 		Limit:     sqlLimit,
 		Order:     order,
+		Primary:   primary,
 	}
 
 	dbEntity := runData.dbConf.Entity
@@ -346,6 +365,13 @@ func Unmarshal(data map[string]interface{}, dst any) error {
 			dV.Field(i).SetFloat(value)
 		case reflect.String:
 			value := utils.Interface2String(v)
+			if isSecretField(field) {
+				plain, e := DecryptSecret(value)
+				if e != nil {
+					return fmt.Errorf("can not umarshal feild %s for %s", tag, e)
+				}
+				value = plain
+			}
 			dV.Field(i).SetString(value)
 		default:
 			continue
@@ -368,6 +394,8 @@ func Marshal(s any) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("we can only marshal struct to map")
 	}
 
+	// the secret fields of a record are encrypted with the same data key
+	var secretKey *dataKey = nil
 	data := make(map[string]interface{}, 0)
 	for i := 0; i < sT.NumField(); i++ {
 		field := sT.Field(i)
@@ -397,6 +425,24 @@ func Marshal(s any) (map[string]interface{}, error) {
 			if strings.TrimSpace(value.(string)) == "" {
 				continue
 			}
+			// the value is stored as it is if the master keys have not been loaded
+			if isSecretField(field) && SecretEnabled() {
+				if secretKey == nil {
+					k, e := newDataKey()
+					if e != nil {
+						return nil, fmt.Errorf("can not marshal field %s for %s", tag, e)
+					}
+					if k == nil {
+						return nil, fmt.Errorf("can not marshal field %s for master keys have not been loaded", tag)
+					}
+					secretKey = k
+				}
+				encrypted, e := secretKey.encrypt(value.(string))
+				if e != nil {
+					return nil, fmt.Errorf("can not marshal field %s for %s", tag, e)
+				}
+				value = encrypted
+			}
 		default:
 			continue
 		}
@@ -428,7 +474,10 @@ func AddObject(tableName, pkName string, data map[string]interface{}) error {
 	return dbEntity.NewInsertData(tableName, dbData)
 }
 
-func GetObjectMaxID(dbEntity sysadmDB.DbEntity, tableName, idField string) (uint, error) {
+// NextObjectID allocates a new ID for the object which data are stored in table tableName with primary key idField.
+// IDs are allocated from the sequences in ids table, so concurrent callers never get the same ID.
+// return the ID and nil if successful, otherwise return 0 and an error
+func NextObjectID(dbEntity sysadmDB.DbEntity, tableName, idField string) (uint, error) {
 	tableName = strings.TrimSpace(tableName)
 	idField = strings.TrimSpace(idField)
 
@@ -444,23 +493,7 @@ func GetObjectMaxID(dbEntity sysadmDB.DbEntity, tableName, idField string) (uint
 		return 0, fmt.Errorf("DB Entity is nil")
 	}
 
-	sql := "max(" + idField + ") as id"
-	selectData := db.SelectData{
-		Tb:        []string{tableName},
-		OutFeilds: []string{sql},
-	}
-	dbData, e := dbEntity.NewQueryData(&selectData)
-	if e != nil || len(dbData) < 1 {
-		return 0, fmt.Errorf("there is an error occurred when building SQL statement")
-	}
-
-	row := dbData[0]
-	numTmp, ok := row["id"]
-	if !ok {
-		return 0, fmt.Errorf("there is an error occurred when getting object ID")
-	}
-
-	id, e := utils.Interface2Uint64(numTmp)
+	id, e := sysadmDB.NextID(dbEntity, tableName, idField)
 	if e != nil {
 		return 0, e
 	}
@@ -468,41 +501,6 @@ func GetObjectMaxID(dbEntity sysadmDB.DbEntity, tableName, idField string) (uint
 	return uint(id), nil
 }
 
-func prepareUpdateObjNextIDData(tbName, idField string, dbEntity sysadmDB.DbEntity) (sysadmDB.FieldData, map[string]string, error) {
-	updateData := make(sysadmDB.FieldData, 0)
-	where := make(map[string]string, 0)
-
-	tbName = strings.TrimSpace(tbName)
-	idField = strings.TrimSpace(idField)
-	if tbName == "" || idField == "" {
-		return updateData, where, fmt.Errorf("table name or field name of id is empty")
-	}
-
-	if dbEntity == nil {
-		dbEntity = runData.dbConf.Entity
-	}
-
-	if dbEntity == nil {
-		return updateData, where, fmt.Errorf("DB Entity is nil")
-	}
-
-	updateData["nextValue"] = "nextValue + 1"
-
-	where["tableName"] = tbName
-	where["fieldName"] = idField
-
-	return updateData, where, nil
-}
-
-func UpdateObjectNextID(dbEntity sysadmDB.DbEntity, tbName, idField string) error {
-	fieldData, where, e := prepareUpdateObjNextIDData(tbName, idField, dbEntity)
-	if e != nil {
-		return e
-	}
-
-	return dbEntity.NewUpdateData(tbName, fieldData, where)
-}
-
 func GetCommandRelatedObjectList() ([]interface{}, error) {
 	var ret []interface{}
 
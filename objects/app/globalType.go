@@ -18,7 +18,9 @@
 package app
 
 import (
+	"reflect"
 	sysadmDB "sysadm/db"
+	"time"
 )
 
 type runningData struct {
@@ -36,7 +38,88 @@ type ObjectEntity interface {
 	GetObjectIDFieldName() (string, string, error)
 }
 
+// Repository is a typed entity of the objects of type T which data are stored in table TableName.
+// Repository implements ObjectEntity, so an object module can embed a Repository of its schema
+// instead of implementing ObjectEntity itself.
+type Repository[T any] struct {
+	// object name. such as "datacenter"
+	Name string
+	// table name which hold object data in DB
+	TableName string
+	// field name of primary key in the table
+	PkName string
+	// Primary force the queries of the repository to be sent to the primary DB server
+	Primary bool
+}
+
 type ObjectTx struct {
 	Tx     *sysadmDB.Tx
 	Entity ObjectEntity
 }
+
+// SoftDeleter is implemented by the entities of the objects which can be deleted softly and be restored.
+// SoftDelete refuses to delete the objects which are referenced by other objects, SoftDeleteCascade deletes the
+// objects referencing them too.
+type SoftDeleter interface {
+	SoftDelete(ids []string) error
+	SoftDeleteCascade(ids []string) error
+	Restore(ids []string) error
+}
+
+// Referrer is implemented by the entities of the objects which reference other objects.
+type Referrer interface {
+	GetName() string
+	// ReferenceFields return the fields which reference other objects. the keys are field names in DB and the values
+	// are the names of the objects referenced by the fields
+	ReferenceFields() map[string]string
+	// ReferringIDs return the IDs of the objects which have not been deleted and which value of field in ids
+	ReferringIDs(field string, ids []string) ([]string, error)
+	// MarkDeletedTx mark the objects which primary key in ids as deleted in transaction tx without checking references.
+	// it is called by the cascading deletes after the objects referencing them have been deleted
+	MarkDeletedTx(tx ObjectTx, ids []string) error
+}
+
+// BlockingReference is the objects of ObjectName which reference other objects through field Field
+type BlockingReference struct {
+	ObjectName string
+	Field      string
+	IDs        []string
+}
+
+// ReferencedError is returned when the objects of ObjectName can not be deleted because they are referenced by others
+type ReferencedError struct {
+	ObjectName string
+	References []BlockingReference
+}
+
+// Purger is implemented by the entities of the objects which deleted data can be purged
+type Purger interface {
+	GetName() string
+	Purge(before time.Time) (int, error)
+}
+
+// SecretRotator is implemented by the entities of the objects which have fields encrypted with the master key
+type SecretRotator interface {
+	GetName() string
+	// RotateSecrets re-encrypt the secret fields of all objects with the current master key.
+	// return the number of objects which have been re-encrypted
+	RotateSecrets() (int, error)
+}
+
+// masterKeyRing holds the master keys. the data keys of new secrets are wrapped by the key named current,
+// the other keys are kept for decrypting the secrets which were encrypted before rotating
+type masterKeyRing struct {
+	current string
+	keys    map[string][]byte
+}
+
+// RetentionFunc return how long the deleted objects of objectName should be kept before they are purged.
+// the deleted objects will be kept forever if the duration is 0
+type RetentionFunc func(objectName string) (time.Duration, error)
+
+// softDeleteInfo holds the fields of a schema which are tagged with softdelete tag
+type softDeleteInfo struct {
+	flagField string
+	timeField string
+	timeKind  reflect.Kind
+}
@@ -17,28 +17,24 @@
 
 package app
 
+// Project is the entity of project object
 type Project struct {
-	// object name. the value should be "project"
-	Name string
-	// table name which hold project data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	Repository[ProjectSchema]
 }
 
 type ProjectSchema struct {
 	//projectid identified a project
-	ProjectID int `form:"id" json:"id" yaml:"id" xml:"id"`
+	ProjectID int `form:"id" json:"id" yaml:"id" xml:"id" db:"projectid"`
 	// the owner of the project. owner is the user who created the project normally
-	UserID int `form:"userid" json:"userid" yaml:"userid" xml:"userid"`
+	UserID int `form:"userid" json:"userid" yaml:"userid" xml:"userid" db:"ownerid"`
 	// project name. it must be a string in english. Is is a part of url of image.
-	Name string `form:"name" json:"name" yaml:"name" xml:"name"`
+	Name string `form:"name" json:"name" yaml:"name" xml:"name" db:"name"`
 	// description of a project
-	Comment string `form:"comment" json:"comment" yaml:"comment" xml:"comment"`
+	Comment string `form:"comment" json:"comment" yaml:"comment" xml:"comment" db:"comment"`
 	// the value is true if a user has be deleted
-	Deleted int `form:"deleted" json:"deleted" yaml:"deleted" xml:"deleted"`
+	Deleted int `form:"deleted" json:"deleted" yaml:"deleted" xml:"deleted" db:"deleted" softdelete:"flag"`
 	// the time when the project has be create
-	Creation_time int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time"`
+	Creation_time int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time" db:"creation_time"`
 	// the time when the project has be update
-	Update_time int `form:"update_time" json:"update_time" yaml:"update_time" xml:"update_time"`
+	Update_time int `form:"update_time" json:"update_time" yaml:"update_time" xml:"update_time" db:"update_time"`
 }
@@ -0,0 +1,275 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sysadm/utils"
+)
+
+/*
+reference contract of objects:
+a field of a schema which holds the ID of another object should be tagged with `references:"<object name>"`, such as
+`references:"datacenter"`. the entity of the object should be registered by RegisterReferrer, then the objects
+referenced by it can not be deleted until it has been deleted, or it will be deleted with them if the deletion is
+cascaded.
+*/
+
+// referrers are the entities of the objects which reference other objects
+var referrers = make(map[string]Referrer, 0)
+
+// referrerLock protects referrers
+var referrerLock sync.Mutex
+
+// Error implements error interface
+func (e *ReferencedError) Error() string {
+	var refs []string
+	for _, ref := range e.References {
+		refs = append(refs, fmt.Sprintf("%s(%s) %s", ref.ObjectName, ref.Field, strings.Join(ref.IDs, ",")))
+	}
+
+	return fmt.Sprintf("%s is referenced by %s", e.ObjectName, strings.Join(refs, "; "))
+}
+
+// IsReferencedError return the ReferencedError in e and true if e is or wraps a ReferencedError
+func IsReferencedError(e error) (*ReferencedError, bool) {
+	var refErr *ReferencedError
+	if errors.As(e, &refErr) {
+		return refErr, true
+	}
+
+	return nil, false
+}
+
+// RegisterReferrer register r to the dependency graph of objects. the referrer registered before with the same name
+// will be replaced
+func RegisterReferrer(r Referrer) {
+	if r == nil || strings.TrimSpace(r.GetName()) == "" || len(r.ReferenceFields()) < 1 {
+		return
+	}
+
+	referrerLock.Lock()
+	defer referrerLock.Unlock()
+	referrers[r.GetName()] = r
+}
+
+// FindReferences return the objects which have not been deleted and which reference the objects of objectName whose
+// IDs are in ids
+func FindReferences(objectName string, ids []string) ([]BlockingReference, error) {
+	var ret []BlockingReference
+	if len(ids) < 1 {
+		return ret, nil
+	}
+
+	referrerLock.Lock()
+	var names []string
+	for name := range referrers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var list []Referrer
+	for _, name := range names {
+		list = append(list, referrers[name])
+	}
+	referrerLock.Unlock()
+
+	for _, r := range list {
+		fields := r.ReferenceFields()
+		var fieldNames []string
+		for field, target := range fields {
+			if target == objectName {
+				fieldNames = append(fieldNames, field)
+			}
+		}
+		sort.Strings(fieldNames)
+
+		for _, field := range fieldNames {
+			referringIDs, e := r.ReferringIDs(field, ids)
+			if e != nil {
+				return ret, e
+			}
+			if len(referringIDs) > 0 {
+				ret = append(ret, BlockingReference{ObjectName: r.GetName(), Field: field, IDs: referringIDs})
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// CheckReferences return a ReferencedError if the objects of objectName whose IDs are in ids are referenced by other
+// objects which have not been deleted
+func CheckReferences(objectName string, ids []string) error {
+	refs, e := FindReferences(objectName, ids)
+	if e != nil {
+		return e
+	}
+
+	if len(refs) > 0 {
+		return &ReferencedError{ObjectName: objectName, References: refs}
+	}
+
+	return nil
+}
+
+// DeleteReferences delete the objects which reference the objects of objectName whose IDs are in ids softly in
+// transaction tx. the objects referencing them will be deleted recursively
+func DeleteReferences(tx ObjectTx, objectName string, ids []string) error {
+	if tx.Tx == nil {
+		return fmt.Errorf("transaction has not began")
+	}
+
+	visited := make(map[string]bool, 0)
+	for _, id := range ids {
+		visited[objectName+":"+strings.TrimSpace(id)] = true
+	}
+
+	return deleteReferences(tx, objectName, ids, visited)
+}
+
+// deleteReferences delete the objects referencing the objects of objectName whose IDs are in ids recursively.
+// visited holds the objects which have been deleted in this cascade, they are skipped to break reference cycles
+func deleteReferences(tx ObjectTx, objectName string, ids []string, visited map[string]bool) error {
+	refs, e := FindReferences(objectName, ids)
+	if e != nil {
+		return e
+	}
+
+	for _, ref := range refs {
+		referrerLock.Lock()
+		r, ok := referrers[ref.ObjectName]
+		referrerLock.Unlock()
+		if !ok {
+			continue
+		}
+
+		var newIDs []string
+		for _, id := range ref.IDs {
+			key := ref.ObjectName + ":" + id
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			newIDs = append(newIDs, id)
+		}
+		if len(newIDs) < 1 {
+			continue
+		}
+
+		if e := deleteReferences(tx, ref.ObjectName, newIDs, visited); e != nil {
+			return e
+		}
+
+		if e := r.MarkDeletedTx(tx, newIDs); e != nil {
+			return fmt.Errorf("delete %s referencing %s error: %s", ref.ObjectName, objectName, e)
+		}
+	}
+
+	return nil
+}
+
+// ReferenceFields implements Referrer interface. return the fields of T which are tagged with references tag
+func (r Repository[T]) ReferenceFields() map[string]string {
+	ret := make(map[string]string, 0)
+	var schema T
+	sT := reflect.TypeOf(schema)
+	if sT == nil || sT.Kind() != reflect.Struct {
+		return ret
+	}
+
+	for i := 0; i < sT.NumField(); i++ {
+		field := sT.Field(i)
+		dbTag, okDb := field.Tag.Lookup("db")
+		target, ok := field.Tag.Lookup(referencesTag)
+		if !field.IsExported() || !okDb || dbTag == "" || !ok || strings.TrimSpace(target) == "" {
+			continue
+		}
+		ret[dbTag] = strings.TrimSpace(target)
+	}
+
+	return ret
+}
+
+// ReferringIDs implements Referrer interface. the objects which have been deleted softly are not included
+func (r Repository[T]) ReferringIDs(field string, ids []string) ([]string, error) {
+	var ret []string
+	if len(ids) < 1 {
+		return ret, nil
+	}
+
+	var quotedIDs []string
+	for _, id := range ids {
+		quotedIDs = append(quotedIDs, QuoteString(strings.TrimSpace(id)))
+	}
+
+	conditions := make(map[string]string, 0)
+	conditions[field] = " in (" + strings.Join(quotedIDs, ",") + ")"
+	if info, e := r.softDeleteInfo(); e == nil {
+		conditions[info.flagField] = "=0"
+	}
+
+	// the references must be checked against the primary server, otherwise the objects just created may be missed
+	dbData, e := getObjectList(r.TableName, r.PkName, "", nil, nil, conditions, 0, 0, nil, true)
+	if e != nil {
+		return ret, e
+	}
+
+	for _, row := range dbData {
+		id := utils.Interface2String(row[r.PkName])
+		if id != "" {
+			ret = append(ret, id)
+		}
+	}
+
+	return ret, nil
+}
+
+// SoftDeleteCascade delete the objects which primary key in ids and the objects referencing them softly.
+// all the objects are deleted in one transaction
+func (r Repository[T]) SoftDeleteCascade(ids []string) error {
+	tx, e := BeginTx(runData.dbConf.Entity, r)
+	if e != nil {
+		return e
+	}
+
+	if e := DeleteReferences(tx, r.Name, ids); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	if e := r.setDeleted(tx, ids, true); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// MarkDeletedTx implements Referrer interface
+func (r Repository[T]) MarkDeletedTx(tx ObjectTx, ids []string) error {
+	if tx.Tx == nil {
+		return fmt.Errorf("transaction has not began")
+	}
+
+	return r.setDeleted(tx, ids, true)
+}
@@ -0,0 +1,148 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"testing"
+)
+
+type referenceParentSchema struct {
+	Id      uint `db:"id"`
+	Deleted int  `db:"deleted" softdelete:"flag"`
+}
+
+type referenceChildSchema struct {
+	Id       uint   `db:"id"`
+	ParentID string `db:"parentid" references:"refparent"`
+	Deleted  int    `db:"deleted" softdelete:"flag"`
+}
+
+type referenceCycleASchema struct {
+	Id      uint   `db:"id"`
+	BID     string `db:"bid" references:"refcycleb"`
+	Deleted int    `db:"deleted" softdelete:"flag"`
+}
+
+type referenceCycleBSchema struct {
+	Id      uint   `db:"id"`
+	AID     string `db:"aid" references:"refcyclea"`
+	Deleted int    `db:"deleted" softdelete:"flag"`
+}
+
+// setTestReferrers replace the registered referrers with rs, and restore them after the test
+func setTestReferrers(t *testing.T, rs ...Referrer) {
+	t.Helper()
+	referrerLock.Lock()
+	old := referrers
+	referrers = make(map[string]Referrer, 0)
+	referrerLock.Unlock()
+	t.Cleanup(func() {
+		referrerLock.Lock()
+		referrers = old
+		referrerLock.Unlock()
+	})
+
+	for _, r := range rs {
+		RegisterReferrer(r)
+	}
+}
+
+func TestCheckReferences(t *testing.T) {
+	db := newFakeDB(t)
+	parent := NewRepository[referenceParentSchema]("refparent", "refparent", "id")
+	child := NewRepository[referenceChildSchema]("refchild", "refchild", "id")
+	setTestReferrers(t, parent, child)
+	db.insert("refparent", map[string]interface{}{"id": 1, "deleted": 0}, map[string]interface{}{"id": 2, "deleted": 0})
+	db.insert("refchild",
+		map[string]interface{}{"id": 10, "parentid": "1", "deleted": 0},
+		map[string]interface{}{"id": 11, "parentid": "2", "deleted": 1},
+	)
+
+	if fields := child.ReferenceFields(); len(fields) != 1 || fields["parentid"] != "refparent" {
+		t.Errorf("reference fields of child = %v", fields)
+	}
+
+	e := CheckReferences("refparent", []string{"1"})
+	refErr, ok := IsReferencedError(e)
+	if !ok || len(refErr.References) != 1 || refErr.References[0].ObjectName != "refchild" ||
+		len(refErr.References[0].IDs) != 1 || refErr.References[0].IDs[0] != "10" {
+		t.Errorf("CheckReferences(1) = %v, want referenced by child 10", e)
+	}
+
+	// deleted children do not block the deletion
+	if e := CheckReferences("refparent", []string{"2"}); e != nil {
+		t.Errorf("CheckReferences(2) = %v, want nil", e)
+	}
+
+	if e := parent.SoftDelete([]string{"1"}); e == nil {
+		t.Errorf("the parent referenced by a child should not be deleted")
+	}
+	if deleted := db.row("refparent", "id", "1")["deleted"]; deleted != 0 {
+		t.Errorf("deleted flag of the parent = %v, want 0", deleted)
+	}
+}
+
+func TestSoftDeleteCascade(t *testing.T) {
+	db := newFakeDB(t)
+	parent := NewRepository[referenceParentSchema]("refparent", "refparent", "id")
+	child := NewRepository[referenceChildSchema]("refchild", "refchild", "id")
+	setTestReferrers(t, parent, child)
+	db.insert("refparent", map[string]interface{}{"id": 1, "deleted": 0})
+	db.insert("refchild",
+		map[string]interface{}{"id": 10, "parentid": "1", "deleted": 0},
+		map[string]interface{}{"id": 11, "parentid": "1", "deleted": 0},
+		map[string]interface{}{"id": 12, "parentid": "2", "deleted": 0},
+	)
+
+	if e := parent.SoftDeleteCascade([]string{"1"}); e != nil {
+		t.Fatalf("cascading delete error: %s", e)
+	}
+
+	for tb, ids := range map[string]map[string]string{"refparent": {"1": "1"}, "refchild": {"10": "1", "11": "1"}} {
+		for id, want := range ids {
+			if got := db.row(tb, "id", id)["deleted"]; got != want {
+				t.Errorf("deleted flag of %s %s = %v, want %s", tb, id, got, want)
+			}
+		}
+	}
+	if got := db.row("refchild", "id", "12")["deleted"]; got != 0 {
+		t.Errorf("the child of another parent should not be deleted")
+	}
+}
+
+func TestSoftDeleteCascadeCycle(t *testing.T) {
+	db := newFakeDB(t)
+	a := NewRepository[referenceCycleASchema]("refcyclea", "refcyclea", "id")
+	b := NewRepository[referenceCycleBSchema]("refcycleb", "refcycleb", "id")
+	setTestReferrers(t, a, b)
+	db.insert("refcyclea", map[string]interface{}{"id": 1, "bid": "1", "deleted": 0})
+	db.insert("refcycleb", map[string]interface{}{"id": 1, "aid": "1", "deleted": 0})
+
+	if e := a.SoftDeleteCascade([]string{"1"}); e != nil {
+		t.Fatalf("cascading delete error: %s", e)
+	}
+
+	for _, tb := range []string{"refcyclea", "refcycleb"} {
+		if got := db.row(tb, "id", "1")["deleted"]; got != "1" {
+			t.Errorf("deleted flag of %s 1 = %v, want 1", tb, got)
+		}
+	}
+	if db.updates != 2 {
+		t.Errorf("%d rows are updated, each object in the cycle should be deleted once", db.updates)
+	}
+}
@@ -0,0 +1,301 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"strings"
+	sysadmDB "sysadm/db"
+	"sysadm/utils"
+)
+
+// NewRepository create a repository for the objects of type T which are stored in table tableName with primary key pkName.
+func NewRepository[T any](name, tableName, pkName string) Repository[T] {
+	return Repository[T]{Name: name, TableName: tableName, PkName: pkName}
+}
+
+// OnPrimary return a copy of the repository whose queries are sent to the primary DB server.
+// it should be used when reading the data just written or the data which will be used to make security decisions
+func (r Repository[T]) OnPrimary() Repository[T] {
+	r.Primary = true
+
+	return r
+}
+
+// Get get the object which primary key is id
+func (r Repository[T]) Get(id string) (T, error) {
+	return r.GetByField(r.PkName, id)
+}
+
+// GetByField get the first object which the value of field is value
+func (r Repository[T]) GetByField(field, value string) (T, error) {
+	var ret T
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ret, fmt.Errorf("can not get %s information with empty %s", r.Name, field)
+	}
+
+	dbData, e := getObjectInfoByID(r.TableName, field, value, r.Primary)
+	if e != nil {
+		return ret, e
+	}
+
+	e = Unmarshal(dbData, &ret)
+
+	return ret, e
+}
+
+// Count get the number of objects which match the conditions
+// the keys of conditions and searchKeys must be the db tags of T.
+func (r Repository[T]) Count(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
+	if e := r.validKeys(searchKeys, conditions, nil); e != nil {
+		return -1, e
+	}
+
+	return getObjectCount(r.TableName, r.PkName, strings.TrimSpace(searchContent), ids, searchKeys, conditions, r.Primary)
+}
+
+// List get objects which match the conditions. step objects will be got from startPos if step is large than 0.
+// the keys of conditions, searchKeys and orders must be the db tags of T.
+func (r Repository[T]) List(searchContent string, ids, searchKeys []string, conditions map[string]string,
+	startPos, step int, orders map[string]string) ([]T, error) {
+	var ret []T
+	if e := r.validKeys(searchKeys, conditions, orders); e != nil {
+		return ret, e
+	}
+
+	dbData, e := getObjectList(r.TableName, r.PkName, strings.TrimSpace(searchContent), ids, searchKeys, conditions, startPos, step, orders, r.Primary)
+	if e != nil {
+		return ret, e
+	}
+
+	for _, v := range dbData {
+		var item T
+		if e := Unmarshal(v, &item); e != nil {
+			return ret, e
+		}
+		ret = append(ret, item)
+	}
+
+	return ret, nil
+}
+
+// Create insert data into the table of the repository
+func (r Repository[T]) Create(data T) error {
+	insertData, e := Marshal(data)
+	if e != nil {
+		return e
+	}
+
+	return AddObject(r.TableName, r.PkName, insertData)
+}
+
+// Update update the object which primary key is id with the fields of data.
+// the fields with empty string value will not be updated.
+func (r Repository[T]) Update(id string, data T) error {
+	updateData, where, e := r.prepareUpdate(id, data)
+	if e != nil {
+		return e
+	}
+
+	return runData.dbConf.Entity.NewUpdateData(r.TableName, updateData, where)
+}
+
+// SoftDelete mark the objects which primary key in ids as deleted.
+// a ReferencedError will be returned if the objects are referenced by other objects
+func (r Repository[T]) SoftDelete(ids []string) error {
+	if e := CheckReferences(r.Name, ids); e != nil {
+		return e
+	}
+
+	return r.setDeleted(ObjectTx{}, ids, true)
+}
+
+// CreateTx insert data into the table of the repository in transaction tx
+func (r Repository[T]) CreateTx(tx ObjectTx, data T) error {
+	if tx.Tx == nil {
+		return fmt.Errorf("transaction has not began")
+	}
+
+	insertData, e := Marshal(data)
+	if e != nil {
+		return e
+	}
+
+	return tx.Tx.NewInsertData(r.TableName, sysadmDB.FieldData(insertData))
+}
+
+// UpdateTx update the object which primary key is id with the fields of data in transaction tx
+func (r Repository[T]) UpdateTx(tx ObjectTx, id string, data T) error {
+	if tx.Tx == nil {
+		return fmt.Errorf("transaction has not began")
+	}
+
+	updateData, where, e := r.prepareUpdate(id, data)
+	if e != nil {
+		return e
+	}
+
+	return tx.Tx.NewUpdateData(r.TableName, updateData, where)
+}
+
+// SoftDeleteTx mark the objects which primary key in ids as deleted in transaction tx
+func (r Repository[T]) SoftDeleteTx(tx ObjectTx, ids []string) error {
+	if tx.Tx == nil {
+		return fmt.Errorf("transaction has not began")
+	}
+
+	if e := CheckReferences(r.Name, ids); e != nil {
+		return e
+	}
+
+	return r.setDeleted(tx, ids, true)
+}
+
+// GetObjectInfoByID implements ObjectEntity interface
+func (r Repository[T]) GetObjectInfoByID(id string) (interface{}, error) {
+	return r.Get(id)
+}
+
+// GetObjectCount implements ObjectEntity interface
+func (r Repository[T]) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
+	return r.Count(searchContent, ids, searchKeys, conditions)
+}
+
+// GetObjectList implements ObjectEntity interface. the items of the list are values of T
+func (r Repository[T]) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
+	startPos, step int, orders map[string]string) ([]interface{}, error) {
+	var ret []interface{}
+	list, e := r.List(searchContent, ids, searchKeys, conditions, startPos, step, orders)
+	if e != nil {
+		return ret, e
+	}
+
+	for _, item := range list {
+		ret = append(ret, item)
+	}
+
+	return ret, nil
+}
+
+// AddObject implements ObjectEntity interface. data must be T or *T
+func (r Repository[T]) AddObject(data interface{}) error {
+	schemaData, e := r.toSchema(data)
+	if e != nil {
+		return e
+	}
+
+	return r.Create(schemaData)
+}
+
+// AddObjectByTx implements ObjectEntity interface. data must be T or *T
+func (r Repository[T]) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
+	addData := make(map[string]interface{}, 0)
+	schemaData, e := r.toSchema(data)
+	if e != nil {
+		return addData, "", e
+	}
+
+	addData, e = Marshal(schemaData)
+	if e != nil {
+		return addData, "", e
+	}
+
+	return addData, r.TableName, nil
+}
+
+// GetObjectIDFieldName implements ObjectEntity interface
+func (r Repository[T]) GetObjectIDFieldName() (string, string, error) {
+	return r.TableName, r.PkName, nil
+}
+
+func (r Repository[T]) toSchema(data interface{}) (T, error) {
+	switch v := data.(type) {
+	case T:
+		return v, nil
+	case *T:
+		if v != nil {
+			return *v, nil
+		}
+	}
+
+	var ret T
+	return ret, fmt.Errorf("the data of %s is not valid", r.Name)
+}
+
+func (r Repository[T]) validKeys(searchKeys []string, conditions, orders map[string]string) error {
+	var schema T
+	if ok, e := ValidKeysInSchema(searchKeys, &schema); !ok {
+		return fmt.Errorf("search key are not valid. error %s", e)
+	}
+
+	var conditionKeys []string
+	for k := range conditions {
+		conditionKeys = append(conditionKeys, k)
+	}
+	if ok, e := ValidKeysInSchema(conditionKeys, &schema); !ok {
+		return fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
+	}
+
+	var orderKeys []string
+	for k := range orders {
+		orderKeys = append(orderKeys, k)
+	}
+	if ok, e := ValidKeysInSchema(orderKeys, &schema); !ok {
+		return fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
+	}
+
+	return nil
+}
+
+func (r Repository[T]) prepareUpdate(id string, data T) (sysadmDB.FieldData, map[string]string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, nil, fmt.Errorf("can not update %s with empty ID", r.Name)
+	}
+
+	dbData, e := Marshal(data)
+	if e != nil {
+		return nil, nil, e
+	}
+	// primary key should not be changed
+	delete(dbData, r.PkName)
+
+	where := map[string]string{r.PkName: id}
+
+	return quoteFieldData(dbData), where, nil
+}
+
+// quoteFieldData quote the string values of data, so they can be used in update statement.
+func quoteFieldData(data map[string]interface{}) sysadmDB.FieldData {
+	ret := make(sysadmDB.FieldData, 0)
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			ret[k] = QuoteString(s)
+			continue
+		}
+		ret[k] = utils.Interface2String(v)
+	}
+
+	return ret
+}
+
+// QuoteString quote s as a string value in SQL statements, such as the values of update data
+func QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, "\\", "\\\\"), "'", "\\'") + "'"
+}
@@ -0,0 +1,116 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"testing"
+)
+
+type repositoryTestSchema struct {
+	Id      uint   `db:"id"`
+	Name    string `db:"name"`
+	Comment string `db:"comment"`
+	Deleted int    `db:"deleted" softdelete:"flag"`
+}
+
+func newTestRepository(t *testing.T) (Repository[repositoryTestSchema], *fakeEntity) {
+	db := newFakeDB(t)
+	db.insert("repotest",
+		map[string]interface{}{"id": 1, "name": "a", "comment": "first", "deleted": 0},
+		map[string]interface{}{"id": 2, "name": "b", "comment": "second", "deleted": 0},
+		map[string]interface{}{"id": 3, "name": "c", "comment": "third", "deleted": 1},
+	)
+
+	return NewRepository[repositoryTestSchema]("repotest", "repotest", "id"), db
+}
+
+func TestRepositoryGetListCount(t *testing.T) {
+	r, _ := newTestRepository(t)
+
+	got, e := r.Get("2")
+	if e != nil || got.Name != "b" || got.Comment != "second" {
+		t.Errorf("Get(2) = %+v, %v", got, e)
+	}
+	if _, e := r.Get(""); e == nil {
+		t.Errorf("Get with empty ID should fail")
+	}
+	if _, e := r.Get("9"); e == nil {
+		t.Errorf("Get of an object which does not exist should fail")
+	}
+
+	conditions, e := r.WithDeletedState(nil, DeletedStateActive)
+	if e != nil {
+		t.Fatalf("WithDeletedState error: %s", e)
+	}
+	list, e := r.List("", nil, nil, conditions, 0, 0, map[string]string{"id": "1"})
+	if e != nil || len(list) != 2 || list[0].Id != 2 || list[1].Id != 1 {
+		t.Errorf("List of active objects = %+v, %v", list, e)
+	}
+
+	num, e := r.Count("", nil, nil, nil)
+	if e != nil || num != 3 {
+		t.Errorf("Count = %d, %v, want 3", num, e)
+	}
+
+	if _, e := r.List("", nil, nil, map[string]string{"unknown": "=1"}, 0, 0, nil); e == nil {
+		t.Errorf("List with a condition which is not a field should fail")
+	}
+}
+
+func TestRepositoryUpdate(t *testing.T) {
+	r, db := newTestRepository(t)
+
+	if e := r.Update("1", repositoryTestSchema{Id: 9, Comment: "it's changed"}); e != nil {
+		t.Fatalf("update error: %s", e)
+	}
+
+	got, e := r.Get("1")
+	if e != nil || got.Comment != "it's changed" || got.Name != "a" {
+		t.Errorf("object after update = %+v, %v", got, e)
+	}
+	if db.row("repotest", "id", "9") != nil {
+		t.Errorf("primary key should not be updated")
+	}
+}
+
+func TestRepositorySoftDeleteRestore(t *testing.T) {
+	r, db := newTestRepository(t)
+
+	if e := r.SoftDelete([]string{"1"}); e != nil {
+		t.Fatalf("soft delete error: %s", e)
+	}
+	if deleted := db.row("repotest", "id", "1")["deleted"]; deleted != "1" {
+		t.Errorf("deleted flag = %v after deleting, want 1", deleted)
+	}
+
+	if e := r.Restore([]string{"1", "3"}); e != nil {
+		t.Fatalf("restore error: %s", e)
+	}
+	conditions, _ := r.WithDeletedState(nil, DeletedStateDeleted)
+	if num, _ := r.Count("", nil, nil, conditions); num != 0 {
+		t.Errorf("%d objects are deleted after restoring, want 0", num)
+	}
+}
+
+func TestRepositoryOnPrimary(t *testing.T) {
+	r := NewRepository[repositoryTestSchema]("repotest", "repotest", "id")
+	p := r.OnPrimary()
+	if r.Primary || !p.Primary {
+		t.Errorf("OnPrimary should return a copy whose queries are sent to the primary server")
+	}
+}
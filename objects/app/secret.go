@@ -0,0 +1,428 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	sysadmDB "sysadm/db"
+	"sysadm/utils"
+)
+
+/*
+envelope encryption of secret fields:
+the string fields of an object schema tagged with `secret:"true"` are encrypted by Marshal and decrypted by Unmarshal
+transparently. a random data key is generated for each record, the values of the secret fields are encrypted with the
+data key by AES-256-GCM, and the data key is wrapped by the current master key. an encrypted value looks like:
+    enc:v1:<master key id>:<base64 of wrapped data key>:<base64 of nonce and cipher text>
+the master keys are read from the environment variable SYSADM_MASTER_KEY, or from the file which path is in
+SYSADM_MASTER_KEY_FILE, or from conf/master.key under the working root. each key is in "<id>:<base64 of 32 bytes>"
+format, the keys are separated by new lines in the file or by commas in the environment variable. the first key is the
+current master key, and the others are the old keys which are only used for decrypting.
+the values are stored as they are if no master key has been loaded, and the values which were stored in clear text are
+returned as they are by Unmarshal, so the old data can be read before they are re-encrypted by RotateAllSecrets.
+*/
+
+// keyRing holds the master keys which have been loaded
+var keyRing *masterKeyRing
+
+// keyRingLock protects keyRing
+var keyRingLock sync.RWMutex
+
+// secretRotators are the entities which secret fields will be re-encrypted when rotating the master key
+var secretRotators = make(map[string]SecretRotator, 0)
+
+// secretRotatorLock protects secretRotators
+var secretRotatorLock sync.Mutex
+
+// LoadMasterKeys load the master keys from the environment variables or the master key file under workingRoot.
+// return false if there is not any master key, then the secrets will not be encrypted.
+func LoadMasterKeys(workingRoot string) (bool, error) {
+	content := strings.TrimSpace(os.Getenv(MasterKeyEnv))
+	if content != "" {
+		content = strings.ReplaceAll(content, ",", "\n")
+	} else {
+		keyFile := strings.TrimSpace(os.Getenv(MasterKeyFileEnv))
+		if keyFile == "" {
+			keyFile = filepath.Join(workingRoot, DefaultMasterKeyFile)
+		}
+
+		data, e := os.ReadFile(keyFile)
+		if os.IsNotExist(e) && strings.TrimSpace(os.Getenv(MasterKeyFileEnv)) == "" {
+			return false, nil
+		}
+		if e != nil {
+			return false, fmt.Errorf("read master key file %s error: %s", keyFile, e)
+		}
+		content = string(data)
+	}
+
+	ring, e := parseMasterKeys(content)
+	if e != nil {
+		return false, e
+	}
+
+	keyRingLock.Lock()
+	keyRing = ring
+	keyRingLock.Unlock()
+
+	return true, nil
+}
+
+// SecretEnabled return true if the master keys have been loaded
+func SecretEnabled() bool {
+	keyRingLock.RLock()
+	defer keyRingLock.RUnlock()
+
+	return keyRing != nil
+}
+
+// IsEncryptedSecret return true if value was encrypted by EncryptSecret
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+// EncryptSecret encrypt value with a new data key. value will be returned as it is if the master keys have not been
+// loaded or value is empty
+func EncryptSecret(value string) (string, error) {
+	dataKey, e := newDataKey()
+	if e != nil || dataKey == nil {
+		return value, e
+	}
+
+	return dataKey.encrypt(value)
+}
+
+// DecryptSecret decrypt value which was encrypted by EncryptSecret. value will be returned as it is if it is in clear text
+func DecryptSecret(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, secretPrefix), ":", 3)
+	if len(parts) != 3 {
+		return "", fmt.Errorf("the format of the encrypted value is not valid")
+	}
+
+	keyRingLock.RLock()
+	ring := keyRing
+	keyRingLock.RUnlock()
+	if ring == nil {
+		return "", fmt.Errorf("master keys have not been loaded, the encrypted value can not be decrypted")
+	}
+
+	masterKey, ok := ring.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("master key %s was not found", parts[0])
+	}
+
+	wrappedKey, e := base64.StdEncoding.DecodeString(parts[1])
+	if e != nil {
+		return "", fmt.Errorf("decode data key error: %s", e)
+	}
+
+	key, e := gcmOpen(masterKey, wrappedKey, []byte(parts[0]))
+	if e != nil {
+		return "", fmt.Errorf("unwrap data key error: %s", e)
+	}
+
+	cipherText, e := base64.StdEncoding.DecodeString(parts[2])
+	if e != nil {
+		return "", fmt.Errorf("decode encrypted value error: %s", e)
+	}
+
+	plain, e := gcmOpen(key, cipherText, nil)
+	if e != nil {
+		return "", fmt.Errorf("decrypt value error: %s", e)
+	}
+
+	return string(plain), nil
+}
+
+// RotateSecret re-encrypt value with the current master key.
+// return the new value and true if value has been re-encrypted, otherwise return value and false
+func RotateSecret(value string) (string, bool, error) {
+	if value == "" || !SecretEnabled() || isEncryptedWithCurrentKey(value) {
+		return value, false, nil
+	}
+
+	plain, e := DecryptSecret(value)
+	if e != nil {
+		return value, false, e
+	}
+
+	newValue, e := EncryptSecret(plain)
+	if e != nil {
+		return value, false, e
+	}
+
+	return newValue, true, nil
+}
+
+// RegisterSecretRotator register r to the secret rotation. the rotator registered before with the same name will be replaced
+func RegisterSecretRotator(r SecretRotator) {
+	if r == nil || strings.TrimSpace(r.GetName()) == "" {
+		return
+	}
+
+	secretRotatorLock.Lock()
+	defer secretRotatorLock.Unlock()
+	secretRotators[r.GetName()] = r
+}
+
+// RotateAllSecrets re-encrypt the secrets of all registered rotators with the current master key.
+// report will be called for each rotator with the number of objects re-encrypted and the error occurred if it is not nil.
+func RotateAllSecrets(report func(objectName string, num int, e error)) error {
+	if !SecretEnabled() {
+		return fmt.Errorf("master keys have not been loaded")
+	}
+
+	secretRotatorLock.Lock()
+	var names []string
+	for name := range secretRotators {
+		names = append(names, name)
+	}
+	secretRotatorLock.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		secretRotatorLock.Lock()
+		r := secretRotators[name]
+		secretRotatorLock.Unlock()
+
+		num, e := r.RotateSecrets()
+		if report != nil {
+			report(name, num, e)
+		}
+	}
+
+	return nil
+}
+
+// RotateSecrets re-encrypt the secret fields of all objects of the repository, including the deleted ones,
+// with the current master key. return the number of objects which have been re-encrypted
+func (r Repository[T]) RotateSecrets() (int, error) {
+	var t T
+	fields := secretFields(reflect.TypeOf(t))
+	if len(fields) < 1 || !SecretEnabled() {
+		return 0, nil
+	}
+
+	dbData, e := getObjectList(r.TableName, r.PkName, "", nil, nil, nil, 0, 0, nil, true)
+	if e != nil {
+		return 0, e
+	}
+
+	num := 0
+	for _, row := range dbData {
+		id := utils.Interface2String(row[r.PkName])
+		updateData := make(sysadmDB.FieldData, 0)
+		for _, field := range fields {
+			value, changed, e := RotateSecret(utils.Interface2String(row[field]))
+			if e != nil {
+				return num, fmt.Errorf("rotate %s of %s %s error: %s", field, r.Name, id, e)
+			}
+			if changed {
+				updateData[field] = QuoteString(value)
+			}
+		}
+
+		if len(updateData) < 1 {
+			continue
+		}
+
+		where := map[string]string{r.PkName: id}
+		if e := runData.dbConf.Entity.NewUpdateData(r.TableName, updateData, where); e != nil {
+			return num, e
+		}
+		num++
+	}
+
+	return num, nil
+}
+
+// dataKey is the key which the secret fields of a record are encrypted with
+type dataKey struct {
+	key        []byte
+	masterID   string
+	wrappedKey string
+}
+
+// newDataKey generate a data key and wrap it with the current master key.
+// return nil if the master keys have not been loaded
+func newDataKey() (*dataKey, error) {
+	keyRingLock.RLock()
+	ring := keyRing
+	keyRingLock.RUnlock()
+	if ring == nil {
+		return nil, nil
+	}
+
+	key := make([]byte, secretMasterKeySize)
+	if _, e := io.ReadFull(rand.Reader, key); e != nil {
+		return nil, fmt.Errorf("generate data key error: %s", e)
+	}
+
+	wrappedKey, e := gcmSeal(ring.keys[ring.current], key, []byte(ring.current))
+	if e != nil {
+		return nil, fmt.Errorf("wrap data key error: %s", e)
+	}
+
+	return &dataKey{key: key, masterID: ring.current, wrappedKey: base64.StdEncoding.EncodeToString(wrappedKey)}, nil
+}
+
+// encrypt encrypt value with the data key. empty value will not be encrypted
+func (k *dataKey) encrypt(value string) (string, error) {
+	if value == "" || IsEncryptedSecret(value) {
+		return value, nil
+	}
+
+	cipherText, e := gcmSeal(k.key, []byte(value), nil)
+	if e != nil {
+		return "", fmt.Errorf("encrypt value error: %s", e)
+	}
+
+	return secretPrefix + k.masterID + ":" + k.wrappedKey + ":" + base64.StdEncoding.EncodeToString(cipherText), nil
+}
+
+// isEncryptedWithCurrentKey return true if value was encrypted with a data key wrapped by the current master key
+func isEncryptedWithCurrentKey(value string) bool {
+	keyRingLock.RLock()
+	defer keyRingLock.RUnlock()
+	if keyRing == nil {
+		return false
+	}
+
+	return strings.HasPrefix(value, secretPrefix+keyRing.current+":")
+}
+
+// isSecretField return true if field is a string field tagged with `secret:"true"`
+func isSecretField(field reflect.StructField) bool {
+	return field.Type.Kind() == reflect.String && strings.TrimSpace(strings.ToLower(field.Tag.Get(secretTag))) == "true"
+}
+
+// secretFields return the db tags of the secret fields of t
+func secretFields(t reflect.Type) []string {
+	var ret []string
+	if t == nil {
+		return ret
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return ret
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("db")
+		if field.IsExported() && tag != "" && isSecretField(field) {
+			ret = append(ret, tag)
+		}
+	}
+
+	return ret
+}
+
+// parseMasterKeys parse the master keys in content. each line of content is a key in "<id>:<base64 of key>" format
+func parseMasterKeys(content string) (*masterKeyRing, error) {
+	ring := &masterKeyRing{keys: make(map[string][]byte, 0)}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, keyStr, found := strings.Cut(line, ":")
+		id = strings.TrimSpace(id)
+		if !found || id == "" {
+			return nil, fmt.Errorf("master key should be in <id>:<base64 of key> format")
+		}
+
+		key, e := base64.StdEncoding.DecodeString(strings.TrimSpace(keyStr))
+		if e != nil {
+			return nil, fmt.Errorf("decode master key %s error: %s", id, e)
+		}
+		if len(key) != secretMasterKeySize {
+			return nil, fmt.Errorf("the length of master key %s should be %d bytes", id, secretMasterKeySize)
+		}
+		if _, ok := ring.keys[id]; ok {
+			return nil, fmt.Errorf("master key %s is duplicated", id)
+		}
+
+		if ring.current == "" {
+			ring.current = id
+		}
+		ring.keys[id] = key
+	}
+
+	if ring.current == "" {
+		return nil, fmt.Errorf("no master key was found")
+	}
+
+	return ring, nil
+}
+
+// gcmSeal encrypt plain with key by AES-GCM. the nonce is prepended to the cipher text
+func gcmSeal(key, plain, additionalData []byte) ([]byte, error) {
+	aead, e := newGCM(key)
+	if e != nil {
+		return nil, e
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, e := io.ReadFull(rand.Reader, nonce); e != nil {
+		return nil, e
+	}
+
+	return aead.Seal(nonce, nonce, plain, additionalData), nil
+}
+
+// gcmOpen decrypt data which was encrypted by gcmSeal
+func gcmOpen(key, data, additionalData []byte) ([]byte, error) {
+	aead, e := newGCM(key)
+	if e != nil {
+		return nil, e
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("the length of cipher text is too short")
+	}
+
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, e := aes.NewCipher(key)
+	if e != nil {
+		return nil, e
+	}
+
+	return cipher.NewGCM(block)
+}
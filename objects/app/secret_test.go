@@ -0,0 +1,210 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+type secretTestSchema struct {
+	Id       uint   `db:"id"`
+	Name     string `db:"name"`
+	Password string `db:"password" secret:"true"`
+	Token    string `db:"token" secret:"true"`
+}
+
+// testMasterKey return a master key line with id whose key bytes are all b
+func testMasterKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string([]byte{b}), secretMasterKeySize)))
+}
+
+// setTestMasterKeys set the master keys in content as the loaded keys, and restore the keys after the test.
+// the master keys are unloaded if content is empty
+func setTestMasterKeys(t *testing.T, content string) {
+	t.Helper()
+	keyRingLock.Lock()
+	old := keyRing
+	keyRing = nil
+	keyRingLock.Unlock()
+	t.Cleanup(func() {
+		keyRingLock.Lock()
+		keyRing = old
+		keyRingLock.Unlock()
+	})
+
+	if content == "" {
+		return
+	}
+
+	ring, e := parseMasterKeys(content)
+	if e != nil {
+		t.Fatalf("parse master keys error: %s", e)
+	}
+	keyRingLock.Lock()
+	keyRing = ring
+	keyRingLock.Unlock()
+}
+
+func TestParseMasterKeys(t *testing.T) {
+	ring, e := parseMasterKeys("# comment\n" + testMasterKey("k2", 2) + "\n\n" + testMasterKey("k1", 1) + "\n")
+	if e != nil {
+		t.Fatalf("parse master keys error: %s", e)
+	}
+	if ring.current != "k2" || len(ring.keys) != 2 {
+		t.Errorf("current key = %s, number of keys = %d, want k2 and 2", ring.current, len(ring.keys))
+	}
+
+	invalid := []string{
+		"",
+		"k1",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		testMasterKey("k1", 1) + "\n" + testMasterKey("k1", 2),
+	}
+	for _, content := range invalid {
+		if _, e := parseMasterKeys(content); e == nil {
+			t.Errorf("master keys %q should be invalid", content)
+		}
+	}
+}
+
+func TestEncryptDecryptSecret(t *testing.T) {
+	setTestMasterKeys(t, "")
+	value, e := EncryptSecret("secret")
+	if e != nil || value != "secret" {
+		t.Fatalf("EncryptSecret without master keys = %q, %v, want the value as it is", value, e)
+	}
+
+	setTestMasterKeys(t, testMasterKey("k1", 1))
+	encrypted, e := EncryptSecret("secret")
+	if e != nil {
+		t.Fatalf("encrypt secret error: %s", e)
+	}
+	if !strings.HasPrefix(encrypted, secretPrefix+"k1:") || strings.Contains(encrypted, "secret") {
+		t.Errorf("encrypted value %q is not encrypted with k1", encrypted)
+	}
+
+	plain, e := DecryptSecret(encrypted)
+	if e != nil || plain != "secret" {
+		t.Errorf("DecryptSecret = %q, %v, want secret", plain, e)
+	}
+
+	plain, e = DecryptSecret("clear text")
+	if e != nil || plain != "clear text" {
+		t.Errorf("DecryptSecret of clear text = %q, %v, want it as it is", plain, e)
+	}
+
+	if _, e := DecryptSecret(encrypted[:len(encrypted)-4] + "AAAA"); e == nil {
+		t.Errorf("tampered value should not be decrypted")
+	}
+}
+
+func TestMarshalUnmarshalSecretFields(t *testing.T) {
+	setTestMasterKeys(t, testMasterKey("k1", 1))
+	data, e := Marshal(secretTestSchema{Id: 1, Name: "alice", Password: "p@ss'word", Token: "token"})
+	if e != nil {
+		t.Fatalf("marshal error: %s", e)
+	}
+
+	if data["name"] != "alice" {
+		t.Errorf("name = %v, the fields which are not secret should not be encrypted", data["name"])
+	}
+	password, token := data["password"].(string), data["token"].(string)
+	if !IsEncryptedSecret(password) || !IsEncryptedSecret(token) {
+		t.Fatalf("secret fields are not encrypted: %q %q", password, token)
+	}
+	// the secret fields of a record share the data key
+	if strings.Split(password, ":")[3] != strings.Split(token, ":")[3] {
+		t.Errorf("the secret fields of a record should be encrypted with the same data key")
+	}
+
+	var got secretTestSchema
+	if e := Unmarshal(data, &got); e != nil {
+		t.Fatalf("unmarshal error: %s", e)
+	}
+	if got.Password != "p@ss'word" || got.Token != "token" || got.Name != "alice" {
+		t.Errorf("unmarshal got %+v", got)
+	}
+
+	// values stored in clear text before encryption was enabled can be read
+	if e := Unmarshal(map[string]interface{}{"password": "old"}, &got); e != nil || got.Password != "old" {
+		t.Errorf("unmarshal clear text got %q, %v", got.Password, e)
+	}
+}
+
+func TestRotateSecret(t *testing.T) {
+	setTestMasterKeys(t, testMasterKey("k1", 1))
+	old, e := EncryptSecret("secret")
+	if e != nil {
+		t.Fatalf("encrypt secret error: %s", e)
+	}
+
+	setTestMasterKeys(t, testMasterKey("k2", 2)+"\n"+testMasterKey("k1", 1))
+	rotated, changed, e := RotateSecret(old)
+	if e != nil || !changed {
+		t.Fatalf("RotateSecret = %v, %v, want the value re-encrypted", changed, e)
+	}
+	if !strings.HasPrefix(rotated, secretPrefix+"k2:") {
+		t.Errorf("rotated value %q is not encrypted with k2", rotated)
+	}
+	if plain, e := DecryptSecret(rotated); e != nil || plain != "secret" {
+		t.Errorf("DecryptSecret of rotated value = %q, %v", plain, e)
+	}
+
+	if _, changed, _ := RotateSecret(rotated); changed {
+		t.Errorf("the value encrypted with the current key should not be rotated")
+	}
+
+	clear, changed, e := RotateSecret("clear")
+	if e != nil || !changed || !strings.HasPrefix(clear, secretPrefix+"k2:") {
+		t.Errorf("RotateSecret of clear text = %q, %v, %v, want it encrypted with k2", clear, changed, e)
+	}
+}
+
+func TestRepositoryRotateSecrets(t *testing.T) {
+	db := newFakeDB(t)
+	setTestMasterKeys(t, testMasterKey("k1", 1))
+	old, _ := EncryptSecret("old")
+	db.insert("secrettest",
+		map[string]interface{}{"id": 1, "name": "a", "password": old},
+		map[string]interface{}{"id": 2, "name": "b", "password": "clear"},
+		map[string]interface{}{"id": 3, "name": "c", "password": ""},
+	)
+
+	setTestMasterKeys(t, testMasterKey("k2", 2)+"\n"+testMasterKey("k1", 1))
+	r := NewRepository[secretTestSchema]("secrettest", "secrettest", "id")
+	num, e := r.RotateSecrets()
+	if e != nil {
+		t.Fatalf("rotate secrets error: %s", e)
+	}
+	if num != 2 || db.updates != 2 {
+		t.Errorf("%d objects rotated and %d rows updated, want 2", num, db.updates)
+	}
+
+	for id, want := range map[string]string{"1": "old", "2": "clear"} {
+		got, e := r.Get(id)
+		if e != nil {
+			t.Fatalf("get object %s error: %s", id, e)
+		}
+		value := db.row("secrettest", "id", id)["password"].(string)
+		if !strings.HasPrefix(value, secretPrefix+"k2:") || got.Password != want {
+			t.Errorf("password of object %s is %q(%q), want %q encrypted with k2", id, value, got.Password, want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	sysadmDB "sysadm/db"
+	"sysadm/utils"
+	"time"
+)
+
+/*
+soft delete contract of objects:
+the schema of an object which can be deleted softly should have an int field tagged with `softdelete:"flag"`, the value
+of the field is 0 for an active object and 1 for a deleted object. the schema can have a field tagged with
+`softdelete:"time"` too, the value of the field is the time when the object was deleted. the time field may be an int
+field which holds a unix timestamp, or a string field which holds the time in "2006-01-02 15:04:05" format.
+only the objects which have the time field can be purged.
+*/
+
+// purgers are the entities which deleted objects will be purged by the purge job
+var purgers = make(map[string]Purger, 0)
+
+// purgerLock protects purgers
+var purgerLock sync.Mutex
+
+// ParseDeletedState return the deleted state in state. DeletedStateActive will be returned if state is empty
+func ParseDeletedState(state string) (string, error) {
+	state = strings.TrimSpace(strings.ToLower(state))
+	switch state {
+	case "", "0", DeletedStateActive:
+		return DeletedStateActive, nil
+	case "1", DeletedStateDeleted:
+		return DeletedStateDeleted, nil
+	case DeletedStateAll:
+		return DeletedStateAll, nil
+	}
+
+	return "", fmt.Errorf("deleted state %s is not valid", state)
+}
+
+// DeletedStateCondition return the condition of the deleted flag field for the objects in deleted state state.
+// an empty string will be returned for DeletedStateAll
+func DeletedStateCondition(state string) (string, error) {
+	state, e := ParseDeletedState(state)
+	if e != nil {
+		return "", e
+	}
+
+	switch state {
+	case DeletedStateDeleted:
+		return "=1", nil
+	case DeletedStateAll:
+		return "", nil
+	}
+
+	return "=0", nil
+}
+
+// RegisterPurger register p to the purge job. the purger registered before with the same name will be replaced
+func RegisterPurger(p Purger) {
+	if p == nil || strings.TrimSpace(p.GetName()) == "" {
+		return
+	}
+
+	purgerLock.Lock()
+	defer purgerLock.Unlock()
+	purgers[p.GetName()] = p
+}
+
+// PurgeDeletedObjects purge the deleted objects of all registered purgers once.
+// the objects which have been deleted longer than the retention of them will be purged. the objects will be kept if
+// their retention is 0.
+// report will be called for each purger with the number of objects purged and the error occurred if it is not nil.
+func PurgeDeletedObjects(retention RetentionFunc, report func(objectName string, num int, e error)) {
+	purgerLock.Lock()
+	var names []string
+	for name := range purgers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var list []Purger
+	for _, name := range names {
+		list = append(list, purgers[name])
+	}
+	purgerLock.Unlock()
+
+	for _, p := range list {
+		keep, e := retention(p.GetName())
+		if e != nil || keep <= 0 {
+			if report != nil && e != nil {
+				report(p.GetName(), 0, e)
+			}
+			continue
+		}
+
+		num, e := p.Purge(time.Now().Add(-keep))
+		if report != nil {
+			report(p.GetName(), num, e)
+		}
+	}
+}
+
+// StartPurgeJob start a goroutine which call PurgeDeletedObjects every interval until stopCh is closed
+func StartPurgeJob(interval time.Duration, retention RetentionFunc, report func(objectName string, num int, e error), stopCh <-chan struct{}) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval of purge job must be large than 0")
+	}
+
+	if retention == nil {
+		return fmt.Errorf("retention of deleted objects has not be set")
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				PurgeDeletedObjects(retention, report)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// GetName return the name of the objects of the repository
+func (r Repository[T]) GetName() string {
+	return r.Name
+}
+
+// WithDeletedState return a copy of conditions with the condition which selects the objects in deleted state state.
+// state is one of DeletedStateActive, DeletedStateDeleted and DeletedStateAll
+func (r Repository[T]) WithDeletedState(conditions map[string]string, state string) (map[string]string, error) {
+	ret := make(map[string]string, 0)
+	for k, v := range conditions {
+		ret[k] = v
+	}
+
+	info, e := r.softDeleteInfo()
+	if e != nil {
+		return ret, e
+	}
+
+	condition, e := DeletedStateCondition(state)
+	if e != nil {
+		return ret, e
+	}
+
+	if condition == "" {
+		delete(ret, info.flagField)
+	} else {
+		ret[info.flagField] = condition
+	}
+
+	return ret, nil
+}
+
+// Restore restore the objects which primary key in ids
+func (r Repository[T]) Restore(ids []string) error {
+	return r.setDeleted(ObjectTx{}, ids, false)
+}
+
+// RestoreTx restore the objects which primary key in ids in transaction tx
+func (r Repository[T]) RestoreTx(tx ObjectTx, ids []string) error {
+	if tx.Tx == nil {
+		return fmt.Errorf("transaction has not began")
+	}
+
+	return r.setDeleted(tx, ids, false)
+}
+
+// PurgeableIDs return the primary keys of the objects which have been deleted before before
+func (r Repository[T]) PurgeableIDs(before time.Time) ([]string, error) {
+	var ret []string
+	info, e := r.softDeleteInfo()
+	if e != nil {
+		return ret, e
+	}
+
+	if info.timeField == "" {
+		return ret, fmt.Errorf("%s has not deleted time field, so it can not be purged", r.Name)
+	}
+
+	conditions := make(map[string]string, 0)
+	conditions[info.flagField] = "=1"
+	if info.timeKind == reflect.String {
+		conditions[info.timeField] = " between '1' and '" + before.Format(softDeleteTimeFormat) + "'"
+	} else {
+		conditions[info.timeField] = " between 1 and " + strconv.FormatInt(before.Unix(), 10)
+	}
+
+	dbData, e := GetObjectList(r.TableName, r.PkName, "", nil, nil, conditions, 0, 0, nil)
+	if e != nil {
+		return ret, e
+	}
+
+	for _, row := range dbData {
+		id := utils.Interface2String(row[r.PkName])
+		if id != "" {
+			ret = append(ret, id)
+		}
+	}
+
+	return ret, nil
+}
+
+// DeleteTx delete the objects which primary key in ids from DB in transaction tx.
+// the objects are deleted one by one, as the delete statement quotes the values of the conditions
+func (r Repository[T]) DeleteTx(tx ObjectTx, ids []string) error {
+	if tx.Tx == nil {
+		return fmt.Errorf("transaction has not began")
+	}
+
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return fmt.Errorf("can not delete %s with empty ID", r.Name)
+		}
+
+		deleteData := sysadmDB.SelectData{
+			Tb:    []string{r.TableName},
+			Where: map[string]string{r.PkName: id},
+		}
+		if e := tx.Tx.NewDeleteData(&deleteData); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Purge delete the objects which have been deleted before before from DB.
+// return the number of objects have been purged
+func (r Repository[T]) Purge(before time.Time) (int, error) {
+	ids, e := r.PurgeableIDs(before)
+	if e != nil || len(ids) < 1 {
+		return 0, e
+	}
+
+	tx, e := BeginTx(nil, r)
+	if e != nil {
+		return 0, e
+	}
+
+	if e := r.DeleteTx(tx, ids); e != nil {
+		_ = tx.Rollback()
+		return 0, e
+	}
+
+	if e := tx.Commit(); e != nil {
+		return 0, e
+	}
+
+	return len(ids), nil
+}
+
+// setDeleted mark the objects which primary key in ids as deleted or restore them.
+// the statements will be executed in tx if tx.Tx is not nil
+func (r Repository[T]) setDeleted(tx ObjectTx, ids []string, deleted bool) error {
+	info, e := r.softDeleteInfo()
+	if e != nil {
+		return e
+	}
+
+	updateData := make(sysadmDB.FieldData, 0)
+	updateData[info.flagField] = 0
+	if deleted {
+		updateData[info.flagField] = 1
+	}
+
+	if info.timeField != "" {
+		switch {
+		case info.timeKind == reflect.String && deleted:
+			updateData[info.timeField] = QuoteString(time.Now().Format(softDeleteTimeFormat))
+		case info.timeKind == reflect.String:
+			updateData[info.timeField] = "''"
+		case deleted:
+			updateData[info.timeField] = time.Now().Unix()
+		default:
+			updateData[info.timeField] = 0
+		}
+	}
+
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return fmt.Errorf("can not delete or restore %s with empty ID", r.Name)
+		}
+
+		where := map[string]string{r.PkName: id}
+		if tx.Tx != nil {
+			e = tx.Tx.NewUpdateData(r.TableName, updateData, where)
+		} else {
+			e = runData.dbConf.Entity.NewUpdateData(r.TableName, updateData, where)
+		}
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// softDeleteInfo get the fields of T which are tagged with softdelete tag
+func (r Repository[T]) softDeleteInfo() (softDeleteInfo, error) {
+	info := softDeleteInfo{}
+	var schema T
+	sT := reflect.TypeOf(schema)
+	if sT == nil || sT.Kind() != reflect.Struct {
+		return info, fmt.Errorf("schema of %s is not a struct", r.Name)
+	}
+
+	for i := 0; i < sT.NumField(); i++ {
+		field := sT.Field(i)
+		dbTag, okDb := field.Tag.Lookup("db")
+		tag, ok := field.Tag.Lookup(softDeleteTag)
+		if !field.IsExported() || !okDb || dbTag == "" || !ok {
+			continue
+		}
+
+		switch tag {
+		case softDeleteFlag:
+			info.flagField = dbTag
+		case softDeleteTime:
+			info.timeField = dbTag
+			info.timeKind = field.Type.Kind()
+		}
+	}
+
+	if info.flagField == "" {
+		return info, fmt.Errorf("%s can not be deleted softly", r.Name)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,68 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+type purgeTestSchema struct {
+	Id          uint `db:"id"`
+	Deleted     int  `db:"deleted" softdelete:"flag"`
+	DeletedTime int  `db:"deleted_time" softdelete:"time"`
+}
+
+func TestRepositoryPurge(t *testing.T) {
+	db := newFakeDB(t)
+	r := NewRepository[purgeTestSchema]("purgetest", "purgetest", "id")
+	old := strconv.FormatInt(time.Now().Add(-48*time.Hour).Unix(), 10)
+	recent := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	db.insert("purgetest",
+		map[string]interface{}{"id": 1, "deleted": 1, "deleted_time": old},
+		map[string]interface{}{"id": 2, "deleted": 1, "deleted_time": old},
+		map[string]interface{}{"id": 3, "deleted": 1, "deleted_time": recent},
+		map[string]interface{}{"id": 4, "deleted": 0, "deleted_time": 0},
+	)
+
+	num, e := r.Purge(time.Now().Add(-24 * time.Hour))
+	if e != nil || num != 2 {
+		t.Fatalf("Purge = %d, %v, want 2", num, e)
+	}
+
+	for id, kept := range map[string]bool{"1": false, "2": false, "3": true, "4": true} {
+		if got := db.row("purgetest", "id", id) != nil; got != kept {
+			t.Errorf("object %s is kept: %v, want %v", id, got, kept)
+		}
+	}
+
+	want := map[string]bool{"delete from `purgetest` where `id`='1'": true, "delete from `purgetest` where `id`='2'": true}
+	if len(db.deletes) != len(want) {
+		t.Fatalf("delete statements = %q", db.deletes)
+	}
+	for _, stmt := range db.deletes {
+		if !want[stmt] {
+			t.Errorf("delete statement %q is not expected", stmt)
+		}
+	}
+
+	if num, e := r.Purge(time.Now().Add(-24 * time.Hour)); e != nil || num != 0 {
+		t.Errorf("Purge again = %d, %v, want 0", num, e)
+	}
+}
@@ -127,30 +127,26 @@ func (o ObjectTx) Commit() error {
 	return tx.Commit()
 }
 
-func (o ObjectTx) UpdateObjectNextID() error {
+// NextObjectID allocates a new ID for the object of o.Entity in the transaction.
+// the sequence of the object keeps locked until the transaction is committed or rolled back.
+func (o ObjectTx) NextObjectID() (uint, error) {
 	if o.Tx == nil {
-		return fmt.Errorf("transaction has not began")
+		return 0, fmt.Errorf("transaction has not began")
 	}
 
 	if o.Entity == nil {
-		return fmt.Errorf("objection entity is nil")
+		return 0, fmt.Errorf("objection entity is nil")
 	}
 
-	objEntity := o.Entity
-	tbName, idField, e := objEntity.GetObjectIDFieldName()
+	tbName, idField, e := o.Entity.GetObjectIDFieldName()
 	if e != nil {
-		return e
+		return 0, e
 	}
 
-	filedData, where, e := prepareUpdateObjNextIDData(tbName, idField, o.Tx.Entity)
+	id, e := o.Tx.NextID(tbName, idField)
 	if e != nil {
-		return e
-	}
-
-	tx := o.Tx
-	if tx == nil {
-		return fmt.Errorf("transaction has not began")
+		return 0, e
 	}
 
-	return tx.NewUpdateData("ids", filedData, where)
+	return uint(id), nil
 }
@@ -0,0 +1,114 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package objectsUI
+
+import (
+	"github.com/wangyysde/sysadmServer"
+	"net/http"
+	"strings"
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/sysadmLog"
+	"sysadm/sysadmapi/apiutils"
+	"sysadm/user"
+	"sysadm/utils"
+)
+
+// GetDeletedStateFromRequest get the deleted state of the objects which should be listed from "deleted" of request data.
+// the value of "deleted" is one of active, deleted and all. active will be returned if it is empty or not valid
+func GetDeletedStateFromRequest(requestData map[string]string) string {
+	state, e := sysadmObjects.ParseDeletedState(requestData["deleted"])
+	if e != nil {
+		return sysadmObjects.DeletedStateActive
+	}
+
+	return state
+}
+
+// GetDeletedConditionFromRequest return the condition of isDeleted field which is suitable for BuildCondition
+func GetDeletedConditionFromRequest(requestData map[string]string) string {
+	condition, _ := sysadmObjects.DeletedStateCondition(GetDeletedStateFromRequest(requestData))
+
+	return condition
+}
+
+// SoftDeleteHandler mark the objects which IDs are in objID or objectid[] of the request as deleted, or restore them
+// if restore is true. the objects referenced by other objects will not be deleted unless cascade of the request is
+// "1" or "true", then the objects referencing them will be deleted too.
+// the result will be sent to client as ApiResponseData. the error codes are from errCode to errCode+3
+func SoftDeleteHandler(c *sysadmServer.Context, entity sysadmObjects.SoftDeleter, restore bool, sessionName string,
+	logEntity *sysadmLog.LoggerConfig, errCode int) {
+	var errs []sysadmLog.Sysadmerror
+	action := "delete"
+	if restore {
+		action = "restore"
+	}
+
+	islogin, _, _ := user.IsLogin(c, sessionName)
+	if !islogin {
+		errs = append(errs, sysadmLog.NewErrorWithStringLevel(errCode, "info", "user has not login or not permission when %s objects", action))
+		logEntity.LogErrors(errs)
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(errCode, "您没有登录或者没有权限执行本操作"))
+		return
+	}
+
+	var ids []string
+	requestData, e := utils.NewGetRequestData(c, []string{"objID", "cascade"})
+	if e == nil && strings.TrimSpace(requestData["objID"]) != "" {
+		ids = append(ids, strings.TrimSpace(requestData["objID"]))
+	}
+	idMap, _ := utils.GetRequestDataArray(c, []string{"objectid[]"})
+	if idMap != nil {
+		ids = append(ids, idMap["objectid[]"]...)
+	}
+	if len(ids) < 1 {
+		errs = append(errs, sysadmLog.NewErrorWithStringLevel(errCode+1, "info", "no object was requested to %s", action))
+		logEntity.LogErrors(errs)
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(errCode+1, "请选择要操作的对象"))
+		return
+	}
+
+	cascade := strings.TrimSpace(strings.ToLower(requestData["cascade"]))
+	switch {
+	case restore:
+		e = entity.Restore(ids)
+	case cascade == "1" || cascade == "true":
+		e = entity.SoftDeleteCascade(ids)
+	default:
+		e = entity.SoftDelete(ids)
+	}
+	if refErr, ok := sysadmObjects.IsReferencedError(e); ok {
+		errs = append(errs, sysadmLog.NewErrorWithStringLevel(errCode+2, "info", "objects %v can not be deleted: %s", ids, refErr))
+		logEntity.LogErrors(errs)
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(errCode+2, "对象仍被以下对象引用，请先删除它们或者选择级联删除: "+refErr.Error()))
+		return
+	}
+	if e != nil {
+		errs = append(errs, sysadmLog.NewErrorWithStringLevel(errCode+2, "error", "%s objects %v error: %s", action, ids, e))
+		logEntity.LogErrors(errs)
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(errCode+2, "系统出错，请稍后再试或者联系系统管理员"))
+		return
+	}
+
+	msg := "删除成功"
+	if restore {
+		msg = "恢复成功"
+	}
+	errs = append(errs, sysadmLog.NewErrorWithStringLevel(errCode+3, "debug", "objects %v have been %sd", ids, action))
+	logEntity.LogErrors(errs)
+	c.JSON(http.StatusOK, apiutils.BuildResponseDataForSuccess(msg))
+}
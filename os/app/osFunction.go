@@ -18,142 +18,15 @@
 package app
 
 import (
-	"fmt"
 	"strings"
 	sysadmObjects "sysadm/objects/app"
 )
 
 func New() OS {
-	ret := OS{}
-	ret.Name = defaultObjectName
-	ret.TableName = defaultTableName
-	ret.PkName = defaultPkName
-	return ret
-}
-
-func (o OS) GetObjectInfoByID(id string) (interface{}, error) {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return nil, fmt.Errorf("can not get OS information with empty ID")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(o.TableName, o.PkName, id)
-	if e != nil {
-		return nil, e
-	}
-
-	osData := OSSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &osData)
-
-	return osData, e
+	return OS{Repository: sysadmObjects.NewRepository[OSSchema](defaultObjectName, defaultTableName, defaultPkName)}
 }
 
+// GetObjectInfoByName get the OS information by its name
 func (o OS) GetObjectInfoByName(name string) (interface{}, error) {
-	name = strings.TrimSpace(strings.ToLower(name))
-	if name == "" {
-		return nil, fmt.Errorf("can not get OS information with empty name")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(o.TableName, "name", name)
-	if e != nil {
-		return nil, e
-	}
-
-	osData := OSSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &osData)
-
-	return osData, e
-}
-
-func (o OS) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &OSSchema{}); !ok {
-		return -1, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &OSSchema{}); !ok {
-		return -1, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	return sysadmObjects.GetObjectCount(o.TableName, o.PkName, searchContent, ids, searchKeys, conditions)
-}
-
-func (o OS) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
-	startPos, step int, orders map[string]string) ([]interface{}, error) {
-
-	var ret []interface{}
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &OSSchema{}); !ok {
-		return ret, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &OSSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	var orderKeys []string
-	for k, _ := range orders {
-		orderKeys = append(orderKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(orderKeys, &OSSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
-	}
-
-	dbData, e := sysadmObjects.GetObjectList(o.TableName, o.PkName, searchContent, ids, searchKeys, conditions, startPos, step, orders)
-	if e != nil {
-		return ret, e
-	}
-
-	var tmpRes []interface{}
-	for _, v := range dbData {
-		cluster := &OSSchema{}
-		if e := sysadmObjects.Unmarshal(v, cluster); e != nil {
-			return ret, e
-		}
-		tmpRes = append(tmpRes, *cluster)
-	}
-
-	return tmpRes, nil
-}
-
-func (o OS) AddObject(data interface{}) error {
-	osData, ok := data.(OSSchema)
-	if !ok {
-		return fmt.Errorf("the data try to add is not valid")
-	}
-
-	insertData, e := sysadmObjects.Marshal(osData)
-	if e != nil {
-		return e
-	}
-
-	return sysadmObjects.AddObject(o.TableName, "", insertData)
-}
-
-func (o OS) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
-	addData := make(map[string]interface{}, 0)
-
-	schemaData, ok := data.(OSSchema)
-	if !ok {
-		return addData, "", fmt.Errorf("there is an error occurred when coverting data to OS Schema schema")
-	}
-
-	addData, e := sysadmObjects.Marshal(schemaData)
-	if e != nil {
-		return addData, "", e
-	}
-
-	return addData, o.TableName, nil
-}
-
-func (o OS) GetObjectIDFieldName() (string, string, error) {
-	return o.TableName, o.PkName, nil
+	return o.GetByField("name", strings.ToLower(name))
 }
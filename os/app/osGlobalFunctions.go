@@ -47,11 +47,7 @@ func SetRunData(dbConf *sysadmDB.DbConfig, logEntity *sysadmLog.LoggerConfig, wo
 		return e
 	}
 
-	runData.objectEntiy = OS{
-		Name:      defaultObjectName,
-		TableName: defaultTableName,
-		PkName:    defaultPkName,
-	}
+	runData.objectEntiy = New()
 
 	return nil
 }
@@ -26,12 +26,7 @@ import (
 )
 
 type OS struct {
-	// object name. the value should be "os"
-	Name string
-	// table name which hold host data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[OSSchema]
 }
 
 // 操作系统信息表结构
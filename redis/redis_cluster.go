@@ -20,6 +20,7 @@ package redis
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	redis "github.com/go-redis/redis/v8"
@@ -104,3 +105,111 @@ func (r RedisCluster) RPop(ctx context.Context, key string) *redis.StringCmd {
 func (r RedisCluster) LLen(ctx context.Context, key string) *redis.IntCmd {
 	return r.Client.LLen(ctx, key)
 }
+
+func (r RedisCluster) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.Expire(ctx, key, expiration)
+}
+
+func (r RedisCluster) PExpire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.PExpire(ctx, key, expiration)
+}
+
+func (r RedisCluster) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	return r.Client.TTL(ctx, key)
+}
+
+func (r RedisCluster) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.SetNX(ctx, key, value, expiration)
+}
+
+// ScanKeys scan the keys on all master nodes of the cluster. fn is called serially
+func (r RedisCluster) ScanKeys(ctx context.Context, match string, count int64, fn func(key string) error) error {
+	var lock sync.Mutex
+	return r.Client.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		return scanKeys(ctx, client, match, count, func(key string) error {
+			lock.Lock()
+			defer lock.Unlock()
+			return fn(key)
+		})
+	})
+}
+
+func (r RedisCluster) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return r.Client.Pipelined(ctx, fn)
+}
+
+func (r RedisCluster) TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return r.Client.TxPipelined(ctx, fn)
+}
+
+func (r RedisCluster) Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	return r.Client.Watch(ctx, fn, keys...)
+}
+
+func (r RedisCluster) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	return r.Client.Publish(ctx, channel, message)
+}
+
+func (r RedisCluster) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return r.Client.Subscribe(ctx, channels...)
+}
+
+func (r RedisCluster) PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub {
+	return r.Client.PSubscribe(ctx, patterns...)
+}
+
+func (r RedisCluster) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	return r.Client.Eval(ctx, script, keys, args...)
+}
+
+func (r RedisCluster) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	return r.Client.EvalSha(ctx, sha1, keys, args...)
+}
+
+func (r RedisCluster) ScriptExists(ctx context.Context, hashes ...string) *redis.BoolSliceCmd {
+	return r.Client.ScriptExists(ctx, hashes...)
+}
+
+func (r RedisCluster) ScriptLoad(ctx context.Context, script string) *redis.StringCmd {
+	return r.Client.ScriptLoad(ctx, script)
+}
+
+func (r RedisCluster) ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd {
+	return r.Client.ZAdd(ctx, key, members...)
+}
+
+func (r RedisCluster) ZIncrBy(ctx context.Context, key string, increment float64, member string) *redis.FloatCmd {
+	return r.Client.ZIncrBy(ctx, key, increment, member)
+}
+
+func (r RedisCluster) ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return r.Client.ZRem(ctx, key, members...)
+}
+
+func (r RedisCluster) ZRemRangeByScore(ctx context.Context, key, min, max string) *redis.IntCmd {
+	return r.Client.ZRemRangeByScore(ctx, key, min, max)
+}
+
+func (r RedisCluster) ZCard(ctx context.Context, key string) *redis.IntCmd {
+	return r.Client.ZCard(ctx, key)
+}
+
+func (r RedisCluster) ZScore(ctx context.Context, key, member string) *redis.FloatCmd {
+	return r.Client.ZScore(ctx, key, member)
+}
+
+func (r RedisCluster) ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	return r.Client.ZRange(ctx, key, start, stop)
+}
+
+func (r RedisCluster) ZRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd {
+	return r.Client.ZRangeWithScores(ctx, key, start, stop)
+}
+
+func (r RedisCluster) ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	return r.Client.ZRangeByScore(ctx, key, opt)
+}
+
+func (r RedisCluster) ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd {
+	return r.Client.ZRevRangeWithScores(ctx, key, start, stop)
+}
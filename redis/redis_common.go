@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	redis "github.com/go-redis/redis/v8"
 	"sysadm/httpclient"
@@ -351,7 +352,8 @@ func HExists(entity RedisEntity, ctx context.Context, key, field string) (bool,
 	return result, e
 }
 
-// Keys get all keys' name matched pattern
+// Keys get all keys' name matched pattern.
+// the keys are iterated by SCAN command instead of KEYS, so redis server will not be blocked by a large keyspace
 func Keys(entity RedisEntity, ctx context.Context, pattern string) ([]string, error) {
 	var ret []string
 
@@ -359,17 +361,31 @@ func Keys(entity RedisEntity, ctx context.Context, pattern string) ([]string, er
 		return ret, fmt.Errorf("can not get keys on  nil entity")
 	}
 
-	ss := entity.Keys(ctx, pattern)
-	result, e := ss.Result()
-	if e == redis.Nil {
-		return ret, fmt.Errorf("the key does not exist")
-	}
-
+	e := entity.ScanKeys(ctx, pattern, DefaultScanCount, func(key string) error {
+		ret = append(ret, key)
+		return nil
+	})
 	if e == redis.ErrClosed {
 		return ret, fmt.Errorf("can not get key value on the closed client")
 	}
 
-	return result, e
+	return ret, e
+}
+
+// scanKeys call fn for each key matched match on the server which client connected to
+func scanKeys(ctx context.Context, client *redis.Client, match string, count int64, fn func(key string) error) error {
+	if count < 1 {
+		count = DefaultScanCount
+	}
+
+	iter := client.Scan(ctx, 0, match, count).Iterator()
+	for iter.Next(ctx) {
+		if e := fn(iter.Val()); e != nil {
+			return e
+		}
+	}
+
+	return iter.Err()
 }
 
 // HKeys get all field's name in specified hash
@@ -509,4 +525,210 @@ func LLen(entity RedisEntity, ctx context.Context,key string)(int, error) {
 	}
 
 	return int(len), e
-}
\ No newline at end of file
+}
+
+// SetWithExpiration set the value of a key which will be expired after expiration
+func SetWithExpiration(entity RedisEntity, ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if entity == nil {
+		return fmt.Errorf("can not set key value on a nil entity")
+	}
+
+	_, e := entity.Set(ctx, key, value, expiration).Result()
+	if e == redis.ErrClosed {
+		return fmt.Errorf("can not set key value on the closed client")
+	}
+
+	return e
+}
+
+// Expire set a timeout on key. return false if the key does not exist
+func Expire(entity RedisEntity, ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	if entity == nil {
+		return false, fmt.Errorf("can not set timeout on nil entity")
+	}
+
+	result, e := entity.Expire(ctx, key, expiration).Result()
+	if e == redis.ErrClosed {
+		return false, fmt.Errorf("can not set timeout on the closed client")
+	}
+
+	return result, e
+}
+
+// TTL get the remaining time to live of a key.
+// -1 will be returned if the key has not an expiration, and -2 will be returned if the key does not exist
+func TTL(entity RedisEntity, ctx context.Context, key string) (time.Duration, error) {
+	if entity == nil {
+		return -2, fmt.Errorf("can not get ttl on nil entity")
+	}
+
+	result, e := entity.TTL(ctx, key).Result()
+	if e == redis.ErrClosed {
+		return -2, fmt.Errorf("can not get ttl on the closed client")
+	}
+
+	return result, e
+}
+
+// Pipelined send the commands queued by fn to the server in one round trip.
+// Transaction sends them in MULTI/EXEC if transaction is true
+func Pipelined(entity RedisEntity, ctx context.Context, transaction bool, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	if entity == nil {
+		return nil, fmt.Errorf("can not run pipeline on nil entity")
+	}
+
+	var cmds []redis.Cmder
+	var e error
+	if transaction {
+		cmds, e = entity.TxPipelined(ctx, fn)
+	} else {
+		cmds, e = entity.Pipelined(ctx, fn)
+	}
+	if e == redis.ErrClosed {
+		return cmds, fmt.Errorf("can not run pipeline on the closed client")
+	}
+
+	return cmds, e
+}
+
+// Publish post message to channel. return the number of subscribers which received the message
+func Publish(entity RedisEntity, ctx context.Context, channel string, message interface{}) (int, error) {
+	if entity == nil {
+		return 0, fmt.Errorf("can not publish message on nil entity")
+	}
+
+	result, e := entity.Publish(ctx, channel, message).Result()
+	if e == redis.ErrClosed {
+		return 0, fmt.Errorf("can not publish message on the closed client")
+	}
+
+	return int(result), e
+}
+
+// SubscribeFunc subscribe channels and call fn for each message received until ctx is done.
+// channels which end with "*" are subscribed as patterns.
+func SubscribeFunc(entity RedisEntity, ctx context.Context, fn func(msg *redis.Message), channels ...string) error {
+	if entity == nil {
+		return fmt.Errorf("can not subscribe channels on nil entity")
+	}
+
+	var names, patterns []string
+	for _, c := range channels {
+		if strings.HasSuffix(c, "*") {
+			patterns = append(patterns, c)
+		} else {
+			names = append(names, c)
+		}
+	}
+	if len(names) < 1 && len(patterns) < 1 {
+		return fmt.Errorf("no channel has be specified")
+	}
+
+	var pubsub *redis.PubSub
+	if len(names) > 0 {
+		pubsub = entity.Subscribe(ctx, names...)
+		if len(patterns) > 0 {
+			if e := pubsub.PSubscribe(ctx, patterns...); e != nil {
+				_ = pubsub.Close()
+				return e
+			}
+		}
+	} else {
+		pubsub = entity.PSubscribe(ctx, patterns...)
+	}
+	defer pubsub.Close()
+
+	if _, e := pubsub.Receive(ctx); e != nil {
+		return e
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return fmt.Errorf("subscription has be closed")
+			}
+			fn(msg)
+		}
+	}
+}
+
+// RunScript run the lua script by EVALSHA, the script will be loaded if it has not been loaded into the server.
+// nil will be returned as the result if the script returns nil
+func RunScript(entity RedisEntity, ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	if entity == nil {
+		return nil, fmt.Errorf("can not run script on nil entity")
+	}
+
+	result, e := script.Run(ctx, entity, keys, args...).Result()
+	if e == redis.Nil {
+		return nil, nil
+	}
+
+	if e == redis.ErrClosed {
+		return nil, fmt.Errorf("can not run script on the closed client")
+	}
+
+	return result, e
+}
+
+// ZAdd add member with score to the sorted set key, the score will be updated if the member exists
+func ZAdd(entity RedisEntity, ctx context.Context, key string, score float64, member interface{}) error {
+	if entity == nil {
+		return fmt.Errorf("can not add member on nil entity")
+	}
+
+	_, e := entity.ZAdd(ctx, key, &redis.Z{Score: score, Member: member}).Result()
+	if e == redis.ErrClosed {
+		return fmt.Errorf("can not add member on the closed client")
+	}
+
+	return e
+}
+
+// ZRem remove members from the sorted set key
+func ZRem(entity RedisEntity, ctx context.Context, key string, members ...interface{}) error {
+	if entity == nil {
+		return fmt.Errorf("can not remove members on nil entity")
+	}
+
+	_, e := entity.ZRem(ctx, key, members...).Result()
+	if e == redis.ErrClosed {
+		return fmt.Errorf("can not remove members on the closed client")
+	}
+
+	return e
+}
+
+// ZCard get the number of members in the sorted set key
+func ZCard(entity RedisEntity, ctx context.Context, key string) (int, error) {
+	if entity == nil {
+		return 0, fmt.Errorf("can not get number of members on nil entity")
+	}
+
+	result, e := entity.ZCard(ctx, key).Result()
+	if e == redis.ErrClosed {
+		return 0, fmt.Errorf("can not get number of members on the closed client")
+	}
+
+	return int(result), e
+}
+
+// ZRangeByScore get the members which score are between min and max in the sorted set key.
+// min and max can be "-inf", "+inf" or prefixed with "(" for exclusive. all members are returned if count is 0
+func ZRangeByScore(entity RedisEntity, ctx context.Context, key, min, max string, offset, count int64) ([]string, error) {
+	var ret []string
+	if entity == nil {
+		return ret, fmt.Errorf("can not get members on nil entity")
+	}
+
+	result, e := entity.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: count}).Result()
+	if e == redis.ErrClosed {
+		return ret, fmt.Errorf("can not get members on the closed client")
+	}
+
+	return result, e
+}
@@ -17,4 +17,20 @@
 
 package redis
 
-var DefaultMode int = 1
\ No newline at end of file
+import (
+	"errors"
+	"time"
+)
+
+var DefaultMode int = 1
+
+const (
+	// DefaultScanCount is the default hint of the number of keys returned by each SCAN command
+	DefaultScanCount int64 = 100
+
+	// DefaultLockRetryInterval is the default interval of trying to acquire a lock
+	DefaultLockRetryInterval = 100 * time.Millisecond
+)
+
+// ErrLockNotHeld is returned when releasing or refreshing a lock which is not held by the caller
+var ErrLockNotHeld = errors.New("lock is not held")
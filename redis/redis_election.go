@@ -0,0 +1,138 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// NewLeaderElector create an elector with conf. the candidate will not take part in the election until Run is called
+func NewLeaderElector(entity RedisEntity, conf LeaderElectionConfig) (*LeaderElector, error) {
+	conf.Identity = strings.TrimSpace(conf.Identity)
+	if conf.Identity == "" {
+		return nil, fmt.Errorf("identity of the candidate should not be empty")
+	}
+
+	if conf.TTL < time.Second {
+		return nil, fmt.Errorf("ttl of the leadership should not less than 1 second")
+	}
+
+	lock, e := newLockWithToken(entity, conf.Key, conf.Identity, conf.TTL)
+	if e != nil {
+		return nil, e
+	}
+
+	return &LeaderElector{conf: conf, lock: lock}, nil
+}
+
+// Run take part in the election until ctx is done. the candidate tries to acquire the leadership every TTL/3, and the
+// leader renews the leadership every TTL/3. the leader steps down if the leadership can not be renewed within 2/3 TTL,
+// so it stops the duties of the leader before the leadership expires and another candidate takes over it.
+// the leadership will be released when ctx is done, so another candidate can take over it quickly.
+func (le *LeaderElector) Run(ctx context.Context) {
+	interval := le.conf.TTL / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// the leadership is counted from sending the request, as it may be held before the response is received
+		start := time.Now()
+		ok, _ := le.lock.TryLock(ctx)
+		if ok {
+			le.lead(ctx, ticker, start)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsLeader return true if the candidate is the leader now
+func (le *LeaderElector) IsLeader() bool {
+	return le.isLeader.Load()
+}
+
+// GetLeader return the identity of the current leader. empty string will be returned if there is not a leader
+func (le *LeaderElector) GetLeader(ctx context.Context) (string, error) {
+	leader, e := le.lock.entity.Get(ctx, le.lock.key).Result()
+	if e == redis.Nil {
+		return "", nil
+	}
+
+	return leader, e
+}
+
+// lead run the callbacks and renew the leadership until the leadership is lost or ctx is done. lastRenew is the time
+// when the request of acquiring the leadership was sent
+func (le *LeaderElector) lead(ctx context.Context, ticker *time.Ticker, lastRenew time.Time) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	le.isLeader.Store(true)
+	if le.conf.OnStartedLeading != nil {
+		go le.conf.OnStartedLeading(leaderCtx)
+	}
+
+	defer func() {
+		cancel()
+		le.isLeader.Store(false)
+		if le.conf.OnStoppedLeading != nil {
+			le.conf.OnStoppedLeading()
+		}
+	}()
+
+	// the leader steps down at 2/3 TTL after the last renewal, and a renewal can not last beyond that time
+	deadline := lastRenew.Add(le.conf.TTL * 2 / 3)
+	stepDown := time.NewTimer(time.Until(deadline))
+	defer stepDown.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			releaseCtx, releaseCancel := context.WithTimeout(context.Background(), le.conf.TTL/3)
+			_ = le.lock.Unlock(releaseCtx)
+			releaseCancel()
+			return
+		case <-stepDown.C:
+			return
+		case <-ticker.C:
+		}
+
+		renewStart := time.Now()
+		renewCtx, renewCancel := context.WithDeadline(ctx, deadline)
+		e := le.lock.Refresh(renewCtx, le.conf.TTL)
+		renewCancel()
+		if e == ErrLockNotHeld || !time.Now().Before(deadline) {
+			return
+		}
+		if e != nil {
+			continue
+		}
+
+		deadline = renewStart.Add(le.conf.TTL * 2 / 3)
+		if !stepDown.Stop() {
+			<-stepDown.C
+		}
+		stepDown.Reset(time.Until(deadline))
+	}
+}
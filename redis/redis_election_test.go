@@ -0,0 +1,125 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// electionEntity keeps the lock of the election in memory. the renewals fail while failRefresh is set
+type electionEntity struct {
+	RedisEntity
+
+	mu          sync.Mutex
+	holder      string
+	failRefresh bool
+	lastRefresh time.Time
+}
+
+func (e *electionEntity) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cmd := redis.NewBoolCmd(ctx)
+	if e.holder != "" {
+		cmd.SetVal(false)
+		return cmd
+	}
+
+	e.holder = value.(string)
+	e.lastRefresh = time.Now()
+	cmd.SetVal(true)
+	return cmd
+}
+
+func (e *electionEntity) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cmd := redis.NewCmd(ctx)
+	if e.holder != args[0].(string) {
+		cmd.SetVal(int64(0))
+		return cmd
+	}
+
+	switch sha1 {
+	case refreshScript.Hash():
+		if e.failRefresh {
+			cmd.SetErr(errors.New("connection refused"))
+			return cmd
+		}
+		e.lastRefresh = time.Now()
+	case unlockScript.Hash():
+		e.holder = ""
+	}
+	cmd.SetVal(int64(1))
+	return cmd
+}
+
+func (e *electionEntity) setFailRefresh(fail bool) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failRefresh = fail
+	return e.lastRefresh
+}
+
+func TestLeaderElectorStepDown(t *testing.T) {
+	ttl := 1500 * time.Millisecond
+	entity := &electionEntity{}
+	stopped := make(chan time.Time, 1)
+	le, e := NewLeaderElector(entity, LeaderElectionConfig{
+		Key:              "election",
+		Identity:         "candidate",
+		TTL:              ttl,
+		OnStartedLeading: func(ctx context.Context) {},
+		OnStoppedLeading: func() { stopped <- time.Now() },
+	})
+	if e != nil {
+		t.Fatalf("create elector error: %s", e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go le.Run(ctx)
+
+	// wait for the leadership and at least one renewal
+	time.Sleep(ttl / 2)
+	if !le.IsLeader() {
+		t.Fatalf("candidate should be the leader")
+	}
+
+	lastRefresh := entity.setFailRefresh(true)
+	select {
+	case at := <-stopped:
+		if held := at.Sub(lastRefresh); held >= ttl*5/6 {
+			t.Errorf("leader stepped down %s after the last renewal, want less than %s", held, ttl*5/6)
+		}
+	case <-time.After(2 * ttl):
+		t.Fatalf("leader did not step down after the renewals failed")
+	}
+	if le.IsLeader() {
+		t.Errorf("candidate should not be the leader after stepping down")
+	}
+}
@@ -0,0 +1,157 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// unlockScript delete the key of the lock only if it is held by the token
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// refreshScript extend the ttl of the lock only if it is held by the token
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// NewLock create a lock on key. the lock will be released automatically after ttl if it is not refreshed
+func NewLock(entity RedisEntity, key string, ttl time.Duration) (*Lock, error) {
+	token, e := newToken()
+	if e != nil {
+		return nil, e
+	}
+
+	return newLockWithToken(entity, key, token, ttl)
+}
+
+// TryLock try to acquire the lock once. return true if the lock has been acquired
+func (l *Lock) TryLock(ctx context.Context) (bool, error) {
+	ok, e := l.entity.SetNX(ctx, l.key, l.token, l.ttl).Result()
+	if e == redis.ErrClosed {
+		return false, fmt.Errorf("can not acquire lock on the closed client")
+	}
+
+	return ok, e
+}
+
+// Lock try to acquire the lock every retryInterval until it has been acquired or ctx is done
+func (l *Lock) Lock(ctx context.Context, retryInterval time.Duration) error {
+	if retryInterval <= 0 {
+		retryInterval = DefaultLockRetryInterval
+	}
+
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		ok, e := l.TryLock(ctx)
+		if e != nil {
+			return e
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Unlock release the lock. ErrLockNotHeld will be returned if the lock is not held by l
+func (l *Lock) Unlock(ctx context.Context) error {
+	result, e := RunScript(l.entity, ctx, unlockScript, []string{l.key}, l.token)
+	if e != nil {
+		return e
+	}
+
+	if n, ok := result.(int64); !ok || n < 1 {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
+
+// Refresh extend the lock to ttl from now. ErrLockNotHeld will be returned if the lock is not held by l
+func (l *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = l.ttl
+	}
+
+	result, e := RunScript(l.entity, ctx, refreshScript, []string{l.key}, l.token, ttl.Milliseconds())
+	if e != nil {
+		return e
+	}
+
+	if n, ok := result.(int64); !ok || n < 1 {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
+
+// Key return the key of the lock
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token return the token which identifies the holder of the lock
+func (l *Lock) Token() string {
+	return l.token
+}
+
+func newLockWithToken(entity RedisEntity, key, token string, ttl time.Duration) (*Lock, error) {
+	key = strings.TrimSpace(key)
+	if entity == nil {
+		return nil, fmt.Errorf("can not create lock on nil entity")
+	}
+
+	if key == "" || token == "" {
+		return nil, fmt.Errorf("key and token of lock should not be empty")
+	}
+
+	if ttl < time.Millisecond {
+		return nil, fmt.Errorf("ttl of lock should not less than 1 millisecond")
+	}
+
+	return &Lock{entity: entity, key: key, token: token, ttl: ttl}, nil
+}
+
+// newToken generate a random token
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, e := rand.Read(b); e != nil {
+		return "", fmt.Errorf("generate token error: %s", e)
+	}
+
+	return hex.EncodeToString(b), nil
+}
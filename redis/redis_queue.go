@@ -0,0 +1,145 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// queuePopScript move the items whose visibility timeout have expired from the processing set back to the tail of the
+// pending list, then pop an item from the tail of the pending list and add it into the processing set with its deadline.
+// KEYS[1] is the pending list, KEYS[2] is the processing set. ARGV[1] is now, ARGV[2] is the deadline of the popped item
+var queuePopScript = redis.NewScript(`
+local expired = redis.call("ZRANGEBYSCORE", KEYS[2], "-inf", ARGV[1])
+for _, item in ipairs(expired) do
+	redis.call("ZREM", KEYS[2], item)
+	redis.call("RPUSH", KEYS[1], item)
+end
+local item = redis.call("RPOP", KEYS[1])
+if item then
+	redis.call("ZADD", KEYS[2], ARGV[2], item)
+end
+return item`)
+
+// queueRebuildScript replace the items in the pending list with ARGV. the items which are being processed are skipped.
+// KEYS[1] is the pending list, KEYS[2] is the processing set
+var queueRebuildScript = redis.NewScript(`
+redis.call("DEL", KEYS[1])
+for _, item in ipairs(ARGV) do
+	if not redis.call("ZSCORE", KEYS[2], item) then
+		redis.call("LPUSH", KEYS[1], item)
+	end
+end
+return redis.call("LLEN", KEYS[1])`)
+
+// NewReliableQueue create a FIFO queue named name. an item popped from the queue is kept in the processing set until
+// it is acked, and it will be returned to the queue if it has not been acked in visibility.
+// the keys of the queue are hash tagged with name, so they are stored on the same node in cluster mode
+func NewReliableQueue(entity RedisEntity, name string, visibility time.Duration) (*ReliableQueue, error) {
+	name = strings.TrimSpace(name)
+	if entity == nil {
+		return nil, fmt.Errorf("can not create queue on nil entity")
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("name of queue should not be empty")
+	}
+
+	if visibility < time.Millisecond {
+		return nil, fmt.Errorf("visibility timeout should not less than 1 millisecond")
+	}
+
+	return &ReliableQueue{entity: entity, name: name, visibility: visibility}, nil
+}
+
+// Push add items to the head of the queue
+func (q *ReliableQueue) Push(ctx context.Context, items ...string) error {
+	if len(items) < 1 {
+		return nil
+	}
+
+	values := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		values = append(values, item)
+	}
+
+	return LPush(q.entity, ctx, q.pendingKey(), values...)
+}
+
+// Pop take an item from the tail of the queue. empty string will be returned if the queue is empty.
+// the item should be acked after it has been processed, otherwise it will be popped again after the visibility timeout
+func (q *ReliableQueue) Pop(ctx context.Context) (string, error) {
+	now := time.Now()
+	result, e := RunScript(q.entity, ctx, queuePopScript, []string{q.pendingKey(), q.processingKey()}, now.UnixMilli(), now.Add(q.visibility).UnixMilli())
+	if e != nil || result == nil {
+		return "", e
+	}
+
+	item, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected item type %T popped from queue %s", result, q.name)
+	}
+
+	return item, nil
+}
+
+// Ack remove item from the processing set after it has been processed
+func (q *ReliableQueue) Ack(ctx context.Context, item string) error {
+	return ZRem(q.entity, ctx, q.processingKey(), item)
+}
+
+// Len return the number of the items which are waiting to be popped
+func (q *ReliableQueue) Len(ctx context.Context) (int, error) {
+	return LLen(q.entity, ctx, q.pendingKey())
+}
+
+// Rebuild replace the pending items with items which are in FIFO order. the items which are being processed are
+// not added to the queue again. it is used to rebuild the queue from the source of truth
+func (q *ReliableQueue) Rebuild(ctx context.Context, items []string) error {
+	args := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		args = append(args, item)
+	}
+
+	_, e := RunScript(q.entity, ctx, queueRebuildScript, []string{q.pendingKey(), q.processingKey()}, args...)
+
+	return e
+}
+
+// Clear remove all items of the queue including the items which are being processed
+func (q *ReliableQueue) Clear(ctx context.Context) error {
+	return Del(q.entity, ctx, q.pendingKey(), q.processingKey())
+}
+
+// Key return the key with the hash tag of the queue. it is used to store data related with the queue on the same node
+func (q *ReliableQueue) Key(suffix string) string {
+	return "{" + q.name + "}:" + suffix
+}
+
+func (q *ReliableQueue) pendingKey() string {
+	return q.Key("pending")
+}
+
+func (q *ReliableQueue) processingKey() string {
+	return q.Key("processing")
+}
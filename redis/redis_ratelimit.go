@@ -0,0 +1,104 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// rateLimitScript remove the events out of the window, then record a new event if the number of events in the window
+// is less than the limit. return 1 if the event is allowed, otherwise return 0
+var rateLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+if redis.call("ZCARD", KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], window)
+	return 1
+end
+return 0`)
+
+// NewRateLimiter create a limiter which allows limit events of an ID in window.
+// the events of an ID are recorded in key prefix:ID
+func NewRateLimiter(entity RedisEntity, prefix string, limit int, window time.Duration) (*RateLimiter, error) {
+	prefix = strings.TrimSpace(prefix)
+	if entity == nil {
+		return nil, fmt.Errorf("can not create rate limiter on nil entity")
+	}
+
+	if prefix == "" {
+		return nil, fmt.Errorf("prefix of rate limiter should not be empty")
+	}
+
+	if limit < 1 || window < time.Millisecond {
+		return nil, fmt.Errorf("limit should large than 0 and window should not less than 1 millisecond")
+	}
+
+	return &RateLimiter{entity: entity, prefix: prefix, limit: limit, window: window}, nil
+}
+
+// Allow record an event of id and return true if it is allowed. the event is not recorded if it is not allowed.
+// the time of the event is got from the local clock, so the clocks of the hosts sharing the limiter should be synchronized
+func (l *RateLimiter) Allow(ctx context.Context, id string) (bool, error) {
+	token, e := newToken()
+	if e != nil {
+		return false, e
+	}
+
+	now := time.Now().UnixMilli()
+	member := strconv.FormatInt(now, 10) + "-" + token
+	result, e := RunScript(l.entity, ctx, rateLimitScript, []string{l.key(id)}, now, l.window.Milliseconds(), l.limit, member)
+	if e != nil {
+		return false, e
+	}
+
+	n, ok := result.(int64)
+
+	return ok && n == 1, nil
+}
+
+// Remaining return the number of events which are allowed for id in the current window
+func (l *RateLimiter) Remaining(ctx context.Context, id string) (int, error) {
+	min := "(" + strconv.FormatInt(time.Now().Add(-l.window).UnixMilli(), 10)
+	events, e := l.entity.ZRangeByScore(ctx, l.key(id), &redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+	if e != nil {
+		return 0, e
+	}
+
+	if len(events) >= l.limit {
+		return 0, nil
+	}
+
+	return l.limit - len(events), nil
+}
+
+// Reset remove all events of id
+func (l *RateLimiter) Reset(ctx context.Context, id string) error {
+	return Del(l.entity, ctx, l.key(id))
+}
+
+func (l *RateLimiter) key(id string) string {
+	return l.prefix + ":" + id
+}
@@ -102,4 +102,104 @@ func (r RedisSentinel) RPop(ctx context.Context, key string) *redis.StringCmd{
 
 func (r RedisSentinel) LLen(ctx context.Context, key string) *redis.IntCmd{
 	return r.Client.LLen(ctx,key)
-}
\ No newline at end of file
+}
+
+func (r RedisSentinel) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.Expire(ctx, key, expiration)
+}
+
+func (r RedisSentinel) PExpire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.PExpire(ctx, key, expiration)
+}
+
+func (r RedisSentinel) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	return r.Client.TTL(ctx, key)
+}
+
+func (r RedisSentinel) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.SetNX(ctx, key, value, expiration)
+}
+
+func (r RedisSentinel) ScanKeys(ctx context.Context, match string, count int64, fn func(key string) error) error {
+	return scanKeys(ctx, r.Client, match, count, fn)
+}
+
+func (r RedisSentinel) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return r.Client.Pipelined(ctx, fn)
+}
+
+func (r RedisSentinel) TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return r.Client.TxPipelined(ctx, fn)
+}
+
+func (r RedisSentinel) Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	return r.Client.Watch(ctx, fn, keys...)
+}
+
+func (r RedisSentinel) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	return r.Client.Publish(ctx, channel, message)
+}
+
+func (r RedisSentinel) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return r.Client.Subscribe(ctx, channels...)
+}
+
+func (r RedisSentinel) PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub {
+	return r.Client.PSubscribe(ctx, patterns...)
+}
+
+func (r RedisSentinel) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	return r.Client.Eval(ctx, script, keys, args...)
+}
+
+func (r RedisSentinel) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	return r.Client.EvalSha(ctx, sha1, keys, args...)
+}
+
+func (r RedisSentinel) ScriptExists(ctx context.Context, hashes ...string) *redis.BoolSliceCmd {
+	return r.Client.ScriptExists(ctx, hashes...)
+}
+
+func (r RedisSentinel) ScriptLoad(ctx context.Context, script string) *redis.StringCmd {
+	return r.Client.ScriptLoad(ctx, script)
+}
+
+func (r RedisSentinel) ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd {
+	return r.Client.ZAdd(ctx, key, members...)
+}
+
+func (r RedisSentinel) ZIncrBy(ctx context.Context, key string, increment float64, member string) *redis.FloatCmd {
+	return r.Client.ZIncrBy(ctx, key, increment, member)
+}
+
+func (r RedisSentinel) ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return r.Client.ZRem(ctx, key, members...)
+}
+
+func (r RedisSentinel) ZRemRangeByScore(ctx context.Context, key, min, max string) *redis.IntCmd {
+	return r.Client.ZRemRangeByScore(ctx, key, min, max)
+}
+
+func (r RedisSentinel) ZCard(ctx context.Context, key string) *redis.IntCmd {
+	return r.Client.ZCard(ctx, key)
+}
+
+func (r RedisSentinel) ZScore(ctx context.Context, key, member string) *redis.FloatCmd {
+	return r.Client.ZScore(ctx, key, member)
+}
+
+func (r RedisSentinel) ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	return r.Client.ZRange(ctx, key, start, stop)
+}
+
+func (r RedisSentinel) ZRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd {
+	return r.Client.ZRangeWithScores(ctx, key, start, stop)
+}
+
+func (r RedisSentinel) ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	return r.Client.ZRangeByScore(ctx, key, opt)
+}
+
+func (r RedisSentinel) ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd {
+	return r.Client.ZRevRangeWithScores(ctx, key, start, stop)
+}
@@ -102,4 +102,104 @@ func (r RedisSingle) RPop(ctx context.Context, key string) *redis.StringCmd{
 
 func (r RedisSingle) LLen(ctx context.Context, key string) *redis.IntCmd{
 	return r.Client.LLen(ctx,key)
-}
\ No newline at end of file
+}
+
+func (r RedisSingle) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.Expire(ctx, key, expiration)
+}
+
+func (r RedisSingle) PExpire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.PExpire(ctx, key, expiration)
+}
+
+func (r RedisSingle) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	return r.Client.TTL(ctx, key)
+}
+
+func (r RedisSingle) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return r.Client.SetNX(ctx, key, value, expiration)
+}
+
+func (r RedisSingle) ScanKeys(ctx context.Context, match string, count int64, fn func(key string) error) error {
+	return scanKeys(ctx, r.Client, match, count, fn)
+}
+
+func (r RedisSingle) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return r.Client.Pipelined(ctx, fn)
+}
+
+func (r RedisSingle) TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return r.Client.TxPipelined(ctx, fn)
+}
+
+func (r RedisSingle) Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	return r.Client.Watch(ctx, fn, keys...)
+}
+
+func (r RedisSingle) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	return r.Client.Publish(ctx, channel, message)
+}
+
+func (r RedisSingle) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return r.Client.Subscribe(ctx, channels...)
+}
+
+func (r RedisSingle) PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub {
+	return r.Client.PSubscribe(ctx, patterns...)
+}
+
+func (r RedisSingle) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	return r.Client.Eval(ctx, script, keys, args...)
+}
+
+func (r RedisSingle) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	return r.Client.EvalSha(ctx, sha1, keys, args...)
+}
+
+func (r RedisSingle) ScriptExists(ctx context.Context, hashes ...string) *redis.BoolSliceCmd {
+	return r.Client.ScriptExists(ctx, hashes...)
+}
+
+func (r RedisSingle) ScriptLoad(ctx context.Context, script string) *redis.StringCmd {
+	return r.Client.ScriptLoad(ctx, script)
+}
+
+func (r RedisSingle) ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd {
+	return r.Client.ZAdd(ctx, key, members...)
+}
+
+func (r RedisSingle) ZIncrBy(ctx context.Context, key string, increment float64, member string) *redis.FloatCmd {
+	return r.Client.ZIncrBy(ctx, key, increment, member)
+}
+
+func (r RedisSingle) ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return r.Client.ZRem(ctx, key, members...)
+}
+
+func (r RedisSingle) ZRemRangeByScore(ctx context.Context, key, min, max string) *redis.IntCmd {
+	return r.Client.ZRemRangeByScore(ctx, key, min, max)
+}
+
+func (r RedisSingle) ZCard(ctx context.Context, key string) *redis.IntCmd {
+	return r.Client.ZCard(ctx, key)
+}
+
+func (r RedisSingle) ZScore(ctx context.Context, key, member string) *redis.FloatCmd {
+	return r.Client.ZScore(ctx, key, member)
+}
+
+func (r RedisSingle) ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	return r.Client.ZRange(ctx, key, start, stop)
+}
+
+func (r RedisSingle) ZRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd {
+	return r.Client.ZRangeWithScores(ctx, key, start, stop)
+}
+
+func (r RedisSingle) ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	return r.Client.ZRangeByScore(ctx, key, opt)
+}
+
+func (r RedisSingle) ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd {
+	return r.Client.ZRevRangeWithScores(ctx, key, start, stop)
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 )
 
 var (
@@ -371,3 +372,118 @@ func TestLPop(t *testing.T) {
 	}
 	t.Logf("popped element from the list is %s\n", s)
 }
+
+func TestLock(t *testing.T) {
+	if entity == nil {
+		tmpEntiy, e := connectRedis(t)
+		if e != nil {
+			t.Log("can not connect to redis server")
+			os.Exit(2)
+		}
+		entity = tmpEntiy
+	}
+
+	first, e := NewLock(entity, "/locks/test", 10*time.Second)
+	if e != nil {
+		t.Errorf("create lock error %s", e)
+		return
+	}
+	second, _ := NewLock(entity, "/locks/test", 10*time.Second)
+
+	if ok, e := first.TryLock(ctx); !ok || e != nil {
+		t.Errorf("first lock should be acquired. error %v", e)
+		return
+	}
+
+	if ok, _ := second.TryLock(ctx); ok {
+		t.Errorf("second lock should not be acquired while the first one is held")
+	}
+
+	if e := second.Unlock(ctx); e != ErrLockNotHeld {
+		t.Errorf("unlock a lock which is not held should return ErrLockNotHeld, got %v", e)
+	}
+
+	if e := first.Unlock(ctx); e != nil {
+		t.Errorf("unlock error %s", e)
+		return
+	}
+
+	t.Log("lock has be acquired and released")
+}
+
+func TestRateLimiter(t *testing.T) {
+	if entity == nil {
+		tmpEntiy, e := connectRedis(t)
+		if e != nil {
+			t.Log("can not connect to redis server")
+			os.Exit(2)
+		}
+		entity = tmpEntiy
+	}
+
+	limiter, e := NewRateLimiter(entity, "/ratelimit/test", 3, time.Minute)
+	if e != nil {
+		t.Errorf("create rate limiter error %s", e)
+		return
+	}
+	_ = limiter.Reset(ctx, "user1")
+
+	for i := 0; i < 3; i++ {
+		if ok, e := limiter.Allow(ctx, "user1"); !ok || e != nil {
+			t.Errorf("event %d should be allowed. error %v", i, e)
+			return
+		}
+	}
+
+	if ok, _ := limiter.Allow(ctx, "user1"); ok {
+		t.Errorf("the fourth event should not be allowed")
+	}
+
+	t.Log("rate limiter works")
+}
+
+func TestReliableQueue(t *testing.T) {
+	if entity == nil {
+		tmpEntiy, e := connectRedis(t)
+		if e != nil {
+			t.Log("can not connect to redis server")
+			os.Exit(2)
+		}
+		entity = tmpEntiy
+	}
+
+	queue, e := NewReliableQueue(entity, "/queue/test", 100*time.Millisecond)
+	if e != nil {
+		t.Errorf("create queue error %s", e)
+		return
+	}
+	_ = queue.Clear(ctx)
+
+	if e = queue.Push(ctx, "1", "2"); e != nil {
+		t.Errorf("push items error %s", e)
+		return
+	}
+
+	item, e := queue.Pop(ctx)
+	if item != "1" || e != nil {
+		t.Errorf("the first item popped should be 1, got %s error %v", item, e)
+		return
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	item, e = queue.Pop(ctx)
+	if item != "1" || e != nil {
+		t.Errorf("the item which has not been acked should be popped again, got %s error %v", item, e)
+		return
+	}
+	_ = queue.Ack(ctx, item)
+
+	item, _ = queue.Pop(ctx)
+	_ = queue.Ack(ctx, item)
+	if item, _ = queue.Pop(ctx); item != "" {
+		t.Errorf("queue should be empty, got %s", item)
+		return
+	}
+
+	t.Log("reliable queue works")
+}
@@ -19,6 +19,7 @@ package redis
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	redis "github.com/go-redis/redis/v8"
@@ -50,6 +51,44 @@ type RedisEntity interface {
 	LPop(ctx context.Context, key string) *redis.StringCmd
 	RPop(ctx context.Context, key string) *redis.StringCmd
 	LLen(ctx context.Context, key string) *redis.IntCmd
+
+	// keys expiration
+	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	PExpire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	TTL(ctx context.Context, key string) *redis.DurationCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+
+	// ScanKeys call fn for each key matched match by SCAN command. count is the hint of the number of keys per SCAN.
+	// all master nodes are scanned in cluster mode.
+	ScanKeys(ctx context.Context, match string, count int64, fn func(key string) error) error
+
+	// pipelines and transactions
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error
+
+	// pub/sub
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+	PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub
+
+	// lua scripts. RedisEntity implements redis.Scripter with these methods, so redis.Script can be run on it
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd
+	ScriptExists(ctx context.Context, hashes ...string) *redis.BoolSliceCmd
+	ScriptLoad(ctx context.Context, script string) *redis.StringCmd
+
+	// sorted sets
+	ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd
+	ZIncrBy(ctx context.Context, key string, increment float64, member string) *redis.FloatCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	ZRemRangeByScore(ctx context.Context, key, min, max string) *redis.IntCmd
+	ZCard(ctx context.Context, key string) *redis.IntCmd
+	ZScore(ctx context.Context, key, member string) *redis.FloatCmd
+	ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	ZRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd
+	ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd
+	ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd
  }
 
 // connection parameters for client.
@@ -84,3 +123,54 @@ type ClientConf struct {
 	// tls parameters for agent when agent running as daemon.
 	Tls config.Tls `form:"tls" json:"tls" yaml:"tls" xml:"tls"`
 }
+
+// Lock is a distributed lock based on a redis key. the lock is held by the one who has set the key to its token
+type Lock struct {
+	entity RedisEntity
+	key    string
+	token  string
+	ttl    time.Duration
+}
+
+// RateLimiter limits the number of events of an ID in a sliding window. events are recorded in a sorted set per ID
+type RateLimiter struct {
+	entity RedisEntity
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+// ReliableQueue is a FIFO queue which items are pushed into a list and the popped items are kept in a sorted set
+// scored by their deadline until they are acked
+type ReliableQueue struct {
+	entity     RedisEntity
+	name       string
+	visibility time.Duration
+}
+
+// LeaderElectionConfig is the configuration of leader election
+type LeaderElectionConfig struct {
+	// the key of the lock which the leader holds
+	Key string
+
+	// identity of the candidate, such as hostname and pid. it should be unique among the candidates
+	Identity string
+
+	// the duration which the leadership is kept without renewing. the leader renews it every TTL/3 and steps down
+	// if it is not renewed within 2/3 TTL
+	TTL time.Duration
+
+	// OnStartedLeading is called in a new goroutine when the candidate becomes the leader.
+	// ctx will be canceled when the leadership is lost
+	OnStartedLeading func(ctx context.Context)
+
+	// OnStoppedLeading is called when the candidate loses the leadership
+	OnStoppedLeading func()
+}
+
+// LeaderElector elects a leader among the candidates which use the same key
+type LeaderElector struct {
+	conf     LeaderElectionConfig
+	lock     *Lock
+	isLeader atomic.Bool
+}
\ No newline at end of file
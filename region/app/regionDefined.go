@@ -17,6 +17,10 @@
 
 package app
 
+import (
+	sysadmObjects "sysadm/objects/app"
+)
+
 var defaultObjectName = "region"
 var defaultTableName = "country"
 var defaultPkName = "code"
@@ -29,6 +33,13 @@ var defaultCountyTable = "county"
 var defaultCountyPkName = "code"
 var DefaultApiVersion = "1.0"
 
+// repositories of the regions which are lower than country
+var (
+	provinceRepository = sysadmObjects.NewRepository[ProvinceSchema]("province", defaultProvinceTable, defaultProvincePkName)
+	cityRepository     = sysadmObjects.NewRepository[CitySchema]("city", defaultCityTable, defaultCityPkName)
+	countyRepository   = sysadmObjects.NewRepository[CountySchema]("county", defaultCountyTable, defaultCountyPkName)
+)
+
 const (
 	CountryUndisplay string = "0"
 	CountryDisplay   string = "1"
@@ -26,12 +26,7 @@ import (
 )
 
 type Region struct {
-	// object name. the value should be "region"
-	Name string
-	// table name which hold host data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[CountrySchema]
 }
 
 // 国家表结构
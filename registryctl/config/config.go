@@ -1096,6 +1096,27 @@ func getSysadmInsecureSkipVerify(confContent *Config) bool{
 	return defaultConfig.Sysadm.Server.InsecureSkipVerify
 }
 
+/*
+  Getting the interval(in minutes) of evaluating the retention policies from environment or configuration file.
+  the retention policies are not evaluated on schedule if the interval is negative.
+  return the default interval if it is not set or zero.
+*/
+func getRetentionInterval(confContent *Config) int{
+	retentionInterval := os.Getenv("RETENTIONINTERVAL")
+	if retentionInterval != ""{
+		interval, err := strconv.Atoi(retentionInterval)
+		if err == nil && interval != 0 {
+			return interval
+		}
+	}
+
+	if confContent != nil && confContent.Retention.Interval != 0 {
+		return confContent.Retention.Interval
+	}
+
+	return defaultConfig.Retention.Interval
+}
+
 /*
   Try to get the values of items of configuration from OS variables ,configuratio file or default value.
   The value of a item will be come from OS variables first ,then come from configuration file and last come from default value.
@@ -1304,6 +1325,9 @@ func HandleConfig(configPath string, cmdRunPath string) (*Config,[]sysadmerror.S
 		errs = appendErrs(errs,err)
 	}
 
+	ConfigDefined.Retention.Interval = getRetentionInterval(confContent)
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(201072,"debug","retention policies are evaluated every %d minutes",ConfigDefined.Retention.Interval))
+
 	return &ConfigDefined,errs
 }
 
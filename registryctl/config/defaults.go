@@ -58,3 +58,4 @@ var RegistryKey = ""
 var RegistryCert = ""
 var RegistryUsername = "sysadm_registry_user"
 var RegistryPassword = "sysadm_registry_password"
+var DefaultRetentionInterval = 1440                  // minutes between the evaluations of the retention policies
@@ -87,6 +87,12 @@ type Sysadm struct {
 	Server SysadmServer `json:"server"`
 }
 
+// Retention is the configurations of the retention policies of the tags
+type Retention struct {
+	// the retention policies are evaluated every Interval minutes. they are not evaluated on schedule if it is negative
+	Interval int `json:"interval"`
+}
+
 type Config struct {
 	SysadmVersion string 
 	RegistryctlVer string `json:"version"`
@@ -100,6 +106,7 @@ type Config struct {
 	DB DB `json:"db"`
 	Registry Registry `json:"registry"`
 	Sysadm Sysadm `json:"sysadmserver"`
+	Retention Retention `json:"retention"`
 }
 
 var DefinedConfig Config = Config{}
@@ -160,4 +167,7 @@ var defaultConfig Config = Config{
 			InsecureSkipVerify: SysadmInsecureSkipVerify,
 		},
 	},
+	Retention: Retention{
+		Interval: DefaultRetentionInterval,
+	},
 }
\ No newline at end of file
@@ -22,7 +22,6 @@ package server
 import (
 	"crypto/tls"
 	"encoding/base64"
-	"encoding/json"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -107,7 +106,7 @@ func addReqestHeader(r *requestParams, req *http.Request) []sysadmerror.Sysadmer
 	}
 	r.headers = append(r.headers, defaultHeaders...)
 
-	for _, h := range headers {
+	for _, h := range r.headers {
 		if h.key != "" {
 			errs = append(errs, sysadmerror.NewErrorWithStringLevel(202017, "debug", "adding key: %s value %s to the header of the request", h.key, h.value))
 			req.Header.Set(h.key, h.value)
@@ -161,12 +160,20 @@ func handleQueryData(r *requestParams) (string, []sysadmerror.Sysadmerror) {
 }
 
 func sendRequest(r *requestParams) ([]byte, []sysadmerror.Sysadmerror) {
+	body, _, errs := doRequest(r)
+
+	return body, errs
+}
+
+// doRequest sends the request and returns the body and the response whose body has been read and closed.
+// the response is nil if the request can not be sent
+func doRequest(r *requestParams) ([]byte, *http.Response, []sysadmerror.Sysadmerror) {
 	var errs []sysadmerror.Sysadmerror
 	var body []byte
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(202024, "debug", "now handling the request"))
 	if r == nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(202025, "fatal", "can not handling a nil request"))
-		return body, errs
+		return body, nil, errs
 	}
 
 	fatalLevel := sysadmerror.GetLevelNum("fatal")
@@ -177,7 +184,7 @@ func sendRequest(r *requestParams) ([]byte, []sysadmerror.Sysadmerror) {
 		maxLevel := sysadmerror.GetMaxLevel(err)
 		errs = appendErrs(errs, err)
 		if maxLevel >= fatalLevel {
-			return body, errs
+			return body, nil, errs
 		}
 		r.url = r.url + "?" + query
 		//bodyReader = strings.NewReader(query)
@@ -198,36 +205,36 @@ func sendRequest(r *requestParams) ([]byte, []sysadmerror.Sysadmerror) {
 
 	if err != nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(202026, "fatal", "can not create a new request, error: %s", err))
-		return body, errs
+		return body, nil, errs
 	}
 	e := addReqestHeader(r, req)
 	errs = appendErrs(errs, e)
 	maxLevel := sysadmerror.GetMaxLevel(errs)
 	if maxLevel >= fatalLevel {
-		return body, errs
+		return body, nil, errs
 	}
 
 	e = setBasicAuth(req)
 	errs = appendErrs(errs, e)
 	maxLevel = sysadmerror.GetMaxLevel(errs)
 	if maxLevel >= fatalLevel {
-		return body, errs
+		return body, nil, errs
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(202027, "fatal", "can not send request, error: %s", err))
-		return body, errs
+		return body, nil, errs
 	}
 	defer resp.Body.Close()
 
 	body, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(202028, "fatal", "can not gets reponse body contenet, error: %s", err))
-		return body, errs
+		return body, resp, errs
 	}
 
-	return body, errs
+	return body, resp, errs
 }
 
 func getRegistryUrl(c *sysadmServer.Context) string {
@@ -386,93 +393,91 @@ func buildModifyReponse(c *sysadmServer.Context) func(r *http.Response) error {
 	}
 }
 
+// verifyManifestAndLayerWithDB records the image and the tag in DB if they have not been recorded when a manifest
+// is pulled by a client, otherwise increases the pull times of them. the manifests pulled by digest are the child
+// manifests of manifest lists or indexes normally, which are recorded with their parents
 func verifyManifestAndLayerWithDB(r *http.Response) error {
-	uri := r.Request.RequestURI
-	if strings.TrimSpace(uri) != "" {
-		uriArray := strings.Split(uri, "/")
-		uriLen := len(uriArray)
-		if strings.ToLower(uriArray[(uriLen-2)]) == "manifests" { // pull manifests of a image by a client
-			// Get imageName form uri
-			imageName := ""
-			for i := 2; i < (uriLen - 2); i++ {
-				if imageName == "" {
-					imageName = uriArray[i]
-				} else {
-					imageName = imageName + "/" + uriArray[i]
-				}
-			}
+	uri := strings.SplitN(r.Request.RequestURI, "?", 2)[0]
+	if strings.TrimSpace(uri) == "" {
+		return nil
+	}
 
-			imgSets, _ := getImageInfoFromDB("", "", imageName, "", 0, 0)
-			if len(imgSets) < 1 { // if there is not the information of the image for imageName in DB
-				reference := uriArray[(uriLen - 1)]
-				manifest := getManifests(imageName, reference)
-				username, _, _ := r.Request.BasicAuth()
-				if username == "" {
-					username = "admin"
-				}
-				image := image{
-					username:     username,
-					name:         imageName,
-					size:         0,
-					tag:          manifest.Tag,
-					architecture: manifest.Architecture,
-					digest:       "",
-					blobs:        []blob{},
-				}
+	uriArray := strings.Split(uri, "/")
+	uriLen := len(uriArray)
+	if uriLen < 4 || strings.ToLower(uriArray[(uriLen-2)]) != "manifests" { // pull manifests of a image by a client
+		return nil
+	}
 
-				processImages[imageName] = image
-				updataImage(imageName)
-				delete(processImages, imageName)
-				return nil
-			} else {
-				imgLine := imgSets[0]
-				imageid := utils.Interface2String(imgLine["imageid"])
-				updatePulltimesForImage(imageid, "")
-				reference := uriArray[(uriLen - 1)]
-				manifest := getManifests(imageName, reference)
-				tagSets, errs := getTagInfoFromDB("", imageid, manifest.Tag, "", "", 0, 0)
-				logErrors(errs)
-				if len(tagSets) < 1 {
-					username, _, _ := r.Request.BasicAuth()
-					if username == "" {
-						username = "admin"
-					}
-					image := image{
-						username:     username,
-						name:         imageName,
-						size:         0,
-						tag:          manifest.Tag,
-						architecture: manifest.Architecture,
-						digest:       "",
-						blobs:        []blob{},
-					}
-					definedConfig := RuntimeData.RuningParas.DefinedConfig
-					apiServerTls := definedConfig.Sysadm.Server.Tls
-					apiServerAddress := definedConfig.Sysadm.Server.Host
-					apiServerPort := definedConfig.Sysadm.Server.Port
-					apiVersion := definedConfig.Sysadm.ApiVerion
-					userid, _ := getUserIdByUsername(apiServerTls, apiServerAddress, apiServerPort, apiVersion, username)
-					if userid == 0 {
-						userid = 1
-					}
-					processImages[imageName] = image
-					imageID, _ := strconv.Atoi(imageid)
-					_ = addTagsToDB(imageName, imageID, userid)
-					delete(processImages, imageName)
-				} else {
-					tagLine := tagSets[0]
-					tagid := utils.Interface2String(tagLine["tagid"])
-					updatePulltimesForTag(tagid, "")
-				}
-				return nil
-			}
+	// Get imageName form uri
+	imageName := strings.Join(uriArray[2:(uriLen-2)], "/")
+	reference := uriArray[(uriLen - 1)]
+	username, _, _ := r.Request.BasicAuth()
+	if username == "" {
+		username = "admin"
+	}
 
+	imgSets, _ := getImageInfoFromDB("", "", imageName, "", 0, 0)
+	if len(imgSets) > 0 {
+		imageid := utils.Interface2String(imgSets[0]["imageid"])
+		updatePulltimesForImage(imageid, "")
+		if tagid := findTagID(imageid, reference); tagid != "" {
+			updatePulltimesForTag(tagid, "")
+			return nil
 		}
+	}
 
+	if isDigestReference(reference) {
+		return nil
 	}
+
+	recordManifest(imageName, reference, username, nil)
+
 	return nil
 }
 
+// findTagID returns the ID of the tag named reference or the manifest whose digest is reference of the image
+// identified by imageid. an empty string is returned if it has not been recorded
+func findTagID(imageid, reference string) string {
+	var tagSets []map[string]interface{}
+	var errs []sysadmerror.Sysadmerror
+	if isDigestReference(reference) {
+		tagSets, errs = getTagInfoFromDB("", imageid, "", "", reference, 0, 0)
+	} else {
+		tagSets, errs = getTagInfoFromDB("", imageid, reference, "", "", 0, 0)
+	}
+	logErrors(errs)
+
+	for _, line := range tagSets {
+		// the names of tags are matched by like
+		if isDigestReference(reference) || utils.Interface2String(line["name"]) == reference {
+			return utils.Interface2String(line["tagid"])
+		}
+	}
+
+	return ""
+}
+
+// recordManifest gets the manifest identified by reference of the image named imageName from the registry,
+// and records the image, the tag, the child manifests and the blobs of them in DB.
+// recorded is the blobs recorded during pushing which have the sizes of the blobs of Docker schema 1 manifests
+func recordManifest(imageName, reference, username string, recorded []blob) {
+	var errs []sysadmerror.Sysadmerror
+	m, e := resolveManifest(imageName, reference)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20308002, "error", "can not get manifest %s of image %s: %s", reference, imageName, e))
+		logErrors(errs)
+		return
+	}
+
+	img := buildImage(imageName, reference, username, m, recorded)
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20308003, "debug", "image information: %#v", img))
+	logErrors(errs)
+
+	processImages[imageName] = img
+	updataImage(imageName)
+	delete(processImages, imageName)
+}
+
 /*
 modifyReponseForCheckBlobExist: recording the infromation of blob ,such as digest, size to global variable processImages
 imageName: the name of image
@@ -524,95 +529,24 @@ func putManifestsResponse(c *sysadmServer.Context) func(r *http.Response) error
 		}
 	}
 	reference := pathArray[(arrayLen - 1)]
-	image := processImages[imageName]
-
-	// sum the size of the all blobs of the image and set the information to the image
-	var size int64 = 0
-	for _, blob := range image.blobs {
-		size += blob.size
-	}
-	image.size = size
-	processImages[imageName] = image
+	username, _, _ := c.Request.BasicAuth()
 
 	return func(r *http.Response) error {
-		var errs []sysadmerror.Sysadmerror
-
-		if r.StatusCode == http.StatusCreated {
-			manifest := getManifests(imageName, reference)
-			digest := r.Header.Get("Docker-Content-Digest")
-			if manifest != nil {
-				image := processImages[imageName]
-				image.name = imageName
-				image.tag = manifest.Tag
-				image.architecture = manifest.Architecture
-				image.digest = digest
-				processImages[imageName] = image
-			}
-			errs = append(errs, sysadmerror.NewErrorWithStringLevel(202040, "debug", "image information: %#v", processImages))
-			logErrors(errs)
-		}
-		updataImage(imageName)
+		image := processImages[imageName]
 		delete(processImages, imageName)
-		return nil
-	}
-}
-
-func getManifests(name string, reference string) *Manifest {
-	var ret *Manifest = nil
-	var errs []sysadmerror.Sysadmerror
 
-	if strings.TrimSpace(name) == "" {
-		errs = append(errs, sysadmerror.NewErrorWithStringLevel(202040, "error", "image name is empty, can not get the manifest for empty name of image"))
-		logErrors(errs)
-		return ret
-	}
-	if strings.TrimSpace(reference) == "" {
-		reference = "lastest"
-	}
-	errs = append(errs, sysadmerror.NewErrorWithStringLevel(202039, "debug", "building proxy request parameters."))
-
-	// gets the configurations from RuntimeData
-	definedConfig := RuntimeData.RuningParas.DefinedConfig
-	registryHost := definedConfig.Registry.Server.Host
-	registryPort := definedConfig.Registry.Server.Port
-	registryTls := definedConfig.Registry.Server.Tls
-	var urlStr string = ""
-	if registryTls {
-		if registryPort == 443 {
-			urlStr = "https://" + registryHost
-		} else {
-			urlStr = "https://" + registryHost + ":" + strconv.Itoa(registryPort)
-		}
-	} else {
-		if registryPort == 80 {
-			urlStr = "http://" + registryHost
-		} else {
-			urlStr = "http://" + registryHost + ":" + strconv.Itoa(registryPort)
+		// the child manifests of a manifest list or an index are pushed by digest before it,
+		// they are recorded when the manifest list or the index is pushed with a tag
+		if r.StatusCode != http.StatusCreated || isDigestReference(reference) {
+			return nil
 		}
-	}
-
-	urlStr = urlStr + "/v2/" + name + "/manifests/" + reference
-
-	var requestParams requestParams = requestParams{}
-	requestParams.url = urlStr
-	requestParams.method = "GET"
-	errs = append(errs, sysadmerror.NewErrorWithStringLevel(202040, "debug", "try to execute the request with url :%s", urlStr))
-	body, err := sendRequest(&requestParams)
-	errs = append(errs, err...)
-	errs = append(errs, sysadmerror.NewErrorWithStringLevel(202041, "debug", "got the reponse body is: %s", body))
 
-	if len(body) < 1 {
-		logErrors(errs)
-		return ret
-	}
+		if image.username != "" {
+			username = image.username
+		}
+		recordManifest(imageName, reference, username, image.blobs)
+		go triggerReplicationOnPush(imageName, reference)
 
-	ret = &Manifest{}
-	e := json.Unmarshal(body, ret)
-	if e != nil {
-		errs = append(errs, sysadmerror.NewErrorWithStringLevel(202041, "error", "can not unmarshal body: %s", e))
-		logErrors(errs)
 		return nil
 	}
-
-	return ret
 }
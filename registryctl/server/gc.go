@@ -0,0 +1,333 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/sysadmerror"
+	"sysadm/utils"
+)
+
+// gcBlob is a blob which is not referenced by any tag of an image
+type gcBlob struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// gcReport is the result of a garbage collection
+type gcReport struct {
+	DryRun    bool
+	StartTime int64
+	EndTime   int64
+	// number of images which have been walked
+	Images int
+	// number of manifests which are referenced by the tags in the registry, including the child manifests of indexes
+	Manifests int
+	// number of blobs which are referenced by the manifests
+	ReferencedBlobs int
+	// blobs which are not referenced. they are deleted unless DryRun is true
+	UnreferencedBlobs []gcBlob
+	// number of tags recorded in DB which do not exist in the registry any more
+	StaleTags int
+	// sum of the sizes of the blobs which have been unlinked from their images through the registry API, or would
+	// be unlinked for a dry run. it is not the storage which has been freed: the registry API only removes the links
+	// of the blobs, the storage of a blob is freed by the garbage collection of the registry itself after the blob
+	// has been unlinked from all images
+	BytesUnlinked int64
+	// number of blobs which can not be deleted
+	Failed int
+	// images which have not been swept as they can not be marked. their blobs are kept
+	SkippedImages []string
+}
+
+// gcLock makes sure that only one garbage collection runs at a time
+var gcLock sync.Mutex
+
+// lastGCReport is the report of the last garbage collection. it is nil if no garbage collection has finished
+var lastGCReport *gcReport
+
+// readOnly is true during the maintenance window of garbage collection, pushes and deletions are refused.
+// the flag is kept in the memory of this registryctl process, so the pushes which are sent to other registryctl
+// instances in front of the same registry are not refused. registryctl should run as a single instance for each
+// registry, or the pushes should be stopped on the other instances before a garbage collection
+var readOnly bool
+
+// readOnlyLock protects readOnly and lastGCReport
+var readOnlyLock sync.RWMutex
+
+// isReadOnly returns true if the registry is in maintenance window
+func isReadOnly() bool {
+	readOnlyLock.RLock()
+	defer readOnlyLock.RUnlock()
+
+	return readOnly
+}
+
+// setReadOnly enters or leaves the maintenance window
+func setReadOnly(on bool) {
+	readOnlyLock.Lock()
+	readOnly = on
+	readOnlyLock.Unlock()
+}
+
+// getLastGCReport returns the report of the last garbage collection
+func getLastGCReport() *gcReport {
+	readOnlyLock.RLock()
+	defer readOnlyLock.RUnlock()
+
+	return lastGCReport
+}
+
+// rejectWhenReadOnly responses the client with UNSUPPORTED error and returns true if the registry is in
+// maintenance window
+func rejectWhenReadOnly(c *sysadmServer.Context) bool {
+	if !isReadOnly() {
+		return false
+	}
+
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	c.JSON(http.StatusMethodNotAllowed, ReponseError{Errors: []BodyError{RegistryErrs["read_only"]}})
+
+	return true
+}
+
+// runGarbageCollection reclaims the blobs which are not referenced by any tag with mark and sweep.
+// the mark phase walks all tags of the images in the registry and marks the manifests, the child manifests
+// of indexes and the blobs referenced by them. the sweep phase deletes the blobs recorded in DB which are not
+// marked through the registry API, and removes the tags recorded in DB which do not exist in the registry.
+// an image which can not be marked is skipped and reported, other images are still collected.
+// pushes are refused from the start of the mark phase to the end of the sweep phase, otherwise the blobs
+// of a manifest pushed during garbage collection may be swept. nothing is changed if dryRun is true
+func runGarbageCollection(dryRun bool) (*gcReport, error) {
+	if !gcLock.TryLock() {
+		return nil, fmt.Errorf("garbage collection is running")
+	}
+	defer gcLock.Unlock()
+
+	var errs []sysadmerror.Sysadmerror
+	report := &gcReport{DryRun: dryRun, StartTime: time.Now().Unix(), UnreferencedBlobs: []gcBlob{}, SkippedImages: []string{}}
+	if !dryRun {
+		setReadOnly(true)
+		defer setReadOnly(false)
+	}
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20309001, "info", "garbage collection started, dry run: %t", dryRun))
+	logErrors(errs)
+
+	imgSets, err := getImageInfoFromDB("", "", "", "", 0, 0)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get images from DB")
+	}
+
+	for _, line := range imgSets {
+		imageid := utils.Interface2String(line["imageid"])
+		name := utils.Interface2String(line["name"])
+		manifests, blobs, e := markImage(name)
+		if e != nil {
+			// the blobs of the image can not be swept without knowing which of them are referenced
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20309006, "error", "mark image %s error: %s, the image is skipped", name, e)})
+			report.SkippedImages = append(report.SkippedImages, name)
+			continue
+		}
+		report.Images++
+		report.Manifests += len(manifests)
+		report.ReferencedBlobs += len(blobs)
+
+		sweepImage(imageid, name, manifests, blobs, report)
+	}
+
+	report.EndTime = time.Now().Unix()
+	errs = errs[0:0]
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20309002, "info", "garbage collection finished, dry run: %t unreferenced blobs: %d bytes unlinked: %d failed: %d skipped images: %d",
+		dryRun, len(report.UnreferencedBlobs), report.BytesUnlinked, report.Failed, len(report.SkippedImages)))
+	logErrors(errs)
+
+	readOnlyLock.Lock()
+	lastGCReport = report
+	readOnlyLock.Unlock()
+
+	return report, nil
+}
+
+// markImage returns the digests of the manifests and the blobs referenced by the tags of the image named name
+// in the registry
+func markImage(name string) (map[string]bool, map[string]bool, error) {
+	manifests := make(map[string]bool, 0)
+	blobs := make(map[string]bool, 0)
+
+	tags, e := listRegistryTags(name)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	for _, tag := range tags {
+		m, e := resolveManifest(name, tag)
+		if e != nil {
+			return nil, nil, e
+		}
+		markManifest(m, manifests, blobs)
+	}
+
+	return manifests, blobs, nil
+}
+
+// markManifest marks the manifest, its child manifests and the blobs referenced by them
+func markManifest(m imageManifest, manifests map[string]bool, blobs map[string]bool) {
+	manifests[strings.ToLower(m.digest)] = true
+	for _, b := range m.blobs {
+		blobs[strings.ToLower(b.digest)] = true
+	}
+	for _, child := range m.children {
+		markManifest(child, manifests, blobs)
+	}
+}
+
+// listRegistryTags gets the tags of the image named name from the registry.
+// an empty list is returned if the image does not exist in the registry
+func listRegistryTags(name string) ([]string, error) {
+	body, resp, errs := doRequest(&requestParams{method: http.MethodGet, url: registryServerUrl() + "/v2/" + name + "/tags/list"})
+	logErrors(errs)
+	if resp == nil {
+		return nil, fmt.Errorf("can not get tags of image %s", name)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can not get tags of image %s, status: %d", name, resp.StatusCode)
+	}
+
+	var tagList struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	if e := json.Unmarshal(body, &tagList); e != nil {
+		return nil, fmt.Errorf("can not unmarshal tags of image %s: %s", name, e)
+	}
+
+	return tagList.Tags, nil
+}
+
+// sweepImage finds the blobs recorded in DB for the tags of the image which are not marked and deletes them.
+// the tags whose manifests are not marked are removed from DB
+func sweepImage(imageid, name string, manifests map[string]bool, blobs map[string]bool, report *gcReport) {
+	tagSets, _ := getTagInfoFromDB("", imageid, "", "", "", 0, 0)
+	var children []map[string]interface{}
+	for _, line := range tagSets {
+		childSets, _ := getChildTagsFromDB(utils.Interface2String(line["tagid"]))
+		children = append(children, childSets...)
+	}
+	tagSets = append(tagSets, children...)
+
+	var tagids, staleTagids []string
+	for _, line := range tagSets {
+		tagid := utils.Interface2String(line["tagid"])
+		tagids = append(tagids, tagid)
+		if !manifests[strings.ToLower(utils.Interface2String(line["digest"]))] {
+			staleTagids = append(staleTagids, tagid)
+		}
+	}
+	if len(tagids) < 1 {
+		return
+	}
+	report.StaleTags += len(staleTagids)
+
+	blobSets, _ := getBlobInfoFromDB("", strings.Join(tagids, ","), "")
+	swept := make(map[string]bool, 0)
+	for _, line := range blobSets {
+		digest := strings.TrimSpace(utils.Interface2String(line["digest"]))
+		key := strings.ToLower(digest)
+		if digest == "" || blobs[key] || swept[key] {
+			continue
+		}
+		swept[key] = true
+
+		size, _ := utils.Interface2Int64(line["size"])
+		if !report.DryRun && !deleteRegistryBlob(name, digest) {
+			report.Failed++
+			continue
+		}
+		report.UnreferencedBlobs = append(report.UnreferencedBlobs, gcBlob{Image: name, Digest: digest, Size: size})
+		report.BytesUnlinked += size
+		if !report.DryRun {
+			_ = delBlobFromDB("", strings.Join(tagids, ","), digest)
+		}
+	}
+
+	if report.DryRun || len(staleTagids) < 1 {
+		return
+	}
+	_ = delBlobFromDB("", strings.Join(staleTagids, ","), "")
+	_ = delTagsFromDB(strings.Join(staleTagids, ","), "", "")
+}
+
+// deleteRegistryBlob deletes the blob identified by digest from the image named name through the registry API.
+// a blob which does not exist in the registry is taken as deleted
+func deleteRegistryBlob(name, digest string) bool {
+	var errs []sysadmerror.Sysadmerror
+	_, resp, err := doRequest(&requestParams{method: http.MethodDelete, url: registryServerUrl() + "/v2/" + name + "/blobs/" + digest})
+	errs = append(errs, err...)
+	if resp == nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20309003, "error", "can not delete blob %s of image %s", digest, name))
+		logErrors(errs)
+		return false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK, http.StatusNotFound:
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20309004, "debug", "blob %s of image %s has been deleted", digest, name))
+		logErrors(errs)
+		return true
+	}
+
+	// the registry responses 405 if deleting is not enabled in its configuration
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20309005, "error", "can not delete blob %s of image %s, status: %d", digest, name, resp.StatusCode))
+	logErrors(errs)
+
+	return false
+}
+
+// gcReportToMap converts report to the data which can be sent to the client
+func gcReportToMap(report *gcReport) map[string]interface{} {
+	ret := make(map[string]interface{}, 0)
+	if report == nil {
+		return ret
+	}
+
+	ret["dryRun"] = report.DryRun
+	ret["startTime"] = report.StartTime
+	ret["endTime"] = report.EndTime
+	ret["images"] = report.Images
+	ret["manifests"] = report.Manifests
+	ret["referencedBlobs"] = report.ReferencedBlobs
+	ret["unreferencedBlobs"] = report.UnreferencedBlobs
+	ret["staleTags"] = report.StaleTags
+	ret["bytesUnlinked"] = report.BytesUnlinked
+	ret["failed"] = report.Failed
+	ret["skippedImages"] = report.SkippedImages
+
+	return ret
+}
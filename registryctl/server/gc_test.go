@@ -0,0 +1,248 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	sysadmDB "sysadm/db"
+	"sysadm/registryctl/config"
+	"sysadm/sysadmerror"
+)
+
+// gcDB keeps the rows of the tables in memory. the conditions of the queries are in the forms of =value,
+// in (values) and like "%value%" which are used by the functions in registry_db.go. querying the tables in
+// broken fails
+type gcDB struct {
+	sysadmDB.DbEntity
+
+	lock   sync.Mutex
+	tables map[string][]sysadmDB.FieldData
+	broken map[string]bool
+}
+
+func (d *gcDB) QueryData(sd *sysadmDB.SelectData) ([]sysadmDB.FieldData, []sysadmerror.Sysadmerror) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.broken[sd.Tb[0]] {
+		return nil, []sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(1, "error", "table %s is broken", sd.Tb[0])}
+	}
+
+	var ret []sysadmDB.FieldData
+	for _, row := range d.tables[sd.Tb[0]] {
+		if gcRowMatched(row, sd.Where) {
+			ret = append(ret, row)
+		}
+	}
+
+	return ret, nil
+}
+
+func (d *gcDB) DeleteData(dd *sysadmDB.SelectData) (int64, []sysadmerror.Sysadmerror) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var kept []sysadmDB.FieldData
+	for _, row := range d.tables[dd.Tb[0]] {
+		if !gcRowMatched(row, dd.Where) {
+			kept = append(kept, row)
+		}
+	}
+	num := len(d.tables[dd.Tb[0]]) - len(kept)
+	d.tables[dd.Tb[0]] = kept
+
+	return int64(num), nil
+}
+
+func (d *gcDB) rows(tb string) []sysadmDB.FieldData {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return append([]sysadmDB.FieldData{}, d.tables[tb]...)
+}
+
+func gcRowMatched(row sysadmDB.FieldData, where map[string]string) bool {
+	for key, condition := range where {
+		condition = strings.TrimSpace(condition)
+		var values []string
+		switch {
+		case strings.HasPrefix(condition, "like"):
+			if !strings.Contains(fmt.Sprint(row[key]), strings.Trim(strings.TrimSpace(condition[4:]), "\"'%")) {
+				return false
+			}
+			continue
+		case strings.HasPrefix(condition, "in"):
+			values = strings.Split(strings.Trim(strings.TrimSpace(condition[2:]), "()"), ",")
+		case strings.HasPrefix(condition, "="):
+			values = []string{condition[1:]}
+		default:
+			return false
+		}
+
+		found := false
+		for _, v := range values {
+			if strings.Trim(strings.TrimSpace(v), "\"'") == fmt.Sprint(row[key]) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// gcRegistry serves the tags of the repositories and records the deleted blobs. other requests are served by
+// fakeRegistry. listing the tags of the repositories in broken fails
+type gcRegistry struct {
+	*fakeRegistry
+
+	tags    map[string][]string
+	broken  map[string]bool
+	deleted []string
+}
+
+func (reg *gcRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case strings.HasSuffix(p, "/tags/list"):
+		repository := strings.TrimSuffix(p, "/tags/list")
+		if reg.broken[repository] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repository, "tags": reg.tags[repository]})
+	case r.Method == http.MethodDelete && strings.Contains(p, "/blobs/"):
+		reg.lock.Lock()
+		reg.deleted = append(reg.deleted, strings.Replace(p, "/blobs/", "@", 1))
+		reg.lock.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		reg.fakeRegistry.ServeHTTP(w, r)
+	}
+}
+
+// newGCTest make the garbage collection work on a registry and a DB with two images. the tag v1 of lib/app is in the
+// registry and the tag old of it is not, while the tags of lib/broken can not be listed
+func newGCTest(t *testing.T) (*gcRegistry, *gcDB) {
+	reg := &gcRegistry{
+		fakeRegistry: &fakeRegistry{manifests: make(map[string]fakeManifest, 0), blobs: make(map[string][]byte, 0)},
+		tags:         map[string][]string{"lib/app": {"v1"}},
+		broken:       map[string]bool{"lib/broken": true},
+	}
+	digest := reg.addImage(t, "lib/app", "v1", "layer of app")
+	m, _ := reg.manifestOf("lib/app", "v1")
+	var v2 ManifestV2
+	_ = json.Unmarshal(m.content, &v2)
+
+	ts := httptest.NewServer(reg)
+	t.Cleanup(ts.Close)
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+	conf := &config.Config{}
+	conf.Registry.Server = config.RegistryServer{Host: u.Hostname(), Port: port}
+
+	db := &gcDB{tables: map[string][]sysadmDB.FieldData{
+		"image": {
+			{"imageid": "1", "name": "lib/app"},
+			{"imageid": "2", "name": "lib/broken"},
+		},
+		"tag": {
+			{"tagid": "10", "imageid": "1", "name": "v1", "digest": digest, "parentid": "0"},
+			{"tagid": "11", "imageid": "1", "name": "old", "digest": testDigest("0"), "parentid": "0"},
+			{"tagid": "20", "imageid": "2", "name": "v1", "digest": testDigest("2"), "parentid": "0"},
+		},
+		"blob": {
+			{"blobid": "1", "tagid": "10", "digest": v2.Config.Digest, "size": v2.Config.Size},
+			{"blobid": "2", "tagid": "10", "digest": v2.Layers[0].Digest, "size": v2.Layers[0].Size},
+			{"blobid": "3", "tagid": "11", "digest": testDigest("1"), "size": int64(100)},
+			{"blobid": "4", "tagid": "20", "digest": testDigest("3"), "size": int64(50)},
+		},
+	}}
+
+	old := *RuntimeData.RuningParas
+	RuntimeData.RuningParas.DefinedConfig = conf
+	RuntimeData.RuningParas.DBConfig = &sysadmDB.DbConfig{Entity: db}
+	t.Cleanup(func() { *RuntimeData.RuningParas = old })
+
+	return reg, db
+}
+
+func TestRunGarbageCollection(t *testing.T) {
+	reg, db := newGCTest(t)
+
+	report, e := runGarbageCollection(false)
+	if e != nil {
+		t.Fatalf("run garbage collection error: %s", e)
+	}
+
+	// the image which can not be marked is skipped, other images are still collected
+	if report.Images != 1 || !reflect.DeepEqual(report.SkippedImages, []string{"lib/broken"}) {
+		t.Errorf("1 image should be collected and lib/broken skipped, got %d images skipped %v", report.Images, report.SkippedImages)
+	}
+	wantBlobs := []gcBlob{{Image: "lib/app", Digest: testDigest("1"), Size: 100}}
+	if !reflect.DeepEqual(report.UnreferencedBlobs, wantBlobs) || report.BytesUnlinked != 100 || report.StaleTags != 1 {
+		t.Errorf("unreferenced blobs should be %v with 100 bytes unlinked and 1 stale tag, got %v %d bytes %d stale tags",
+			wantBlobs, report.UnreferencedBlobs, report.BytesUnlinked, report.StaleTags)
+	}
+	if want := []string{"lib/app@" + testDigest("1")}; !reflect.DeepEqual(reg.deleted, want) {
+		t.Errorf("blobs deleted from the registry should be %v, got %v", want, reg.deleted)
+	}
+
+	var tagids []string
+	for _, row := range db.rows("tag") {
+		tagids = append(tagids, fmt.Sprint(row["tagid"]))
+	}
+	if want := []string{"10", "20"}; !reflect.DeepEqual(tagids, want) {
+		t.Errorf("tags in DB should be %v, got %v", want, tagids)
+	}
+	if blobs := db.rows("blob"); len(blobs) != 3 {
+		t.Errorf("3 blobs should be kept in DB, got %v", blobs)
+	}
+	if isReadOnly() {
+		t.Errorf("registry should leave the maintenance window after garbage collection")
+	}
+}
+
+func TestRunGarbageCollectionDryRun(t *testing.T) {
+	reg, db := newGCTest(t)
+
+	report, e := runGarbageCollection(true)
+	if e != nil {
+		t.Fatalf("run garbage collection error: %s", e)
+	}
+
+	if len(report.UnreferencedBlobs) != 1 || report.BytesUnlinked != 100 || len(report.SkippedImages) != 1 {
+		t.Errorf("dry run should report 1 blob of 100 bytes and 1 skipped image, got %v %d bytes skipped %v",
+			report.UnreferencedBlobs, report.BytesUnlinked, report.SkippedImages)
+	}
+	if len(reg.deleted) != 0 || len(db.rows("tag")) != 3 || len(db.rows("blob")) != 4 {
+		t.Errorf("dry run should not change anything, deleted %v tags %v blobs %v", reg.deleted, db.rows("tag"), db.rows("blob"))
+	}
+}
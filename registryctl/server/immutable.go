@@ -0,0 +1,299 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/db"
+	"sysadm/sysadmerror"
+	"sysadm/utils"
+)
+
+// immutableRuleTable is the table which the tag immutability rules are stored in
+const immutableRuleTable = "tagImmutableRule"
+
+// immutableRule makes the tags of the images of a project which match it immutable. an immutable tag can not be
+// overwritten by pushing another manifest with it, and its manifest can not be deleted
+type immutableRule struct {
+	id int
+	// name of the project which the rule applies to
+	project string
+	// the rule applies to the repositories of the project whose names match the pattern. it applies to all
+	// repositories of the project if it is empty
+	repository string
+	// the tags whose names match the pattern are immutable
+	tag string
+}
+
+// validate checks whether the rule is valid
+func (r immutableRule) validate() error {
+	if !repositoryNamePattern.MatchString(r.project) || strings.Contains(r.project, "/") {
+		return fmt.Errorf("project name %q is not valid", r.project)
+	}
+	if r.repository != "" {
+		if _, e := path.Match(r.repository, ""); e != nil {
+			return fmt.Errorf("repository pattern %s is not valid: %s", r.repository, e)
+		}
+		if !strings.HasPrefix(r.repository, r.project+"/") {
+			return fmt.Errorf("repository pattern %s does not belong to project %s", r.repository, r.project)
+		}
+	}
+	if r.tag == "" {
+		return fmt.Errorf("tag pattern must not be empty")
+	}
+	if _, e := path.Match(r.tag, ""); e != nil {
+		return fmt.Errorf("tag pattern %s is not valid: %s", r.tag, e)
+	}
+
+	return nil
+}
+
+// matches returns true if the tag of the image named imageName is made immutable by the rule
+func (r immutableRule) matches(imageName, tag string) bool {
+	if !strings.HasPrefix(imageName, r.project+"/") {
+		return false
+	}
+	if r.repository != "" {
+		if ok, _ := path.Match(r.repository, imageName); !ok {
+			return false
+		}
+	}
+	ok, _ := path.Match(r.tag, tag)
+
+	return ok
+}
+
+// toMap converts the rule to the data which can be sent to the client
+func (r immutableRule) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"ruleid":     r.id,
+		"project":    r.project,
+		"repository": r.repository,
+		"tag":        r.tag,
+	}
+}
+
+// addImmutableRuleToDB validates the rule and inserts it into DB. return the ID of the rule
+func addImmutableRuleToDB(r immutableRule) (int, error) {
+	if e := r.validate(); e != nil {
+		return 0, e
+	}
+
+	rules, e := getImmutableRulesFromDB("", r.project)
+	if e != nil {
+		return 0, e
+	}
+	for _, exist := range rules {
+		if exist.repository == r.repository && exist.tag == r.tag {
+			return 0, fmt.Errorf("rule %d has been defined", exist.id)
+		}
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	id, e := db.NextID(dbEntity, immutableRuleTable, "ruleid")
+	if e != nil {
+		return 0, fmt.Errorf("can not allocate ID for the rule: %s", e)
+	}
+
+	now := time.Now().Unix()
+	data := make(db.FieldData, 0)
+	data["ruleid"] = id
+	data["project"] = r.project
+	data["repository"] = sqlStringValue(r.repository)
+	data["tag"] = sqlStringValue(r.tag)
+	data["creation_time"] = now
+	data["update_time"] = now
+	_, err := dbEntity.InsertData(immutableRuleTable, data)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return 0, fmt.Errorf("can not insert the rule into DB")
+	}
+
+	return int(id), nil
+}
+
+// getImmutableRulesFromDB gets the rule identified by ruleid or the rules of project.
+// all rules are returned if both of them are empty
+func getImmutableRulesFromDB(ruleid, project string) ([]immutableRule, error) {
+	whereMap := make(map[string]string, 0)
+	if ruleid != "" {
+		id, e := strconv.Atoi(ruleid)
+		if e != nil {
+			return nil, fmt.Errorf("rule id %s is not valid", ruleid)
+		}
+		whereMap["ruleid"] = "=" + strconv.Itoa(id)
+	}
+	if project != "" {
+		whereMap["project"] = "=\"" + sqlStringValue(project) + "\""
+	}
+
+	selectData := db.SelectData{
+		Tb:        []string{immutableRuleTable},
+		OutFeilds: []string{"ruleid", "project", "repository", "tag"},
+		Where:     whereMap,
+		Order:     []db.OrderData{{Key: "ruleid", Order: 0}},
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	retData, err := dbEntity.QueryData(&selectData)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get tag immutability rules from DB")
+	}
+
+	var rules []immutableRule
+	for _, line := range retData {
+		id, _ := utils.Interface2Int(line["ruleid"])
+		rules = append(rules, immutableRule{
+			id:         id,
+			project:    utils.Interface2String(line["project"]),
+			repository: utils.Interface2String(line["repository"]),
+			tag:        utils.Interface2String(line["tag"]),
+		})
+	}
+
+	return rules, nil
+}
+
+// delImmutableRuleFromDB deletes the rule identified by ruleid
+func delImmutableRuleFromDB(ruleid string) error {
+	id, e := strconv.Atoi(strings.TrimSpace(ruleid))
+	if e != nil {
+		return fmt.Errorf("rule id %s is not valid", ruleid)
+	}
+
+	delData := db.SelectData{
+		Tb:    []string{immutableRuleTable},
+		Where: map[string]string{"ruleid": "=" + strconv.Itoa(id)},
+	}
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	rows, err := dbEntity.DeleteData(&delData)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return fmt.Errorf("can not delete rule %d", id)
+	}
+	if rows == 0 {
+		return fmt.Errorf("rule %d was not found", id)
+	}
+
+	return nil
+}
+
+// isImmutableTag returns true if the tag of the image can not be deleted or overwritten. the tag is taken as
+// immutable if the rules can not be got from DB
+func isImmutableTag(imageName, tag string) bool {
+	return immutableTagsOf(imageName)(tag)
+}
+
+// immutableTagsOf returns the function which checks whether a tag of the image named imageName is immutable.
+// the rules of the project are got from DB once, so the function can check many tags of the image. all tags are
+// taken as immutable if the rules can not be got from DB
+func immutableTagsOf(imageName string) func(tag string) bool {
+	rules, e := getImmutableRulesFromDB("", strings.Split(imageName, "/")[0])
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20313001, "error", "can not check immutability of tags of image %s: %s", imageName, e)})
+		return func(tag string) bool {
+			return true
+		}
+	}
+
+	return func(tag string) bool {
+		for _, r := range rules {
+			if r.matches(imageName, tag) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// enforceImmutableTag refuses pushing a manifest with an immutable tag which exists in the registry, unless the
+// manifest is the same as the existing one. the response will be sent to the client and false will be returned if
+// the pushing is refused
+func enforceImmutableTag(c *sysadmServer.Context, imageName, reference string) bool {
+	if isDigestReference(reference) || !isImmutableTag(imageName, reference) {
+		return true
+	}
+
+	r := requestParams{method: http.MethodHead, url: registryServerUrl() + "/v2/" + imageName + "/manifests/" + reference}
+	r.headers = append(r.headers, httpHeader{key: "Accept", value: strings.Join(manifestAcceptTypes, ", ")})
+	_, resp, errs := doRequest(&r)
+	logErrors(errs)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return true
+	}
+
+	body, e := ioutil.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	c.Request.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	if e == nil && resp != nil && resp.StatusCode == http.StatusOK {
+		sum := sha256.Sum256(body)
+		if resp.Header.Get("Docker-Content-Digest") == "sha256:"+hex.EncodeToString(sum[:]) {
+			return true
+		}
+	}
+
+	rejectImmutableTag(c, imageName, reference)
+	return false
+}
+
+// enforceImmutableManifest refuses deleting a manifest which is referenced by an immutable tag. the response will
+// be sent to the client and false will be returned if the deleting is refused
+func enforceImmutableManifest(c *sysadmServer.Context, imageName, digest string) bool {
+	imgSets, _ := getImageInfoFromDB("", "", imageName, "", 0, 0)
+	for _, img := range imgSets {
+		// the name of the image is matched with LIKE
+		if utils.Interface2String(img["name"]) != imageName {
+			continue
+		}
+
+		tagSets, _ := getTagInfoFromDB("", utils.Interface2String(img["imageid"]), "", "", digest, 0, 0)
+		for _, line := range tagSets {
+			parentid, _ := utils.Interface2Int(line["parentid"])
+			tag := utils.Interface2String(line["name"])
+			if parentid == 0 && isImmutableTag(imageName, tag) {
+				rejectImmutableTag(c, imageName, tag)
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// rejectImmutableTag responses DENIED error to the client for changing the immutable tag
+func rejectImmutableTag(c *sysadmServer.Context, imageName, tag string) {
+	logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20313002, "info", "tag %s of image %s is immutable, the request has been refused", tag, imageName)})
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	c.JSON(http.StatusForbidden, ReponseError{Errors: []BodyError{{
+		Code:    RegistryErrs["denied"].Code,
+		Message: fmt.Sprintf("tag %s of image %s is immutable", tag, imageName),
+		Detail:  RegistryErrs["denied"].Detail,
+	}}})
+}
@@ -0,0 +1,390 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sysadm/sysadmerror"
+)
+
+// media types of the manifests supported by registryctl
+const (
+	MediaTypeDockerSchema1Manifest       = "application/vnd.docker.distribution.manifest.v1+json"
+	MediaTypeDockerSchema1SignedManifest = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	MediaTypeDockerSchema2Manifest       = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList          = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest                 = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex                    = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestAcceptTypes are sent in Accept header when getting manifests from the registry, otherwise the registry
+// converts the manifests to schema 1 or refuses to response the manifests which can not be converted
+var manifestAcceptTypes = []string{
+	MediaTypeOCIIndex,
+	MediaTypeDockerManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeDockerSchema2Manifest,
+	MediaTypeDockerSchema1SignedManifest,
+	MediaTypeDockerSchema1Manifest,
+}
+
+// Platform is the platform which an image runs on
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Descriptor describes the content referenced by a manifest or an index
+type Descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *Platform `json:"platform,omitempty"`
+}
+
+// ManifestV2 is Docker schema 2 manifest, manifest list, OCI image manifest or OCI image index.
+// Config and Layers are set for the manifests and Manifests is set for the lists and indexes
+type ManifestV2 struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        *Descriptor  `json:"config,omitempty"`
+	Layers        []Descriptor `json:"layers,omitempty"`
+	Manifests     []Descriptor `json:"manifests,omitempty"`
+}
+
+// imageManifest is the information of a manifest which will be recorded in DB
+type imageManifest struct {
+	// media type of the manifest
+	mediaType string
+	// digest of the manifest
+	digest string
+	// tag in the manifest, set by Docker schema 1 only
+	tag string
+	// platform of the image. it is empty for manifest lists and indexes
+	platform Platform
+	// config descriptor of the image. the platform will be got from the config if it is not known
+	config *Descriptor
+	// blobs referenced by the manifest including the config
+	blobs []blob
+	// child manifests of a manifest list or an index, one for each platform
+	children []imageManifest
+}
+
+// isIndex returns true if the manifest is a manifest list or an index which has child manifests
+func (m imageManifest) isIndex() bool {
+	return m.mediaType == MediaTypeDockerManifestList || m.mediaType == MediaTypeOCIIndex
+}
+
+// architecture returns the architectures of the image. the architectures of all platforms are joined with ","
+// for manifest lists and indexes
+func (m imageManifest) architecture() string {
+	if !m.isIndex() {
+		return m.platform.Architecture
+	}
+
+	var archs []string
+	seen := make(map[string]bool, 0)
+	for _, child := range m.children {
+		arch := child.platform.Architecture
+		if arch != "" && !seen[arch] {
+			seen[arch] = true
+			archs = append(archs, arch)
+		}
+	}
+
+	return strings.Join(archs, ",")
+}
+
+// String returns the platform in os/architecture[/variant] form
+func (p Platform) String() string {
+	if p.OS == "" && p.Architecture == "" {
+		return ""
+	}
+	if p.Variant == "" {
+		return p.OS + "/" + p.Architecture
+	}
+
+	return p.OS + "/" + p.Architecture + "/" + p.Variant
+}
+
+// isDigestReference checks whether reference of a manifest is a digest rather than a tag
+func isDigestReference(reference string) bool {
+	return strings.Contains(reference, ":")
+}
+
+// detectManifestMediaType gets the media type of the manifest from Content-Type of the response, the mediaType
+// field of the manifest or the structure of the manifest in order
+func detectManifestMediaType(body []byte, contentType string) string {
+	if mediaType, _, e := mime.ParseMediaType(contentType); e == nil {
+		for _, t := range manifestAcceptTypes {
+			if mediaType == t {
+				return mediaType
+			}
+		}
+	}
+
+	var probe struct {
+		SchemaVersion int             `json:"schemaVersion"`
+		MediaType     string          `json:"mediaType"`
+		Manifests     json.RawMessage `json:"manifests"`
+	}
+	if e := json.Unmarshal(body, &probe); e != nil {
+		return ""
+	}
+	if probe.MediaType != "" {
+		return probe.MediaType
+	}
+	if probe.SchemaVersion == 1 {
+		return MediaTypeDockerSchema1Manifest
+	}
+
+	// the mediaType field is optional in OCI manifests and indexes
+	if len(probe.Manifests) > 0 {
+		return MediaTypeOCIIndex
+	}
+
+	return MediaTypeOCIManifest
+}
+
+// parseManifest parses the manifest according to its media type. the platforms of the images in Docker schema 2
+// or OCI manifests are in their config which are not got by parseManifest
+func parseManifest(body []byte, contentType string) (imageManifest, error) {
+	m := imageManifest{mediaType: detectManifestMediaType(body, contentType)}
+
+	switch m.mediaType {
+	case MediaTypeDockerSchema1Manifest, MediaTypeDockerSchema1SignedManifest:
+		v1 := Manifest{}
+		if e := json.Unmarshal(body, &v1); e != nil {
+			return m, fmt.Errorf("can not unmarshal schema 1 manifest: %s", e)
+		}
+		m.tag = v1.Tag
+		m.platform = Platform{Architecture: v1.Architecture}
+		// the layers of schema 1 manifests may be repeated, the sizes of them are not in the manifests
+		seen := make(map[string]bool, 0)
+		for _, l := range v1.FsLayers {
+			if l.BlobSum != "" && !seen[l.BlobSum] {
+				seen[l.BlobSum] = true
+				m.blobs = append(m.blobs, blob{digest: l.BlobSum})
+			}
+		}
+	case MediaTypeDockerSchema2Manifest, MediaTypeOCIManifest:
+		v2 := ManifestV2{}
+		if e := json.Unmarshal(body, &v2); e != nil {
+			return m, fmt.Errorf("can not unmarshal manifest %s: %s", m.mediaType, e)
+		}
+		if v2.Config != nil && v2.Config.Digest != "" {
+			m.config = v2.Config
+			m.blobs = append(m.blobs, blob{digest: v2.Config.Digest, size: v2.Config.Size})
+		}
+		for _, l := range v2.Layers {
+			m.blobs = append(m.blobs, blob{digest: l.Digest, size: l.Size})
+		}
+	case MediaTypeDockerManifestList, MediaTypeOCIIndex:
+		v2 := ManifestV2{}
+		if e := json.Unmarshal(body, &v2); e != nil {
+			return m, fmt.Errorf("can not unmarshal manifest %s: %s", m.mediaType, e)
+		}
+		for _, d := range v2.Manifests {
+			child := imageManifest{mediaType: d.MediaType, digest: d.Digest}
+			if d.Platform != nil {
+				child.platform = *d.Platform
+			}
+			m.children = append(m.children, child)
+		}
+	default:
+		return m, fmt.Errorf("media type %q of the manifest is not supported", m.mediaType)
+	}
+
+	return m, nil
+}
+
+// resolveManifest gets the manifest identified by reference of the image named name from the registry.
+// the child manifests of a manifest list or an index are got too, and the platforms of the images are got
+// from their config if they are not known
+func resolveManifest(name, reference string) (imageManifest, error) {
+	m, e := fetchManifest(name, reference)
+	if e != nil {
+		return m, e
+	}
+
+	if !m.isIndex() {
+		return m, resolvePlatform(name, &m)
+	}
+
+	var children []imageManifest
+	for _, c := range m.children {
+		// the attestation manifests attached by buildx are not images and have unknown/unknown platform
+		if c.platform.OS == "unknown" {
+			continue
+		}
+
+		child, e := fetchManifest(name, c.digest)
+		if e != nil {
+			return m, fmt.Errorf("get child manifest %s error: %s", c.digest, e)
+		}
+		if child.isIndex() {
+			return m, fmt.Errorf("child manifest %s is a nested index which is not supported", c.digest)
+		}
+
+		child.digest = c.digest
+		child.platform = c.platform
+		if e := resolvePlatform(name, &child); e != nil {
+			return m, e
+		}
+		children = append(children, child)
+	}
+	m.children = children
+
+	return m, nil
+}
+
+// resolvePlatform gets the platform of the image from its config if the platform is not known
+func resolvePlatform(name string, m *imageManifest) error {
+	if m.platform.Architecture != "" || m.config == nil {
+		return nil
+	}
+
+	body, resp, errs := doRequest(&requestParams{method: http.MethodGet, url: registryServerUrl() + "/v2/" + name + "/blobs/" + m.config.Digest})
+	logErrors(errs)
+	if resp == nil || resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("can not get config %s of image %s", m.config.Digest, name)
+	}
+
+	platform := Platform{}
+	if e := json.Unmarshal(body, &platform); e != nil {
+		return fmt.Errorf("can not unmarshal config %s of image %s: %s", m.config.Digest, name, e)
+	}
+	m.platform = platform
+
+	return nil
+}
+
+// fetchManifest gets the manifest identified by reference of the image named name from the registry and parses it.
+// the digest of the manifest is set to the digest responsed by the registry or the digest of the content
+func fetchManifest(name, reference string) (imageManifest, error) {
+	var errs []sysadmerror.Sysadmerror
+	r := requestParams{method: http.MethodGet, url: registryServerUrl() + "/v2/" + name + "/manifests/" + reference}
+	r.headers = append(r.headers, httpHeader{key: "Accept", value: strings.Join(manifestAcceptTypes, ", ")})
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20308001, "debug", "try to get manifest with url: %s", r.url))
+	body, resp, err := doRequest(&r)
+	errs = append(errs, err...)
+	logErrors(errs)
+	if resp == nil || resp.StatusCode != http.StatusOK {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		return imageManifest{}, fmt.Errorf("can not get manifest %s of image %s, status: %d", reference, name, status)
+	}
+
+	m, e := parseManifest(body, resp.Header.Get("Content-Type"))
+	if e != nil {
+		return m, e
+	}
+
+	m.digest = resp.Header.Get("Docker-Content-Digest")
+	if m.digest == "" {
+		if isDigestReference(reference) {
+			m.digest = reference
+		} else {
+			sum := sha256.Sum256(body)
+			m.digest = "sha256:" + hex.EncodeToString(sum[:])
+		}
+	}
+
+	return m, nil
+}
+
+// registryServerUrl returns the root url of the registry server which registryctl proxies for
+func registryServerUrl() string {
+	definedConfig := RuntimeData.RuningParas.DefinedConfig
+	host := definedConfig.Registry.Server.Host
+	port := definedConfig.Registry.Server.Port
+	if definedConfig.Registry.Server.Tls {
+		if port == 443 {
+			return "https://" + host
+		}
+		return "https://" + host + ":" + strconv.Itoa(port)
+	}
+
+	if port == 80 {
+		return "http://" + host
+	}
+
+	return "http://" + host + ":" + strconv.Itoa(port)
+}
+
+// buildImage builds the image information which will be recorded in DB from the manifest. reference is used as
+// the tag except for Docker schema 1 manifests which have tags in them. the sizes of the blobs which are not
+// in the manifests are got from the blobs recorded during pushing
+func buildImage(name, reference, username string, m imageManifest, recorded []blob) image {
+	tag := reference
+	if m.tag != "" {
+		tag = m.tag
+	}
+
+	img := manifestToImage(m, recorded)
+	img.name = name
+	img.username = username
+	img.tag = tag
+	for i := range img.children {
+		img.children[i].name = name
+		img.children[i].username = username
+		img.children[i].tag = tag
+	}
+
+	return img
+}
+
+// manifestToImage converts the manifest and its child manifests to image
+func manifestToImage(m imageManifest, recorded []blob) image {
+	sizes := make(map[string]int64, 0)
+	for _, b := range recorded {
+		sizes[strings.ToLower(b.digest)] = b.size
+	}
+
+	img := image{
+		architecture: m.architecture(),
+		digest:       m.digest,
+		mediaType:    m.mediaType,
+		platform:     m.platform.String(),
+	}
+	for _, b := range m.blobs {
+		if b.size == 0 {
+			b.size = sizes[strings.ToLower(b.digest)]
+		}
+		img.blobs = append(img.blobs, b)
+		img.size += b.size
+	}
+	for _, child := range m.children {
+		c := manifestToImage(child, recorded)
+		img.children = append(img.children, c)
+		img.size += c.size
+	}
+
+	return img
+}
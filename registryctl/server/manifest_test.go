@@ -0,0 +1,151 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDigest return the sha256 digest whose hex is filled with c
+func testDigest(c string) string {
+	return "sha256:" + strings.Repeat(c, 64)
+}
+
+func readManifestFixture(t *testing.T, name string) []byte {
+	body, e := os.ReadFile(filepath.Join("testdata", "manifests", name))
+	if e != nil {
+		t.Fatalf("read manifest fixture %s error: %s", name, e)
+	}
+
+	return body
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		contentType string
+		mediaType   string
+		tag         string
+		platform    Platform
+		blobs       []blob
+		children    []imageManifest
+	}{
+		{
+			name: "signed schema 1", fixture: "schema1.json", contentType: MediaTypeDockerSchema1SignedManifest,
+			mediaType: MediaTypeDockerSchema1SignedManifest, tag: "1.36", platform: Platform{Architecture: "amd64"},
+			blobs: []blob{{digest: testDigest("a")}, {digest: testDigest("b")}},
+		},
+		{
+			name: "schema 1 detected by schema version", fixture: "schema1.json",
+			mediaType: MediaTypeDockerSchema1Manifest, tag: "1.36", platform: Platform{Architecture: "amd64"},
+			blobs: []blob{{digest: testDigest("a")}, {digest: testDigest("b")}},
+		},
+		{
+			name: "schema 2 detected by media type field", fixture: "schema2.json", contentType: "application/json",
+			mediaType: MediaTypeDockerSchema2Manifest,
+			blobs:     []blob{{digest: testDigest("c"), size: 1469}, {digest: testDigest("a"), size: 2220094}, {digest: testDigest("b"), size: 512}},
+		},
+		{
+			name: "oci manifest with content type parameters", fixture: "oci-manifest.json", contentType: MediaTypeOCIManifest + "; charset=utf-8",
+			mediaType: MediaTypeOCIManifest,
+			blobs:     []blob{{digest: testDigest("c"), size: 581}, {digest: testDigest("a"), size: 3370706}},
+		},
+		{
+			name: "oci manifest detected by structure", fixture: "oci-manifest.json",
+			mediaType: MediaTypeOCIManifest,
+			blobs:     []blob{{digest: testDigest("c"), size: 581}, {digest: testDigest("a"), size: 3370706}},
+		},
+		{
+			name: "oci index detected by structure", fixture: "oci-index.json",
+			mediaType: MediaTypeOCIIndex,
+			children: []imageManifest{
+				{mediaType: MediaTypeOCIManifest, digest: testDigest("d"), platform: Platform{Architecture: "amd64", OS: "linux"}},
+				{mediaType: MediaTypeOCIManifest, digest: testDigest("e"), platform: Platform{Architecture: "arm64", OS: "linux", Variant: "v8"}},
+				{mediaType: MediaTypeOCIManifest, digest: testDigest("a")},
+			},
+		},
+		{
+			name: "docker manifest list", fixture: "manifest-list.json", contentType: MediaTypeDockerManifestList,
+			mediaType: MediaTypeDockerManifestList,
+			children: []imageManifest{
+				{mediaType: MediaTypeDockerSchema2Manifest, digest: testDigest("d"), platform: Platform{Architecture: "amd64", OS: "linux"}},
+				{mediaType: MediaTypeDockerSchema2Manifest, digest: testDigest("e"), platform: Platform{Architecture: "arm", OS: "linux", Variant: "v7"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		m, e := parseManifest(readManifestFixture(t, tt.fixture), tt.contentType)
+		if e != nil {
+			t.Errorf("%s: parse manifest error: %s", tt.name, e)
+			continue
+		}
+
+		if m.mediaType != tt.mediaType || m.tag != tt.tag || m.platform != tt.platform {
+			t.Errorf("%s: got media type %s tag %q platform %v, want %s %q %v", tt.name, m.mediaType, m.tag, m.platform, tt.mediaType, tt.tag, tt.platform)
+		}
+		if !reflect.DeepEqual(m.blobs, tt.blobs) {
+			t.Errorf("%s: blobs should be %v, got %v", tt.name, tt.blobs, m.blobs)
+		}
+		if !reflect.DeepEqual(m.children, tt.children) {
+			t.Errorf("%s: child manifests should be %v, got %v", tt.name, tt.children, m.children)
+		}
+	}
+}
+
+func TestParseManifestIndex(t *testing.T) {
+	m, e := parseManifest(readManifestFixture(t, "oci-index.json"), "")
+	if e != nil {
+		t.Fatalf("parse oci index error: %s", e)
+	}
+
+	if !m.isIndex() || m.architecture() != "amd64,arm64" {
+		t.Errorf("index should have architectures amd64,arm64, got %q", m.architecture())
+	}
+	if p := m.children[1].platform.String(); p != "linux/arm64/v8" {
+		t.Errorf("platform of the second child should be linux/arm64/v8, got %s", p)
+	}
+
+	m, _ = parseManifest(readManifestFixture(t, "schema2.json"), "")
+	if m.isIndex() || m.config == nil || m.config.Digest != testDigest("c") {
+		t.Errorf("config of schema 2 manifest should be %s, got %v", testDigest("c"), m.config)
+	}
+}
+
+func TestParseManifestUnsupported(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+	}{
+		{"not json", "not json", ""},
+		{"unknown media type", `{"schemaVersion":2,"mediaType":"application/vnd.docker.plugin.v1+json"}`, "application/json"},
+		{"broken schema 2", `{"schemaVersion":2,"layers":"a"}`, MediaTypeDockerSchema2Manifest},
+	}
+
+	for _, tt := range tests {
+		if _, e := parseManifest([]byte(tt.body), tt.contentType); e == nil {
+			t.Errorf("%s: parse manifest should fail", tt.name)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/db"
+	"sysadm/sysadmerror"
+	"sysadm/utils"
+)
+
+// quotaTable is the table which the quotas are stored in
+const quotaTable = "quota"
+
+// kinds of the quotas
+const (
+	QuotaKindProject = "project"
+	QuotaKindUser    = "user"
+)
+
+// quota limits the storage used by the images of a project or the images pushed by a user
+type quota struct {
+	id int
+	// kind of the quota, QuotaKindProject or QuotaKindUser
+	kind string
+	// name of the project or the user
+	name string
+	// the maximum bytes of the unique blobs
+	limit int64
+}
+
+// quotaExceededError is returned when pushing exceeds a quota
+type quotaExceededError struct {
+	q       quota
+	used    int64
+	pushing int64
+}
+
+func (e quotaExceededError) Error() string {
+	return fmt.Sprintf("storage quota of %s %s is exceeded: %d bytes used, %d bytes being pushed, limit %d bytes",
+		e.q.kind, e.q.name, e.used, e.pushing, e.q.limit)
+}
+
+// toMap converts the quota to the data which can be sent to the client
+func (q quota) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"quotaid": q.id,
+		"kind":    q.kind,
+		"name":    q.name,
+		"limit":   q.limit,
+	}
+}
+
+// setQuotaToDB sets the limit of the quota for the project or the user. the quota will be created if it does
+// not exist. return the ID of the quota
+func setQuotaToDB(q quota) (int, error) {
+	if q.kind != QuotaKindProject && q.kind != QuotaKindUser {
+		return 0, fmt.Errorf("kind of quota must be %s or %s", QuotaKindProject, QuotaKindUser)
+	}
+	if q.kind == QuotaKindProject && (!repositoryNamePattern.MatchString(q.name) || strings.Contains(q.name, "/")) {
+		return 0, fmt.Errorf("project name %q is not valid", q.name)
+	}
+	if q.kind == QuotaKindUser && (q.name == "" || strings.ContainsAny(q.name, "\"'\\ ")) {
+		return 0, fmt.Errorf("user name %q is not valid", q.name)
+	}
+	if q.limit <= 0 {
+		return 0, fmt.Errorf("limit of quota must be positive")
+	}
+
+	quotas, e := getQuotasFromDB("", q.kind, q.name)
+	if e != nil {
+		return 0, e
+	}
+
+	now := time.Now().Unix()
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	if len(quotas) > 0 {
+		data := make(db.FieldData, 0)
+		data["limit_bytes"] = q.limit
+		data["update_time"] = now
+		where := map[string]string{"quotaid": "=" + strconv.Itoa(quotas[0].id)}
+		_, err := dbEntity.UpdateData(quotaTable, data, where)
+		logErrors(err)
+		if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+			return 0, fmt.Errorf("can not update quota %d", quotas[0].id)
+		}
+
+		return quotas[0].id, nil
+	}
+
+	id, e := db.NextID(dbEntity, quotaTable, "quotaid")
+	if e != nil {
+		return 0, fmt.Errorf("can not allocate ID for the quota: %s", e)
+	}
+
+	data := make(db.FieldData, 0)
+	data["quotaid"] = id
+	data["kind"] = q.kind
+	data["name"] = q.name
+	data["limit_bytes"] = q.limit
+	data["creation_time"] = now
+	data["update_time"] = now
+	_, err := dbEntity.InsertData(quotaTable, data)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return 0, fmt.Errorf("can not insert the quota into DB")
+	}
+
+	return int(id), nil
+}
+
+// getQuotasFromDB gets the quota identified by quotaid or the quotas of kind and name.
+// the conditions which are empty are ignored
+func getQuotasFromDB(quotaid, kind, name string) ([]quota, error) {
+	whereMap := make(map[string]string, 0)
+	if quotaid != "" {
+		id, e := strconv.Atoi(quotaid)
+		if e != nil {
+			return nil, fmt.Errorf("quota id %s is not valid", quotaid)
+		}
+		whereMap["quotaid"] = "=" + strconv.Itoa(id)
+	}
+	if kind != "" {
+		whereMap["kind"] = "=\"" + sqlStringValue(kind) + "\""
+	}
+	if name != "" {
+		whereMap["name"] = "=\"" + sqlStringValue(name) + "\""
+	}
+
+	selectData := db.SelectData{
+		Tb:        []string{quotaTable},
+		OutFeilds: []string{"quotaid", "kind", "name", "limit_bytes"},
+		Where:     whereMap,
+		Order:     []db.OrderData{{Key: "quotaid", Order: 0}},
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	retData, err := dbEntity.QueryData(&selectData)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get quotas from DB")
+	}
+
+	var quotas []quota
+	for _, line := range retData {
+		id, _ := utils.Interface2Int(line["quotaid"])
+		limit, _ := utils.Interface2Int64(line["limit_bytes"])
+		quotas = append(quotas, quota{
+			id:    id,
+			kind:  utils.Interface2String(line["kind"]),
+			name:  utils.Interface2String(line["name"]),
+			limit: limit,
+		})
+	}
+
+	return quotas, nil
+}
+
+// delQuotaFromDB deletes the quota identified by quotaid
+func delQuotaFromDB(quotaid string) error {
+	id, e := strconv.Atoi(strings.TrimSpace(quotaid))
+	if e != nil {
+		return fmt.Errorf("quota id %s is not valid", quotaid)
+	}
+
+	delData := db.SelectData{
+		Tb:    []string{quotaTable},
+		Where: map[string]string{"quotaid": "=" + strconv.Itoa(id)},
+	}
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	rows, err := dbEntity.DeleteData(&delData)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return fmt.Errorf("can not delete quota %d", id)
+	}
+	if rows == 0 {
+		return fmt.Errorf("quota %d was not found", id)
+	}
+
+	return nil
+}
+
+// quotaBlobs returns the sizes of the unique blobs counted by the quota indexed by their digests.
+// the blobs of the images of the project are counted by a project quota, and the blobs of the tags pushed by
+// the user are counted by a user quota. a blob shared by several tags or images is counted once
+func quotaBlobs(q quota) (map[string]int64, error) {
+	var tagSets []map[string]interface{}
+	switch q.kind {
+	case QuotaKindProject:
+		imgSets, err := getImageInfoFromDB("", "", q.name+"/", "", 0, 0)
+		if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+			logErrors(err)
+			return nil, fmt.Errorf("can not get images of project %s", q.name)
+		}
+		var imageids []string
+		for _, line := range imgSets {
+			if strings.HasPrefix(utils.Interface2String(line["name"]), q.name+"/") {
+				imageids = append(imageids, utils.Interface2String(line["imageid"]))
+			}
+		}
+		if len(imageids) > 0 {
+			var err []sysadmerror.Sysadmerror
+			tagSets, err = getTagInfoFromDB("", strings.Join(imageids, ","), "", "", "", 0, 0)
+			if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+				logErrors(err)
+				return nil, fmt.Errorf("can not get tags of project %s", q.name)
+			}
+		}
+	case QuotaKindUser:
+		userid := userIDOf(q.name)
+		if userid == 0 {
+			return nil, fmt.Errorf("can not get ID of user %s from sysadm server", q.name)
+		}
+		var err []sysadmerror.Sysadmerror
+		tagSets, err = getTagInfoFromDB("", "", "", strconv.Itoa(userid), "", 0, 0)
+		if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+			logErrors(err)
+			return nil, fmt.Errorf("can not get tags of user %s", q.name)
+		}
+	}
+
+	var tagids []string
+	for _, line := range tagSets {
+		tagid := utils.Interface2String(line["tagid"])
+		tagids = append(tagids, tagid)
+		childSets, err := getChildTagsFromDB(tagid)
+		if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+			logErrors(err)
+			return nil, fmt.Errorf("can not get child manifests of tag %s", tagid)
+		}
+		for _, child := range childSets {
+			tagids = append(tagids, utils.Interface2String(child["tagid"]))
+		}
+	}
+
+	blobs := make(map[string]int64, 0)
+	if len(tagids) < 1 {
+		return blobs, nil
+	}
+	blobSets, err := getBlobInfoFromDB("", strings.Join(tagids, ","), "")
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get blobs of %s %s", q.kind, q.name)
+	}
+	for _, line := range blobSets {
+		size, _ := utils.Interface2Int64(line["size"])
+		blobs[strings.ToLower(utils.Interface2String(line["digest"]))] = size
+	}
+
+	return blobs, nil
+}
+
+// sumBlobs returns the sum of the sizes of the blobs
+func sumBlobs(blobs map[string]int64) int64 {
+	var sum int64 = 0
+	for _, size := range blobs {
+		sum += size
+	}
+
+	return sum
+}
+
+// userIDOf gets the ID of the user named username from sysadm server. return 0 if the user was not found or
+// sysadm server can not be accessed
+func userIDOf(username string) int {
+	definedConfig := RuntimeData.RuningParas.DefinedConfig
+	userid, errs := getUserIdByUsername(definedConfig.Sysadm.Server.Tls, definedConfig.Sysadm.Server.Host,
+		definedConfig.Sysadm.Server.Port, definedConfig.Sysadm.ApiVerion, username)
+	logErrors(errs)
+
+	return userid
+}
+
+// checkQuota checks whether pushing the blobs to the image named imageName by the user named username exceeds
+// the quota of the project of the image or the quota of the user. the blobs which have been counted by a quota
+// do not increase the usage of it. quotaExceededError is returned if a quota is exceeded. otherwise the bytes
+// which can be pushed in addition to the blobs before the quotas are exceeded are returned, -1 is returned if
+// no quota counts the blobs
+func checkQuota(imageName, username string, pushing map[string]int64) (int64, error) {
+	project := strings.Split(imageName, "/")[0]
+	var quotas []quota
+	projectQuotas, e := getQuotasFromDB("", QuotaKindProject, project)
+	if e != nil {
+		return 0, e
+	}
+	quotas = append(quotas, projectQuotas...)
+	if username != "" {
+		userQuotas, e := getQuotasFromDB("", QuotaKindUser, username)
+		if e != nil {
+			return 0, e
+		}
+		quotas = append(quotas, userQuotas...)
+	}
+
+	var headroom int64 = -1
+	for _, q := range quotas {
+		used, e := quotaBlobs(q)
+		if e != nil {
+			return 0, e
+		}
+
+		var adding int64 = 0
+		uncounted := false
+		for digest, size := range pushing {
+			if _, ok := used[strings.ToLower(digest)]; !ok {
+				adding += size
+				uncounted = true
+			}
+		}
+
+		usedBytes := sumBlobs(used)
+		if usedBytes+adding > q.limit {
+			return 0, quotaExceededError{q: q, used: usedBytes, pushing: adding}
+		}
+		if uncounted && (headroom < 0 || q.limit-usedBytes-adding < headroom) {
+			headroom = q.limit - usedBytes - adding
+		}
+	}
+
+	return headroom, nil
+}
+
+// enforceQuotaOnBlob checks the quotas when a blob is uploaded monolithically or an upload is completed.
+// the size of the blob is the bytes which have been uploaded in chunks plus the length of the request body.
+// if the length of the body is not known in advance, the body is read until the quotas are exceeded only.
+// the response will be sent to the client and false will be returned if a quota is exceeded or the quotas can
+// not be checked
+func enforceQuotaOnBlob(c *sysadmServer.Context, imageName string) bool {
+	digest := strings.TrimSpace(c.Query("digest"))
+	if digest == "" {
+		return true
+	}
+
+	size, e := uploadedSize(c)
+	if e != nil {
+		return refuseUncheckedQuota(c, imageName, e)
+	}
+	if c.Request.ContentLength > 0 {
+		size += c.Request.ContentLength
+	}
+	username, _, _ := c.Request.BasicAuth()
+
+	headroom, ok := enforceQuota(c, imageName, username, map[string]int64{digest: size})
+	if ok && c.Request.ContentLength < 0 && headroom >= 0 && c.Request.Body != nil {
+		c.Request.Body = &quotaLimitedBody{ReadCloser: c.Request.Body, imageName: imageName, remaining: headroom}
+	}
+
+	return ok
+}
+
+// uploadedSize gets the bytes which have been uploaded in chunks to the upload completed by the request from the
+// registry. 0 is returned if the request does not complete an upload. the registry reports the uploaded bytes in
+// Range header of the upload status in the form of 0-<offset>, which is 0-0 when nothing has been uploaded, so
+// one more byte is counted for an empty upload
+func uploadedSize(c *sysadmServer.Context) (int64, error) {
+	path := c.Param("path")
+	i := strings.Index(path, "/blobs/uploads/")
+	if c.Request.Method != http.MethodPut || i < 0 || strings.Trim(path[i+len("/blobs/uploads/"):], "/") == "" {
+		return 0, nil
+	}
+
+	// the state of the upload is in the query of the request
+	query := c.Request.URL.Query()
+	query.Del("digest")
+	r := requestParams{method: http.MethodGet, url: registryServerUrl() + "/v2" + path}
+	if len(query) > 0 {
+		r.url += "?" + query.Encode()
+	}
+	_, resp, err := doRequest(&r)
+	if resp == nil || resp.StatusCode != http.StatusNoContent {
+		logErrors(err)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		return 0, fmt.Errorf("can not get status of upload %s, status: %d", path, status)
+	}
+
+	bytesRange := strings.SplitN(resp.Header.Get("Range"), "-", 2)
+	if len(bytesRange) == 2 {
+		if offset, e := strconv.ParseInt(bytesRange[1], 10, 64); e == nil {
+			return offset + 1, nil
+		}
+	}
+
+	return 0, fmt.Errorf("range %q of upload %s is not valid", resp.Header.Get("Range"), path)
+}
+
+// quotaLimitedBody is the body of an upload whose length is not known in advance. reading it fails once more
+// bytes than remaining are read, then the upload is aborted before the quotas are exceeded
+type quotaLimitedBody struct {
+	io.ReadCloser
+	imageName string
+	remaining int64
+}
+
+func (b *quotaLimitedBody) Read(p []byte) (int, error) {
+	n, e := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20311004, "info", "uploading blob to image %s is aborted: storage quota is exceeded", b.imageName)})
+		return 0, fmt.Errorf("storage quota of image %s is exceeded", b.imageName)
+	}
+
+	return n, e
+}
+
+// enforceQuotaOnManifest checks the quotas when a manifest is pushed. the blobs referenced by the manifest, or by
+// the child manifests of a manifest list or an index, are counted. the response will be sent to the client and
+// false will be returned if a quota is exceeded
+func enforceQuotaOnManifest(c *sysadmServer.Context, imageName string) bool {
+	body, e := ioutil.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	// the body is sent to the registry after checking
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20311001, "error", "can not read manifest of image %s: %s", imageName, e)})
+		responseErrorToClient("manifest_invalid", c)
+		return false
+	}
+
+	m, e := parseManifest(body, c.Request.Header.Get("Content-Type"))
+	if e != nil {
+		// the registry validates the manifests and responses the errors to the client
+		return true
+	}
+
+	pushing := make(map[string]int64, 0)
+	for _, b := range m.blobs {
+		pushing[b.digest] = b.size
+	}
+	for _, child := range m.children {
+		childManifest, e := fetchManifest(imageName, child.digest)
+		if e != nil {
+			continue
+		}
+		for _, b := range childManifest.blobs {
+			pushing[b.digest] = b.size
+		}
+	}
+	username, _, _ := c.Request.BasicAuth()
+	_, ok := enforceQuota(c, imageName, username, pushing)
+
+	return ok
+}
+
+// enforceQuota checks the quotas and responses DENIED error with the usage to the client if a quota is exceeded.
+// the pushing is refused too if the quotas can not be checked, otherwise it could exceed the quotas while DB or
+// sysadm server is unavailable. return the headroom got by checkQuota and whether the pushing is allowed
+func enforceQuota(c *sysadmServer.Context, imageName, username string, pushing map[string]int64) (int64, bool) {
+	headroom, e := checkQuota(imageName, username, pushing)
+	if e == nil {
+		return headroom, true
+	}
+
+	exceeded, ok := e.(quotaExceededError)
+	if !ok {
+		return 0, refuseUncheckedQuota(c, imageName, e)
+	}
+
+	logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20311003, "info", "pushing image %s by %s is denied: %s", imageName, username, exceeded)})
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	c.JSON(http.StatusForbidden, ReponseError{Errors: []BodyError{{
+		Code:    RegistryErrs["denied"].Code,
+		Message: exceeded.Error(),
+		Detail:  RegistryErrs["denied"].Detail,
+	}}})
+
+	return 0, false
+}
+
+// refuseUncheckedQuota responses the error to the client when the quotas can not be checked because of e.
+// it always returns false
+func refuseUncheckedQuota(c *sysadmServer.Context, imageName string, e error) bool {
+	logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20311002, "error", "can not check quota for image %s: %s", imageName, e)})
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	c.JSON(http.StatusServiceUnavailable, ReponseError{Errors: []BodyError{{
+		Code:    RegistryErrs["internal_error"].Code,
+		Message: "can not check storage quota, please try again later",
+		Detail:  RegistryErrs["internal_error"].Detail,
+	}}})
+
+	return false
+}
+
+// imageNameOfPath gets the name of the image from the path of the request of registry API, such as
+// /<name>/manifests/<reference>, /<name>/blobs/<digest> and /<name>/blobs/uploads/<uuid>
+func imageNameOfPath(path string) string {
+	path = strings.Trim(path, "/")
+	for _, sep := range []string{"/manifests/", "/blobs/", "/tags/"} {
+		if i := strings.LastIndex(path, sep); i > 0 {
+			return path[:i]
+		}
+	}
+
+	return path
+}
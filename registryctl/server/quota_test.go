@@ -0,0 +1,185 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/wangyysde/sysadmServer"
+	sysadmDB "sysadm/db"
+	"sysadm/registryctl/config"
+)
+
+// newQuotaTest sets the quota of project lib to limit bytes, 100 bytes of which are used by the blob
+// testDigest("1") of lib/app:v1. the registry reports the bytes uploaded to the uploads as the ranges in ranges
+func newQuotaTest(t *testing.T, limit int64, ranges map[string]string) *gcDB {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bytesRange, ok := ranges[r.URL.Path]
+		if r.Method != http.MethodGet || !ok || r.URL.Query().Get("_state") == "" || r.URL.Query().Get("digest") != "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Range", bytesRange)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+	conf := &config.Config{}
+	conf.Registry.Server = config.RegistryServer{Host: u.Hostname(), Port: port}
+
+	db := &gcDB{tables: map[string][]sysadmDB.FieldData{
+		"quota": {
+			{"quotaid": 1, "kind": QuotaKindProject, "name": "lib", "limit_bytes": limit},
+		},
+		"image": {
+			{"imageid": "1", "name": "lib/app"},
+		},
+		"tag": {
+			{"tagid": "10", "imageid": "1", "name": "v1", "digest": testDigest("0"), "parentid": "0"},
+		},
+		"blob": {
+			{"blobid": "1", "tagid": "10", "digest": testDigest("1"), "size": int64(100)},
+		},
+	}, broken: map[string]bool{}}
+
+	old := *RuntimeData.RuningParas
+	RuntimeData.RuningParas.DefinedConfig = conf
+	RuntimeData.RuningParas.DBConfig = &sysadmDB.DbConfig{Entity: db}
+	t.Cleanup(func() { *RuntimeData.RuningParas = old })
+
+	return db
+}
+
+// newBlobContext returns the context of the request which uploads a blob to the path
+func newBlobContext(method, path string, body io.Reader) (*sysadmServer.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := sysadmServer.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/v2"+path, body)
+	c.Params = sysadmServer.Params{{Key: "path", Value: strings.SplitN(path, "?", 2)[0]}}
+
+	return c, w
+}
+
+func TestCheckQuota(t *testing.T) {
+	newQuotaTest(t, 1000, nil)
+
+	tests := []struct {
+		name     string
+		pushing  map[string]int64
+		headroom int64
+		exceeded bool
+	}{
+		{"counted blob", map[string]int64{testDigest("1"): 100}, -1, false},
+		{"new blob", map[string]int64{testDigest("1"): 100, testDigest("2"): 500}, 400, false},
+		{"new blob using up the quota", map[string]int64{testDigest("2"): 900}, 0, false},
+		{"new blob exceeding the quota", map[string]int64{testDigest("2"): 901}, 0, true},
+	}
+
+	for _, tt := range tests {
+		headroom, e := checkQuota("lib/app", "", tt.pushing)
+		if _, ok := e.(quotaExceededError); ok != tt.exceeded {
+			t.Errorf("%s: quota exceeded should be %v, got error %v", tt.name, tt.exceeded, e)
+		}
+		if e == nil && headroom != tt.headroom {
+			t.Errorf("%s: headroom should be %d, got %d", tt.name, tt.headroom, headroom)
+		}
+	}
+}
+
+func TestEnforceQuotaOnChunkedUpload(t *testing.T) {
+	newQuotaTest(t, 1000, map[string]string{
+		"/v2/lib/app/blobs/uploads/full": "0-899",
+		"/v2/lib/app/blobs/uploads/over": "0-900",
+	})
+
+	tests := []struct {
+		upload string
+		body   string
+		status int
+	}{
+		{"full", "", http.StatusOK},
+		{"full", "x", http.StatusForbidden},
+		{"over", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		// docker uploads the blob with PATCH, then completes the upload with PUT and an empty body
+		path := "/lib/app/blobs/uploads/" + tt.upload + "?_state=state&digest=" + testDigest("2")
+		c, w := newBlobContext(http.MethodPut, path, strings.NewReader(tt.body))
+		ok := enforceQuotaOnBlob(c, "lib/app")
+		if ok != (tt.status == http.StatusOK) || (!ok && w.Code != tt.status) {
+			t.Errorf("upload %s with body %q: status should be %d, got allowed %v status %d", tt.upload, tt.body, tt.status, ok, w.Code)
+		}
+	}
+}
+
+func TestEnforceQuotaOnUnknownLength(t *testing.T) {
+	newQuotaTest(t, 1000, nil)
+
+	for _, size := range []int{900, 901} {
+		c, _ := newBlobContext(http.MethodPost, "/lib/app/blobs/uploads/?digest="+testDigest("2"), strings.NewReader(strings.Repeat("x", size)))
+		c.Request.ContentLength = -1
+		if !enforceQuotaOnBlob(c, "lib/app") {
+			t.Fatalf("upload of unknown length should be allowed until the quota is exceeded")
+		}
+
+		_, e := io.ReadAll(c.Request.Body)
+		if (e != nil) != (size > 900) {
+			t.Errorf("reading %d bytes with 900 bytes headroom: got error %v", size, e)
+		}
+	}
+}
+
+func TestEnforceQuotaFailsClosed(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		setup func(db *gcDB)
+	}{
+		{"quotas can not be got", "/lib/app/blobs/uploads/?digest=" + testDigest("2"), func(db *gcDB) { db.broken["quota"] = true }},
+		{"tags can not be got", "/lib/app/blobs/uploads/?digest=" + testDigest("2"), func(db *gcDB) { db.broken["tag"] = true }},
+		{"blobs can not be got", "/lib/app/blobs/uploads/?digest=" + testDigest("2"), func(db *gcDB) { db.broken["blob"] = true }},
+		{"upload status can not be got", "/lib/app/blobs/uploads/unknown?_state=state&digest=" + testDigest("2"), func(db *gcDB) {}},
+		{"user can not be got from sysadm server", "/lib/app/blobs/uploads/?digest=" + testDigest("2"), func(db *gcDB) {
+			db.tables["quota"] = append(db.tables["quota"], sysadmDB.FieldData{"quotaid": 2, "kind": QuotaKindUser, "name": "dev", "limit_bytes": int64(1000)})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(newQuotaTest(t, 1000, nil))
+			method := http.MethodPost
+			if !strings.Contains(tt.path, "uploads/?") {
+				method = http.MethodPut
+			}
+			c, w := newBlobContext(method, tt.path, strings.NewReader("x"))
+			c.Request.SetBasicAuth("dev", "secret")
+
+			if enforceQuotaOnBlob(c, "lib/app") || w.Code != http.StatusServiceUnavailable {
+				t.Errorf("pushing should be refused with status %d, got %d", http.StatusServiceUnavailable, w.Code)
+			}
+		})
+	}
+}
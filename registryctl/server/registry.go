@@ -30,6 +30,7 @@ import (
 
 	sysadm "sysadm/sysadm/server"
 	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
 	"github.com/wangyysde/sysadmServer"
 )
 
@@ -120,6 +121,11 @@ var RegistryErrs = map[string]BodyError{
 		Message: "internal error has occurred.",
 		Detail: "internal error has occurred.",
 	},
+	"read_only": {
+		Code: "UNSUPPORTED",
+		Message: "registry is in read-only mode",
+		Detail: "The registry is in maintenance window for garbage collection, please try again later.",
+	},
 }
 type ReponseError struct {
 	Errors []BodyError `json:"errors"`
@@ -172,6 +178,16 @@ func getHandlers(c *sysadmServer.Context) {
 		return 
 	}
 
+	if !authorizeRequest(c,permissionOfMethod(c.Request.Method)) {
+		logErrors(errs)
+		return 
+	}
+
+	if strings.Contains(path,"/manifests/") && !enforceSignatureOnPull(c,imageNameOfPath(path),path[strings.LastIndex(path,"/")+1:]) {
+		logErrors(errs)
+		return 
+	}
+
 	reverseProxyDirector := buildReverseProxyDirector(c)
 	if reverseProxyDirector == nil {
 		logErrors(errs)
@@ -203,6 +219,17 @@ func postHandlers(c *sysadmServer.Context) {
 	path := strings.TrimSpace(c.Param("path")) 
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20304004,"debug","handling POST handlers for PATH %s",path))
 	logErrors(errs)
+	if !authorizeRequest(c,permissionOfMethod(c.Request.Method)) {
+		return 
+	}
+	if rejectWhenReadOnly(c) {
+		return 
+	}
+	restrictBlobMount(c)
+	// blobs can be uploaded monolithically with POST method
+	if !enforceQuotaOnBlob(c,imageNameOfPath(path)) {
+		return 
+	}
 	passProxy(c)
 }
 
@@ -217,6 +244,10 @@ func headHandlers(c *sysadmServer.Context) {
 	// gets image name from uri of the request 
 	path := c.Param("path")
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20304002,"debug","handling HEAD handlers for PATH %s",path))
+	if !authorizeRequest(c,permissionOfMethod(c.Request.Method)) {
+		logErrors(errs)
+		return 
+	}
 	pathArray := strings.Split(path,"/")
 	arrayLen := len(pathArray)
 
@@ -279,10 +310,23 @@ func putHandlers(c *sysadmServer.Context) {
 	path := c.Param("path")
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20304005,"debug","handling PUT handlers for PATH %s",path))
 	logErrors(errs)
+	if !authorizeRequest(c,permissionOfMethod(c.Request.Method)) {
+		return 
+	}
+	if rejectWhenReadOnly(c) {
+		return 
+	}
 	pathArray := strings.Split(path,"/")
 	arrayLen := len(pathArray)
 	action := pathArray[(arrayLen -2)]
+	imageName := imageNameOfPath(path)
 	if strings.TrimSpace(strings.ToLower(action)) == "manifests"{
+		if !enforceImmutableTag(c,imageName,pathArray[(arrayLen -1)]) {
+			return 
+		}
+		if !enforceQuotaOnManifest(c,imageName) {
+			return 
+		}
 		director = buildReverseProxyDirector(c)
 		modifyResponse = putManifestsResponse(c)
 		if director == nil {
@@ -291,6 +335,10 @@ func putHandlers(c *sysadmServer.Context) {
 		}
 	}
 	
+	if strings.Contains(path,"/blobs/uploads/") && !enforceQuotaOnBlob(c,imageName) {
+		return 
+	}
+
 	if director == nil {
 		director = buildReverseProxyDirector(c)
 	}
@@ -326,6 +374,12 @@ func patchHandlers(c *sysadmServer.Context) {
 	path := c.Param("path")
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20304006,"debug","handling PATCH handlers for PATH %s",path))
 	logErrors(errs)
+	if !authorizeRequest(c,permissionOfMethod(c.Request.Method)) {
+		return 
+	}
+	if rejectWhenReadOnly(c) {
+		return 
+	}
 	passProxy(c)
 }
 
@@ -340,6 +394,15 @@ func deleteHandlers(c *sysadmServer.Context) {
 	path := c.Param("path")
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20304007,"debug","handling DELETE handlers for PATH %s",path))
 	logErrors(errs)
+	if !authorizeRequest(c,permissionOfMethod(c.Request.Method)) {
+		return 
+	}
+	if rejectWhenReadOnly(c) {
+		return 
+	}
+	if strings.Contains(path,"/manifests/") && !enforceImmutableManifest(c,imageNameOfPath(path),path[strings.LastIndex(path,"/")+1:]) {
+		return 
+	}
 	
 	reverseProxyDirector := buildReverseProxyDirector(c)
 	if reverseProxyDirector == nil {
@@ -449,28 +512,14 @@ func isLogin(username string, password string) bool {
 		return false
 	}
 
-	var reqUrl string = ""
-	m := sysadm.Modules
-	definedConfig := RuntimeData.RuningParas.DefinedConfig 
-	if definedConfig.Sysadm.Server.Tls {
-		if definedConfig.Sysadm.Server.Port == 443 {
-			reqUrl = "https://" + definedConfig.Sysadm.Server.Host + "/api/" + definedConfig.Sysadm.ApiVerion + "/" + m["user"].Path +"/login" 
-		} else {
-			reqUrl = "https://" + definedConfig.Sysadm.Server.Host + ":" + strconv.Itoa(definedConfig.Sysadm.Server.Port) + "/api/" + definedConfig.Sysadm.ApiVerion + "/" + m["user"].Path +"/login" 
-		}
-	}else {
-		if definedConfig.Sysadm.Server.Port == 80 {
-			reqUrl = "http://" + definedConfig.Sysadm.Server.Host + "/api/" + definedConfig.Sysadm.ApiVerion  + "/" + m["user"].Path +"/login"
-		} else {
-			reqUrl = "http://" + definedConfig.Sysadm.Server.Host + ":" + strconv.Itoa(definedConfig.Sysadm.Server.Port) + "/api/" + definedConfig.Sysadm.ApiVerion +  "/" + m["user"].Path +"/login"
-		}
-	}
-
+	reqUrl := sysadmUserApiUrl("login")
 	var requestParams requestParams = requestParams{}
 	requestParams.url = reqUrl
 	requestParams.method = "POST"
 	requestParams.data = append(requestParams.data,&requestData{key: "username", value: username})
 	requestParams.data = append(requestParams.data,&requestData{key: "password", value: password})
+	// the password may be an API token, which should be allowed to pull images at least
+	requestParams.data = append(requestParams.data,&requestData{key: "scope", value: userApp.ApiTokenScopeRegistryPull})
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(2030005,"debug","try to execute the request with:%s",reqUrl))
 	body,err := sendRequest(&requestParams)
 	errs = append(errs, err...)
@@ -500,6 +549,31 @@ func isLogin(username string, password string) bool {
 	return ret.Status
 }
 
+/*
+	sysadmUserApiUrl build the url of the action of user module on sysadm server
+*/
+func sysadmUserApiUrl(action string) string {
+	var reqUrl string = ""
+	m := sysadm.Modules
+	definedConfig := RuntimeData.RuningParas.DefinedConfig 
+	if definedConfig.Sysadm.Server.Tls {
+		if definedConfig.Sysadm.Server.Port == 443 {
+			reqUrl = "https://" + definedConfig.Sysadm.Server.Host + "/api/" + definedConfig.Sysadm.ApiVerion + "/" + m["user"].Path +"/" + action 
+		} else {
+			reqUrl = "https://" + definedConfig.Sysadm.Server.Host + ":" + strconv.Itoa(definedConfig.Sysadm.Server.Port) + "/api/" + definedConfig.Sysadm.ApiVerion + "/" + m["user"].Path +"/" + action 
+		}
+	}else {
+		if definedConfig.Sysadm.Server.Port == 80 {
+			reqUrl = "http://" + definedConfig.Sysadm.Server.Host + "/api/" + definedConfig.Sysadm.ApiVerion  + "/" + m["user"].Path +"/" + action
+		} else {
+			reqUrl = "http://" + definedConfig.Sysadm.Server.Host + ":" + strconv.Itoa(definedConfig.Sysadm.Server.Port) + "/api/" + definedConfig.Sysadm.ApiVerion +  "/" + m["user"].Path +"/" + action
+		}
+	}
+
+
+	return reqUrl
+}
+
 /*
 	passProxy: 1. set BasicAuth on request and
 	2. change the host and port of the request to registry server
@@ -61,6 +61,54 @@ func apiV1PostHandlers(c *sysadmServer.Context){
 	case "taglist":
 		err := entity.tagList(c)
 		errs = append(errs,err...)
+	case "gc":
+		err := entity.garbageCollect(c)
+		errs = append(errs,err...)
+	case "gcreport":
+		err := entity.gcReport(c)
+		errs = append(errs,err...)
+	case "retentionadd":
+		err := entity.retentionAdd(c)
+		errs = append(errs,err...)
+	case "retentionlist":
+		err := entity.retentionList(c)
+		errs = append(errs,err...)
+	case "retentionpreview":
+		err := entity.retentionRun(c,true)
+		errs = append(errs,err...)
+	case "retentionrun":
+		err := entity.retentionRun(c,false)
+		errs = append(errs,err...)
+	case "quotaset":
+		err := entity.quotaSet(c)
+		errs = append(errs,err...)
+	case "quotalist":
+		err := entity.quotaList(c)
+		errs = append(errs,err...)
+	case "endpointadd":
+		err := entity.endpointAdd(c)
+		errs = append(errs,err...)
+	case "endpointlist":
+		err := entity.endpointList(c)
+		errs = append(errs,err...)
+	case "replicationadd":
+		err := entity.replicationAdd(c)
+		errs = append(errs,err...)
+	case "replicationlist":
+		err := entity.replicationList(c)
+		errs = append(errs,err...)
+	case "replicationrun":
+		err := entity.replicationRun(c)
+		errs = append(errs,err...)
+	case "replicationhistory":
+		err := entity.replicationHistory(c)
+		errs = append(errs,err...)
+	case "immutableadd":
+		err := entity.immutableAdd(c)
+		errs = append(errs,err...)
+	case "immutablelist":
+		err := entity.immutableList(c)
+		errs = append(errs,err...)
 	default: 
 		err := entity.ActionNotFound(c,action)
 		errs = append(errs,err...)
@@ -83,6 +131,21 @@ func apiV1DeleteHandlers(c *sysadmServer.Context){
 	case "tagdel":
 		err := entity.tagDelHandler(c)
 		errs = append(errs, err...)
+	case "retentiondel":
+		err := entity.retentionDel(c)
+		errs = append(errs, err...)
+	case "quotadel":
+		err := entity.quotaDel(c)
+		errs = append(errs, err...)
+	case "endpointdel":
+		err := entity.endpointDel(c)
+		errs = append(errs, err...)
+	case "replicationdel":
+		err := entity.replicationDel(c)
+		errs = append(errs, err...)
+	case "immutabledel":
+		err := entity.immutableDel(c)
+		errs = append(errs, err...)
 	default: 
 		err := entity.ActionNotFound(c,action)
 		errs = append(errs,err...)
@@ -239,6 +302,14 @@ func (r RegistryCtl)tagDel(c *sysadmServer.Context, tagID string, digest string)
 	} else {
 		res,_ = getTagInfoFromDB("","","","",digest,0,0)
 	}
+
+	// the child manifests of manifest lists or indexes are deleted with them
+	var children []map[string]interface{}
+	for _,line := range res {
+		childSets,_ := getChildTagsFromDB(utils.Interface2String(line["tagid"]))
+		children = append(children, childSets...)
+	}
+	res = append(res, children...)
 	
 	for _,line := range res {
 		tmpTagid := utils.Interface2String(line["tagid"])
@@ -381,6 +452,622 @@ func (r RegistryCtl)tagList(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror)
 	return errs
 }
 
+/*
+	garbageCollect runs garbage collection which deletes the blobs not referenced by any tag and responses the report.
+	dryrun: the unreferenced blobs are found but not deleted if it is true
+*/
+func (r RegistryCtl)garbageCollect(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"dryrun"})
+	errs = append(errs,err...)
+	dryRun := false
+	if dryRunStr := strings.TrimSpace(dataMap["dryrun"]); dryRunStr != "" {
+		v,e := strconv.ParseBool(dryRunStr)
+		if e != nil {
+			msg := "dryrun must be true or false"
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600016,"error",msg))
+			err := apiutils.SendResponseForErrorMessage(c,20600016,msg)
+			errs = append(errs,err...)
+			return errs
+		}
+		dryRun = v
+	}
+
+	report,e := runGarbageCollection(dryRun)
+	if e != nil {
+		msg := fmt.Sprintf("garbage collection error: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600017,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600017,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	err = apiutils.SendResponseForMap(c,[]map[string]interface{}{gcReportToMap(report)})
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	gcReport responses the report of the last garbage collection and whether the registry is in read-only mode
+*/
+func (r RegistryCtl)gcReport(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	data := gcReportToMap(getLastGCReport())
+	data["readOnly"] = isReadOnly()
+	err := apiutils.SendResponseForMap(c,[]map[string]interface{}{data})
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	retentionAdd adds a retention policy for a project or a repository according to "project","repository","keeplast","keepregex","expiredays"
+	project: the name of the project
+	repository: the name of the image. the policy applies to all images of the project if it is empty
+	keeplast: the number of the most recently pushed tags which are kept
+	keepregex: the tags whose names match the regular expression are kept
+	expiredays: the tags which have been pushed or pulled in the days are kept
+*/
+func (r RegistryCtl)retentionAdd(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"project","repository","keeplast","keepregex","expiredays"})
+	errs = append(errs,err...)
+
+	policy := retentionPolicy{
+		project: strings.TrimSpace(dataMap["project"]),
+		repository: strings.TrimSpace(dataMap["repository"]),
+		keepRegex: strings.TrimSpace(dataMap["keepregex"]),
+	}
+	var e error
+	if keepLastStr := strings.TrimSpace(dataMap["keeplast"]); keepLastStr != "" {
+		policy.keepLast,e = strconv.Atoi(keepLastStr)
+	}
+	if expireDaysStr := strings.TrimSpace(dataMap["expiredays"]); e == nil && expireDaysStr != "" {
+		policy.expireDays,e = strconv.Atoi(expireDaysStr)
+	}
+	if e != nil {
+		msg := "keeplast and expiredays must be numbers"
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600018,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600018,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	id,e := addRetentionPolicyToDB(policy)
+	if e != nil {
+		msg := fmt.Sprintf("can not add retention policy: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600019,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600019,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	policy.id = id
+	err = apiutils.SendResponseForMap(c,[]map[string]interface{}{policy.toMap()})
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	retentionList lists the retention policies. only the policies of the project are listed if "project" is not empty
+*/
+func (r RegistryCtl)retentionList(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"project"})
+	errs = append(errs,err...)
+
+	policies,e := getRetentionPoliciesFromDB("",strings.TrimSpace(dataMap["project"]))
+	if e != nil {
+		msg := fmt.Sprintf("can not list retention policies: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600020,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600020,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	var dataSet []map[string]interface{}
+	for _,p := range policies {
+		dataSet = append(dataSet,p.toMap())
+	}
+	err = apiutils.SendResponseForMap(c,dataSet)
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	retentionDel deletes the retention policy identified by "policyid"
+*/
+func (r RegistryCtl)retentionDel(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"policyid"})
+	errs = append(errs,err...)
+
+	e := delRetentionPolicyFromDB(dataMap["policyid"])
+	if e != nil {
+		msg := fmt.Sprintf("can not delete retention policy: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20700002,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20700002,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	err = apiutils.SendResponseForSuccessMessage(c,"retention policy has be deleted.")
+	errs = append(errs,err...)
+	return errs
+}
+
+/*
+	retentionRun evaluates the retention policies and responses the tags which are not kept by them. the tags are
+	deleted unless dryRun is true. only the policy identified by "policyid" is evaluated if it is not empty
+*/
+func (r RegistryCtl)retentionRun(c *sysadmServer.Context, dryRun bool) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"policyid"})
+	errs = append(errs,err...)
+
+	candidates,e := runRetention(strings.TrimSpace(dataMap["policyid"]),dryRun)
+	if e != nil {
+		msg := fmt.Sprintf("can not evaluate retention policies: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600021,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600021,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	var dataSet []map[string]interface{}
+	for _,candidate := range candidates {
+		dataSet = append(dataSet,candidate.toMap())
+	}
+	err = apiutils.SendResponseForMap(c,dataSet)
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	quotaSet sets the storage quota of a project or a user according to "kind","name","limit"
+	kind: project or user
+	name: the name of the project or the user
+	limit: the maximum bytes of the unique blobs which can be stored for the project or the user
+*/
+func (r RegistryCtl)quotaSet(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"kind","name","limit"})
+	errs = append(errs,err...)
+
+	limit,e := strconv.ParseInt(strings.TrimSpace(dataMap["limit"]),10,64)
+	if e != nil {
+		msg := "limit must be a number"
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600022,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600022,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	q := quota{
+		kind: strings.ToLower(strings.TrimSpace(dataMap["kind"])),
+		name: strings.TrimSpace(dataMap["name"]),
+		limit: limit,
+	}
+	id,e := setQuotaToDB(q)
+	if e != nil {
+		msg := fmt.Sprintf("can not set quota: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600023,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600023,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	q.id = id
+	err = apiutils.SendResponseForMap(c,[]map[string]interface{}{q.toMap()})
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	quotaList lists the quotas with the bytes used by them. only the quotas of the kind are listed if "kind"
+	is not empty
+*/
+func (r RegistryCtl)quotaList(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"kind","name"})
+	errs = append(errs,err...)
+
+	quotas,e := getQuotasFromDB("",strings.ToLower(strings.TrimSpace(dataMap["kind"])),strings.TrimSpace(dataMap["name"]))
+	if e != nil {
+		msg := fmt.Sprintf("can not list quotas: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600024,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600024,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	var dataSet []map[string]interface{}
+	for _,q := range quotas {
+		line := q.toMap()
+		blobs,e := quotaBlobs(q)
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600025,"warning","can not get the usage of quota %d: %s",q.id,e))
+		}
+		line["used"] = sumBlobs(blobs)
+		dataSet = append(dataSet,line)
+	}
+	err = apiutils.SendResponseForMap(c,dataSet)
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	quotaDel deletes the quota identified by "quotaid"
+*/
+func (r RegistryCtl)quotaDel(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"quotaid"})
+	errs = append(errs,err...)
+
+	e := delQuotaFromDB(dataMap["quotaid"])
+	if e != nil {
+		msg := fmt.Sprintf("can not delete quota: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20700003,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20700003,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	err = apiutils.SendResponseForSuccessMessage(c,"quota has be deleted.")
+	errs = append(errs,err...)
+	return errs
+}
+
+/*
+	endpointAdd adds a registry which the images are replicated to or from according to "name","url","username","password"
+	name: the name of the endpoint
+	url: the root url of the registry, such as https://registry.example.com
+	username,password: the credentials for the registry. they can be empty if the registry can be accessed anonymously
+*/
+func (r RegistryCtl)endpointAdd(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"name","url","username","password"})
+	errs = append(errs,err...)
+
+	ep := replicationEndpoint{
+		name: strings.TrimSpace(dataMap["name"]),
+		url: strings.TrimSpace(dataMap["url"]),
+		username: strings.TrimSpace(dataMap["username"]),
+		password: dataMap["password"],
+	}
+	id,e := addReplicationEndpointToDB(ep)
+	if e != nil {
+		msg := fmt.Sprintf("can not add replication endpoint: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600026,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600026,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	ep.id = id
+	err = apiutils.SendResponseForMap(c,[]map[string]interface{}{ep.toMap()})
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	endpointList lists the replication endpoints without their passwords
+*/
+func (r RegistryCtl)endpointList(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	endpoints,e := getReplicationEndpointsFromDB("","")
+	if e != nil {
+		msg := fmt.Sprintf("can not list replication endpoints: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600027,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600027,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	var dataSet []map[string]interface{}
+	for _,ep := range endpoints {
+		dataSet = append(dataSet,ep.toMap())
+	}
+	err := apiutils.SendResponseForMap(c,dataSet)
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	endpointDel deletes the replication endpoint identified by "endpointid"
+*/
+func (r RegistryCtl)endpointDel(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"endpointid"})
+	errs = append(errs,err...)
+
+	e := delReplicationEndpointFromDB(dataMap["endpointid"])
+	if e != nil {
+		msg := fmt.Sprintf("can not delete replication endpoint: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20700004,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20700004,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	err = apiutils.SendResponseForSuccessMessage(c,"replication endpoint has be deleted.")
+	errs = append(errs,err...)
+	return errs
+}
+
+/*
+	replicationAdd adds a replication policy according to "name","direction","endpointid","repositoryfilter","tagfilter","trigger","interval","enabled"
+	direction: push copies the images to the endpoint, pull copies the images from the endpoint
+	repositoryfilter,tagfilter: the repositories and the tags matching the patterns are replicated, all of them are replicated if they are empty
+	trigger: manual, onpush or scheduled. onpush can only be used by push policies
+	interval: the minutes between two executions of a scheduled policy
+	enabled: the policy is not triggered on push or on schedule if it is "0" or "false"
+*/
+func (r RegistryCtl)replicationAdd(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"name","direction","endpointid","repositoryfilter","tagfilter","trigger","interval","enabled"})
+	errs = append(errs,err...)
+
+	p := replicationPolicy{
+		name: strings.TrimSpace(dataMap["name"]),
+		direction: strings.ToLower(strings.TrimSpace(dataMap["direction"])),
+		repositoryFilter: strings.TrimSpace(dataMap["repositoryfilter"]),
+		tagFilter: strings.TrimSpace(dataMap["tagfilter"]),
+		trigger: strings.ToLower(strings.TrimSpace(dataMap["trigger"])),
+		enabled: true,
+	}
+	if p.trigger == "" {
+		p.trigger = ReplicationTriggerManual
+	}
+	enabled := strings.ToLower(strings.TrimSpace(dataMap["enabled"]))
+	if enabled == "0" || enabled == "false" {
+		p.enabled = false
+	}
+	var e error
+	p.endpointID,e = strconv.Atoi(strings.TrimSpace(dataMap["endpointid"]))
+	if intervalStr := strings.TrimSpace(dataMap["interval"]); e == nil && intervalStr != "" {
+		p.interval,e = strconv.Atoi(intervalStr)
+	}
+	if e != nil {
+		msg := "endpointid and interval must be numbers"
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600028,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600028,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	id,e := addReplicationPolicyToDB(p)
+	if e != nil {
+		msg := fmt.Sprintf("can not add replication policy: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600029,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600029,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	p.id = id
+	err = apiutils.SendResponseForMap(c,[]map[string]interface{}{p.toMap()})
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	replicationList lists the replication policies
+*/
+func (r RegistryCtl)replicationList(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	policies,e := getReplicationPoliciesFromDB("")
+	if e != nil {
+		msg := fmt.Sprintf("can not list replication policies: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600030,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600030,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	var dataSet []map[string]interface{}
+	for _,p := range policies {
+		dataSet = append(dataSet,p.toMap())
+	}
+	err := apiutils.SendResponseForMap(c,dataSet)
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	replicationDel deletes the replication policy identified by "policyid" and its execution history
+*/
+func (r RegistryCtl)replicationDel(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"policyid"})
+	errs = append(errs,err...)
+
+	e := delReplicationPolicyFromDB(dataMap["policyid"])
+	if e != nil {
+		msg := fmt.Sprintf("can not delete replication policy: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20700005,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20700005,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	err = apiutils.SendResponseForSuccessMessage(c,"replication policy has be deleted.")
+	errs = append(errs,err...)
+	return errs
+}
+
+/*
+	replicationRun starts an execution of the replication policy identified by "policyid" manually. the execution
+	runs in background, the result of it can be got by replicationhistory. only the repository and the tag are
+	replicated if "repository" and "tag" are not empty
+*/
+func (r RegistryCtl)replicationRun(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"policyid","repository","tag"})
+	errs = append(errs,err...)
+
+	var executionid int
+	policies,e := getReplicationPoliciesFromDB(strings.TrimSpace(dataMap["policyid"]))
+	if e == nil && len(policies) < 1 {
+		e = fmt.Errorf("policy %s was not found",dataMap["policyid"])
+	}
+	if e == nil {
+		executionid,e = startReplication(policies[0],ReplicationTriggerManual,strings.TrimSpace(dataMap["repository"]),strings.TrimSpace(dataMap["tag"]))
+	}
+	if e != nil {
+		msg := fmt.Sprintf("can not start replication: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600031,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600031,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	err = apiutils.SendResponseForMap(c,[]map[string]interface{}{{"executionid": executionid}})
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	replicationHistory lists the executions of the replication policy identified by "policyid", the latest first.
+	the executions of all policies are listed if "policyid" is empty. "num" executions are listed at most if it is positive
+*/
+func (r RegistryCtl)replicationHistory(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"policyid","num"})
+	errs = append(errs,err...)
+
+	num,_ := strconv.Atoi(strings.TrimSpace(dataMap["num"]))
+	executions,e := getReplicationExecutionsFromDB(strings.TrimSpace(dataMap["policyid"]),num)
+	if e != nil {
+		msg := fmt.Sprintf("can not list replication executions: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600032,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600032,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	var dataSet []map[string]interface{}
+	for _,ex := range executions {
+		dataSet = append(dataSet,ex.toMap())
+	}
+	err = apiutils.SendResponseForMap(c,dataSet)
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	immutableAdd adds a tag immutability rule for a project according to "project","repository","tag"
+	project: the name of the project
+	repository: the rule applies to the repositories whose names match the pattern, such as project/app-*. it applies to all repositories of the project if it is empty
+	tag: the tags whose names match the pattern, such as latest or v*, can not be overwritten or deleted
+*/
+func (r RegistryCtl)immutableAdd(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"project","repository","tag"})
+	errs = append(errs,err...)
+
+	rule := immutableRule{
+		project: strings.TrimSpace(dataMap["project"]),
+		repository: strings.TrimSpace(dataMap["repository"]),
+		tag: strings.TrimSpace(dataMap["tag"]),
+	}
+	id,e := addImmutableRuleToDB(rule)
+	if e != nil {
+		msg := fmt.Sprintf("can not add tag immutability rule: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600033,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600033,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	rule.id = id
+	err = apiutils.SendResponseForMap(c,[]map[string]interface{}{rule.toMap()})
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	immutableList lists the tag immutability rules. only the rules of the project are listed if "project" is not empty
+*/
+func (r RegistryCtl)immutableList(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"project"})
+	errs = append(errs,err...)
+
+	rules,e := getImmutableRulesFromDB("",strings.TrimSpace(dataMap["project"]))
+	if e != nil {
+		msg := fmt.Sprintf("can not list tag immutability rules: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20600034,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20600034,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	var dataSet []map[string]interface{}
+	for _,rule := range rules {
+		dataSet = append(dataSet,rule.toMap())
+	}
+	err = apiutils.SendResponseForMap(c,dataSet)
+	errs = append(errs,err...)
+
+	return errs
+}
+
+/*
+	immutableDel deletes the tag immutability rule identified by "ruleid"
+*/
+func (r RegistryCtl)immutableDel(c *sysadmServer.Context) ([]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+
+	dataMap,err := utils.GetRequestData(c,[]string{"ruleid"})
+	errs = append(errs,err...)
+
+	e := delImmutableRuleFromDB(dataMap["ruleid"])
+	if e != nil {
+		msg := fmt.Sprintf("can not delete tag immutability rule: %s",e)
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20700006,"error",msg))
+		err := apiutils.SendResponseForErrorMessage(c,20700006,msg)
+		errs = append(errs,err...)
+		return errs
+	}
+
+	err = apiutils.SendResponseForSuccessMessage(c,"tag immutability rule has be deleted.")
+	errs = append(errs,err...)
+	return errs
+}
+
 func (r RegistryCtl)ActionNotFound(c *sysadmServer.Context,action string) ([]sysadmerror.Sysadmerror){
 	var errs []sysadmerror.Sysadmerror
 	
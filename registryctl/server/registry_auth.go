@@ -0,0 +1,183 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wangyysde/sysadmServer"
+	sysadm "sysadm/sysadm/server"
+	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
+)
+
+// authCacheTTL is the time of the result of an authorization is cached. the clients of the registry send the
+// credentials with each request, caching avoids asking sysadm server for every layer of an image
+const authCacheTTL = time.Minute
+
+// authCache caches the authorizations which have been allowed
+var authCache = make(map[string]time.Time, 0)
+
+// authCacheLock protects authCache
+var authCacheLock sync.Mutex
+
+// authorizeRequest check whether the user of the request has permission on the project which the image in the
+// path of the request belongs to. the response will be sent to the client and false will be returned if the
+// user has not the permission
+func authorizeRequest(c *sysadmServer.Context, permission string) bool {
+	path := strings.Trim(c.Param("path"), "/")
+	project := strings.Split(path, "/")[0]
+	username, password, ok := c.Request.BasicAuth()
+	if !ok || username == "" || password == "" {
+		responseUnauthorized(c)
+		return false
+	}
+
+	// listing repositories is not limited to a project
+	if project == "_catalog" {
+		if !isLogin(username, password) {
+			responseUnauthorized(c)
+			return false
+		}
+		return true
+	}
+
+	allowed, failed, errs := allowedOnProject(username, password, project, permission)
+	logErrors(errs)
+	if failed {
+		responseErrorToClient("internal_error", c)
+		return false
+	}
+	if !allowed {
+		responseDenied(c)
+		return false
+	}
+
+	return true
+}
+
+// allowedOnProject check whether the user has permission on project by asking sysadm server, and cache the result if it
+// is allowed. failed will be true if the response of sysadm server can not be parsed
+func allowedOnProject(username, password, project, permission string) (allowed bool, failed bool, errs []sysadmerror.Sysadmerror) {
+	key := authCacheKey(username, password, project, permission)
+	authCacheLock.Lock()
+	expire, cached := authCache[key]
+	authCacheLock.Unlock()
+	if cached && time.Now().Before(expire) {
+		return true, false, errs
+	}
+
+	var requestParams requestParams = requestParams{}
+	requestParams.url = sysadmUserApiUrl("authorize")
+	requestParams.method = http.MethodPost
+	requestParams.data = append(requestParams.data, &requestData{key: "username", value: username})
+	requestParams.data = append(requestParams.data, &requestData{key: "password", value: password})
+	requestParams.data = append(requestParams.data, &requestData{key: "project", value: project})
+	requestParams.data = append(requestParams.data, &requestData{key: "permission", value: permission})
+	body, err := sendRequest(&requestParams)
+	errs = append(errs, err...)
+
+	ret := &sysadm.ApiResponseStatus{}
+	if e := json.Unmarshal(body, ret); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20307001, "error", "can not parsing reponse body of authorization to json. error: %s", e))
+		return false, true, errs
+	}
+
+	if !ret.Status || ret.Errorcode != 0 {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20307002, "debug", "user %s has not %s permission on project %s, errorcode: %d", username, permission, project, ret.Errorcode))
+		return false, false, errs
+	}
+
+	authCacheLock.Lock()
+	now := time.Now()
+	for k, v := range authCache {
+		if now.After(v) {
+			delete(authCache, k)
+		}
+	}
+	authCache[key] = now.Add(authCacheTTL)
+	authCacheLock.Unlock()
+
+	return true, false, errs
+}
+
+// restrictBlobMount remove the mount and from parameters of the request for mounting a blob from another repository if
+// the user has not pull permission on the project of the source repository. then the registry starts a normal upload
+// instead of mounting the blob, so blobs of the projects which the user can not pull are not exposed
+func restrictBlobMount(c *sysadmServer.Context) {
+	r := c.Request
+	query := r.URL.Query()
+	if query.Get("mount") == "" && query.Get("from") == "" {
+		return
+	}
+
+	from := strings.Trim(query.Get("from"), "/")
+	project := strings.Split(from, "/")[0]
+	username, password, _ := r.BasicAuth()
+	if project != "" {
+		allowed, _, errs := allowedOnProject(username, password, project, userApp.PermissionPull)
+		logErrors(errs)
+		if allowed {
+			return
+		}
+	}
+
+	logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20307003, "debug", "user %s can not mount blob from %s, start a normal upload instead", username, from)})
+	query.Del("mount")
+	query.Del("from")
+	r.URL.RawQuery = query.Encode()
+	r.RequestURI = r.URL.RequestURI()
+}
+
+// permissionOfMethod return the permission on the project which is needed by the request with method
+func permissionOfMethod(method string) string {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return userApp.PermissionPush
+	case http.MethodDelete:
+		return userApp.PermissionDelete
+	}
+
+	return userApp.PermissionPull
+}
+
+// authCacheKey build the key of authCache. the password is not stored in plain text
+func authCacheKey(username, password, project, permission string) string {
+	sum := sha256.Sum256([]byte(username + "\x00" + password + "\x00" + project + "\x00" + permission))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// responseUnauthorized ask the client for the credentials
+func responseUnauthorized(c *sysadmServer.Context) {
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	c.Header("WWW-Authenticate", "Basic realm=\"basic-realm\"")
+	c.JSON(http.StatusUnauthorized, ReponseError{Errors: []BodyError{RegistryErrs["unauthorized"]}})
+}
+
+// responseDenied tell the client that the access to the resource is denied
+func responseDenied(c *sysadmServer.Context) {
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	c.JSON(http.StatusForbidden, ReponseError{Errors: []BodyError{RegistryErrs["denied"]}})
+}
@@ -0,0 +1,86 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/registryctl/config"
+	sysadm "sysadm/sysadm/server"
+	userApp "sysadm/user/app"
+)
+
+// newAuthorizeServer start a sysadm server which allows permission on the projects in allowed only, and make the
+// registryctl ask it for the authorizations
+func newAuthorizeServer(t *testing.T, allowed map[string]string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		ok := allowed[q.Get("project")] == q.Get("permission")
+		_ = json.NewEncoder(w).Encode(sysadm.ApiResponseStatus{Status: ok})
+	}))
+	t.Cleanup(ts.Close)
+
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+	old := RuntimeData.RuningParas.DefinedConfig
+	conf := &config.Config{}
+	conf.Sysadm.ApiVerion = "1.0"
+	conf.Sysadm.Server = config.SysadmServer{Host: u.Hostname(), Port: port}
+	RuntimeData.RuningParas.DefinedConfig = conf
+	t.Cleanup(func() { RuntimeData.RuningParas.DefinedConfig = old })
+}
+
+func TestRestrictBlobMount(t *testing.T) {
+	newAuthorizeServer(t, map[string]string{"lib": userApp.PermissionPull})
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"mount from pullable project", "mount=" + digest + "&from=lib/base", "mount=" + digest + "&from=lib/base"},
+		{"mount from other project", "mount=" + digest + "&from=secret/base", ""},
+		{"mount without from", "mount=" + digest, ""},
+		{"normal upload", "", ""},
+	}
+
+	for _, tt := range tests {
+		uri := "/v2/app/web/blobs/uploads/"
+		if tt.query != "" {
+			uri += "?" + tt.query
+		}
+		c, _ := sysadmServer.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, uri, nil)
+		c.Request.SetBasicAuth("dev", tt.name)
+
+		restrictBlobMount(c)
+		if c.Request.URL.RawQuery != tt.want {
+			t.Errorf("%s: query should be %q, got %q", tt.name, tt.want, c.Request.URL.RawQuery)
+		}
+		if want := c.Request.URL.RequestURI(); c.Request.RequestURI != want {
+			t.Errorf("%s: request uri should be %s, got %s", tt.name, want, c.Request.RequestURI)
+		}
+	}
+}
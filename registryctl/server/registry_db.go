@@ -108,6 +108,9 @@ func updataImage(imageName string){
 		data["update_time"] = update_time
 		sizeStr := strconv.Itoa(int(image.size))
 		data["size"] = "size + " + sizeStr 
+		if image.architecture != "" {
+			data["architecture"] = "\"" + image.architecture + "\""
+		}
 
 		where := make(map[string]string,0)
 		where["imageid"] = "=" + imageIDStr
@@ -123,9 +126,7 @@ func updataImage(imageName string){
 
 	_ = delTagsFromDB("",image.tag,strconv.Itoa(imageID))
 
-	_ = addTagsToDB(imageName,imageID,userid)
-	
-	lastTagId := getObjectMaxID("tag")
+	lastTagId := addTagsToDB(imageName,imageID,userid)
 	if lastTagId == 0 {
 		return 
 	}
@@ -134,6 +135,17 @@ func updataImage(imageName string){
 	for _,b := range blobs {
 		_ = addBlobToDB(b,lastTagId)
 	}
+
+	// the manifests for each platform of a manifest list or an index are recorded as the children of the tag
+	for _,child := range image.children {
+		childTagId := insertTagToDB(child,imageID,userid,lastTagId)
+		if childTagId == 0 {
+			continue
+		}
+		for _,b := range child.blobs {
+			_ = addBlobToDB(b,childTagId)
+		}
+	}
 }
 
 func updatePulltimesForImage(imageid string,imageName string)  {
@@ -172,6 +184,8 @@ func updatePulltimesForTag(tagid string, digest string)  {
 
 	data := make(db.FieldData,0)
 	data["pulltimes"] = "pulltimes + 1"
+	// pull_time is used by the retention policies to find the tags which have not been pulled for days
+	data["pull_time"] = time.Now().Unix()
 
 	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
 	_,err := dbEntity.UpdateData("tag",data,where)
@@ -583,6 +597,11 @@ func getTagInfoFromDB(tagid string,imageid string,name string,ownerid string,dig
 		whereMap["name"] = " like \"%" + name +"%\""
 	}
 
+	// the child manifests of manifest lists or indexes can be got by tagid or digest only
+	if tagid == "" && digest == "" {
+		whereMap["parentid"] = "=0"
+	}
+
 	if ownerid != "" {
 		var ids = ""
 		ownerids := strings.Split(ownerid, ",")
@@ -617,7 +636,7 @@ func getTagInfoFromDB(tagid string,imageid string,name string,ownerid string,dig
 	order = append(order, db.OrderData{Key: "name", Order: 1})
 	selectData := db.SelectData{
 		Tb: []string{"tag"},
-		OutFeilds: []string{"tagid","imageid","name", "description","description","pulltimes","ownerid","creation_time","update_time","size","digest"},
+		OutFeilds: []string{"tagid","imageid","name", "description","description","pulltimes","ownerid","creation_time","update_time","size","digest","media_type","platform","parentid","pull_time"},
 		Where: whereMap,
 		Order: order,
 		Limit: limit,
@@ -650,6 +669,42 @@ func getTagInfoFromDB(tagid string,imageid string,name string,ownerid string,dig
 
 }
 
+/*
+	getChildTagsFromDB: get the child manifests of the manifest list or the index identified by tagid from DB
+*/
+func getChildTagsFromDB(tagid string)([]map[string]interface{},[]sysadmerror.Sysadmerror){
+	var errs []sysadmerror.Sysadmerror
+	if strings.TrimSpace(tagid) == "" {
+		return nil,errs
+	}
+
+	whereMap := make(map[string]string,0)
+	whereMap["parentid"] = "=" + strings.TrimSpace(tagid)
+	selectData := db.SelectData{
+		Tb: []string{"tag"},
+		OutFeilds: []string{"tagid","imageid","name","digest","media_type","platform","parentid"},
+		Where: whereMap,
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	retData,err := dbEntity.QueryData(&selectData)
+	errs = append(errs,err...)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error"){
+		return nil,errs
+	}
+
+	var rets []map[string]interface{}
+	for _,line := range retData {
+		lineData := make(map[string]interface{},0)
+		for k,v := range line {
+			lineData[k] = v
+		}
+		rets = append(rets,lineData)
+	}
+
+	return rets,errs
+}
+
 func getBlobInfoFromDB(blobid string, tagid string,digest string)([]map[string]interface{},[]sysadmerror.Sysadmerror){
 	var errs []sysadmerror.Sysadmerror
 	var rets []map[string]interface{}
@@ -773,6 +828,12 @@ func addImageToDB(imageName string, projectid int, ownerid int)(int){
 	data["update_time"] = update_time
 	data["size"] = image.size
 	
+	imageID := getObjectNextID("image")
+	if imageID == 0 {
+		return 0
+	}
+	data["imageid"] = imageID
+
 	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
 	_,err := dbEntity.InsertData("image",data)
 	errs = append(errs, err...)
@@ -780,20 +841,26 @@ func addImageToDB(imageName string, projectid int, ownerid int)(int){
 	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("error"){
 		return 0
 	}
-	
-	lastID := getObjectMaxID("image")
 
-	return lastID
+	return imageID
 }
 
 /*
 	addTagsToDB: Insert the data of tag into the database.
-	return affected rows if execute successfully otherwise return zero 
+	return the tagid of the tag if execute successfully otherwise return zero 
 */
 func addTagsToDB(imageName string, imageId int, ownerid int)(int){
+	return insertTagToDB(processImages[imageName],imageId,ownerid,0)
+}
+
+/*
+	insertTagToDB: Insert the data of tag of image into the database. parentid is the tagid of the manifest list or 
+	the index which the manifest of image belongs to, it is zero for the tags pushed by the clients.
+	return the tagid of the tag if execute successfully otherwise return zero 
+*/
+func insertTagToDB(image image, imageId int, ownerid int, parentid int)(int){
 	var errs []sysadmerror.Sysadmerror
 
-	image := processImages[imageName]
 	data := make(map[string]interface{},0)
 	data["imageid"] = imageId
 	data["name"] = image.tag 
@@ -806,16 +873,25 @@ func addTagsToDB(imageName string, imageId int, ownerid int)(int){
 	data["update_time"] = update_time
 	data["size"] = image.size
 	data["digest"] = image.digest
+	data["media_type"] = image.mediaType
+	data["platform"] = image.platform
+	data["parentid"] = parentid
+
+	tagID := getObjectNextID("tag")
+	if tagID == 0 {
+		return 0
+	}
+	data["tagid"] = tagID
 
 	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
-	rows,err := dbEntity.InsertData("tag",data)
+	_,err := dbEntity.InsertData("tag",data)
 	errs = append(errs, err...)
 	logErrors(errs)
 	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("error"){
-		rows = 0
+		return 0
 	}
 
-	return rows
+	return tagID
 }
 
 /*
@@ -1082,48 +1158,30 @@ func delBlobFromDB(blobid string, tagid string, digest string)(int) {
 
 
 /*
-	getObjectMaxID: get maxid of the object from DB.
-	return zero if any error occurs, otherwise return the maxid
+	getObjectNextID: allocate a new ID for the object from the sequence in DB.
+	return zero if any error occurs, otherwise return the ID
 */
-func getObjectMaxID(object string)(int){
+func getObjectNextID(object string)(int){
 	var errs []sysadmerror.Sysadmerror
-	var tb []string 
-	var outFeilds []string
+	var tb, idField string
 	switch object {
 		case "image":
-			tb = append(tb,"image")
-			outFeilds = append(outFeilds,"max(imageid) as id")
+			tb = "image"
+			idField = "imageid"
 		case "tag":
-			tb = append(tb,"tag")
-			outFeilds = append(outFeilds,"max(tagid) as id")
+			tb = "tag"
+			idField = "tagid"
 		default:
 			return 0
 	}
 
-
-	selectData := db.SelectData{
-		Tb: tb,
-		OutFeilds: outFeilds,
-	}
-
 	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
-	retData,err := dbEntity.QueryData(&selectData)
-	errs = append(errs,err...)
-	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error"){
-		logErrors(errs)
-		return 0
-	} 
-
-	lineData := retData[0]
-	v, ok := lineData["id"]
-	if !ok {
-		return 0
-	}
-	value := utils.Interface2String(v)
-	id,e := strconv.Atoi(value)
+	id,e := db.NextID(dbEntity,tb,idField)
 	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(2022004,"error","can not allocate ID for %s: %s",object,e))
+		logErrors(errs)
 		return 0
 	}
 
-	return id
+	return int(id)
 }
\ No newline at end of file
@@ -33,6 +33,12 @@ type image struct {
 	architecture string
 	digest string
 	blobs []blob
+	// media type of the manifest of the image
+	mediaType string
+	// platform of the image in os/architecture[/variant] form, it is empty for manifest lists and indexes 
+	platform string
+	// images for each platform if the manifest is a manifest list or an index
+	children []image
 }
 
 var processImages map[string]image = make(map[string]image,0)
@@ -44,6 +50,7 @@ type FsLayer struct {
 type History struct {
 	V1Compatibility string `json:"v1Compatibility"`
 }
+// Manifest is Docker schema 1 manifest. the other formats are parsed with ManifestV2 
 type Manifest struct {
 	SchemaVersion int `json:"schemaVersion"`
 	Name string `json:"name"`
@@ -55,4 +62,4 @@ type Manifest struct {
 
 type RegistryCtl struct {}
 
-var  registryctlActions = []string{"imagelist","getcount","taglist"}
\ No newline at end of file
+var  registryctlActions = []string{"imagelist","getcount","taglist","gc","gcreport","retentionadd","retentionlist","retentionpreview","retentionrun","quotaset","quotalist","endpointadd","endpointlist","replicationadd","replicationlist","replicationrun","replicationhistory","immutableadd","immutablelist"}
\ No newline at end of file
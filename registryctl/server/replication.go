@@ -0,0 +1,1071 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/sysadmerror"
+	"sysadm/utils"
+)
+
+// tables which the replication endpoints, policies and executions are stored in
+const (
+	replicationEndpointTable  = "replicationEndpoint"
+	replicationPolicyTable    = "replicationPolicy"
+	replicationExecutionTable = "replicationExecution"
+)
+
+// directions of the replication policies
+const (
+	// ReplicationPush copies the images from the registry to the endpoint
+	ReplicationPush = "push"
+	// ReplicationPull copies the images from the endpoint to the registry
+	ReplicationPull = "pull"
+)
+
+// triggers of the replication policies
+const (
+	ReplicationTriggerManual    = "manual"
+	ReplicationTriggerOnPush    = "onpush"
+	ReplicationTriggerScheduled = "scheduled"
+)
+
+// status of the replication executions
+const (
+	replicationStatusRunning   = "running"
+	replicationStatusSucceeded = "succeeded"
+	replicationStatusFailed    = "failed"
+)
+
+// replicationEndpoint is a registry which the images are replicated to or from
+type replicationEndpoint struct {
+	id   int
+	name string
+	// root url of the registry, such as https://registry.example.com
+	url      string
+	username string
+	// password is stored encrypted if the master keys have been loaded
+	password string
+}
+
+// replicationPolicy decides which images are replicated between the registry and an endpoint, and when
+type replicationPolicy struct {
+	id   int
+	name string
+	// ReplicationPush or ReplicationPull
+	direction  string
+	endpointID int
+	// the repositories whose names match the pattern are replicated. all repositories are replicated if it is empty.
+	// the pattern is matched with path.Match, so "*" does not match "/"
+	repositoryFilter string
+	// the tags whose names match the pattern are replicated. all tags are replicated if it is empty
+	tagFilter string
+	// ReplicationTriggerManual, ReplicationTriggerOnPush or ReplicationTriggerScheduled
+	trigger string
+	// minutes between two executions of a scheduled policy
+	interval int
+	enabled  bool
+}
+
+// replicationExecution is an execution of a replication policy
+type replicationExecution struct {
+	id       int
+	policyID int
+	trigger  string
+	status   string
+	// unix timestamps of the start and the end of the execution
+	startTime int64
+	endTime   int64
+	// number of the tags which have been copied
+	tags int
+	// number of the tags which are the same in the source and the destination
+	skippedTags int
+	// number of the blobs which have been transferred
+	blobsCopied int
+	// number of the blobs which have not been transferred because they exist in the destination or have been mounted
+	blobsSkipped int
+	bytesCopied  int64
+	// number of the tags which can not be copied
+	failed int
+	// the last error of the execution
+	message string
+}
+
+// replicationTarget is the repository and the tag which an execution replicates. all tags of the repository are
+// replicated if tag is empty, and all repositories matching the policy are replicated if repository is empty
+type replicationTarget struct {
+	repository string
+	tag        string
+}
+
+// replicationQueue makes a policy be executed once at a time. the on push triggers which arrive while the policy
+// is being executed are queued, and they are replicated by another execution after the current one finished
+type replicationQueue struct {
+	lock sync.Mutex
+	// IDs of the policies which are being executed
+	running map[int]bool
+	// targets queued for the policies which are being executed
+	pending map[int][]replicationTarget
+}
+
+// replicationSecretRotator re-encrypts the passwords of the replication endpoints with the current master key
+type replicationSecretRotator struct{}
+
+// replications holds the policies which are being executed
+var replications = &replicationQueue{running: make(map[int]bool, 0), pending: make(map[int][]replicationTarget, 0)}
+
+// register the passwords of the replication endpoints to the secret rotation
+func init() {
+	sysadmObjects.RegisterSecretRotator(replicationSecretRotator{})
+}
+
+// covers returns true if the tags replicated for target t are replicated for target o too
+func (t replicationTarget) covers(o replicationTarget) bool {
+	if t.repository == "" {
+		return true
+	}
+
+	return t.repository == o.repository && (t.tag == "" || t.tag == o.tag)
+}
+
+// start marks the policy identified by id as being executed. false is returned if the policy is being executed,
+// and target is queued for the next execution of the policy if queue is true
+func (q *replicationQueue) start(id int, target replicationTarget, queue bool) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if !q.running[id] {
+		q.running[id] = true
+		return true
+	}
+	if !queue {
+		return false
+	}
+
+	for _, t := range q.pending[id] {
+		if t.covers(target) {
+			return false
+		}
+	}
+	q.pending[id] = append(q.pending[id], target)
+
+	return false
+}
+
+// next takes the targets queued for the policy identified by id. the policy is marked as not being executed if
+// there is not any target queued
+func (q *replicationQueue) next(id int) []replicationTarget {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	targets := q.pending[id]
+	delete(q.pending, id)
+	if len(targets) < 1 {
+		delete(q.running, id)
+	}
+
+	return targets
+}
+
+// stop marks the policy identified by id as not being executed and drops the targets queued for it
+func (q *replicationQueue) stop(id int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	delete(q.pending, id)
+	delete(q.running, id)
+}
+
+// isRunning returns true if the policy identified by id is being executed
+func (q *replicationQueue) isRunning(id int) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.running[id]
+}
+
+// GetName implements SecretRotator interface
+func (r replicationSecretRotator) GetName() string {
+	return replicationEndpointTable
+}
+
+// RotateSecrets implements SecretRotator interface. the passwords of the endpoints are re-encrypted with the
+// current master key
+func (r replicationSecretRotator) RotateSecrets() (int, error) {
+	if !sysadmObjects.SecretEnabled() {
+		return 0, nil
+	}
+
+	endpoints, e := getReplicationEndpointsFromDB("", "")
+	if e != nil {
+		return 0, e
+	}
+
+	num := 0
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	for _, ep := range endpoints {
+		password, changed, e := sysadmObjects.RotateSecret(ep.password)
+		if e != nil {
+			return num, fmt.Errorf("rotate password of endpoint %s error: %s", ep.name, e)
+		}
+		if !changed {
+			continue
+		}
+
+		data := make(db.FieldData, 0)
+		data["password"] = sqlStringValue(password)
+		data["update_time"] = time.Now().Unix()
+		where := map[string]string{"endpointid": "=" + strconv.Itoa(ep.id)}
+		_, err := dbEntity.UpdateData(replicationEndpointTable, data, where)
+		logErrors(err)
+		if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+			return num, fmt.Errorf("can not update password of endpoint %s", ep.name)
+		}
+		num++
+	}
+
+	return num, nil
+}
+
+// validate checks whether the endpoint is valid
+func (ep replicationEndpoint) validate() error {
+	if !repositoryNamePattern.MatchString(ep.name) || strings.Contains(ep.name, "/") {
+		return fmt.Errorf("endpoint name %q is not valid", ep.name)
+	}
+	u, e := url.Parse(ep.url)
+	if e != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q of endpoint is not valid", ep.url)
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+		return fmt.Errorf("url of endpoint must be the root url of the registry")
+	}
+
+	return nil
+}
+
+// toMap converts the endpoint to the data which can be sent to the client. the password is not sent
+func (ep replicationEndpoint) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"endpointid": ep.id,
+		"name":       ep.name,
+		"url":        ep.url,
+		"username":   ep.username,
+	}
+}
+
+// client creates a client for the endpoint
+func (ep replicationEndpoint) client() (*registryClient, error) {
+	password, e := sysadmObjects.DecryptSecret(ep.password)
+	if e != nil {
+		return nil, fmt.Errorf("can not decrypt password of endpoint %s: %s", ep.name, e)
+	}
+
+	return newRegistryClient(ep.url, ep.username, password), nil
+}
+
+// validate checks whether the policy is valid
+func (p replicationPolicy) validate() error {
+	if !repositoryNamePattern.MatchString(p.name) || strings.Contains(p.name, "/") {
+		return fmt.Errorf("policy name %q is not valid", p.name)
+	}
+	if p.direction != ReplicationPush && p.direction != ReplicationPull {
+		return fmt.Errorf("direction of policy must be %s or %s", ReplicationPush, ReplicationPull)
+	}
+	for _, filter := range []string{p.repositoryFilter, p.tagFilter} {
+		if _, e := path.Match(filter, ""); e != nil {
+			return fmt.Errorf("filter %s is not valid: %s", filter, e)
+		}
+	}
+
+	switch p.trigger {
+	case ReplicationTriggerManual:
+	case ReplicationTriggerOnPush:
+		// the images pushed to the endpoint are not known by registryctl
+		if p.direction != ReplicationPush {
+			return fmt.Errorf("trigger %s can only be used by %s policies", ReplicationTriggerOnPush, ReplicationPush)
+		}
+	case ReplicationTriggerScheduled:
+		if p.interval <= 0 {
+			return fmt.Errorf("interval of scheduled policy must be positive")
+		}
+	default:
+		return fmt.Errorf("trigger of policy must be %s, %s or %s", ReplicationTriggerManual, ReplicationTriggerOnPush, ReplicationTriggerScheduled)
+	}
+
+	return nil
+}
+
+// matches returns true if the tag of the repository is replicated by the policy
+func (p replicationPolicy) matches(repository, tag string) bool {
+	if p.repositoryFilter != "" {
+		if ok, _ := path.Match(p.repositoryFilter, repository); !ok {
+			return false
+		}
+	}
+	if p.tagFilter != "" && tag != "" {
+		if ok, _ := path.Match(p.tagFilter, tag); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// toMap converts the policy to the data which can be sent to the client
+func (p replicationPolicy) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"policyid":         p.id,
+		"name":             p.name,
+		"direction":        p.direction,
+		"endpointid":       p.endpointID,
+		"repositoryfilter": p.repositoryFilter,
+		"tagfilter":        p.tagFilter,
+		"trigger":          p.trigger,
+		"interval":         p.interval,
+		"enabled":          p.enabled,
+	}
+}
+
+// toMap converts the execution to the data which can be sent to the client
+func (ex replicationExecution) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"executionid":  ex.id,
+		"policyid":     ex.policyID,
+		"trigger":      ex.trigger,
+		"status":       ex.status,
+		"startTime":    ex.startTime,
+		"endTime":      ex.endTime,
+		"tags":         ex.tags,
+		"skippedTags":  ex.skippedTags,
+		"blobsCopied":  ex.blobsCopied,
+		"blobsSkipped": ex.blobsSkipped,
+		"bytesCopied":  ex.bytesCopied,
+		"failed":       ex.failed,
+		"message":      ex.message,
+	}
+}
+
+// addReplicationEndpointToDB validates the endpoint and inserts it into DB. the password is encrypted with
+// the master key before storing. return the ID of the endpoint
+func addReplicationEndpointToDB(ep replicationEndpoint) (int, error) {
+	if e := ep.validate(); e != nil {
+		return 0, e
+	}
+
+	exists, e := getReplicationEndpointsFromDB("", ep.name)
+	if e != nil {
+		return 0, e
+	}
+	if len(exists) > 0 {
+		return 0, fmt.Errorf("endpoint %s has been defined", ep.name)
+	}
+
+	password, e := sysadmObjects.EncryptSecret(ep.password)
+	if e != nil {
+		return 0, fmt.Errorf("can not encrypt password of endpoint %s: %s", ep.name, e)
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	id, e := db.NextID(dbEntity, replicationEndpointTable, "endpointid")
+	if e != nil {
+		return 0, fmt.Errorf("can not allocate ID for the endpoint: %s", e)
+	}
+
+	now := time.Now().Unix()
+	data := make(db.FieldData, 0)
+	data["endpointid"] = id
+	data["name"] = ep.name
+	data["url"] = sqlStringValue(strings.TrimSuffix(ep.url, "/"))
+	data["username"] = sqlStringValue(ep.username)
+	data["password"] = sqlStringValue(password)
+	data["creation_time"] = now
+	data["update_time"] = now
+	_, err := dbEntity.InsertData(replicationEndpointTable, data)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return 0, fmt.Errorf("can not insert the endpoint into DB")
+	}
+
+	return int(id), nil
+}
+
+// getReplicationEndpointsFromDB gets the endpoint identified by endpointid or named name.
+// all endpoints are returned if both of them are empty
+func getReplicationEndpointsFromDB(endpointid, name string) ([]replicationEndpoint, error) {
+	whereMap := make(map[string]string, 0)
+	if endpointid != "" {
+		id, e := strconv.Atoi(endpointid)
+		if e != nil {
+			return nil, fmt.Errorf("endpoint id %s is not valid", endpointid)
+		}
+		whereMap["endpointid"] = "=" + strconv.Itoa(id)
+	}
+	if name != "" {
+		whereMap["name"] = "=\"" + sqlStringValue(name) + "\""
+	}
+
+	selectData := db.SelectData{
+		Tb:        []string{replicationEndpointTable},
+		OutFeilds: []string{"endpointid", "name", "url", "username", "password"},
+		Where:     whereMap,
+		Order:     []db.OrderData{{Key: "endpointid", Order: 0}},
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	retData, err := dbEntity.QueryData(&selectData)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get replication endpoints from DB")
+	}
+
+	var endpoints []replicationEndpoint
+	for _, line := range retData {
+		id, _ := utils.Interface2Int(line["endpointid"])
+		endpoints = append(endpoints, replicationEndpoint{
+			id:       id,
+			name:     utils.Interface2String(line["name"]),
+			url:      utils.Interface2String(line["url"]),
+			username: utils.Interface2String(line["username"]),
+			password: utils.Interface2String(line["password"]),
+		})
+	}
+
+	return endpoints, nil
+}
+
+// delReplicationEndpointFromDB deletes the endpoint identified by endpointid. the endpoint which is used by
+// any policy can not be deleted
+func delReplicationEndpointFromDB(endpointid string) error {
+	id, e := strconv.Atoi(strings.TrimSpace(endpointid))
+	if e != nil {
+		return fmt.Errorf("endpoint id %s is not valid", endpointid)
+	}
+
+	policies, e := getReplicationPoliciesFromDB("")
+	if e != nil {
+		return e
+	}
+	for _, p := range policies {
+		if p.endpointID == id {
+			return fmt.Errorf("endpoint %d is used by policy %s", id, p.name)
+		}
+	}
+
+	delData := db.SelectData{
+		Tb:    []string{replicationEndpointTable},
+		Where: map[string]string{"endpointid": "=" + strconv.Itoa(id)},
+	}
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	rows, err := dbEntity.DeleteData(&delData)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return fmt.Errorf("can not delete endpoint %d", id)
+	}
+	if rows == 0 {
+		return fmt.Errorf("endpoint %d was not found", id)
+	}
+
+	return nil
+}
+
+// addReplicationPolicyToDB validates the policy and inserts it into DB. return the ID of the policy
+func addReplicationPolicyToDB(p replicationPolicy) (int, error) {
+	if e := p.validate(); e != nil {
+		return 0, e
+	}
+
+	endpoints, e := getReplicationEndpointsFromDB(strconv.Itoa(p.endpointID), "")
+	if e != nil {
+		return 0, e
+	}
+	if len(endpoints) < 1 {
+		return 0, fmt.Errorf("endpoint %d was not found", p.endpointID)
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	id, e := db.NextID(dbEntity, replicationPolicyTable, "policyid")
+	if e != nil {
+		return 0, fmt.Errorf("can not allocate ID for the policy: %s", e)
+	}
+
+	enabled := 0
+	if p.enabled {
+		enabled = 1
+	}
+	now := time.Now().Unix()
+	data := make(db.FieldData, 0)
+	data["policyid"] = id
+	data["name"] = p.name
+	data["direction"] = p.direction
+	data["endpointid"] = p.endpointID
+	data["repository_filter"] = sqlStringValue(p.repositoryFilter)
+	data["tag_filter"] = sqlStringValue(p.tagFilter)
+	data["trigger_type"] = p.trigger
+	data["schedule_interval"] = p.interval
+	data["enabled"] = enabled
+	data["creation_time"] = now
+	data["update_time"] = now
+	_, err := dbEntity.InsertData(replicationPolicyTable, data)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return 0, fmt.Errorf("can not insert the policy into DB")
+	}
+
+	return int(id), nil
+}
+
+// getReplicationPoliciesFromDB gets the policy identified by policyid. all policies are returned if it is empty
+func getReplicationPoliciesFromDB(policyid string) ([]replicationPolicy, error) {
+	whereMap := make(map[string]string, 0)
+	if policyid != "" {
+		id, e := strconv.Atoi(policyid)
+		if e != nil {
+			return nil, fmt.Errorf("policy id %s is not valid", policyid)
+		}
+		whereMap["policyid"] = "=" + strconv.Itoa(id)
+	}
+
+	selectData := db.SelectData{
+		Tb: []string{replicationPolicyTable},
+		OutFeilds: []string{"policyid", "name", "direction", "endpointid", "repository_filter", "tag_filter",
+			"trigger_type", "schedule_interval", "enabled"},
+		Where: whereMap,
+		Order: []db.OrderData{{Key: "policyid", Order: 0}},
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	retData, err := dbEntity.QueryData(&selectData)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get replication policies from DB")
+	}
+
+	var policies []replicationPolicy
+	for _, line := range retData {
+		id, _ := utils.Interface2Int(line["policyid"])
+		endpointID, _ := utils.Interface2Int(line["endpointid"])
+		interval, _ := utils.Interface2Int(line["schedule_interval"])
+		enabled, _ := utils.Interface2Int(line["enabled"])
+		policies = append(policies, replicationPolicy{
+			id:               id,
+			name:             utils.Interface2String(line["name"]),
+			direction:        utils.Interface2String(line["direction"]),
+			endpointID:       endpointID,
+			repositoryFilter: utils.Interface2String(line["repository_filter"]),
+			tagFilter:        utils.Interface2String(line["tag_filter"]),
+			trigger:          utils.Interface2String(line["trigger_type"]),
+			interval:         interval,
+			enabled:          enabled != 0,
+		})
+	}
+
+	return policies, nil
+}
+
+// delReplicationPolicyFromDB deletes the policy identified by policyid and its executions
+func delReplicationPolicyFromDB(policyid string) error {
+	id, e := strconv.Atoi(strings.TrimSpace(policyid))
+	if e != nil {
+		return fmt.Errorf("policy id %s is not valid", policyid)
+	}
+	if replications.isRunning(id) {
+		return fmt.Errorf("policy %d is being executed", id)
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	delData := db.SelectData{
+		Tb:    []string{replicationPolicyTable},
+		Where: map[string]string{"policyid": "=" + strconv.Itoa(id)},
+	}
+	rows, err := dbEntity.DeleteData(&delData)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return fmt.Errorf("can not delete policy %d", id)
+	}
+	if rows == 0 {
+		return fmt.Errorf("policy %d was not found", id)
+	}
+
+	delData.Tb = []string{replicationExecutionTable}
+	_, err = dbEntity.DeleteData(&delData)
+	logErrors(err)
+
+	return nil
+}
+
+// addReplicationExecutionToDB inserts the execution which is starting into DB. return the ID of the execution
+func addReplicationExecutionToDB(ex replicationExecution) (int, error) {
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	id, e := db.NextID(dbEntity, replicationExecutionTable, "executionid")
+	if e != nil {
+		return 0, fmt.Errorf("can not allocate ID for the execution: %s", e)
+	}
+
+	data := make(db.FieldData, 0)
+	data["executionid"] = id
+	data["policyid"] = ex.policyID
+	data["trigger_type"] = ex.trigger
+	data["status"] = ex.status
+	data["start_time"] = ex.startTime
+	data["end_time"] = 0
+	data["message"] = ""
+	_, err := dbEntity.InsertData(replicationExecutionTable, data)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return 0, fmt.Errorf("can not insert the execution into DB")
+	}
+
+	return int(id), nil
+}
+
+// updateReplicationExecutionToDB records the result of the execution into DB
+func updateReplicationExecutionToDB(ex replicationExecution) error {
+	data := make(db.FieldData, 0)
+	data["status"] = ex.status
+	data["end_time"] = ex.endTime
+	data["tags"] = ex.tags
+	data["skipped_tags"] = ex.skippedTags
+	data["blobs_copied"] = ex.blobsCopied
+	data["blobs_skipped"] = ex.blobsSkipped
+	data["bytes_copied"] = ex.bytesCopied
+	data["failed"] = ex.failed
+	data["message"] = sqlStringValue(ex.message)
+	where := map[string]string{"executionid": "=" + strconv.Itoa(ex.id)}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	_, err := dbEntity.UpdateData(replicationExecutionTable, data, where)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return fmt.Errorf("can not update execution %d", ex.id)
+	}
+
+	return nil
+}
+
+// getReplicationExecutionsFromDB gets the executions of the policy identified by policyid, the latest first.
+// the executions of all policies are returned if policyid is empty. num executions are returned at most if num
+// is positive
+func getReplicationExecutionsFromDB(policyid string, num int) ([]replicationExecution, error) {
+	whereMap := make(map[string]string, 0)
+	if policyid != "" {
+		id, e := strconv.Atoi(policyid)
+		if e != nil {
+			return nil, fmt.Errorf("policy id %s is not valid", policyid)
+		}
+		whereMap["policyid"] = "=" + strconv.Itoa(id)
+	}
+
+	selectData := db.SelectData{
+		Tb: []string{replicationExecutionTable},
+		OutFeilds: []string{"executionid", "policyid", "trigger_type", "status", "start_time", "end_time", "tags",
+			"skipped_tags", "blobs_copied", "blobs_skipped", "bytes_copied", "failed", "message"},
+		Where: whereMap,
+		Order: []db.OrderData{{Key: "executionid", Order: 1}},
+	}
+	if num > 0 {
+		selectData.Limit = []int{0, num}
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	retData, err := dbEntity.QueryData(&selectData)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get replication executions from DB")
+	}
+
+	var executions []replicationExecution
+	for _, line := range retData {
+		ex := replicationExecution{
+			trigger: utils.Interface2String(line["trigger_type"]),
+			status:  utils.Interface2String(line["status"]),
+			message: utils.Interface2String(line["message"]),
+		}
+		ex.id, _ = utils.Interface2Int(line["executionid"])
+		ex.policyID, _ = utils.Interface2Int(line["policyid"])
+		ex.startTime, _ = utils.Interface2Int64(line["start_time"])
+		ex.endTime, _ = utils.Interface2Int64(line["end_time"])
+		ex.tags, _ = utils.Interface2Int(line["tags"])
+		ex.skippedTags, _ = utils.Interface2Int(line["skipped_tags"])
+		ex.blobsCopied, _ = utils.Interface2Int(line["blobs_copied"])
+		ex.blobsSkipped, _ = utils.Interface2Int(line["blobs_skipped"])
+		ex.bytesCopied, _ = utils.Interface2Int64(line["bytes_copied"])
+		ex.failed, _ = utils.Interface2Int(line["failed"])
+		executions = append(executions, ex)
+	}
+
+	return executions, nil
+}
+
+// localRegistryClient creates a client for the registry which registryctl proxies for
+func localRegistryClient() *registryClient {
+	definedConfig := RuntimeData.RuningParas.DefinedConfig
+
+	return newRegistryClient(registryServerUrl(), definedConfig.Registry.Credit.Username, definedConfig.Registry.Credit.Password)
+}
+
+// startReplication starts an execution of the policy in background. only the repository and the tag are replicated
+// if they are not empty. the on push triggers are queued if the policy is being executed, they are replicated by
+// another execution after the current one finished, and 0 is returned as the ID of the execution for them.
+// return the ID of the execution
+func startReplication(p replicationPolicy, trigger, repository, tag string) (int, error) {
+	target := replicationTarget{repository: repository, tag: tag}
+	if !replications.start(p.id, target, trigger == ReplicationTriggerOnPush) {
+		if trigger == ReplicationTriggerOnPush {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("policy %s is being executed", p.name)
+	}
+
+	r, e := newReplicator(p, trigger)
+	if e != nil {
+		replications.stop(p.id)
+		return 0, e
+	}
+
+	executionID := r.execution.id
+	go func() {
+		targets := []replicationTarget{target}
+		for {
+			r.run(p, targets)
+			if targets = replications.next(p.id); len(targets) < 1 {
+				return
+			}
+
+			var e error
+			if r, e = newReplicator(p, ReplicationTriggerOnPush); e != nil {
+				replications.stop(p.id)
+				logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20312010, "error", "%d queued triggers of replication policy %s have been dropped: %s", len(targets), p.name, e)})
+				return
+			}
+		}
+	}()
+
+	return executionID, nil
+}
+
+// newReplicator creates the replicator for an execution of the policy and records the execution into DB
+func newReplicator(p replicationPolicy, trigger string) (*replicator, error) {
+	endpoints, e := getReplicationEndpointsFromDB(strconv.Itoa(p.endpointID), "")
+	if e != nil {
+		return nil, e
+	}
+	if len(endpoints) < 1 {
+		return nil, fmt.Errorf("endpoint %d of policy %s was not found", p.endpointID, p.name)
+	}
+	remote, e := endpoints[0].client()
+	if e != nil {
+		return nil, e
+	}
+
+	ex := replicationExecution{policyID: p.id, trigger: trigger, status: replicationStatusRunning, startTime: time.Now().Unix()}
+	if ex.id, e = addReplicationExecutionToDB(ex); e != nil {
+		return nil, e
+	}
+
+	r := &replicator{src: localRegistryClient(), dst: remote, blobs: make(map[string]string, 0), execution: &ex}
+	if p.direction == ReplicationPull {
+		// the tags pulled are pushed to the registry directly, so the immutability rules are enforced here
+		r.src, r.dst = remote, localRegistryClient()
+		r.immutable = isImmutableTag
+	}
+
+	return r, nil
+}
+
+// replicator copies the images from the source registry to the destination registry for an execution
+type replicator struct {
+	src *registryClient
+	dst *registryClient
+	// blobs which exist in the destination indexed by their digests, the values are the repositories they are in
+	blobs     map[string]string
+	execution *replicationExecution
+	// immutable returns true if the tag of the repository in the destination can not be overwritten.
+	// the tags in the destination are overwritten without checking if it is nil
+	immutable func(repository, tag string) bool
+}
+
+// run replicates the tags of the targets matching the policy and records the result of the execution
+func (r *replicator) run(p replicationPolicy, targets []replicationTarget) {
+	var errs []sysadmerror.Sysadmerror
+	ex := r.execution
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20312001, "info", "execution %d of replication policy %s started", ex.id, p.name))
+	logErrors(errs)
+
+	e := r.replicate(p, targets)
+	if e != nil {
+		ex.message = e.Error()
+	}
+	ex.status = replicationStatusSucceeded
+	if e != nil || ex.failed > 0 {
+		ex.status = replicationStatusFailed
+	}
+	ex.endTime = time.Now().Unix()
+	if e := updateReplicationExecutionToDB(*ex); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(20312002, "error", "%s", e))
+	}
+
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(20312003, "info", "execution %d of replication policy %s finished, status: %s tags: %d failed: %d blobs copied: %d skipped: %d",
+		ex.id, p.name, ex.status, ex.tags, ex.failed, ex.blobsCopied, ex.blobsSkipped))
+	logErrors(errs)
+}
+
+// replicate copies the tags of the targets matching the policy. the tags which can not be copied are counted as
+// failed, the last error is returned if the repositories or the tags of any target can not be listed
+func (r *replicator) replicate(p replicationPolicy, targets []replicationTarget) error {
+	// the images are pushed to the registry directly, which can not be refused during garbage collection
+	if p.direction == ReplicationPull && isReadOnly() {
+		return fmt.Errorf("registry is in read-only mode for garbage collection")
+	}
+
+	var last error
+	for _, t := range targets {
+		if e := r.replicateTarget(p, t.repository, t.tag); e != nil {
+			last = e
+		}
+	}
+
+	return last
+}
+
+// replicateTarget copies the tags of the repository matching the policy. all tags are copied if tag is empty,
+// and all repositories are copied if repository is empty
+func (r *replicator) replicateTarget(p replicationPolicy, repository, tag string) error {
+	repositories := []string{repository}
+	if repository == "" {
+		var e error
+		repositories, e = r.src.catalog()
+		if e != nil {
+			// some registries, such as Docker Hub, do not support listing the repositories
+			if p.repositoryFilter == "" || strings.ContainsAny(p.repositoryFilter, "*?[\\") {
+				return fmt.Errorf("can not list repositories: %s", e)
+			}
+			repositories = []string{p.repositoryFilter}
+		}
+	}
+
+	for _, repo := range repositories {
+		if !p.matches(repo, "") {
+			continue
+		}
+
+		tags := []string{tag}
+		if tag == "" {
+			var e error
+			if tags, e = r.src.tags(repo); e != nil {
+				return fmt.Errorf("can not list tags of %s: %s", repo, e)
+			}
+		}
+		for _, t := range tags {
+			if !p.matches(repo, t) {
+				continue
+			}
+			r.replicateTag(p, repo, t)
+		}
+	}
+
+	return nil
+}
+
+// replicateTag copies the tag of the repository and counts the result into the execution
+func (r *replicator) replicateTag(p replicationPolicy, repository, tag string) {
+	ex := r.execution
+	copied, e := r.copyTag(repository, tag)
+	if e != nil {
+		ex.failed++
+		ex.message = fmt.Sprintf("can not replicate %s:%s: %s", repository, tag, e)
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20312004, "error", "policy %s: %s", p.name, ex.message)})
+		return
+	}
+	if !copied {
+		ex.skippedTags++
+		return
+	}
+
+	ex.tags++
+	// the images pulled are pushed to the registry directly, they are recorded here as the images pushed by admin
+	if p.direction == ReplicationPull {
+		recordManifest(repository, tag, "admin", nil)
+	}
+}
+
+// copyTag copies the manifest of the tag and the blobs referenced by it. return false if the tag in the
+// destination has the same manifest as the source
+func (r *replicator) copyTag(repository, tag string) (bool, error) {
+	content, mediaType, digest, e := r.src.manifest(repository, tag)
+	if e != nil {
+		return false, e
+	}
+	dstDigest, e := r.dst.manifestDigest(repository, tag)
+	if e != nil {
+		return false, e
+	}
+	if dstDigest == digest {
+		return false, nil
+	}
+	if dstDigest != "" && r.immutable != nil && r.immutable(repository, tag) {
+		return false, fmt.Errorf("tag %s of %s is immutable in the destination", tag, repository)
+	}
+
+	if e := r.copyReferences(repository, content, mediaType); e != nil {
+		return false, e
+	}
+
+	return true, r.dst.putManifest(repository, tag, mediaType, content)
+}
+
+// copyReferences copies the child manifests and the blobs referenced by the manifest. they are pushed before
+// the manifest, otherwise the destination refuses the manifest
+func (r *replicator) copyReferences(repository string, content []byte, mediaType string) error {
+	m, e := parseManifest(content, mediaType)
+	if e != nil {
+		return e
+	}
+
+	for _, child := range m.children {
+		dstDigest, e := r.dst.manifestDigest(repository, child.digest)
+		if e != nil {
+			return e
+		}
+		if dstDigest != "" {
+			continue
+		}
+
+		childContent, childMediaType, _, e := r.src.manifest(repository, child.digest)
+		if e != nil {
+			return e
+		}
+		if e := r.copyReferences(repository, childContent, childMediaType); e != nil {
+			return e
+		}
+		if e := r.dst.putManifest(repository, child.digest, childMediaType, childContent); e != nil {
+			return e
+		}
+	}
+
+	for _, b := range m.blobs {
+		if e := r.copyBlob(repository, b.digest); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// copyBlob copies the blob identified by digest to the repository in the destination. the blob is not transferred
+// if it exists in the repository, and it is mounted if it has been copied to another repository in the destination
+func (r *replicator) copyBlob(repository, digest string) error {
+	ex := r.execution
+	from, known := r.blobs[digest]
+	if known && from == repository {
+		ex.blobsSkipped++
+		return nil
+	}
+
+	exists, e := r.dst.blobExists(repository, digest)
+	if e != nil {
+		return e
+	}
+	if exists {
+		r.blobs[digest] = repository
+		ex.blobsSkipped++
+		return nil
+	}
+
+	location, mounted, e := r.dst.startUpload(repository, digest, from)
+	if e != nil {
+		return e
+	}
+	r.blobs[digest] = repository
+	if mounted {
+		ex.blobsSkipped++
+		return nil
+	}
+
+	content, size, e := r.src.blob(repository, digest)
+	if e != nil {
+		return e
+	}
+	defer content.Close()
+	if e := r.dst.finishUpload(location, digest, content, size); e != nil {
+		delete(r.blobs, digest)
+		return e
+	}
+	ex.blobsCopied++
+	if size > 0 {
+		ex.bytesCopied += size
+	}
+
+	return nil
+}
+
+// triggerReplicationOnPush starts the executions of the enabled push policies with on push trigger which match
+// the tag of the image pushed to the registry
+func triggerReplicationOnPush(imageName, tag string) {
+	policies, e := getReplicationPoliciesFromDB("")
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20312005, "error", "can not trigger replication for %s:%s: %s", imageName, tag, e)})
+		return
+	}
+
+	for _, p := range policies {
+		if !p.enabled || p.trigger != ReplicationTriggerOnPush || !p.matches(imageName, tag) {
+			continue
+		}
+		// the trigger is queued if the policy is being executed
+		if _, e := startReplication(p, ReplicationTriggerOnPush, imageName, tag); e != nil {
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20312006, "warning", "replication policy %s has not been triggered for %s:%s: %s", p.name, imageName, tag, e)})
+		}
+	}
+}
+
+// startReplicationScheduler starts the executions of the enabled scheduled policies when their intervals have
+// elapsed since their last executions. it never returns and should be run in a goroutine
+func startReplicationScheduler() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		policies, e := getReplicationPoliciesFromDB("")
+		if e != nil {
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20312007, "warning", "scheduled replication policies have not been checked: %s", e)})
+			continue
+		}
+
+		now := time.Now().Unix()
+		for _, p := range policies {
+			if !p.enabled || p.trigger != ReplicationTriggerScheduled {
+				continue
+			}
+			executions, e := getReplicationExecutionsFromDB(strconv.Itoa(p.id), 1)
+			if e != nil || (len(executions) > 0 && now-executions[0].startTime < int64(p.interval)*60) {
+				continue
+			}
+			if _, e := startReplication(p, ReplicationTriggerScheduled, "", ""); e != nil {
+				logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20312008, "warning", "scheduled replication policy %s has not been started: %s", p.name, e)})
+			}
+		}
+	}
+}
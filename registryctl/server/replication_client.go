@@ -0,0 +1,376 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"sysadm/registryctl/config"
+)
+
+// challengeParamPattern matches the parameters of the challenge in WWW-Authenticate header
+var challengeParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// linkPattern matches the url of the next page in Link header
+var linkPattern = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// registryClient accesses a registry with the registry API V2 for replication. both basic authentication and
+// token authentication are supported. a client should not be used by more than one goroutine at a time
+type registryClient struct {
+	// root url of the registry, such as https://registry.example.com:5000
+	url      string
+	username string
+	password string
+	// bearer token got from the token server of the registry
+	token  string
+	client *http.Client
+}
+
+// newRegistryClient creates a client for the registry with root url rawurl
+func newRegistryClient(rawurl, username, password string) *registryClient {
+	return &registryClient{
+		url:      strings.TrimSuffix(strings.TrimSpace(rawurl), "/"),
+		username: username,
+		password: password,
+		// there is not timeout for the client, because large blobs may be transferred
+		client: &http.Client{Transport: sysadmTransport},
+	}
+}
+
+// do sends the request to the registry. target is a path under the root url or an absolute url.
+// a token is requested and the request is sent again if the registry challenges for token authentication,
+// except for the request with a body which can not be sent twice
+func (rc *registryClient) do(method, target string, headers map[string]string, body io.Reader, size int64) (*http.Response, error) {
+	resp, e := rc.send(method, target, headers, body, size)
+	if e != nil {
+		return nil, e
+	}
+	if resp.StatusCode != http.StatusUnauthorized || body != nil {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if e := rc.fetchToken(challenge); e != nil {
+		return nil, e
+	}
+
+	return rc.send(method, target, headers, nil, 0)
+}
+
+// send sends the request with the token or the credentials of the client
+func (rc *registryClient) send(method, target string, headers map[string]string, body io.Reader, size int64) (*http.Response, error) {
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = rc.url + target
+	}
+
+	req, e := http.NewRequest(method, target, body)
+	if e != nil {
+		return nil, e
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("User-Agent", "registryctl-"+config.RegistryctlVer)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if rc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+rc.token)
+	} else if rc.username != "" {
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+
+	return rc.client.Do(req)
+}
+
+// fetchToken gets a token from the token server in the challenge with the credentials of the client
+func (rc *registryClient) fetchToken(challenge string) error {
+	params := make(map[string]string, 0)
+	for _, m := range challengeParamPattern.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	if params["realm"] == "" {
+		return fmt.Errorf("realm was not found in the challenge of %s", rc.url)
+	}
+
+	u, e := url.Parse(params["realm"])
+	if e != nil {
+		return fmt.Errorf("realm %s of %s is not valid: %s", params["realm"], rc.url, e)
+	}
+	query := u.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+	u.RawQuery = query.Encode()
+
+	req, e := http.NewRequest(http.MethodGet, u.String(), nil)
+	if e != nil {
+		return e
+	}
+	if rc.username != "" {
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+	resp, e := rc.client.Do(req)
+	if e != nil {
+		return fmt.Errorf("can not get token for %s: %s", rc.url, e)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("can not get token for %s, status: %d", rc.url, resp.StatusCode)
+	}
+
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if e := json.NewDecoder(resp.Body).Decode(&t); e != nil {
+		return fmt.Errorf("can not decode token for %s: %s", rc.url, e)
+	}
+	rc.token = t.Token
+	if rc.token == "" {
+		rc.token = t.AccessToken
+	}
+	if rc.token == "" {
+		return fmt.Errorf("token server of %s responsed an empty token", rc.url)
+	}
+
+	return nil
+}
+
+// getJSON gets the paginated list from target and calls decode with the body of each page
+func (rc *registryClient) getJSON(target string, decode func(body []byte) error) error {
+	for target != "" {
+		resp, e := rc.do(http.MethodGet, target, nil, nil, 0)
+		if e != nil {
+			return e
+		}
+		body, e := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if e != nil {
+			return e
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("can not get %s from %s, status: %d", target, rc.url, resp.StatusCode)
+		}
+		if e := decode(body); e != nil {
+			return e
+		}
+
+		target = ""
+		if m := linkPattern.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			target = m[1]
+		}
+	}
+
+	return nil
+}
+
+// catalog lists the repositories in the registry. some registries do not support listing the repositories
+func (rc *registryClient) catalog() ([]string, error) {
+	var repositories []string
+	e := rc.getJSON("/v2/_catalog?n=1000", func(body []byte) error {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		if e := json.Unmarshal(body, &page); e != nil {
+			return e
+		}
+		repositories = append(repositories, page.Repositories...)
+		return nil
+	})
+
+	return repositories, e
+}
+
+// tags lists the tags of the repository
+func (rc *registryClient) tags(repository string) ([]string, error) {
+	var tags []string
+	e := rc.getJSON("/v2/"+repository+"/tags/list", func(body []byte) error {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if e := json.Unmarshal(body, &page); e != nil {
+			return e
+		}
+		tags = append(tags, page.Tags...)
+		return nil
+	})
+
+	return tags, e
+}
+
+// manifest gets the manifest identified by reference of the repository. return the content, the media type and
+// the digest of the manifest
+func (rc *registryClient) manifest(repository, reference string) ([]byte, string, string, error) {
+	headers := map[string]string{"Accept": strings.Join(manifestAcceptTypes, ", ")}
+	resp, e := rc.do(http.MethodGet, "/v2/"+repository+"/manifests/"+reference, headers, nil, 0)
+	if e != nil {
+		return nil, "", "", e
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("can not get manifest %s of %s from %s, status: %d", reference, repository, rc.url, resp.StatusCode)
+	}
+
+	body, e := ioutil.ReadAll(resp.Body)
+	if e != nil {
+		return nil, "", "", e
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		sum := sha256.Sum256(body)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	return body, resp.Header.Get("Content-Type"), digest, nil
+}
+
+// manifestDigest gets the digest of the manifest identified by reference of the repository.
+// an empty digest is returned if the manifest does not exist
+func (rc *registryClient) manifestDigest(repository, reference string) (string, error) {
+	headers := map[string]string{"Accept": strings.Join(manifestAcceptTypes, ", ")}
+	resp, e := rc.do(http.MethodHead, "/v2/"+repository+"/manifests/"+reference, headers, nil, 0)
+	if e != nil {
+		return "", e
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("Docker-Content-Digest"), nil
+	case http.StatusNotFound:
+		return "", nil
+	}
+
+	return "", fmt.Errorf("can not check manifest %s of %s in %s, status: %d", reference, repository, rc.url, resp.StatusCode)
+}
+
+// putManifest pushes the manifest with reference to the repository
+func (rc *registryClient) putManifest(repository, reference, mediaType string, content []byte) error {
+	headers := map[string]string{"Content-Type": mediaType}
+	resp, e := rc.do(http.MethodPut, "/v2/"+repository+"/manifests/"+reference, headers, strings.NewReader(string(content)), int64(len(content)))
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("can not push manifest %s of %s to %s, status: %d %s", reference, repository, rc.url, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// blobExists checks whether the blob identified by digest exists in the repository
+func (rc *registryClient) blobExists(repository, digest string) (bool, error) {
+	resp, e := rc.do(http.MethodHead, "/v2/"+repository+"/blobs/"+digest, nil, nil, 0)
+	if e != nil {
+		return false, e
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+
+	return false, fmt.Errorf("can not check blob %s of %s in %s, status: %d", digest, repository, rc.url, resp.StatusCode)
+}
+
+// blob gets the content of the blob identified by digest of the repository. the caller should close the content
+func (rc *registryClient) blob(repository, digest string) (io.ReadCloser, int64, error) {
+	resp, e := rc.do(http.MethodGet, "/v2/"+repository+"/blobs/"+digest, nil, nil, 0)
+	if e != nil {
+		return nil, 0, e
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("can not get blob %s of %s from %s, status: %d", digest, repository, rc.url, resp.StatusCode)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// startUpload starts uploading the blob identified by digest to the repository. the blob is mounted from the
+// repository named from in the same registry if from is not empty. return true if the blob has been mounted,
+// otherwise return the location which the content of the blob should be uploaded to
+func (rc *registryClient) startUpload(repository, digest, from string) (string, bool, error) {
+	target := "/v2/" + repository + "/blobs/uploads/"
+	if from != "" {
+		target = target + "?mount=" + url.QueryEscape(digest) + "&from=" + url.QueryEscape(from)
+	}
+	resp, e := rc.do(http.MethodPost, target, nil, nil, 0)
+	if e != nil {
+		return "", false, e
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return "", true, nil
+	case http.StatusAccepted:
+		location, e := resp.Request.URL.Parse(resp.Header.Get("Location"))
+		if e != nil {
+			return "", false, fmt.Errorf("upload location of %s in %s is not valid: %s", repository, rc.url, e)
+		}
+		return location.String(), false, nil
+	}
+
+	return "", false, fmt.Errorf("can not start uploading blob %s to %s in %s, status: %d", digest, repository, rc.url, resp.StatusCode)
+}
+
+// finishUpload uploads the content of the blob identified by digest to the location got by startUpload
+func (rc *registryClient) finishUpload(location, digest string, content io.Reader, size int64) error {
+	u, e := url.Parse(location)
+	if e != nil {
+		return e
+	}
+	query := u.Query()
+	query.Set("digest", digest)
+	u.RawQuery = query.Encode()
+
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	resp, e := rc.do(http.MethodPut, u.String(), headers, content, size)
+	if e != nil {
+		return e
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("can not upload blob %s to %s, status: %d", digest, rc.url, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry is a registry stand-in which serves the registry API V2 used by the replication
+type fakeRegistry struct {
+	lock sync.Mutex
+	// manifests indexed by repository:reference, a manifest is indexed by its tags and its digest
+	manifests map[string]fakeManifest
+	// blobs indexed by repository@digest
+	blobs   map[string][]byte
+	uploads int
+}
+
+type fakeManifest struct {
+	mediaType string
+	digest    string
+	content   []byte
+}
+
+func newFakeRegistry(t *testing.T) (*fakeRegistry, *registryClient) {
+	reg := &fakeRegistry{manifests: make(map[string]fakeManifest, 0), blobs: make(map[string][]byte, 0)}
+	ts := httptest.NewServer(reg)
+	t.Cleanup(ts.Close)
+
+	return reg, newRegistryClient(ts.URL, "", "")
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// addImage adds an OCI image with a config and a layer to the repository with tag. return the digest of the manifest
+func (reg *fakeRegistry) addImage(t *testing.T, repository, tag, layer string) string {
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	m := ManifestV2{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		Config:        &Descriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: sha256Digest(config), Size: int64(len(config))},
+		Layers:        []Descriptor{{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: sha256Digest([]byte(layer)), Size: int64(len(layer))}},
+	}
+	content, e := json.Marshal(m)
+	if e != nil {
+		t.Fatalf("marshal manifest error: %s", e)
+	}
+
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	reg.blobs[repository+"@"+m.Config.Digest] = config
+	reg.blobs[repository+"@"+m.Layers[0].Digest] = []byte(layer)
+	fm := fakeManifest{mediaType: MediaTypeOCIManifest, digest: sha256Digest(content), content: content}
+	reg.manifests[repository+":"+tag] = fm
+	reg.manifests[repository+":"+fm.digest] = fm
+
+	return fm.digest
+}
+
+func (reg *fakeRegistry) manifestOf(repository, reference string) (fakeManifest, bool) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	m, ok := reg.manifests[repository+":"+reference]
+
+	return m, ok
+}
+
+func (reg *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/uploads/"):
+		repository := r.URL.Query().Get("repository")
+		content, _ := ioutil.ReadAll(r.Body)
+		digest := r.URL.Query().Get("digest")
+		if sha256Digest(content) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reg.blobs[repository+"@"+digest] = content
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasSuffix(p, "/blobs/uploads/"):
+		reg.uploads++
+		repository := strings.TrimSuffix(p, "/blobs/uploads/")
+		w.Header().Set("Location", fmt.Sprintf("/uploads/%d?repository=%s", reg.uploads, repository))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(p, "/blobs/"):
+		i := strings.LastIndex(p, "/blobs/")
+		content, ok := reg.blobs[p[:i]+"@"+p[i+len("/blobs/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	case strings.Contains(p, "/manifests/"):
+		i := strings.LastIndex(p, "/manifests/")
+		key := p[:i] + ":" + p[i+len("/manifests/"):]
+		if r.Method == http.MethodPut {
+			content, _ := ioutil.ReadAll(r.Body)
+			fm := fakeManifest{mediaType: r.Header.Get("Content-Type"), digest: sha256Digest(content), content: content}
+			reg.manifests[key] = fm
+			reg.manifests[p[:i]+":"+fm.digest] = fm
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		fm, ok := reg.manifests[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", fm.mediaType)
+		w.Header().Set("Docker-Content-Digest", fm.digest)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(fm.content)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestReplicator(src, dst *registryClient) *replicator {
+	return &replicator{src: src, dst: dst, blobs: make(map[string]string, 0), execution: &replicationExecution{}}
+}
+
+func TestReplicatorCopyTag(t *testing.T) {
+	srcReg, src := newFakeRegistry(t)
+	dstReg, dst := newFakeRegistry(t)
+	digest := srcReg.addImage(t, "library/nginx", "1.25", "layer of nginx")
+
+	r := newTestReplicator(src, dst)
+	copied, e := r.copyTag("library/nginx", "1.25")
+	if e != nil || !copied {
+		t.Fatalf("copy tag = %v, %v, want true, nil", copied, e)
+	}
+	if m, ok := dstReg.manifestOf("library/nginx", "1.25"); !ok || m.digest != digest {
+		t.Errorf("manifest of the tag in the destination is %s, want %s", m.digest, digest)
+	}
+	if r.execution.blobsCopied != 2 || r.execution.bytesCopied == 0 {
+		t.Errorf("blobs copied = %d bytes = %d, want 2 blobs", r.execution.blobsCopied, r.execution.bytesCopied)
+	}
+
+	// the tag which has the same manifest in the destination is skipped
+	copied, e = r.copyTag("library/nginx", "1.25")
+	if e != nil || copied {
+		t.Errorf("copy the same tag again = %v, %v, want false, nil", copied, e)
+	}
+}
+
+func TestReplicatorCopyTagImmutable(t *testing.T) {
+	srcReg, src := newFakeRegistry(t)
+	dstReg, dst := newFakeRegistry(t)
+	srcDigest := srcReg.addImage(t, "library/nginx", "1.25", "new layer of nginx")
+	dstDigest := dstReg.addImage(t, "library/nginx", "1.25", "old layer of nginx")
+	srcReg.addImage(t, "library/nginx", "1.26", "layer of nginx 1.26")
+
+	r := newTestReplicator(src, dst)
+	r.immutable = func(repository, tag string) bool {
+		return repository == "library/nginx" && strings.HasPrefix(tag, "1.")
+	}
+	if _, e := r.copyTag("library/nginx", "1.25"); e == nil {
+		t.Errorf("immutable tag in the destination has been overwritten")
+	}
+	if m, _ := dstReg.manifestOf("library/nginx", "1.25"); m.digest != dstDigest {
+		t.Errorf("manifest of immutable tag is %s, want %s", m.digest, dstDigest)
+	}
+
+	// an immutable tag which does not exist in the destination can be copied
+	if copied, e := r.copyTag("library/nginx", "1.26"); e != nil || !copied {
+		t.Errorf("copy new immutable tag = %v, %v, want true, nil", copied, e)
+	}
+
+	r.immutable = nil
+	if copied, e := r.copyTag("library/nginx", "1.25"); e != nil || !copied {
+		t.Fatalf("copy mutable tag = %v, %v, want true, nil", copied, e)
+	}
+	if m, _ := dstReg.manifestOf("library/nginx", "1.25"); m.digest != srcDigest {
+		t.Errorf("manifest of mutable tag is %s, want %s", m.digest, srcDigest)
+	}
+}
+
+func TestReplicationQueue(t *testing.T) {
+	q := &replicationQueue{running: make(map[int]bool, 0), pending: make(map[int][]replicationTarget, 0)}
+	first := replicationTarget{repository: "library/nginx", tag: "1.25"}
+	second := replicationTarget{repository: "library/nginx", tag: "1.26"}
+
+	if !q.start(1, first, true) {
+		t.Fatalf("policy which is not being executed can not be started")
+	}
+	if q.start(1, second, true) || q.start(1, second, true) || q.start(1, first, false) {
+		t.Fatalf("policy which is being executed has been started again")
+	}
+	if !q.start(2, first, true) {
+		t.Errorf("policies are not executed independently")
+	}
+
+	if got := q.next(1); !reflect.DeepEqual(got, []replicationTarget{second}) {
+		t.Errorf("queued targets = %v, want %v", got, []replicationTarget{second})
+	}
+	if !q.isRunning(1) {
+		t.Errorf("policy is not running while the queued targets are being replicated")
+	}
+	if got := q.next(1); len(got) != 0 || q.isRunning(1) {
+		t.Errorf("policy is still running after all queued targets have been replicated: %v", got)
+	}
+
+	// the targets covered by a queued target are not queued again
+	q.start(3, first, true)
+	q.start(3, replicationTarget{repository: "library/nginx"}, true)
+	q.start(3, second, true)
+	if got := q.next(3); !reflect.DeepEqual(got, []replicationTarget{{repository: "library/nginx"}}) {
+		t.Errorf("queued targets = %v, want all tags of library/nginx", got)
+	}
+}
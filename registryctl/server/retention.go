@@ -0,0 +1,447 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sysadm/db"
+	"sysadm/sysadmerror"
+	"sysadm/utils"
+)
+
+// retentionPolicyTable is the table which the retention policies are stored in
+const retentionPolicyTable = "retentionPolicy"
+
+// retentionPolicy decides which tags of the images of a project or a repository are kept. all rules of a policy
+// are keeping rules: a tag is kept if any rule keeps it, the other tags are deleted. the blobs of the deleted tags
+// are reclaimed by garbage collection
+type retentionPolicy struct {
+	id int
+	// name of the project which the policy applies to
+	project string
+	// name of the image which the policy applies to. the policy applies to all images of the project if it is empty
+	repository string
+	// number of the most recently pushed tags which are kept. 0 means the rule is not applied
+	keepLast int
+	// the tags whose names match the regular expression are kept. empty means the rule is not applied
+	keepRegex string
+	// the tags which have been pushed or pulled in the days are kept. 0 means the rule is not applied
+	expireDays int
+}
+
+// retentionCandidate is a tag which is deleted by a retention policy
+type retentionCandidate struct {
+	policyID int
+	image    string
+	tagID    string
+	tag      string
+	digest   string
+	size     int64
+}
+
+// repositoryNamePattern matches the names of the projects and the repositories
+var repositoryNamePattern = regexp.MustCompile("^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$")
+
+// retentionLock makes sure only one evaluation of the retention policies which deletes tags runs at a time
+var retentionLock sync.Mutex
+
+// validate checks whether the policy is valid
+func (p retentionPolicy) validate() error {
+	if !repositoryNamePattern.MatchString(p.project) || strings.Contains(p.project, "/") {
+		return fmt.Errorf("project name %q is not valid", p.project)
+	}
+	if p.repository != "" {
+		if !repositoryNamePattern.MatchString(p.repository) {
+			return fmt.Errorf("repository name %q is not valid", p.repository)
+		}
+		if !strings.HasPrefix(p.repository, p.project+"/") {
+			return fmt.Errorf("repository %s does not belong to project %s", p.repository, p.project)
+		}
+	}
+	if p.keepLast < 0 || p.expireDays < 0 {
+		return fmt.Errorf("the number of tags and the days must not be negative")
+	}
+	if p.keepRegex != "" {
+		if _, e := regexp.Compile(p.keepRegex); e != nil {
+			return fmt.Errorf("regular expression %s is not valid: %s", p.keepRegex, e)
+		}
+	}
+
+	// a policy without any keeping rule deletes all tags
+	if p.keepLast == 0 && p.keepRegex == "" && p.expireDays == 0 {
+		return fmt.Errorf("at least one of the rules must be set")
+	}
+
+	return nil
+}
+
+// appliesTo returns true if the policy applies to the image named imageName
+func (p retentionPolicy) appliesTo(imageName string) bool {
+	if p.repository != "" {
+		return p.repository == imageName
+	}
+
+	return strings.HasPrefix(imageName, p.project+"/")
+}
+
+// toMap converts the policy to the data which can be sent to the client
+func (p retentionPolicy) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"policyid":   p.id,
+		"project":    p.project,
+		"repository": p.repository,
+		"keeplast":   p.keepLast,
+		"keepregex":  p.keepRegex,
+		"expiredays": p.expireDays,
+	}
+}
+
+// toMap converts the candidate to the data which can be sent to the client
+func (c retentionCandidate) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"policyid": c.policyID,
+		"image":    c.image,
+		"tagid":    c.tagID,
+		"tag":      c.tag,
+		"digest":   c.digest,
+		"size":     c.size,
+	}
+}
+
+// addRetentionPolicyToDB validates the policy and inserts it into DB. there is one policy for a project or
+// a repository at most. return the ID of the policy
+func addRetentionPolicyToDB(p retentionPolicy) (int, error) {
+	if e := p.validate(); e != nil {
+		return 0, e
+	}
+
+	policies, e := getRetentionPoliciesFromDB("", p.project)
+	if e != nil {
+		return 0, e
+	}
+	for _, exist := range policies {
+		if exist.repository == p.repository {
+			return 0, fmt.Errorf("policy %d has been defined for %s", exist.id, retentionScope(p))
+		}
+	}
+
+	id, e := db.NextID(RuntimeData.RuningParas.DBConfig.Entity, retentionPolicyTable, "policyid")
+	if e != nil {
+		return 0, fmt.Errorf("can not allocate ID for the policy: %s", e)
+	}
+
+	now := time.Now().Unix()
+	data := make(db.FieldData, 0)
+	data["policyid"] = id
+	data["project"] = p.project
+	data["repository"] = p.repository
+	data["keep_last"] = p.keepLast
+	data["keep_regex"] = sqlStringValue(p.keepRegex)
+	data["expire_days"] = p.expireDays
+	data["creation_time"] = now
+	data["update_time"] = now
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	_, err := dbEntity.InsertData(retentionPolicyTable, data)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return 0, fmt.Errorf("can not insert the policy into DB")
+	}
+
+	return int(id), nil
+}
+
+// getRetentionPoliciesFromDB gets the policies identified by policyid or the policies of project.
+// all policies are returned if both of them are empty
+func getRetentionPoliciesFromDB(policyid string, project string) ([]retentionPolicy, error) {
+	whereMap := make(map[string]string, 0)
+	if policyid != "" {
+		id, e := strconv.Atoi(policyid)
+		if e != nil {
+			return nil, fmt.Errorf("policy id %s is not valid", policyid)
+		}
+		whereMap["policyid"] = "=" + strconv.Itoa(id)
+	}
+	if project != "" {
+		whereMap["project"] = "=\"" + project + "\""
+	}
+
+	selectData := db.SelectData{
+		Tb:        []string{retentionPolicyTable},
+		OutFeilds: []string{"policyid", "project", "repository", "keep_last", "keep_regex", "expire_days"},
+		Where:     whereMap,
+		Order:     []db.OrderData{{Key: "policyid", Order: 0}},
+	}
+
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	retData, err := dbEntity.QueryData(&selectData)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get retention policies from DB")
+	}
+
+	var policies []retentionPolicy
+	for _, line := range retData {
+		id, _ := utils.Interface2Int(line["policyid"])
+		keepLast, _ := utils.Interface2Int(line["keep_last"])
+		expireDays, _ := utils.Interface2Int(line["expire_days"])
+		policies = append(policies, retentionPolicy{
+			id:         id,
+			project:    utils.Interface2String(line["project"]),
+			repository: utils.Interface2String(line["repository"]),
+			keepLast:   keepLast,
+			keepRegex:  utils.Interface2String(line["keep_regex"]),
+			expireDays: expireDays,
+		})
+	}
+
+	return policies, nil
+}
+
+// delRetentionPolicyFromDB deletes the policy identified by policyid
+func delRetentionPolicyFromDB(policyid string) error {
+	id, e := strconv.Atoi(strings.TrimSpace(policyid))
+	if e != nil {
+		return fmt.Errorf("policy id %s is not valid", policyid)
+	}
+
+	delData := db.SelectData{
+		Tb:    []string{retentionPolicyTable},
+		Where: map[string]string{"policyid": "=" + strconv.Itoa(id)},
+	}
+	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
+	rows, err := dbEntity.DeleteData(&delData)
+	logErrors(err)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		return fmt.Errorf("can not delete policy %d", id)
+	}
+	if rows == 0 {
+		return fmt.Errorf("policy %d was not found", id)
+	}
+
+	return nil
+}
+
+// retentionScope returns the project or the repository which the policy applies to
+func retentionScope(p retentionPolicy) string {
+	if p.repository != "" {
+		return "repository " + p.repository
+	}
+
+	return "project " + p.project
+}
+
+// policyForImage returns the policy which applies to the image named imageName. the policy of the repository
+// takes precedence over the policy of the project. nil is returned if no policy applies to the image
+func policyForImage(policies []retentionPolicy, imageName string) *retentionPolicy {
+	var ret *retentionPolicy
+	for i := range policies {
+		p := &policies[i]
+		if !p.appliesTo(imageName) {
+			continue
+		}
+		if p.repository != "" {
+			return p
+		}
+		ret = p
+	}
+
+	return ret
+}
+
+// runRetention evaluates the retention policies and deletes the tags which are not kept by them.
+// only the images which the policy identified by policyid applies to are evaluated if policyid is not empty.
+// the tags are not deleted if dryRun is true. return the tags which are deleted or would be deleted
+func runRetention(policyid string, dryRun bool) ([]retentionCandidate, error) {
+	if !dryRun {
+		if !retentionLock.TryLock() {
+			return nil, fmt.Errorf("retention policies are being evaluated")
+		}
+		defer retentionLock.Unlock()
+
+		if isReadOnly() {
+			return nil, fmt.Errorf("registry is in read-only mode for garbage collection")
+		}
+	}
+
+	policies, e := getRetentionPoliciesFromDB("", "")
+	if e != nil {
+		return nil, e
+	}
+	if policyid != "" {
+		selected, e := getRetentionPoliciesFromDB(policyid, "")
+		if e != nil {
+			return nil, e
+		}
+		if len(selected) < 1 {
+			return nil, fmt.Errorf("policy %s was not found", policyid)
+		}
+	}
+
+	imgSets, err := getImageInfoFromDB("", "", "", "", 0, 0)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		logErrors(err)
+		return nil, fmt.Errorf("can not get images from DB")
+	}
+
+	now := time.Now()
+	candidates := []retentionCandidate{}
+	for _, line := range imgSets {
+		imageName := utils.Interface2String(line["name"])
+		p := policyForImage(policies, imageName)
+		if p == nil || (policyid != "" && strconv.Itoa(p.id) != policyid) {
+			continue
+		}
+
+		tagSets, _ := getTagInfoFromDB("", utils.Interface2String(line["imageid"]), "", "", "", 0, 0)
+		for _, c := range evaluateRetention(*p, imageName, tagSets, immutableTagsOf(imageName), now) {
+			if !dryRun {
+				if e := deleteTagForRetention(c); e != nil {
+					logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20310001, "error", "can not delete tag %s of image %s: %s", c.tag, c.image, e)})
+					continue
+				}
+			}
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates, nil
+}
+
+// evaluateRetention returns the tags in tagSets of the image named imageName which are not kept by the policy.
+// the tags for which immutable returns true are always kept whatever the policy is. the tags which have the same
+// manifest as a kept tag are kept too, because deleting the manifest deletes all tags of it from the registry
+func evaluateRetention(p retentionPolicy, imageName string, tagSets []map[string]interface{}, immutable func(tag string) bool, now time.Time) []retentionCandidate {
+	var re *regexp.Regexp
+	if p.keepRegex != "" {
+		re, _ = regexp.Compile(p.keepRegex)
+	}
+
+	// the most recently pushed tags are first
+	sort.SliceStable(tagSets, func(i, j int) bool {
+		ti, _ := utils.Interface2Int64(tagSets[i]["update_time"])
+		tj, _ := utils.Interface2Int64(tagSets[j]["update_time"])
+		return ti > tj
+	})
+
+	expireBefore := now.AddDate(0, 0, -p.expireDays).Unix()
+	kept := make(map[string]bool, 0)
+	var candidates []retentionCandidate
+	for i, line := range tagSets {
+		name := utils.Interface2String(line["name"])
+		digest := strings.ToLower(utils.Interface2String(line["digest"]))
+		pushTime, _ := utils.Interface2Int64(line["update_time"])
+		pullTime, _ := utils.Interface2Int64(line["pull_time"])
+
+		keep := immutable(name) ||
+			(p.keepLast > 0 && i < p.keepLast) ||
+			(re != nil && re.MatchString(name)) ||
+			(p.expireDays > 0 && (pushTime >= expireBefore || pullTime >= expireBefore))
+		if keep {
+			kept[digest] = true
+			continue
+		}
+
+		size, _ := utils.Interface2Int64(line["size"])
+		candidates = append(candidates, retentionCandidate{
+			policyID: p.id,
+			image:    imageName,
+			tagID:    utils.Interface2String(line["tagid"]),
+			tag:      name,
+			digest:   digest,
+			size:     size,
+		})
+	}
+
+	var ret []retentionCandidate
+	for _, c := range candidates {
+		if !kept[c.digest] {
+			ret = append(ret, c)
+		}
+	}
+
+	return ret
+}
+
+// deleteTagForRetention deletes the manifest of the tag from the registry and removes the tag, its child
+// manifests and the blobs of them from DB. the blobs are not deleted from the registry because they may be
+// shared with the other tags, garbage collection reclaims them
+func deleteTagForRetention(c retentionCandidate) error {
+	if c.digest != "" {
+		_, resp, errs := doRequest(&requestParams{method: http.MethodDelete, url: registryServerUrl() + "/v2/" + c.image + "/manifests/" + c.digest})
+		logErrors(errs)
+		if resp == nil {
+			return fmt.Errorf("can not delete manifest %s", c.digest)
+		}
+		if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("can not delete manifest %s, status: %d", c.digest, resp.StatusCode)
+		}
+	}
+
+	tagids := []string{c.tagID}
+	childSets, _ := getChildTagsFromDB(c.tagID)
+	for _, line := range childSets {
+		tagids = append(tagids, utils.Interface2String(line["tagid"]))
+	}
+	_ = delBlobFromDB("", strings.Join(tagids, ","), "")
+	if delTagsFromDB(strings.Join(tagids, ","), "", "") == 0 {
+		return fmt.Errorf("no tag has be deleted")
+	}
+
+	return nil
+}
+
+// startRetentionScheduler evaluates the retention policies every interval minutes. it never returns and
+// should be run in a goroutine. the policies are not evaluated on schedule if interval is not positive
+func startRetentionScheduler(interval int) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		var errs []sysadmerror.Sysadmerror
+		candidates, e := runRetention("", false)
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(20310002, "warning", "retention policies have not been evaluated: %s", e))
+		} else {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(20310003, "info", "retention policies have been evaluated, %d tags have been deleted", len(candidates)))
+		}
+		logErrors(errs)
+	}
+}
+
+// sqlStringValue escapes s for the string values in the SQL statements built by DB entity. the values are
+// quoted with double quotation marks without escaping by MySQL entity, while they are bound as parameters by
+// the other entities
+func sqlStringValue(s string) string {
+	if !strings.EqualFold(RuntimeData.RuningParas.DBConfig.Type, "mysql") {
+		return s
+	}
+
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\\", "\\\\"), "\"", "\\\"")
+}
@@ -0,0 +1,142 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// retentionTag returns a row of the tag as it is got from DB. pushed and pulled are the days before now
+func retentionTag(name, digest string, pushed, pulled int, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"tagid":       name + "-id",
+		"name":        name,
+		"digest":      digest,
+		"size":        int64(10),
+		"update_time": now.AddDate(0, 0, -pushed).Unix(),
+		"pull_time":   now.AddDate(0, 0, -pulled).Unix(),
+	}
+}
+
+func TestEvaluateRetention(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	notImmutable := func(tag string) bool { return false }
+	tags := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			retentionTag("v1", testDigest("1"), 30, 30, now),
+			retentionTag("v3", testDigest("3"), 1, 1, now),
+			retentionTag("v2", testDigest("2"), 10, 2, now),
+			retentionTag("release-1", testDigest("4"), 40, 40, now),
+			retentionTag("v1-alias", testDigest("3"), 50, 50, now),
+		}
+	}
+
+	cases := []struct {
+		name      string
+		policy    retentionPolicy
+		immutable func(tag string) bool
+		want      []string
+	}{
+		{
+			name:      "keep the most recently pushed tags",
+			policy:    retentionPolicy{keepLast: 2},
+			immutable: notImmutable,
+			want:      []string{"v1", "release-1"},
+		},
+		{
+			name:      "keep the tags matching the regular expression",
+			policy:    retentionPolicy{keepRegex: "^(release-.*|v3)$"},
+			immutable: notImmutable,
+			want:      []string{"v2", "v1"},
+		},
+		{
+			name:      "keep the tags pushed or pulled recently",
+			policy:    retentionPolicy{expireDays: 7},
+			immutable: notImmutable,
+			want:      []string{"v1", "release-1"},
+		},
+		{
+			name:      "keep the immutable tags",
+			policy:    retentionPolicy{keepLast: 1},
+			immutable: func(tag string) bool { return tag == "v1" || tag == "release-1" },
+			want:      []string{"v2"},
+		},
+	}
+
+	for _, c := range cases {
+		c.policy.id = 1
+		candidates := evaluateRetention(c.policy, "library/nginx", tags(), c.immutable, now)
+		got := []string{}
+		for _, candidate := range candidates {
+			if candidate.policyID != 1 || candidate.image != "library/nginx" || candidate.tagID != candidate.tag+"-id" || candidate.size != 10 {
+				t.Errorf("%s: candidate %+v is not filled", c.name, candidate)
+			}
+			got = append(got, candidate.tag)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: deleted tags %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPolicyForImage(t *testing.T) {
+	policies := []retentionPolicy{
+		{id: 1, project: "library"},
+		{id: 2, project: "library", repository: "library/nginx"},
+		{id: 3, project: "other"},
+	}
+
+	cases := map[string]int{
+		"library/nginx": 2,
+		"library/redis": 1,
+		"other/nginx":   3,
+		"unknown/nginx": 0,
+	}
+	for imageName, want := range cases {
+		got := 0
+		if p := policyForImage(policies, imageName); p != nil {
+			got = p.id
+		}
+		if got != want {
+			t.Errorf("policy for image %s is %d, want %d", imageName, got, want)
+		}
+	}
+}
+
+func TestImmutableRuleMatches(t *testing.T) {
+	cases := []struct {
+		rule  immutableRule
+		image string
+		tag   string
+		want  bool
+	}{
+		{immutableRule{project: "library", tag: "v*"}, "library/nginx", "v1", true},
+		{immutableRule{project: "library", tag: "v*"}, "library/nginx", "latest", false},
+		{immutableRule{project: "library", tag: "v*"}, "other/nginx", "v1", false},
+		{immutableRule{project: "library", repository: "library/ng*", tag: "*"}, "library/nginx", "latest", true},
+		{immutableRule{project: "library", repository: "library/ng*", tag: "*"}, "library/redis", "latest", false},
+	}
+
+	for _, c := range cases {
+		if got := c.rule.matches(c.image, c.tag); got != c.want {
+			t.Errorf("rule %+v matches tag %s of image %s: got %v, want %v", c.rule, c.tag, c.image, got, c.want)
+		}
+	}
+}
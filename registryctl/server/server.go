@@ -27,7 +27,10 @@ import (
 
 	"github.com/spf13/cobra"
 	"sysadm/registryctl/config"
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/sysadmLog"
 	"sysadm/sysadmerror"
+	sysadmSetting "sysadm/syssetting/app"
 	log "github.com/wangyysde/sysadmLog"
 	"github.com/wangyysde/sysadmServer"
 )
@@ -74,6 +77,12 @@ func DaemonStart(cmd *cobra.Command, cmdPath string){
 		os.Exit(202003)
 	}
 	
+	// loading the master keys which the passwords of replication endpoints are encrypted with
+	if _,e := sysadmObjects.LoadMasterKeys(RuntimeData.StartParas.SysadmRootPath); e != nil {
+		sysadmServer.Logf("fatal","erroCode: 20312009 Msg: load master keys error: %s",e)
+		os.Exit(20312009)
+	}
+
 	// handing DB configuration,initating an entity, open an connection to DB server according to the configuration
 	entity,errs := initDB(definedConfig,cmdPath)
 	if entity == nil {
@@ -82,6 +91,26 @@ func DaemonStart(cmd *cobra.Command, cmdPath string){
 	}
 	defer entity.CloseDB()
 
+	// re-encrypting the passwords of replication endpoints with the current master key. they are not known by
+	// the secret rotation of sysadm, so they are rotated when registryctl starts
+	if num,e := (replicationSecretRotator{}).RotateSecrets(); e != nil {
+		sysadmServer.Logf("warning","erroCode: 20312011 Msg: rotate passwords of replication endpoints error: %s",e)
+	} else if num > 0 {
+		sysadmServer.Logf("info","erroCode: 20312012 Msg: passwords of %d replication endpoints have been re-encrypted",num)
+	}
+
+	// the settings of signature verification are got through syssetting
+	if e := sysadmSetting.SetRunData(RuntimeData.RuningParas.DBConfig,newSysadmLogger(definedConfig),RuntimeData.StartParas.SysadmRootPath); e != nil {
+		sysadmServer.Logf("fatal","erroCode: 20314003 Msg: set run data for syssetting error: %s",e)
+		os.Exit(20314003)
+	}
+
+	// evaluating the retention policies on schedule
+	go startRetentionScheduler(definedConfig.Retention.Interval)
+
+	// starting the scheduled replication policies
+	go startReplicationScheduler()
+
 	// initating server
 	r := sysadmServer.New()
 	r.Use(sysadmServer.Logger(),sysadmServer.Recovery())
@@ -197,6 +226,24 @@ func closeLogger(){
 	}
 }
 
+// newSysadmLogger creates the logger for the modules which log with sysadmLog, such as syssetting.
+// the log files opened by setLogger are shared with it
+func newSysadmLogger(definedConfig *config.Config) *sysadmLog.LoggerConfig {
+	logger := sysadmLog.NewSysadmLogger()
+	logger.SetLoggerKind(definedConfig.Log.Kind)
+	logger.SetLoggerLevel(definedConfig.Log.Level)
+	logger.SetTimestampFormat(definedConfig.Log.TimeStampFormat)
+	if RuntimeData.RuningParas.AccessLogFp != nil {
+		logger.SetAccessLoggerWithFp(RuntimeData.RuningParas.AccessLogFp)
+	}
+	if RuntimeData.RuningParas.ErrorLogFp != nil {
+		logger.SetErrorLoggerWithFp(RuntimeData.RuningParas.ErrorLogFp)
+	}
+	logger.SetIsSplitLog(definedConfig.Log.SplitAccessAndError)
+
+	return logger
+}
+
 // Get the install dir path of  sysadm 
 func getSysadmRootPath(cmdPath string) (string,error){
 	dir ,error := filepath.Abs(filepath.Dir(cmdPath))
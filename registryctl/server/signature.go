@@ -0,0 +1,691 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/sysadmerror"
+	sysadmSetting "sysadm/syssetting/app"
+	"sysadm/utils"
+)
+
+// keys of the setting items of signature verification. they can be set globally or for a project
+const (
+	// SettingKeyForSignatureRequired the manifests of the images which are not signed by a trusted key can not be pulled
+	// if it is true
+	SettingKeyForSignatureRequired = "registrysignaturerequired"
+	// SettingKeyForTrustedKeys the PEM encoded public keys which cosign signatures are verified with and the
+	// certificates which notation signatures are verified with
+	SettingKeyForTrustedKeys = "registrytrustedkeys"
+)
+
+// media types and annotations of the signatures
+const (
+	cosignSimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	cosignSignatureAnnotation    = "dev.cosignproject.cosign/signature"
+	dsseEnvelopeMediaType        = "application/vnd.dsse.envelope.v1+json"
+	notationArtifactType         = "application/vnd.cncf.notary.signature"
+	notationJWSMediaType         = "application/jose+json"
+	notationCOSEMediaType        = "application/cose"
+	// cosign stores the signatures with the config of OCI images, notation may store them with the empty config
+	ociImageConfigMediaType = "application/vnd.oci.image.config.v1+json"
+	ociEmptyMediaType       = "application/vnd.oci.empty.v1+json"
+)
+
+// verifiedTTL is how long a manifest whose signature has been verified is served without verifying again
+const verifiedTTL = 5 * time.Minute
+
+// maxSignatureSize is the maximum size of the signature payloads and envelopes which are read from the registry
+const maxSignatureSize = 4 << 20
+
+// signatureSettings are the definitions of the setting items of signature verification
+var signatureSettings = []sysadmSetting.SettingDefinition{
+	{Key: SettingKeyForSignatureRequired, Type: sysadmSetting.SettingTypeBool, Default: "false",
+		Scopes:      []int{sysadmSetting.SettingScopeGlobal, sysadmSetting.SettingScopeProject},
+		Description: "whether the manifests of the images must be signed by a trusted key to be pulled"},
+	{Key: SettingKeyForTrustedKeys, Type: sysadmSetting.SettingTypeString,
+		Scopes: []int{sysadmSetting.SettingScopeGlobal, sysadmSetting.SettingScopeProject},
+		Validate: func(value string) error {
+			_, e := parseTrustedKeys(value)
+			return e
+		},
+		Description: "PEM encoded public keys for cosign signatures and certificates for notation signatures"},
+}
+
+// signatureTagPattern matches the tags which cosign signatures and attestations are stored with, and the referrers
+// tags which list the referrers of a manifest for the registries not supporting the referrers API
+var signatureTagPattern = regexp.MustCompile(`^sha256-([0-9a-f]{64})(\.sig|\.att)?$`)
+
+// verifiedManifests are the manifests whose signatures have been verified, indexed by <image name>@<digest>.
+// the values are the times when they were verified
+var verifiedManifests = make(map[string]time.Time, 0)
+
+// verifiedLock protects verifiedManifests
+var verifiedLock sync.Mutex
+
+func init() {
+	for _, def := range signatureSettings {
+		if e := sysadmSetting.RegisterSetting(def); e != nil {
+			panic(e)
+		}
+	}
+}
+
+// trustedKeys are the keys which the signatures are verified with
+type trustedKeys struct {
+	// public keys for cosign signatures
+	publicKeys []crypto.PublicKey
+	// root certificates for notation signatures
+	roots *x509.CertPool
+}
+
+// signatureDescriptor is a descriptor in a signature manifest or a referrers index
+type signatureDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// signatureManifest is a manifest which signatures are stored in, or an index which lists the referrers of a manifest
+type signatureManifest struct {
+	MediaType    string                `json:"mediaType"`
+	ArtifactType string                `json:"artifactType,omitempty"`
+	Config       *signatureDescriptor  `json:"config,omitempty"`
+	Layers       []signatureDescriptor `json:"layers,omitempty"`
+	Manifests    []signatureDescriptor `json:"manifests,omitempty"`
+	// the manifest which the signature signs
+	Subject *signatureDescriptor `json:"subject,omitempty"`
+}
+
+// parseTrustedKeys parses the PEM encoded public keys and certificates in value
+func parseTrustedKeys(value string) (trustedKeys, error) {
+	keys := trustedKeys{roots: x509.NewCertPool()}
+	rest := []byte(value)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, e := x509.ParsePKIXPublicKey(block.Bytes)
+			if e != nil {
+				return keys, fmt.Errorf("public key is not valid: %s", e)
+			}
+			keys.publicKeys = append(keys.publicKeys, key)
+		case "CERTIFICATE":
+			cert, e := x509.ParseCertificate(block.Bytes)
+			if e != nil {
+				return keys, fmt.Errorf("certificate is not valid: %s", e)
+			}
+			keys.roots.AddCert(cert)
+		default:
+			return keys, fmt.Errorf("PEM block %s is not supported, PUBLIC KEY or CERTIFICATE is expected", block.Type)
+		}
+	}
+	if strings.TrimSpace(string(rest)) != "" {
+		return keys, fmt.Errorf("trusted keys must be PEM encoded")
+	}
+
+	return keys, nil
+}
+
+// signaturePolicy resolves whether the signatures are required for the images of the project and the keys which
+// they are verified with
+func signaturePolicy(project string) (bool, trustedKeys, error) {
+	definedConfig := RuntimeData.RuningParas.DefinedConfig
+	projectid, errs := getProjectIdByName(definedConfig.Sysadm.Server.Tls, definedConfig.Sysadm.Server.Host,
+		definedConfig.Sysadm.Server.Port, definedConfig.Sysadm.ApiVerion, project)
+	logErrors(errs)
+
+	ctx := sysadmSetting.SettingContext{}
+	if projectid > 0 {
+		ctx.ProjectID = strconv.Itoa(projectid)
+	}
+	s := sysadmSetting.New()
+	required, _, e := s.ResolveBool(SettingKeyForSignatureRequired, ctx)
+	if e != nil && !sysadmSetting.IsSettingNotSet(e) {
+		return false, trustedKeys{}, e
+	}
+	if !required {
+		return false, trustedKeys{}, nil
+	}
+
+	ev, e := s.ResolveEffective(SettingKeyForTrustedKeys, ctx)
+	if e != nil && !sysadmSetting.IsSettingNotSet(e) {
+		return true, trustedKeys{}, e
+	}
+	keys, e := parseTrustedKeys(ev.Value)
+
+	return true, keys, e
+}
+
+// enforceSignatureOnPull refuses serving the manifest identified by reference of the image named imageName if the
+// signatures are required for the project of the image and the manifest is not signed by a trusted key.
+// the child manifests of a signed manifest list or index, and the signatures of a signed manifest can be served
+// without signatures. the manifest is refused if it can not be got from the registry. the response will be sent to
+// the client and false will be returned if the manifest is refused
+func enforceSignatureOnPull(c *sysadmServer.Context, imageName, reference string) bool {
+	required, keys, e := signaturePolicy(strings.Split(imageName, "/")[0])
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20314001, "error", "can not resolve signature policy for image %s: %s", imageName, e)})
+		rejectUnsigned(c, fmt.Sprintf("signature policy of image %s can not be resolved", imageName))
+		return false
+	}
+	if !required {
+		return true
+	}
+
+	rc := localRegistryClient()
+	content, _, digest, e := rc.manifest(imageName, reference)
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20314004, "info", "manifest %s of image %s has been refused: %s", reference, imageName, e)})
+		rejectUnsigned(c, fmt.Sprintf("manifest %s of image %s can not be verified", reference, imageName))
+		return false
+	}
+	if isVerified(imageName, digest) {
+		return true
+	}
+
+	// the signatures are served if the manifests they sign are signed by a trusted key
+	if subject, ok := signatureSubject(content, reference); ok {
+		if e = verifySubjectSignature(rc, imageName, subject, keys); e != nil {
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20314005, "info", "signature %s of image %s has been refused: %s", reference, imageName, e)})
+			rejectUnsigned(c, fmt.Sprintf("manifest %s of image %s is not signed by a trusted key", subject, imageName))
+			return false
+		}
+		return true
+	}
+
+	e = verifyManifestSignature(rc, imageName, digest, keys)
+	if e != nil && isDigestReference(reference) && verifyParentSignature(rc, imageName, digest, keys) {
+		e = nil
+	}
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(20314002, "info", "manifest %s of image %s has been refused: %s", reference, imageName, e)})
+		rejectUnsigned(c, fmt.Sprintf("manifest %s of image %s is not signed by a trusted key", reference, imageName))
+		return false
+	}
+
+	markVerified(imageName, digest)
+	// the child manifests are pulled by digest after the manifest list or the index
+	if m, e := parseManifest(content, ""); e == nil {
+		for _, child := range m.children {
+			markVerified(imageName, child.digest)
+		}
+	}
+
+	return true
+}
+
+// rejectUnsigned responses DENIED error with msg to the client
+func rejectUnsigned(c *sysadmServer.Context, msg string) {
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	c.JSON(http.StatusForbidden, ReponseError{Errors: []BodyError{{
+		Code:    RegistryErrs["denied"].Code,
+		Message: msg,
+		Detail:  RegistryErrs["denied"].Detail,
+	}}})
+}
+
+// isVerified returns true if the signature of the manifest has been verified in verifiedTTL
+func isVerified(imageName, digest string) bool {
+	verifiedLock.Lock()
+	defer verifiedLock.Unlock()
+
+	key := imageName + "@" + digest
+	t, ok := verifiedManifests[key]
+	if ok && time.Since(t) > verifiedTTL {
+		delete(verifiedManifests, key)
+		return false
+	}
+
+	return ok
+}
+
+// markVerified records the manifest whose signature has been verified
+func markVerified(imageName, digest string) {
+	verifiedLock.Lock()
+	defer verifiedLock.Unlock()
+
+	now := time.Now()
+	for key, t := range verifiedManifests {
+		if now.Sub(t) > verifiedTTL {
+			delete(verifiedManifests, key)
+		}
+	}
+	verifiedManifests[imageName+"@"+digest] = now
+}
+
+// signatureSubject returns the digest of the manifest which the signature in content signs. the signatures are
+// the cosign signatures and attestations stored with tags sha256-<hex>.sig and sha256-<hex>.att, the notation
+// signatures which subjects are the manifests, and the indexes stored with referrers tags sha256-<hex>.
+// false is returned if content is not a signature, or its config or any of its layers is not a signature
+func signatureSubject(content []byte, reference string) (string, bool) {
+	var m signatureManifest
+	if e := json.Unmarshal(content, &m); e != nil {
+		return "", false
+	}
+
+	tagSubject, suffix := "", ""
+	if match := signatureTagPattern.FindStringSubmatch(reference); match != nil {
+		tagSubject, suffix = "sha256:"+match[1], match[2]
+	}
+
+	switch {
+	case len(m.Manifests) > 0:
+		if tagSubject == "" || suffix != "" || m.Config != nil || len(m.Layers) > 0 {
+			return "", false
+		}
+		for _, d := range m.Manifests {
+			if d.ArtifactType == "" {
+				return "", false
+			}
+		}
+		return tagSubject, true
+	case suffix != "":
+		layerMediaType := cosignSimpleSigningMediaType
+		if suffix == ".att" {
+			layerMediaType = dsseEnvelopeMediaType
+		}
+		if !hasSignatureMediaTypes(m, []string{ociImageConfigMediaType, ociEmptyMediaType}, []string{layerMediaType}) {
+			return "", false
+		}
+		return tagSubject, true
+	case m.ArtifactType == notationArtifactType || (m.Config != nil && m.Config.MediaType == notationArtifactType):
+		if m.Subject == nil || m.Subject.Digest == "" {
+			return "", false
+		}
+		if !hasSignatureMediaTypes(m, []string{notationArtifactType, ociEmptyMediaType}, []string{notationJWSMediaType, notationCOSEMediaType}) {
+			return "", false
+		}
+		return m.Subject.Digest, true
+	}
+
+	return "", false
+}
+
+// hasSignatureMediaTypes returns true if the media type of the config of the manifest is one of configTypes and
+// the manifest has layers which media types are all in layerTypes
+func hasSignatureMediaTypes(m signatureManifest, configTypes, layerTypes []string) bool {
+	if m.Config == nil || !utils.FoundStrInSlice(configTypes, m.Config.MediaType, false) || len(m.Layers) < 1 {
+		return false
+	}
+	for _, layer := range m.Layers {
+		if !utils.FoundStrInSlice(layerTypes, layer.MediaType, false) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// verifySubjectSignature checks whether the manifest identified by digest, which is the subject of a signature,
+// is signed by a trusted key, or is a child of a manifest list or an index signed by a trusted key
+func verifySubjectSignature(rc *registryClient, imageName, digest string, keys trustedKeys) error {
+	if isVerified(imageName, digest) {
+		return nil
+	}
+
+	e := verifyManifestSignature(rc, imageName, digest, keys)
+	if e != nil && !verifyParentSignature(rc, imageName, digest, keys) {
+		return e
+	}
+	markVerified(imageName, digest)
+
+	return nil
+}
+
+// verifyParentSignature returns true if a manifest list or an index which the manifest identified by digest is a
+// child of has been signed by a trusted key
+func verifyParentSignature(rc *registryClient, imageName, digest string, keys trustedKeys) bool {
+	tagSets, _ := getTagInfoFromDB("", "", "", "", digest, 0, 0)
+	for _, line := range tagSets {
+		parentid := utils.Interface2String(line["parentid"])
+		if parentid == "" || parentid == "0" {
+			continue
+		}
+
+		parentSets, _ := getTagInfoFromDB(parentid, "", "", "", "", 0, 0)
+		for _, parent := range parentSets {
+			parentDigest := utils.Interface2String(parent["digest"])
+			if parentDigest == "" {
+				continue
+			}
+			if isVerified(imageName, parentDigest) || verifyManifestSignature(rc, imageName, parentDigest, keys) == nil {
+				markVerified(imageName, parentDigest)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// verifyManifestSignature verifies the cosign signatures and the notation signatures of the manifest identified by
+// digest. nil is returned if any of them is signed by a trusted key
+func verifyManifestSignature(rc *registryClient, imageName, digest string, keys trustedKeys) error {
+	cosignErr := verifyCosignSignature(rc, imageName, digest, keys)
+	if cosignErr == nil {
+		return nil
+	}
+	notationErr := verifyNotationSignature(rc, imageName, digest, keys)
+	if notationErr == nil {
+		return nil
+	}
+
+	return fmt.Errorf("cosign: %s, notation: %s", cosignErr, notationErr)
+}
+
+// verifyCosignSignature verifies the cosign signatures which are stored with tag sha256-<hex>.sig of the image.
+// each signature is an ECDSA, RSA or Ed25519 signature of a simple signing payload which has the digest of the manifest
+func verifyCosignSignature(rc *registryClient, imageName, digest string, keys trustedKeys) error {
+	if len(keys.publicKeys) < 1 {
+		return fmt.Errorf("no trusted public key")
+	}
+
+	content, _, _, e := rc.manifest(imageName, strings.Replace(digest, ":", "-", 1)+".sig")
+	if e != nil {
+		return fmt.Errorf("signature was not found")
+	}
+	var m signatureManifest
+	if e := json.Unmarshal(content, &m); e != nil {
+		return fmt.Errorf("signature manifest is not valid: %s", e)
+	}
+
+	for _, layer := range m.Layers {
+		if layer.MediaType != cosignSimpleSigningMediaType {
+			continue
+		}
+		sig, e := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+		if e != nil || len(sig) < 1 {
+			continue
+		}
+		payload, e := readSignatureBlob(rc, imageName, layer.Digest)
+		if e != nil || !verifyWithPublicKeys(keys.publicKeys, payload, sig) {
+			continue
+		}
+
+		var simpleSigning struct {
+			Critical struct {
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}
+		if json.Unmarshal(payload, &simpleSigning) == nil && simpleSigning.Critical.Image.DockerManifestDigest == digest {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no signature is signed by a trusted public key")
+}
+
+// verifyWithPublicKeys returns true if sig is the signature of the SHA-256 digest of payload signed by any of keys
+func verifyWithPublicKeys(keys []crypto.PublicKey, payload, sig []byte) bool {
+	sum := sha256.Sum256(payload)
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, sum[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil || rsa.VerifyPSS(k, crypto.SHA256, sum[:], sig, nil) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, sig) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// verifyNotationSignature verifies the notation signatures which are the referrers of the manifest. the referrers
+// are listed with the referrers API, or the referrers tag schema sha256-<hex> if the registry does not support it.
+// the signatures in JWS envelopes whose certificate chains are issued by a trusted certificate are verified,
+// COSE envelopes are not supported
+func verifyNotationSignature(rc *registryClient, imageName, digest string, keys trustedKeys) error {
+	referrers, e := listReferrers(rc, imageName, digest)
+	if e != nil {
+		return e
+	}
+
+	var lastErr error = fmt.Errorf("signature was not found")
+	for _, d := range referrers {
+		if d.ArtifactType != notationArtifactType {
+			continue
+		}
+		content, _, _, e := rc.manifest(imageName, d.Digest)
+		if e != nil {
+			lastErr = e
+			continue
+		}
+		var m signatureManifest
+		if e := json.Unmarshal(content, &m); e != nil || len(m.Layers) < 1 {
+			lastErr = fmt.Errorf("signature manifest %s is not valid", d.Digest)
+			continue
+		}
+		if m.Layers[0].MediaType != notationJWSMediaType {
+			lastErr = fmt.Errorf("signature envelope %s is not supported", m.Layers[0].MediaType)
+			continue
+		}
+
+		envelope, e := readSignatureBlob(rc, imageName, m.Layers[0].Digest)
+		if e != nil {
+			lastErr = e
+			continue
+		}
+		if lastErr = verifyJWSEnvelope(envelope, digest, keys); lastErr == nil {
+			return nil
+		}
+	}
+
+	return lastErr
+}
+
+// listReferrers lists the referrers of the manifest identified by digest
+func listReferrers(rc *registryClient, imageName, digest string) ([]signatureDescriptor, error) {
+	var content []byte
+	target := "/v2/" + imageName + "/referrers/" + digest + "?artifactType=" + url.QueryEscape(notationArtifactType)
+	resp, e := rc.do(http.MethodGet, target, map[string]string{"Accept": MediaTypeOCIIndex}, nil, 0)
+	if e != nil {
+		return nil, e
+	}
+	if resp.StatusCode == http.StatusOK {
+		content, e = ioutil.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+	}
+	resp.Body.Close()
+	if e != nil {
+		return nil, e
+	}
+
+	if content == nil {
+		content, _, _, e = rc.manifest(imageName, strings.Replace(digest, ":", "-", 1))
+		if e != nil {
+			return nil, fmt.Errorf("signature was not found")
+		}
+	}
+
+	var index signatureManifest
+	if e := json.Unmarshal(content, &index); e != nil {
+		return nil, fmt.Errorf("referrers of manifest %s are not valid: %s", digest, e)
+	}
+
+	return index.Manifests, nil
+}
+
+// verifyJWSEnvelope verifies the notation signature in JWS envelope. the signature must be signed by the leaf
+// certificate in the envelope, the certificate chain must be issued by a trusted certificate for code signing,
+// and the payload must have the digest of the manifest
+func verifyJWSEnvelope(envelope []byte, digest string, keys trustedKeys) error {
+	var jws struct {
+		Payload   string `json:"payload"`
+		Protected string `json:"protected"`
+		Header    struct {
+			X5c [][]byte `json:"x5c"`
+		} `json:"header"`
+		Signature string `json:"signature"`
+	}
+	if e := json.Unmarshal(envelope, &jws); e != nil {
+		return fmt.Errorf("JWS envelope is not valid: %s", e)
+	}
+	if len(jws.Header.X5c) < 1 {
+		return fmt.Errorf("certificate chain was not found in JWS envelope")
+	}
+
+	protected, e := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if e != nil {
+		return fmt.Errorf("protected header of JWS envelope is not valid: %s", e)
+	}
+	var header struct {
+		Alg         string `json:"alg"`
+		SigningTime string `json:"io.cncf.notary.signingTime"`
+	}
+	if e := json.Unmarshal(protected, &header); e != nil {
+		return fmt.Errorf("protected header of JWS envelope is not valid: %s", e)
+	}
+
+	var chain []*x509.Certificate
+	for _, der := range jws.Header.X5c {
+		cert, e := x509.ParseCertificate(der)
+		if e != nil {
+			return fmt.Errorf("certificate in JWS envelope is not valid: %s", e)
+		}
+		chain = append(chain, cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{Roots: keys.roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}}
+	if t, e := time.Parse(time.RFC3339, header.SigningTime); e == nil {
+		opts.CurrentTime = t
+	}
+	if _, e := chain[0].Verify(opts); e != nil {
+		return fmt.Errorf("certificate is not trusted: %s", e)
+	}
+
+	sig, e := base64.RawURLEncoding.DecodeString(jws.Signature)
+	if e != nil {
+		return fmt.Errorf("signature of JWS envelope is not valid: %s", e)
+	}
+	if e := verifyJWSSignature(header.Alg, chain[0].PublicKey, []byte(jws.Protected+"."+jws.Payload), sig); e != nil {
+		return e
+	}
+
+	payload, e := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if e != nil {
+		return fmt.Errorf("payload of JWS envelope is not valid: %s", e)
+	}
+	var target struct {
+		TargetArtifact struct {
+			Digest string `json:"digest"`
+		} `json:"targetArtifact"`
+	}
+	if e := json.Unmarshal(payload, &target); e != nil || target.TargetArtifact.Digest != digest {
+		return fmt.Errorf("signature is not signed for manifest %s", digest)
+	}
+
+	return nil
+}
+
+// verifyJWSSignature verifies sig of input with the algorithm alg which is supported by notation
+func verifyJWSSignature(alg string, key crypto.PublicKey, input, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "PS256", "ES256":
+		hash = crypto.SHA256
+	case "PS384", "ES384":
+		hash = crypto.SHA384
+	case "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("signing algorithm %s is not supported", alg)
+	}
+	h := hash.New()
+	h.Write(input)
+	sum := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") && rsa.VerifyPSS(k, hash, sum, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		// the signature is the concatenation of r and s in JWS
+		if strings.HasPrefix(alg, "ES") && len(sig)%2 == 0 {
+			r := new(big.Int).SetBytes(sig[:len(sig)/2])
+			s := new(big.Int).SetBytes(sig[len(sig)/2:])
+			if ecdsa.Verify(k, sum, r, s) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("signature of JWS envelope can not be verified")
+}
+
+// readSignatureBlob reads the blob identified by digest of the image and checks the digest of its content
+func readSignatureBlob(rc *registryClient, imageName, digest string) ([]byte, error) {
+	body, _, e := rc.blob(imageName, digest)
+	if e != nil {
+		return nil, e
+	}
+	defer body.Close()
+
+	content, e := ioutil.ReadAll(io.LimitReader(body, maxSignatureSize))
+	if e != nil {
+		return nil, e
+	}
+	sum := sha256.Sum256(content)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("digest of blob %s does not match its content", digest)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,74 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSignatureSubject(t *testing.T) {
+	subject := "sha256:" + strings.Repeat("ab", 32)
+	sigTag := "sha256-" + strings.Repeat("ab", 32)
+	descriptor := func(mediaType string) *signatureDescriptor {
+		return &signatureDescriptor{MediaType: mediaType, Digest: "sha256:" + strings.Repeat("cd", 32), Size: 10}
+	}
+	layers := func(mediaTypes ...string) []signatureDescriptor {
+		var ret []signatureDescriptor
+		for _, mediaType := range mediaTypes {
+			ret = append(ret, *descriptor(mediaType))
+		}
+		return ret
+	}
+
+	tests := []struct {
+		name      string
+		manifest  signatureManifest
+		reference string
+		subject   string
+		ok        bool
+	}{
+		{"cosign signature", signatureManifest{Config: descriptor(ociImageConfigMediaType), Layers: layers(cosignSimpleSigningMediaType)}, sigTag + ".sig", subject, true},
+		{"cosign attestation", signatureManifest{Config: descriptor(ociImageConfigMediaType), Layers: layers(dsseEnvelopeMediaType)}, sigTag + ".att", subject, true},
+		{"cosign signature with image layer", signatureManifest{Config: descriptor(ociImageConfigMediaType), Layers: layers(cosignSimpleSigningMediaType, "application/vnd.oci.image.layer.v1.tar+gzip")}, sigTag + ".sig", "", false},
+		{"cosign signature without layers", signatureManifest{Config: descriptor(ociImageConfigMediaType)}, sigTag + ".sig", "", false},
+		{"cosign signature with other tag", signatureManifest{Config: descriptor(ociImageConfigMediaType), Layers: layers(cosignSimpleSigningMediaType)}, "latest", "", false},
+		{"cosign signature by digest", signatureManifest{Config: descriptor(ociImageConfigMediaType), Layers: layers(cosignSimpleSigningMediaType)}, subject, "", false},
+		{"attestation with signature tag", signatureManifest{Config: descriptor(ociImageConfigMediaType), Layers: layers(dsseEnvelopeMediaType)}, sigTag + ".sig", "", false},
+		{"notation signature", signatureManifest{ArtifactType: notationArtifactType, Config: descriptor(ociEmptyMediaType), Layers: layers(notationJWSMediaType), Subject: &signatureDescriptor{Digest: subject}}, "sha256:" + strings.Repeat("ef", 32), subject, true},
+		{"notation signature with artifact config", signatureManifest{Config: descriptor(notationArtifactType), Layers: layers(notationCOSEMediaType), Subject: &signatureDescriptor{Digest: subject}}, "sha256:" + strings.Repeat("ef", 32), subject, true},
+		{"notation signature without subject", signatureManifest{ArtifactType: notationArtifactType, Config: descriptor(ociEmptyMediaType), Layers: layers(notationJWSMediaType)}, "sha256:" + strings.Repeat("ef", 32), "", false},
+		{"notation signature with image config", signatureManifest{ArtifactType: notationArtifactType, Config: descriptor(ociImageConfigMediaType), Layers: layers(notationJWSMediaType), Subject: &signatureDescriptor{Digest: subject}}, "sha256:" + strings.Repeat("ef", 32), "", false},
+		{"referrers index", signatureManifest{Manifests: []signatureDescriptor{{ArtifactType: notationArtifactType, Digest: subject}}}, sigTag, subject, true},
+		{"index with other tag", signatureManifest{Manifests: []signatureDescriptor{{ArtifactType: notationArtifactType, Digest: subject}}}, "latest", "", false},
+		{"referrers index with image", signatureManifest{Manifests: []signatureDescriptor{{ArtifactType: notationArtifactType, Digest: subject}, {Digest: subject}}}, sigTag, "", false},
+		{"image", signatureManifest{Config: descriptor(ociImageConfigMediaType), Layers: layers("application/vnd.oci.image.layer.v1.tar+gzip")}, "latest", "", false},
+	}
+
+	for _, tt := range tests {
+		content, e := json.Marshal(tt.manifest)
+		if e != nil {
+			t.Fatalf("%s: marshal manifest error: %s", tt.name, e)
+		}
+		subject, ok := signatureSubject(content, tt.reference)
+		if subject != tt.subject || ok != tt.ok {
+			t.Errorf("%s: signatureSubject() = %q, %v, want %q, %v", tt.name, subject, ok, tt.subject, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"sysadm/sysadm/server"
+)
+
+// define secret sub-command
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "manage the secrets which are encrypted by the master key",
+	Args:  cobra.NoArgs,
+}
+
+// define rotate sub-command of secret
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Re-encrypt all secrets stored in DB with the current master key",
+	Long: `Re-encrypt all secrets stored in DB with the current master key.
+The first key in the master key file or SYSADM_MASTER_KEY is the current master key, and the old keys
+should be kept after it until the rotation is finished. Secrets stored in clear text are encrypted too.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		server.CliData.ConfigPath = cfgFile
+		server.RotateSecrets(os.Args[0])
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(rotateCmd)
+}
@@ -0,0 +1,71 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"sysadm/sysadm/server"
+)
+
+var (
+	sessionUserID string
+	logoutAll     bool
+)
+
+// define session sub-command
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "manage the sessions of users which are stored in redis",
+	Args:  cobra.NoArgs,
+}
+
+// define list sub-command of session
+var sessionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the active sessions of a user",
+	Run: func(cmd *cobra.Command, args []string) {
+		server.CliData.ConfigPath = cfgFile
+		server.ListSessions(os.Args[0], sessionUserID)
+	},
+	Args: cobra.NoArgs,
+}
+
+// define logout sub-command of session
+var sessionLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Force a user or all users to logout",
+	Long: `Force a user or all users to logout by destroying their sessions.
+The users should login again before their next requests.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		server.CliData.ConfigPath = cfgFile
+		server.LogoutSessions(os.Args[0], sessionUserID, logoutAll)
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	rootCmd.AddCommand(sessionCmd)
+	sessionCmd.AddCommand(sessionListCmd)
+	sessionCmd.AddCommand(sessionLogoutCmd)
+
+	sessionListCmd.Flags().StringVarP(&sessionUserID, "user", "u", "", "ID of the user")
+	sessionLogoutCmd.Flags().StringVarP(&sessionUserID, "user", "u", "", "ID of the user")
+	sessionLogoutCmd.Flags().BoolVarP(&logoutAll, "all", "a", false, "force all users to logout")
+}
@@ -21,12 +21,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	sysadmDB "sysadm/db"
+	"sysadm/redis"
 	"sysadm/utils"
 	"github.com/wangyysde/sysadmServer"
 	"github.com/wangyysde/yaml"
@@ -779,6 +781,67 @@ func getDBDbIdleConnect(confContent *Config) int{
 	return defaultConfig.DB.DbIdleConnect
 }
 
+// Getting replicas of DB from environment(comma separated) and checking the validity of them
+// return the replicas if they are valid ,otherwise getting replicas of DB from
+// configuration file. return empty if no replica has be set.
+func getDBReplicas(confContent *Config) []string{
+	return getDBAddresses(os.Getenv("SYSADMSERVER_DBREPLICAS"), confContent, func(c *Config) []string { return c.DB.Replicas })
+}
+
+// Getting standbys of DB from environment(comma separated) and checking the validity of them
+// return the standbys if they are valid ,otherwise getting standbys of DB from
+// configuration file. return empty if no standby has be set.
+func getDBStandbys(confContent *Config) []string{
+	return getDBAddresses(os.Getenv("SYSADMSERVER_DBSTANDBYS"), confContent, func(c *Config) []string { return c.DB.Standbys })
+}
+
+func getDBAddresses(envValue string, confContent *Config, fromConf func(*Config) []string) []string{
+	if envValue != "" {
+		addresses := strings.Split(envValue, ",")
+		if _, err := sysadmDB.ParseEndpoints(addresses, 0); err == nil {
+			return addresses
+		}
+	}
+
+	if confContent != nil {
+		addresses := fromConf(confContent)
+		if _, err := sysadmDB.ParseEndpoints(addresses, 0); err == nil {
+			return addresses
+		}
+	}
+
+	return []string{}
+}
+
+// Getting the interval of health probes for DB from environment and checking the validity of it
+// return the interval if it is valid ,otherwise getting the interval from
+// configuration file and checking the validity of it. return the interval if it is valid.
+// otherwise return the default interval.
+// a negative interval disables health probes and 0 means using the default interval.
+func getDBHealthCheckInterval(confContent *Config) int{
+	interval := os.Getenv("SYSADMSERVER_DBHEALTHCHECKINTERVAL")
+	if interval != ""{
+		i, err := strconv.Atoi(interval)
+		if err == nil && i < 0 {
+			return 0
+		}
+		if err == nil && i > 0 && i <= 3600 {
+			return i
+		}
+	}
+
+	if confContent != nil  {
+		if confContent.DB.HealthCheckInterval < 0 {
+			return 0
+		}
+		if confContent.DB.HealthCheckInterval > 0 && confContent.DB.HealthCheckInterval <= 3600 {
+			return confContent.DB.HealthCheckInterval
+		}
+	}
+
+	return defaultConfig.DB.HealthCheckInterval
+}
+
 // Getting Sslmode of Postgre  from environment and checking the validity of it
 // return the Sslmode if it is valid ,otherwise getting Sslmode of Postgre  from 
 // configuration file and checking the validity of it. return the user if it is valid.
@@ -1273,6 +1336,161 @@ func handleRegistryctlConfig(registryConfig *ApiServer,cmdRunPath string){
 	}
 }
 
+// handleRedisConfig get the configurations of redis from environment or configuration file.
+// redis will be disabled(Addrs is empty) if the addresses of redis are not valid.
+func handleRedisConfig(confContent *Config, cmdRunPath string){
+	redisConf := defaultConfig.Redis
+	if confContent != nil {
+		redisConf = confContent.Redis
+	}
+
+	if mode := os.Getenv("SYSADMSERVER_REDISMODE"); mode != "" {
+		if m, err := strconv.Atoi(mode); err == nil {
+			redisConf.Mode = m
+		}
+	}
+	if addrs := os.Getenv("SYSADMSERVER_REDISADDRS"); addrs != "" {
+		redisConf.Addrs = addrs
+	}
+	if master := os.Getenv("SYSADMSERVER_REDISMASTER"); master != "" {
+		redisConf.Master = master
+	}
+	if password := os.Getenv("SYSADMSERVER_REDISPASSWORD"); password != "" {
+		redisConf.Password = password
+	}
+
+	ConfigDefined.Redis = Redis{Mode: defaultConfig.Redis.Mode, DB: defaultConfig.Redis.DB}
+	redisConf.Addrs = strings.TrimSpace(redisConf.Addrs)
+	if redisConf.Addrs == "" {
+		return
+	}
+
+	if redisConf.Mode < redis.RedisModeSingle || redisConf.Mode > redis.RedisModeSentinel {
+		sysadmServer.Logf("warning","redis mode(%d) is not valid. single mode will be used",redisConf.Mode)
+		redisConf.Mode = defaultConfig.Redis.Mode
+	}
+
+	redisConf.Master = strings.TrimSpace(redisConf.Master)
+	if !redis.IsValidMaster(redisConf.Mode, redisConf.Master) || !redis.IsValidAddrs(redisConf.Mode, redisConf.Addrs) {
+		sysadmServer.Logf("warning","redis master(%s) or addresses(%s) is not valid. redis will be disabled",redisConf.Master, redisConf.Addrs)
+		return
+	}
+
+	if redisConf.DB < 0 {
+		redisConf.DB = defaultConfig.Redis.DB
+	}
+
+	if redisConf.Tls {
+		if redisConf.Ca == "" || !checkFileExists(redisConf.Ca, cmdRunPath) {
+			sysadmServer.Logf("warning","Tls of redis has be set to true But CA(%s) can not be found. We will try to set Tls to false",redisConf.Ca)
+			redisConf.Tls = false
+		}
+	}
+
+	redisConf.Username = strings.TrimSpace(redisConf.Username)
+	redisConf.SentinelUsername = strings.TrimSpace(redisConf.SentinelUsername)
+	ConfigDefined.Redis = redisConf
+}
+
+// handleLdapConfig get the configurations of LDAP from environment or configuration file.
+// LDAP will be disabled if the url or the user search settings are not valid.
+func handleLdapConfig(confContent *Config, cmdRunPath string){
+	ldapConf := defaultConfig.Ldap
+	if confContent != nil {
+		ldapConf = confContent.Ldap
+	}
+
+	if enabled := os.Getenv("SYSADMSERVER_LDAPENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			ldapConf.Enabled = b
+		}
+	}
+	if ldapURL := os.Getenv("SYSADMSERVER_LDAPURL"); ldapURL != "" {
+		ldapConf.Url = ldapURL
+	}
+	if bindDN := os.Getenv("SYSADMSERVER_LDAPBINDDN"); bindDN != "" {
+		ldapConf.BindDN = bindDN
+	}
+	if password := os.Getenv("SYSADMSERVER_LDAPBINDPASSWORD"); password != "" {
+		ldapConf.BindPassword = password
+	}
+
+	ConfigDefined.Ldap = Ldap{Enabled: false, Timeout: defaultConfig.Ldap.Timeout}
+	if !ldapConf.Enabled {
+		return
+	}
+
+	ldapConf.Url = strings.TrimSpace(ldapConf.Url)
+	ldapConf.UserBaseDN = strings.TrimSpace(ldapConf.UserBaseDN)
+	if ldapConf.Url == "" || ldapConf.UserBaseDN == "" || strings.Count(ldapConf.UserFilter, "%s") != 1 {
+		sysadmServer.Logf("warning","url(%s), userBaseDN(%s) or userFilter(%s) of LDAP is not valid. LDAP will be disabled",ldapConf.Url, ldapConf.UserBaseDN, ldapConf.UserFilter)
+		return
+	}
+
+	if ldapConf.Ca != "" && !checkFileExists(ldapConf.Ca, cmdRunPath) {
+		sysadmServer.Logf("warning","CA(%s) of LDAP can not be found. system CAs will be used",ldapConf.Ca)
+		ldapConf.Ca = ""
+	}
+
+	if ldapConf.Timeout < 1 {
+		ldapConf.Timeout = defaultConfig.Ldap.Timeout
+	}
+
+	ConfigDefined.Ldap = ldapConf
+}
+
+// handleOidcConfig get the configurations of OpenID Connect from environment or configuration file.
+// OpenID Connect will be disabled if the issuer, client ID or redirect URL is not valid.
+func handleOidcConfig(confContent *Config, cmdRunPath string){
+	oidcConf := defaultConfig.Oidc
+	if confContent != nil {
+		oidcConf = confContent.Oidc
+	}
+
+	if enabled := os.Getenv("SYSADMSERVER_OIDCENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			oidcConf.Enabled = b
+		}
+	}
+	if issuer := os.Getenv("SYSADMSERVER_OIDCISSUER"); issuer != "" {
+		oidcConf.Issuer = issuer
+	}
+	if clientID := os.Getenv("SYSADMSERVER_OIDCCLIENTID"); clientID != "" {
+		oidcConf.ClientID = clientID
+	}
+	if secret := os.Getenv("SYSADMSERVER_OIDCCLIENTSECRET"); secret != "" {
+		oidcConf.ClientSecret = secret
+	}
+	if redirectURL := os.Getenv("SYSADMSERVER_OIDCREDIRECTURL"); redirectURL != "" {
+		oidcConf.RedirectURL = redirectURL
+	}
+
+	ConfigDefined.Oidc = Oidc{Enabled: false, Timeout: defaultConfig.Oidc.Timeout}
+	if !oidcConf.Enabled {
+		return
+	}
+
+	oidcConf.Issuer = strings.TrimSpace(oidcConf.Issuer)
+	oidcConf.ClientID = strings.TrimSpace(oidcConf.ClientID)
+	oidcConf.RedirectURL = strings.TrimSpace(oidcConf.RedirectURL)
+	redirectURL, err := url.Parse(oidcConf.RedirectURL)
+	if oidcConf.Issuer == "" || oidcConf.ClientID == "" || err != nil || !redirectURL.IsAbs() {
+		sysadmServer.Logf("warning","issuer(%s), clientID(%s) or redirectURL(%s) of OpenID Connect is not valid. OpenID Connect will be disabled",oidcConf.Issuer, oidcConf.ClientID, oidcConf.RedirectURL)
+		return
+	}
+
+	if oidcConf.Ca != "" && !checkFileExists(oidcConf.Ca, cmdRunPath) {
+		sysadmServer.Logf("warning","CA(%s) of OpenID Connect can not be found. system CAs will be used",oidcConf.Ca)
+		oidcConf.Ca = ""
+	}
+
+	if oidcConf.Timeout < 1 {
+		oidcConf.Timeout = defaultConfig.Oidc.Timeout
+	}
+
+	ConfigDefined.Oidc = oidcConf
+}
+
 // Try to get the values of items of configuration from OS variables ,configuratio file or default value.
 // The value of a item will be come from OS variables first ,then come from configuration file and last come from default value.
 // All the values of items should be passed check when set it to ConfigDefined
@@ -1378,8 +1596,14 @@ func HandleConfig(configPath string, cmdRunPath string) (*Config,error) {
 
 	ConfigDefined.DB.DbMaxConnect = getDBMaxConnect(confContent)
 	ConfigDefined.DB.DbIdleConnect = getDBDbIdleConnect(confContent)
+	ConfigDefined.DB.Replicas = getDBReplicas(confContent)
+	ConfigDefined.DB.Standbys = getDBStandbys(confContent)
+	ConfigDefined.DB.HealthCheckInterval = getDBHealthCheckInterval(confContent)
 
 	handleRegistryctlConfig(&confContent.Registryctl,cmdRunPath)
+	handleRedisConfig(confContent,cmdRunPath)
+	handleLdapConfig(confContent,cmdRunPath)
+	handleOidcConfig(confContent,cmdRunPath)
 	return &ConfigDefined,nil
 }
 
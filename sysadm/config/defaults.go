@@ -48,6 +48,11 @@ var DefaultDbSslmode = "disable"
 var DefaltDbSslrootcert = ""
 var DefaultDbSslkey = ""
 var DefaultDbSslcert = ""
+var DefaultDbHealthCheckInterval = 10
+var DefaultRedisMode = 1
+var DefaultRedisDB = 0
+var DefaultLdapTimeout = 10
+var DefaultOidcTimeout = 10
 var DefaultHtmlPath = "html/"
 var DefaultPath = "index.html"
 var ImagesDir = "images"
@@ -74,6 +74,88 @@ type DB struct {
 	Sslrootcert string `json:"sslrootcert"`
 	Sslkey string `json:"sslkey"`
 	Sslcert string `json:"sslcert"`
+	// read-only replicas in "host:port" format. list queries will be sent to the replicas if they are healthy
+	Replicas []string `json:"replicas"`
+	// standby servers in "host:port" format which may be promoted to primary when host is unavailable
+	Standbys []string `json:"standbys"`
+	// interval in seconds of health probes for DB servers. 0 means the default interval and a negative value disables health probes
+	HealthCheckInterval int `json:"healthCheckInterval"`
+}
+
+// Defining redis configuration. redis is optional, the features depending on it such as command queue
+// will fall back to DB when Addrs is empty
+type Redis struct {
+	// connection mode 1 for single server; 2 for cluster; 3 for sentinel mode
+	Mode int `json:"mode"`
+	// master name of sentinel. it is required only when mode is 3
+	Master string `json:"master"`
+	// addresses of redis servers or sentinels join with semicolon, like as localhost:6379;192.168.1.10:6379
+	Addrs string `json:"addrs"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	SentinelUsername string `json:"sentinelUsername"`
+	SentinelPassword string `json:"sentinelPassword"`
+	DB int `json:"db"`
+	Tls bool `json:"tls"`
+	Ca string `json:"ca"`
+	Cert string `json:"cert"`
+	Key string `json:"key"`
+}
+
+// Defining LDAP/AD authentication configuration. local users are always authenticated by the password stored in DB,
+// users who are not exist in DB will be authenticated by LDAP and created automatically on first login when Enabled is true
+type Ldap struct {
+	Enabled bool `json:"enabled"`
+	// url of LDAP server, like as ldap://127.0.0.1:389 or ldaps://ad.example.com:636
+	Url string `json:"url"`
+	StartTLS bool `json:"startTLS"`
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+	// CA file(PEM) to verify the certificate of LDAP server. system CAs will be used if it is empty
+	Ca string `json:"ca"`
+	BindDN string `json:"bindDN"`
+	BindPassword string `json:"bindPassword"`
+	UserBaseDN string `json:"userBaseDN"`
+	// %s in the filter will be replaced with the escaped username, like as (&(objectClass=person)(uid=%s))
+	UserFilter string `json:"userFilter"`
+	UsernameAttr string `json:"usernameAttr"`
+	EmailAttr string `json:"emailAttr"`
+	RealnameAttr string `json:"realnameAttr"`
+	MemberOfAttr string `json:"memberOfAttr"`
+	GroupBaseDN string `json:"groupBaseDN"`
+	// %s in the filter will be replaced with the escaped DN of the user, like as (&(objectClass=groupOfNames)(member=%s))
+	GroupFilter string `json:"groupFilter"`
+	GroupNameAttr string `json:"groupNameAttr"`
+	// LDAP group name or DN to the name of local user group
+	GroupMapping map[string]string `json:"groupMapping"`
+	// members of these LDAP groups are sysadmins
+	AdminGroups []string `json:"adminGroups"`
+	// timeout in seconds
+	Timeout int `json:"timeout"`
+}
+
+// Defining OpenID Connect configuration. sysadm acts as a relying party with authorization code and PKCE flow,
+// the users authenticated by the provider will be created automatically on first login when Enabled is true
+type Oidc struct {
+	Enabled bool `json:"enabled"`
+	Issuer string `json:"issuer"`
+	ClientID string `json:"clientID"`
+	ClientSecret string `json:"clientSecret"`
+	// the url the provider redirects users to after authentication, like as https://sysadm.example.com/oidc/callback
+	RedirectURL string `json:"redirectURL"`
+	Scopes []string `json:"scopes"`
+	UsernameClaim string `json:"usernameClaim"`
+	EmailClaim string `json:"emailClaim"`
+	RealnameClaim string `json:"realnameClaim"`
+	GroupsClaim string `json:"groupsClaim"`
+	// group name in the groups claim to the name of local user group
+	GroupMapping map[string]string `json:"groupMapping"`
+	// members of these groups are sysadmins
+	AdminGroups []string `json:"adminGroups"`
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+	// CA file(PEM) to verify the certificate of the provider. system CAs will be used if it is empty
+	Ca string `json:"ca"`
+	// timeout in seconds
+	Timeout int `json:"timeout"`
 }
 
 type Config struct {
@@ -84,6 +166,9 @@ type Config struct {
 	User User `json:"user"`
 	DB DB `json:"db"`
 	Registryctl ApiServer `json:"registryctl"`
+	Redis Redis `json:"redis"`
+	Ldap Ldap `json:"ldap"`
+	Oidc Oidc `json:"oidc"`
 }
 
 var DefinedConfig Config = Config{}
@@ -133,6 +218,20 @@ var defaultConfig Config = Config{
 		Sslrootcert: DefaltDbSslrootcert,
 		Sslkey: DefaultDbSslkey,
 		Sslcert: DefaultDbSslcert,
+		HealthCheckInterval: DefaultDbHealthCheckInterval,
+	},
+	Redis: Redis{
+		Mode: DefaultRedisMode,
+		Addrs: "",
+		DB: DefaultRedisDB,
+	},
+	Ldap: Ldap{
+		Enabled: false,
+		Timeout: DefaultLdapTimeout,
+	},
+	Oidc: Oidc{
+		Enabled: false,
+		Timeout: DefaultOidcTimeout,
 	},
 	Registryctl: ApiServer {
 		ApiVersion: DefaultApiVersion,
@@ -76,6 +76,11 @@ func apiHandlers(c *sysadmServer.Context){
 		return 
 	}
 
+	// scripts and CI call the API with API tokens, the operator of the request is the owner of the token or the session
+	if !authenticateBearer(c) {
+		return
+	}
+
 	action =strings.ToLower(action)
 	mI := Modules[module].Instance
 	mI.ActionHanderCaller(action,c)
@@ -42,7 +42,7 @@ func addAvailablezoneHandlers(r *sysadmServer.Engine) []sysadmerror.Sysadmerror
 
 	sysadmAZ.SetSessionOptions(sessionOptions, sessionName)
 	pageInfo := sysadmSetting.PageInfo{
-		NumPerPage: numPerPage,
+		NumPerPage: getNumPerPage(),
 	}
 
 	sysadmAZ.SetPageInfo(pageInfo)
@@ -112,8 +112,36 @@ num: lines of the result will be returned.
 */
 func receiveCommandStatusHandler(c *sysadmServer.Context) {
 	var errs []sysadmerror.Sysadmerror
-	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1101004, "debug", "now handling project list"))
-	// TODO
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(700030006, "debug", "received a command status"))
+
+	body, err := httpclient.GetRequestBody(c.Request)
+	errs = append(errs, err...)
+	if len(body) < 1 {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700030007, "error", "parameters error"))
+		err := apiutils.SendResponseForErrorMessage(c, 700030007, "parameters error")
+		errs = append(errs, err...)
+		logErrors(errs)
+		return
+	}
+
+	commandStatus, e := apiServerApp.UnMarshalCommandStatus(body)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700030008, "error", "command status data is not valid %s", e))
+		c.JSON(http.StatusOK, apiServerApp.RepStatus{StatusCode: apiServerApp.ComandStatusSendError, Message: "command status data is not valid"})
+		logErrors(errs)
+		return
+	}
+
+	err = infrastructure.ReceiveCommandStatus(commandStatus.CommandSeq, int(commandStatus.StatusCode))
+	errs = append(errs, err...)
+	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error") {
+		c.JSON(http.StatusOK, apiServerApp.RepStatus{CommandSeq: commandStatus.CommandSeq, StatusCode: apiServerApp.ComandStatusSendError, Message: "save command status error"})
+		logErrors(errs)
+		return
+	}
+
+	c.JSON(http.StatusOK, apiServerApp.RepStatus{CommandSeq: commandStatus.CommandSeq, StatusCode: apiServerApp.ComandStatusReceived})
+	logErrors(errs)
 }
 
 /*
@@ -42,7 +42,7 @@ func addDatacenterHandlers(r *sysadmServer.Engine) []sysadmerror.Sysadmerror {
 
 	sysadmDC.SetSessionOptions(sessionOptions, sessionName)
 	pageInfo := sysadmSetting.PageInfo{
-		NumPerPage: numPerPage,
+		NumPerPage: getNumPerPage(),
 	}
 
 	sysadmDC.SetPageInfo(pageInfo)
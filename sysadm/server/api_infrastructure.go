@@ -66,7 +66,8 @@ func AddInfrastructureHandlers(r *sysadmServer.Engine) []sysadmerror.Sysadmerror
 	errs = append(errs, err...)
 
 	infrastructureApp.SetSessionOptions(sessionOptions.Path, sessionOptions.Domain, sessionName, sessionOptions.MaxAge, sessionOptions.Secure, sessionOptions.HttpOnly)
-	infrastructureApp.SetPageInfo(numPerPage)
+	infrastructureApp.SetPageInfo(getNumPerPage())
+	infrastructureApp.SetRedisEntity(RuntimeData.RuningParas.RedisEntity)
 	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("fatal") {
 		return errs
 	}
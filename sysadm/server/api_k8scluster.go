@@ -42,7 +42,7 @@ func addK8sclusterHandlers(r *sysadmServer.Engine) []sysadmerror.Sysadmerror {
 
 	k8scluster.SetSessionOptions(sessionOptions, sessionName)
 	pageInfo := sysadmSetting.PageInfo{
-		NumPerPage: numPerPage,
+		NumPerPage: getNumPerPage(),
 	}
 
 	k8scluster.SetPageInfo(pageInfo)
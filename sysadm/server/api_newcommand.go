@@ -42,7 +42,7 @@ func addNewCommandHandlers(r *sysadmServer.Engine) []sysadmerror.Sysadmerror {
 
 	sysadmCommand.SetSessionOptions(sessionOptions, sessionName)
 	pageInfo := sysadmSetting.PageInfo{
-		NumPerPage: numPerPage,
+		NumPerPage: getNumPerPage(),
 	}
 
 	sysadmCommand.SetPageInfo(pageInfo)
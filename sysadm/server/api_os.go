@@ -42,7 +42,7 @@ func addOSHandlers(r *sysadmServer.Engine) []sysadmerror.Sysadmerror {
 
 	sysadmOS.SetSessionOptions(sessionOptions, sessionName)
 	pageInfo := sysadmSetting.PageInfo{
-		NumPerPage: numPerPage,
+		NumPerPage: getNumPerPage(),
 	}
 
 	sysadmOS.SetPageInfo(pageInfo)
@@ -28,12 +28,13 @@ import (
 
 	"sysadm/db"
 	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
 	"sysadm/utils"
 	"github.com/wangyysde/sysadmServer"
 )
 
 
-var  projectActions = []string{"list","add","getcount","del","getinfo"}
+var  projectActions = []string{"list","add","getcount","del","getinfo","addmember","delmember","listmembers"}
 
 
  func (p Project)ModuleName()string{
@@ -52,6 +53,12 @@ func (p Project) ActionHanderCaller(action string, c *sysadmServer.Context){
 			p.delHandler(c)
 		case "getinfo":
 			p.getInfoHandler(c)
+		case "addmember":
+			p.memberHandler(c,true)
+		case "delmember":
+			p.memberHandler(c,false)
+		case "listmembers":
+			p.listMembersHandler(c)
 	}
 	
 }
@@ -83,6 +90,22 @@ func (p Project) listHandler(c *sysadmServer.Context){
 		where[deleted] = "deleted=0 " 
 	}
 
+	accessible,e := restrictProjects(c,where)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1080018,"error","get projects which the user can access error: %s",e))
+		logErrors(errs)
+		ret := buildResponse(1080018,false,"database query error")
+		c.JSON(http.StatusOK, ret)
+		return 
+	}
+	if !accessible {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1080101,"debug","no project has be queried."))
+		logErrors(errs)
+		ret := buildResponse(1080101,false,"no project has be queried.")
+		c.JSON(http.StatusOK, ret)
+		return 
+	}
+
 	start, _ := c.GetQuery("start")
 	num, _ := c.GetQuery("num")
 	start = strings.ToLower(strings.TrimSpace(start))
@@ -269,6 +292,25 @@ func (p Project) getCountHandler(c *sysadmServer.Context){
 		where[deleted] = "deleted=0 " 
 	}
 
+	accessible,e := restrictProjects(c,where)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1080019,"error","get projects which the user can access error: %s",e))
+		logErrors(errs)
+		ret := buildResponse(1080019,false,"database query error")
+		c.JSON(http.StatusOK, ret)
+		return 
+	}
+	if !accessible {
+		logErrors(errs)
+		ret := ApiResponseStatus {
+			Status: true,
+			Errorcode: 0,
+			Message: []map[string]string{{"num": "0"}},
+		}
+		c.JSON(http.StatusOK, ret)
+		return 
+	}
+
 	field, okField := c.GetQuery("field")
 	if !okField {
 		field = "*"
@@ -326,6 +368,15 @@ func (p Project) delHandler(c *sysadmServer.Context){
 		c.JSON(http.StatusOK, ret)
 		return 
 	}
+
+	// only the operator who can delete all of the projects is allowed
+	allowed,e := canOperateProjects(c,data,userApp.PermissionDelete)
+	if !allowed {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1080025,"debug","operator %s can not delete projects %v %v",operatorID(c),data,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1080025,false,"permission denied"))
+		return 
+	}
 	logErrors(errs)
 	errs = errs[0:0]
 	var ids = ""
@@ -474,4 +525,119 @@ func (p Project) getInfoHandler(c *sysadmServer.Context){
 	}
 
 	c.JSON(http.StatusOK, ret)
-}
\ No newline at end of file
+}
+
+/*
+	restrictProjects restrict the projects queried by where to the projects which can be accessed by the operator
+	of the request. userid in the request only narrows the projects of the requests which carry neither a session
+	nor an API token, such as the requests sent by the forms of the server. where will not be changed if the user is
+	unknown or an administrator of the system. false will be returned if the user can not access any project queried
+	by where.
+*/
+func restrictProjects(c *sysadmServer.Context, where map[string]string) (bool, error) {
+	userid := operatorID(c)
+	if userid == "" {
+		userid,_ = c.GetQuery("userid")
+		userid = strings.TrimSpace(userid)
+	}
+	if userid == "" {
+		return true, nil
+	}
+
+	ids,all,e := userApp.AccessibleProjectIDs(userid)
+	if e != nil || all {
+		return all, e
+	}
+
+	// projectid has be specified by conditionKey
+	if cond,ok := where["projectid"]; ok {
+		id := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(cond),"="))
+		for _,v := range ids {
+			if v == id {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if len(ids) < 1 {
+		return false, nil
+	}
+	where["projectid"] = " in (" + strings.Join(ids,",") + ")"
+
+	return true, nil
+}
+
+/*
+	memberHandler add the user or user group identified by memberid to the project identified by projectid with role 
+	if add is true, otherwise remove the member from the project. membertype is 1 for user and 2 for user group.
+	the operator of the request should have the manage permission on the project.
+*/
+func (p Project) memberHandler(c *sysadmServer.Context, add bool){
+	var errs []sysadmerror.Sysadmerror
+	keys := []string{"projectid","membertype","memberid","role"}
+	datas,err := utils.GetRequestData(c,keys)
+	errs = append(errs,err...)
+	projectid := strings.TrimSpace(datas["projectid"])
+	memberid := strings.TrimSpace(datas["memberid"])
+	operatorid := operatorID(c)
+	memberType := userApp.MemberTypeUser
+	if strings.TrimSpace(datas["membertype"]) != "" {
+		memberType,_ = strconv.Atoi(strings.TrimSpace(datas["membertype"]))
+	}
+	if projectid == "" || memberid == "" || operatorid == "" {
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1080020,false,"projectid and memberid should not be empty, and the operator should login"))
+		return 
+	}
+
+	allowed,e := userApp.HasProjectPermission(operatorid,projectid,userApp.PermissionManage)
+	if !allowed {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1080021,"debug","user %s can not manage members of project %s %v",operatorid,projectid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1080021,false,"permission denied"))
+		return 
+	}
+
+	if add {
+		e = userApp.SetProjectMember(projectid,memberType,memberid,datas["role"])
+	} else {
+		e = userApp.RemoveProjectMember(projectid,memberType,memberid)
+	}
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1080022,"error","change members of project %s error: %s",projectid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1080022,false,e.Error()))
+		return 
+	}
+
+	logErrors(errs)
+	c.JSON(http.StatusOK, buildResponse(0,true,""))
+}
+
+/*
+	listMembersHandler list the members of the project identified by projectid
+*/
+func (p Project) listMembersHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	projectid,_ := c.GetQuery("projectid")
+	if strings.TrimSpace(projectid) == "" {
+		c.JSON(http.StatusOK, buildResponse(1080023,false,"projectid should not be empty"))
+		return 
+	}
+
+	members,e := userApp.ListProjectMembers(projectid)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1080024,"error","list members of project %s error: %s",projectid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1080024,false,"database query error"))
+		return 
+	}
+
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: members,
+	}
+	c.JSON(http.StatusOK, ret)
+}
@@ -23,6 +23,7 @@ import (
 	"sysadm/httpclient"
 	"sysadm/sysadmapi/apiutils"
 	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
 	"sysadm/utils"
 	"github.com/wangyysde/sysadmServer"
 )
@@ -123,6 +124,20 @@ func (r Registryctl) imagedelHandler(c *sysadmServer.Context) {
 		return
 	}
 
+	// the operator should have the delete permission on the projects of all the images
+	projectids, e := projectIDsOfImages(data)
+	allowed := false
+	if e == nil {
+		allowed, e = canOperateProjects(c, projectids, userApp.PermissionDelete)
+	}
+	if !allowed {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700010012, "debug", "operator %s can not delete images %v %v", operatorID(c), data, e))
+		err := apiutils.SendResponseForErrorMessage(c, 700010012, "permission denied")
+		errs = append(errs, err...)
+		logErrors(errs)
+		return
+	}
+
 	imageidStr := ""
 	for _, id := range data {
 		if imageidStr == "" {
@@ -192,6 +207,20 @@ func (r Registryctl) tagdelHandler(c *sysadmServer.Context) {
 		return
 	}
 
+	// the operator should have the delete permission on the projects of all the tags
+	projectids, e := projectIDsOfTags(data)
+	allowed := false
+	if e == nil {
+		allowed, e = canOperateProjects(c, projectids, userApp.PermissionDelete)
+	}
+	if !allowed {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700010013, "debug", "operator %s can not delete tags %v %v", operatorID(c), data, e))
+		err := apiutils.SendResponseForErrorMessage(c, 700010013, "permission denied")
+		errs = append(errs, err...)
+		logErrors(errs)
+		return
+	}
+
 	tagidStr := ""
 	for _, id := range data {
 		if tagidStr == "" {
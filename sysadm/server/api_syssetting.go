@@ -41,7 +41,7 @@ func addSyssettingHandlers(r *sysadmServer.Engine) []sysadmerror.Sysadmerror {
 
 	sysadmSysSetting.SetSessionOptions(sessionOptions, sessionName)
 	pageInfo := sysadmSysSetting.PageInfo{
-		NumPerPage: numPerPage,
+		NumPerPage: getNumPerPage(),
 	}
 
 	sysadmSysSetting.SetPageInfo(pageInfo)
@@ -18,17 +18,31 @@
 package server
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"sysadm/db"
 	"sysadm/sysadmerror"
+	sysadmObjects "sysadm/objects/app"
+	userApp "sysadm/user/app"
 	"github.com/wangyysde/sysadmServer"
 )
 
 type apiUserHandler func (u User)(c *sysadmServer.Context)
 
-var  userActions = []string{"login","getinfo"}
+var  userActions = []string{"login","getinfo","setpassword","authorize","addgroup","delgroup","listgroups","addgroupmember","delgroupmember","totpenroll","totpconfirm","totpdisable","recoverycodes","tokencreate","tokenlist","tokenrevoke","serviceaccountadd","serviceaccountlist","serviceaccountdel"}
+
+// apiErrCodePasswordExpired is the error code responsed by login action when the password of the user has expired
+const apiErrCodePasswordExpired = 1040017
+
+// apiErrCodeTotpRequired is the error code responsed by login action when the password is correct but TOTP code is required
+const apiErrCodeTotpRequired = 1040038
+
+// apiErrCodeTotpEnrollRequired is the error code responsed by login action when the password is correct but the user
+// must enroll TOTP before login
+const apiErrCodeTotpEnrollRequired = 1040039
 
 
  func (u User) ModuleName()string{
@@ -41,6 +55,40 @@ func (u User) ActionHanderCaller(action string, c *sysadmServer.Context){
 			u.loginHandler(c)
 		case "getinfo":
 			u.getInfoHandler(c)
+		case "setpassword":
+			u.setPasswordHandler(c)
+		case "authorize":
+			u.authorizeHandler(c)
+		case "addgroup":
+			u.addGroupHandler(c)
+		case "delgroup":
+			u.delGroupHandler(c)
+		case "listgroups":
+			u.listGroupsHandler(c)
+		case "addgroupmember":
+			u.groupMemberHandler(c,true)
+		case "delgroupmember":
+			u.groupMemberHandler(c,false)
+		case "totpenroll":
+			u.totpEnrollHandler(c)
+		case "totpconfirm":
+			u.totpConfirmHandler(c)
+		case "totpdisable":
+			u.totpDisableHandler(c)
+		case "recoverycodes":
+			u.recoveryCodesHandler(c)
+		case "tokencreate":
+			u.tokenCreateHandler(c)
+		case "tokenlist":
+			u.tokenListHandler(c)
+		case "tokenrevoke":
+			u.tokenRevokeHandler(c)
+		case "serviceaccountadd":
+			u.serviceAccountAddHandler(c)
+		case "serviceaccountlist":
+			u.serviceAccountListHandler(c)
+		case "serviceaccountdel":
+			u.serviceAccountDelHandler(c)
 	}
 	
 	return
@@ -58,7 +106,6 @@ func (u User) loginHandler(c *sysadmServer.Context){
 	username,okUsername := c.GetQuery("username")
 	password,okPassword := c.GetQuery("password")
 	username = strings.TrimSpace(username)
-	password = strings.TrimSpace(password)
 
 	if username == "" || password == "" || !okUsername || !okPassword {
 		ret := ApiResponseStatus {
@@ -70,61 +117,93 @@ func (u User) loginHandler(c *sysadmServer.Context){
 		return 
 	}
 
-	// Qeurying data from DB
-	selectData := db.SelectData{
-		Tb: []string{"user"},
-		OutFeilds: []string{"userid","username","password","salt",},
-		Where: map[string]string{"username": "='" + username + "' and deleted=0"},
+	// API tokens can be used instead of the password by the clients such as registryctl which specify the scope 
+	// the token must have. the tokens can not be used to login the web console, it does not specify the scope
+	if userApp.IsApiToken(password) {
+		scope,_ := c.GetQuery("scope")
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040055,"debug","user %s try to login with an api token without scope.",username))
+			logErrors(errs)
+			c.JSON(http.StatusOK, buildResponse(1040055,false,"username or password incorrect"))
+			return 
+		}
+
+		user,e := userApp.AuthenticateWithToken(username,password,scope)
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040056,"debug","user %s try to login with an api token, but token is not valid: %s",username,e))
+			logErrors(errs)
+			c.JSON(http.StatusOK, buildResponse(1040056,false,"username or password incorrect"))
+			return 
+		}
+
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040010,"debug","user %s login successful with an api token.",username))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(0,true,user.Id))
+		return 
 	}
-	dbEntity := RuntimeData.RuningParas.DBConfig.Entity
-	retData,err := dbEntity.QueryData(&selectData)
-	if sysadmerror.GetMaxLevel(err) >= sysadmerror.GetLevelNum("error"){
-		errs = append(errs,err...)
+
+	// local users are authenticated by the passwords stored in DB and the others by the external authenticators such as LDAP
+	user,ok,e := userApp.Authenticate(username,password)
+	if !ok && e != nil && !errors.Is(e,userApp.ErrAuthenticationFailed) {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040005,"error","authenticate user %s error: %s",username,e))
 		logErrors(errs)
 		ret := ApiResponseStatus {
 			Status: false,
 			Errorcode: 1040005,
-			Message: "database query error",
+			Message: "authenticate user error",
 		}
 		c.JSON(http.StatusOK, ret)
 		return 
-	} 
-
-	// if the user is not exist in DB 
-	if len(retData) < 1 {
-		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040006,"debug","user %s try to login by api ,but username %s is not exist.",username,username))
-		logErrors(err)
+	}
+	if ok && errors.Is(e,userApp.ErrPasswordExpired) {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040017,"debug","user %s try to login by api, but password has expired.",username))
+		logErrors(errs)
 		ret := ApiResponseStatus {
 			Status: false,
-			Errorcode: 1040006,
-			Message: "username or password incorrect",
+			Errorcode: apiErrCodePasswordExpired,
+			Message: "password has expired",
 		}
 		c.JSON(http.StatusOK, ret)
 		return 
 	}
-	
-	// checking password 
-	row := retData[0]
-	dbPassword := string(row["password"].([]uint8))
-	salt := string(row["salt"].([]uint8))
-	userid := string(row["userid"].([]uint8))
-	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040007,"debug","dbpassword %s.",dbPassword))
-	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040008,"debug","password %s.",password))
-	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040009,"debug","salt %s.",salt))
-	if(md5Encrypt(password,salt) == strings.TrimSpace(dbPassword)) {
+	if e != nil {
+		// the password is correct if ok is true, so the user can login although the hash of the password can not be upgraded 
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040018,"error","verify password of user %s error: %s",username,e))
+	}
+
+	if ok {
+		// the user has not login until the second factor has be verified if TOTP has be enabled or is required
+		totpCode,_ := c.GetQuery("totp")
+		errCode,msg,e := checkSecondFactor(user,strings.TrimSpace(totpCode))
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(errCode,"error","check second factor of user %s error: %s",username,e))
+		}
+		if errCode != 0 {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040042,"debug","user %s try to login by api, but second factor is required or incorrect: %s",username,msg))
+			logErrors(errs)
+			ret := ApiResponseStatus {
+				Status: false,
+				Errorcode: errCode,
+				Message: msg,
+			}
+			c.JSON(http.StatusOK, ret)
+			return 
+		}
+
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040010,"debug","user %s login successful.",username))
-		logErrors(err)
+		logErrors(errs)
 		ret := ApiResponseStatus {
 			Status: true,
 			Errorcode: 0,
-			Message: userid,
+			Message: user.Id,
 		}
 		c.JSON(http.StatusOK, ret)
 		return 
 	}
 
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040011,"debug","user %s try to login by api, but password error.",username))
-	logErrors(err)
+	logErrors(errs)
 	ret := ApiResponseStatus {
 		Status: false,
 		Errorcode: 1040008,
@@ -136,6 +215,119 @@ func (u User) loginHandler(c *sysadmServer.Context){
 
 }
 
+/*
+	checkSecondFactor check the TOTP code of the user whose password has be verified.
+	it returns apiErrCodeTotpRequired with the ID of the user as message if TOTP has be enabled but code is empty, 
+	and returns apiErrCodeTotpEnrollRequired with the ID of the user as message if TOTP is required by the policy
+	but has not be enabled, so the caller can keep the login pending until the second factor is verified.
+	code may be a TOTP code or a recovery code. 0 will be returned if the user can login. 
+*/
+func checkSecondFactor(user userApp.UserSchema, code string) (int,string,error) {
+	userEntity := userApp.New()
+	if user.TotpEnabled == 1 {
+		if code == "" {
+			return apiErrCodeTotpRequired,user.Id,nil
+		}
+
+		// the API login has no session to count the attempts like the login form, so they are counted for the user
+		ok,e := userEntity.VerifyTotpLoginWithLimit(user.Id,code)
+		if errors.Is(e,userApp.ErrTotpAttemptsExceeded) {
+			return 1040074,"too many totp attempts, please try again later",nil
+		}
+		if e != nil {
+			return 1040041,"verify totp code error",e
+		}
+		if !ok {
+			return 1040040,"totp code incorrect",nil
+		}
+
+		return 0,"",nil
+	}
+
+	required,e := userApp.IsTotpRequired(user)
+	if e != nil {
+		return 1040041,"get totp policy error",e
+	}
+	if required {
+		return apiErrCodeTotpEnrollRequired,user.Id,nil
+	}
+
+	return 0,"",nil
+}
+
+/*
+	setPasswordHandler change the password of the user to password after the old password has be verified.
+	the user whose password has expired can change the password through this handler. 
+	the new password must match the password policy and must not be one of the passwords used recently.
+*/
+func (u User) setPasswordHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040019,"debug","now handling setpassword action handler through api."))
+
+	username,_ := c.GetQuery("username")
+	oldPassword,_ := c.GetQuery("oldpassword")
+	password,_ := c.GetQuery("password")
+	username = strings.TrimSpace(username)
+	if username == "" || oldPassword == "" || password == "" {
+		ret := ApiResponseStatus {
+			Status: false,
+			Errorcode: 1040020,
+			Message: "username, old password and new password should not be empty",
+		}
+		c.JSON(http.StatusOK, ret)
+		return 
+	}
+
+	userEntity := userApp.New()
+	user,e := userEntity.GetByField("username",username)
+	if e != nil || user.Id == "" {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040021,"debug","get user %s error: %v",username,e))
+		logErrors(errs)
+		ret := ApiResponseStatus {
+			Status: false,
+			Errorcode: 1040021,
+			Message: "username or password incorrect",
+		}
+		c.JSON(http.StatusOK, ret)
+		return 
+	}
+
+	// the password which has expired can be used to change the password 
+	ok,e := userEntity.VerifyPassword(user,oldPassword)
+	if !ok {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040022,"debug","user %s try to change password, but old password error: %v",username,e))
+		logErrors(errs)
+		ret := ApiResponseStatus {
+			Status: false,
+			Errorcode: 1040022,
+			Message: "username or password incorrect",
+		}
+		c.JSON(http.StatusOK, ret)
+		return 
+	}
+
+	if e := userEntity.SetPassword(user.Id,password); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040023,"debug","set password of user %s error: %s",username,e))
+		logErrors(errs)
+		ret := ApiResponseStatus {
+			Status: false,
+			Errorcode: 1040023,
+			Message: e.Error(),
+		}
+		c.JSON(http.StatusOK, ret)
+		return 
+	}
+
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040024,"info","password of user %s has be changed.",username))
+	logErrors(errs)
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: "",
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
 /* 
 	getInfoHandler get a user information according userid or username 
 */
@@ -239,4 +431,575 @@ func (u User) getInfoHandler(c *sysadmServer.Context){
 	}
 
 	c.JSON(http.StatusOK, ret)
-}
\ No newline at end of file
+}
+
+/*
+	authorizeHandler check whether the user identified by username and password has the permission on the project.
+	it is called by registryctl for each request from the clients of the registry. 
+	permission is one of pull, push and delete. the pull on a project which is not exist is allowed, because there is
+	nothing to pull. the push on it is denied, the project should be created by a user before images are pushed to it.
+*/
+func (u User) authorizeHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	username,_ := c.GetQuery("username")
+	password,_ := c.GetQuery("password")
+	projectName,_ := c.GetQuery("project")
+	permission,_ := c.GetQuery("permission")
+	username = strings.TrimSpace(username)
+	projectName = strings.TrimSpace(projectName)
+	permission = strings.ToLower(strings.TrimSpace(permission))
+	if username == "" || password == "" || projectName == "" || permission == "" {
+		c.JSON(http.StatusOK, buildResponse(1040025,false,"username, password, project and permission should not be empty"))
+		return 
+	}
+
+	var user userApp.UserSchema
+	var ok bool
+	var e error
+	if userApp.IsApiToken(password) {
+		// the clients of the registry may use API tokens as the passwords, only the tokens with registry scope 
+		// can push or delete images
+		scope := userApp.ApiTokenScopeRegistry
+		if permission == userApp.PermissionPull {
+			scope = userApp.ApiTokenScopeRegistryPull
+		}
+		user,e = userApp.AuthenticateWithToken(username,password,scope)
+		ok = e == nil
+		if errors.Is(e,userApp.ErrApiTokenInvalid) || errors.Is(e,userApp.ErrApiTokenScope) {
+			e = userApp.ErrAuthenticationFailed
+		}
+	} else {
+		user,ok,e = userApp.Authenticate(username,password)
+	}
+	if !ok && e != nil && !errors.Is(e,userApp.ErrAuthenticationFailed) {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040026,"error","authenticate user %s error: %s",username,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040026,false,"authenticate user error"))
+		return 
+	}
+
+	if !ok || errors.Is(e,userApp.ErrPasswordExpired) {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040027,"debug","user %s can not be authorized: password incorrect or expired %v",username,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040027,false,"username or password incorrect"))
+		return 
+	}
+
+	conditions := make(map[string]string,0)
+	conditions["name"] = "=" + sysadmObjects.QuoteString(projectName)
+	conditions["deleted"] = "=0"
+	projects,e := sysadmObjects.New().List("",nil,nil,conditions,0,1,nil)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040028,"error","get project %s error: %s",projectName,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040028,false,"database query error"))
+		return 
+	}
+
+	allowed := false
+	if len(projects) < 1 {
+		allowed = permission == userApp.PermissionPull
+	} else {
+		allowed,e = userApp.HasProjectPermission(user.Id,strconv.Itoa(projects[0].ProjectID),permission)
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040029,"error","check permission of user %s on project %s error: %s",username,projectName,e))
+		}
+	}
+
+	if !allowed {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040030,"debug","user %s has not %s permission on project %s",username,permission,projectName))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040030,false,"requested access to the resource is denied"))
+		return 
+	}
+
+	logErrors(errs)
+	c.JSON(http.StatusOK, buildResponse(0,true,user.Id))
+}
+
+/*
+	isSysadmin check whether the operator of the request is an administrator of the system. the operator is the user
+	who has logged in or the owner of the API token of the request. only the administrators can manage user groups.
+	the user is read from the primary DB server, so the privileges revoked just now are not granted by a lagging replica.
+*/
+func isSysadmin(c *sysadmServer.Context) bool {
+	operatorid := operatorID(c)
+	if operatorid == "" {
+		return false
+	}
+
+	user,e := userApp.New().OnPrimary().Get(operatorid)
+	if e != nil {
+		return false
+	}
+
+	return user.SysadminFlag == 1 && user.Deleted == 0
+}
+
+/*
+	addGroupHandler add a user group with name and comment
+*/
+func (u User) addGroupHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	if !isSysadmin(c) {
+		c.JSON(http.StatusOK, buildResponse(1040031,false,"permission denied"))
+		return 
+	}
+
+	name,_ := c.GetQuery("name")
+	comment,_ := c.GetQuery("comment")
+	id,e := userApp.AddGroup(name,comment)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040032,"error","add user group %s error: %s",name,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040032,false,e.Error()))
+		return 
+	}
+
+	c.JSON(http.StatusOK, buildResponse(0,true,strconv.Itoa(int(id))))
+}
+
+/*
+	delGroupHandler delete the user group identified by groupid with its members
+*/
+func (u User) delGroupHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	if !isSysadmin(c) {
+		c.JSON(http.StatusOK, buildResponse(1040033,false,"permission denied"))
+		return 
+	}
+
+	groupid,_ := c.GetQuery("groupid")
+	if e := userApp.DeleteGroup(groupid); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040034,"error","delete user group %s error: %s",groupid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040034,false,e.Error()))
+		return 
+	}
+
+	c.JSON(http.StatusOK, buildResponse(0,true,""))
+}
+
+/*
+	listGroupsHandler list all user groups. the members of the group will be listed if groupid is provided.
+*/
+func (u User) listGroupsHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	var data interface{}
+	var e error
+
+	groupid,_ := c.GetQuery("groupid")
+	if strings.TrimSpace(groupid) != "" {
+		data,e = userApp.ListGroupMembers(groupid)
+	} else {
+		data,e = userApp.ListGroups()
+	}
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040035,"error","list user groups error: %s",e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040035,false,"database query error"))
+		return 
+	}
+
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: data,
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+/*
+	groupMemberHandler add the user identified by userid into the user group identified by groupid if add is true, 
+	otherwise remove the user from the group.
+*/
+func (u User) groupMemberHandler(c *sysadmServer.Context, add bool){
+	var errs []sysadmerror.Sysadmerror
+	if !isSysadmin(c) {
+		c.JSON(http.StatusOK, buildResponse(1040036,false,"permission denied"))
+		return 
+	}
+
+	groupid,_ := c.GetQuery("groupid")
+	userid,_ := c.GetQuery("userid")
+	var e error
+	if add {
+		e = userApp.AddGroupMember(groupid,userid)
+	} else {
+		e = userApp.RemoveGroupMember(groupid,userid)
+	}
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040037,"error","change members of user group %s error: %s",groupid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040037,false,e.Error()))
+		return 
+	}
+
+	c.JSON(http.StatusOK, buildResponse(0,true,""))
+}
+
+/*
+	isSelfOperator return the operator of the request which is taken from the session or the API token of it. 
+	false will be returned if the request carries neither or userid in the request is not the operator.
+	the users can only manage the TOTP of themselves except that the sysadmins can disable TOTP of any user.
+*/
+func isSelfOperator(c *sysadmServer.Context) (string,bool) {
+	operatorid := operatorID(c)
+	if operatorid == "" {
+		return "",false
+	}
+
+	userid,_ := c.GetQuery("userid")
+	userid = strings.TrimSpace(userid)
+
+	return operatorid, userid == "" || userid == operatorid
+}
+
+/*
+	totpEnrollHandler generate a new TOTP secret for the user and response the secret and the otpauth URI which can
+	be shown as a QR code. TOTP will not be enabled until it is confirmed by totpconfirm action with a code.
+*/
+func (u User) totpEnrollHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	userid,ok := isSelfOperator(c)
+	if !ok {
+		c.JSON(http.StatusOK, buildResponse(1040043,false,"permission denied"))
+		return 
+	}
+
+	secret,uri,e := userApp.New().BeginTotpEnrollment(userid)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040044,"error","begin totp enrollment of user %s error: %s",userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040044,false,e.Error()))
+		return 
+	}
+
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: map[string]string{"secret": secret, "uri": uri},
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+/*
+	totpConfirmHandler enable TOTP of the user after the code generated by the authenticator has be verified,
+	and response the recovery codes which are shown only once.
+*/
+func (u User) totpConfirmHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	userid,ok := isSelfOperator(c)
+	if !ok {
+		c.JSON(http.StatusOK, buildResponse(1040045,false,"permission denied"))
+		return 
+	}
+
+	code,_ := c.GetQuery("code")
+	codes,e := userApp.New().ConfirmTotpEnrollment(userid,strings.TrimSpace(code))
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040046,"debug","confirm totp enrollment of user %s error: %s",userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040046,false,e.Error()))
+		return 
+	}
+
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040047,"info","totp of user %s has be enabled",userid))
+	logErrors(errs)
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: codes,
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+/*
+	totpDisableHandler disable TOTP of the user. the user should provide a valid TOTP code or recovery code, 
+	the sysadmins can disable TOTP of any user without code, such as when the user has lost the authenticator.
+*/
+func (u User) totpDisableHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	userEntity := userApp.New()
+	// the operator is taken from the session or the API token, the administrators disable TOTP of others without code
+	operatorid := operatorID(c)
+	userid,_ := c.GetQuery("userid")
+	userid = strings.TrimSpace(userid)
+	self := operatorid != "" && userid == operatorid
+	if userid == "" || (!self && !isSysadmin(c)) {
+		c.JSON(http.StatusOK, buildResponse(1040048,false,"permission denied"))
+		return 
+	}
+
+	if self {
+		code,_ := c.GetQuery("code")
+		ok,e := userEntity.VerifyTotpLogin(userid,strings.TrimSpace(code))
+		if !ok {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040049,"debug","user %s try to disable totp, but code is incorrect: %v",userid,e))
+			logErrors(errs)
+			c.JSON(http.StatusOK, buildResponse(1040049,false,"totp code incorrect"))
+			return 
+		}
+	}
+
+	if e := userEntity.DisableTotp(userid); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040050,"error","disable totp of user %s error: %s",userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040050,false,e.Error()))
+		return 
+	}
+
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040051,"info","totp of user %s has be disabled by %s",userid,operatorid))
+	logErrors(errs)
+	c.JSON(http.StatusOK, buildResponse(0,true,""))
+}
+
+/*
+	recoveryCodesHandler regenerate the recovery codes of the user after a valid TOTP code has be verified. 
+	the old recovery codes will be invalid.
+*/
+func (u User) recoveryCodesHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	userEntity := userApp.New()
+	userid,ok := isSelfOperator(c)
+	if !ok {
+		c.JSON(http.StatusOK, buildResponse(1040052,false,"permission denied"))
+		return 
+	}
+
+	code,_ := c.GetQuery("code")
+	if ok,e := userEntity.VerifyTotpLogin(userid,strings.TrimSpace(code)); !ok {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040053,"debug","user %s try to regenerate recovery codes, but code is incorrect: %v",userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040053,false,"totp code incorrect"))
+		return 
+	}
+
+	codes,e := userEntity.RegenerateRecoveryCodes(userid)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040054,"error","regenerate recovery codes of user %s error: %s",userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040054,false,e.Error()))
+		return 
+	}
+
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: codes,
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+/*
+	canManageTokens check whether the operator of the request can manage the API tokens of the user identified by userid.
+	the users can manage their own tokens and the sysadmins can manage the tokens of service accounts. the tokens can
+	not be created by the requests authenticated by API tokens, otherwise a token may create a token with more scopes.
+	the tokens of the operator are managed if userid is not specified.
+*/
+func canManageTokens(c *sysadmServer.Context) (string,bool) {
+	operatorid,self := isSelfOperator(c)
+	if operatorid == "" {
+		return "",false
+	}
+	if self {
+		return operatorid,true
+	}
+
+	userid,_ := c.GetQuery("userid")
+	userid = strings.TrimSpace(userid)
+	if !isSysadmin(c) {
+		return userid,false
+	}
+
+	user,e := userApp.New().Get(userid)
+	if e != nil {
+		return userid,false
+	}
+
+	return userid, user.AuthSource == userApp.AuthSourceServiceAccount && user.Deleted == 0
+}
+
+/*
+	tokenCreateHandler create an API token named name for the user identified by userid. scopes are separated by comma
+	and days is the number of days the token is valid for, 0 for the longest days allowed by the settings.
+	the token is responsed only once.
+*/
+func (u User) tokenCreateHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	userid,ok := canManageTokens(c)
+	if !ok || isTokenRequest(c) {
+		c.JSON(http.StatusOK, buildResponse(1040057,false,"permission denied"))
+		return 
+	}
+
+	name,_ := c.GetQuery("name")
+	scopes,_ := c.GetQuery("scopes")
+	daysStr,_ := c.GetQuery("days")
+	days := 0
+	if strings.TrimSpace(daysStr) != "" {
+		var e error
+		days,e = strconv.Atoi(strings.TrimSpace(daysStr))
+		if e != nil {
+			c.JSON(http.StatusOK, buildResponse(1040058,false,"days should be a number"))
+			return 
+		}
+	}
+
+	token,row,e := userApp.CreateApiToken(userid,name,strings.Split(scopes,","),days)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040059,"error","create api token %s for user %s error: %s",name,userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040059,false,e.Error()))
+		return 
+	}
+
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040060,"info","api token %s(%s) has be created for user %s",row.Name,row.Prefix,userid))
+	logErrors(errs)
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: map[string]interface{}{"token": token, "info": row},
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+/*
+	tokenListHandler list the API tokens of the user identified by userid with the last used time of them.
+	the tokens themselves are not responsed because only the hashes of them are stored.
+*/
+func (u User) tokenListHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	userid,ok := canManageTokens(c)
+	if !ok {
+		c.JSON(http.StatusOK, buildResponse(1040061,false,"permission denied"))
+		return 
+	}
+
+	tokens,e := userApp.ListApiTokens(userid)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040062,"error","list api tokens of user %s error: %s",userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040062,false,"database query error"))
+		return 
+	}
+
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: tokens,
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+/*
+	tokenRevokeHandler revoke the API token identified by tokenid. the owner of the token or the sysadmins can revoke it.
+*/
+func (u User) tokenRevokeHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	tokenid,_ := c.GetQuery("tokenid")
+	operatorid := operatorID(c)
+	if operatorid == "" {
+		c.JSON(http.StatusOK, buildResponse(1040064,false,"permission denied"))
+		return 
+	}
+
+	token,e := userApp.GetApiToken(strings.TrimSpace(tokenid))
+	if e != nil {
+		c.JSON(http.StatusOK, buildResponse(1040063,false,"token not found"))
+		return 
+	}
+
+	if token.Userid != operatorid && !isSysadmin(c) {
+		c.JSON(http.StatusOK, buildResponse(1040064,false,"permission denied"))
+		return 
+	}
+
+	if e := userApp.RevokeApiToken(tokenid); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040065,"error","revoke api token %s error: %s",tokenid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040065,false,e.Error()))
+		return 
+	}
+
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040066,"info","api token %s(%s) of user %s has be revoked by %s",token.Name,token.Prefix,token.Userid,operatorid))
+	logErrors(errs)
+	c.JSON(http.StatusOK, buildResponse(0,true,""))
+}
+
+/*
+	serviceAccountAddHandler add a service account with name and comment. service accounts have not passwords,
+	they call the APIs and the registry with the API tokens created for them by the sysadmins.
+*/
+func (u User) serviceAccountAddHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	if !isSysadmin(c) {
+		c.JSON(http.StatusOK, buildResponse(1040067,false,"permission denied"))
+		return 
+	}
+
+	name,_ := c.GetQuery("name")
+	comment,_ := c.GetQuery("comment")
+	user,e := userApp.CreateServiceAccount(name,comment)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040068,"error","add service account %s error: %s",name,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040068,false,e.Error()))
+		return 
+	}
+
+	c.JSON(http.StatusOK, buildResponse(0,true,user.Id))
+}
+
+/*
+	serviceAccountListHandler list all service accounts
+*/
+func (u User) serviceAccountListHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	if !isSysadmin(c) {
+		c.JSON(http.StatusOK, buildResponse(1040069,false,"permission denied"))
+		return 
+	}
+
+	users,e := userApp.ListServiceAccounts()
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040070,"error","list service accounts error: %s",e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040070,false,"database query error"))
+		return 
+	}
+
+	var data []map[string]interface{}
+	for _,user := range users {
+		data = append(data, map[string]interface{}{"userid": user.Id, "username": user.Username, "comment": user.Comment, "creation_time": user.CreationTime})
+	}
+	ret := ApiResponseStatus {
+		Status: true,
+		Errorcode: 0,
+		Message: data,
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+/*
+	serviceAccountDelHandler delete the service account identified by userid and revoke all of its API tokens
+*/
+func (u User) serviceAccountDelHandler(c *sysadmServer.Context){
+	var errs []sysadmerror.Sysadmerror
+	if !isSysadmin(c) {
+		c.JSON(http.StatusOK, buildResponse(1040071,false,"permission denied"))
+		return 
+	}
+
+	userid,_ := c.GetQuery("userid")
+	if e := userApp.DeleteServiceAccount(strings.TrimSpace(userid)); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040072,"error","delete service account %s error: %s",userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, buildResponse(1040072,false,e.Error()))
+		return 
+	}
+
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1040073,"info","service account %s has be deleted",userid))
+	logErrors(errs)
+	c.JSON(http.StatusOK, buildResponse(0,true,""))
+}
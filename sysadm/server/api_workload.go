@@ -42,7 +42,7 @@ func addNewWorkloadHandlers(r *sysadmServer.Engine) []sysadmerror.Sysadmerror {
 
 	sysadmWorkload.SetSessionOptions(sessionOptions, sessionName)
 	pageInfo := sysadmSetting.PageInfo{
-		NumPerPage: numPerPage,
+		NumPerPage: getNumPerPage(),
 	}
 
 	sysadmWorkload.SetPageInfo(pageInfo)
@@ -0,0 +1,143 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
+	"sysadm/utils"
+)
+
+// initAuthenticators set the external authenticators of users. local users are always authenticated by
+// the passwords stored in DB, the users who are not exist in DB will be authenticated by LDAP if it has been configured
+func initAuthenticators() []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	ldapConf := RuntimeData.RuningParas.DefinedConfig.Ldap
+	if !ldapConf.Enabled {
+		userApp.SetAuthenticators()
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700170001, "info", "LDAP has not be enabled, only local users can login"))
+		return errs
+	}
+
+	var ca []byte
+	if ldapConf.Ca != "" {
+		caFile, e := utils.CheckFileIsReadable(ldapConf.Ca, RuntimeData.StartParas.SysadmRootPath)
+		if e == nil {
+			ca, e = os.ReadFile(caFile)
+		}
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(700170002, "error", "read CA file %s of LDAP error: %s, LDAP will be disabled", ldapConf.Ca, e))
+			return errs
+		}
+	}
+
+	authenticator, e := userApp.NewLdapAuthenticator(userApp.LdapConfig{
+		Url:                ldapConf.Url,
+		StartTLS:           ldapConf.StartTLS,
+		InsecureSkipVerify: ldapConf.InsecureSkipVerify,
+		Ca:                 ca,
+		BindDN:             ldapConf.BindDN,
+		BindPassword:       ldapConf.BindPassword,
+		UserBaseDN:         ldapConf.UserBaseDN,
+		UserFilter:         ldapConf.UserFilter,
+		UsernameAttr:       ldapConf.UsernameAttr,
+		EmailAttr:          ldapConf.EmailAttr,
+		RealnameAttr:       ldapConf.RealnameAttr,
+		MemberOfAttr:       ldapConf.MemberOfAttr,
+		GroupBaseDN:        ldapConf.GroupBaseDN,
+		GroupFilter:        ldapConf.GroupFilter,
+		GroupNameAttr:      ldapConf.GroupNameAttr,
+		GroupMapping:       ldapConf.GroupMapping,
+		AdminGroups:        ldapConf.AdminGroups,
+		Timeout:            ldapConf.Timeout,
+	})
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700170003, "error", "LDAP configuration is not valid: %s, LDAP will be disabled", e))
+		return errs
+	}
+
+	userApp.SetAuthenticators(authenticator)
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(700170004, "info", "users will be authenticated by LDAP %s", ldapConf.Url))
+
+	return errs
+}
+
+// authenticateBearer authenticate the API request carrying an API token in the Authorization header. the operator of
+// an API request is taken only from the session or the API token of it, so the requests which carry operatorid are
+// rejected. false will be returned after the response has been sent if the request is rejected
+func authenticateBearer(c *sysadmServer.Context) bool {
+	var errs []sysadmerror.Sysadmerror
+	if _, ok := c.GetQuery("operatorid"); ok {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700170006, "debug", "API request %s carries operatorid", c.Request.URL.Path))
+		logErrors(errs)
+		c.JSON(http.StatusBadRequest, buildResponse(700170006, false, "operatorid should not be specified, the operator is taken from the session or the api token"))
+		return false
+	}
+
+	token, ok := userApp.BearerToken(c.Request)
+	if !ok {
+		return true
+	}
+
+	user, e := userApp.AuthenticateApiToken(token, userApp.ApiTokenScopeApi)
+	if e != nil {
+		level := "error"
+		if errors.Is(e, userApp.ErrApiTokenInvalid) || errors.Is(e, userApp.ErrApiTokenScope) {
+			level = "debug"
+		}
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700170005, level, "authenticate API request with bearer token error: %s", e))
+		logErrors(errs)
+		c.Header("WWW-Authenticate", "Bearer realm=\"sysadm\"")
+		c.JSON(http.StatusUnauthorized, buildResponse(700170005, false, "api token is not valid"))
+		return false
+	}
+
+	userApp.SetApiTokenUser(c, user)
+
+	return true
+}
+
+// isTokenRequest check whether the request has been authenticated by an API token
+func isTokenRequest(c *sysadmServer.Context) bool {
+	_, ok := userApp.GetApiTokenUser(c)
+
+	return ok
+}
+
+// operatorID get the ID of the user who sends the request. it is the owner of the API token if the request has been
+// authenticated by a token, otherwise it is the user who has logged in with the session of the request. empty string
+// will be returned if the request carries neither
+func operatorID(c *sysadmServer.Context) string {
+	if user, ok := userApp.GetApiTokenUser(c); ok {
+		return user.Id
+	}
+
+	userid, e := getSessionValue(c, "userid")
+	if e != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(utils.Interface2String(userid))
+}
@@ -19,6 +19,9 @@ package server
 
 var formTemplateDir = "formstmpl/"
 var formBaseUri = "/forms/"
+var totpFormUri = "totp"
+var oidcLoginUri = "/oidc/login"
+var oidcCallbackUri = "/oidc/callback"
 var templateDelimLeft = "{{"
 var templateDelimRight = "}}"
 var mainTitle = "sysadm系统"
@@ -27,4 +30,4 @@ var sessionName = "sysadm"
 var sessionDomain = "sysadm"
 var sessionAge = 1800
 var apiVersion = "v1.0"
-var numPerPage = 5
+var defaultNumPerPage = 5
@@ -18,8 +18,6 @@
 package server
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -28,6 +26,8 @@ import (
 
 	"sysadm/httpclient"
 	"sysadm/sysadmerror"
+	"sysadm/user"
+	userApp "sysadm/user/app"
 	"github.com/wangyysde/sysadmServer"
 )
 
@@ -71,6 +71,8 @@ func addFormHandler(r *sysadmServer.Engine,cmdRunPath string) error {
 	}
 
 	addForms(r)
+	// the second step of login for the users who must verify TOTP code
+	r.POST(formBaseUri + totpFormUri, totpHandler)
 
 	return nil
 }
@@ -84,6 +86,9 @@ func addForms(r *sysadmServer.Engine){
 			"formUri": formUri,
 			"formId": "login",
 		}
+		if k == "login" && oidcProvider != nil {
+			tplData["oidcLoginUri"] = oidcLoginUri
+		}
 
 		r.GET(formUri,func(c *sysadmServer.Context) {
 			c.HTML(http.StatusOK, f.formTemplateName, tplData)
@@ -96,6 +101,22 @@ func addForms(r *sysadmServer.Engine){
 }
 
 
+// loginFormData build the data of login form with a message shown to the user
+func loginFormData(msg string) map[string]interface{} {
+	f := formsData["login"]
+	tplData := map[string] interface{}{
+		"htmlTitle": f.htmlTitle,
+		"formUri": formBaseUri + f.formUri,
+		"formId": "login",
+		"errMsg": msg,
+	}
+	if oidcProvider != nil {
+		tplData["oidcLoginUri"] = oidcLoginUri
+	}
+
+	return tplData
+}
+
 // handler for handling login form.
 func loginHandler(c *sysadmServer.Context) {
 	value,ok := c.GetPostForm("username")
@@ -106,54 +127,123 @@ func loginHandler(c *sysadmServer.Context) {
 	username := strings.TrimSpace(value);
 
 	value,ok = c.GetPostForm("password")
-	if !ok || strings.TrimSpace(value) == "" {
+	if !ok || value == "" {
 		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 101, "msg": "请输入密码！"})
 		return 
 	}
-	password := strings.TrimSpace(value)
+	password := value
 
-	okLogin,userid := loginWithDB(username,password)
+	okLogin,userid,errCode := loginWithDB(username,password)
 	if okLogin {
-		_ = setSessionValue(c,"isLogin",true)
-		_ = setSessionValue(c,"userid",userid)
+		if e := completeLogin(c,userid); e != nil {
+			c.JSON(http.StatusOK, sysadmServer.H{"errCode": 103, "msg": "登录失败，请稍后重试！"})
+			return
+		}
 		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 0, "msg": "登录成功！"})
 		return
 	}
 
+	// the password is correct, but the login is pending until the TOTP code is verified by totpHandler
+	if errCode == apiErrCodeTotpRequired || errCode == apiErrCodeTotpEnrollRequired {
+		if e := user.SetPendingLogin(c,userid); e != nil {
+			c.JSON(http.StatusOK, sysadmServer.H{"errCode": 103, "msg": "登录失败，请稍后重试！"})
+			return
+		}
+
+		if errCode == apiErrCodeTotpRequired {
+			c.JSON(http.StatusOK, sysadmServer.H{"errCode": 105, "msg": "请输入动态验证码或恢复码！", "totpUri": formBaseUri + totpFormUri})
+			return
+		}
+
+		secret,uri,e := userApp.New().BeginTotpEnrollment(userid)
+		if e != nil {
+			c.JSON(http.StatusOK, sysadmServer.H{"errCode": 103, "msg": "登录失败，请稍后重试！"})
+			return
+		}
+		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 106, "msg": "你必须启用两步验证，请使用认证器扫描二维码或输入密钥后输入动态验证码！",
+			"totpUri": formBaseUri + totpFormUri, "secret": secret, "uri": uri})
+		return
+	}
+
+	if errCode == apiErrCodePasswordExpired {
+		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 104, "msg": "密码已过期，请修改密码后重新登录！"})
+		return
+	}
+
 	c.JSON(http.StatusOK, sysadmServer.H{"errCode": 102, "msg": "用户名或密码错误！"})
 	return 
 }
 
 
-/*
-  encrypt data with md5 
-  if the length is zero ,then return ""
-  otherwise return encrypted data`
+// totpHandler handle the second step of login. the TOTP code or recovery code is verified for the user whose TOTP has
+// been enabled, and TOTP of the user who is enrolling is enabled with the code and the recovery codes are responsed.
+func totpHandler(c *sysadmServer.Context) {
+	var errs []sysadmerror.Sysadmerror
+	userid,e := user.GetPendingLogin(c)
+	if e != nil {
+		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 107, "msg": "登录已失效，请重新登录！"})
+		return
+	}
+
+	value,_ := c.GetPostForm("code")
+	code := strings.TrimSpace(value)
+	if code == "" {
+		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 108, "msg": "请输入动态验证码！"})
+		return
+	}
+
+	userEntity := userApp.New()
+	u,e := userEntity.Get(userid)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050007,"error","get user %s error: %s",userid,e))
+		logErrors(errs)
+		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 103, "msg": "登录失败，请稍后重试！"})
+		return
+	}
 
-*/
-func md5Encrypt(data string, salt string) string{
-	if len(data) < 1 {
-		return ""
+	var recoveryCodes []string
+	if u.TotpEnabled == 1 {
+		ok,e := userEntity.VerifyTotpLogin(userid,code)
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050008,"error","verify totp code of user %s error: %s",userid,e))
+		}
+		if !ok {
+			logErrors(errs)
+			c.JSON(http.StatusOK, sysadmServer.H{"errCode": 109, "msg": "动态验证码错误！"})
+			return
+		}
+	} else {
+		recoveryCodes,e = userEntity.ConfirmTotpEnrollment(userid,code)
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050009,"debug","confirm totp enrollment of user %s error: %s",userid,e))
+			logErrors(errs)
+			c.JSON(http.StatusOK, sysadmServer.H{"errCode": 109, "msg": "动态验证码错误！"})
+			return
+		}
+	}
+
+	if e := completeLogin(c,userid); e != nil {
+		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 103, "msg": "登录失败，请稍后重试！"})
+		return
 	}
-	md5Ctx := md5.New()
-	md5Ctx.Write([]byte(data))
-	if len(salt) > 0 {
-		md5Ctx.Write([]byte(salt))
+
+	logErrors(errs)
+	if len(recoveryCodes) > 0 {
+		c.JSON(http.StatusOK, sysadmServer.H{"errCode": 0, "msg": "两步验证已启用，请妥善保存以下恢复码，每个恢复码只能使用一次！", "recoveryCodes": recoveryCodes})
+		return
 	}
-	cipherStr := md5Ctx.Sum(nil)
-	encryptedData := hex.EncodeToString(cipherStr)
-	return encryptedData
+	c.JSON(http.StatusOK, sysadmServer.H{"errCode": 0, "msg": "登录成功！"})
 }
 
 // TODO: 
 // we are plan to cut user as an independent module, so user login in sysadm should call API 
-func loginWithDB(username string, password string) (bool,string) {
+func loginWithDB(username string, password string) (bool,string,int) {
 	var errs []sysadmerror.Sysadmerror
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050001,"debug","now checking the user is login"))
 	if username == "" && password == "" {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050002,"error","username and password are empty."))
 		logErrors(errs)
-		return false,""
+		return false,"",0
 	}
 
 	var reqUrl string = ""
@@ -185,7 +275,7 @@ func loginWithDB(username string, password string) (bool,string) {
 	if len(body) < 1 {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050003,"error","the response from  the server is empty"))
 		logErrors(errs)
-		return false,""
+		return false,"",0
 	}
 
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050004,"debug","got response body is: %s",string(body)))
@@ -194,15 +284,20 @@ func loginWithDB(username string, password string) (bool,string) {
 	if e != nil {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050005,"error","can not parsing reponse body to json. error: %s",e))
 		logErrors(errs)
-		return false,""
+		return false,"",0
 	}
 
 	if ret.Errorcode  != 0  {
 		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1050006,"debug","can not login with errorcode: %d message: %s",ret.Errorcode,ret.Message))
 		logErrors(errs)
-		return false,""
+		// the ID of the user is responsed if the login is waiting for the second factor
+		if ret.Errorcode == apiErrCodeTotpRequired || ret.Errorcode == apiErrCodeTotpEnrollRequired {
+			userid,_ := ret.Message.(string)
+			return false,userid,ret.Errorcode
+		}
+		return false,"",ret.Errorcode
 	}
 	
 	logErrors(errs)
-	return ret.Status,ret.Message.(string)
+	return ret.Status,ret.Message.(string),0
 }
\ No newline at end of file
@@ -0,0 +1,209 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/wangyysde/sysadmServer"
+	sessions "github.com/wangyysde/sysadmSessions"
+	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
+	"sysadm/utils"
+)
+
+// the session keys of an OpenID Connect authentication request in progress
+const (
+	oidcSessionState    = "oidcState"
+	oidcSessionNonce    = "oidcNonce"
+	oidcSessionVerifier = "oidcVerifier"
+)
+
+// oidcProvider is nil if OpenID Connect has not be enabled
+var oidcProvider *userApp.OidcProvider = nil
+
+// initOidc create the OpenID Connect provider if OpenID Connect has been enabled.
+func initOidc() []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	oidcConf := RuntimeData.RuningParas.DefinedConfig.Oidc
+	if !oidcConf.Enabled {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180001, "info", "OpenID Connect has not be enabled"))
+		return errs
+	}
+
+	var ca []byte
+	if oidcConf.Ca != "" {
+		caFile, e := utils.CheckFileIsReadable(oidcConf.Ca, RuntimeData.StartParas.SysadmRootPath)
+		if e == nil {
+			ca, e = os.ReadFile(caFile)
+		}
+		if e != nil {
+			errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180002, "error", "read CA file %s of OpenID Connect error: %s, OpenID Connect will be disabled", oidcConf.Ca, e))
+			return errs
+		}
+	}
+
+	provider, e := userApp.NewOidcProvider(userApp.OidcConfig{
+		Issuer:             oidcConf.Issuer,
+		ClientID:           oidcConf.ClientID,
+		ClientSecret:       oidcConf.ClientSecret,
+		RedirectURL:        oidcConf.RedirectURL,
+		Scopes:             oidcConf.Scopes,
+		UsernameClaim:      oidcConf.UsernameClaim,
+		EmailClaim:         oidcConf.EmailClaim,
+		RealnameClaim:      oidcConf.RealnameClaim,
+		GroupsClaim:        oidcConf.GroupsClaim,
+		GroupMapping:       oidcConf.GroupMapping,
+		AdminGroups:        oidcConf.AdminGroups,
+		InsecureSkipVerify: oidcConf.InsecureSkipVerify,
+		Ca:                 ca,
+		Timeout:            oidcConf.Timeout,
+	})
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180003, "error", "OpenID Connect configuration is not valid: %s, OpenID Connect will be disabled", e))
+		return errs
+	}
+
+	oidcProvider = provider
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180004, "info", "users can login with OpenID Connect provider %s", oidcConf.Issuer))
+
+	return errs
+}
+
+// addOidcHandler add the handlers which redirect users to the provider and handle the redirection back from the provider.
+// the path of the callback handler is the path of the redirect URL
+func addOidcHandler(r *sysadmServer.Engine) {
+	if r == nil || oidcProvider == nil {
+		return
+	}
+
+	callbackUri := oidcCallbackUri
+	if u, e := url.Parse(RuntimeData.RuningParas.DefinedConfig.Oidc.RedirectURL); e == nil && u.Path != "" {
+		callbackUri = u.Path
+	}
+
+	r.GET(oidcLoginUri, oidcLoginHandler)
+	r.GET(callbackUri, oidcCallbackHandler)
+}
+
+// oidcLoginHandler start an authorization code flow with PKCE. state, nonce and code verifier are kept in the session
+// of the user and the user is redirected to the provider.
+func oidcLoginHandler(c *sysadmServer.Context) {
+	var errs []sysadmerror.Sysadmerror
+
+	state, nonce, verifier, e := userApp.NewOidcRequest()
+	if e == nil {
+		e = setOidcSession(c, state, nonce, verifier)
+	}
+	authURL := ""
+	if e == nil {
+		authURL, e = oidcProvider.AuthCodeURL(state, nonce, verifier)
+	}
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180005, "error", "start OpenID Connect authentication error: %s", e))
+		logErrors(errs)
+		oidcLoginFailed(c, http.StatusInternalServerError, "单点登录失败，请稍后重试！")
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// oidcCallbackHandler exchange the authorization code for ID token after checking state, then bind the user
+// identified by the ID token to a local user and mark the session as login as the login form does.
+// the second factor of the users is left to the provider, so TOTP is not checked here.
+func oidcCallbackHandler(c *sysadmServer.Context) {
+	var errs []sysadmerror.Sysadmerror
+
+	state, nonce, verifier := getOidcSession(c)
+	if errCode := c.Query("error"); errCode != "" {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180006, "debug", "OpenID Connect provider responsed with error %s: %s", errCode, c.Query("error_description")))
+		logErrors(errs)
+		oidcLoginFailed(c, http.StatusUnauthorized, "单点登录失败！")
+		return
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180007, "debug", "state of OpenID Connect callback does not match the session"))
+		logErrors(errs)
+		oidcLoginFailed(c, http.StatusBadRequest, "单点登录请求已失效，请重新登录！")
+		return
+	}
+
+	identity, e := oidcProvider.Exchange(c.Query("code"), nonce, verifier)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180008, "error", "OpenID Connect authentication error: %s", e))
+		logErrors(errs)
+		oidcLoginFailed(c, http.StatusUnauthorized, "单点登录失败！")
+		return
+	}
+
+	user, e := userApp.LoginExternal(oidcProvider.Name(), identity)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180009, "error", "login user %s authenticated by OpenID Connect error: %s", identity.Username, e))
+		logErrors(errs)
+		oidcLoginFailed(c, http.StatusForbidden, "用户不能通过单点登录！")
+		return
+	}
+
+	if e := completeLogin(c, user.Id); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180010, "error", "regenerate session for user %s error: %s", user.Username, e))
+		logErrors(errs)
+		oidcLoginFailed(c, http.StatusInternalServerError, "登录失败，请稍后重试！")
+		return
+	}
+
+	errs = append(errs, sysadmerror.NewErrorWithStringLevel(700180011, "debug", "user %s(%s) login with OpenID Connect successful", user.Username, user.Id))
+	logErrors(errs)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// setOidcSession keep state, nonce and code verifier of the authentication request in the session
+func setOidcSession(c *sysadmServer.Context, state, nonce, verifier string) error {
+	session := sessions.Default(c)
+	session.Set(oidcSessionState, state)
+	session.Set(oidcSessionNonce, nonce)
+	session.Set(oidcSessionVerifier, verifier)
+
+	return session.Save()
+}
+
+// getOidcSession get state, nonce and code verifier of the authentication request from the session and delete them,
+// so a callback can be handled only once
+func getOidcSession(c *sysadmServer.Context) (state, nonce, verifier string) {
+	session := sessions.Default(c)
+	state, _ = session.Get(oidcSessionState).(string)
+	nonce, _ = session.Get(oidcSessionNonce).(string)
+	verifier, _ = session.Get(oidcSessionVerifier).(string)
+
+	session.Delete(oidcSessionState)
+	session.Delete(oidcSessionNonce)
+	session.Delete(oidcSessionVerifier)
+	_ = session.Save()
+
+	return state, nonce, verifier
+}
+
+// oidcLoginFailed show the login form with the message
+func oidcLoginFailed(c *sysadmServer.Context, status int, msg string) {
+	c.HTML(status, formsData["login"].formTemplateName, loginFormData(msg))
+}
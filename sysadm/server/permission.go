@@ -0,0 +1,110 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/db"
+	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
+	"sysadm/utils"
+)
+
+// canOperateProjects check whether the operator of the request has permission on all the projects identified by
+// projectids. false will be returned if the request has not been sent by a login user or the owner of an API token
+func canOperateProjects(c *sysadmServer.Context, projectids []string, permission string) (bool, error) {
+	operatorid := operatorID(c)
+	if operatorid == "" || len(projectids) < 1 {
+		return false, nil
+	}
+
+	for _, id := range projectids {
+		allowed, e := userApp.HasProjectPermission(operatorid, id, permission)
+		if e != nil || !allowed {
+			return false, e
+		}
+	}
+
+	return true, nil
+}
+
+// projectIDsOfImages get the IDs of the projects which the images identified by imageids belong to
+func projectIDsOfImages(imageids []string) ([]string, error) {
+	return queryParentIDs("image", "imageid", "projectid", imageids)
+}
+
+// projectIDsOfTags get the IDs of the projects which the tags identified by tagids belong to
+func projectIDsOfTags(tagids []string) ([]string, error) {
+	imageids, e := queryParentIDs("tag", "tagid", "imageid", tagids)
+	if e != nil {
+		return nil, e
+	}
+
+	return projectIDsOfImages(imageids)
+}
+
+// queryParentIDs get the distinct values of parentField of the rows in table tb which value of idField is in ids.
+// an error will be returned if any of ids is not a number or is not exist
+func queryParentIDs(tb, idField, parentField string, ids []string) ([]string, error) {
+	if len(ids) < 1 {
+		return nil, fmt.Errorf("no %s has been specified", idField)
+	}
+
+	var trimmed []string
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if _, e := strconv.ParseUint(id, 10, 64); e != nil {
+			return nil, fmt.Errorf("%s %s is not valid", idField, id)
+		}
+		trimmed = append(trimmed, id)
+	}
+
+	selectData := db.SelectData{
+		Tb:        []string{tb},
+		OutFeilds: []string{idField, parentField},
+		Where:     map[string]string{idField: " in (" + strings.Join(trimmed, ",") + ")"},
+	}
+	retData, errs := RuntimeData.RuningParas.DBConfig.Entity.QueryData(&selectData)
+	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("error") {
+		return nil, fmt.Errorf("query %s of %s error", parentField, tb)
+	}
+
+	found := make(map[string]bool, 0)
+	parents := make(map[string]bool, 0)
+	var parentIDs []string
+	for _, line := range retData {
+		found[utils.Interface2String(line[idField])] = true
+		parentID := utils.Interface2String(line[parentField])
+		if !parents[parentID] {
+			parents[parentID] = true
+			parentIDs = append(parentIDs, parentID)
+		}
+	}
+
+	for _, id := range trimmed {
+		if !found[id] {
+			return nil, fmt.Errorf("%s %s is not exist", idField, id)
+		}
+	}
+
+	return parentIDs, nil
+}
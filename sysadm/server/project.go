@@ -147,7 +147,7 @@ func projectListHandler(c *sysadmServer.Context) {
 	}else{
 		data["start"] = "0"
 	}
-	data["num"] = strconv.Itoa(numPerPage)
+	data["num"] = strconv.Itoa(getNumPerPage())
 
 	orderField, _ := c.GetQuery("orderfield")
 	order, _ := c.GetQuery("order")
@@ -170,6 +170,9 @@ func projectListHandler(c *sysadmServer.Context) {
 		data["order"] = order
 	}
 
+	// only the projects which the user can access will be listed
+	data["userid"] = userid.(string)
+	numData["userid"] = userid.(string)
 	requestParams := buildApiRequestParameters("project","list",data,nil,nil)
 	requestNumParams := buildApiRequestParameters("project","getcount",numData,nil,nil)
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(110003,"debug","try to execute the request with:%s",requestParams.Url))
@@ -293,24 +296,24 @@ func projectListHandler(c *sysadmServer.Context) {
 	htmlData += "</table>\n"
 	htmlData += "</form>\n"
 	pageStr := "<td ><div class=\"div-foot\">当前第"
-	totalPages := int(math.Ceil(float64(total) / float64(numPerPage)))
+	totalPages := int(math.Ceil(float64(total) / float64(getNumPerPage())))
 	currentPage := 1
 	startInt,_ := strconv.Atoi(start)
-	currentPage = int(math.Ceil(float64(startInt + 1) / float64(numPerPage)))
+	currentPage = int(math.Ceil(float64(startInt + 1) / float64(getNumPerPage())))
 	pageStr += strconv.Itoa(currentPage) + "页"
 	if currentPage <= 1{
 		pageStr += " 上一页 "
 	}else {
-		preNum := startInt - numPerPage
-		prePage := fmt.Sprintf("?start=%d&num=%d&%s",preNum,numPerPage,pageInfoParas)
+		preNum := startInt - getNumPerPage()
+		prePage := fmt.Sprintf("?start=%d&num=%d&%s",preNum,getNumPerPage(),pageInfoParas)
 		pageStr = pageStr + "<a href=\"javascript:void(0)\" onclick='changePage(\"" + prePage + "\")'>上一页</a>"
 	}
 
 	if currentPage >= totalPages {
 		pageStr += "下一页 "
 	}else{
-		nextNum := startInt + numPerPage
-		nextPage := fmt.Sprintf("?start=%d&num=%d&%s",nextNum,numPerPage,pageInfoParas)
+		nextNum := startInt + getNumPerPage()
+		nextPage := fmt.Sprintf("?start=%d&num=%d&%s",nextNum,getNumPerPage(),pageInfoParas)
 		pageStr = pageStr + "<a href=\"javascript:void(0)\" onclick='changePage(\"" + nextPage + "\")'>下一页</a>"
 	}
 	pageStr = pageStr + " 共" + strconv.Itoa(totalPages) + "页"
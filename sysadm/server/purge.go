@@ -0,0 +1,102 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/redis"
+	"sysadm/sysadmerror"
+	sysadmSysSetting "sysadm/syssetting/app"
+)
+
+// purgeInterval is the interval of purging the deleted objects which are out of their retention
+const purgeInterval = time.Hour
+
+// purgeLeaderKey is the key of the lock in redis which is held by the sysadm server running the purge job
+const purgeLeaderKey = "sysadm:server:purge:leader"
+
+// purgeLeaseDuration is the duration which the leadership of the purge job is kept without renewing
+const purgeLeaseDuration = 30 * time.Second
+
+// startPurgeJob start the job which purge the deleted objects of the modules registered to objects package.
+// the sysadm servers sharing a redis elect a leader, and the job runs on the leader only. a sysadm server without
+// redis is the only one, so it runs the job itself. the job will be stopped when stopCh is closed
+func startPurgeJob(stopCh <-chan struct{}) []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	report := func(objectName string, num int, e error) {
+		var reportErrs []sysadmerror.Sysadmerror
+		if e != nil {
+			reportErrs = append(reportErrs, sysadmerror.NewErrorWithStringLevel(700120002, "error", "purge deleted %s error: %s", objectName, e))
+		} else if num > 0 {
+			reportErrs = append(reportErrs, sysadmerror.NewErrorWithStringLevel(700120003, "info", "%d deleted %s have been purged", num, objectName))
+		}
+		logErrors(reportErrs)
+	}
+
+	run := func(stop <-chan struct{}) {
+		if e := sysadmObjects.StartPurgeJob(purgeInterval, sysadmSysSetting.New().GetRetention, report, stop); e != nil {
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700120001, "error", "start purge job error: %s", e)})
+		}
+	}
+
+	entity := RuntimeData.RuningParas.RedisEntity
+	if entity == nil {
+		run(stopCh)
+		return errs
+	}
+
+	hostname, e := os.Hostname()
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700120004, "error", "get hostname for the election of purge job error: %s", e))
+		return errs
+	}
+	identity := fmt.Sprintf("%s:%d:%d", hostname, RuntimeData.RuningParas.DefinedConfig.Server.Port, os.Getpid())
+
+	conf := redis.LeaderElectionConfig{
+		Key:      purgeLeaderKey,
+		Identity: identity,
+		TTL:      purgeLeaseDuration,
+		OnStartedLeading: func(ctx context.Context) {
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700120005, "info", "sysadm server %s becomes the leader, starting purge job", identity)})
+			run(ctx.Done())
+		},
+		OnStoppedLeading: func() {
+			logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700120006, "warning", "sysadm server %s is not the leader of purge job any more", identity)})
+		},
+	}
+	elector, e := redis.NewLeaderElector(entity, conf)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700120007, "error", "create elector for purge job error: %s", e))
+		return errs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	go elector.Run(ctx)
+
+	return errs
+}
@@ -0,0 +1,71 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	sysadmConfig "sysadm/config"
+	"sysadm/redis"
+	"sysadm/sysadmerror"
+)
+
+// initRedis open a connection to redis server if redis has been configured.
+// redis is optional, the features depending on it will fall back to DB if redis is not available
+func initRedis() []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	redisConf := RuntimeData.RuningParas.DefinedConfig.Redis
+	if redisConf.Addrs == "" {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700150001, "info", "redis has not be configured, command queue and server side sessions will be disabled"))
+		return errs
+	}
+
+	clientConf := redis.ClientConf{
+		Mode:             redisConf.Mode,
+		Master:           redisConf.Master,
+		Addrs:            redisConf.Addrs,
+		Username:         redisConf.Username,
+		Password:         redisConf.Password,
+		SentinelUsername: redisConf.SentinelUsername,
+		SentinelPassword: redisConf.SentinelPassword,
+		DB:               redisConf.DB,
+		Tls: sysadmConfig.Tls{
+			IsTls: redisConf.Tls,
+			Ca:    redisConf.Ca,
+			Cert:  redisConf.Cert,
+			Key:   redisConf.Key,
+		},
+	}
+
+	entity, e := redis.NewClient(clientConf, RuntimeData.StartParas.SysadmRootPath)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700150002, "error", "can not open connection to redis server %s: %s, command queue and server side sessions will be disabled", redisConf.Addrs, e))
+		return errs
+	}
+
+	RuntimeData.RuningParas.RedisEntity = entity
+
+	return errs
+}
+
+// closeRedis close the connection to redis server
+func closeRedis() {
+	if RuntimeData.RuningParas.RedisEntity != nil {
+		_ = RuntimeData.RuningParas.RedisEntity.Close()
+		RuntimeData.RuningParas.RedisEntity = nil
+	}
+}
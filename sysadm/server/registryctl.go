@@ -33,6 +33,7 @@ import (
 	"sysadm/httpclient"
 	"sysadm/sysadmapi/apiutils"
 	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
 	"sysadm/utils"
 	"github.com/wangyysde/sysadmServer"
 )
@@ -118,6 +119,23 @@ func imageListHandler(c *sysadmServer.Context) {
 		return 
 	}
 
+	// the user can only view the images in the projects which he can access
+	accessibleIDs,allProjects,e := userApp.AccessibleProjectIDs(userid.(string))
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1101024,"error","get projects which the user can access error %s",e))
+		logErrors(errs)
+		tplData := map[string] interface{}{
+			"errormessage": "get projects which the user can access error",
+		}
+		templateFile = "showmessage.html"
+		c.HTML(http.StatusOK,templateFile, tplData)
+		return 
+	}
+	accessible := make(map[string]bool,0)
+	for _,id := range accessibleIDs {
+		accessible[id] = true
+	}
+
 	// get project for select menu
 	moduleName := "project"
 	actionName := "list"
@@ -185,6 +203,9 @@ func imageListHandler(c *sysadmServer.Context) {
 		lineMap := make(map[string]string,0)
 		id := utils.Interface2String(line["projectid"])
 		name := utils.Interface2String(line["name"])
+		if !allProjects && !accessible[id] {
+			continue
+		}
 		lineMap[id] = name
 		projectInfo = append(projectInfo,lineMap)
 	}
@@ -239,7 +260,27 @@ func imageListHandler(c *sysadmServer.Context) {
 	requestParas.Method = http.MethodPost
 	
 	projectid,ok := queryData["projectid"]
+	if ok && projectid != "0" && !allProjects && !accessible[projectid] {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1101025,"debug","user %s can not access project %s",userid,projectid))
+		logErrors(errs)
+		tplData := map[string] interface{}{
+			"errormessage": "permission denied",
+		}
+		templateFile = "showmessage.html"
+		c.HTML(http.StatusOK,templateFile, tplData)
+		return 
+	}
 	pageInfoParas := ""
+	if (!ok || projectid == "0") && !allProjects {
+		// all projects which the user can access. no image will be listed if the user can not access any project
+		projectFilter := "0"
+		if len(accessibleIDs) > 0 {
+			projectFilter = strings.Join(accessibleIDs,",")
+		}
+		requestParasPr,err := httpclient.AddQueryData(&requestParas,"projectid",projectFilter)
+		requestParas = *requestParasPr
+		errs=append(errs,err...)
+	}
 	if ok && projectid != "0" {
 		requestParasPr,err := httpclient.AddQueryData(&requestParas,"projectid",projectid)
 		requestParas = *requestParasPr
@@ -294,17 +335,17 @@ func imageListHandler(c *sysadmServer.Context) {
 	if num < 1 {
 		num = 0
 	}
-	totalPages := int(math.Ceil(float64(num) / float64(numPerPage)))
+	totalPages := int(math.Ceil(float64(num) / float64(getNumPerPage())))
 	currentPage := 1
-	currentPage = int(math.Ceil(float64(start + 1) / float64(numPerPage)))
+	currentPage = int(math.Ceil(float64(start + 1) / float64(getNumPerPage())))
 	currentPageHTML := strconv.Itoa(currentPage)
 	totalPageHTML := strconv.Itoa(totalPages)
 	prePageHTML := ""
 	if currentPage <= 1{
 		prePageHTML = " 上一页 "
 	}else {
-		preNum := start - numPerPage
-		prePage := fmt.Sprintf("?start=%d&numPerPage=%d&%s",preNum,numPerPage,pageInfoParas)
+		preNum := start - getNumPerPage()
+		prePage := fmt.Sprintf("?start=%d&numPerPage=%d&%s",preNum,getNumPerPage(),pageInfoParas)
 		prePageHTML = "<a href=\"javascript:void(0)\" onclick='changePage(\"" + prePage + "\")'>上一页</a>"
 	}
 
@@ -312,8 +353,8 @@ func imageListHandler(c *sysadmServer.Context) {
 	if currentPage >= totalPages{
 		nextPageHTML = "下一页 "
 	}else{
-		nextNum := start + numPerPage
-		nextPage := fmt.Sprintf("?start=%d&num=%d&%s",nextNum,numPerPage,pageInfoParas)
+		nextNum := start + getNumPerPage()
+		nextPage := fmt.Sprintf("?start=%d&num=%d&%s",nextNum,getNumPerPage(),pageInfoParas)
 		nextPageHTML = "<a href=\"javascript:void(0)\" onclick='changePage(\"" + nextPage + "\")'>下一页</a>"
 	}
 	
@@ -354,7 +395,7 @@ func imageListHandler(c *sysadmServer.Context) {
 	requestParasPr,err := httpclient.AddQueryData(&requestParas,"start",startStr)
 	requestParas = *requestParasPr
 	errs=append(errs,err...)
-	requestParasPr,err = httpclient.AddQueryData(&requestParas,"numperpage",strconv.Itoa(numPerPage))
+	requestParasPr,err = httpclient.AddQueryData(&requestParas,"numperpage",strconv.Itoa(getNumPerPage()))
 	requestParas = *requestParasPr
 	errs=append(errs,err...)
 	
@@ -515,6 +556,18 @@ func tagListHandler(c *sysadmServer.Context) {
 	message := ret.Message
 	imageLine := message[0]	
 	imageName := utils.Interface2String(imageLine["name"])
+	imageProjectID := utils.Interface2String(imageLine["projectid"])
+	allowed,e := userApp.HasProjectPermission(userid.(string),imageProjectID,userApp.PermissionPull)
+	if !allowed {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(1101026,"debug","user %s can not access project %s %v",userid,imageProjectID,e))
+		logErrors(errs)
+		tplData := map[string] interface{}{
+			"errormessage": "permission denied",
+		}
+		templateFile = "showmessage.html"
+		c.HTML(http.StatusOK,templateFile, tplData)
+		return 
+	}
 	
 	moduleName = "registryctl"
 	actionName = "taglist"
@@ -816,17 +869,17 @@ func yumListHandler(c *sysadmServer.Context) {
 	if num < 1 {
 		num = 0
 	}
-	totalPages := int(math.Ceil(float64(num) / float64(numPerPage)))
+	totalPages := int(math.Ceil(float64(num) / float64(getNumPerPage())))
 	currentPage := 1
-	currentPage = int(math.Ceil(float64(start + 1) / float64(numPerPage)))
+	currentPage = int(math.Ceil(float64(start + 1) / float64(getNumPerPage())))
 	currentPageHTML := strconv.Itoa(currentPage)
 	totalPageHTML := strconv.Itoa(totalPages)
 	prePageHTML := ""
 	if currentPage <= 1{
 		prePageHTML = " 上一页 "
 	}else {
-		preNum := start - numPerPage
-		prePage := fmt.Sprintf("?start=%d&numPerPage=%d&%s",preNum,numPerPage,pageInfoParas)
+		preNum := start - getNumPerPage()
+		prePage := fmt.Sprintf("?start=%d&numPerPage=%d&%s",preNum,getNumPerPage(),pageInfoParas)
 		prePageHTML = "<a href=\"javascript:void(0)\" onclick='changePage(\"" + prePage + "\")'>上一页</a>"
 	}
 
@@ -834,8 +887,8 @@ func yumListHandler(c *sysadmServer.Context) {
 	if currentPage >= totalPages{
 		nextPageHTML = "下一页 "
 	}else{
-		nextNum := start + numPerPage
-		nextPage := fmt.Sprintf("?start=%d&num=%d&%s",nextNum,numPerPage,pageInfoParas)
+		nextNum := start + getNumPerPage()
+		nextPage := fmt.Sprintf("?start=%d&num=%d&%s",nextNum,getNumPerPage(),pageInfoParas)
 		nextPageHTML = "<a href=\"javascript:void(0)\" onclick='changePage(\"" + nextPage + "\")'>下一页</a>"
 	}
 	tplData["currentpage"] = currentPageHTML
@@ -851,7 +904,7 @@ func yumListHandler(c *sysadmServer.Context) {
 	requestParasPr,err := httpclient.AddQueryData(&requestParas,"start",startStr)
 	requestParas = *requestParasPr
 	errs=append(errs,err...)
-	requestParasPr,err = httpclient.AddQueryData(&requestParas,"numperpage",strconv.Itoa(numPerPage))
+	requestParasPr,err = httpclient.AddQueryData(&requestParas,"numperpage",strconv.Itoa(getNumPerPage()))
 	requestParas = *requestParasPr
 	errs=append(errs,err...)
 
@@ -0,0 +1,106 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"os"
+
+	"github.com/wangyysde/sysadmServer"
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/sysadm/config"
+	"sysadm/sysadmerror"
+	sysadmSysSetting "sysadm/syssetting/app"
+)
+
+// loadMasterKeys load the master keys which the secret fields of objects are encrypted with
+func loadMasterKeys() []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	loaded, e := sysadmObjects.LoadMasterKeys(RuntimeData.StartParas.SysadmRootPath)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700140001, "fatal", "load master keys error: %s", e))
+		return errs
+	}
+
+	if !loaded {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700140002, "warning", "master key was not found, secrets will be stored in clear text"))
+	}
+
+	return errs
+}
+
+// RotateSecrets re-encrypt the secrets which have been stored in DB with the current master key.
+// the old master keys should be kept in the master key file or environment variable until the rotation is finished
+func RotateSecrets(cmdPath string) {
+	definedConfig, e := config.HandleConfig(CliData.ConfigPath, cmdPath)
+	if e != nil {
+		sysadmServer.Logf("error", "error:%s", e)
+		os.Exit(1)
+	}
+	RuntimeData.RuningParas.DefinedConfig = definedConfig
+
+	if _, e = getSysadmRootPath(cmdPath); e != nil {
+		sysadmServer.Logf("error", "error:%s", e)
+		os.Exit(2)
+	}
+
+	setLogger()
+	defer closeLogger()
+	setSysadmLogger()
+
+	dbConfig, errs := buildDBConfig(definedConfig, cmdPath)
+	logErrors(errs)
+	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("fatal") {
+		os.Exit(3)
+	}
+	RuntimeData.RuningParas.DBConfig = dbConfig
+
+	dbEntity := dbConfig.Entity
+	errs = dbEntity.OpenDbConnect()
+	logErrors(errs)
+	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("fatal") {
+		os.Exit(4)
+	}
+	defer dbEntity.CloseDB()
+
+	errs = loadMasterKeys()
+	logErrors(errs)
+	if !sysadmObjects.SecretEnabled() {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700140003, "error", "secrets can not be rotated without master key")})
+		return
+	}
+
+	if e := sysadmSysSetting.SetRunData(dbConfig, RuntimeData.sysadmLogEntity, RuntimeData.StartParas.SysadmRootPath); e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700140004, "error", "set run data error: %s", e)})
+		return
+	}
+
+	report := func(objectName string, num int, e error) {
+		var reportErrs []sysadmerror.Sysadmerror
+		if e != nil {
+			reportErrs = append(reportErrs, sysadmerror.NewErrorWithStringLevel(700140005, "error", "rotate secrets of %s error: %s", objectName, e))
+		} else {
+			reportErrs = append(reportErrs, sysadmerror.NewErrorWithStringLevel(700140006, "info", "secrets of %d %s have been re-encrypted", num, objectName))
+		}
+		logErrors(reportErrs)
+	}
+
+	if e := sysadmObjects.RotateAllSecrets(report); e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700140007, "error", "rotate secrets error: %s", e)})
+	}
+}
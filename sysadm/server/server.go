@@ -31,6 +31,7 @@ import (
 	sysadmDB "sysadm/db"
 	"sysadm/sysadm/config"
 	"sysadm/sysadmerror"
+	userApp "sysadm/user/app"
 )
 
 var err error
@@ -76,6 +77,24 @@ func DaemonStart(cmd *cobra.Command, cmdPath string) {
 
 	defer dbEntity.CloseDB()
 
+	// the IDs of objects are allocated from the sequences in ids table, which need the primary key of the table
+	if err = sysadmDB.UpgradeSequenceTable(dbEntity); err != nil {
+		sysadmServer.Logf("error", "error:%s", err)
+		os.Exit(24)
+	}
+
+	// 加载主密钥，对象中的敏感字段使用主密钥加密存储
+	errs = loadMasterKeys()
+	logErrors(errs)
+	if sysadmerror.GetMaxLevel(errs) >= sysadmerror.GetLevelNum("fatal") {
+		os.Exit(21)
+	}
+
+	// 连接redis，redis不可用时依赖于redis的功能使用数据库
+	errs = initRedis()
+	logErrors(errs)
+	defer closeRedis()
+
 	// newing an instance of sysadmServer
 	r := sysadmServer.New()
 	r.Use(sysadmServer.Logger(), sysadmServer.Recovery())
@@ -99,12 +118,30 @@ func DaemonStart(cmd *cobra.Command, cmdPath string) {
 		os.Exit(5)
 	}
 
+	// the login form shows the entry of single sign-on if OpenID Connect has been enabled
+	errs = initOidc()
+	logErrors(errs)
+	addOidcHandler(r)
+
 	// adding all handlers
 	err = addFormHandler(r, cmdPath)
 	if err != nil {
 		sysadmServer.Logf("error", "error:%s", err)
 		os.Exit(6)
 	}
+
+	// 用户登录时校验和升级用户密码需要访问数据库
+	if err = userApp.SetRunData(RuntimeData.RuningParas.DBConfig, RuntimeData.sysadmLogEntity, RuntimeData.StartParas.SysadmRootPath); err != nil {
+		sysadmServer.Logf("error", "error:%s", err)
+		os.Exit(22)
+	}
+	// 旧表结构的password字段保存不下新加密算法加密的密码，升级后再允许用户登录
+	if err = userApp.UpgradePasswordSchema(); err != nil {
+		sysadmServer.Logf("error", "error:%s", err)
+		os.Exit(23)
+	}
+	errs = initAuthenticators()
+	logErrors(errs)
 	addApiHandler(r, cmdPath)
 
 	// adding project handlers
@@ -184,6 +221,18 @@ func DaemonStart(cmd *cobra.Command, cmdPath string) {
 		os.Exit(14)
 	}
 
+	// 侦听配置项的变化，使配置项修改后不需要重启即可生效
+	settingStopCh := make(chan struct{})
+	defer close(settingStopCh)
+	errs = startSettingWatcher(settingStopCh)
+	logErrors(errs)
+
+	// 启动已删除对象的清理任务
+	purgeStopCh := make(chan struct{})
+	defer close(purgeStopCh)
+	errs = startPurgeJob(purgeStopCh)
+	logErrors(errs)
+
 	// adding Root handlers
 	err = addRootHandler(r, cmdPath)
 	if err != nil {
@@ -346,21 +395,37 @@ func buildDBConfig(definedConfig *config.Config, cmdPath string) (*sysadmDB.DbCo
 	var errs []sysadmerror.Sysadmerror
 	errs = append(errs, sysadmerror.NewErrorWithStringLevel(100001, "debug", "try to build DB configurations"))
 	dbConf := sysadmDB.DbConfig{
-		Type:         definedConfig.DB.Type,
-		Host:         definedConfig.DB.Host,
-		Port:         definedConfig.DB.Port,
-		User:         definedConfig.DB.User,
-		Password:     definedConfig.DB.Password,
-		DbName:       definedConfig.DB.Dbname,
-		SslMode:      definedConfig.DB.Sslmode,
-		SslCa:        definedConfig.DB.Sslrootcert,
-		SslCert:      definedConfig.DB.Sslcert,
-		SslKey:       definedConfig.DB.Sslkey,
-		MaxOpenConns: definedConfig.DB.DbMaxConnect,
-		MaxIdleConns: definedConfig.DB.DbIdleConnect,
-		RunModeDebug: sysadmServer.IsDebugging(),
-	}
-	dbConfig, errs := sysadmDB.InitDbConfig(&dbConf, cmdPath)
+		Type:                definedConfig.DB.Type,
+		Host:                definedConfig.DB.Host,
+		Port:                definedConfig.DB.Port,
+		User:                definedConfig.DB.User,
+		Password:            definedConfig.DB.Password,
+		DbName:              definedConfig.DB.Dbname,
+		SslMode:             definedConfig.DB.Sslmode,
+		SslCa:               definedConfig.DB.Sslrootcert,
+		SslCert:             definedConfig.DB.Sslcert,
+		SslKey:              definedConfig.DB.Sslkey,
+		MaxOpenConns:        definedConfig.DB.DbMaxConnect,
+		MaxIdleConns:        definedConfig.DB.DbIdleConnect,
+		RunModeDebug:        sysadmServer.IsDebugging(),
+		HealthCheckInterval: definedConfig.DB.HealthCheckInterval,
+	}
+
+	replicas, e := sysadmDB.ParseEndpoints(definedConfig.DB.Replicas, definedConfig.DB.Port)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(100019, "fatal", "replicas of DB is not valid: %s", e))
+		return &dbConf, errs
+	}
+	standbys, e := sysadmDB.ParseEndpoints(definedConfig.DB.Standbys, definedConfig.DB.Port)
+	if e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(100020, "fatal", "standbys of DB is not valid: %s", e))
+		return &dbConf, errs
+	}
+	dbConf.Replicas = replicas
+	dbConf.Standbys = standbys
+
+	dbConfig, err := sysadmDB.InitDbConfig(&dbConf, cmdPath)
+	errs = append(errs, err...)
 
 	return dbConfig, errs
 }
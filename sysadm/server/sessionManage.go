@@ -0,0 +1,124 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wangyysde/sysadmServer"
+	"sysadm/sysadm/config"
+	"sysadm/sysadmerror"
+	"sysadm/user"
+)
+
+// ListSessions print the active sessions of the user
+func ListSessions(cmdPath string, userid string) {
+	store := openSessionStore(cmdPath)
+	defer closeLogger()
+	defer closeRedis()
+
+	userid = strings.TrimSpace(userid)
+	if userid == "" {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700160001, "error", "the ID of the user should be specified")})
+		return
+	}
+
+	infos, e := store.ListUserSessions(context.Background(), userid)
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700160002, "error", "list sessions of user %s error: %s", userid, e)})
+		return
+	}
+
+	for _, info := range infos {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", maskSessionID(info.ID), info.IP, info.Created.Format(time.RFC3339), info.LastAccess.Format(time.RFC3339), info.UserAgent)
+	}
+}
+
+// LogoutSessions force the user or all users if all is true to logout by destroying their sessions
+func LogoutSessions(cmdPath string, userid string, all bool) {
+	store := openSessionStore(cmdPath)
+	defer closeLogger()
+	defer closeRedis()
+
+	var num int
+	var e error
+	userid = strings.TrimSpace(userid)
+	switch {
+	case all:
+		num, e = store.DestroyAllSessions(context.Background())
+	case userid != "":
+		num, e = store.DestroyUserSessions(context.Background(), userid)
+	default:
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700160003, "error", "either the ID of the user or all should be specified")})
+		return
+	}
+
+	if e != nil {
+		logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700160004, "error", "destroy sessions error: %s", e)})
+		return
+	}
+
+	logErrors([]sysadmerror.Sysadmerror{sysadmerror.NewErrorWithStringLevel(700160005, "info", "%d sessions have been destroyed", num)})
+}
+
+// openSessionStore connect to redis and create the session store on it. the program will exit if sessions are not
+// stored in redis
+func openSessionStore(cmdPath string) *user.RedisStore {
+	definedConfig, e := config.HandleConfig(CliData.ConfigPath, cmdPath)
+	if e != nil {
+		sysadmServer.Logf("error", "error:%s", e)
+		os.Exit(1)
+	}
+	RuntimeData.RuningParas.DefinedConfig = definedConfig
+
+	if _, e = getSysadmRootPath(cmdPath); e != nil {
+		sysadmServer.Logf("error", "error:%s", e)
+		os.Exit(2)
+	}
+
+	setLogger()
+	setSysadmLogger()
+
+	errs := initRedis()
+	logErrors(errs)
+	if RuntimeData.RuningParas.RedisEntity == nil {
+		sysadmServer.Logf("error", "sessions are not stored in redis")
+		os.Exit(3)
+	}
+
+	store, e := user.NewRedisStore(RuntimeData.RuningParas.RedisEntity, sessionOptions, time.Duration(sessionAge)*time.Second, user.DefaultSessionAbsoluteTimeout)
+	if e != nil {
+		sysadmServer.Logf("error", "error:%s", e)
+		os.Exit(4)
+	}
+
+	return store
+}
+
+// maskSessionID hide the most part of session ID, so it can not be used by others who can see the output
+func maskSessionID(id string) string {
+	if len(id) <= 8 {
+		return id
+	}
+
+	return id[:8] + "..."
+}
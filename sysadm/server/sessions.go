@@ -26,9 +26,12 @@
 	sessions "github.com/wangyysde/sysadmSessions"
   	"github.com/wangyysde/sysadmSessions/cookie"
 	"github.com/wangyysde/sysadmServer"
-
+	"sysadm/user"
  )
 
+ // the store of sessions. it is nil if sessions are stored in cookies
+ var redisSessionStore *user.RedisStore = nil
+
  var sessionOptions = sessions.Options{
 	  Path: sessionPath,
 	  Domain: sessionDomain,
@@ -44,11 +47,33 @@
 		return fmt.Errorf("router is nil.")
 	}
 
+	// sessions are stored in redis if it is available, so they can be shared among replicas and revoked on server side
+	if RuntimeData.RuningParas.RedisEntity != nil {
+		store, e := user.NewRedisStore(RuntimeData.RuningParas.RedisEntity, sessionOptions, time.Duration(sessionAge) * time.Second, user.DefaultSessionAbsoluteTimeout)
+		if e != nil {
+			return e
+		}
+		redisSessionStore = store
+		r.Use(sessions.Sessions(sessionName, store))
+		return nil
+	}
+
 	store := cookie.NewStore([]byte("secret"))
   	r.Use(sessions.Sessions(sessionName, store))
 	return nil
  }
 
+// completeLogin mark the session as login by the user after all the factors of the user have been verified.
+// a new session ID is issued on login to prevent session fixation
+func completeLogin(c *sysadmServer.Context, userid string) error {
+	if e := user.CompleteLogin(c, userid); e != nil {
+		return e
+	}
+	refreshSession(c)
+
+	return nil
+}
+
 func refreshSession(c *sysadmServer.Context){
 	cc,_ :=c.Request.Cookie(sessionName)
 	if cc != nil{
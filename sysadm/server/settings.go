@@ -0,0 +1,105 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package server
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	sysadmAZ "sysadm/availablezone/app"
+	sysadmCommand "sysadm/command/app"
+	sysadmDC "sysadm/datacenter/app"
+	infrastructureApp "sysadm/infrastructure/app"
+	k8scluster "sysadm/k8scluster/app"
+	sysadmOS "sysadm/os/app"
+	"sysadm/sysadmerror"
+	sysadmSysSetting "sysadm/syssetting/app"
+	sysadmWorkload "sysadm/workload/app"
+)
+
+// settingWatchInterval is the interval of polling the changes of settings
+const settingWatchInterval = 10 * time.Second
+
+// numPerPage is the number of items per page. it is changed by the setting watcher while the handlers are reading it,
+// so it must be accessed by getNumPerPage and setNumPerPage
+var numPerPage atomic.Int64
+
+// getNumPerPage return the number of items per page
+func getNumPerPage() int {
+	if num := numPerPage.Load(); num > 0 {
+		return int(num)
+	}
+
+	return defaultNumPerPage
+}
+
+// startSettingWatcher apply the current values of the settings which can be reloaded, and start watching the changes
+// of them. the watcher will be stopped when stopCh is closed
+func startSettingWatcher(stopCh <-chan struct{}) []sysadmerror.Sysadmerror {
+	var errs []sysadmerror.Sysadmerror
+
+	num, _, e := sysadmSysSetting.New().ResolveInt(sysadmSysSetting.SettingKeyForNumPerPage, sysadmSysSetting.SettingContext{})
+	switch {
+	case e == nil:
+		setNumPerPage(num)
+	case !sysadmSysSetting.IsSettingNotSet(e):
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700130001, "warning", "get number of items per page error: %s", e))
+	}
+
+	sysadmSysSetting.Subscribe(sysadmSysSetting.SettingKeyForNumPerPage, func(change sysadmSysSetting.SettingChange) {
+		var changeErrs []sysadmerror.Sysadmerror
+		if change.Scope != sysadmSysSetting.SettingScopeGlobal {
+			return
+		}
+
+		num, e := strconv.Atoi(change.Value)
+		if e != nil || num < 1 {
+			changeErrs = append(changeErrs, sysadmerror.NewErrorWithStringLevel(700130002, "warning", "number of items per page %s is not valid", change.Value))
+			logErrors(changeErrs)
+			return
+		}
+
+		setNumPerPage(num)
+		changeErrs = append(changeErrs, sysadmerror.NewErrorWithStringLevel(700130003, "info", "number of items per page has been changed to %d", num))
+		logErrors(changeErrs)
+	})
+
+	if e := sysadmSysSetting.StartWatcher(settingWatchInterval, stopCh); e != nil {
+		errs = append(errs, sysadmerror.NewErrorWithStringLevel(700130004, "error", "start setting watcher error: %s", e))
+	}
+
+	return errs
+}
+
+// setNumPerPage set the number of items per page to num for all modules
+func setNumPerPage(num int) {
+	numPerPage.Store(int64(num))
+	pageInfo := sysadmSysSetting.PageInfo{
+		NumPerPage: num,
+	}
+
+	sysadmDC.SetPageInfo(pageInfo)
+	sysadmAZ.SetPageInfo(pageInfo)
+	k8scluster.SetPageInfo(pageInfo)
+	sysadmCommand.SetPageInfo(pageInfo)
+	sysadmOS.SetPageInfo(pageInfo)
+	sysadmWorkload.SetPageInfo(pageInfo)
+	sysadmSysSetting.SetPageInfo(pageInfo)
+	infrastructureApp.SetPageInfo(num)
+}
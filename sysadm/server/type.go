@@ -22,6 +22,7 @@ import (
 
 	"github.com/wangyysde/sysadmServer"
 	sysadmDB "sysadm/db"
+	"sysadm/redis"
 	"sysadm/sysadm/config"
 	"sysadm/sysadmLog"
 )
@@ -43,6 +44,8 @@ type RunningParas struct {
 	DefinedConfig *config.Config
 	// the DB configurations what have been parsed. these configurations come from environment, configure file or default value
 	DBConfig *sysadmDB.DbConfig
+	// the entity of redis. it is nil if redis has not be configured or can not be connected
+	RedisEntity redis.RedisEntity
 }
 
 type RuningData struct {
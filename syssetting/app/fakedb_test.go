@@ -0,0 +1,331 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/utils"
+)
+
+// fakeDriverName is the name of the database/sql driver which accepts every statement. the statements of transactions
+// are applied to the tables of fakeEntity when they are built, so the driver only has to let them pass
+const fakeDriverName = "syssettingfake"
+
+func init() {
+	sql.Register(fakeDriverName, fakeDriver{})
+}
+
+// fakeEntity is an in-memory DB entity which supports the queries built by this package
+type fakeEntity struct {
+	sysadmDB.DbEntity
+	config *sysadmDB.DbConfig
+	lock   sync.Mutex
+	tables map[string][]map[string]interface{}
+	// updates is the number of rows which have been updated
+	updates int
+	// deletes is the delete statements which have been built
+	deletes []string
+}
+
+// newFakeDB set an in-memory DB as the DB of this package and objects package, and restore the DB after the test
+func newFakeDB(t *testing.T) *fakeEntity {
+	t.Helper()
+	db, e := sql.Open(fakeDriverName, "")
+	if e != nil {
+		t.Fatalf("open fake DB error: %s", e)
+	}
+
+	entity := &fakeEntity{tables: make(map[string][]map[string]interface{}, 0)}
+	entity.config = &sysadmDB.DbConfig{Connect: db, Entity: entity}
+	old, oldObjects := runData.dbConf, sysadmObjects.GetRunDataForDBConf()
+	runData.dbConf = entity.config
+	_ = sysadmObjects.SetRunDataForDBConf(entity.config)
+	t.Cleanup(func() {
+		runData.dbConf = old
+		if oldObjects != nil {
+			_ = sysadmObjects.SetRunDataForDBConf(oldObjects)
+		}
+		_ = db.Close()
+	})
+
+	return entity
+}
+
+// insert add rows into table tb
+func (f *fakeEntity) insert(tb string, rows ...map[string]interface{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.tables[tb] = append(f.tables[tb], rows...)
+}
+
+// row return the row of table tb whose field is value
+func (f *fakeEntity) row(tb, field, value string) map[string]interface{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, row := range f.tables[tb] {
+		if utils.Interface2String(row[field]) == value {
+			return row
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeEntity) GetDbConfig() *sysadmDB.DbConfig {
+	return f.config
+}
+
+func (f *fakeEntity) NewQueryData(sd *sysadmDB.SelectData) ([]map[string]interface{}, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(sd.Tb) != 1 {
+		return nil, fmt.Errorf("only one table is supported")
+	}
+
+	var rows []map[string]interface{}
+	for _, row := range f.tables[sd.Tb[0]] {
+		if matchWhere(row, sd.Where) {
+			rows = append(rows, row)
+		}
+	}
+
+	if len(sd.OutFeilds) == 1 && strings.HasPrefix(sd.OutFeilds[0], "count(") {
+		return []map[string]interface{}{{"num": len(rows)}}, nil
+	}
+
+	for _, order := range sd.Order {
+		key, desc := order.Key, order.Order == 1
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := utils.Interface2String(rows[i][key]), utils.Interface2String(rows[j][key])
+			if len(a) != len(b) {
+				return (len(a) < len(b)) != desc
+			}
+			return (a < b) != desc
+		})
+	}
+
+	start, step := 0, len(rows)
+	switch len(sd.Limit) {
+	case 1:
+		step = sd.Limit[0]
+	case 2:
+		start, step = sd.Limit[0], sd.Limit[1]
+	}
+	var ret []map[string]interface{}
+	for i := start; i < len(rows) && i < start+step; i++ {
+		line := make(map[string]interface{}, 0)
+		for k, v := range rows[i] {
+			line[k] = v
+		}
+		ret = append(ret, line)
+	}
+
+	return ret, nil
+}
+
+func (f *fakeEntity) NewInsertData(tb string, data sysadmDB.FieldData) error {
+	_, e := f.NewBuildInsertQuery(tb, data)
+	return e
+}
+
+func (f *fakeEntity) NewBuildInsertQuery(tb string, data sysadmDB.FieldData) (string, error) {
+	row := make(map[string]interface{}, 0)
+	for k, v := range data {
+		row[k] = v
+	}
+	f.insert(tb, row)
+
+	return "insert into " + tb, nil
+}
+
+func (f *fakeEntity) NewUpdateData(tb string, data sysadmDB.FieldData, where map[string]string) error {
+	_, e := f.NewBuildUpdateQuery(tb, data, where)
+	return e
+}
+
+// NewBuildUpdateQuery update the rows whose fields are equal to the values in where, as the MySQL entity quotes the
+// values in where
+func (f *fakeEntity) NewBuildUpdateQuery(tb string, data sysadmDB.FieldData, where map[string]string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, row := range f.tables[tb] {
+		matched := true
+		for k, v := range where {
+			if utils.Interface2String(row[k]) != v {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		for k, v := range data {
+			row[k] = unquoteSQLValue(utils.Interface2String(v))
+		}
+		f.updates++
+	}
+
+	return "update " + tb, nil
+}
+
+func (f *fakeEntity) NewDeleteData(dd *sysadmDB.SelectData) error {
+	_, e := f.NewBuildDeleteQuery(dd)
+	return e
+}
+
+// NewBuildDeleteQuery delete the rows whose fields are equal to the values in the conditions of dd, as the MySQL entity
+// quotes the values in the conditions. the statement is built by the MySQL entity and is recorded in deletes
+func (f *fakeEntity) NewBuildDeleteQuery(dd *sysadmDB.SelectData) (string, error) {
+	query, e := sysadmDB.MySQL{Config: f.config}.NewBuildDeleteQuery(dd)
+	if e != nil {
+		return "", e
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.deletes = append(f.deletes, query)
+	for _, tb := range dd.Tb {
+		var kept []map[string]interface{}
+		for _, row := range f.tables[tb] {
+			matched := true
+			for k, v := range dd.Where {
+				if utils.Interface2String(row[k]) != v {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				kept = append(kept, row)
+			}
+		}
+		f.tables[tb] = kept
+	}
+
+	return query, nil
+}
+
+// matchWhere return true if row matches all the conditions in where. the conditions are in "=value", "<>value",
+// ">value", "in (values)" or "between value1 and value2" format
+func matchWhere(row map[string]interface{}, where map[string]string) bool {
+	for k, cond := range where {
+		value := utils.Interface2String(row[k])
+		cond = strings.TrimSpace(cond)
+		switch {
+		case k == "1":
+			continue
+		case strings.HasPrefix(cond, "in"):
+			found := false
+			list := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(cond, "in")), "("), ")")
+			for _, item := range strings.Split(list, ",") {
+				if unquoteSQLValue(item) == value {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		case strings.HasPrefix(cond, "between"):
+			bounds := strings.SplitN(strings.TrimPrefix(cond, "between"), " and ", 2)
+			if len(bounds) != 2 || compareSQLValue(value, unquoteSQLValue(bounds[0])) < 0 ||
+				compareSQLValue(value, unquoteSQLValue(bounds[1])) > 0 {
+				return false
+			}
+		case strings.HasPrefix(cond, ">"):
+			if compareSQLValue(value, unquoteSQLValue(strings.TrimPrefix(cond, ">"))) <= 0 {
+				return false
+			}
+		case strings.HasPrefix(cond, "<>"):
+			if unquoteSQLValue(strings.TrimPrefix(cond, "<>")) == value {
+				return false
+			}
+		case strings.HasPrefix(cond, "="):
+			if unquoteSQLValue(strings.TrimPrefix(cond, "=")) != value {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareSQLValue compare a and b as numbers if both of them are numbers, otherwise compare them as strings
+func compareSQLValue(a, b string) int {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+
+	return 0
+}
+
+// unquoteSQLValue return the value of a SQL literal which may be quoted by QuoteString
+func unquoteSQLValue(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+		s = strings.NewReplacer("\\'", "'", "\\\\", "\\").Replace(s)
+	}
+
+	return s
+}
+
+// fakeDriver is a database/sql driver whose statements do nothing
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("queries are not supported by the fake driver")
+}
@@ -17,6 +17,8 @@
 
 package app
 
+import "errors"
+
 const (
 	SettingScopeGlobal = iota
 	SettingScopeK8sCluster
@@ -40,6 +42,62 @@ const (
 	SettingKeyForApiServerCertKey = "apiserverkey"
 	SettingKeyForAgentCert        = "agentcert"
 	SettingKeyFroAgentCertKey     = "agentkey"
+
+	// 已删除对象的保留天数。可以通过 retentiondays.<对象名> 为某类对象单独设置，值为0表示永久保留
+	SettingKeyForRetentionDays = "retentiondays"
+	DefaultRetentionDays       = 30
+
+	// 列表页面每页显示的条目数
+	SettingKeyForNumPerPage = "numperpage"
+
+	// 加密存储的配置项在修订差异中显示的值
+	secretSettingMask = "******"
+)
+
+// 配置项值的类型
+const (
+	SettingTypeString SettingValueType = iota
+	SettingTypeInt
+	SettingTypeBool
+	SettingTypeDuration
+	SettingTypeList
+)
+
+// 配置项修订记录
+const (
+	revisionObjectName = "syssettingrevision"
+	revisionTableName  = "object_syssetting_sysadm_cn_syssettingrevision"
+	revisionPkName     = "id"
+
+	RevisionActionCreate   = "create"
+	RevisionActionUpdate   = "update"
+	RevisionActionRollback = "rollback"
+)
+
+// 用户表，用于判断执行回滚的用户是否为系统管理员
+const (
+	userTableName = "user"
+	userPkName    = "userid"
 )
 
+// SettingScopeDefault 表示配置项的值来自于配置项定义中的默认值，而不是来自于某一级别的配置
+const SettingScopeDefault = -1
+
+// ErrSettingNotSet 配置项在各个级别上都没有设置，且没有默认值
+var ErrSettingNotSet = errors.New("setting item has not been set")
+
+// resolveOrder 解析配置项生效值时依次查找的级别，排在前面的级别优先
+var resolveOrder = []int{SettingScopeUser, SettingScopeUserGroup, SettingScopeProject, SettingScopeNode, SettingScopeK8sCluster, SettingScopeGlobal}
+
+// 各级别的名称
+var scopeNames = map[int]string{
+	SettingScopeDefault:    "default",
+	SettingScopeGlobal:     "global",
+	SettingScopeK8sCluster: "k8scluster",
+	SettingScopeNode:       "node",
+	SettingScopeProject:    "project",
+	SettingScopeUserGroup:  "usergroup",
+	SettingScopeUser:       "user",
+}
+
 var runData = runingData{}
@@ -29,121 +29,7 @@ import (
 )
 
 func New() Syssetting {
-	ret := Syssetting{}
-	ret.Name = defaultObjectName
-	ret.TableName = defaultTableName
-	ret.PkName = defaultPkName
-	return ret
-}
-
-func (s Syssetting) GetObjectInfoByID(id string) (interface{}, error) {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return nil, fmt.Errorf("can not get sysSetting information with empty ID")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(s.TableName, s.PkName, id)
-	if e != nil {
-		return nil, e
-	}
-
-	settingData := SysSettingSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &settingData)
-
-	return settingData, e
-}
-
-func (s Syssetting) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &SysSettingSchema{}); !ok {
-		return -1, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &SysSettingSchema{}); !ok {
-		return -1, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	return sysadmObjects.GetObjectCount(s.TableName, s.PkName, searchContent, ids, searchKeys, conditions)
-}
-
-func (s Syssetting) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
-	startPos, step int, orders map[string]string) ([]interface{}, error) {
-
-	var ret []interface{}
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &SysSettingSchema{}); !ok {
-		return ret, fmt.Errorf("search key are not valid. error: %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &SysSettingSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of conditions must be the object fields name. %s", e)
-	}
-
-	var orderKeys []string
-	for k, _ := range orders {
-		orderKeys = append(orderKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(orderKeys, &SysSettingSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
-	}
-
-	dbData, e := sysadmObjects.GetObjectList(s.TableName, s.PkName, searchContent, ids, searchKeys, conditions, startPos, step, orders)
-	if e != nil {
-		return ret, e
-	}
-
-	var tmpRes []interface{}
-	for _, v := range dbData {
-		sysSettingData := &SysSettingSchema{}
-		if e := sysadmObjects.Unmarshal(v, sysSettingData); e != nil {
-			return ret, e
-		}
-		tmpRes = append(tmpRes, *sysSettingData)
-	}
-
-	return tmpRes, nil
-}
-
-func (s Syssetting) AddObject(data interface{}) error {
-	ssSchemaData, ok := data.(SysSettingSchema)
-	if !ok {
-		return fmt.Errorf("the data try to add is not valid")
-	}
-
-	insertData, e := sysadmObjects.Marshal(ssSchemaData)
-	if e != nil {
-		return e
-	}
-
-	return sysadmObjects.AddObject(s.TableName, s.PkName, insertData)
-}
-
-func (s Syssetting) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
-	addData := make(map[string]interface{}, 0)
-
-	schemaData, ok := data.(SysSettingSchema)
-	if !ok {
-		return addData, "", fmt.Errorf("there is an error occurred when coverting data to sysSetting Schema data")
-	}
-
-	addData, e := sysadmObjects.Marshal(schemaData)
-	if e != nil {
-		return addData, "", e
-	}
-
-	return addData, s.TableName, nil
-}
-
-func (s Syssetting) GetObjectIDFieldName() (string, string, error) {
-	return s.TableName, s.PkName, nil
+	return Syssetting{Repository: sysadmObjects.NewRepository[SysSettingSchema](defaultObjectName, defaultTableName, defaultPkName)}
 }
 
 func (s Syssetting) GetGlobalValueByKey(key string) (defaultValue, value []string, e error) {
@@ -227,27 +113,13 @@ func (s Syssetting) GetValueByKey(key, objectID string, scope int) (defaultValue
 	}
 	conditions["key"] = "='" + key + "'"
 
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, err := sysadmObjects.ValidKeysInSchema(conditionKeys, &SysSettingSchema{}); !ok {
-		e = errors.Wrap(err, "the keys of condition must be the object field name")
-		return
-	}
-
-	dbData, err := sysadmObjects.GetObjectList(s.TableName, s.PkName, "", []string{}, []string{}, conditions, 0, 0, nil)
-	if e != nil {
+	settings, err := s.List("", []string{}, []string{}, conditions, 0, 0, nil)
+	if err != nil {
 		e = errors.Wrap(err, "query data error")
 		return
 	}
 
-	for _, v := range dbData {
-		sysSettingData := &SysSettingSchema{}
-		if err := sysadmObjects.Unmarshal(v, sysSettingData); err != nil {
-			e = errors.Wrap(err, "unmarshal DB data to sysSetting Schema data error")
-			return
-		}
+	for _, sysSettingData := range settings {
 		defaultValue = append(defaultValue, sysSettingData.DefaultValue)
 		value = append(value, sysSettingData.Value)
 	}
@@ -281,7 +153,10 @@ func (s Syssetting) GetCertAndKey(certType int) (string, string, error) {
 	}
 
 	certBase64 := certs[0]
-	keyBase64 := keys[0]
+	keyBase64, e := decryptSettingValue(keyKey, keys[0])
+	if e != nil {
+		return "", "", e
+	}
 
 	cert, e := base64.StdEncoding.DecodeString(certBase64)
 	if e != nil {
@@ -296,55 +171,33 @@ func (s Syssetting) GetCertAndKey(certType int) (string, string, error) {
 	return string(cert), string(key), nil
 }
 
+// SaveCertAndKey save the certificate and its key of certType as global setting items in one transaction.
+// revisions are recorded for them, so the daemons watching the settings reload the certificate
 func (s Syssetting) SaveCertAndKey(certType int, cert, key, reason string) error {
-	scope := SettingScopeGlobal
 	certName, keyName, e := getCertAndKeyName(certType)
 	if e != nil {
 		return e
 	}
 
-	certBase64 := base64.StdEncoding.EncodeToString([]byte(cert))
-	keyBase64 := base64.StdEncoding.EncodeToString([]byte(key))
-	certSchemaData := SysSettingSchema{
-		Scope:              scope,
-		ObjectID:           "0",
-		Key:                certName,
-		Value:              certBase64,
-		LastModifiedBy:     0,
-		LastModifiedTime:   int(time.Now().Unix()),
-		LastModifiedReason: reason,
-	}
 	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, s)
 	if e != nil {
 		return e
 	}
-	e = tx.AddObject(certSchemaData)
-	if e != nil {
-		return e
-	}
 
-	keySchemmaData := SysSettingSchema{
-		Scope:              scope,
-		ObjectID:           "0",
-		Key:                keyName,
-		Value:              keyBase64,
-		LastModifiedBy:     0,
-		LastModifiedTime:   int(time.Now().Unix()),
-		LastModifiedReason: reason,
-	}
-	e = tx.AddObject(keySchemmaData)
-	if e != nil {
-		tx.Rollback()
+	// the key is encrypted by setValueTx
+	certBase64 := base64.StdEncoding.EncodeToString([]byte(cert))
+	if e := s.setValueTx(tx, SettingScopeGlobal, "0", certName, certBase64, 0, reason); e != nil {
+		_ = tx.Rollback()
 		return e
 	}
 
-	e = tx.Commit()
-	if e != nil {
-		tx.Rollback()
+	keyBase64 := base64.StdEncoding.EncodeToString([]byte(key))
+	if e := s.setValueTx(tx, SettingScopeGlobal, "0", keyName, keyBase64, 0, reason); e != nil {
+		_ = tx.Rollback()
 		return e
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 func getCertAndKeyName(certType int) (string, string, error) {
@@ -366,4 +219,34 @@ func getCertAndKeyName(certType int) (string, string, error) {
 	}
 
 	return certKey, keyKey, nil
-}
\ No newline at end of file
+}
+
+// GetRetention return how long the deleted objects named objectName should be kept before they are purged.
+// the value of retentiondays.<objectName> is used if it has been set, otherwise the value of retentiondays is used.
+// 0 means the deleted objects should be kept forever
+func (s Syssetting) GetRetention(objectName string) (time.Duration, error) {
+	keys := []string{SettingKeyForRetentionDays}
+	objectName = strings.TrimSpace(strings.ToLower(objectName))
+	if objectName != "" {
+		keys = append([]string{SettingKeyForRetentionDays + "." + objectName}, keys...)
+	}
+
+	for _, key := range keys {
+		ev, e := s.ResolveEffective(key, SettingContext{})
+		if IsSettingNotSet(e) {
+			continue
+		}
+		if e != nil {
+			return 0, e
+		}
+
+		if e := validNonNegativeInt(ev.Value); e != nil {
+			return 0, fmt.Errorf("value of setting item %s is not valid: %s", key, e)
+		}
+		days, _ := ev.Int()
+
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	return time.Duration(DefaultRetentionDays) * 24 * time.Hour, nil
+}
@@ -46,11 +46,7 @@ func SetRunData(dbConf *sysadmDB.DbConfig, logEntity *sysadmLog.LoggerConfig, wo
 		return e
 	}
 
-	runData.objectEntiy = Syssetting{
-		Name:      defaultObjectName,
-		TableName: defaultTableName,
-		PkName:    defaultPkName,
-	}
+	runData.objectEntiy = New()
 
 	return nil
 }
@@ -87,6 +83,9 @@ func AddHandlers(r *sysadmServer.Engine) error {
 	display := r.Group(groupPath)
 	{
 		display.GET("/list", listHandler)
+		display.GET("/revisions", revisionsHandler)
+		display.GET("/diffrevisions", diffRevisionsHandler)
+		display.POST("/rollback", rollbackHandler)
 		//	display.GET("/addform", addformHandler)
 		//	display.GET("/getprovincebycountrycodeforselect", getprovincebycountrycodeforselectHandler)
 		//	display.GET("/getcitybyprovincecodeforselect", getcitybyprovincecodeforselectHandler)
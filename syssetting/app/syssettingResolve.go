@@ -0,0 +1,294 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settingDefinitions 已注册的配置项定义
+var settingDefinitions = map[string]SettingDefinition{}
+
+// definitionLock protects settingDefinitions
+var definitionLock sync.RWMutex
+
+// RegisterSetting register the definition of a setting item. the definition registered before with the same key will
+// be replaced. an error will be returned if the default value of def is not valid
+func RegisterSetting(def SettingDefinition) error {
+	def.Key = strings.TrimSpace(strings.ToLower(def.Key))
+	if def.Key == "" {
+		return fmt.Errorf("key of setting item should not empty")
+	}
+
+	for _, scope := range def.Scopes {
+		if scope < SettingScopeGlobal || scope > SettingScopeUser {
+			return fmt.Errorf("scope %d of setting item %s is not valid", scope, def.Key)
+		}
+	}
+
+	if def.Default != "" {
+		if e := def.ValidValue(def.Default); e != nil {
+			return fmt.Errorf("default value of setting item %s is not valid: %s", def.Key, e)
+		}
+	}
+
+	definitionLock.Lock()
+	defer definitionLock.Unlock()
+	settingDefinitions[def.Key] = def
+
+	return nil
+}
+
+// GetSettingDefinition return the definition of key and true if it has been registered
+func GetSettingDefinition(key string) (SettingDefinition, bool) {
+	definitionLock.RLock()
+	defer definitionLock.RUnlock()
+	def, ok := settingDefinitions[strings.TrimSpace(strings.ToLower(key))]
+
+	return def, ok
+}
+
+// ScopeName return the name of scope
+func ScopeName(scope int) string {
+	if name, ok := scopeNames[scope]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// AllowScope return true if the setting item can be set at scope
+func (d SettingDefinition) AllowScope(scope int) bool {
+	if len(d.Scopes) < 1 {
+		return true
+	}
+
+	for _, s := range d.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidValue check whether value matches the type of the setting item and pass the validation of it
+func (d SettingDefinition) ValidValue(value string) error {
+	ev := EffectiveValue{Key: d.Key, Type: d.Type, Value: value}
+	var e error
+	switch d.Type {
+	case SettingTypeInt:
+		_, e = ev.Int()
+	case SettingTypeBool:
+		_, e = ev.Bool()
+	case SettingTypeDuration:
+		_, e = ev.Duration()
+	}
+	if e != nil {
+		return e
+	}
+
+	if d.Validate != nil {
+		return d.Validate(value)
+	}
+
+	return nil
+}
+
+// Source return the provenance of the value, such as "project 3" or "global"
+func (v EffectiveValue) Source() string {
+	if v.ObjectID == "" {
+		return ScopeName(v.Scope)
+	}
+
+	return ScopeName(v.Scope) + " " + v.ObjectID
+}
+
+// Int return the value as an int
+func (v EffectiveValue) Int() (int, error) {
+	i, e := strconv.Atoi(strings.TrimSpace(v.Value))
+	if e != nil {
+		return 0, fmt.Errorf("value %s of setting item %s is not an integer", v.Value, v.Key)
+	}
+
+	return i, nil
+}
+
+// Bool return the value as a bool. 1, t, true, yes and on are true, 0, f, false, no and off are false
+func (v EffectiveValue) Bool() (bool, error) {
+	switch strings.TrimSpace(strings.ToLower(v.Value)) {
+	case "1", "t", "true", "yes", "on":
+		return true, nil
+	case "0", "f", "false", "no", "off":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("value %s of setting item %s is not a bool", v.Value, v.Key)
+}
+
+// Duration return the value as a time.Duration. the value may be a duration string such as "1h30m",
+// or an integer which is the number of seconds
+func (v EffectiveValue) Duration() (time.Duration, error) {
+	value := strings.TrimSpace(v.Value)
+	if seconds, e := strconv.Atoi(value); e == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, e := time.ParseDuration(value)
+	if e != nil {
+		return 0, fmt.Errorf("value %s of setting item %s is not a duration", v.Value, v.Key)
+	}
+
+	return d, nil
+}
+
+// List return the value as a list of strings which are separated by comma. the empty items are dropped
+func (v EffectiveValue) List() []string {
+	var ret []string
+	for _, item := range strings.Split(v.Value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			ret = append(ret, item)
+		}
+	}
+
+	return ret
+}
+
+// ResolveEffective get the effective value of key in ctx. the value is searched in the order of
+// user -> user group -> project -> node -> k8s cluster -> global, the value of the first scope which has set the key
+// is the winner. the default value of the row is used if the value of it is empty. the default value in the
+// definition of the key is used if the key has not been set at any scope, and ErrSettingNotSet will be returned if
+// the key has not a default value either.
+// the scopes which are not allowed by the definition of the key are skipped.
+func (s Syssetting) ResolveEffective(key string, ctx SettingContext) (EffectiveValue, error) {
+	key = strings.TrimSpace(strings.ToLower(key))
+	def, defined := GetSettingDefinition(key)
+	ret := EffectiveValue{Key: key, Type: def.Type, Scope: SettingScopeDefault}
+
+	objectIDs := map[int]string{
+		SettingScopeUser:       strings.TrimSpace(ctx.UserID),
+		SettingScopeUserGroup:  strings.TrimSpace(ctx.UserGroupID),
+		SettingScopeProject:    strings.TrimSpace(ctx.ProjectID),
+		SettingScopeNode:       strings.TrimSpace(ctx.HostID),
+		SettingScopeK8sCluster: strings.TrimSpace(ctx.K8sClusterID),
+		SettingScopeGlobal:     "",
+	}
+
+	for _, scope := range resolveOrder {
+		objectID := objectIDs[scope]
+		if scope != SettingScopeGlobal && (objectID == "" || objectID == "0") {
+			continue
+		}
+		if defined && !def.AllowScope(scope) {
+			continue
+		}
+
+		defaultValue, value, e := s.GetValueByKey(key, objectID, scope)
+		if e != nil {
+			return ret, e
+		}
+
+		v := ""
+		if len(value) > 0 && strings.TrimSpace(value[0]) != "" {
+			v = value[0]
+		} else if len(defaultValue) > 0 && strings.TrimSpace(defaultValue[0]) != "" {
+			v = defaultValue[0]
+		}
+		if v == "" {
+			continue
+		}
+		if defined && def.Secret {
+			if v, e = decryptSettingValue(key, v); e != nil {
+				return ret, e
+			}
+		}
+
+		ret.Value, ret.Scope, ret.ObjectID = v, scope, objectID
+		if defined {
+			if e := def.ValidValue(v); e != nil {
+				return ret, fmt.Errorf("value of setting item %s at %s is not valid: %s", key, ret.Source(), e)
+			}
+		}
+
+		return ret, nil
+	}
+
+	if defined && def.Default != "" {
+		ret.Value = def.Default
+		return ret, nil
+	}
+
+	return ret, ErrSettingNotSet
+}
+
+// ResolveInt get the effective value of key in ctx as an int
+func (s Syssetting) ResolveInt(key string, ctx SettingContext) (int, EffectiveValue, error) {
+	ev, e := s.ResolveEffective(key, ctx)
+	if e != nil {
+		return 0, ev, e
+	}
+
+	i, e := ev.Int()
+
+	return i, ev, e
+}
+
+// ResolveBool get the effective value of key in ctx as a bool
+func (s Syssetting) ResolveBool(key string, ctx SettingContext) (bool, EffectiveValue, error) {
+	ev, e := s.ResolveEffective(key, ctx)
+	if e != nil {
+		return false, ev, e
+	}
+
+	b, e := ev.Bool()
+
+	return b, ev, e
+}
+
+// ResolveDuration get the effective value of key in ctx as a time.Duration
+func (s Syssetting) ResolveDuration(key string, ctx SettingContext) (time.Duration, EffectiveValue, error) {
+	ev, e := s.ResolveEffective(key, ctx)
+	if e != nil {
+		return 0, ev, e
+	}
+
+	d, e := ev.Duration()
+
+	return d, ev, e
+}
+
+// ResolveList get the effective value of key in ctx as a list of strings
+func (s Syssetting) ResolveList(key string, ctx SettingContext) ([]string, EffectiveValue, error) {
+	ev, e := s.ResolveEffective(key, ctx)
+	if e != nil {
+		return []string{}, ev, e
+	}
+
+	return ev.List(), ev, nil
+}
+
+// IsSettingNotSet return true if e means the setting item has not been set
+func IsSettingNotSet(e error) bool {
+	return errors.Is(e, ErrSettingNotSet)
+}
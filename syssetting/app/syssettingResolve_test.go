@@ -0,0 +1,122 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+// registerTestSetting register def and remove it after the test
+func registerTestSetting(t *testing.T, def SettingDefinition) {
+	t.Helper()
+	if e := RegisterSetting(def); e != nil {
+		t.Fatalf("register setting item %s error: %s", def.Key, e)
+	}
+	t.Cleanup(func() {
+		definitionLock.Lock()
+		delete(settingDefinitions, def.Key)
+		definitionLock.Unlock()
+	})
+}
+
+func insertTestSetting(db *fakeEntity, id, scope int, objectID, key, defaultValue, value string) {
+	db.insert(defaultTableName, map[string]interface{}{"id": id, "scope": scope, "objectID": objectID, "key": key,
+		"defaultValue": defaultValue, "value": value})
+}
+
+func TestResolveEffective(t *testing.T) {
+	db := newFakeDB(t)
+	registerTestSetting(t, SettingDefinition{Key: "test.timeout", Type: SettingTypeDuration, Default: "30s",
+		Scopes: []int{SettingScopeGlobal, SettingScopeProject, SettingScopeUser}})
+	registerTestSetting(t, SettingDefinition{Key: "test.pagesize", Type: SettingTypeInt, Default: "20",
+		Scopes: []int{SettingScopeGlobal, SettingScopeProject}})
+	insertTestSetting(db, 1, SettingScopeGlobal, "0", "test.timeout", "", "1m")
+	insertTestSetting(db, 2, SettingScopeProject, "3", "test.timeout", "", "90")
+	insertTestSetting(db, 3, SettingScopeUser, "5", "test.timeout", "2h", "")
+	insertTestSetting(db, 4, SettingScopeUser, "5", "test.pagesize", "", "100")
+	insertTestSetting(db, 5, SettingScopeProject, "3", "test.pagesize", "", "50")
+
+	s := New()
+	tests := []struct {
+		ctx    SettingContext
+		want   time.Duration
+		source string
+	}{
+		{SettingContext{}, time.Minute, "global"},
+		{SettingContext{ProjectID: "3"}, 90 * time.Second, "project 3"},
+		{SettingContext{ProjectID: "4"}, time.Minute, "global"},
+		// the default value of the row is used if the value of it is empty
+		{SettingContext{UserID: "5", ProjectID: "3"}, 2 * time.Hour, "user 5"},
+		{SettingContext{UserID: "0", ProjectID: "3"}, 90 * time.Second, "project 3"},
+	}
+	for _, tt := range tests {
+		d, ev, e := s.ResolveDuration("Test.Timeout", tt.ctx)
+		if e != nil || d != tt.want || ev.Source() != tt.source {
+			t.Errorf("ResolveDuration(%+v) = %s from %s, %v, want %s from %s", tt.ctx, d, ev.Source(), e, tt.want, tt.source)
+		}
+	}
+
+	// the value at the scope which is not allowed by the definition is skipped
+	if n, ev, e := s.ResolveInt("test.pagesize", SettingContext{UserID: "5", ProjectID: "3"}); e != nil || n != 50 ||
+		ev.Source() != "project 3" {
+		t.Errorf("ResolveInt(test.pagesize) = %d from %s, %v, want 50 from project 3", n, ev.Source(), e)
+	}
+	// the default value of the definition is used if the item has not been set at any scope
+	if n, ev, e := s.ResolveInt("test.pagesize", SettingContext{ProjectID: "4"}); e != nil || n != 20 ||
+		ev.Scope != SettingScopeDefault {
+		t.Errorf("ResolveInt(test.pagesize) = %d from %s, %v, want the default value 20", n, ev.Source(), e)
+	}
+
+	if _, e := s.ResolveEffective("test.unknown", SettingContext{}); !IsSettingNotSet(e) {
+		t.Errorf("ResolveEffective of an unknown item = %v, want ErrSettingNotSet", e)
+	}
+
+	insertTestSetting(db, 6, SettingScopeProject, "6", "test.pagesize", "", "many")
+	if _, _, e := s.ResolveInt("test.pagesize", SettingContext{ProjectID: "6"}); e == nil {
+		t.Errorf("the value which is not an integer should be rejected")
+	}
+}
+
+func TestRegisterSetting(t *testing.T) {
+	for _, def := range []SettingDefinition{
+		{Key: " "},
+		{Key: "test.bad", Scopes: []int{SettingScopeUser + 1}},
+		{Key: "test.bad", Type: SettingTypeBool, Default: "maybe"},
+	} {
+		if e := RegisterSetting(def); e == nil {
+			t.Errorf("definition %+v should be rejected", def)
+		}
+	}
+
+	registerTestSetting(t, SettingDefinition{Key: " Test.Enabled ", Type: SettingTypeBool, Default: "on"})
+	def, ok := GetSettingDefinition("test.enabled")
+	if !ok || def.Key != "test.enabled" {
+		t.Fatalf("definition of test.enabled = %+v, %v", def, ok)
+	}
+	for value, valid := range map[string]bool{"yes": true, "0": true, "2": false} {
+		if e := def.ValidValue(value); (e == nil) != valid {
+			t.Errorf("ValidValue(%s) = %v, want valid %v", value, e, valid)
+		}
+	}
+
+	ev := EffectiveValue{Key: "test.list", Value: " a, ,b,c "}
+	if list := ev.List(); len(list) != 3 || list[0] != "a" || list[2] != "c" {
+		t.Errorf("List() = %q", list)
+	}
+}
@@ -0,0 +1,272 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+)
+
+// revisionRepository is the repository of the revisions of settings
+var revisionRepository = sysadmObjects.NewRepository[SettingRevisionSchema](revisionObjectName, revisionTableName, revisionPkName)
+
+// SetValue set the value of key at scope for the object objectID and record a revision of it.
+// the setting item will be created if it has not been set at the scope. objectID is ignored for global scope.
+func (s Syssetting) SetValue(scope int, objectID, key, value string, modifiedBy int, reason string) error {
+	tx, e := sysadmObjects.BeginTx(nil, s)
+	if e != nil {
+		return e
+	}
+
+	if e := s.setValueTx(tx, scope, objectID, key, value, modifiedBy, reason); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// setValueTx set the value of key at scope for the object objectID and record a revision of it in transaction tx
+func (s Syssetting) setValueTx(tx sysadmObjects.ObjectTx, scope int, objectID, key, value string, modifiedBy int, reason string) error {
+	key = strings.TrimSpace(strings.ToLower(key))
+	if key == "" {
+		return fmt.Errorf("key of setting item should not empty")
+	}
+
+	objectID = strings.TrimSpace(objectID)
+	if scope == SettingScopeGlobal {
+		objectID = "0"
+	}
+	if objectID == "" {
+		return fmt.Errorf("ID of the object which setting item %s is set for should not empty", key)
+	}
+
+	if def, ok := GetSettingDefinition(key); ok {
+		if !def.AllowScope(scope) {
+			return fmt.Errorf("setting item %s can not be set at %s", key, ScopeName(scope))
+		}
+		if e := def.ValidValue(value); e != nil {
+			return e
+		}
+	}
+
+	value, e := encryptSettingValue(key, value)
+	if e != nil {
+		return e
+	}
+
+	conditions := make(map[string]string, 0)
+	conditions["scope"] = "='" + strconv.Itoa(scope) + "'"
+	conditions["objectID"] = "='" + objectID + "'"
+	conditions["key"] = "='" + key + "'"
+	settings, e := s.OnPrimary().List("", []string{}, []string{}, conditions, 0, 0, nil)
+	if e != nil {
+		return e
+	}
+
+	setting := SysSettingSchema{Scope: scope, ObjectID: objectID, Key: key, Value: value}
+	action := RevisionActionCreate
+	if len(settings) > 0 {
+		setting = settings[0]
+		setting.Value = value
+		action = RevisionActionUpdate
+		e = s.updateValueTx(tx, setting, settings[0].Value, modifiedBy, reason)
+	} else {
+		e = s.createTx(tx, &setting, modifiedBy, reason)
+	}
+	if e != nil {
+		return e
+	}
+
+	return addRevisionTx(tx, setting, action, 0, modifiedBy, reason)
+}
+
+// GetRevisions return the revisions of the setting item which ID is settingID. the latest revision is the first one
+func (s Syssetting) GetRevisions(settingID int) ([]SettingRevisionSchema, error) {
+	conditions := make(map[string]string, 0)
+	conditions["settingID"] = "='" + strconv.Itoa(settingID) + "'"
+
+	return revisionRepository.List("", nil, nil, conditions, 0, 0, map[string]string{"revision": "1"})
+}
+
+// GetRevision return the revision of the setting item which ID is settingID
+func (s Syssetting) GetRevision(settingID, revision int) (SettingRevisionSchema, error) {
+	conditions := make(map[string]string, 0)
+	conditions["settingID"] = "='" + strconv.Itoa(settingID) + "'"
+	conditions["revision"] = "='" + strconv.Itoa(revision) + "'"
+	revisions, e := revisionRepository.List("", nil, nil, conditions, 0, 1, nil)
+	if e != nil {
+		return SettingRevisionSchema{}, e
+	}
+
+	if len(revisions) < 1 {
+		return SettingRevisionSchema{}, fmt.Errorf("revision %d of setting item %d was not found", revision, settingID)
+	}
+
+	return revisions[0], nil
+}
+
+// DiffRevisions return the fields which are different between revision from and revision to of the setting item
+func (s Syssetting) DiffRevisions(settingID, from, to int) ([]RevisionDiffItem, error) {
+	var ret []RevisionDiffItem
+	fromRevision, e := s.GetRevision(settingID, from)
+	if e != nil {
+		return ret, e
+	}
+
+	toRevision, e := s.GetRevision(settingID, to)
+	if e != nil {
+		return ret, e
+	}
+
+	// the values of secret setting items are compared after decrypted, and they are masked in the result
+	secret := isSecretSetting(toRevision.Key)
+	fields := []string{"defaultValue", "value"}
+	fromValues := []string{fromRevision.DefaultValue, fromRevision.Value}
+	toValues := []string{toRevision.DefaultValue, toRevision.Value}
+	for i, field := range fields {
+		fromValue, e := decryptSettingValue(toRevision.Key, fromValues[i])
+		if e != nil {
+			return ret, e
+		}
+		toValue, e := decryptSettingValue(toRevision.Key, toValues[i])
+		if e != nil {
+			return ret, e
+		}
+
+		if fromValue == toValue {
+			continue
+		}
+		if secret {
+			fromValue, toValue = secretSettingMask, secretSettingMask
+		}
+		ret = append(ret, RevisionDiffItem{Field: field, From: fromValue, To: toValue})
+	}
+
+	return ret, nil
+}
+
+// Rollback set the value of the setting item which ID is settingID to the value of revision. a new revision which
+// records who rolled the setting back and why is added
+func (s Syssetting) Rollback(settingID, revision, operator int, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("reason of rolling back setting item should not empty")
+	}
+
+	target, e := s.GetRevision(settingID, revision)
+	if e != nil {
+		return e
+	}
+
+	setting, e := s.Get(strconv.Itoa(settingID))
+	if e != nil {
+		return e
+	}
+
+	if def, ok := GetSettingDefinition(setting.Key); ok {
+		if e := def.ValidValue(target.Value); e != nil {
+			return fmt.Errorf("value of revision %d can not be rolled back to: %s", revision, e)
+		}
+	}
+
+	tx, e := sysadmObjects.BeginTx(nil, s)
+	if e != nil {
+		return e
+	}
+
+	lastValue := setting.Value
+	setting.Value = target.Value
+	setting.DefaultValue = target.DefaultValue
+	if e := s.updateValueTx(tx, setting, lastValue, operator, reason); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	if e := addRevisionTx(tx, setting, RevisionActionRollback, revision, operator, reason); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// createTx insert setting into DB in transaction tx. the ID of setting is set to the new allocated ID
+func (s Syssetting) createTx(tx sysadmObjects.ObjectTx, setting *SysSettingSchema, modifiedBy int, reason string) error {
+	id, e := tx.NextObjectID()
+	if e != nil {
+		return e
+	}
+
+	setting.Id = id
+	setting.LastModifiedBy = modifiedBy
+	setting.LastModifiedTime = int(time.Now().Unix())
+	setting.LastModifiedReason = reason
+
+	return s.CreateTx(tx, *setting)
+}
+
+// updateValueTx update the value and default value of setting in transaction tx. lastValue is the value before updating
+func (s Syssetting) updateValueTx(tx sysadmObjects.ObjectTx, setting SysSettingSchema, lastValue string, modifiedBy int, reason string) error {
+	updateData := make(sysadmDB.FieldData, 0)
+	updateData["value"] = sysadmObjects.QuoteString(setting.Value)
+	updateData["defaultValue"] = sysadmObjects.QuoteString(setting.DefaultValue)
+	updateData["lastValue"] = sysadmObjects.QuoteString(lastValue)
+	updateData["lastModifiedBy"] = modifiedBy
+	updateData["lastModifiedTime"] = time.Now().Unix()
+	updateData["lastModifiedReason"] = sysadmObjects.QuoteString(reason)
+	where := map[string]string{s.PkName: strconv.Itoa(int(setting.Id))}
+
+	return tx.Tx.NewUpdateData(s.TableName, updateData, where)
+}
+
+// addRevisionTx add a revision for setting in transaction tx
+func addRevisionTx(tx sysadmObjects.ObjectTx, setting SysSettingSchema, action string, rollbackTo, modifiedBy int, reason string) error {
+	// the last revision is locked until tx is committed, so concurrent changes of the setting get different revisions
+	revision, e := tx.Tx.NextGroupValue(revisionTableName, "revision", "settingID", setting.Id)
+	if e != nil {
+		return e
+	}
+
+	id, e := tx.Tx.NextID(revisionTableName, revisionPkName)
+	if e != nil {
+		return e
+	}
+
+	revisionData := SettingRevisionSchema{
+		ID:           uint(id),
+		SettingID:    setting.Id,
+		Revision:     int(revision),
+		Scope:        setting.Scope,
+		ObjectID:     setting.ObjectID,
+		Key:          setting.Key,
+		DefaultValue: setting.DefaultValue,
+		Value:        setting.Value,
+		Action:       action,
+		RollbackTo:   rollbackTo,
+		ModifiedBy:   modifiedBy,
+		ModifiedTime: int(time.Now().Unix()),
+		Reason:       reason,
+	}
+
+	return revisionRepository.CreateTx(tx, revisionData)
+}
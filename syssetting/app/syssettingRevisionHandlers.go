@@ -0,0 +1,154 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/wangyysde/sysadmServer"
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/sysadmLog"
+	"sysadm/sysadmapi/apiutils"
+	"sysadm/user"
+	"sysadm/utils"
+)
+
+// revisionsHandler response the revisions of the setting item which ID is settingID
+func revisionsHandler(c *sysadmServer.Context) {
+	var errs []sysadmLog.Sysadmerror
+	if !checkLogin(c, 700200001) {
+		return
+	}
+
+	requestData, _ := utils.NewGetRequestData(c, []string{"settingID"})
+	settingID, e := strconv.Atoi(requestData["settingID"])
+	if e != nil || settingID < 1 {
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(700200002, "配置项ID不正确"))
+		return
+	}
+
+	revisions, e := New().GetRevisions(settingID)
+	if e != nil {
+		errs = append(errs, sysadmLog.NewErrorWithStringLevel(700200003, "error", "get revisions of setting item %d error %s", settingID, e))
+		runData.logEntity.LogErrors(errs)
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(700200003, "系统内部出错，请稍后再试或联系系统管理员"))
+		return
+	}
+
+	var data []map[string]interface{}
+	for _, r := range revisions {
+		value, defaultValue := r.Value, r.DefaultValue
+		if isSecretSetting(r.Key) {
+			value, defaultValue = secretSettingMask, secretSettingMask
+		}
+		data = append(data, map[string]interface{}{"revision": r.Revision, "key": r.Key, "value": value,
+			"defaultValue": defaultValue, "action": r.Action, "rollbackTo": r.RollbackTo, "modifiedBy": r.ModifiedBy,
+			"modifiedTime": r.ModifiedTime, "reason": r.Reason})
+	}
+	c.JSON(http.StatusOK, apiutils.BuildResponseDataForMap(data))
+}
+
+// diffRevisionsHandler response the differences between revision from and revision to of a setting item
+func diffRevisionsHandler(c *sysadmServer.Context) {
+	var errs []sysadmLog.Sysadmerror
+	if !checkLogin(c, 700200004) {
+		return
+	}
+
+	requestData, _ := utils.NewGetRequestData(c, []string{"settingID", "from", "to"})
+	settingID, e1 := strconv.Atoi(requestData["settingID"])
+	from, e2 := strconv.Atoi(requestData["from"])
+	to, e3 := strconv.Atoi(requestData["to"])
+	if e1 != nil || e2 != nil || e3 != nil {
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(700200005, "配置项ID或修订号不正确"))
+		return
+	}
+
+	diff, e := New().DiffRevisions(settingID, from, to)
+	if e != nil {
+		errs = append(errs, sysadmLog.NewErrorWithStringLevel(700200006, "error", "diff revisions %d and %d of setting item %d error %s", from, to, settingID, e))
+		runData.logEntity.LogErrors(errs)
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(700200006, "系统内部出错，请稍后再试或联系系统管理员"))
+		return
+	}
+
+	var data []map[string]interface{}
+	for _, d := range diff {
+		data = append(data, map[string]interface{}{"field": d.Field, "from": d.From, "to": d.To})
+	}
+	c.JSON(http.StatusOK, apiutils.BuildResponseDataForMap(data))
+}
+
+// rollbackHandler roll the setting item which ID is settingID back to revision. the user who rolls back and the
+// reason of the rollback are recorded in a new revision
+func rollbackHandler(c *sysadmServer.Context) {
+	var errs []sysadmLog.Sysadmerror
+	islogin, userid, _ := user.IsLogin(c, runData.sessionName)
+	if !islogin || !isSysadmin(userid) {
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(700200007, "您没有登录或者没有权限执行本操作"))
+		return
+	}
+
+	requestData, _ := utils.NewGetRequestData(c, []string{"settingID", "revision", "reason"})
+	settingID, e1 := strconv.Atoi(requestData["settingID"])
+	revision, e2 := strconv.Atoi(requestData["revision"])
+	if e1 != nil || e2 != nil {
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(700200008, "配置项ID或修订号不正确"))
+		return
+	}
+
+	if e := New().Rollback(settingID, revision, userid, requestData["reason"]); e != nil {
+		errs = append(errs, sysadmLog.NewErrorWithStringLevel(700200009, "error", "roll setting item %d back to revision %d error %s", settingID, revision, e))
+		runData.logEntity.LogErrors(errs)
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(700200009, "回滚配置项出错: "+e.Error()))
+		return
+	}
+
+	errs = append(errs, sysadmLog.NewErrorWithStringLevel(700200010, "info", "setting item %d has been rolled back to revision %d by user %d", settingID, revision, userid))
+	runData.logEntity.LogErrors(errs)
+	c.JSON(http.StatusOK, apiutils.BuildResponseDataForSuccess("回滚成功"))
+}
+
+// checkLogin send an error response with errCode and return false if the user has not login
+func checkLogin(c *sysadmServer.Context, errCode int) bool {
+	islogin, _, _ := user.IsLogin(c, runData.sessionName)
+	if !islogin {
+		c.JSON(http.StatusOK, apiutils.BuildResponseDataForError(errCode, "您没有登录或者没有权限执行本操作"))
+	}
+
+	return islogin
+}
+
+// isSysadmin return true if the user which ID is userid is a system administrator and has not been deleted.
+// user/app depends on this package, so the user is got from user table directly
+func isSysadmin(userid int) bool {
+	if userid < 1 {
+		return false
+	}
+
+	data, e := sysadmObjects.GetObjectInfoByID(userTableName, userPkName, strconv.Itoa(userid))
+	if e != nil {
+		return false
+	}
+
+	flag, e1 := utils.Interface2Int(data["sysadmin_flag"])
+	deleted, e2 := utils.Interface2Int(data["deleted"])
+
+	return e1 == nil && e2 == nil && flag == 1 && deleted == 0
+}
@@ -0,0 +1,83 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"testing"
+)
+
+func insertTestRevision(db *fakeEntity, id, settingID, revision int, defaultValue, value, action string) {
+	db.insert(revisionTableName, map[string]interface{}{"id": id, "settingID": settingID, "revision": revision,
+		"scope": SettingScopeGlobal, "objectID": "0", "key": "test.revision", "defaultValue": defaultValue,
+		"value": value, "action": action})
+}
+
+func TestRevisions(t *testing.T) {
+	db := newFakeDB(t)
+	insertTestRevision(db, 1, 7, 1, "10", "10", RevisionActionCreate)
+	insertTestRevision(db, 2, 7, 2, "10", "20", RevisionActionUpdate)
+	insertTestRevision(db, 3, 8, 1, "", "a", RevisionActionCreate)
+	insertTestRevision(db, 4, 7, 3, "15", "20", RevisionActionUpdate)
+
+	s := New()
+	revisions, e := s.GetRevisions(7)
+	if e != nil || len(revisions) != 3 {
+		t.Fatalf("GetRevisions(7) = %+v, %v", revisions, e)
+	}
+	for i, want := range []int{3, 2, 1} {
+		if revisions[i].Revision != want {
+			t.Errorf("revision %d = %d, want %d. the latest revision should be the first one", i, revisions[i].Revision, want)
+		}
+	}
+
+	diff, e := s.DiffRevisions(7, 1, 3)
+	if e != nil || len(diff) != 2 {
+		t.Fatalf("DiffRevisions(7, 1, 3) = %+v, %v", diff, e)
+	}
+	if diff[0] != (RevisionDiffItem{Field: "defaultValue", From: "10", To: "15"}) ||
+		diff[1] != (RevisionDiffItem{Field: "value", From: "10", To: "20"}) {
+		t.Errorf("DiffRevisions(7, 1, 3) = %+v", diff)
+	}
+	if diff, e := s.DiffRevisions(7, 2, 2); e != nil || len(diff) != 0 {
+		t.Errorf("DiffRevisions(7, 2, 2) = %+v, %v, want no difference", diff, e)
+	}
+	if _, e := s.DiffRevisions(7, 1, 9); e == nil {
+		t.Errorf("DiffRevisions with a revision which does not exist should fail")
+	}
+}
+
+func TestRollbackRejected(t *testing.T) {
+	db := newFakeDB(t)
+	registerTestSetting(t, SettingDefinition{Key: "test.revision", Type: SettingTypeInt})
+	insertTestSetting(db, 7, SettingScopeGlobal, "0", "test.revision", "", "20")
+	insertTestRevision(db, 1, 7, 1, "", "ten", RevisionActionCreate)
+
+	s := New()
+	if e := s.Rollback(7, 1, 1, " "); e == nil {
+		t.Errorf("rolling back without reason should fail")
+	}
+	if e := s.Rollback(7, 2, 1, "restore"); e == nil {
+		t.Errorf("rolling back to a revision which does not exist should fail")
+	}
+	if e := s.Rollback(7, 1, 1, "restore"); e == nil {
+		t.Errorf("rolling back to a value which is not valid should fail")
+	}
+	if db.updates != 0 {
+		t.Errorf("the setting item should not be updated by rejected rollbacks")
+	}
+}
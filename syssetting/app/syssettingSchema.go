@@ -0,0 +1,71 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import "fmt"
+
+// builtinSettings 系统内置配置项的定义
+var builtinSettings = []SettingDefinition{
+	{Key: SettingKeyForCA, Type: SettingTypeString, Scopes: []int{SettingScopeGlobal}, Description: "CA证书"},
+	{Key: SettingKeyForCaKey, Type: SettingTypeString, Scopes: []int{SettingScopeGlobal}, Secret: true, Description: "CA证书密钥"},
+	{Key: SettingKeyForApiServerCert, Type: SettingTypeString, Scopes: []int{SettingScopeGlobal}, Description: "apiserver证书"},
+	{Key: SettingKeyForApiServerCertKey, Type: SettingTypeString, Scopes: []int{SettingScopeGlobal}, Secret: true, Description: "apiserver证书密钥"},
+	{Key: SettingKeyForAgentCert, Type: SettingTypeString, Scopes: []int{SettingScopeGlobal}, Description: "agent证书"},
+	{Key: SettingKeyFroAgentCertKey, Type: SettingTypeString, Scopes: []int{SettingScopeGlobal}, Secret: true, Description: "agent证书密钥"},
+	{Key: SettingKeyForRetentionDays, Type: SettingTypeInt, Default: fmt.Sprintf("%d", DefaultRetentionDays),
+		Scopes: []int{SettingScopeGlobal}, Validate: validNonNegativeInt, Description: "已删除对象的保留天数，0表示永久保留"},
+	{Key: SettingKeyForNumPerPage, Type: SettingTypeInt, Scopes: []int{SettingScopeGlobal}, Validate: validPositiveInt,
+		Description: "列表页面每页显示的条目数"},
+}
+
+// register builtin setting items
+func init() {
+	for _, def := range builtinSettings {
+		if e := RegisterSetting(def); e != nil {
+			panic(e)
+		}
+	}
+}
+
+// validNonNegativeInt check whether value is an integer which is not less than 0
+func validNonNegativeInt(value string) error {
+	i, e := EffectiveValue{Value: value}.Int()
+	if e != nil {
+		return e
+	}
+
+	if i < 0 {
+		return fmt.Errorf("value %s should not less than 0", value)
+	}
+
+	return nil
+}
+
+// validPositiveInt check whether value is an integer which is large than 0
+func validPositiveInt(value string) error {
+	i, e := EffectiveValue{Value: value}.Int()
+	if e != nil {
+		return e
+	}
+
+	if i < 1 {
+		return fmt.Errorf("value %s should large than 0", value)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"strings"
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/utils"
+)
+
+// register the secret setting items to the secret rotation
+func init() {
+	sysadmObjects.RegisterSecretRotator(settingSecretRotator{})
+}
+
+// isSecretSetting return true if the value of key should be encrypted
+func isSecretSetting(key string) bool {
+	def, ok := GetSettingDefinition(key)
+
+	return ok && def.Secret
+}
+
+// secretSettingKeys return the keys of the setting items which values should be encrypted
+func secretSettingKeys() []string {
+	definitionLock.RLock()
+	defer definitionLock.RUnlock()
+
+	var ret []string
+	for key, def := range settingDefinitions {
+		if def.Secret {
+			ret = append(ret, key)
+		}
+	}
+
+	return ret
+}
+
+// encryptSettingValue encrypt value with the master key if key is a secret setting item
+func encryptSettingValue(key, value string) (string, error) {
+	if !isSecretSetting(key) {
+		return value, nil
+	}
+
+	return sysadmObjects.EncryptSecret(value)
+}
+
+// decryptSettingValue decrypt value if key is a secret setting item
+func decryptSettingValue(key, value string) (string, error) {
+	if !isSecretSetting(key) {
+		return value, nil
+	}
+
+	return sysadmObjects.DecryptSecret(value)
+}
+
+// GetName implements SecretRotator interface
+func (r settingSecretRotator) GetName() string {
+	return defaultObjectName
+}
+
+// RotateSecrets implements SecretRotator interface. the values of the secret setting items and the values of their
+// revisions are re-encrypted with the current master key
+func (r settingSecretRotator) RotateSecrets() (int, error) {
+	keys := secretSettingKeys()
+	if len(keys) < 1 || !sysadmObjects.SecretEnabled() {
+		return 0, nil
+	}
+
+	var quotedKeys []string
+	for _, key := range keys {
+		quotedKeys = append(quotedKeys, sysadmObjects.QuoteString(key))
+	}
+	conditions := map[string]string{"key": " in (" + strings.Join(quotedKeys, ",") + ")"}
+
+	num, e := rotateSettingRows(defaultTableName, defaultPkName, conditions, []string{"defaultValue", "value", "lastValue"})
+	if e != nil {
+		return num, e
+	}
+
+	revisionNum, e := rotateSettingRows(revisionTableName, revisionPkName, conditions, []string{"defaultValue", "value"})
+
+	return num + revisionNum, e
+}
+
+// rotateSettingRows re-encrypt the fields of the rows in tableName which match conditions.
+// return the number of rows which have been re-encrypted
+func rotateSettingRows(tableName, pkName string, conditions map[string]string, fields []string) (int, error) {
+	rows, e := sysadmObjects.GetObjectList(tableName, pkName, "", nil, nil, conditions, 0, 0, nil)
+	if e != nil {
+		return 0, e
+	}
+
+	num := 0
+	for _, row := range rows {
+		id := utils.Interface2String(row[pkName])
+		updateData := make(sysadmDB.FieldData, 0)
+		for _, field := range fields {
+			value, changed, e := sysadmObjects.RotateSecret(utils.Interface2String(row[field]))
+			if e != nil {
+				return num, fmt.Errorf("rotate %s of %s %s error: %s", field, tableName, id, e)
+			}
+			if changed {
+				updateData[field] = sysadmObjects.QuoteString(value)
+			}
+		}
+
+		if len(updateData) < 1 {
+			continue
+		}
+
+		where := map[string]string{pkName: id}
+		if e := runData.dbConf.Entity.NewUpdateData(tableName, updateData, where); e != nil {
+			return num, e
+		}
+		num++
+	}
+
+	return num, nil
+}
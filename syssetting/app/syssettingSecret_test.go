@@ -0,0 +1,81 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	sysadmObjects "sysadm/objects/app"
+	"sysadm/utils"
+)
+
+// loadTestMasterKeys load the master keys which ids are ids. the key bytes of a key are all the last byte of its id,
+// and the first key is the current key
+func loadTestMasterKeys(t *testing.T, ids ...string) {
+	t.Helper()
+	var keys []string
+	for _, id := range ids {
+		keys = append(keys, id+":"+base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id[len(id)-1:], 32))))
+	}
+	t.Setenv(sysadmObjects.MasterKeyEnv, strings.Join(keys, ","))
+	if ok, e := sysadmObjects.LoadMasterKeys(t.TempDir()); !ok || e != nil {
+		t.Fatalf("load master keys error: %v", e)
+	}
+}
+
+func TestRotateSettingSecrets(t *testing.T) {
+	db := newFakeDB(t)
+	registerTestSetting(t, SettingDefinition{Key: "test.secret", Secret: true})
+	loadTestMasterKeys(t, "k1")
+
+	encrypted, e := encryptSettingValue("test.secret", "p@ss'word")
+	if e != nil {
+		t.Fatalf("encrypt setting value error: %s", e)
+	}
+	insertTestSetting(db, 5, SettingScopeGlobal, "0", "test.secret", "", encrypted)
+	insertTestSetting(db, 6, SettingScopeGlobal, "0", "test.plain", "", "plain")
+	db.insert(revisionTableName, map[string]interface{}{"id": 9, "settingID": 5, "revision": 1, "key": "test.secret",
+		"defaultValue": "", "value": encrypted})
+
+	loadTestMasterKeys(t, "k2", "k1")
+	num, e := settingSecretRotator{}.RotateSecrets()
+	if e != nil || num != 2 {
+		t.Fatalf("RotateSecrets() = %d, %v, want 2", num, e)
+	}
+
+	for tb, id := range map[string]string{defaultTableName: "5", revisionTableName: "9"} {
+		value := utils.Interface2String(db.row(tb, "id", id)["value"])
+		if value == encrypted {
+			t.Errorf("value of %s %s has not been re-encrypted", tb, id)
+			continue
+		}
+		if plain, e := decryptSettingValue("test.secret", value); e != nil || plain != "p@ss'word" {
+			t.Errorf("value of %s %s is decrypted to %q, %v", tb, id, plain, e)
+		}
+	}
+	if value := db.row(defaultTableName, "id", "6")["value"]; value != "plain" {
+		t.Errorf("the value of the setting item which is not secret should not be changed: %v", value)
+	}
+
+	// the values encrypted with the current key are not updated again
+	if num, e := (settingSecretRotator{}).RotateSecrets(); e != nil || num != 0 {
+		t.Errorf("RotateSecrets() again = %d, %v, want 0", num, e)
+	}
+}
@@ -25,12 +25,7 @@ import (
 )
 
 type Syssetting struct {
-	// object name. the value should be "syssetting"
-	Name string
-	// table name which hold project data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[SysSettingSchema]
 }
 
 type SysSettingSchema struct {
@@ -57,6 +52,87 @@ type SysSettingSchema struct {
 	LastValue string `form:"lastValue" json:"lastValue" yaml:"lastValue" xml:"lastValue" db:"lastValue"`
 }
 
+// SettingRevisionSchema 配置项的修订记录，每次修改配置项都会增加一条修订记录
+type SettingRevisionSchema struct {
+	// 修订记录ID
+	ID uint `form:"id" json:"id" yaml:"id" xml:"id" db:"id"`
+	// 配置项ID
+	SettingID uint `form:"settingID" json:"settingID" yaml:"settingID" xml:"settingID" db:"settingID"`
+	// 修订号,同一配置项的修订号从1开始递增
+	Revision int `form:"revision" json:"revision" yaml:"revision" xml:"revision" db:"revision"`
+	// 配置项的应用范围
+	Scope int `form:"scope" json:"scope" yaml:"scope" xml:"scope" db:"scope"`
+	// 配置项所适用的对象ID
+	ObjectID string `form:"objectID" json:"objectID" yaml:"objectID" xml:"objectID" db:"objectID"`
+	// 配置的key值
+	Key string `form:"key" json:"key" yaml:"key" xml:"key" db:"key"`
+	// 本次修订后配置项的默认值
+	DefaultValue string `form:"defaultValue" json:"defaultValue" yaml:"defaultValue" xml:"defaultValue" db:"defaultValue"`
+	// 本次修订后配置项的值
+	Value string `form:"value" json:"value" yaml:"value" xml:"value" db:"value"`
+	// 修订的动作, 见RevisionAction*常量
+	Action string `form:"action" json:"action" yaml:"action" xml:"action" db:"action"`
+	// 动作为rollback时，回滚到的修订号
+	RollbackTo int `form:"rollbackTo" json:"rollbackTo" yaml:"rollbackTo" xml:"rollbackTo" db:"rollbackTo"`
+	// 修订配置项的用户,对应user表的userid.0表示系统自动设置的
+	ModifiedBy int `form:"modifiedBy" json:"modifiedBy" yaml:"modifiedBy" xml:"modifiedBy" db:"modifiedBy"`
+	// 修订的时间截
+	ModifiedTime int `form:"modifiedTime" json:"modifiedTime" yaml:"modifiedTime" xml:"modifiedTime" db:"modifiedTime"`
+	// 修订的原因
+	Reason string `form:"reason" json:"reason" yaml:"reason" xml:"reason" db:"reason"`
+}
+
+// RevisionDiffItem 两个修订之间不同的字段
+type RevisionDiffItem struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// SettingValueType 配置项值的类型
+type SettingValueType int
+
+// SettingDefinition 配置项的定义
+type SettingDefinition struct {
+	// 配置项的key值,大小写不敏感
+	Key string
+	// 配置项值的类型
+	Type SettingValueType
+	// 配置项在所有级别上都没有设置时使用的默认值
+	Default string
+	// 配置项允许设置的级别，为空时表示允许在所有级别上设置
+	Scopes []int
+	// 校验配置项的值，为nil时只校验值是否符合Type
+	Validate func(value string) error
+	// 配置项的值是否需要加密存储
+	Secret bool
+	// 配置项说明
+	Description string
+}
+
+// SettingContext 解析配置项生效值时的上下文，为空的ID表示不在该级别上查找
+type SettingContext struct {
+	UserID       string
+	UserGroupID  string
+	ProjectID    string
+	HostID       string
+	K8sClusterID string
+}
+
+// EffectiveValue 配置项的生效值及其来源
+type EffectiveValue struct {
+	// 配置项的key值
+	Key string
+	// 配置项值的类型
+	Type SettingValueType
+	// 生效的值
+	Value string
+	// 值所在的级别，SettingScopeDefault表示值来自于配置项定义中的默认值
+	Scope int
+	// 值所在级别的对象ID，全局级别和默认值时为空
+	ObjectID string
+}
+
 // 存储运行期数据
 type runingData struct {
 	dbConf        *sysadmDB.DbConfig
@@ -67,3 +143,6 @@ type runingData struct {
 	pageInfo      PageInfo
 	objectEntiy   sysadmObjects.ObjectEntity
 }
+
+// settingSecretRotator 重新加密加密存储的配置项及其修订的值
+type settingSecretRotator struct{}
@@ -0,0 +1,210 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sysadm/sysadmLog"
+	"time"
+)
+
+/*
+hot reload of settings:
+every change of a setting item is recorded as a revision in the revision table, and the IDs of the revisions are
+increasing. so a running daemon polls the revisions which ID is large than the last one it has seen, and calls the
+callbacks subscribed to the keys of the changed setting items. then the daemon can pick up new values without restart.
+*/
+
+// SettingChange is a change of a setting item
+type SettingChange struct {
+	Key          string
+	Scope        int
+	ObjectID     string
+	Value        string
+	DefaultValue string
+	Revision     int
+	Action       string
+}
+
+// SettingCallback is called when a setting item which has been subscribed is changed
+type SettingCallback func(change SettingChange)
+
+// subscription is a callback subscribed to a key
+type subscription struct {
+	id       int
+	key      string
+	callback SettingCallback
+}
+
+// SubscribeAllKeys can be used as the key of Subscribe to subscribe the changes of all setting items
+const SubscribeAllKeys = "*"
+
+var (
+	// subscriptions are the callbacks subscribed to setting items
+	subscriptions []subscription
+	// lastSubscriptionID is the ID of the last subscription
+	lastSubscriptionID int
+	// lastRevisionID is the ID of the last revision which has been dispatched to subscriptions
+	lastRevisionID uint
+	// watcherStarted is true if the watcher has been started
+	watcherStarted bool
+	// watchLock protects the variables above
+	watchLock sync.Mutex
+)
+
+// Subscribe subscribe callback to the changes of key. key is case insensitive, and SubscribeAllKeys means all keys.
+// the returned function can be called to unsubscribe it
+func Subscribe(key string, callback SettingCallback) func() {
+	key = strings.TrimSpace(strings.ToLower(key))
+	watchLock.Lock()
+	defer watchLock.Unlock()
+
+	lastSubscriptionID++
+	id := lastSubscriptionID
+	subscriptions = append(subscriptions, subscription{id: id, key: key, callback: callback})
+
+	return func() {
+		watchLock.Lock()
+		defer watchLock.Unlock()
+		for i, s := range subscriptions {
+			if s.id == id {
+				subscriptions = append(subscriptions[:i], subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// StartWatcher start a goroutine which polls the changes of settings every interval and dispatches them to the
+// subscriptions until stopCh is closed. only the changes made after the watcher started are dispatched
+func StartWatcher(interval time.Duration, stopCh <-chan struct{}) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval of setting watcher must be large than 0")
+	}
+
+	watchLock.Lock()
+	if watcherStarted {
+		watchLock.Unlock()
+		return fmt.Errorf("setting watcher has been started")
+	}
+	watchLock.Unlock()
+
+	latest, e := latestRevisionID()
+	if e != nil {
+		return e
+	}
+
+	watchLock.Lock()
+	watcherStarted = true
+	lastRevisionID = latest
+	watchLock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				watchLock.Lock()
+				watcherStarted = false
+				watchLock.Unlock()
+				return
+			case <-ticker.C:
+				if e := PollChanges(); e != nil && runData.logEntity != nil {
+					var errs []sysadmLog.Sysadmerror
+					errs = append(errs, sysadmLog.NewErrorWithStringLevel(700200011, "error", "poll changes of settings error %s", e))
+					runData.logEntity.LogErrors(errs)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// PollChanges get the changes of settings since the last poll and dispatch them to the subscriptions
+func PollChanges() error {
+	watchLock.Lock()
+	last := lastRevisionID
+	watchLock.Unlock()
+
+	conditions := make(map[string]string, 0)
+	conditions[revisionPkName] = ">'" + strconv.FormatUint(uint64(last), 10) + "'"
+	revisions, e := revisionRepository.List("", nil, nil, conditions, 0, 0, map[string]string{revisionPkName: "0"})
+	if e != nil {
+		return e
+	}
+
+	// the revision which values can not be decrypted is skipped, so it will not block the following revisions
+	var ret error = nil
+	for _, r := range revisions {
+		value, e := decryptSettingValue(r.Key, r.Value)
+		defaultValue, de := decryptSettingValue(r.Key, r.DefaultValue)
+		if e == nil {
+			e = de
+		}
+		if e != nil {
+			ret = fmt.Errorf("decrypt revision %d of setting item %s error: %s", r.Revision, r.Key, e)
+		} else {
+			dispatch(SettingChange{Key: r.Key, Scope: r.Scope, ObjectID: r.ObjectID, Value: value,
+				DefaultValue: defaultValue, Revision: r.Revision, Action: r.Action})
+		}
+
+		watchLock.Lock()
+		if r.ID > lastRevisionID {
+			lastRevisionID = r.ID
+		}
+		watchLock.Unlock()
+	}
+
+	return ret
+}
+
+// dispatch call the callbacks subscribed to the key of change
+func dispatch(change SettingChange) {
+	key := strings.ToLower(change.Key)
+	var callbacks []SettingCallback
+	watchLock.Lock()
+	for _, s := range subscriptions {
+		if s.key == key || s.key == SubscribeAllKeys {
+			callbacks = append(callbacks, s.callback)
+		}
+	}
+	watchLock.Unlock()
+
+	for _, callback := range callbacks {
+		callback(change)
+	}
+}
+
+// latestRevisionID return the ID of the latest revision of settings. 0 will be returned if there is no revision
+func latestRevisionID() (uint, error) {
+	revisions, e := revisionRepository.List("", nil, nil, nil, 0, 1, map[string]string{revisionPkName: "1"})
+	if e != nil {
+		return 0, e
+	}
+
+	if len(revisions) < 1 {
+		return 0, nil
+	}
+
+	return revisions[0].ID, nil
+}
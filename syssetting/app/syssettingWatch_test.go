@@ -0,0 +1,81 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"testing"
+)
+
+// resetWatcher reset the last revision which has been dispatched, and restore it after the test
+func resetWatcher(t *testing.T, last uint) {
+	t.Helper()
+	watchLock.Lock()
+	old := lastRevisionID
+	lastRevisionID = last
+	watchLock.Unlock()
+	t.Cleanup(func() {
+		watchLock.Lock()
+		lastRevisionID = old
+		watchLock.Unlock()
+	})
+}
+
+func TestPollChanges(t *testing.T) {
+	db := newFakeDB(t)
+	insertTestRevision(db, 1, 7, 1, "", "10", RevisionActionCreate)
+	latest, e := latestRevisionID()
+	if e != nil || latest != 1 {
+		t.Fatalf("latestRevisionID() = %d, %v, want 1", latest, e)
+	}
+	resetWatcher(t, latest)
+
+	var changes, all []SettingChange
+	unsubscribe := Subscribe("Test.Revision", func(c SettingChange) { changes = append(changes, c) })
+	defer unsubscribe()
+	unsubscribeAll := Subscribe(SubscribeAllKeys, func(c SettingChange) { all = append(all, c) })
+	defer unsubscribeAll()
+
+	insertTestRevision(db, 3, 7, 3, "", "30", RevisionActionRollback)
+	insertTestRevision(db, 2, 7, 2, "", "20", RevisionActionUpdate)
+	db.insert(revisionTableName, map[string]interface{}{"id": 4, "settingID": 8, "revision": 1, "scope": SettingScopeProject,
+		"objectID": "3", "key": "test.other", "value": "on", "action": RevisionActionCreate})
+	if e := PollChanges(); e != nil {
+		t.Fatalf("poll changes error: %s", e)
+	}
+
+	// only the changes made after the last poll are dispatched, in the order of the revisions
+	if len(changes) != 2 || changes[0].Value != "20" || changes[1].Value != "30" ||
+		changes[1].Action != RevisionActionRollback {
+		t.Errorf("changes of test.revision = %+v", changes)
+	}
+	if len(all) != 3 || all[2].Key != "test.other" || all[2].ObjectID != "3" {
+		t.Errorf("changes of all keys = %+v", all)
+	}
+
+	unsubscribe()
+	insertTestRevision(db, 5, 7, 4, "", "40", RevisionActionUpdate)
+	if e := PollChanges(); e != nil {
+		t.Fatalf("poll changes error: %s", e)
+	}
+	if len(changes) != 2 {
+		t.Errorf("the callback which has been unsubscribed should not be called")
+	}
+	if len(all) != 4 || all[3].Revision != 4 {
+		t.Errorf("changes of all keys = %+v", all)
+	}
+}
@@ -0,0 +1,274 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+)
+
+// fakeDriverName 测试用的数据库驱动的名称，该驱动记录执行的SQL语句，并由测试用例提供查询的结果
+const fakeDriverName = "userfake"
+
+var (
+	fakeDBLock sync.Mutex
+	fakeDBs    = make(map[string]*fakeDB, 0)
+)
+
+// fakeTablePattern 获取查询语句中的表名
+var fakeTablePattern = regexp.MustCompile("from `([^`]+)`")
+
+func init() {
+	sql.Register(fakeDriverName, fakeDriver{})
+}
+
+// fakeDB 测试用的数据库。语句由MySQL实体生成，因此测试用例可以检查实际执行的SQL语句
+type fakeDB struct {
+	lock       sync.Mutex
+	statements []string
+	// tables 各表的数据，查询语句返回表中满足"字段='值'"条件的行
+	tables map[string][]map[string]interface{}
+	// primary 各表的查询是否都发送到了主库
+	primary map[string]bool
+}
+
+// fakeEntity 在MySQL实体的基础上记录查询是否发送到主库
+type fakeEntity struct {
+	sysadmDB.MySQL
+	db *fakeDB
+}
+
+func (f fakeEntity) NewQueryData(sd *sysadmDB.SelectData) ([]map[string]interface{}, error) {
+	f.db.lock.Lock()
+	for _, tb := range sd.Tb {
+		if onPrimary, ok := f.db.primary[tb]; !ok || onPrimary {
+			f.db.primary[tb] = sd.Primary
+		}
+	}
+	f.db.lock.Unlock()
+
+	return f.MySQL.NewQueryData(sd)
+}
+
+// newFakeDB 将测试用的数据库设置为本包及对象包使用的数据库，测试结束后恢复
+func newFakeDB(t *testing.T) *fakeDB {
+	t.Helper()
+	db := &fakeDB{tables: make(map[string][]map[string]interface{}, 0), primary: make(map[string]bool, 0)}
+	fakeDBLock.Lock()
+	name := fmt.Sprintf("%s/%d", t.Name(), len(fakeDBs))
+	fakeDBs[name] = db
+	fakeDBLock.Unlock()
+
+	conn, e := sql.Open(fakeDriverName, name)
+	if e != nil {
+		t.Fatalf("open fake DB error: %s", e)
+	}
+	conf := &sysadmDB.DbConfig{Type: "mysql", Connect: conn}
+	conf.Entity = fakeEntity{MySQL: sysadmDB.MySQL{Config: conf}, db: db}
+
+	old, oldObjects := runData.dbConf, sysadmObjects.GetRunDataForDBConf()
+	runData.dbConf = conf
+	_ = sysadmObjects.SetRunDataForDBConf(conf)
+	t.Cleanup(func() {
+		runData.dbConf = old
+		if oldObjects != nil {
+			_ = sysadmObjects.SetRunDataForDBConf(oldObjects)
+		}
+		_ = conn.Close()
+		fakeDBLock.Lock()
+		delete(fakeDBs, name)
+		fakeDBLock.Unlock()
+	})
+
+	return db
+}
+
+// insert 向表tb中添加数据
+func (f *fakeDB) insert(tb string, rows ...map[string]interface{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.tables[tb] = append(f.tables[tb], rows...)
+}
+
+// executed 返回以prefix开头的已执行的语句
+func (f *fakeDB) executed(prefix string) []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var ret []string
+	for _, s := range f.statements {
+		if strings.HasPrefix(s, prefix) {
+			ret = append(ret, s)
+		}
+	}
+
+	return ret
+}
+
+// deleted 返回删除表tb中数据的各条语句的"字段='值'"条件
+func (f *fakeDB) deleted(tb string) []map[string]string {
+	var ret []map[string]string
+	for _, s := range f.executed("delete from `" + tb + "` ") {
+		conditions := make(map[string]string, 0)
+		for _, c := range fakeCondition.FindAllStringSubmatch(s, -1) {
+			conditions[c[1]] = c[2]
+		}
+		ret = append(ret, conditions)
+	}
+
+	return ret
+}
+
+// onPrimary 返回表tb的查询是否都发送到了主库
+func (f *fakeDB) onPrimary(tb string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.primary[tb]
+}
+
+// fakeCondition 匹配查询语句中的"字段='值'"条件
+var fakeCondition = regexp.MustCompile("`?([a-zA-Z_]+)`?\\s*=\\s*'([^']*)'")
+
+func (f *fakeDB) query(query string) (*fakeRows, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.statements = append(f.statements, query)
+
+	m := fakeTablePattern.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("table of query %s is not found", query)
+	}
+	rows := &fakeRows{}
+	// 序列总是返回下一个ID为1
+	if strings.HasPrefix(query, "select nextValue from") {
+		rows.columns = []string{"nextValue"}
+		rows.data = [][]driver.Value{{int64(1)}}
+		return rows, nil
+	}
+	if strings.HasPrefix(query, "select count(") {
+		rows.columns = []string{"num"}
+	}
+
+	var matched []map[string]interface{}
+	for _, row := range f.tables[m[1]] {
+		ok := true
+		for _, c := range fakeCondition.FindAllStringSubmatch(query, -1) {
+			if v, found := row[c[1]]; found && fmt.Sprint(v) != c[2] {
+				ok = false
+			}
+		}
+		if ok {
+			matched = append(matched, row)
+		}
+	}
+
+	if rows.columns != nil {
+		rows.data = [][]driver.Value{{int64(len(matched))}}
+		return rows, nil
+	}
+	for _, row := range matched {
+		var values []driver.Value
+		if rows.columns == nil {
+			for k := range row {
+				rows.columns = append(rows.columns, k)
+			}
+		}
+		for _, k := range rows.columns {
+			values = append(values, row[k])
+		}
+		rows.data = append(rows.data, values)
+	}
+
+	return rows, nil
+}
+
+func (f *fakeDB) exec(query string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.statements = append(f.statements, query)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBLock.Lock()
+	defer fakeDBLock.Unlock()
+	db, ok := fakeDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("DB %s does not exist", name)
+	}
+
+	return fakeConn{db: db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{db: c.db, query: query}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.db.exec(s.query)
+	return driver.RowsAffected(1), nil
+}
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return s.db.query(s.query)
+}
+
+type fakeRows struct {
+	columns []string
+	data    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.next])
+	r.next++
+
+	return nil
+}
@@ -0,0 +1,283 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+)
+
+// identityReplacer 去除外部认证源返回的用户信息中不能写入数据库的字符
+var identityReplacer = strings.NewReplacer("\"", "", "\\", "")
+
+// SetAuthenticators 设置外部认证源。用户登录时先使用本地用户认证，本地用户不存在时依次尝试外部认证源
+func SetAuthenticators(authenticators ...Authenticator) {
+	runData.authenticators = authenticators
+}
+
+// Authenticate 认证用户并返回用户信息。ok为true时表示认证通过，此时e不为nil表示认证通过后的处理出错或密码已过期(ErrPasswordExpired)。
+// 外部认证源的用户首次登录时自动创建本地用户，以后每次登录时同步用户信息及其所属的用户组
+func Authenticate(username, password string) (user UserSchema, ok bool, e error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return user, false, ErrAuthenticationFailed
+	}
+
+	user, found, e := getUserByName(username)
+	if e != nil {
+		return user, false, e
+	}
+
+	// 服务帐号没有密码，只能使用API令牌认证
+	if found && user.AuthSource == AuthSourceServiceAccount {
+		return user, false, ErrAuthenticationFailed
+	}
+
+	if found && (user.AuthSource == "" || user.AuthSource == AuthSourceLocal) {
+		ok, e = New().VerifyPassword(user, password)
+		if !ok && e == nil {
+			e = ErrAuthenticationFailed
+		}
+		return user, ok, e
+	}
+
+	var lastErr error = ErrAuthenticationFailed
+	for _, a := range runData.authenticators {
+		identity, e := a.Authenticate(username, password)
+		if e != nil {
+			if !errors.Is(e, ErrAuthenticationFailed) {
+				lastErr = fmt.Errorf("authenticate user %s with %s error: %s", username, a.Name(), e)
+			}
+			continue
+		}
+
+		// 外部认证源不能登录认证来源不同的同名用户
+		if found && user.AuthSource != a.Name() {
+			continue
+		}
+
+		user, e = provisionUser(user, found, a.Name(), identity)
+		if e != nil {
+			return user, false, fmt.Errorf("provision user %s from %s error: %s", username, a.Name(), e)
+		}
+
+		return user, true, nil
+	}
+
+	return user, false, lastErr
+}
+
+// LoginExternal 使用外部认证源(如OIDC)已认证的用户信息登录。用户首次登录时自动创建本地用户，以后每次登录时同步用户信息及其所属的用户组。
+// 用户按外部标识绑定本地用户，外部认证源不能登录未绑定该外部标识的同名用户
+func LoginExternal(source string, identity Identity) (UserSchema, error) {
+	identity.Username = strings.TrimSpace(identity.Username)
+	// 外部标识按保存时的方式处理后再查找绑定的用户
+	identity.ExternalID = identityReplacer.Replace(strings.TrimSpace(identity.ExternalID))
+	if identity.Username == "" || identity.ExternalID == "" {
+		return UserSchema{}, fmt.Errorf("username or external ID of the user authenticated by %s is empty", source)
+	}
+
+	user, found, e := getUserByExternalID(source, identity.ExternalID)
+	if e != nil {
+		return user, e
+	}
+	if found {
+		return provisionUser(user, true, source, identity)
+	}
+
+	user, found, e = getUserByName(identity.Username)
+	if e != nil {
+		return user, e
+	}
+	if found {
+		return user, fmt.Errorf("user %s has been exist with auth source %s, it can not be bound to %s user %s", identity.Username, user.AuthSource, source, identity.ExternalID)
+	}
+
+	return provisionUser(user, false, source, identity)
+}
+
+// getUserByExternalID 获取认证来源为source，外部标识为externalID且未被删除的用户
+func getUserByExternalID(source, externalID string) (UserSchema, bool, error) {
+	conditions := make(map[string]string, 0)
+	conditions["auth_source"] = "=" + sysadmObjects.QuoteString(source)
+	conditions["external_id"] = "=" + sysadmObjects.QuoteString(externalID)
+	conditions["deleted"] = "=0"
+	users, e := New().List("", nil, nil, conditions, 0, 1, nil)
+	if e != nil || len(users) < 1 {
+		return UserSchema{}, false, e
+	}
+
+	return users[0], true, nil
+}
+
+// getUserByName 获取用户名为username且未被删除的用户
+func getUserByName(username string) (UserSchema, bool, error) {
+	conditions := make(map[string]string, 0)
+	conditions["username"] = "=" + sysadmObjects.QuoteString(username)
+	conditions["deleted"] = "=0"
+	users, e := New().List("", nil, nil, conditions, 0, 1, nil)
+	if e != nil || len(users) < 1 {
+		return UserSchema{}, false, e
+	}
+
+	return users[0], true, nil
+}
+
+// provisionUser 使用外部认证源返回的用户信息创建或更新本地用户，并同步用户所属的用户组
+func provisionUser(user UserSchema, found bool, source string, identity Identity) (UserSchema, error) {
+	u := New()
+	now := time.Now().Unix()
+	email := identityReplacer.Replace(strings.TrimSpace(identity.Email))
+	realname := identityReplacer.Replace(strings.TrimSpace(identity.Realname))
+	if realname == "" {
+		realname = identity.Username
+	}
+	sysadminFlag := 0
+	if identity.Sysadmin {
+		sysadminFlag = 1
+	}
+
+	if found {
+		updateData := make(sysadmDB.FieldData, 0)
+		updateData["email"] = sysadmObjects.QuoteString(email)
+		updateData["realname"] = sysadmObjects.QuoteString(realname)
+		updateData["sysadmin_flag"] = sysadminFlag
+		updateData["update_time"] = now
+		where := map[string]string{u.PkName: user.Id}
+		if e := runData.dbConf.Entity.NewUpdateData(u.TableName, updateData, where); e != nil {
+			return user, e
+		}
+	} else {
+		tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, u)
+		if e != nil {
+			return user, e
+		}
+
+		id, e := tx.Tx.NextID(u.TableName, u.PkName)
+		if e != nil {
+			_ = tx.Rollback()
+			return user, e
+		}
+
+		user.Id = strconv.FormatUint(id, 10)
+		user.Username = identityReplacer.Replace(strings.TrimSpace(identity.Username))
+		user.CreationTime = int(now)
+		user.AuthSource = source
+		user.ExternalID = identity.ExternalID
+		// 外部认证源的用户没有本地密码
+		insertData := sysadmDB.FieldData{
+			u.PkName:        user.Id,
+			"username":      user.Username,
+			"password":      "",
+			"salt":          "",
+			"realname":      realname,
+			"creation_time": now,
+			"update_time":   now,
+			"auth_source":   source,
+			"external_id":   identity.ExternalID,
+		}
+		if e := tx.Tx.NewInsertData(u.TableName, insertData); e != nil {
+			_ = tx.Rollback()
+			return user, e
+		}
+
+		if e := tx.Commit(); e != nil {
+			return user, e
+		}
+	}
+
+	user.Email, user.Realname, user.SysadminFlag, user.UpdateTime = email, realname, sysadminFlag, int(now)
+
+	return user, syncUserGroups(user.Id, identity.Groups, identity.ManagedGroups)
+}
+
+// syncUserGroups 将用户加入groups中的用户组，用户组不存在时自动创建。并将用户从不在groups中的managedGroups中移除
+func syncUserGroups(userid string, groups, managedGroups []string) error {
+	existGroups, e := ListGroups()
+	if e != nil {
+		return e
+	}
+
+	groupIDs := make(map[string]string, 0)
+	for _, g := range existGroups {
+		groupIDs[g.Name] = strconv.Itoa(int(g.Id))
+	}
+
+	in := make(map[string]bool, 0)
+	for _, name := range groups {
+		in[name] = true
+		id, ok := groupIDs[name]
+		if !ok {
+			newID, e := AddGroup(name, "created automatically by external authentication source")
+			if e != nil {
+				return e
+			}
+			id = strconv.Itoa(int(newID))
+			groupIDs[name] = id
+		}
+
+		if e := AddGroupMember(id, userid); e != nil {
+			return e
+		}
+	}
+
+	for _, name := range managedGroups {
+		id, ok := groupIDs[name]
+		if !ok || in[name] {
+			continue
+		}
+		if e := RemoveGroupMember(id, userid); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// mapIdentityGroups 按照组映射关系设置用户所属的本地用户组及是否为系统管理员，组名称使用normalize规范化后进行比较
+func mapIdentityGroups(identity *Identity, groups []string, mapping map[string]string, adminGroups []string, normalize func(string) string) {
+	member := make(map[string]bool, 0)
+	for _, g := range groups {
+		member[normalize(g)] = true
+	}
+
+	local := make(map[string]bool, 0)
+	for externalGroup, localGroup := range mapping {
+		if _, ok := local[localGroup]; !ok {
+			local[localGroup] = false
+			identity.ManagedGroups = append(identity.ManagedGroups, localGroup)
+		}
+		if member[normalize(externalGroup)] && !local[localGroup] {
+			local[localGroup] = true
+			identity.Groups = append(identity.Groups, localGroup)
+		}
+	}
+
+	for _, g := range adminGroups {
+		if member[normalize(g)] {
+			identity.Sysadmin = true
+			break
+		}
+	}
+}
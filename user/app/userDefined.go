@@ -17,9 +17,208 @@
 
 package app
 
+import "errors"
+
 var DefaultObjectName = "user"
 var DefaultTableName = "user"
 var DefaultPkName = "userid"
 var DefaultModuleName = "user"
 var DefaultApiVersion = "1.0"
 var runData = runingData{}
+
+// 用户历史密码表
+var passwordHistoryObjectName = "userpasswordhistory"
+var passwordHistoryTableName = "userPasswordHistory"
+var passwordHistoryPkName = "id"
+
+// 用户表password字段的长度。旧表结构中该字段为varchar(40)，只能保存md5密码
+const passwordFieldLength = 255
+
+// 用户表password字段除类型外的定义，加宽该字段时使用
+const passwordFieldDefinition = "NOT NULL COMMENT 'user password. it is prefixed with the hashing algorithm except the old md5 passwords'"
+
+// 密码策略配置项
+const (
+	SettingKeyForPasswordMinLength  = "passwordminlength"
+	SettingKeyForPasswordComplexity = "passwordcomplexity"
+	SettingKeyForPasswordHistory    = "passwordhistory"
+	SettingKeyForPasswordMaxAge     = "passwordmaxage"
+	// 系统管理员是否必须启用TOTP
+	SettingKeyForTotpRequiredSysadmin = "totprequiredsysadmin"
+	// 用户是否必须启用TOTP，可以在全局，用户组及用户级别上设置
+	SettingKeyForTotpRequired = "totprequired"
+	// API令牌允许的最长有效天数，0表示不限制
+	SettingKeyForApiTokenMaxDays = "apitokenmaxdays"
+
+	DefaultPasswordMinLength  = 8
+	DefaultPasswordComplexity = 3
+	DefaultPasswordHistory    = 5
+	DefaultPasswordMaxAge     = 90
+	DefaultApiTokenMaxDays    = 365
+)
+
+// ErrPasswordExpired 用户密码已超过密码策略规定的有效天数
+var ErrPasswordExpired = errors.New("password has expired")
+
+// 用户组及用户组成员表
+var groupObjectName = "usergroup"
+var groupTableName = "userGroup"
+var groupPkName = "groupid"
+var groupMemberObjectName = "usergroupmember"
+var groupMemberTableName = "userGroupMember"
+var groupMemberPkName = "id"
+
+// 项目成员表
+var projectMemberObjectName = "projectmember"
+var projectMemberTableName = "projectMember"
+var projectMemberPkName = "id"
+
+// 项目成员的类型
+const (
+	MemberTypeUser  = 1
+	MemberTypeGroup = 2
+)
+
+// 项目成员的角色
+const (
+	ProjectRoleOwner      = "owner"
+	ProjectRoleMaintainer = "maintainer"
+	ProjectRoleViewer     = "viewer"
+)
+
+// 对项目的操作权限
+const (
+	// 查看项目及拉取项目中的镜像
+	PermissionPull = "pull"
+	// 向项目中推送镜像
+	PermissionPush = "push"
+	// 删除项目中的镜像
+	PermissionDelete = "delete"
+	// 管理项目的成员
+	PermissionManage = "manage"
+)
+
+// roleLevels 角色的级别，用户通过多个途径成为项目成员时以级别最高的角色为准
+var roleLevels = map[string]int{
+	ProjectRoleViewer:     1,
+	ProjectRoleMaintainer: 2,
+	ProjectRoleOwner:      3,
+}
+
+// rolePermissions 各角色拥有的权限
+var rolePermissions = map[string][]string{
+	ProjectRoleViewer:     {PermissionPull},
+	ProjectRoleMaintainer: {PermissionPull, PermissionPush},
+	ProjectRoleOwner:      {PermissionPull, PermissionPush, PermissionDelete, PermissionManage},
+}
+
+// 用户的认证来源
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLdap  = "ldap"
+	AuthSourceOidc  = "oidc"
+	// 服务帐号不是自然人使用的帐号，没有密码，只能使用API令牌认证
+	AuthSourceServiceAccount = "serviceaccount"
+)
+
+// ErrAuthenticationFailed 用户名或密码错误
+var ErrAuthenticationFailed = errors.New("username or password incorrect")
+
+// LDAP认证源的默认配置
+const (
+	DefaultLdapUsernameAttr  = "uid"
+	DefaultLdapEmailAttr     = "mail"
+	DefaultLdapRealnameAttr  = "cn"
+	DefaultLdapMemberOfAttr  = "memberOf"
+	DefaultLdapGroupNameAttr = "cn"
+	DefaultLdapTimeout       = 10
+)
+
+// OIDC认证源的默认配置
+const (
+	DefaultOidcUsernameClaim = "preferred_username"
+	DefaultOidcEmailClaim    = "email"
+	DefaultOidcRealnameClaim = "name"
+	DefaultOidcGroupsClaim   = "groups"
+	DefaultOidcTimeout       = 10
+	// 签名密钥未找到时重新获取签名密钥的最小间隔，单位秒
+	oidcKeysRefreshInterval = 10
+	// 校验ID Token的有效时间时允许的时钟偏差，单位秒
+	oidcClockSkew = 60
+)
+
+// DefaultOidcScopes OIDC认证时默认请求的scope
+var DefaultOidcScopes = []string{"openid", "profile", "email"}
+
+// TOTP恢复码
+var recoveryCodeObjectName = "userRecoveryCode"
+var recoveryCodeTableName = "userRecoveryCode"
+var recoveryCodePkName = "id"
+
+// TOTP(RFC 6238)的参数
+const (
+	// 认证器中显示的发行者名称
+	DefaultTotpIssuer = "sysadm"
+	// 密钥的字节数
+	totpSecretSize = 20
+	// 验证码的位数
+	totpDigits = 6
+	// 时间步长，单位秒
+	totpPeriod = 30
+	// 校验验证码时允许前后偏差的时间步数
+	totpSkew = 1
+	// 每次生成的恢复码的数量
+	recoveryCodeNum = 10
+	// 恢复码的字符数，不包括分隔符
+	recoveryCodeLength = 10
+	// 没有会话的登录(如API登录)在totpAttemptsWindow秒内最多可以尝试的第二因素的次数，与Web登录等待第二因素时的限制一致
+	totpMaxAttempts    = 5
+	totpAttemptsWindow = 300
+)
+
+// ErrTotpCodeIncorrect TOTP验证码或恢复码错误
+var ErrTotpCodeIncorrect = errors.New("totp code incorrect")
+
+// ErrTotpAttemptsExceeded 尝试第二因素的次数超过了限制
+var ErrTotpAttemptsExceeded = errors.New("too many totp attempts")
+
+// API令牌表
+var apiTokenObjectName = "userApiToken"
+var apiTokenTableName = "userApiToken"
+var apiTokenPkName = "id"
+
+// API令牌的权限范围
+const (
+	// 调用sysadm的API
+	ApiTokenScopeApi = "api"
+	// 调用apiserver的资源API
+	ApiTokenScopeApiserver = "apiserver"
+	// 作为镜像仓库的密码拉取镜像
+	ApiTokenScopeRegistryPull = "registry:pull"
+	// 作为镜像仓库的密码拉取，推送及删除镜像，仍受用户在项目中的角色限制
+	ApiTokenScopeRegistry = "registry"
+)
+
+// ApiTokenScopes 所有有效的API令牌权限范围
+var ApiTokenScopes = []string{ApiTokenScopeApi, ApiTokenScopeApiserver, ApiTokenScopeRegistryPull, ApiTokenScopeRegistry}
+
+const (
+	// API令牌明文的前缀，用于区分API令牌与密码
+	ApiTokenPrefix = "sat_"
+	// API令牌随机部分的字节数
+	apiTokenSize = 32
+	// 列表中显示的令牌明文的前几个字符，用于识别令牌
+	apiTokenDisplayLength = 12
+	// 令牌名称的最大长度
+	apiTokenNameMaxLength = 64
+	// 更新令牌最后使用时间的最小间隔，单位秒，避免每个请求都写数据库
+	apiTokenLastUsedInterval = 60
+	// 请求上下文中保存使用API令牌认证的用户的键
+	apiTokenUserKey = "apiTokenUser"
+)
+
+// ErrApiTokenInvalid API令牌不存在，已被撤销，已过期或其所属的用户已被删除
+var ErrApiTokenInvalid = errors.New("api token invalid")
+
+// ErrApiTokenScope API令牌没有访问请求的资源的权限范围
+var ErrApiTokenScope = errors.New("api token has not the scope")
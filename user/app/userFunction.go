@@ -18,125 +18,9 @@
 package app
 
 import (
-	"fmt"
-	"strings"
 	sysadmObjects "sysadm/objects/app"
 )
 
 func New() User {
-	ret := User{}
-	ret.Name = DefaultObjectName
-	ret.TableName = DefaultTableName
-	ret.PkName = DefaultPkName
-	return ret
-}
-
-func (u User) GetObjectInfoByID(id string) (interface{}, error) {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return nil, fmt.Errorf("system can not get user information with empty ID")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(u.TableName, u.PkName, id)
-	if e != nil {
-		return nil, e
-	}
-
-	userData := UserSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &userData)
-
-	return userData, e
-}
-
-func (u User) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &UserSchema{}); !ok {
-		return -1, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &UserSchema{}); !ok {
-		return -1, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	return sysadmObjects.GetObjectCount(u.TableName, u.PkName, searchContent, ids, searchKeys, conditions)
-}
-
-func (u User) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
-	startPos, step int, orders map[string]string) ([]interface{}, error) {
-
-	var ret []interface{}
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &UserSchema{}); !ok {
-		return ret, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &UserSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	var orderKeys []string
-	for k, _ := range orders {
-		orderKeys = append(orderKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(orderKeys, &UserSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
-	}
-
-	dbData, e := sysadmObjects.GetObjectList(u.TableName, u.PkName, searchContent, ids, searchKeys, conditions, startPos, step, orders)
-	if e != nil {
-		return ret, e
-	}
-
-	var tmpRes []interface{}
-	for _, v := range dbData {
-		user := &UserSchema{}
-		if e := sysadmObjects.Unmarshal(v, user); e != nil {
-			return ret, e
-		}
-		tmpRes = append(tmpRes, *user)
-	}
-
-	return tmpRes, nil
-}
-
-func (u User) AddObject(data interface{}) error {
-	userSchemaData, ok := data.(UserSchema)
-	if !ok {
-		return fmt.Errorf("the data try to add is not valid")
-	}
-
-	insertData, e := sysadmObjects.Marshal(userSchemaData)
-	if e != nil {
-		return e
-	}
-
-	return sysadmObjects.AddObject(u.TableName, "", insertData)
-}
-
-func (u User) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
-	addData := make(map[string]interface{}, 0)
-
-	schemaData, ok := data.(UserSchema)
-	if !ok {
-		return addData, "", fmt.Errorf("there is an error occurred when coverting data to User Schema schema")
-	}
-
-	addData, e := sysadmObjects.Marshal(schemaData)
-	if e != nil {
-		return addData, "", e
-	}
-
-	return addData, u.TableName, nil
-}
-
-func (u User) GetObjectIDFieldName() (string, string, error) {
-	return u.TableName, u.PkName, nil
+	return User{Repository: sysadmObjects.NewRepository[UserSchema](DefaultObjectName, DefaultTableName, DefaultPkName)}
 }
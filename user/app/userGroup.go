@@ -0,0 +1,197 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+)
+
+// groupRepository 用户组
+var groupRepository = sysadmObjects.NewRepository[UserGroupSchema](groupObjectName, groupTableName, groupPkName)
+
+// groupMemberRepository 用户组成员
+var groupMemberRepository = sysadmObjects.NewRepository[UserGroupMemberSchema](groupMemberObjectName, groupMemberTableName, groupMemberPkName)
+
+// AddGroup 添加名称为name的用户组，返回新用户组的ID
+func AddGroup(name, comment string) (uint, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("name of user group should not be empty")
+	}
+
+	conditions := make(map[string]string, 0)
+	conditions["name"] = "=" + sysadmObjects.QuoteString(name)
+	groups, e := groupRepository.List("", nil, nil, conditions, 0, 1, nil)
+	if e != nil {
+		return 0, e
+	}
+	if len(groups) > 0 {
+		return 0, fmt.Errorf("user group %s has exist", name)
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, nil)
+	if e != nil {
+		return 0, e
+	}
+
+	id, e := tx.Tx.NextID(groupTableName, groupPkName)
+	if e != nil {
+		_ = tx.Rollback()
+		return 0, e
+	}
+
+	now := int(time.Now().Unix())
+	group := UserGroupSchema{Id: uint(id), Name: name, Comment: strings.TrimSpace(comment), CreationTime: now, UpdateTime: now}
+	if e := groupRepository.CreateTx(tx, group); e != nil {
+		_ = tx.Rollback()
+		return 0, e
+	}
+
+	return uint(id), tx.Commit()
+}
+
+// DeleteGroup 删除用户组groupid，同时删除用户组的成员及该用户组在各项目中的成员身份
+func DeleteGroup(groupid string) error {
+	groupid = strings.TrimSpace(groupid)
+	if _, e := groupRepository.Get(groupid); e != nil {
+		return fmt.Errorf("user group %s is not exist", groupid)
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, nil)
+	if e != nil {
+		return e
+	}
+
+	// 删除语句会为条件的值加上引号，因此条件中使用不带引号的值
+	members := sysadmDB.SelectData{
+		Tb:    []string{groupMemberTableName},
+		Where: map[string]string{"groupid": groupid},
+	}
+	projectMembers := sysadmDB.SelectData{
+		Tb:    []string{projectMemberTableName},
+		Where: map[string]string{"member_type": strconv.Itoa(MemberTypeGroup), "memberid": groupid},
+	}
+	group := sysadmDB.SelectData{
+		Tb:    []string{groupTableName},
+		Where: map[string]string{groupPkName: groupid},
+	}
+	for _, dd := range []sysadmDB.SelectData{members, projectMembers, group} {
+		dd := dd
+		if e := tx.Tx.NewDeleteData(&dd); e != nil {
+			_ = tx.Rollback()
+			return e
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListGroups 获取全部用户组
+func ListGroups() ([]UserGroupSchema, error) {
+	return groupRepository.List("", nil, nil, nil, 0, 0, map[string]string{"name": "0"})
+}
+
+// AddGroupMember 将用户userid加入用户组groupid
+func AddGroupMember(groupid, userid string) error {
+	groupid = strings.TrimSpace(groupid)
+	userid = strings.TrimSpace(userid)
+	if _, e := groupRepository.Get(groupid); e != nil {
+		return fmt.Errorf("user group %s is not exist", groupid)
+	}
+
+	if _, e := New().Get(userid); e != nil {
+		return fmt.Errorf("user %s is not exist", userid)
+	}
+
+	members, e := groupMemberRepository.List("", nil, nil, groupMemberConditions(groupid, userid), 0, 1, nil)
+	if e != nil {
+		return e
+	}
+	if len(members) > 0 {
+		return nil
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, nil)
+	if e != nil {
+		return e
+	}
+
+	id, e := tx.Tx.NextID(groupMemberTableName, groupMemberPkName)
+	if e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	member := UserGroupMemberSchema{Id: uint(id), GroupID: groupid, Userid: userid, CreationTime: int(time.Now().Unix())}
+	if e := groupMemberRepository.CreateTx(tx, member); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// RemoveGroupMember 将用户userid从用户组groupid中移除
+func RemoveGroupMember(groupid, userid string) error {
+	dd := sysadmDB.SelectData{
+		Tb:    []string{groupMemberTableName},
+		Where: map[string]string{"groupid": strings.TrimSpace(groupid), "userid": strings.TrimSpace(userid)},
+	}
+
+	return runData.dbConf.Entity.NewDeleteData(&dd)
+}
+
+// ListGroupMembers 获取用户组groupid的全部成员
+func ListGroupMembers(groupid string) ([]UserGroupMemberSchema, error) {
+	conditions := make(map[string]string, 0)
+	conditions["groupid"] = "=" + sysadmObjects.QuoteString(strings.TrimSpace(groupid))
+
+	return groupMemberRepository.List("", nil, nil, conditions, 0, 0, nil)
+}
+
+// GetUserGroupIDs 获取用户userid所属的全部用户组的ID。用户组用于权限判断，从主库读取，避免从库延迟导致刚被移出用户组的用户仍然拥有用户组的权限
+func GetUserGroupIDs(userid string) ([]string, error) {
+	var ret []string
+	conditions := make(map[string]string, 0)
+	conditions["userid"] = "=" + sysadmObjects.QuoteString(strings.TrimSpace(userid))
+	members, e := groupMemberRepository.OnPrimary().List("", nil, nil, conditions, 0, 0, nil)
+	if e != nil {
+		return ret, e
+	}
+
+	for _, m := range members {
+		ret = append(ret, m.GroupID)
+	}
+
+	return ret, nil
+}
+
+// groupMemberConditions 查询用户组groupid中用户userid的条件
+func groupMemberConditions(groupid, userid string) map[string]string {
+	conditions := make(map[string]string, 0)
+	conditions["groupid"] = "=" + sysadmObjects.QuoteString(strings.TrimSpace(groupid))
+	conditions["userid"] = "=" + sysadmObjects.QuoteString(strings.TrimSpace(userid))
+
+	return conditions
+}
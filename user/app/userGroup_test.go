@@ -0,0 +1,58 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDeleteGroup(t *testing.T) {
+	db := newFakeDB(t)
+	db.insert("userGroup", map[string]interface{}{"groupid": "3", "name": "dev"})
+
+	if e := DeleteGroup(" 3 "); e != nil {
+		t.Fatalf("delete group 3 error: %s", e)
+	}
+
+	// 条件的值只能被加上一次引号
+	tests := map[string]map[string]string{
+		"userGroupMember": {"groupid": "3"},
+		"projectMember":   {"member_type": strconv.Itoa(MemberTypeGroup), "memberid": "3"},
+		"userGroup":       {"groupid": "3"},
+	}
+	for tb, want := range tests {
+		if got := db.deleted(tb); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+			t.Errorf("rows of table %s should be deleted by %v, got %v in %v", tb, want, got, db.executed("delete"))
+		}
+	}
+}
+
+func TestRemoveGroupMember(t *testing.T) {
+	db := newFakeDB(t)
+
+	if e := RemoveGroupMember("3", " 5"); e != nil {
+		t.Fatalf("remove user 5 from group 3 error: %s", e)
+	}
+
+	want := map[string]string{"groupid": "3", "userid": "5"}
+	if got := db.deleted("userGroupMember"); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("member should be deleted by %v, got %v in %v", want, got, db.executed("delete"))
+	}
+}
@@ -0,0 +1,214 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// NewLdapAuthenticator 校验LDAP/AD认证源的配置，未设置的属性名称使用默认值
+func NewLdapAuthenticator(conf LdapConfig) (*LdapAuthenticator, error) {
+	conf.Url = strings.TrimSpace(conf.Url)
+	u, e := url.Parse(conf.Url)
+	if e != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, fmt.Errorf("ldap url %s is not valid", conf.Url)
+	}
+	if conf.StartTLS && u.Scheme == "ldaps" {
+		return nil, fmt.Errorf("starttls can not be used with ldaps url %s", conf.Url)
+	}
+
+	if strings.TrimSpace(conf.UserBaseDN) == "" {
+		return nil, fmt.Errorf("user base dn of ldap must be set")
+	}
+	if strings.Count(conf.UserFilter, "%s") != 1 {
+		return nil, fmt.Errorf("user filter %s of ldap must contain one %%s", conf.UserFilter)
+	}
+	if conf.GroupBaseDN != "" && strings.Count(conf.GroupFilter, "%s") != 1 {
+		return nil, fmt.Errorf("group filter %s of ldap must contain one %%s", conf.GroupFilter)
+	}
+	if len(conf.Ca) > 0 && !x509.NewCertPool().AppendCertsFromPEM(conf.Ca) {
+		return nil, fmt.Errorf("ca certificate of ldap is not valid")
+	}
+
+	if conf.UsernameAttr == "" {
+		conf.UsernameAttr = DefaultLdapUsernameAttr
+	}
+	if conf.EmailAttr == "" {
+		conf.EmailAttr = DefaultLdapEmailAttr
+	}
+	if conf.RealnameAttr == "" {
+		conf.RealnameAttr = DefaultLdapRealnameAttr
+	}
+	if conf.MemberOfAttr == "" {
+		conf.MemberOfAttr = DefaultLdapMemberOfAttr
+	}
+	if conf.GroupNameAttr == "" {
+		conf.GroupNameAttr = DefaultLdapGroupNameAttr
+	}
+	if conf.Timeout < 1 {
+		conf.Timeout = DefaultLdapTimeout
+	}
+
+	return &LdapAuthenticator{conf: conf}, nil
+}
+
+// Name 返回认证源的名称
+func (l *LdapAuthenticator) Name() string {
+	return AuthSourceLdap
+}
+
+// Authenticate 使用服务帐号搜索用户，然后以用户的DN及密码绑定LDAP服务器认证用户，认证通过后获取用户的信息及所属的组
+func (l *LdapAuthenticator) Authenticate(username, password string) (Identity, error) {
+	identity := Identity{}
+	// 空密码绑定在LDAP中是未认证绑定(unauthenticated bind)，总是成功，必须拒绝
+	if strings.TrimSpace(username) == "" || password == "" {
+		return identity, ErrAuthenticationFailed
+	}
+
+	conn, e := l.connect()
+	if e != nil {
+		return identity, e
+	}
+	defer conn.Close()
+
+	if e := l.bindService(conn); e != nil {
+		return identity, e
+	}
+
+	attrs := []string{"dn", l.conf.UsernameAttr, l.conf.EmailAttr, l.conf.RealnameAttr, l.conf.MemberOfAttr}
+	req := ldap.NewSearchRequest(l.conf.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, l.conf.Timeout, false,
+		fmt.Sprintf(l.conf.UserFilter, ldap.EscapeFilter(username)), attrs, nil)
+	res, e := conn.Search(req)
+	if e != nil && !ldap.IsErrorWithCode(e, ldap.LDAPResultSizeLimitExceeded) {
+		return identity, fmt.Errorf("search user %s error: %s", username, e)
+	}
+	if res == nil || len(res.Entries) != 1 {
+		return identity, ErrAuthenticationFailed
+	}
+	entry := res.Entries[0]
+
+	if e := conn.Bind(entry.DN, password); e != nil {
+		if ldap.IsErrorWithCode(e, ldap.LDAPResultInvalidCredentials) {
+			return identity, ErrAuthenticationFailed
+		}
+		return identity, fmt.Errorf("bind as user %s error: %s", entry.DN, e)
+	}
+
+	identity.Username = entry.GetAttributeValue(l.conf.UsernameAttr)
+	if identity.Username == "" {
+		identity.Username = username
+	}
+	identity.Email = entry.GetAttributeValue(l.conf.EmailAttr)
+	identity.Realname = entry.GetAttributeValue(l.conf.RealnameAttr)
+
+	groups := entry.GetAttributeValues(l.conf.MemberOfAttr)
+	if l.conf.GroupBaseDN != "" {
+		// 以用户身份绑定后可能没有搜索组的权限，重新绑定服务帐号
+		if e := l.bindService(conn); e != nil {
+			return identity, e
+		}
+		found, e := l.searchGroups(conn, entry.DN)
+		if e != nil {
+			return identity, e
+		}
+		groups = append(groups, found...)
+	}
+	mapIdentityGroups(&identity, groups, l.conf.GroupMapping, l.conf.AdminGroups, normalizeLdapName)
+
+	return identity, nil
+}
+
+// connect 连接LDAP服务器，根据配置使用TLS或StartTLS
+func (l *LdapAuthenticator) connect() (*ldap.Conn, error) {
+	tlsConf := &tls.Config{InsecureSkipVerify: l.conf.InsecureSkipVerify}
+	if u, e := url.Parse(l.conf.Url); e == nil {
+		tlsConf.ServerName = u.Hostname()
+	}
+	if len(l.conf.Ca) > 0 {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(l.conf.Ca)
+		tlsConf.RootCAs = pool
+	}
+
+	timeout := time.Duration(l.conf.Timeout) * time.Second
+	conn, e := ldap.DialURL(l.conf.Url, ldap.DialWithTLSConfig(tlsConf), ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+	if e != nil {
+		return nil, fmt.Errorf("connect to ldap server %s error: %s", l.conf.Url, e)
+	}
+	conn.SetTimeout(timeout)
+
+	if l.conf.StartTLS {
+		if e := conn.StartTLS(tlsConf); e != nil {
+			conn.Close()
+			return nil, fmt.Errorf("starttls with ldap server %s error: %s", l.conf.Url, e)
+		}
+	}
+
+	return conn, nil
+}
+
+// bindService 以服务帐号绑定LDAP服务器，未配置服务帐号时匿名绑定
+func (l *LdapAuthenticator) bindService(conn *ldap.Conn) error {
+	var e error
+	if l.conf.BindDN == "" {
+		e = conn.UnauthenticatedBind("")
+	} else {
+		e = conn.Bind(l.conf.BindDN, l.conf.BindPassword)
+	}
+	if e != nil {
+		return fmt.Errorf("bind to ldap server as %s error: %s", l.conf.BindDN, e)
+	}
+
+	return nil
+}
+
+// searchGroups 搜索用户所属的组，返回组的DN及名称
+func (l *LdapAuthenticator) searchGroups(conn *ldap.Conn, userDN string) ([]string, error) {
+	req := ldap.NewSearchRequest(l.conf.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, l.conf.Timeout, false,
+		fmt.Sprintf(l.conf.GroupFilter, ldap.EscapeFilter(userDN)), []string{"dn", l.conf.GroupNameAttr}, nil)
+	res, e := conn.Search(req)
+	if e != nil {
+		return nil, fmt.Errorf("search groups of %s error: %s", userDN, e)
+	}
+
+	var groups []string
+	for _, entry := range res.Entries {
+		groups = append(groups, entry.DN)
+		groups = append(groups, entry.GetAttributeValues(l.conf.GroupNameAttr)...)
+	}
+
+	return groups, nil
+}
+
+// normalizeLdapName 规范化组名或DN以便不区分大小写及DN中的空格进行比较
+func normalizeLdapName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if dn, e := ldap.ParseDN(name); e == nil && len(dn.RDNs) > 1 {
+		return dn.String()
+	}
+
+	return name
+}
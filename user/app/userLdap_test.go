@@ -0,0 +1,296 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	ldapServiceDN       = "cn=service,dc=example,dc=com"
+	ldapServicePassword = "servicepassword"
+	ldapAliceDN         = "uid=alice,ou=people,dc=example,dc=com"
+	ldapAlicePassword   = "alicepassword"
+)
+
+// ldapStandIn 测试用的LDAP服务器，仅支持简单绑定，按过滤条件返回预设条目的搜索及解除绑定
+type ldapStandIn struct {
+	listener  net.Listener
+	passwords map[string]string
+	entries   map[string][]*ldap.Entry
+}
+
+func startLdapStandIn(t *testing.T) *ldapStandIn {
+	listener, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatalf("listen for ldap stand-in error: %s", e)
+	}
+
+	s := &ldapStandIn{
+		listener: listener,
+		passwords: map[string]string{
+			ldapServiceDN: ldapServicePassword,
+			ldapAliceDN:   ldapAlicePassword,
+		},
+		entries: map[string][]*ldap.Entry{
+			"(&(objectClass=person)(uid=alice))": {
+				ldap.NewEntry(ldapAliceDN, map[string][]string{
+					"uid":      {"alice"},
+					"mail":     {"alice@example.com"},
+					"cn":       {"Alice Liddell"},
+					"memberOf": {"cn=Developers,ou=groups,dc=example,dc=com"},
+				}),
+			},
+			"(&(objectClass=groupOfNames)(member=" + ldapAliceDN + "))": {
+				ldap.NewEntry("cn=admins,ou=groups,dc=example,dc=com", map[string][]string{
+					"cn": {"admins"},
+				}),
+			},
+		},
+	}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return s
+}
+
+func (s *ldapStandIn) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStandIn) serve() {
+	for {
+		conn, e := s.listener.Accept()
+		if e != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *ldapStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, e := ber.ReadPacket(conn)
+		if e != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		msgID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			name := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := ldap.LDAPResultSuccess
+			if pw, ok := s.passwords[name]; (name != "" || password != "") && (!ok || pw != password) {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			conn.Write(ldapResult(msgID, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for _, entry := range s.entries[filter] {
+				conn.Write(ldapSearchEntry(msgID, entry).Bytes())
+			}
+			conn.Write(ldapResult(msgID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func ldapEnvelope(msgID int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, ""))
+	packet.AppendChild(op)
+
+	return packet
+}
+
+func ldapResult(msgID int64, tag ber.Tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+
+	return ldapEnvelope(msgID, op)
+}
+
+func ldapSearchEntry(msgID int64, entry *ldap.Entry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, ""))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for _, attr := range entry.Attributes {
+		a := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		a.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attr.Name, ""))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, v := range attr.Values {
+			values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+		}
+		a.AppendChild(values)
+		attrs.AppendChild(a)
+	}
+	op.AppendChild(attrs)
+
+	return ldapEnvelope(msgID, op)
+}
+
+func newTestLdapAuthenticator(t *testing.T, s *ldapStandIn) *LdapAuthenticator {
+	a, e := NewLdapAuthenticator(LdapConfig{
+		Url:          s.url(),
+		BindDN:       ldapServiceDN,
+		BindPassword: ldapServicePassword,
+		UserBaseDN:   "ou=people,dc=example,dc=com",
+		UserFilter:   "(&(objectClass=person)(uid=%s))",
+		GroupBaseDN:  "ou=groups,dc=example,dc=com",
+		GroupFilter:  "(&(objectClass=groupOfNames)(member=%s))",
+		GroupMapping: map[string]string{
+			"CN=developers, OU=groups, DC=example, DC=com": "developers",
+			"Testers": "testers",
+		},
+		AdminGroups: []string{"Admins"},
+		Timeout:     2,
+	})
+	if e != nil {
+		t.Fatalf("create ldap authenticator error: %s", e)
+	}
+
+	return a
+}
+
+func TestLdapAuthenticate(t *testing.T) {
+	a := newTestLdapAuthenticator(t, startLdapStandIn(t))
+
+	identity, e := a.Authenticate("alice", ldapAlicePassword)
+	if e != nil {
+		t.Fatalf("authenticate alice error: %s", e)
+	}
+
+	if identity.Username != "alice" || identity.Email != "alice@example.com" || identity.Realname != "Alice Liddell" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+	if !identity.Sysadmin {
+		t.Errorf("alice should be sysadmin as a member of admins")
+	}
+	if len(identity.Groups) != 1 || identity.Groups[0] != "developers" {
+		t.Errorf("groups of alice should be [developers], got %v", identity.Groups)
+	}
+
+	managed := append([]string{}, identity.ManagedGroups...)
+	sort.Strings(managed)
+	if len(managed) != 2 || managed[0] != "developers" || managed[1] != "testers" {
+		t.Errorf("managed groups should be [developers testers], got %v", managed)
+	}
+}
+
+func TestLdapAuthenticateFailed(t *testing.T) {
+	a := newTestLdapAuthenticator(t, startLdapStandIn(t))
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "alice", "wrongpassword"},
+		{"empty password", "alice", ""},
+		{"unknown user", "bob", ldapAlicePassword},
+		{"filter injection", "*", ldapAlicePassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, e := a.Authenticate(tt.username, tt.password)
+			if !errors.Is(e, ErrAuthenticationFailed) {
+				t.Errorf("expected ErrAuthenticationFailed, got %v", e)
+			}
+		})
+	}
+}
+
+func TestLdapAuthenticateServiceBindFailed(t *testing.T) {
+	s := startLdapStandIn(t)
+	s.passwords[ldapServiceDN] = "changed"
+	a := newTestLdapAuthenticator(t, s)
+
+	_, e := a.Authenticate("alice", ldapAlicePassword)
+	if e == nil || errors.Is(e, ErrAuthenticationFailed) {
+		t.Errorf("service bind failure should not be reported as authentication failure, got %v", e)
+	}
+}
+
+func TestLdapMapGroupsToSameLocalGroup(t *testing.T) {
+	mapping := map[string]string{
+		"cn=dev,ou=groups,dc=example,dc=com": "developers",
+		"cn=ops,ou=groups,dc=example,dc=com": "developers",
+		"cn=qa,ou=groups,dc=example,dc=com":  "testers",
+		"cn=uat,ou=groups,dc=example,dc=com": "testers",
+	}
+	groups := []string{"CN=dev,OU=groups,DC=example,DC=com", "cn=ops, ou=groups, dc=example, dc=com"}
+
+	// the iteration order of mapping is random, so the groups are mapped many times
+	for i := 0; i < 20; i++ {
+		identity := &Identity{}
+		mapIdentityGroups(identity, groups, mapping, nil, normalizeLdapName)
+
+		if len(identity.Groups) != 1 || identity.Groups[0] != "developers" {
+			t.Fatalf("groups should be [developers], got %v", identity.Groups)
+		}
+		managed := append([]string{}, identity.ManagedGroups...)
+		sort.Strings(managed)
+		if len(managed) != 2 || managed[0] != "developers" || managed[1] != "testers" {
+			t.Fatalf("managed groups should be [developers testers], got %v", managed)
+		}
+	}
+}
+
+func TestNewLdapAuthenticator(t *testing.T) {
+	valid := LdapConfig{
+		Url:        "ldap://127.0.0.1:389",
+		UserBaseDN: "ou=people,dc=example,dc=com",
+		UserFilter: "(uid=%s)",
+	}
+
+	a, e := NewLdapAuthenticator(valid)
+	if e != nil {
+		t.Fatalf("create ldap authenticator error: %s", e)
+	}
+	if a.conf.UsernameAttr != DefaultLdapUsernameAttr || a.conf.Timeout != DefaultLdapTimeout {
+		t.Errorf("default values are not applied: %+v", a.conf)
+	}
+
+	invalid := map[string]func(c *LdapConfig){
+		"url scheme":     func(c *LdapConfig) { c.Url = "http://127.0.0.1:389" },
+		"starttls ldaps": func(c *LdapConfig) { c.Url = "ldaps://127.0.0.1:636"; c.StartTLS = true },
+		"empty base dn":  func(c *LdapConfig) { c.UserBaseDN = "" },
+		"user filter":    func(c *LdapConfig) { c.UserFilter = "(uid=alice)" },
+		"group filter":   func(c *LdapConfig) { c.GroupBaseDN = "ou=groups,dc=example,dc=com" },
+		"ca certificate": func(c *LdapConfig) { c.Ca = []byte("not a certificate") },
+	}
+	for name, modify := range invalid {
+		c := valid
+		modify(&c)
+		if _, e := NewLdapAuthenticator(c); e == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
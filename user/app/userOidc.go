@@ -0,0 +1,361 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// NewOidcProvider 校验OIDC认证源的配置，未设置的claim名称及scope使用默认值。provider的配置在首次使用时获取
+func NewOidcProvider(conf OidcConfig) (*OidcProvider, error) {
+	conf.Issuer = strings.TrimSuffix(strings.TrimSpace(conf.Issuer), "/")
+	if u, e := url.Parse(conf.Issuer); e != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("oidc issuer %s is not valid", conf.Issuer)
+	}
+	if u, e := url.Parse(strings.TrimSpace(conf.RedirectURL)); e != nil || !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("oidc redirect url %s is not valid", conf.RedirectURL)
+	}
+	conf.ClientID = strings.TrimSpace(conf.ClientID)
+	if conf.ClientID == "" {
+		return nil, fmt.Errorf("oidc client id must be set")
+	}
+
+	tlsConf := &tls.Config{InsecureSkipVerify: conf.InsecureSkipVerify}
+	if len(conf.Ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(conf.Ca) {
+			return nil, fmt.Errorf("ca certificate of oidc is not valid")
+		}
+		tlsConf.RootCAs = pool
+	}
+
+	if conf.UsernameClaim == "" {
+		conf.UsernameClaim = DefaultOidcUsernameClaim
+	}
+	if conf.EmailClaim == "" {
+		conf.EmailClaim = DefaultOidcEmailClaim
+	}
+	if conf.RealnameClaim == "" {
+		conf.RealnameClaim = DefaultOidcRealnameClaim
+	}
+	if conf.GroupsClaim == "" {
+		conf.GroupsClaim = DefaultOidcGroupsClaim
+	}
+	if conf.Timeout < 1 {
+		conf.Timeout = DefaultOidcTimeout
+	}
+
+	scopes := []string{"openid"}
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = DefaultOidcScopes
+	}
+	for _, s := range conf.Scopes {
+		if s = strings.TrimSpace(s); s != "" && s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+	conf.Scopes = scopes
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConf
+	client := &http.Client{Transport: transport, Timeout: time.Duration(conf.Timeout) * time.Second}
+
+	return &OidcProvider{conf: conf, client: client}, nil
+}
+
+// Name 返回认证源的名称
+func (p *OidcProvider) Name() string {
+	return AuthSourceOidc
+}
+
+// NewOidcRequest 生成一次认证请求使用的state，nonce及PKCE的code verifier。调用者应将它们保存在用户的session中，
+// 并在provider将用户重定向回来时使用它们调用Exchange
+func NewOidcRequest() (state, nonce, verifier string, e error) {
+	if state, e = randomToken(); e != nil {
+		return
+	}
+	if nonce, e = randomToken(); e != nil {
+		return
+	}
+	verifier, e = randomToken()
+
+	return
+}
+
+// AuthCodeURL 返回将用户重定向到provider进行认证的地址
+func (p *OidcProvider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	metadata, e := p.discover()
+	if e != nil {
+		return "", e
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	return p.oauth2Config(metadata).AuthCodeURL(state,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil
+}
+
+// Exchange 使用授权码向provider换取ID Token，校验ID Token后返回其中的用户信息
+func (p *OidcProvider) Exchange(code, nonce, verifier string) (Identity, error) {
+	identity := Identity{}
+	if strings.TrimSpace(code) == "" {
+		return identity, fmt.Errorf("authorization code is empty")
+	}
+
+	metadata, e := p.discover()
+	if e != nil {
+		return identity, e
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.conf.Timeout)*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, e := p.oauth2Config(metadata).Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if e != nil {
+		return identity, fmt.Errorf("exchange authorization code error: %s", e)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return identity, fmt.Errorf("id token was not found in the token response")
+	}
+
+	claims, e := p.verifyIDToken(metadata, rawIDToken, nonce)
+	if e != nil {
+		return identity, e
+	}
+
+	return p.identityFromClaims(claims)
+}
+
+// oauth2Config 返回访问provider的oauth2配置
+func (p *OidcProvider) oauth2Config(metadata *oidcMetadata) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.conf.ClientID,
+		ClientSecret: p.conf.ClientSecret,
+		RedirectURL:  p.conf.RedirectURL,
+		Scopes:       p.conf.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}
+}
+
+// discover 获取并缓存provider的配置，provider的issuer必须与配置的issuer一致
+func (p *OidcProvider) discover() (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := &oidcMetadata{}
+	if e := p.getJSON(p.conf.Issuer+"/.well-known/openid-configuration", metadata); e != nil {
+		return nil, fmt.Errorf("get configuration of oidc provider %s error: %s", p.conf.Issuer, e)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.conf.Issuer {
+		return nil, fmt.Errorf("issuer %s of oidc provider does not match the configured issuer %s", metadata.Issuer, p.conf.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JwksURI == "" {
+		return nil, fmt.Errorf("authorization endpoint, token endpoint or jwks uri of oidc provider %s is empty", p.conf.Issuer)
+	}
+	if len(metadata.SigningAlgs) == 0 {
+		metadata.SigningAlgs = []string{string(jose.RS256)}
+	}
+
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// signingKeys 返回ID Token签名头中kid对应的签名密钥。密钥未找到时(provider可能已轮换密钥)重新获取provider的密钥
+func (p *OidcProvider) signingKeys(metadata *oidcMetadata, kid string) ([]jose.JSONWebKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := p.findKeys(kid)
+	if len(keys) > 0 || time.Since(p.keysTime) < oidcKeysRefreshInterval*time.Second {
+		return keys, nil
+	}
+
+	keySet := jose.JSONWebKeySet{}
+	if e := p.getJSON(metadata.JwksURI, &keySet); e != nil {
+		return nil, fmt.Errorf("get signing keys of oidc provider error: %s", e)
+	}
+	p.keys = keySet
+	p.keysTime = time.Now()
+
+	return p.findKeys(kid), nil
+}
+
+// findKeys 返回缓存的密钥中kid对应的公钥，kid为空时返回全部公钥
+func (p *OidcProvider) findKeys(kid string) []jose.JSONWebKey {
+	if kid != "" {
+		return p.keys.Key(kid)
+	}
+
+	var keys []jose.JSONWebKey
+	for _, k := range p.keys.Keys {
+		if k.IsPublic() && (k.Use == "" || k.Use == "sig") {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// verifyIDToken 校验ID Token的签名，issuer，audience，有效时间及nonce，返回ID Token中的全部claim
+func (p *OidcProvider) verifyIDToken(metadata *oidcMetadata, rawIDToken, nonce string) (map[string]interface{}, error) {
+	token, e := jwt.ParseSigned(rawIDToken)
+	if e != nil {
+		return nil, fmt.Errorf("parse id token error: %s", e)
+	}
+	if len(token.Headers) != 1 {
+		return nil, fmt.Errorf("id token should have one signature")
+	}
+
+	header := token.Headers[0]
+	allowed := false
+	for _, alg := range metadata.SigningAlgs {
+		if header.Algorithm == alg && !strings.HasPrefix(alg, "HS") {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("signing algorithm %s of id token is not supported", header.Algorithm)
+	}
+
+	keys, e := p.signingKeys(metadata, header.KeyID)
+	if e != nil {
+		return nil, e
+	}
+
+	var standard jwt.Claims
+	var claims map[string]interface{}
+	verified := false
+	for i := range keys {
+		if token.Claims(&keys[i], &standard, &claims) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("signature of id token is not valid")
+	}
+
+	if standard.Expiry == 0 {
+		return nil, fmt.Errorf("id token has no expiry")
+	}
+	expected := jwt.Expected{Issuer: metadata.Issuer, Audience: jwt.Audience{p.conf.ClientID}, Time: time.Now()}
+	if e := standard.ValidateWithLeeway(expected, oidcClockSkew*time.Second); e != nil {
+		return nil, fmt.Errorf("id token is not valid: %s", e)
+	}
+
+	if n, _ := claims["nonce"].(string); nonce == "" || n != nonce {
+		return nil, fmt.Errorf("nonce of id token does not match")
+	}
+
+	return claims, nil
+}
+
+// identityFromClaims 从ID Token的claim中获取用户信息，并按照组映射关系(不区分大小写)映射用户所属的组
+func (p *OidcProvider) identityFromClaims(claims map[string]interface{}) (Identity, error) {
+	identity := Identity{}
+	// 用户名等claim可以被用户修改，只有issuer和sub的组合才能唯一且不变地标识一个用户
+	sub, _ := claims["sub"].(string)
+	if strings.TrimSpace(sub) == "" {
+		return identity, fmt.Errorf("claim sub was not found in id token")
+	}
+	identity.ExternalID = oidcExternalID(p.conf.Issuer, sub)
+	identity.Username, _ = claims[p.conf.UsernameClaim].(string)
+	if strings.TrimSpace(identity.Username) == "" {
+		return identity, fmt.Errorf("claim %s was not found in id token", p.conf.UsernameClaim)
+	}
+	identity.Email, _ = claims[p.conf.EmailClaim].(string)
+	identity.Realname, _ = claims[p.conf.RealnameClaim].(string)
+
+	var groups []string
+	switch v := claims[p.conf.GroupsClaim].(type) {
+	case string:
+		groups = append(groups, v)
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	mapIdentityGroups(&identity, groups, p.conf.GroupMapping, p.conf.AdminGroups, func(name string) string {
+		return strings.ToLower(strings.TrimSpace(name))
+	})
+
+	return identity, nil
+}
+
+// oidcExternalID 返回issuer签发的sub所标识的用户的外部标识
+func oidcExternalID(issuer, sub string) string {
+	return issuer + "#" + sub
+}
+
+// getJSON 获取url的内容并解析到v中
+func (p *OidcProvider) getJSON(url string, v interface{}) error {
+	resp, e := p.client.Get(url)
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+
+	body, e := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if e != nil {
+		return e
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responsed with status %s", url, resp.Status)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// randomToken 生成随机字符串，用作state，nonce及PKCE的code verifier
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, e := rand.Read(b); e != nil {
+		return "", e
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,271 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	oidcClientID     = "sysadm"
+	oidcClientSecret = "clientsecret"
+	oidcRedirectURL  = "https://sysadm.example.com/oidc/callback"
+	oidcCode         = "authorizationcode"
+)
+
+// oidcMock 测试用的OIDC provider，提供配置，签名密钥及token端点。token端点校验授权码，客户端密码及PKCE的code verifier，
+// 返回使用claims签名的ID Token
+type oidcMock struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	kid       string
+	challenge string
+	claims    map[string]interface{}
+}
+
+func startOidcMock(t *testing.T) *oidcMock {
+	key, e := rsa.GenerateKey(rand.Reader, 2048)
+	if e != nil {
+		t.Fatalf("generate rsa key error: %s", e)
+	}
+
+	m := &oidcMock{key: key, kid: "key1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.server.URL,
+			"authorization_endpoint":                m.server.URL + "/authorize",
+			"token_endpoint":                        m.server.URL + "/token",
+			"jwks_uri":                              m.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &m.key.PublicKey, KeyID: "key1", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	m.claims = map[string]interface{}{
+		"iss":                m.server.URL,
+		"sub":                "248289761001",
+		"aud":                oidcClientID,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"name":               "Alice Liddell",
+		"groups":             []string{"Developers", "admins"},
+	}
+
+	return m
+}
+
+func (m *oidcMock) token(w http.ResponseWriter, r *http.Request) {
+	if e := r.ParseForm(); e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if clientID != oidcClientID || secret != oidcClientSecret || r.PostForm.Get("code") != oidcCode ||
+		r.PostForm.Get("grant_type") != "authorization_code" ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != m.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	signer, e := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: m.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", m.kid))
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	idToken, e := jwt.Signed(signer).Claims(m.claims).CompactSerialize()
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "accesstoken",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// authorize 模拟用户在provider认证的过程，校验认证请求的参数并记录code challenge及nonce
+func (m *oidcMock) authorize(t *testing.T, p *OidcProvider, state, nonce, verifier string) {
+	authURL, e := p.AuthCodeURL(state, nonce, verifier)
+	if e != nil {
+		t.Fatalf("get auth code url error: %s", e)
+	}
+
+	u, e := url.Parse(authURL)
+	if e != nil {
+		t.Fatalf("parse auth code url error: %s", e)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != oidcClientID || q.Get("redirect_uri") != oidcRedirectURL ||
+		q.Get("response_type") != "code" || q.Get("state") != state || q.Get("nonce") != nonce ||
+		q.Get("code_challenge_method") != "S256" || !strings.Contains(q.Get("scope"), "openid") {
+		t.Fatalf("auth code url %s is not valid", authURL)
+	}
+	m.challenge = q.Get("code_challenge")
+	m.claims["nonce"] = nonce
+}
+
+func newTestOidcProvider(t *testing.T, m *oidcMock) *OidcProvider {
+	p, e := NewOidcProvider(OidcConfig{
+		Issuer:       m.server.URL,
+		ClientID:     oidcClientID,
+		ClientSecret: oidcClientSecret,
+		RedirectURL:  oidcRedirectURL,
+		GroupMapping: map[string]string{"developers": "developers", "testers": "testers"},
+		AdminGroups:  []string{"Admins"},
+		Timeout:      2,
+	})
+	if e != nil {
+		t.Fatalf("create oidc provider error: %s", e)
+	}
+
+	return p
+}
+
+func TestOidcExchange(t *testing.T) {
+	m := startOidcMock(t)
+	p := newTestOidcProvider(t, m)
+
+	state, nonce, verifier, e := NewOidcRequest()
+	if e != nil {
+		t.Fatalf("new oidc request error: %s", e)
+	}
+	m.authorize(t, p, state, nonce, verifier)
+
+	identity, e := p.Exchange(oidcCode, nonce, verifier)
+	if e != nil {
+		t.Fatalf("exchange error: %s", e)
+	}
+
+	if identity.Username != "alice" || identity.Email != "alice@example.com" || identity.Realname != "Alice Liddell" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+	if identity.ExternalID != m.server.URL+"#248289761001" {
+		t.Errorf("external ID should be bound to issuer and sub, got %s", identity.ExternalID)
+	}
+	if !identity.Sysadmin {
+		t.Errorf("alice should be sysadmin as a member of admins")
+	}
+	if len(identity.Groups) != 1 || identity.Groups[0] != "developers" {
+		t.Errorf("groups of alice should be [developers], got %v", identity.Groups)
+	}
+
+	managed := append([]string{}, identity.ManagedGroups...)
+	sort.Strings(managed)
+	if len(managed) != 2 || managed[0] != "developers" || managed[1] != "testers" {
+		t.Errorf("managed groups should be [developers testers], got %v", managed)
+	}
+}
+
+func TestOidcExchangeFailed(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(m *oidcMock, nonce, verifier *string)
+	}{
+		{"wrong code verifier", func(m *oidcMock, nonce, verifier *string) { *verifier = "wrongverifier" }},
+		{"wrong nonce", func(m *oidcMock, nonce, verifier *string) { *nonce = "wrongnonce" }},
+		{"wrong audience", func(m *oidcMock, nonce, verifier *string) { m.claims["aud"] = "other" }},
+		{"wrong issuer", func(m *oidcMock, nonce, verifier *string) { m.claims["iss"] = "https://evil.example.com" }},
+		{"without sub", func(m *oidcMock, nonce, verifier *string) { delete(m.claims, "sub") }},
+		{"expired", func(m *oidcMock, nonce, verifier *string) {
+			m.claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		}},
+		{"no username", func(m *oidcMock, nonce, verifier *string) { delete(m.claims, "preferred_username") }},
+		{"unknown key", func(m *oidcMock, nonce, verifier *string) {
+			m.kid = "key2"
+			m.key, _ = rsa.GenerateKey(rand.Reader, 2048)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := startOidcMock(t)
+			p := newTestOidcProvider(t, m)
+			state, nonce, verifier, e := NewOidcRequest()
+			if e != nil {
+				t.Fatalf("new oidc request error: %s", e)
+			}
+			m.authorize(t, p, state, nonce, verifier)
+
+			tt.modify(m, &nonce, &verifier)
+			if _, e := p.Exchange(oidcCode, nonce, verifier); e == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestOidcDiscoverIssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 "https://evil.example.com",
+			"authorization_endpoint": "https://evil.example.com/authorize",
+			"token_endpoint":         "https://evil.example.com/token",
+			"jwks_uri":               "https://evil.example.com/jwks",
+		})
+	}))
+	defer server.Close()
+
+	p, e := NewOidcProvider(OidcConfig{
+		Issuer:      server.URL,
+		ClientID:    oidcClientID,
+		RedirectURL: oidcRedirectURL,
+	})
+	if e != nil {
+		t.Fatalf("create oidc provider error: %s", e)
+	}
+
+	if _, e := p.AuthCodeURL("state", "nonce", "verifier"); e == nil || !strings.Contains(e.Error(), "does not match") {
+		t.Errorf("expected an error when issuer does not match, got %v", e)
+	}
+}
@@ -0,0 +1,285 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"unicode"
+
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+	sysadmSetting "sysadm/syssetting/app"
+	"sysadm/utils"
+)
+
+// passwordHistoryRepository 用户历史密码
+var passwordHistoryRepository = sysadmObjects.NewRepository[PasswordHistorySchema](passwordHistoryObjectName, passwordHistoryTableName, passwordHistoryPkName)
+
+// passwordSettings 密码策略配置项的定义
+var passwordSettings = []sysadmSetting.SettingDefinition{
+	{Key: SettingKeyForPasswordMinLength, Type: sysadmSetting.SettingTypeInt, Default: strconv.Itoa(DefaultPasswordMinLength),
+		Scopes: []int{sysadmSetting.SettingScopeGlobal}, Validate: validIntRange(1, 255), Description: "密码的最小长度"},
+	{Key: SettingKeyForPasswordComplexity, Type: sysadmSetting.SettingTypeInt, Default: strconv.Itoa(DefaultPasswordComplexity),
+		Scopes: []int{sysadmSetting.SettingScopeGlobal}, Validate: validIntRange(1, 4),
+		Description: "密码至少需要包含的字符种类数(小写字母，大写字母，数字和其它字符)"},
+	{Key: SettingKeyForPasswordHistory, Type: sysadmSetting.SettingTypeInt, Default: strconv.Itoa(DefaultPasswordHistory),
+		Scopes: []int{sysadmSetting.SettingScopeGlobal}, Validate: validIntRange(0, 100), Description: "新密码不能与最近使用过的多少个密码相同，0表示不检查"},
+	{Key: SettingKeyForPasswordMaxAge, Type: sysadmSetting.SettingTypeInt, Default: strconv.Itoa(DefaultPasswordMaxAge),
+		Scopes: []int{sysadmSetting.SettingScopeGlobal}, Validate: validIntRange(0, 36500), Description: "密码的有效天数，0表示永不过期"},
+}
+
+// 注册密码策略配置项
+func init() {
+	for _, def := range passwordSettings {
+		if e := sysadmSetting.RegisterSetting(def); e != nil {
+			panic(e)
+		}
+	}
+}
+
+// validIntRange 返回检查配置项的值是否为min到max之间的整数的函数
+func validIntRange(min, max int) func(string) error {
+	return func(value string) error {
+		i, e := sysadmSetting.EffectiveValue{Value: value}.Int()
+		if e != nil {
+			return e
+		}
+
+		if i < min || i > max {
+			return fmt.Errorf("value %s should be between %d and %d", value, min, max)
+		}
+
+		return nil
+	}
+}
+
+// GetPasswordPolicy 获取当前生效的密码策略，配置项未设置时使用默认值
+func GetPasswordPolicy() (PasswordPolicy, error) {
+	policy := PasswordPolicy{
+		MinLength:  DefaultPasswordMinLength,
+		Complexity: DefaultPasswordComplexity,
+		History:    DefaultPasswordHistory,
+		MaxAgeDays: DefaultPasswordMaxAge,
+	}
+
+	items := map[string]*int{
+		SettingKeyForPasswordMinLength:  &policy.MinLength,
+		SettingKeyForPasswordComplexity: &policy.Complexity,
+		SettingKeyForPasswordHistory:    &policy.History,
+		SettingKeyForPasswordMaxAge:     &policy.MaxAgeDays,
+	}
+
+	s := sysadmSetting.New()
+	for key, value := range items {
+		v, _, e := s.ResolveInt(key, sysadmSetting.SettingContext{})
+		if e != nil {
+			if sysadmSetting.IsSettingNotSet(e) {
+				continue
+			}
+			return policy, fmt.Errorf("get password policy %s error: %s", key, e)
+		}
+		*value = v
+	}
+
+	return policy, nil
+}
+
+// Check 检查密码是否符合密码策略规定的长度和复杂度
+func (p PasswordPolicy) Check(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password should be at least %d characters", p.MinLength)
+	}
+
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+
+	if lower+upper+digit+other < p.Complexity {
+		return fmt.Errorf("password should contain at least %d kinds of lowercase letters, uppercase letters, digits and other characters", p.Complexity)
+	}
+
+	return nil
+}
+
+// IsExpired 判断最后一次设置密码的时间为passwordTime的密码是否已过期。passwordTime为0的密码为旧的密码，不判断是否过期
+func (p PasswordPolicy) IsExpired(passwordTime int) bool {
+	if p.MaxAgeDays < 1 || passwordTime < 1 {
+		return false
+	}
+
+	return time.Now().Unix()-int64(passwordTime) > int64(p.MaxAgeDays)*24*3600
+}
+
+// UpgradePasswordSchema 将旧表结构中用户表的password字段加宽到passwordFieldLength，以便保存使用默认加密算法加密的密码。
+// 该字段已足够宽时不做任何操作。需要在用户登录或修改密码前调用
+func UpgradePasswordSchema() error {
+	if runData.dbConf == nil {
+		return fmt.Errorf("数据库配置为空")
+	}
+
+	return sysadmDB.WidenColumn(runData.dbConf.Entity, DefaultTableName, "password", passwordFieldLength, passwordFieldDefinition)
+}
+
+// VerifyPassword 检查password是否为用户user的密码。密码正确但不是使用默认加密算法加密的密码(如旧的md5密码)将使用默认加密算法
+// 重新加密后更新到数据库中。密码正确但已过期时返回ErrPasswordExpired
+func (u User) VerifyPassword(user UserSchema, password string) (bool, error) {
+	ok, needRehash, e := utils.VerifyPassword(password, user.Password, user.Salt)
+	if e != nil || !ok {
+		return false, e
+	}
+
+	if needRehash {
+		if e := u.rehashPassword(user, password); e != nil {
+			return true, fmt.Errorf("upgrade password hash of user %s error: %s", user.Username, e)
+		}
+		if user.PasswordTime < 1 {
+			return true, nil
+		}
+	}
+
+	policy, e := GetPasswordPolicy()
+	if e != nil {
+		return true, e
+	}
+
+	if policy.IsExpired(user.PasswordTime) {
+		return true, ErrPasswordExpired
+	}
+
+	return true, nil
+}
+
+// rehashPassword 使用默认加密算法重新加密用户的密码。旧的密码没有设置密码的时间，其有效期从升级时开始计算
+func (u User) rehashPassword(user UserSchema, password string) error {
+	hash, e := utils.HashPassword(password)
+	if e != nil {
+		return e
+	}
+
+	updateData := make(sysadmDB.FieldData, 0)
+	updateData["password"] = sysadmObjects.QuoteString(hash)
+	updateData["salt"] = sysadmObjects.QuoteString("")
+	if user.PasswordTime < 1 {
+		updateData["password_time"] = time.Now().Unix()
+	}
+	where := map[string]string{u.PkName: user.Id}
+
+	return runData.dbConf.Entity.NewUpdateData(u.TableName, updateData, where)
+}
+
+// SetPassword 将用户userid的密码设置为password。password需要符合密码策略，且不能与最近使用过的密码相同
+func (u User) SetPassword(userid, password string) error {
+	user, e := u.Get(userid)
+	if e != nil {
+		return e
+	}
+
+	// 外部认证源的用户密码由外部认证源管理
+	if user.AuthSource != "" && user.AuthSource != AuthSourceLocal {
+		return fmt.Errorf("the password of user %s is managed by %s", user.Username, user.AuthSource)
+	}
+
+	policy, e := GetPasswordPolicy()
+	if e != nil {
+		return e
+	}
+
+	if e := policy.Check(password); e != nil {
+		return e
+	}
+
+	if policy.History > 0 {
+		used, e := u.isPasswordUsed(user, password, policy.History)
+		if e != nil {
+			return e
+		}
+		if used {
+			return fmt.Errorf("password should not be same as the last %d passwords", policy.History)
+		}
+	}
+
+	hash, e := utils.HashPassword(password)
+	if e != nil {
+		return e
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, u)
+	if e != nil {
+		return e
+	}
+
+	now := time.Now().Unix()
+	updateData := make(sysadmDB.FieldData, 0)
+	updateData["password"] = sysadmObjects.QuoteString(hash)
+	updateData["salt"] = sysadmObjects.QuoteString("")
+	updateData["password_time"] = now
+	updateData["update_time"] = now
+	where := map[string]string{u.PkName: user.Id}
+	if e := tx.Tx.NewUpdateData(u.TableName, updateData, where); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	id, e := tx.Tx.NextID(passwordHistoryTableName, passwordHistoryPkName)
+	if e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	history := PasswordHistorySchema{Id: uint(id), Userid: user.Id, Password: hash, CreationTime: int(now)}
+	if e := passwordHistoryRepository.CreateTx(tx, history); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// isPasswordUsed 检查password是否与用户当前的密码或最近使用过的num个密码相同
+func (u User) isPasswordUsed(user UserSchema, password string, num int) (bool, error) {
+	if ok, _, e := utils.VerifyPassword(password, user.Password, user.Salt); e != nil || ok {
+		return ok, e
+	}
+
+	conditions := make(map[string]string, 0)
+	conditions["userid"] = "='" + user.Id + "'"
+	histories, e := passwordHistoryRepository.List("", nil, nil, conditions, 0, num, map[string]string{passwordHistoryPkName: "1"})
+	if e != nil {
+		return false, e
+	}
+
+	for _, h := range histories {
+		if ok, _, e := utils.VerifyPassword(password, h.Password, ""); e != nil || ok {
+			return ok, e
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,78 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"sysadm/utils"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, Complexity: 3}
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"Abcdefg1", true},
+		{"abcdefg#1", true},
+		{"密码Abc#1234", true},
+		{"Abc#123", false},
+		{"abcdefgh", false},
+		{"abcdefg1", false},
+		{"ABCDEFG#", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if e := policy.Check(tt.password); (e == nil) != tt.ok {
+			t.Errorf("check password %q should be %v, got error %v", tt.password, tt.ok, e)
+		}
+	}
+
+	// 长度按字符计算而不是按字节计算
+	if e := (PasswordPolicy{MinLength: 4, Complexity: 1}).Check("密码"); e == nil {
+		t.Errorf("password with 2 characters should be shorter than 4")
+	}
+}
+
+// 使用md5加密的旧密码验证通过后应使用argon2id重新加密
+func TestVerifyPasswordRehash(t *testing.T) {
+	db := newFakeDB(t)
+	user := UserSchema{Id: "1", Username: "alice", Password: utils.Md5Encrypt("Secret#1", "salt"), Salt: "salt"}
+
+	ok, e := New().VerifyPassword(user, "Secret#2")
+	if ok || e != nil {
+		t.Fatalf("wrong password should not be verified, got %v error %v", ok, e)
+	}
+	if updates := db.executed("update"); len(updates) != 0 {
+		t.Fatalf("password should not be rehashed when it is wrong, got %v", updates)
+	}
+
+	ok, e = New().VerifyPassword(user, "Secret#1")
+	if !ok || e != nil {
+		t.Fatalf("password should be verified, got %v error %v", ok, e)
+	}
+
+	updates := db.executed("update `user`")
+	if len(updates) != 1 || !strings.Contains(updates[0], "'$argon2id$") || !strings.Contains(updates[0], "`salt`=''") ||
+		!strings.Contains(updates[0], "`password_time`=") || !strings.HasSuffix(updates[0], "where `userid`='1'") {
+		t.Errorf("password should be rehashed with argon2id, got %v", updates)
+	}
+}
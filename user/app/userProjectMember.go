@@ -0,0 +1,262 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+)
+
+// projectMemberRepository 项目成员
+var projectMemberRepository = sysadmObjects.NewRepository[ProjectMemberSchema](projectMemberObjectName, projectMemberTableName, projectMemberPkName)
+
+// SetProjectMember 将用户或用户组memberid设置为项目projectid的成员，角色为role。memberid已是项目成员时修改其角色
+func SetProjectMember(projectid string, memberType int, memberid, role string) error {
+	projectid = strings.TrimSpace(projectid)
+	memberid = strings.TrimSpace(memberid)
+	role = strings.ToLower(strings.TrimSpace(role))
+	if _, ok := roleLevels[role]; !ok {
+		return fmt.Errorf("role %s is not valid", role)
+	}
+
+	if e := checkProjectMember(projectid, memberType, memberid); e != nil {
+		return e
+	}
+
+	members, e := projectMemberRepository.List("", nil, nil, projectMemberConditions(projectid, memberType, memberid), 0, 1, nil)
+	if e != nil {
+		return e
+	}
+	if len(members) > 0 {
+		updateData := make(sysadmDB.FieldData, 0)
+		updateData["role"] = sysadmObjects.QuoteString(role)
+		where := map[string]string{projectMemberPkName: strconv.Itoa(int(members[0].Id))}
+		return runData.dbConf.Entity.NewUpdateData(projectMemberTableName, updateData, where)
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, nil)
+	if e != nil {
+		return e
+	}
+
+	id, e := tx.Tx.NextID(projectMemberTableName, projectMemberPkName)
+	if e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	member := ProjectMemberSchema{Id: uint(id), ProjectID: projectid, MemberType: memberType, MemberID: memberid, Role: role,
+		CreationTime: int(time.Now().Unix())}
+	if e := projectMemberRepository.CreateTx(tx, member); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// RemoveProjectMember 将用户或用户组memberid从项目projectid的成员中移除
+func RemoveProjectMember(projectid string, memberType int, memberid string) error {
+	dd := sysadmDB.SelectData{
+		Tb: []string{projectMemberTableName},
+		Where: map[string]string{"projectid": strings.TrimSpace(projectid), "member_type": strconv.Itoa(memberType),
+			"memberid": strings.TrimSpace(memberid)},
+	}
+
+	return runData.dbConf.Entity.NewDeleteData(&dd)
+}
+
+// ListProjectMembers 获取项目projectid的全部成员
+func ListProjectMembers(projectid string) ([]ProjectMemberSchema, error) {
+	conditions := make(map[string]string, 0)
+	conditions["projectid"] = "=" + sysadmObjects.QuoteString(strings.TrimSpace(projectid))
+
+	return projectMemberRepository.List("", nil, nil, conditions, 0, 0, nil)
+}
+
+// GetProjectRole 获取用户userid在项目projectid中的角色。系统管理员和项目的所有者为owner，用户直接或通过用户组成为项目成员时
+// 以级别最高的角色为准，用户不是项目成员时返回空字符串。角色用于权限判断，相关数据均从主库读取，避免从库延迟导致刚被撤销的权限仍然生效
+func GetProjectRole(userid, projectid string) (string, error) {
+	userid = strings.TrimSpace(userid)
+	projectid = strings.TrimSpace(projectid)
+	user, e := New().OnPrimary().Get(userid)
+	if e != nil {
+		return "", fmt.Errorf("user %s is not exist", userid)
+	}
+	if user.SysadminFlag == 1 {
+		return ProjectRoleOwner, nil
+	}
+
+	project, e := sysadmObjects.New().OnPrimary().Get(projectid)
+	if e != nil || project.Deleted != 0 {
+		return "", fmt.Errorf("project %s is not exist", projectid)
+	}
+	if strconv.Itoa(project.UserID) == userid {
+		return ProjectRoleOwner, nil
+	}
+
+	groupIDs, e := GetUserGroupIDs(userid)
+	if e != nil {
+		return "", e
+	}
+
+	conditions := make(map[string]string, 0)
+	conditions["projectid"] = "=" + sysadmObjects.QuoteString(projectid)
+	members, e := projectMemberRepository.OnPrimary().List("", nil, nil, conditions, 0, 0, nil)
+	if e != nil {
+		return "", e
+	}
+
+	role := ""
+	for _, m := range members {
+		if !isMemberOf(m, userid, groupIDs) {
+			continue
+		}
+		if roleLevels[m.Role] > roleLevels[role] {
+			role = m.Role
+		}
+	}
+
+	return role, nil
+}
+
+// HasProjectPermission 判断用户userid是否对项目projectid拥有permission权限
+func HasProjectPermission(userid, projectid, permission string) (bool, error) {
+	role, e := GetProjectRole(userid, projectid)
+	if e != nil || role == "" {
+		return false, e
+	}
+
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// AccessibleProjectIDs 获取用户userid可以访问的项目的ID。all为true时表示用户为系统管理员，可以访问全部项目。相关数据均从主库读取
+func AccessibleProjectIDs(userid string) (ids []string, all bool, e error) {
+	userid = strings.TrimSpace(userid)
+	user, e := New().OnPrimary().Get(userid)
+	if e != nil {
+		return ids, false, fmt.Errorf("user %s is not exist", userid)
+	}
+	if user.SysadminFlag == 1 {
+		return ids, true, nil
+	}
+
+	found := make(map[string]bool, 0)
+	conditions := make(map[string]string, 0)
+	conditions["ownerid"] = "=" + sysadmObjects.QuoteString(userid)
+	conditions["deleted"] = "=0"
+	projects, e := sysadmObjects.New().OnPrimary().List("", nil, nil, conditions, 0, 0, nil)
+	if e != nil {
+		return ids, false, e
+	}
+	for _, p := range projects {
+		found[strconv.Itoa(p.ProjectID)] = true
+	}
+
+	groupIDs, e := GetUserGroupIDs(userid)
+	if e != nil {
+		return ids, false, e
+	}
+
+	memberIDs := map[int][]string{MemberTypeUser: {userid}, MemberTypeGroup: groupIDs}
+	for memberType, idList := range memberIDs {
+		if len(idList) < 1 {
+			continue
+		}
+		var quoted []string
+		for _, id := range idList {
+			quoted = append(quoted, sysadmObjects.QuoteString(id))
+		}
+		conditions := make(map[string]string, 0)
+		conditions["member_type"] = "=" + strconv.Itoa(memberType)
+		conditions["memberid"] = " in (" + strings.Join(quoted, ",") + ")"
+		members, e := projectMemberRepository.OnPrimary().List("", nil, nil, conditions, 0, 0, nil)
+		if e != nil {
+			return ids, false, e
+		}
+		for _, m := range members {
+			found[m.ProjectID] = true
+		}
+	}
+
+	for id := range found {
+		ids = append(ids, id)
+	}
+
+	return ids, false, nil
+}
+
+// checkProjectMember 检查项目projectid及用户或用户组memberid是否存在
+func checkProjectMember(projectid string, memberType int, memberid string) error {
+	project, e := sysadmObjects.New().Get(projectid)
+	if e != nil || project.Deleted != 0 {
+		return fmt.Errorf("project %s is not exist", projectid)
+	}
+
+	switch memberType {
+	case MemberTypeUser:
+		if _, e := New().Get(memberid); e != nil {
+			return fmt.Errorf("user %s is not exist", memberid)
+		}
+	case MemberTypeGroup:
+		if _, e := groupRepository.Get(memberid); e != nil {
+			return fmt.Errorf("user group %s is not exist", memberid)
+		}
+	default:
+		return fmt.Errorf("member type %d is not valid", memberType)
+	}
+
+	return nil
+}
+
+// isMemberOf 判断项目成员m是否为用户userid本身或用户所属的用户组
+func isMemberOf(m ProjectMemberSchema, userid string, groupIDs []string) bool {
+	switch m.MemberType {
+	case MemberTypeUser:
+		return m.MemberID == userid
+	case MemberTypeGroup:
+		for _, id := range groupIDs {
+			if m.MemberID == id {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// projectMemberConditions 查询项目projectid中成员memberid的条件
+func projectMemberConditions(projectid string, memberType int, memberid string) map[string]string {
+	conditions := make(map[string]string, 0)
+	conditions["projectid"] = "=" + sysadmObjects.QuoteString(strings.TrimSpace(projectid))
+	conditions["member_type"] = "=" + strconv.Itoa(memberType)
+	conditions["memberid"] = "=" + sysadmObjects.QuoteString(strings.TrimSpace(memberid))
+
+	return conditions
+}
@@ -0,0 +1,72 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestGetProjectRole(t *testing.T) {
+	db := newFakeDB(t)
+	db.insert("user",
+		map[string]interface{}{"userid": "5", "username": "dev", "sysadmin_flag": int64(0), "deleted": int64(0)},
+		map[string]interface{}{"userid": "6", "username": "guest", "sysadmin_flag": int64(0), "deleted": int64(0)},
+	)
+	db.insert("project", map[string]interface{}{"projectid": "7", "ownerid": "1", "deleted": int64(0)})
+	db.insert("userGroupMember", map[string]interface{}{"id": "1", "groupid": "3", "userid": "5"})
+	db.insert("projectMember",
+		map[string]interface{}{"id": "1", "projectid": "7", "member_type": int64(MemberTypeUser), "memberid": "5", "role": ProjectRoleViewer},
+		map[string]interface{}{"id": "2", "projectid": "7", "member_type": int64(MemberTypeGroup), "memberid": "3", "role": ProjectRoleMaintainer},
+	)
+
+	// 用户直接及通过用户组成为项目成员时以级别最高的角色为准
+	if role, e := GetProjectRole("5", "7"); e != nil || role != ProjectRoleMaintainer {
+		t.Errorf("role of user 5 = %q, %v, want %s", role, e, ProjectRoleMaintainer)
+	}
+	if allowed, e := HasProjectPermission("5", "7", PermissionPush); e != nil || !allowed {
+		t.Errorf("user 5 should be allowed to push: %v", e)
+	}
+	if allowed, _ := HasProjectPermission("5", "7", PermissionDelete); allowed {
+		t.Errorf("user 5 should not be allowed to delete")
+	}
+	if role, e := GetProjectRole("6", "7"); e != nil || role != "" {
+		t.Errorf("role of user 6 = %q, %v, want empty", role, e)
+	}
+
+	// 角色用于权限判断，不能从可能延迟的从库读取
+	for _, tb := range []string{"user", "project", "userGroupMember", "projectMember"} {
+		if !db.onPrimary(tb) {
+			t.Errorf("the queries of table %s should be sent to the primary server", tb)
+		}
+	}
+}
+
+func TestRemoveProjectMember(t *testing.T) {
+	db := newFakeDB(t)
+
+	if e := RemoveProjectMember(" 7", MemberTypeUser, "5 "); e != nil {
+		t.Fatalf("remove user 5 from project 7 error: %s", e)
+	}
+
+	want := map[string]string{"projectid": "7", "member_type": strconv.Itoa(MemberTypeUser), "memberid": "5"}
+	if got := db.deleted("projectMember"); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("member should be deleted by %v, got %v in %v", want, got, db.executed("delete"))
+	}
+}
@@ -0,0 +1,405 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wangyysde/sysadmServer"
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+	sysadmSetting "sysadm/syssetting/app"
+)
+
+// apiTokenRepository 用户的API令牌
+var apiTokenRepository = sysadmObjects.NewRepository[ApiTokenSchema](apiTokenObjectName, apiTokenTableName, apiTokenPkName)
+
+// apiTokenNamePattern 令牌名称允许的字符
+var apiTokenNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9 ._:@-]*$`)
+
+// serviceAccountNamePattern 服务帐号名称允许的字符
+var serviceAccountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// 注册API令牌的配置项
+func init() {
+	def := sysadmSetting.SettingDefinition{Key: SettingKeyForApiTokenMaxDays, Type: sysadmSetting.SettingTypeInt,
+		Default: strconv.Itoa(DefaultApiTokenMaxDays), Scopes: []int{sysadmSetting.SettingScopeGlobal},
+		Validate: validIntRange(0, 36500), Description: "API令牌允许的最长有效天数，0表示不限制"}
+	if e := sysadmSetting.RegisterSetting(def); e != nil {
+		panic(e)
+	}
+}
+
+// IsApiToken 检查密码是否为API令牌。API令牌可以在使用用户名及密码认证的地方(如镜像仓库)代替密码
+func IsApiToken(password string) bool {
+	return strings.HasPrefix(password, ApiTokenPrefix)
+}
+
+// CreateApiToken 为用户创建名称为name，权限范围为scopes的API令牌，days为令牌的有效天数，0表示允许的最长有效天数。
+// 返回令牌的明文，令牌的明文只在创建时返回一次，数据库中只保存令牌的SHA256值
+func CreateApiToken(userid, name string, scopes []string, days int) (string, ApiTokenSchema, error) {
+	var row ApiTokenSchema
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > apiTokenNameMaxLength || !apiTokenNamePattern.MatchString(name) {
+		return "", row, fmt.Errorf("token name %q is not valid", name)
+	}
+
+	scopes, e := normalizeApiTokenScopes(scopes)
+	if e != nil {
+		return "", row, e
+	}
+
+	maxDays, e := getApiTokenMaxDays()
+	if e != nil {
+		return "", row, e
+	}
+	if days < 0 || (maxDays > 0 && days > maxDays) {
+		return "", row, fmt.Errorf("days of the token should be between 0 and %d", maxDays)
+	}
+	if days == 0 {
+		days = maxDays
+	}
+
+	user, e := New().Get(userid)
+	if e != nil {
+		return "", row, e
+	}
+	if user.Deleted != 0 {
+		return "", row, fmt.Errorf("user %s has been deleted", user.Username)
+	}
+
+	conditions := make(map[string]string, 0)
+	conditions["userid"] = "=" + sysadmObjects.QuoteString(user.Id)
+	conditions["name"] = "=" + sysadmObjects.QuoteString(name)
+	conditions["revoke_time"] = "=0"
+	exist, e := apiTokenRepository.OnPrimary().Count("", nil, nil, conditions)
+	if e != nil {
+		return "", row, e
+	}
+	if exist > 0 {
+		return "", row, fmt.Errorf("token %s of user %s has been exist", name, user.Username)
+	}
+
+	b := make([]byte, apiTokenSize)
+	if _, e := rand.Read(b); e != nil {
+		return "", row, e
+	}
+	token := ApiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	row = ApiTokenSchema{Userid: user.Id, Name: name, Token: hashApiToken(token), Prefix: token[:apiTokenDisplayLength],
+		Scopes: strings.Join(scopes, ","), CreationTime: int(now.Unix())}
+	if days > 0 {
+		row.ExpireTime = int(now.AddDate(0, 0, days).Unix())
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, apiTokenRepository)
+	if e != nil {
+		return "", row, e
+	}
+	id, e := tx.Tx.NextID(apiTokenTableName, apiTokenPkName)
+	if e != nil {
+		_ = tx.Rollback()
+		return "", row, e
+	}
+	row.Id = uint(id)
+	if e := apiTokenRepository.CreateTx(tx, row); e != nil {
+		_ = tx.Rollback()
+		return "", row, e
+	}
+
+	return token, row, tx.Commit()
+}
+
+// ListApiTokens 列出用户的所有API令牌，包括已过期及已撤销的令牌
+func ListApiTokens(userid string) ([]ApiTokenSchema, error) {
+	conditions := map[string]string{"userid": "=" + sysadmObjects.QuoteString(strings.TrimSpace(userid))}
+
+	return apiTokenRepository.List("", nil, nil, conditions, 0, 0, nil)
+}
+
+// GetApiToken 获取ID为id的API令牌
+func GetApiToken(id string) (ApiTokenSchema, error) {
+	return apiTokenRepository.Get(id)
+}
+
+// RevokeApiToken 撤销ID为id的API令牌，撤销后的令牌不能再使用
+func RevokeApiToken(id string) error {
+	token, e := apiTokenRepository.OnPrimary().Get(id)
+	if e != nil {
+		return e
+	}
+	if token.RevokeTime != 0 {
+		return nil
+	}
+
+	updateData := sysadmDB.FieldData{"revoke_time": time.Now().Unix()}
+	where := map[string]string{apiTokenPkName: strconv.Itoa(int(token.Id))}
+
+	return runData.dbConf.Entity.NewUpdateData(apiTokenTableName, updateData, where)
+}
+
+// AuthenticateApiToken 认证API令牌，令牌需有效且拥有权限范围scope。返回令牌所属的用户。
+// 令牌的最后使用时间每apiTokenLastUsedInterval秒最多更新一次
+func AuthenticateApiToken(token, scope string) (UserSchema, error) {
+	var user UserSchema
+	token = strings.TrimSpace(token)
+	if !IsApiToken(token) {
+		return user, ErrApiTokenInvalid
+	}
+
+	conditions := map[string]string{"token": "=" + sysadmObjects.QuoteString(hashApiToken(token))}
+	// 令牌的撤销需要立即生效，因此从主库读取令牌
+	rows, e := apiTokenRepository.OnPrimary().List("", nil, nil, conditions, 0, 1, nil)
+	if e != nil {
+		return user, e
+	}
+	if len(rows) < 1 {
+		return user, ErrApiTokenInvalid
+	}
+
+	row := rows[0]
+	now := time.Now().Unix()
+	if row.RevokeTime != 0 {
+		return user, fmt.Errorf("%w: token %s has been revoked", ErrApiTokenInvalid, row.Prefix)
+	}
+	if row.ExpireTime != 0 && int64(row.ExpireTime) <= now {
+		return user, fmt.Errorf("%w: token %s has expired", ErrApiTokenInvalid, row.Prefix)
+	}
+	if !apiTokenHasScope(row.Scopes, scope) {
+		return user, fmt.Errorf("%w: token %s has not scope %s", ErrApiTokenScope, row.Prefix, scope)
+	}
+
+	user, e = New().Get(row.Userid)
+	if e != nil {
+		return user, e
+	}
+	if user.Deleted != 0 {
+		return user, fmt.Errorf("%w: user %s of token %s has been deleted", ErrApiTokenInvalid, user.Username, row.Prefix)
+	}
+
+	if now-int64(row.LastUsedTime) >= apiTokenLastUsedInterval {
+		// 令牌是有效的，最后使用时间更新失败不影响认证结果
+		updateData := sysadmDB.FieldData{"last_used_time": now}
+		where := map[string]string{apiTokenPkName: strconv.Itoa(int(row.Id))}
+		_ = runData.dbConf.Entity.NewUpdateData(apiTokenTableName, updateData, where)
+	}
+
+	return user, nil
+}
+
+// BearerToken 获取请求的Authorization头中的Bearer令牌，请求没有携带Bearer令牌时返回false
+func BearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return "", false
+	}
+
+	return strings.TrimSpace(auth[7:]), true
+}
+
+// SetApiTokenUser 将使用API令牌认证的用户保存到请求的上下文中
+func SetApiTokenUser(c *sysadmServer.Context, user UserSchema) {
+	c.Set(apiTokenUserKey, user)
+}
+
+// GetApiTokenUser 获取请求上下文中使用API令牌认证的用户，请求未使用API令牌认证时返回false
+func GetApiTokenUser(c *sysadmServer.Context) (UserSchema, bool) {
+	v, ok := c.Get(apiTokenUserKey)
+	if !ok {
+		return UserSchema{}, false
+	}
+	user, ok := v.(UserSchema)
+
+	return user, ok
+}
+
+// AuthenticateWithToken 认证使用API令牌代替密码的用户，如镜像仓库的客户端。令牌需属于用户名为username的用户
+func AuthenticateWithToken(username, token, scope string) (UserSchema, error) {
+	user, e := AuthenticateApiToken(token, scope)
+	if e != nil {
+		return user, e
+	}
+	if user.Username != strings.TrimSpace(username) {
+		return UserSchema{}, fmt.Errorf("%w: token does not belong to user %s", ErrApiTokenInvalid, username)
+	}
+
+	return user, nil
+}
+
+// CreateServiceAccount 创建名称为name的服务帐号，服务帐号没有密码，只能使用API令牌认证
+func CreateServiceAccount(name, comment string) (UserSchema, error) {
+	var user UserSchema
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > apiTokenNameMaxLength || !serviceAccountNamePattern.MatchString(name) {
+		return user, fmt.Errorf("service account name %q is not valid", name)
+	}
+
+	_, found, e := getUserByName(name)
+	if e != nil {
+		return user, e
+	}
+	if found {
+		return user, fmt.Errorf("user %s has been exist", name)
+	}
+
+	u := New()
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, u)
+	if e != nil {
+		return user, e
+	}
+	id, e := tx.Tx.NextID(u.TableName, u.PkName)
+	if e != nil {
+		_ = tx.Rollback()
+		return user, e
+	}
+
+	now := time.Now().Unix()
+	user = UserSchema{Id: strconv.FormatUint(id, 10), Username: name, Realname: name,
+		Comment: identityReplacer.Replace(strings.TrimSpace(comment)), CreationTime: int(now), UpdateTime: int(now),
+		AuthSource: AuthSourceServiceAccount}
+	insertData := sysadmDB.FieldData{
+		u.PkName:        user.Id,
+		"username":      user.Username,
+		"password":      "",
+		"salt":          "",
+		"realname":      user.Realname,
+		"comment":       user.Comment,
+		"creation_time": now,
+		"update_time":   now,
+		"auth_source":   AuthSourceServiceAccount,
+	}
+	if e := tx.Tx.NewInsertData(u.TableName, insertData); e != nil {
+		_ = tx.Rollback()
+		return user, e
+	}
+
+	return user, tx.Commit()
+}
+
+// ListServiceAccounts 列出所有未被删除的服务帐号
+func ListServiceAccounts() ([]UserSchema, error) {
+	conditions := make(map[string]string, 0)
+	conditions["auth_source"] = "=" + sysadmObjects.QuoteString(AuthSourceServiceAccount)
+	conditions["deleted"] = "=0"
+
+	return New().List("", nil, nil, conditions, 0, 0, nil)
+}
+
+// DeleteServiceAccount 删除服务帐号并撤销其所有API令牌
+func DeleteServiceAccount(userid string) error {
+	u := New()
+	user, e := u.Get(userid)
+	if e != nil {
+		return e
+	}
+	if user.AuthSource != AuthSourceServiceAccount {
+		return fmt.Errorf("user %s is not a service account", user.Username)
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, u)
+	if e != nil {
+		return e
+	}
+	if e := u.SoftDeleteTx(tx, []string{user.Id}); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	updateData := sysadmDB.FieldData{"revoke_time": time.Now().Unix()}
+	where := map[string]string{"userid": user.Id}
+	if e := tx.Tx.NewUpdateData(apiTokenTableName, updateData, where); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// getApiTokenMaxDays 获取API令牌允许的最长有效天数，配置项未设置时使用默认值
+func getApiTokenMaxDays() (int, error) {
+	days, _, e := sysadmSetting.New().ResolveInt(SettingKeyForApiTokenMaxDays, sysadmSetting.SettingContext{})
+	if e != nil {
+		if sysadmSetting.IsSettingNotSet(e) {
+			return DefaultApiTokenMaxDays, nil
+		}
+		return 0, fmt.Errorf("get setting %s error: %s", SettingKeyForApiTokenMaxDays, e)
+	}
+
+	return days, nil
+}
+
+// normalizeApiTokenScopes 检查权限范围是否有效并去除重复的权限范围
+func normalizeApiTokenScopes(scopes []string) ([]string, error) {
+	var ret []string
+	seen := make(map[string]bool, 0)
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+
+		valid := false
+		for _, v := range ApiTokenScopes {
+			if s == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("token scope %s is not valid", s)
+		}
+
+		seen[s] = true
+		ret = append(ret, s)
+	}
+
+	if len(ret) < 1 {
+		return nil, fmt.Errorf("at least one scope should be specified for a token")
+	}
+
+	return ret, nil
+}
+
+// apiTokenHasScope 检查以逗号分隔的权限范围scopes是否包含scope，拥有镜像仓库全部权限的令牌也可以拉取镜像
+func apiTokenHasScope(scopes, scope string) bool {
+	for _, s := range strings.Split(scopes, ",") {
+		s = strings.TrimSpace(s)
+		if s == scope || (s == ApiTokenScopeRegistry && scope == ApiTokenScopeRegistryPull) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hashApiToken 返回API令牌的SHA256值。令牌是随机生成的，不需要使用慢哈希
+func hashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
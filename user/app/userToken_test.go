@@ -0,0 +1,98 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeApiTokenScopes(t *testing.T) {
+	scopes, e := normalizeApiTokenScopes([]string{" API", "registry:pull", "", "api"})
+	if e != nil {
+		t.Fatalf("normalize scopes error: %s", e)
+	}
+	if want := []string{ApiTokenScopeApi, ApiTokenScopeRegistryPull}; !reflect.DeepEqual(scopes, want) {
+		t.Errorf("scopes = %v, want %v", scopes, want)
+	}
+
+	for _, invalid := range [][]string{nil, {""}, {"api", "admin"}} {
+		if _, e := normalizeApiTokenScopes(invalid); e == nil {
+			t.Errorf("scopes %v should be invalid", invalid)
+		}
+	}
+}
+
+func TestApiTokenHasScope(t *testing.T) {
+	tests := []struct {
+		scopes string
+		scope  string
+		want   bool
+	}{
+		{"api", ApiTokenScopeApi, true},
+		{"api,apiserver", ApiTokenScopeApiserver, true},
+		{"api", ApiTokenScopeApiserver, false},
+		{"registry", ApiTokenScopeRegistryPull, true},
+		{"registry", ApiTokenScopeRegistry, true},
+		{"registry:pull", ApiTokenScopeRegistry, false},
+		{"", ApiTokenScopeApi, false},
+	}
+
+	for _, tt := range tests {
+		if got := apiTokenHasScope(tt.scopes, tt.scope); got != tt.want {
+			t.Errorf("apiTokenHasScope(%q, %q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestHashApiToken(t *testing.T) {
+	if !IsApiToken(ApiTokenPrefix+"abc") || IsApiToken("password") {
+		t.Errorf("IsApiToken can not tell API tokens from passwords")
+	}
+
+	h := hashApiToken(ApiTokenPrefix + "abc")
+	if len(h) != 64 || h == hashApiToken(ApiTokenPrefix+"abd") {
+		t.Errorf("hash of token %s is not valid", h)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer " + ApiTokenPrefix + "abc", ApiTokenPrefix + "abc", true},
+		{"bearer  " + ApiTokenPrefix + "abc ", ApiTokenPrefix + "abc", true},
+		{"Basic dXNlcjpwYXNz", "", false},
+		{"Bearer", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/api/v1.0/user/tokenlist", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		token, ok := BearerToken(r)
+		if token != tt.token || ok != tt.ok {
+			t.Errorf("BearerToken(%q) = %q, %v, want %q, %v", tt.header, token, ok, tt.token, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	sysadmDB "sysadm/db"
+	sysadmObjects "sysadm/objects/app"
+	sysadmSetting "sysadm/syssetting/app"
+)
+
+// recoveryCodeRepository 用户的TOTP恢复码
+var recoveryCodeRepository = sysadmObjects.NewRepository[RecoveryCodeSchema](recoveryCodeObjectName, recoveryCodeTableName, recoveryCodePkName)
+
+// totpSecretEncoding TOTP密钥使用不带填充的base32编码，与认证器的要求一致
+var totpSecretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpSettings TOTP策略配置项的定义
+var totpSettings = []sysadmSetting.SettingDefinition{
+	{Key: SettingKeyForTotpRequiredSysadmin, Type: sysadmSetting.SettingTypeBool, Default: "false",
+		Scopes: []int{sysadmSetting.SettingScopeGlobal}, Description: "系统管理员是否必须启用TOTP两步验证"},
+	{Key: SettingKeyForTotpRequired, Type: sysadmSetting.SettingTypeBool, Default: "false",
+		Scopes:      []int{sysadmSetting.SettingScopeGlobal, sysadmSetting.SettingScopeUserGroup, sysadmSetting.SettingScopeUser},
+		Description: "用户是否必须启用TOTP两步验证，用户属于任一必须启用的用户组时必须启用"},
+}
+
+// 注册TOTP策略配置项，TOTP密钥在轮换主密钥时重新加密
+func init() {
+	for _, def := range totpSettings {
+		if e := sysadmSetting.RegisterSetting(def); e != nil {
+			panic(e)
+		}
+	}
+
+	sysadmObjects.RegisterSecretRotator(New())
+}
+
+// IsTotpRequired 根据TOTP策略检查用户是否必须启用TOTP
+func IsTotpRequired(user UserSchema) (bool, error) {
+	s := sysadmSetting.New()
+	if user.SysadminFlag == 1 {
+		required, _, e := s.ResolveBool(SettingKeyForTotpRequiredSysadmin, sysadmSetting.SettingContext{})
+		if e != nil && !sysadmSetting.IsSettingNotSet(e) {
+			return false, e
+		}
+		if required {
+			return true, nil
+		}
+	}
+
+	groupIDs, e := GetUserGroupIDs(user.Id)
+	if e != nil {
+		return false, e
+	}
+
+	// 用户级别的设置优先于用户组级别，所以每个用户组都需要与用户一起解析
+	contexts := []sysadmSetting.SettingContext{{UserID: user.Id}}
+	for _, gid := range groupIDs {
+		contexts = append(contexts, sysadmSetting.SettingContext{UserID: user.Id, UserGroupID: gid})
+	}
+	for _, ctx := range contexts {
+		required, _, e := s.ResolveBool(SettingKeyForTotpRequired, ctx)
+		if e != nil && !sysadmSetting.IsSettingNotSet(e) {
+			return false, e
+		}
+		if required {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// TotpURI 返回认证器扫描二维码时使用的otpauth地址
+func TotpURI(issuer, username, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(username)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(totpDigits))
+	q.Set("period", strconv.Itoa(totpPeriod))
+
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// BeginTotpEnrollment 为用户生成新的TOTP密钥，返回密钥及otpauth地址。用户使用认证器添加密钥后需调用ConfirmTotpEnrollment确认绑定
+func (u User) BeginTotpEnrollment(userid string) (secret, uri string, e error) {
+	user, e := u.Get(userid)
+	if e != nil {
+		return "", "", e
+	}
+	if user.TotpEnabled == 1 {
+		return "", "", fmt.Errorf("totp of user %s has been enabled", user.Username)
+	}
+
+	b := make([]byte, totpSecretSize)
+	if _, e = rand.Read(b); e != nil {
+		return "", "", e
+	}
+	secret = totpSecretEncoding.EncodeToString(b)
+
+	encrypted, e := sysadmObjects.EncryptSecret(secret)
+	if e != nil {
+		return "", "", e
+	}
+	updateData := make(sysadmDB.FieldData, 0)
+	updateData["totp_secret"] = sysadmObjects.QuoteString(encrypted)
+	updateData["totp_enabled"] = 0
+	updateData["totp_last_step"] = 0
+	updateData["update_time"] = time.Now().Unix()
+	if e = runData.dbConf.Entity.NewUpdateData(u.TableName, updateData, map[string]string{u.PkName: user.Id}); e != nil {
+		return "", "", e
+	}
+
+	return secret, TotpURI(DefaultTotpIssuer, user.Username, secret), nil
+}
+
+// ConfirmTotpEnrollment 使用认证器生成的验证码确认绑定TOTP，启用TOTP并返回新生成的恢复码
+func (u User) ConfirmTotpEnrollment(userid, code string) ([]string, error) {
+	user, e := u.Get(userid)
+	if e != nil {
+		return nil, e
+	}
+	if user.TotpEnabled == 1 {
+		return nil, fmt.Errorf("totp of user %s has been enabled", user.Username)
+	}
+	if user.TotpSecret == "" {
+		return nil, fmt.Errorf("totp enrollment of user %s has not been started", user.Username)
+	}
+
+	step, ok := validateTotp(user.TotpSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrTotpCodeIncorrect
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, u)
+	if e != nil {
+		return nil, e
+	}
+
+	updateData := make(sysadmDB.FieldData, 0)
+	updateData["totp_enabled"] = 1
+	updateData["totp_last_step"] = step
+	updateData["update_time"] = time.Now().Unix()
+	if e := tx.Tx.NewUpdateData(u.TableName, updateData, map[string]string{u.PkName: user.Id}); e != nil {
+		_ = tx.Rollback()
+		return nil, e
+	}
+
+	codes, e := createRecoveryCodes(tx, user.Id)
+	if e != nil {
+		_ = tx.Rollback()
+		return nil, e
+	}
+
+	return codes, tx.Commit()
+}
+
+// VerifyTotpLogin 校验用户登录时输入的TOTP验证码或恢复码。验证码在有效期内只能使用一次，恢复码只能使用一次
+func (u User) VerifyTotpLogin(userid, code string) (bool, error) {
+	// 上次使用的时间步刚刚被写入，需要从主库读取以防止验证码被重放
+	user, e := u.OnPrimary().Get(userid)
+	if e != nil {
+		return false, e
+	}
+	if user.TotpEnabled != 1 || user.TotpSecret == "" {
+		return false, fmt.Errorf("totp of user %s has not been enabled", user.Username)
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		step, ok := validateTotp(user.TotpSecret, code, time.Now(), user.TotpLastStep)
+		if !ok {
+			return false, nil
+		}
+
+		// 只有时间步仍小于本次使用的时间步时才更新，并发请求使用同一个验证码时只有一个能够成功
+		updateData := sysadmDB.FieldData{"totp_last_step": step}
+		conditions := []sysadmDB.Condition{{Field: u.PkName, Op: "=", Value: user.Id}, {Field: "totp_last_step", Op: "<", Value: step}}
+		num, e := sysadmDB.UpdateIf(runData.dbConf.Entity, u.TableName, updateData, conditions)
+		if e != nil {
+			return false, e
+		}
+
+		return num > 0, nil
+	}
+
+	return useRecoveryCode(user.Id, code)
+}
+
+// VerifyTotpLoginWithLimit 校验没有会话的登录(如API登录)的TOTP验证码或恢复码。每个用户在totpAttemptsWindow秒内最多可以尝试
+// totpMaxAttempts次，超过限制时返回ErrTotpAttemptsExceeded，校验成功后重新计数
+func (u User) VerifyTotpLoginWithLimit(userid, code string) (bool, error) {
+	user, e := u.OnPrimary().Get(userid)
+	if e != nil {
+		return false, e
+	}
+
+	now := time.Now().Unix()
+	attempts := user.TotpAttempts
+	updateData := sysadmDB.FieldData{}
+	if now-int64(user.TotpAttemptTime) >= totpAttemptsWindow {
+		attempts = 0
+		updateData["totp_attempt_time"] = now
+	}
+	if attempts >= totpMaxAttempts {
+		return false, ErrTotpAttemptsExceeded
+	}
+
+	// 先计入本次尝试再校验，计数已被并发的请求修改时本次尝试被拒绝
+	updateData["totp_attempts"] = attempts + 1
+	conditions := []sysadmDB.Condition{{Field: u.PkName, Op: "=", Value: user.Id}, {Field: "totp_attempts", Op: "=", Value: user.TotpAttempts},
+		{Field: "totp_attempt_time", Op: "=", Value: user.TotpAttemptTime}}
+	num, e := sysadmDB.UpdateIf(runData.dbConf.Entity, u.TableName, updateData, conditions)
+	if e != nil {
+		return false, e
+	}
+	if num < 1 {
+		return false, ErrTotpAttemptsExceeded
+	}
+
+	ok, e := u.VerifyTotpLogin(user.Id, code)
+	if e != nil || !ok {
+		return ok, e
+	}
+
+	reset := sysadmDB.FieldData{"totp_attempts": 0}
+	if _, e := sysadmDB.UpdateIf(runData.dbConf.Entity, u.TableName, reset, conditions[:1]); e != nil {
+		return true, e
+	}
+
+	return true, nil
+}
+
+// DisableTotp 停用用户的TOTP，删除密钥及恢复码
+func (u User) DisableTotp(userid string) error {
+	user, e := u.Get(userid)
+	if e != nil {
+		return e
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, u)
+	if e != nil {
+		return e
+	}
+
+	updateData := make(sysadmDB.FieldData, 0)
+	updateData["totp_secret"] = sysadmObjects.QuoteString("")
+	updateData["totp_enabled"] = 0
+	updateData["totp_last_step"] = 0
+	updateData["update_time"] = time.Now().Unix()
+	if e := tx.Tx.NewUpdateData(u.TableName, updateData, map[string]string{u.PkName: user.Id}); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	dd := sysadmDB.SelectData{
+		Tb:    []string{recoveryCodeTableName},
+		Where: map[string]string{"userid": user.Id},
+	}
+	if e := tx.Tx.NewDeleteData(&dd); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes 为已启用TOTP的用户重新生成恢复码，原有的恢复码全部失效
+func (u User) RegenerateRecoveryCodes(userid string) ([]string, error) {
+	user, e := u.Get(userid)
+	if e != nil {
+		return nil, e
+	}
+	if user.TotpEnabled != 1 {
+		return nil, fmt.Errorf("totp of user %s has not been enabled", user.Username)
+	}
+
+	tx, e := sysadmObjects.BeginTx(runData.dbConf.Entity, u)
+	if e != nil {
+		return nil, e
+	}
+
+	codes, e := createRecoveryCodes(tx, user.Id)
+	if e != nil {
+		_ = tx.Rollback()
+		return nil, e
+	}
+
+	return codes, tx.Commit()
+}
+
+// createRecoveryCodes 删除用户原有的恢复码并生成新的恢复码，数据库中只保存恢复码的SHA256值
+func createRecoveryCodes(tx sysadmObjects.ObjectTx, userid string) ([]string, error) {
+	dd := sysadmDB.SelectData{
+		Tb:    []string{recoveryCodeTableName},
+		Where: map[string]string{"userid": userid},
+	}
+	if e := tx.Tx.NewDeleteData(&dd); e != nil {
+		return nil, e
+	}
+
+	now := int(time.Now().Unix())
+	var codes []string
+	for i := 0; i < recoveryCodeNum; i++ {
+		b := make([]byte, recoveryCodeLength)
+		if _, e := rand.Read(b); e != nil {
+			return nil, e
+		}
+		code := strings.ToLower(totpSecretEncoding.EncodeToString(b))[:recoveryCodeLength]
+
+		id, e := tx.Tx.NextID(recoveryCodeTableName, recoveryCodePkName)
+		if e != nil {
+			return nil, e
+		}
+		row := RecoveryCodeSchema{Id: uint(id), Userid: userid, Code: hashRecoveryCode(code), CreationTime: now}
+		if e := recoveryCodeRepository.CreateTx(tx, row); e != nil {
+			return nil, e
+		}
+
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+	}
+
+	return codes, nil
+}
+
+// useRecoveryCode 校验恢复码，恢复码正确时将其标记为已使用
+func useRecoveryCode(userid, code string) (bool, error) {
+	code = strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+	if len(code) != recoveryCodeLength {
+		return false, nil
+	}
+
+	conditions := make(map[string]string, 0)
+	conditions["userid"] = "=" + sysadmObjects.QuoteString(userid)
+	conditions["code"] = "=" + sysadmObjects.QuoteString(hashRecoveryCode(code))
+	conditions["used_time"] = "=0"
+	rows, e := recoveryCodeRepository.OnPrimary().List("", nil, nil, conditions, 0, 1, nil)
+	if e != nil || len(rows) < 1 {
+		return false, e
+	}
+
+	// 只有恢复码仍未被使用时才标记为已使用，并发请求使用同一个恢复码时只有一个能够成功
+	updateData := sysadmDB.FieldData{"used_time": time.Now().Unix()}
+	unused := []sysadmDB.Condition{{Field: recoveryCodePkName, Op: "=", Value: rows[0].Id}, {Field: "used_time", Op: "=", Value: 0}}
+	num, e := sysadmDB.UpdateIf(runData.dbConf.Entity, recoveryCodeTableName, updateData, unused)
+	if e != nil {
+		return false, e
+	}
+
+	return num > 0, nil
+}
+
+// hashRecoveryCode 返回恢复码的SHA256值。恢复码是随机生成的，不需要使用慢哈希
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// validateTotp 校验验证码是否与now前后totpSkew个时间步内的验证码一致，且时间步大于上次使用的时间步lastStep。
+// 返回验证码对应的时间步
+func validateTotp(secret, code string, now time.Time, lastStep int) (int, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	key, e := totpSecretEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if e != nil {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= int64(lastStep) {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(step))), []byte(code)) == 1 {
+			return int(step), true
+		}
+	}
+
+	return 0, false
+}
+
+// hotp 按照RFC 4226计算计数器counter对应的验证码
+func hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
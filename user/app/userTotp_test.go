@@ -0,0 +1,101 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package app
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// RFC 6238附录B中SHA1的测试数据，取验证码的后6位
+func TestValidateTotp(t *testing.T) {
+	secret := totpSecretEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		now := time.Unix(tt.unix, 0)
+		step, ok := validateTotp(secret, tt.code, now, 0)
+		if !ok || int64(step) != tt.unix/totpPeriod {
+			t.Errorf("code %s at %d should be valid at step %d, got %d %v", tt.code, tt.unix, tt.unix/totpPeriod, step, ok)
+		}
+
+		// 同一个验证码不能重复使用
+		if _, ok := validateTotp(secret, tt.code, now, step); ok {
+			t.Errorf("code %s at %d should not be used twice", tt.code, tt.unix)
+		}
+	}
+
+	// 允许前后一个时间步的偏差
+	if _, ok := validateTotp(secret, "081804", time.Unix(1111111109+totpPeriod, 0), 0); !ok {
+		t.Errorf("code of the previous step should be valid")
+	}
+	if _, ok := validateTotp(secret, "081804", time.Unix(1111111109+3*totpPeriod, 0), 0); ok {
+		t.Errorf("code of three steps ago should not be valid")
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "000000"} {
+		if _, ok := validateTotp(secret, code, time.Unix(59, 0), 0); ok {
+			t.Errorf("code %q should not be valid", code)
+		}
+	}
+}
+
+func TestTotpURI(t *testing.T) {
+	uri := TotpURI("sysadm", "alice smith", "JBSWY3DPEHPK3PXP")
+	u, e := url.Parse(uri)
+	if e != nil {
+		t.Fatalf("parse uri %s error: %s", uri, e)
+	}
+
+	q := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/sysadm:alice smith" || q.Get("secret") != "JBSWY3DPEHPK3PXP" ||
+		q.Get("issuer") != "sysadm" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("uri %s is not valid", uri)
+	}
+}
+
+// 删除恢复码的条件的值只能被加上一次引号
+func TestDeleteRecoveryCodes(t *testing.T) {
+	db := newFakeDB(t)
+	db.insert("user", map[string]interface{}{"userid": "5", "username": "dev", "totp_enabled": int64(1), "deleted": int64(0)})
+
+	codes, e := New().RegenerateRecoveryCodes("5")
+	if e != nil || len(codes) != recoveryCodeNum {
+		t.Fatalf("regenerate recovery codes of user 5 = %d codes, %v", len(codes), e)
+	}
+	if e := New().DisableTotp("5"); e != nil {
+		t.Fatalf("disable totp of user 5 error: %s", e)
+	}
+
+	want := map[string]string{"userid": "5"}
+	deleted := db.deleted("userRecoveryCode")
+	if len(deleted) != 2 || !reflect.DeepEqual(deleted[0], want) || !reflect.DeepEqual(deleted[1], want) {
+		t.Errorf("recovery codes should be deleted by %v, got %v in %v", want, deleted, db.executed("delete"))
+	}
+}
@@ -18,7 +18,12 @@
 package app
 
 import (
+	"net/http"
+	"sync"
+	"time"
+
 	sessions "github.com/wangyysde/sysadmSessions"
+	jose "gopkg.in/square/go-jose.v2"
 	sysadmDB "sysadm/db"
 	sysadmObjects "sysadm/objects/app"
 	"sysadm/sysadmLog"
@@ -26,12 +31,7 @@ import (
 )
 
 type User struct {
-	// object name. the value should be "user"
-	Name string
-	// table name which hold project data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[UserSchema]
 }
 
 // 用户表结构
@@ -42,14 +42,14 @@ type UserSchema struct {
 	Username string `form:"username" json:"username" yaml:"username" xml:"username" db:"username"`
 	// 用户的电子邮件地址，最长不超过255个字符
 	Email string `form:"email" json:"email" yaml:"email" xml:"email" db:"email"`
-	// 用户帐号密码，最长不超长40个字符
+	// 用户帐号密码，加密后的密码，除旧的md5密码外均以加密算法为前缀
 	Password string `form:"password" json:"password" yaml:"password" xml:"password" db:"password"`
 	// 用户真实名字,最长不超过255个字符
 	Realname string `form:"realname" json:"realname" yaml:"realname" xml:"realname" db:"realname"`
 	// 描述，最找不超过255个字符
 	Comment string `form:"comment" json:"comment" yaml:"comment" xml:"comment" db:"comment"`
 	// 删除标识，0表示正常， 1表示已删除
-	Deleted int `form:"deleted" json:"deleted" yaml:"deleted" xml:"deleted" db:"deleted"`
+	Deleted int `form:"deleted" json:"deleted" yaml:"deleted" xml:"deleted" db:"deleted" softdelete:"flag"`
 	// 上次用户被重置密码的操作者对应的ID
 	ResetUuid string `form:"reset_uuid" json:"reset_uuid" yaml:"reset_uuid" xml:"reset_uuid" db:"reset_uuid"`
 	// 加密密码的Salt
@@ -60,6 +60,240 @@ type UserSchema struct {
 	CreationTime int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time" db:"creation_time"`
 	// 用户更新的时间截
 	UpdateTime int `form:"update_time" json:"update_time" yaml:"update_time" xml:"update_time" db:"update_time"`
+	// 用户最后一次设置密码的时间截，0表示从未按照密码策略设置过密码
+	PasswordTime int `form:"password_time" json:"password_time" yaml:"password_time" xml:"password_time" db:"password_time"`
+	// 用户的认证来源，AuthSourceLocal表示本地用户，其它表示首次登录时从外部认证源自动创建的用户
+	AuthSource string `form:"auth_source" json:"auth_source" yaml:"auth_source" xml:"auth_source" db:"auth_source"`
+	// 用户在外部认证源中不可变的唯一标识，OIDC用户为issuer与sub的组合。外部认证源的用户按该标识绑定本地用户
+	ExternalID string `form:"external_id" json:"external_id" yaml:"external_id" xml:"external_id" db:"external_id"`
+	// TOTP的密钥(base32编码)，使用主密钥加密存储，为空表示未开始绑定TOTP
+	TotpSecret string `form:"totp_secret" json:"-" yaml:"-" xml:"-" db:"totp_secret" secret:"true"`
+	// 是否已启用TOTP 0表示未启用(包括已生成密钥但尚未确认绑定) 1表示已启用
+	TotpEnabled int `form:"totp_enabled" json:"totp_enabled" yaml:"totp_enabled" xml:"totp_enabled" db:"totp_enabled"`
+	// 最后一次使用的TOTP验证码对应的时间步，用于防止验证码被重放
+	TotpLastStep int `form:"totp_last_step" json:"-" yaml:"-" xml:"-" db:"totp_last_step"`
+	// 没有会话的登录在当前计数周期内尝试第二因素的次数
+	TotpAttempts int `form:"totp_attempts" json:"-" yaml:"-" xml:"-" db:"totp_attempts"`
+	// 当前计数周期开始的时间截
+	TotpAttemptTime int `form:"totp_attempt_time" json:"-" yaml:"-" xml:"-" db:"totp_attempt_time"`
+}
+
+// 用户TOTP恢复码表结构
+type RecoveryCodeSchema struct {
+	// 记录ID
+	Id uint `form:"id" json:"id" yaml:"id" xml:"id" db:"id"`
+	// 用户ID
+	Userid string `form:"userid" json:"userid" yaml:"userid" xml:"userid" db:"userid"`
+	// 恢复码的SHA256值
+	Code string `form:"code" json:"-" yaml:"-" xml:"-" db:"code"`
+	// 恢复码被使用的时间截，0表示未被使用
+	UsedTime int `form:"used_time" json:"used_time" yaml:"used_time" xml:"used_time" db:"used_time"`
+	// 生成恢复码的时间截
+	CreationTime int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time" db:"creation_time"`
+}
+
+// 用户API令牌表结构
+type ApiTokenSchema struct {
+	// 记录ID
+	Id uint `form:"id" json:"id" yaml:"id" xml:"id" db:"id"`
+	// 令牌所属的用户ID，可以是服务帐号
+	Userid string `form:"userid" json:"userid" yaml:"userid" xml:"userid" db:"userid"`
+	// 令牌的名称
+	Name string `form:"name" json:"name" yaml:"name" xml:"name" db:"name"`
+	// 令牌的SHA256值
+	Token string `form:"token" json:"-" yaml:"-" xml:"-" db:"token"`
+	// 令牌明文的前几个字符，用于识别令牌
+	Prefix string `form:"prefix" json:"prefix" yaml:"prefix" xml:"prefix" db:"prefix"`
+	// 令牌的权限范围，多个权限范围以逗号分隔
+	Scopes string `form:"scopes" json:"scopes" yaml:"scopes" xml:"scopes" db:"scopes"`
+	// 令牌过期的时间截，0表示永不过期
+	ExpireTime int `form:"expire_time" json:"expire_time" yaml:"expire_time" xml:"expire_time" db:"expire_time"`
+	// 令牌最后一次被使用的时间截，0表示从未被使用
+	LastUsedTime int `form:"last_used_time" json:"last_used_time" yaml:"last_used_time" xml:"last_used_time" db:"last_used_time"`
+	// 令牌被撤销的时间截，0表示未被撤销
+	RevokeTime int `form:"revoke_time" json:"revoke_time" yaml:"revoke_time" xml:"revoke_time" db:"revoke_time"`
+	// 创建令牌的时间截
+	CreationTime int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time" db:"creation_time"`
+}
+
+// 用户历史密码表结构
+type PasswordHistorySchema struct {
+	// 记录ID
+	Id uint `form:"id" json:"id" yaml:"id" xml:"id" db:"id"`
+	// 用户ID
+	Userid string `form:"userid" json:"userid" yaml:"userid" xml:"userid" db:"userid"`
+	// 加密后的密码
+	Password string `form:"password" json:"password" yaml:"password" xml:"password" db:"password"`
+	// 设置密码的时间截
+	CreationTime int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time" db:"creation_time"`
+}
+
+// 密码策略，通过系统配置项设置
+type PasswordPolicy struct {
+	// 密码的最小长度
+	MinLength int
+	// 密码至少需要包含的字符种类数(小写字母，大写字母，数字和其它字符)
+	Complexity int
+	// 新密码不能与最近使用过的多少个密码相同，0表示不检查
+	History int
+	// 密码的有效天数，0表示永不过期
+	MaxAgeDays int
+}
+
+// 用户组表结构
+type UserGroupSchema struct {
+	// 用户组ID
+	Id uint `form:"groupid" json:"groupid" yaml:"groupid" xml:"groupid" db:"groupid"`
+	// 用户组名称，最长不超过255个字符
+	Name string `form:"name" json:"name" yaml:"name" xml:"name" db:"name"`
+	// 描述，最长不超过255个字符
+	Comment string `form:"comment" json:"comment" yaml:"comment" xml:"comment" db:"comment"`
+	// 用户组创建的时间截
+	CreationTime int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time" db:"creation_time"`
+	// 用户组更新的时间截
+	UpdateTime int `form:"update_time" json:"update_time" yaml:"update_time" xml:"update_time" db:"update_time"`
+}
+
+// 用户组成员表结构
+type UserGroupMemberSchema struct {
+	// 记录ID
+	Id uint `form:"id" json:"id" yaml:"id" xml:"id" db:"id"`
+	// 用户组ID
+	GroupID string `form:"groupid" json:"groupid" yaml:"groupid" xml:"groupid" db:"groupid"`
+	// 用户ID
+	Userid string `form:"userid" json:"userid" yaml:"userid" xml:"userid" db:"userid"`
+	// 用户加入用户组的时间截
+	CreationTime int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time" db:"creation_time"`
+}
+
+// 项目成员表结构，项目成员可以是用户，也可以是用户组
+type ProjectMemberSchema struct {
+	// 记录ID
+	Id uint `form:"id" json:"id" yaml:"id" xml:"id" db:"id"`
+	// 项目ID
+	ProjectID string `form:"projectid" json:"projectid" yaml:"projectid" xml:"projectid" db:"projectid"`
+	// 成员类型，MemberTypeUser表示用户，MemberTypeGroup表示用户组
+	MemberType int `form:"member_type" json:"member_type" yaml:"member_type" xml:"member_type" db:"member_type"`
+	// 用户ID或用户组ID
+	MemberID string `form:"memberid" json:"memberid" yaml:"memberid" xml:"memberid" db:"memberid"`
+	// 成员在项目中的角色，为owner, maintainer或viewer
+	Role string `form:"role" json:"role" yaml:"role" xml:"role" db:"role"`
+	// 成员加入项目的时间截
+	CreationTime int `form:"creation_time" json:"creation_time" yaml:"creation_time" xml:"creation_time" db:"creation_time"`
+}
+
+// Identity 外部认证源认证通过后返回的用户信息
+type Identity struct {
+	// 用户名
+	Username string
+	// 用户在外部认证源中不可变的唯一标识，为空时按用户名绑定本地用户
+	ExternalID string
+	// 用户的电子邮件地址
+	Email string
+	// 用户的真实名字
+	Realname string
+	// 用户在外部认证源中所属的组按照组映射关系映射后的本地用户组名称
+	Groups []string
+	// 由外部认证源管理成员的本地用户组名称，用户不再属于的这些用户组时将被从中移除
+	ManagedGroups []string
+	// 用户是否为系统管理员
+	Sysadmin bool
+}
+
+// Authenticator 用户认证接口，本地用户和LDAP等外部认证源分别实现该接口
+type Authenticator interface {
+	// Name 认证源的名称，外部认证源自动创建的用户以该名称为认证来源
+	Name() string
+	// Authenticate 使用username和password认证用户，认证失败时返回ErrAuthenticationFailed
+	Authenticate(username, password string) (Identity, error)
+}
+
+// LDAP/AD认证源的配置
+type LdapConfig struct {
+	// LDAP服务器的地址，如ldap://127.0.0.1:389或ldaps://ad.example.com:636
+	Url string
+	// 是否在ldap://连接上使用StartTLS
+	StartTLS bool
+	// 是否跳过服务器证书的校验，仅用于测试
+	InsecureSkipVerify bool
+	// 校验服务器证书的CA证书内容(PEM格式)，为空时使用系统的CA证书
+	Ca []byte
+	// 用于搜索用户和组的帐号DN及密码，为空时匿名搜索
+	BindDN       string
+	BindPassword string
+	// 搜索用户的起始DN
+	UserBaseDN string
+	// 搜索用户的过滤条件，%s将被替换为转义后的用户名，如(&(objectClass=person)(uid=%s))
+	UserFilter string
+	// 用户名，电子邮件，真实名字及用户所属组对应的属性
+	UsernameAttr string
+	EmailAttr    string
+	RealnameAttr string
+	MemberOfAttr string
+	// 搜索组的起始DN，为空时不搜索组，仅使用用户的MemberOfAttr属性
+	GroupBaseDN string
+	// 搜索用户所属组的过滤条件，%s将被替换为转义后的用户DN，如(&(objectClass=groupOfNames)(member=%s))
+	GroupFilter string
+	// 组名对应的属性
+	GroupNameAttr string
+	// 组映射关系，键为LDAP组的名称或DN，值为本地用户组的名称
+	GroupMapping map[string]string
+	// 属于这些组(组名称或DN)的用户为系统管理员
+	AdminGroups []string
+	// 连接及操作的超时时间，单位秒
+	Timeout int
+}
+
+// LdapAuthenticator 使用LDAP/AD认证用户
+type LdapAuthenticator struct {
+	conf LdapConfig
+}
+
+// OIDC认证源的配置，sysadm作为relying party使用授权码及PKCE流程认证用户
+type OidcConfig struct {
+	// OIDC provider的issuer，如https://accounts.example.com，用于获取provider的配置(/.well-known/openid-configuration)
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// 认证后provider将用户重定向到的地址，如https://sysadm.example.com/oidc/callback
+	RedirectURL string
+	// 请求的scope，为空时使用DefaultOidcScopes，openid总是被请求
+	Scopes []string
+	// 用户名，电子邮件，真实名字及用户所属组对应的claim
+	UsernameClaim string
+	EmailClaim    string
+	RealnameClaim string
+	GroupsClaim   string
+	// 组映射关系，键为provider中组的名称，值为本地用户组的名称
+	GroupMapping map[string]string
+	// 属于这些组的用户为系统管理员
+	AdminGroups []string
+	// 是否跳过provider证书的校验，仅用于测试
+	InsecureSkipVerify bool
+	// 校验provider证书的CA证书内容(PEM格式)，为空时使用系统的CA证书
+	Ca []byte
+	// 访问provider的超时时间，单位秒
+	Timeout int
+}
+
+// provider的配置(/.well-known/openid-configuration)中sysadm需要的部分
+type oidcMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JwksURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// OidcProvider 使用OIDC provider认证用户
+type OidcProvider struct {
+	conf   OidcConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *oidcMetadata
+	keys     jose.JSONWebKeySet
+	keysTime time.Time
 }
 
 // 存储运行期数据
@@ -71,4 +305,6 @@ type runingData struct {
 	sessionOption sessions.Options
 	pageInfo      sysadmSetting.PageInfo
 	objectEntiy   sysadmObjects.ObjectEntity
+	// 依次尝试的认证源，本地用户的认证总是最先尝试
+	authenticators []Authenticator
 }
@@ -20,8 +20,39 @@
 
 package user
 
+import "time"
+
 // default path for saving session data
 var DefaultSessionPath = "/"
 
 // default max age of session
 var DefaultMaxAge = 1800
+
+// default timeout of sessions which are not accessed
+var DefaultSessionIdleTimeout = 30 * time.Minute
+
+// default lifetime of sessions since they are created
+var DefaultSessionAbsoluteTimeout = 12 * time.Hour
+
+// prefix of keys of sessions in redis
+var sessionKeyPrefix = "sysadm:session:"
+
+// prefix of keys of the session indexes of users in redis
+var userSessionsKeyPrefix = "sysadm:usersessions:"
+
+// the session value which the ID of the logined user is saved in
+var sessionUserIDKey = "userid"
+
+// the length of session ID in bytes before it is encoded
+var sessionIDLength = 32
+
+// the session values which the login waiting for the second factor is saved in
+var sessionPendingUserIDKey = "pendingUserid"
+var sessionPendingTimeKey = "pendingTime"
+var sessionPendingAttemptsKey = "pendingAttempts"
+
+// default timeout of the login waiting for the second factor
+var DefaultPendingLoginTimeout = 5 * time.Minute
+
+// default max attempts of the second factor for a login
+var DefaultPendingLoginAttempts = 5
@@ -0,0 +1,290 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package user
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"sysadm/redis"
+)
+
+// fakeRedis is an in-memory redis server which serves the commands used by RedisStore over net.Pipe.
+// keys which have been expired are removed before each command is run
+type fakeRedis struct {
+	mu      sync.Mutex
+	hashes  map[string]map[string]string
+	zsets   map[string]map[string]float64
+	expires map[string]time.Time
+}
+
+// newFakeRedis return an entity of redis whose connections are served by a new fakeRedis
+func newFakeRedis() (redis.RedisEntity, *fakeRedis) {
+	f := &fakeRedis{hashes: map[string]map[string]string{}, zsets: map[string]map[string]float64{}, expires: map[string]time.Time{}}
+	client := goredis.NewClient(&goredis.Options{
+		Addr: "fakeredis",
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			server, conn := net.Pipe()
+			go f.serve(server)
+			return conn, nil
+		},
+	})
+
+	return redis.RedisSingle{Client: client}, f
+}
+
+// exists return true if key is in the server
+func (f *fakeRedis) exists(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expire()
+
+	_, ok := f.hashes[key]
+	return ok
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	var queued [][]string
+	multi := false
+	for {
+		args, e := readCommand(reader)
+		if e != nil {
+			return
+		}
+
+		var reply string
+		switch strings.ToLower(args[0]) {
+		case "watch", "unwatch":
+			reply = "+OK\r\n"
+		case "multi":
+			multi = true
+			queued = nil
+			reply = "+OK\r\n"
+		case "exec":
+			replies := make([]string, 0, len(queued))
+			for _, cmd := range queued {
+				replies = append(replies, f.run(cmd))
+			}
+			multi = false
+			reply = fmt.Sprintf("*%d\r\n%s", len(replies), strings.Join(replies, ""))
+		default:
+			if multi {
+				queued = append(queued, args)
+				reply = "+QUEUED\r\n"
+			} else {
+				reply = f.run(args)
+			}
+		}
+
+		if _, e := io.WriteString(conn, reply); e != nil {
+			return
+		}
+	}
+}
+
+// run the command and return the reply of it in RESP
+func (f *fakeRedis) run(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expire()
+
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "hget":
+		value, ok := f.hashes[key][args[2]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulkString(value)
+	case "hgetall":
+		var items []string
+		for field, value := range f.hashes[key] {
+			items = append(items, field, value)
+		}
+		return bulkArray(items)
+	case "hset":
+		if f.hashes[key] == nil {
+			f.hashes[key] = map[string]string{}
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			f.hashes[key][args[i]] = args[i+1]
+		}
+		return ":1\r\n"
+	case "pexpire", "expire":
+		n, _ := strconv.ParseInt(args[2], 10, 64)
+		unit := time.Millisecond
+		if strings.ToLower(args[0]) == "expire" {
+			unit = time.Second
+		}
+		if _, ok := f.hashes[key]; !ok {
+			if _, ok := f.zsets[key]; !ok {
+				return ":0\r\n"
+			}
+		}
+		f.expires[key] = time.Now().Add(time.Duration(n) * unit)
+		return ":1\r\n"
+	case "del":
+		num := 0
+		for _, k := range args[1:] {
+			if f.delete(k) {
+				num++
+			}
+		}
+		return ":" + strconv.Itoa(num) + "\r\n"
+	case "zadd":
+		if f.zsets[key] == nil {
+			f.zsets[key] = map[string]float64{}
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			score, _ := strconv.ParseFloat(args[i], 64)
+			f.zsets[key][args[i+1]] = score
+		}
+		return ":1\r\n"
+	case "zrem":
+		num := 0
+		for _, member := range args[2:] {
+			if _, ok := f.zsets[key][member]; ok {
+				delete(f.zsets[key], member)
+				num++
+			}
+		}
+		return ":" + strconv.Itoa(num) + "\r\n"
+	case "zrange":
+		members := make([]string, 0, len(f.zsets[key]))
+		for member := range f.zsets[key] {
+			members = append(members, member)
+		}
+		sort.Slice(members, func(i, j int) bool {
+			return f.zsets[key][members[i]] < f.zsets[key][members[j]]
+		})
+		return bulkArray(members)
+	case "scan":
+		match := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.ToLower(args[i]) == "match" {
+				match = args[i+1]
+			}
+		}
+		var keys []string
+		for k := range f.keys() {
+			if ok, _ := path.Match(match, k); ok {
+				keys = append(keys, k)
+			}
+		}
+		return "*2\r\n" + bulkString("0") + bulkArray(keys)
+	}
+
+	return "-ERR unknown command " + args[0] + "\r\n"
+}
+
+func (f *fakeRedis) keys() map[string]bool {
+	keys := map[string]bool{}
+	for k := range f.hashes {
+		keys[k] = true
+	}
+	for k := range f.zsets {
+		keys[k] = true
+	}
+
+	return keys
+}
+
+func (f *fakeRedis) delete(key string) bool {
+	_, isHash := f.hashes[key]
+	_, isZset := f.zsets[key]
+	delete(f.hashes, key)
+	delete(f.zsets, key)
+	delete(f.expires, key)
+
+	return isHash || isZset
+}
+
+func (f *fakeRedis) expire() {
+	now := time.Now()
+	for key, at := range f.expires {
+		if !now.Before(at) {
+			f.delete(key)
+		}
+	}
+}
+
+// readCommand read a command which is sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, e := reader.ReadString('\n')
+	if e != nil {
+		return nil, e
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+
+	num, e := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if e != nil {
+		return nil, e
+	}
+
+	args := make([]string, 0, num)
+	for i := 0; i < num; i++ {
+		line, e = reader.ReadString('\n')
+		if e != nil {
+			return nil, e
+		}
+		size, e := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if e != nil {
+			return nil, e
+		}
+
+		buf := make([]byte, size+2)
+		if _, e := io.ReadFull(reader, buf); e != nil {
+			return nil, e
+		}
+		args = append(args, string(buf[:size]))
+	}
+
+	return args, nil
+}
+
+func bulkString(value string) string {
+	return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+}
+
+func bulkArray(values []string) string {
+	ret := "*" + strconv.Itoa(len(values)) + "\r\n"
+	for _, value := range values {
+		ret += bulkString(value)
+	}
+
+	return ret
+}
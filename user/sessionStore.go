@@ -0,0 +1,394 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package user
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	gsessions "github.com/gorilla/sessions"
+	sessions "github.com/wangyysde/sysadmSessions"
+	"sysadm/redis"
+	"sysadm/utils"
+)
+
+// NewRedisStore create a session store on entity. DefaultSessionIdleTimeout and DefaultSessionAbsoluteTimeout will be
+// used if idleTimeout or absoluteTimeout is not large than zero
+func NewRedisStore(entity redis.RedisEntity, options sessions.Options, idleTimeout, absoluteTimeout time.Duration) (*RedisStore, error) {
+	if entity == nil {
+		return nil, fmt.Errorf("can not create session store on nil entity")
+	}
+
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultSessionIdleTimeout
+	}
+
+	if absoluteTimeout <= 0 {
+		absoluteTimeout = DefaultSessionAbsoluteTimeout
+	}
+
+	if idleTimeout > absoluteTimeout {
+		idleTimeout = absoluteTimeout
+	}
+
+	return &RedisStore{entity: entity, options: options.ToGorillaOptions(), idleTimeout: idleTimeout, absoluteTimeout: absoluteTimeout}, nil
+}
+
+// Options set the options of the cookies of sessions
+func (s *RedisStore) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+}
+
+// Get return the session named name which is cached in the registry of the request
+func (s *RedisStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New load the session whose ID is in the cookie of the request from redis, and reset the idle timeout of it.
+// a new session will be returned if the session does not exist or has been expired
+func (s *RedisStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, e := r.Cookie(name)
+	if e != nil || !isValidSessionID(cookie.Value) {
+		return session, nil
+	}
+
+	ok, e := s.load(r.Context(), session, cookie.Value)
+	if e != nil || !ok {
+		return session, e
+	}
+	session.ID = cookie.Value
+	session.IsNew = false
+
+	return session, nil
+}
+
+// Save write the session into redis and send the ID of it to the browser by cookie.
+// the session will be destroyed if MaxAge of its options is less than zero
+func (s *RedisStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	ctx := r.Context()
+	if session.Options == nil {
+		options := *s.options
+		session.Options = &options
+	}
+
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if e := s.destroy(ctx, session.ID); e != nil {
+				return e
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		id, e := newSessionID()
+		if e != nil {
+			return e
+		}
+		session.ID = id
+	}
+
+	if e := s.save(ctx, r, session); e != nil {
+		return e
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), session.ID, session.Options))
+
+	return nil
+}
+
+// Regenerate issue a new ID to the session and remove the session with the old ID, so the ID which may have been known
+// by others before login is not valid any more. the session is saved with the new ID
+func (s *RedisStore) Regenerate(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.ID != "" {
+		if e := s.destroy(r.Context(), session.ID); e != nil {
+			return e
+		}
+	}
+	session.ID = ""
+	session.IsNew = true
+
+	return s.Save(r, w, session)
+}
+
+// ListUserSessions return the active sessions of the user
+func (s *RedisStore) ListUserSessions(ctx context.Context, userid string) ([]SessionInfo, error) {
+	var ret []SessionInfo
+
+	userKey := userSessionsKey(userid)
+	ids, e := s.entity.ZRange(ctx, userKey, 0, -1).Result()
+	if e != nil {
+		return ret, e
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		fields, e := redis.HGetAll(s.entity, ctx, sessionKey(id))
+		if e != nil {
+			return ret, e
+		}
+
+		info := buildSessionInfo(id, fields)
+		// the session has been expired or has been taken by another user
+		if info.UserID != userid || s.ttl(info.Created, info.LastAccess) <= now.Sub(info.LastAccess) {
+			_ = redis.ZRem(s.entity, ctx, userKey, id)
+			continue
+		}
+		ret = append(ret, info)
+	}
+
+	return ret, nil
+}
+
+// DestroyUserSessions force the user to logout by destroying all sessions of the user.
+// return the number of the sessions have been destroyed
+func (s *RedisStore) DestroyUserSessions(ctx context.Context, userid string) (int, error) {
+	userKey := userSessionsKey(userid)
+	ids, e := s.entity.ZRange(ctx, userKey, 0, -1).Result()
+	if e != nil {
+		return 0, e
+	}
+
+	num := 0
+	for _, id := range ids {
+		key := sessionKey(id)
+		owner, e := s.entity.HGet(ctx, key, "userid").Result()
+		if e == goredis.Nil {
+			continue
+		}
+		if e != nil {
+			return num, e
+		}
+
+		if owner != userid {
+			continue
+		}
+		if e := redis.Del(s.entity, ctx, key); e != nil {
+			return num, e
+		}
+		num++
+	}
+
+	return num, redis.Del(s.entity, ctx, userKey)
+}
+
+// DestroyAllSessions force all users to logout by destroying all sessions.
+// return the number of the sessions have been destroyed
+func (s *RedisStore) DestroyAllSessions(ctx context.Context) (int, error) {
+	num := 0
+	e := s.entity.ScanKeys(ctx, sessionKeyPrefix+"*", redis.DefaultScanCount, func(key string) error {
+		num++
+		return redis.Del(s.entity, ctx, key)
+	})
+	if e != nil {
+		return num, e
+	}
+
+	e = s.entity.ScanKeys(ctx, userSessionsKeyPrefix+"*", redis.DefaultScanCount, func(key string) error {
+		return redis.Del(s.entity, ctx, key)
+	})
+
+	return num, e
+}
+
+// load read the values of the session with id from redis into session. false will be returned if the session does
+// not exist or has been expired
+func (s *RedisStore) load(ctx context.Context, session *gsessions.Session, id string) (bool, error) {
+	key := sessionKey(id)
+	fields, e := redis.HGetAll(s.entity, ctx, key)
+	if e != nil || len(fields) < 1 {
+		return false, e
+	}
+
+	info := buildSessionInfo(id, fields)
+	now := time.Now()
+	ttl := s.ttl(info.Created, now)
+	if ttl <= 0 || now.Sub(info.LastAccess) >= s.idleTimeout {
+		return false, s.destroy(ctx, id)
+	}
+
+	if e := gob.NewDecoder(bytes.NewBufferString(fields["data"])).Decode(&session.Values); e != nil {
+		return false, fmt.Errorf("decode values of session error: %s", e)
+	}
+
+	// reset the idle timeout of the session
+	_, e = s.entity.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(ctx, key, "lastAccess", now.UnixMilli())
+		pipe.PExpire(ctx, key, ttl)
+		return nil
+	})
+
+	return e == nil, e
+}
+
+// save write the values of session into redis and add the session into the session index of the user
+func (s *RedisStore) save(ctx context.Context, r *http.Request, session *gsessions.Session) error {
+	var data bytes.Buffer
+	if e := gob.NewEncoder(&data).Encode(session.Values); e != nil {
+		return fmt.Errorf("encode values of session error: %s", e)
+	}
+
+	key := sessionKey(session.ID)
+	now := time.Now()
+	created := now
+	expired := false
+	userid := utils.Interface2String(session.Values[sessionUserIDKey])
+	// the session is read and written in one transaction, so a session which has been destroyed by others will not be
+	// written back after it was loaded
+	e := s.entity.Watch(ctx, func(tx *goredis.Tx) error {
+		createdStr, e := tx.HGet(ctx, key, "created").Result()
+		if e != nil && e != goredis.Nil {
+			return e
+		}
+		if e == goredis.Nil {
+			if !session.IsNew {
+				return fmt.Errorf("session has been destroyed")
+			}
+		} else {
+			created = parseUnixMilli(createdStr)
+		}
+
+		ttl := s.ttl(created, now)
+		if ttl <= 0 {
+			expired = true
+			return nil
+		}
+
+		_, e = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.HSet(ctx, key, "data", data.String(), "userid", userid, "created", created.UnixMilli(), "lastAccess", now.UnixMilli(), "ip", clientIP(r), "userAgent", r.UserAgent())
+			pipe.PExpire(ctx, key, ttl)
+			return nil
+		})
+		return e
+	}, key)
+	if e != nil {
+		return e
+	}
+
+	if expired {
+		_ = s.destroy(ctx, session.ID)
+		return fmt.Errorf("session has been expired")
+	}
+
+	if userid == "" {
+		return nil
+	}
+
+	userKey := userSessionsKey(userid)
+	if e := redis.ZAdd(s.entity, ctx, userKey, float64(created.UnixMilli()), session.ID); e != nil {
+		return e
+	}
+	_, e = redis.Expire(s.entity, ctx, userKey, s.absoluteTimeout)
+
+	return e
+}
+
+// destroy remove the session with id from redis and the session index of its user
+func (s *RedisStore) destroy(ctx context.Context, id string) error {
+	key := sessionKey(id)
+	userid, e := s.entity.HGet(ctx, key, "userid").Result()
+	if e != nil && e != goredis.Nil {
+		return e
+	}
+
+	if e := redis.Del(s.entity, ctx, key); e != nil {
+		return e
+	}
+
+	if userid == "" {
+		return nil
+	}
+
+	return redis.ZRem(s.entity, ctx, userSessionsKey(userid), id)
+}
+
+// ttl return how long the session created at created can be kept after it is accessed at now
+func (s *RedisStore) ttl(created, now time.Time) time.Duration {
+	remain := created.Add(s.absoluteTimeout).Sub(now)
+	if remain > s.idleTimeout {
+		return s.idleTimeout
+	}
+
+	return remain
+}
+
+func buildSessionInfo(id string, fields map[string]string) SessionInfo {
+	return SessionInfo{
+		ID:         id,
+		UserID:     fields["userid"],
+		IP:         fields["ip"],
+		UserAgent:  fields["userAgent"],
+		Created:    parseUnixMilli(fields["created"]),
+		LastAccess: parseUnixMilli(fields["lastAccess"]),
+	}
+}
+
+func parseUnixMilli(value string) time.Time {
+	ms, e := strconv.ParseInt(value, 10, 64)
+	if e != nil {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(ms)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, sessionIDLength)
+	if _, e := rand.Read(b); e != nil {
+		return "", fmt.Errorf("generate session ID error: %s", e)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func isValidSessionID(id string) bool {
+	b, e := base64.RawURLEncoding.DecodeString(id)
+
+	return e == nil && len(b) == sessionIDLength
+}
+
+func clientIP(r *http.Request) string {
+	host, _, e := net.SplitHostPort(r.RemoteAddr)
+	if e != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func sessionKey(id string) string {
+	return sessionKeyPrefix + id
+}
+
+func userSessionsKey(userid string) string {
+	return userSessionsKeyPrefix + userid
+}
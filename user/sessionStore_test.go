@@ -0,0 +1,162 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package user
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gsessions "github.com/gorilla/sessions"
+	sessions "github.com/wangyysde/sysadmSessions"
+)
+
+const testSessionName = "sysadm"
+
+func newTestStore(t *testing.T, idleTimeout time.Duration) (*RedisStore, *fakeRedis) {
+	entity, server := newFakeRedis()
+	store, e := NewRedisStore(entity, sessions.Options{Path: "/", MaxAge: 3600}, idleTimeout, time.Hour)
+	if e != nil {
+		t.Fatalf("create session store error: %s", e)
+	}
+
+	return store, server
+}
+
+// loadSession load the session whose ID is id. a new session is returned if id is empty
+func loadSession(t *testing.T, store *RedisStore, id string) (*gsessions.Session, *http.Request) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if id != "" {
+		r.AddCookie(&http.Cookie{Name: testSessionName, Value: id})
+	}
+
+	session, e := store.New(r, testSessionName)
+	if e != nil {
+		t.Fatalf("load session %s error: %s", id, e)
+	}
+
+	return session, r
+}
+
+// saveUserSession save a new session which is logged in by userid and return the ID of it
+func saveUserSession(t *testing.T, store *RedisStore, userid string) string {
+	session, r := loadSession(t, store, "")
+	session.Values[sessionUserIDKey] = userid
+	if e := store.Save(r, httptest.NewRecorder(), session); e != nil {
+		t.Fatalf("save session of user %s error: %s", userid, e)
+	}
+
+	return session.ID
+}
+
+func TestRedisStoreRegenerate(t *testing.T) {
+	store, server := newTestStore(t, time.Hour)
+
+	session, r := loadSession(t, store, "")
+	if e := store.Save(r, httptest.NewRecorder(), session); e != nil {
+		t.Fatalf("save anonymous session error: %s", e)
+	}
+	oldID := session.ID
+
+	session, r = loadSession(t, store, oldID)
+	if session.IsNew {
+		t.Fatalf("session %s should be loaded from redis", oldID)
+	}
+	session.Values[sessionUserIDKey] = "1"
+	w := httptest.NewRecorder()
+	if e := store.Regenerate(r, w, session); e != nil {
+		t.Fatalf("regenerate session error: %s", e)
+	}
+
+	if session.ID == oldID || !isValidSessionID(session.ID) {
+		t.Errorf("session should get a new ID after login, got %s", session.ID)
+	}
+	if server.exists(sessionKey(oldID)) {
+		t.Errorf("session with the old ID %s should be removed", oldID)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != session.ID {
+		t.Errorf("cookie should carry the new ID %s, got %v", session.ID, cookies)
+	}
+
+	if old, _ := loadSession(t, store, oldID); !old.IsNew {
+		t.Errorf("the old ID %s should not be valid after login", oldID)
+	}
+	loaded, _ := loadSession(t, store, session.ID)
+	if loaded.IsNew || loaded.Values[sessionUserIDKey] != "1" {
+		t.Errorf("session with the new ID should be logged in by user 1, got %v", loaded.Values)
+	}
+}
+
+func TestRedisStoreDestroyUserSessions(t *testing.T) {
+	store, _ := newTestStore(t, time.Hour)
+	ctx := context.Background()
+
+	first := saveUserSession(t, store, "1")
+	second := saveUserSession(t, store, "1")
+	other := saveUserSession(t, store, "2")
+
+	list, e := store.ListUserSessions(ctx, "1")
+	if e != nil || len(list) != 2 {
+		t.Fatalf("user 1 should have 2 sessions, got %v error %v", list, e)
+	}
+
+	// the session is loaded before the user is forced to logout, and it should not be written back
+	loaded, r := loadSession(t, store, first)
+	num, e := store.DestroyUserSessions(ctx, "1")
+	if e != nil || num != 2 {
+		t.Fatalf("2 sessions of user 1 should be destroyed, got %d error %v", num, e)
+	}
+	if e := store.Save(r, httptest.NewRecorder(), loaded); e == nil {
+		t.Errorf("session %s which has been destroyed should not be saved", first)
+	}
+
+	for _, id := range []string{first, second} {
+		if session, _ := loadSession(t, store, id); !session.IsNew {
+			t.Errorf("session %s of user 1 should be destroyed", id)
+		}
+	}
+	if session, _ := loadSession(t, store, other); session.IsNew {
+		t.Errorf("session %s of user 2 should be kept", other)
+	}
+	if list, _ := store.ListUserSessions(ctx, "1"); len(list) != 0 {
+		t.Errorf("user 1 should not have any session, got %v", list)
+	}
+}
+
+func TestRedisStoreIdleTimeout(t *testing.T) {
+	store, _ := newTestStore(t, 100*time.Millisecond)
+
+	id := saveUserSession(t, store, "1")
+	time.Sleep(50 * time.Millisecond)
+	if session, _ := loadSession(t, store, id); session.IsNew {
+		t.Fatalf("session %s should be active before the idle timeout", id)
+	}
+
+	// the idle timeout is reset by the access above
+	time.Sleep(70 * time.Millisecond)
+	if session, _ := loadSession(t, store, id); session.IsNew {
+		t.Fatalf("session %s should be active after it has been accessed", id)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if session, _ := loadSession(t, store, id); !session.IsNew {
+		t.Errorf("session %s should be expired after the idle timeout", id)
+	}
+}
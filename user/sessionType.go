@@ -0,0 +1,48 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package user
+
+import (
+	"time"
+
+	gsessions "github.com/gorilla/sessions"
+	"sysadm/redis"
+)
+
+// RedisStore saves the data of sessions in redis, so sessions can be shared among the replicas of sysadm and can be
+// revoked on server side. only the ID of a session is sent to the browser
+type RedisStore struct {
+	entity  redis.RedisEntity
+	options *gsessions.Options
+
+	// a session will be expired if it has not been accessed in idleTimeout
+	idleTimeout time.Duration
+
+	// a session will be expired after absoluteTimeout since it was created no matter whether it is accessed
+	absoluteTimeout time.Duration
+}
+
+// SessionInfo is the information of an active session
+type SessionInfo struct {
+	ID         string
+	UserID     string
+	IP         string
+	UserAgent  string
+	Created    time.Time
+	LastAccess time.Time
+}
@@ -24,6 +24,7 @@ import (
 	"strings"
 	"time"
 
+	gsessions "github.com/gorilla/sessions"
 	"github.com/wangyysde/sysadmServer"
 	sessions "github.com/wangyysde/sysadmSessions"
 	"sysadm/utils"
@@ -139,6 +140,32 @@ func ClearSession(c *sysadmServer.Context) error {
 	return nil
 }
 
+// RegenerateSession issue a new ID to the session of the request to prevent session fixation. it should be called
+// before the ID of the user is saved into the session on login. nothing will be done if the session is not stored in
+// RedisStore, because there is not any session ID for the sessions stored in cookies
+func RegenerateSession(c *sysadmServer.Context) error {
+	if c == nil {
+		return fmt.Errorf("Context is nil.")
+	}
+
+	wrapper, ok := sessions.Default(c).(interface{ Session() *gsessions.Session })
+	if !ok {
+		return nil
+	}
+
+	session := wrapper.Session()
+	if session == nil {
+		return fmt.Errorf("can not get session of the request")
+	}
+
+	store, ok := session.Store().(*RedisStore)
+	if !ok {
+		return nil
+	}
+
+	return store.Regenerate(c.Request, c.Writer, session)
+}
+
 func IsLogin(c *sysadmServer.Context, sessionName string) (bool, int, error) {
 	if c == nil {
 		return false, 0, fmt.Errorf("can not check whether login on an empty connection")
@@ -160,3 +187,80 @@ func IsLogin(c *sysadmServer.Context, sessionName string) (bool, int, error) {
 
 	return true, useridInt, nil
 }
+
+// SetPendingLogin save the ID of the user whose password has been verified but the second factor (such as TOTP) has
+// not into the session. the user is not login until CompleteLogin is called, so IsLogin returns false for the session
+func SetPendingLogin(c *sysadmServer.Context, userid string) error {
+	if c == nil {
+		return fmt.Errorf("Context is nil.")
+	}
+
+	userid = strings.TrimSpace(userid)
+	if userid == "" {
+		return fmt.Errorf("user ID should not be empty")
+	}
+
+	session := sessions.Default(c)
+	session.Delete("isLogin")
+	session.Delete(sessionUserIDKey)
+	session.Set(sessionPendingUserIDKey, userid)
+	session.Set(sessionPendingTimeKey, time.Now().Unix())
+	session.Set(sessionPendingAttemptsKey, 0)
+
+	return session.Save()
+}
+
+// GetPendingLogin get the ID of the user who is waiting for the second factor. an attempt is counted every time it is
+// called, and the pending login will be discarded if it has timed out or the attempts have exceeded the limit
+func GetPendingLogin(c *sysadmServer.Context) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("Context is nil.")
+	}
+
+	session := sessions.Default(c)
+	userid, _ := session.Get(sessionPendingUserIDKey).(string)
+	if userid == "" {
+		return "", fmt.Errorf("there is not any login waiting for the second factor")
+	}
+
+	pendingTime, _ := utils.Interface2Int(session.Get(sessionPendingTimeKey))
+	attempts, _ := utils.Interface2Int(session.Get(sessionPendingAttemptsKey))
+	if time.Since(time.Unix(int64(pendingTime), 0)) > DefaultPendingLoginTimeout || attempts >= DefaultPendingLoginAttempts {
+		clearPendingLogin(session)
+		_ = session.Save()
+		return "", fmt.Errorf("the login waiting for the second factor has expired")
+	}
+
+	session.Set(sessionPendingAttemptsKey, attempts+1)
+	if err := session.Save(); err != nil {
+		return "", err
+	}
+
+	return userid, nil
+}
+
+// CompleteLogin mark the session as login by the user after all the factors have been verified. the session ID is
+// regenerated to prevent session fixation and the pending login is discarded
+func CompleteLogin(c *sysadmServer.Context, userid string) error {
+	if c == nil {
+		return fmt.Errorf("Context is nil.")
+	}
+
+	if err := RegenerateSession(c); err != nil {
+		return err
+	}
+
+	session := sessions.Default(c)
+	clearPendingLogin(session)
+	session.Set("isLogin", true)
+	session.Set(sessionUserIDKey, userid)
+
+	return session.Save()
+}
+
+// clearPendingLogin delete the values of the pending login from session
+func clearPendingLogin(session sessions.Session) {
+	session.Delete(sessionPendingUserIDKey)
+	session.Delete(sessionPendingTimeKey)
+	session.Delete(sessionPendingAttemptsKey)
+}
@@ -21,4 +21,27 @@ const (
 	dmiDir       string = "/sys/class/dmi"
 	ppcDevTree   string = "/proc/device-tree"
 	s390xDevTree string = "/etc"
-)
\ No newline at end of file
+)
+
+// algorithms of password hashing. the hash of a password is prefixed with the name of its algorithm except md5
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	// md5 is only used to verify the passwords which were hashed before argon2id and bcrypt were supported
+	PasswordAlgorithmMd5 = "md5"
+)
+
+// DefaultPasswordAlgorithm is the algorithm which new passwords are hashed with
+var DefaultPasswordAlgorithm = PasswordAlgorithmArgon2id
+
+// parameters of argon2id
+var (
+	argon2Memory  uint32 = 64 * 1024
+	argon2Time    uint32 = 3
+	argon2Threads uint8  = 2
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+)
+
+// cost of bcrypt
+var bcryptCost = 12
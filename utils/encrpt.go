@@ -29,6 +29,8 @@ import(
    generating md5 data using data and salt. salt can empty.
    return "" if data is empty. 
    otherwise return string
+   md5 should not be used for hashing new passwords, it is kept for verifying the passwords 
+   which were hashed before. use HashPassword to hash passwords instead.
 */
 func Md5Encrypt(data string, salt string) string{
 	if len(data) < 1 {
@@ -0,0 +1,148 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword hash password with DefaultPasswordAlgorithm. the result is encoded with the algorithm and its
+// parameters, so it can be verified without the salt field
+func HashPassword(password string) (string, error) {
+	return HashPasswordWith(DefaultPasswordAlgorithm, password)
+}
+
+// HashPasswordWith hash password with algorithm which is one of argon2id and bcrypt
+func HashPasswordWith(algorithm, password string) (string, error) {
+	if len(password) < 1 {
+		return "", fmt.Errorf("password should not be empty")
+	}
+
+	switch algorithm {
+	case PasswordAlgorithmArgon2id:
+		salt := make([]byte, argon2SaltLen)
+		if _, e := rand.Read(salt); e != nil {
+			return "", fmt.Errorf("generate salt error: %s", e)
+		}
+		key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+		return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", PasswordAlgorithmArgon2id, argon2.Version, argon2Memory, argon2Time,
+			argon2Threads, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	case PasswordAlgorithmBcrypt:
+		hash, e := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		if e != nil {
+			return "", e
+		}
+
+		return string(hash), nil
+	}
+
+	return "", fmt.Errorf("password hashing algorithm %s is not supported", algorithm)
+}
+
+// PasswordAlgorithm return the algorithm which hash was hashed with. the hashes without algorithm prefix are md5
+func PasswordAlgorithm(hash string) string {
+	hash = strings.TrimSpace(hash)
+	switch {
+	case strings.HasPrefix(hash, "$"+PasswordAlgorithmArgon2id+"$"):
+		return PasswordAlgorithmArgon2id
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return PasswordAlgorithmBcrypt
+	}
+
+	return PasswordAlgorithmMd5
+}
+
+// VerifyPassword check whether password matches hash. salt is used only by the md5 hashes.
+// needRehash will be true if the password matches but hash was not hashed with DefaultPasswordAlgorithm and the
+// current parameters, then the password should be hashed again and replace the old hash
+func VerifyPassword(password, hash, salt string) (ok bool, needRehash bool, e error) {
+	hash = strings.TrimSpace(hash)
+	if len(password) < 1 || hash == "" {
+		return false, false, nil
+	}
+
+	algorithm := PasswordAlgorithm(hash)
+	switch algorithm {
+	case PasswordAlgorithmArgon2id:
+		ok, needRehash, e = verifyArgon2id(password, hash)
+	case PasswordAlgorithmBcrypt:
+		e = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if e == bcrypt.ErrMismatchedHashAndPassword {
+			return false, false, nil
+		}
+		if e != nil {
+			return false, false, e
+		}
+		cost, _ := bcrypt.Cost([]byte(hash))
+		ok, needRehash = true, cost < bcryptCost
+	default:
+		ok = subtle.ConstantTimeCompare([]byte(Md5Encrypt(password, salt)), []byte(hash)) == 1
+	}
+
+	if !ok || e != nil {
+		return false, false, e
+	}
+
+	return true, needRehash || algorithm != DefaultPasswordAlgorithm, nil
+}
+
+// verifyArgon2id check password with hash which is encoded as $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func verifyArgon2id(password, hash string) (bool, bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, fmt.Errorf("argon2id hash is not valid")
+	}
+
+	var version int
+	if _, e := fmt.Sscanf(parts[2], "v=%d", &version); e != nil || version != argon2.Version {
+		return false, false, fmt.Errorf("version of argon2id hash is not supported")
+	}
+
+	var memory, times uint32
+	var threads uint8
+	if _, e := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &times, &threads); e != nil {
+		return false, false, fmt.Errorf("parameters of argon2id hash are not valid: %s", e)
+	}
+
+	salt, e := base64.RawStdEncoding.DecodeString(parts[4])
+	if e != nil {
+		return false, false, fmt.Errorf("salt of argon2id hash is not valid: %s", e)
+	}
+
+	key, e := base64.RawStdEncoding.DecodeString(parts[5])
+	if e != nil || len(key) < 1 {
+		return false, false, fmt.Errorf("key of argon2id hash is not valid")
+	}
+
+	other := argon2.IDKey([]byte(password), salt, times, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+
+	needRehash := memory < argon2Memory || times < argon2Time || threads < argon2Threads || uint32(len(key)) < argon2KeyLen
+
+	return true, needRehash, nil
+}
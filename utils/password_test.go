@@ -0,0 +1,125 @@
+/* =============================================================
+* @Author:  Wayne Wang <net_use@bzhy.com>
+*
+* @Copyright (c) 2024 Bzhy Network. All rights reserved.
+* @HomePage http://www.sysadm.cn
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at:
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and  limitations under the License.
+* @License GNU Lesser General Public License  https://www.sysadm.cn/lgpl.html
+ */
+
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// weakArgon2id hash password with argon2id and the parameters which are weaker than the current ones
+func weakArgon2id(password string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, 1, 8*1024, 1, argon2KeyLen)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", PasswordAlgorithmArgon2id, argon2.Version, 8*1024, 1, 1,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestHashPassword(t *testing.T) {
+	oldCost := bcryptCost
+	bcryptCost = bcrypt.MinCost
+	defer func() { bcryptCost = oldCost }()
+
+	tests := []struct {
+		algorithm string
+		password  string
+		prefix    string
+		wantErr   bool
+	}{
+		{algorithm: PasswordAlgorithmArgon2id, password: "Secret#1", prefix: "$argon2id$v=19$m=65536,t=3,p=2$"},
+		{algorithm: PasswordAlgorithmBcrypt, password: "Secret#1", prefix: "$2a$04$"},
+		{algorithm: PasswordAlgorithmArgon2id, password: "", wantErr: true},
+		{algorithm: PasswordAlgorithmMd5, password: "Secret#1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		hash, e := HashPasswordWith(tt.algorithm, tt.password)
+		if tt.wantErr {
+			if e == nil {
+				t.Errorf("hash password %q with %s should fail", tt.password, tt.algorithm)
+			}
+			continue
+		}
+
+		if e != nil || !strings.HasPrefix(hash, tt.prefix) {
+			t.Errorf("hash of %s should start with %s, got %s error %v", tt.algorithm, tt.prefix, hash, e)
+			continue
+		}
+		if PasswordAlgorithm(hash) != tt.algorithm {
+			t.Errorf("algorithm of %s should be %s, got %s", hash, tt.algorithm, PasswordAlgorithm(hash))
+		}
+		if ok, _, e := VerifyPassword(tt.password, hash, ""); !ok || e != nil {
+			t.Errorf("password should match its %s hash, got %v error %v", tt.algorithm, ok, e)
+		}
+	}
+
+	first, _ := HashPassword("Secret#1")
+	second, _ := HashPassword("Secret#1")
+	if first == second {
+		t.Errorf("hashes of the same password should be salted differently")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	argon2Hash, e := HashPassword("Secret#1")
+	if e != nil {
+		t.Fatalf("hash password error: %s", e)
+	}
+	bcryptHash, e := bcrypt.GenerateFromPassword([]byte("Secret#1"), bcrypt.MinCost)
+	if e != nil {
+		t.Fatalf("hash password with bcrypt error: %s", e)
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		hash       string
+		salt       string
+		ok         bool
+		needRehash bool
+		wantErr    bool
+	}{
+		{name: "argon2id", password: "Secret#1", hash: argon2Hash, ok: true},
+		{name: "argon2id mismatched", password: "Secret#2", hash: argon2Hash},
+		{name: "argon2id weak parameters", password: "Secret#1", hash: weakArgon2id("Secret#1"), ok: true, needRehash: true},
+		{name: "argon2id invalid", password: "Secret#1", hash: "$argon2id$v=19$m=65536$salt", wantErr: true},
+		{name: "bcrypt", password: "Secret#1", hash: string(bcryptHash), ok: true, needRehash: true},
+		{name: "bcrypt mismatched", password: "Secret#2", hash: string(bcryptHash)},
+		{name: "md5", password: "Secret#1", hash: Md5Encrypt("Secret#1", "salt"), salt: "salt", ok: true, needRehash: true},
+		{name: "md5 mismatched salt", password: "Secret#1", hash: Md5Encrypt("Secret#1", "salt"), salt: "other"},
+		{name: "empty password", password: "", hash: argon2Hash},
+		{name: "empty hash", password: "Secret#1", hash: ""},
+	}
+
+	for _, tt := range tests {
+		ok, needRehash, e := VerifyPassword(tt.password, tt.hash, tt.salt)
+		if (e != nil) != tt.wantErr {
+			t.Errorf("%s: error should be %v, got %v", tt.name, tt.wantErr, e)
+			continue
+		}
+		if ok != tt.ok || needRehash != tt.needRehash {
+			t.Errorf("%s: should be (%v, %v), got (%v, %v)", tt.name, tt.ok, tt.needRehash, ok, needRehash)
+		}
+	}
+}
@@ -26,12 +26,7 @@ import (
 )
 
 type Version struct {
-	// object name. the value should be "version"
-	Name string
-	// table name which hold host data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[VersionSchema]
 }
 
 // 版本信息表结构
@@ -41,7 +36,7 @@ type VersionSchema struct {
 	// 版本名称
 	Name string `form:"name" json:"name" yaml:"name" xml:"name" db:"name"`
 	// 操作系统ID
-	OSID int `form:"osid" json:"osid" yaml:"osid" xml:"osid" db:"osid"`
+	OSID int `form:"osid" json:"osid" yaml:"osid" xml:"osid" db:"osid" references:"os"`
 	// 版本类型ID ，对应见常量定义
 	TypeID int `form:"typeID" json:"typeID" yaml:"typeID" xml:"typeID" db:"typeID"`
 	// 描述
@@ -24,6 +24,11 @@ import (
 	sysadmObjects "sysadm/objects/app"
 )
 
+// register yum to the dependency graph of objects
+func init() {
+	sysadmObjects.RegisterReferrer(sysadmObjects.NewRepository[YumSchema](defaultObjectName, defaultTableName, defaultPkName))
+}
+
 func New(dbConfig *sysadmDB.DbConfig, workingRoot string) (Yum, error) {
 	ret := Yum{}
 	if dbConfig == nil && runData.dbConf == nil {
@@ -53,137 +58,13 @@ func New(dbConfig *sysadmDB.DbConfig, workingRoot string) (Yum, error) {
 			return ret, e
 		}
 	}
-	ret.Name = defaultObjectName
-	ret.TableName = defaultTableName
-	ret.PkName = defaultPkName
+	ret.Repository = sysadmObjects.NewRepository[YumSchema](defaultObjectName, defaultTableName, defaultPkName)
 	return ret, nil
 }
 
-func (y Yum) GetObjectInfoByID(id string) (interface{}, error) {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return nil, fmt.Errorf("the value of id is empty")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(y.TableName, y.PkName, id)
-	if e != nil {
-		return nil, e
-	}
-
-	versionData := YumSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &versionData)
-
-	return versionData, e
-}
-
+// GetObjectInfoByName get the yum information by its name
 func (y Yum) GetObjectInfoByName(name string) (interface{}, error) {
-	name = strings.TrimSpace(strings.ToLower(name))
-	if name == "" {
-		return nil, fmt.Errorf("name is empty")
-	}
-
-	dbData, e := sysadmObjects.GetObjectInfoByID(y.TableName, "name", name)
-	if e != nil {
-		return nil, e
-	}
-
-	versionData := YumSchema{}
-	e = sysadmObjects.Unmarshal(dbData, &versionData)
-
-	return versionData, e
-}
-
-func (y Yum) GetObjectCount(searchContent string, ids, searchKeys []string, conditions map[string]string) (int, error) {
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &YumSchema{}); !ok {
-		return -1, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &YumSchema{}); !ok {
-		return -1, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	return sysadmObjects.GetObjectCount(y.TableName, y.PkName, searchContent, ids, searchKeys, conditions)
-}
-
-func (y Yum) GetObjectList(searchContent string, ids, searchKeys []string, conditions map[string]string,
-	startPos, step int, orders map[string]string) ([]interface{}, error) {
-
-	var ret []interface{}
-	searchContent = strings.TrimSpace(searchContent)
-	if ok, e := sysadmObjects.ValidKeysInSchema(searchKeys, &YumSchema{}); !ok {
-		return ret, fmt.Errorf("search key are not valid. error %s", e)
-	}
-
-	var conditionKeys []string
-	for k, _ := range conditions {
-		conditionKeys = append(conditionKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(conditionKeys, &YumSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of conditions must be the object fields name. error %s", e)
-	}
-
-	var orderKeys []string
-	for k, _ := range orders {
-		orderKeys = append(orderKeys, k)
-	}
-	if ok, e := sysadmObjects.ValidKeysInSchema(orderKeys, &YumSchema{}); !ok {
-		return ret, fmt.Errorf("the keys of orders must be the object fields name.error %s", e)
-	}
-
-	dbData, e := sysadmObjects.GetObjectList(y.TableName, y.PkName, searchContent, ids, searchKeys, conditions, startPos, step, orders)
-	if e != nil {
-		return ret, e
-	}
-
-	var tmpRes []interface{}
-	for _, v := range dbData {
-		yumdata := &YumSchema{}
-		if e := sysadmObjects.Unmarshal(v, yumdata); e != nil {
-			return ret, e
-		}
-		tmpRes = append(tmpRes, *yumdata)
-	}
-
-	return tmpRes, nil
-}
-
-func (y Yum) AddObject(data interface{}) error {
-	versionSchemaData, ok := data.(YumSchema)
-	if !ok {
-		return fmt.Errorf("the data try to add is not valid")
-	}
-
-	insertData, e := sysadmObjects.Marshal(versionSchemaData)
-	if e != nil {
-		return e
-	}
-
-	return sysadmObjects.AddObject(y.TableName, "", insertData)
-}
-
-func (y Yum) AddObjectByTx(data interface{}) (map[string]interface{}, string, error) {
-	addData := make(map[string]interface{}, 0)
-
-	schemaData, ok := data.(YumSchema)
-	if !ok {
-		return addData, "", fmt.Errorf("there is an error occurred when coverting data to Yum Schema schema")
-	}
-
-	addData, e := sysadmObjects.Marshal(schemaData)
-	if e != nil {
-		return addData, "", e
-	}
-
-	return addData, y.TableName, nil
-}
-
-func (y Yum) GetObjectIDFieldName() (string, string, error) {
-	return y.TableName, y.PkName, nil
+	return y.GetByField("name", strings.ToLower(name))
 }
 
 func GetYumListByOSIDAndVersionID(osid, versionid int) ([]YumSchema, error) {
@@ -26,12 +26,7 @@ import (
 )
 
 type Yum struct {
-	// object name. the value should be "version"
-	Name string
-	// table name which hold host data in DB
-	TableName string
-	// field name of primary key in the table
-	PkName string
+	sysadmObjects.Repository[YumSchema]
 }
 
 // Yum信息表结构
@@ -41,9 +36,9 @@ type YumSchema struct {
 	// Yum名称，作为yum的标识符
 	Name string `form:"name" json:"name" yaml:"name" xml:"name" db:"name"`
 	// 操作系统ID
-	OSID int `form:"osid" json:"osid" yaml:"osid" xml:"osid" db:"osid"`
+	OSID int `form:"osid" json:"osid" yaml:"osid" xml:"osid" db:"osid" references:"os"`
 	// 版本版本ID ，
-	VersionID int `form:"versionid" json:"versionid" yaml:"versionid" xml:"versionid" db:"versionid"`
+	VersionID int `form:"versionid" json:"versionid" yaml:"versionid" xml:"versionid" db:"versionid" references:"version"`
 	// what type of the yum is it,such as os, docker, kubernetes,对应类型定义
 	TypeID int `form:"typeid" json:"typeid" yaml:"typeid" xml:"typeid" db:"typeid"`
 	// which catalog  of the yum is it,such as base, update,plus,......